@@ -0,0 +1,149 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+const applyDesc = `
+This command applies the releases declared in the file of --file:
+
+    releases:
+    - name: db
+      namespace: apps
+      chart: postgresql
+      version: 10.3.0
+      repoURL: https://charts.example.com/
+    - name: web
+      namespace: apps
+      chart: web
+      version: 1.2.0
+      repoURL: https://charts.example.com/
+      needs:
+      - db
+      values:
+        replicas: 2
+    - name: legacy
+      namespace: apps
+      installed: false
+
+The missing releases are installed, the ones whose chart or values differ are
+upgraded and the ones declared with 'installed: false' are uninstalled. The
+releases are applied after the releases they need, given as "name" in the
+same namespace or as "namespace/name", and uninstalled in the reverse order.
+The releases without a namespace are applied in the current namespace.
+
+The plan is printed with the status of each step. With --dry-run, the plan is
+only computed and printed.
+`
+
+type applyOptions struct {
+	file   string
+	dryRun bool
+	outfmt output.Format
+}
+
+func newApplyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	o := &applyOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "apply",
+		Short:             "apply the releases declared in a file",
+		Long:              applyDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cfg, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&o.file, "file", "f", "", "file declaring the releases")
+	f.BoolVar(&o.dryRun, "dry-run", false, "print the plan without applying it")
+	bindOutputFlag(cmd, &o.outfmt)
+
+	return cmd
+}
+
+func (o *applyOptions) run(actionConfig *action.Configuration, out io.Writer) error {
+	if o.file == "" {
+		return errors.New("--file is required")
+	}
+	data, err := ioutil.ReadFile(o.file)
+	if err != nil {
+		return err
+	}
+	m, err := action.LoadReleasesManifest(data)
+	if err != nil {
+		return err
+	}
+
+	client := action.NewApply(namespaceConfigs(actionConfig), settings)
+	client.Namespace = settings.Namespace()
+	client.DryRun = o.dryRun
+	plan, err := client.Run(context.Background(), m)
+	if plan == nil {
+		return err
+	}
+	return writeResult(out, o.outfmt, &applyPlanWriter{plan: plan}, err)
+}
+
+type applyPlanWriter struct {
+	plan *action.ApplyPlan
+}
+
+func (w *applyPlanWriter) WriteTable(out io.Writer) error {
+	if !w.plan.Changed() {
+		fmt.Fprintln(out, "All releases are up to date.")
+	}
+	tbl := uitable.New()
+	tbl.AddRow("NAMESPACE", "NAME", "ACTION", "CHART", "STATUS")
+	for _, s := range w.plan.Steps {
+		chart := s.Chart
+		if chart == "" {
+			chart = s.CurrentChart
+		} else if s.CurrentChart != "" && s.CurrentChart != s.Chart {
+			chart = s.CurrentChart + " -> " + s.Chart
+		}
+		status := string(s.Status)
+		if s.Error != "" {
+			status += ": " + s.Error
+		}
+		tbl.AddRow(s.Namespace, s.Name, s.Action, chart, status)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *applyPlanWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.plan)
+}
+
+func (w *applyPlanWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.plan)
+}
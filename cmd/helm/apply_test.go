@@ -0,0 +1,41 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestApplyCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "apply without file",
+		cmd:       "apply",
+		golden:    "output/apply-no-file.txt",
+		wantError: true,
+	}, {
+		name:      "apply releases needing each other",
+		cmd:       "apply -f testdata/apply/cycle.yaml --dry-run",
+		golden:    "output/apply-cycle.txt",
+		wantError: true,
+	}, {
+		name:      "apply a release needing an undeclared release",
+		cmd:       "apply -f testdata/apply/unknown-need.yaml --dry-run",
+		golden:    "output/apply-unknown-need.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
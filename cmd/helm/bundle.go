@@ -0,0 +1,47 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+)
+
+const bundleHelp = `
+This command consists of multiple subcommands to create and install offline
+chart bundles.
+
+A bundle is a single archive holding a chart with its dependencies, its
+provenance file, the list of the images it references and a repository index,
+for delivering charts to air-gapped clusters.
+`
+
+func newBundleCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "create and install offline chart bundles",
+		Long:  bundleHelp,
+	}
+	cmd.AddCommand(
+		newBundleCreateCmd(cfg, out),
+		newBundleInstallCmd(cfg, out),
+	)
+	return cmd
+}
@@ -0,0 +1,81 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/bundle"
+)
+
+const bundleCreateHelp = `
+This command creates an offline bundle of a chart, which 'helm bundle install'
+installs without any network access.
+
+The chart is a chart directory, which is packaged, a chart archive, whose
+provenance file is bundled if it is next to it, or a chart reference as for
+'helm install'. The dependencies of the chart must have been built with
+'helm dependency build' first.
+
+The bundle is written to NAME-VERSION.bundle.tgz in the destination
+directory. It lists the images referenced by the values of the chart and of
+its dependencies in images.txt, to mirror them into the registry of the
+target cluster.
+`
+
+func newBundleCreateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewBundleCreate(cfg)
+	var dest string
+
+	cmd := &cobra.Command{
+		Use:   "create CHART",
+		Short: "create an offline bundle of a chart",
+		Long:  bundleCreateHelp,
+		Args:  require.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			b, err := client.Run(args[0], settings)
+			if err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			if err := bundle.Write(&buf, b); err != nil {
+				return err
+			}
+			name := filepath.Join(dest, fmt.Sprintf("%s-%s.bundle.tgz", b.Manifest.Chart.Name, b.Manifest.Chart.Version))
+			if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Created bundle of %s-%s with %d dependencies and %d images: %s\n",
+				b.Manifest.Chart.Name, b.Manifest.Chart.Version, len(b.Manifest.Dependencies), len(b.Manifest.Images), name)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&dest, "destination", "d", ".", "location to write the bundle to")
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+
+	return cmd
+}
@@ -0,0 +1,125 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/bundle"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/cli/values"
+	"github.com/open-hand/helm/pkg/getter"
+)
+
+const bundleInstallHelp = `
+This command installs the chart of a bundle created with 'helm bundle create'.
+
+The chart is read from the bundle only, so nothing is downloaded: the digest
+of the chart archive is checked against the one recorded in the bundle, and
+with '--verify' its provenance file is verified with the local keyring. Values
+are given as for 'helm install'. Use '-' to read the bundle from standard
+input.
+`
+
+func newBundleInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewInstall(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", "", 0, "", "", "", false)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "install [NAME] BUNDLE",
+		Short: "install the chart of an offline bundle",
+		Long:  bundleInstallHelp,
+		Args:  require.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, path, err := client.NameAndChart(args)
+			if err != nil {
+				return err
+			}
+			client.ReleaseName = name
+
+			in := cmd.InOrStdin()
+			if path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			b, err := bundle.Read(in)
+			if err != nil {
+				return err
+			}
+			if client.Verify {
+				if _, err := b.Verify(client.Keyring); err != nil {
+					return err
+				}
+			}
+			ch, err := b.LoadChart()
+			if err != nil {
+				return err
+			}
+			if err := checkIfInstallable(ch); err != nil {
+				return err
+			}
+			if req := ch.Metadata.Dependencies; req != nil {
+				if err := action.CheckDependencies(ch, req); err != nil {
+					return err
+				}
+			}
+
+			vals, err := valueOpts.MergeValues(getter.All(settings))
+			if err != nil {
+				return err
+			}
+			client.Namespace = settings.Namespace()
+			rel, err := client.RunWithContext(context.Background(), ch, vals, "")
+			if err != nil {
+				return errors.Wrap(err, "INSTALLATION FAILED")
+			}
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, nil})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.CreateNamespace, "create-namespace", false, "create the release namespace if not present")
+	f.BoolVar(&client.DryRun, "dry-run", false, "simulate an install")
+	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during install")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVarP(&client.GenerateName, "generate-name", "g", false, "generate the name (and omit the NAME parameter)")
+	f.StringVar(&client.Description, "description", "", "add a custom description")
+	f.BoolVar(&client.Atomic, "atomic", false, "if set, the installation process deletes the installation on failure. The --wait flag will be set automatically if --atomic is used")
+	f.BoolVar(&client.SkipCRDs, "skip-crds", false, "if set, no CRDs will be installed. By default, CRDs are installed if not already present")
+	f.BoolVar(&client.Verify, "verify", false, "verify the provenance file of the chart of the bundle")
+	f.StringVar(&client.Keyring, "keyring", defaultKeyring(), "location of public keys used for verification")
+	addValueOptionsFlags(f, valueOpts)
+	bindOutputFlag(cmd, &outfmt)
+	bindWaitStrategyFlag(cmd, &client.WaitStrategy)
+
+	return cmd
+}
@@ -0,0 +1,51 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/internal/test/ensure"
+)
+
+func TestBundleCreateInstall(t *testing.T) {
+	defer ensure.HelmHome(t)()
+	dir := ensure.TempDir(t)
+
+	_, out, err := executeActionCommand("bundle create testdata/testcharts/signtest-0.1.0.tgz --verify --keyring testdata/helm-test-key.pub -d " + dir)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %s", err)
+	}
+	b := filepath.Join(dir, "signtest-0.1.0.bundle.tgz")
+	if want := "Created bundle of signtest-0.1.0 with 0 dependencies and 0 images: " + b + "\n"; out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+
+	_, out, err = executeActionCommand("bundle install signtest " + b + " --dry-run --verify --keyring testdata/helm-test-key.pub")
+	if err != nil {
+		t.Fatalf("Failed to install bundle: %s", err)
+	}
+	if !strings.Contains(out, "NAME: signtest") || !strings.Contains(out, "STATUS: pending-install") {
+		t.Errorf("Unexpected install output %q", out)
+	}
+
+	if _, _, err := executeActionCommand("bundle create testdata/testcharts/chart-missing-deps -d " + dir); err == nil {
+		t.Error("Expected an error bundling a chart with missing dependencies")
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+const capabilitiesHelp = `
+This command consists of multiple subcommands to work with the capabilities of
+clusters: their Kubernetes version and API versions, exposed to the templates
+as .Capabilities.
+`
+
+const capabilitiesExportDesc = `
+This command writes the capabilities of the cluster as JSON to the standard
+output, for rendering charts where the cluster cannot be reached, with the
+exact Kubernetes version and API versions of the cluster:
+
+    $ helm capabilities export > caps.json
+    $ helm template --capabilities-file caps.json mychart ./mychart
+
+The file is accepted by 'helm template', 'helm lint', 'helm show hooks' and
+'helm install --dry-run'. With '--detect-features', the features of the
+cluster are exported too.
+`
+
+func newCapabilitiesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capabilities",
+		Short: "work with the capabilities of clusters",
+		Long:  capabilitiesHelp,
+	}
+	cmd.AddCommand(newCapabilitiesExportCmd(cfg, out))
+	return cmd
+}
+
+func newCapabilitiesExportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewCapabilitiesExport(cfg)
+
+	cmd := &cobra.Command{
+		Use:               "export",
+		Short:             "write the capabilities of the cluster to a file",
+		Long:              capabilitiesExportDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			caps, err := client.Run()
+			if err != nil {
+				return err
+			}
+			return chartutil.WriteCapabilities(out, caps)
+		},
+	}
+	bindDetectFeaturesFlag(cmd, &cfg.DetectFeatures)
+	return cmd
+}
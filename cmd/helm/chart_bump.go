@@ -0,0 +1,158 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/downloader"
+	"github.com/open-hand/helm/pkg/getter"
+)
+
+const chartBumpDesc = `
+Bump the version of a chart, a chart directory or archive, to release it.
+
+The chart version is bumped at the semantic version level given by '--level',
+or set by '--version'. The appVersion is bumped at the level given by
+'--app-version-level', or set by '--app-version'.
+
+The version constraints of the dependencies are set by '--dependency', e.g.
+'--dependency postgresql=^12.0.0'. When the versions locked by Chart.lock no
+longer satisfy them, '--dependency-update' updates the dependencies of a chart
+directory like 'helm dependency update'.
+
+An archive is replaced by the archive of the new version. '--index' adds it
+to the index of a repository, served at '--url'.
+`
+
+type chartBumpOptions struct {
+	dependencies     []string
+	dependencyUpdate bool
+	keyring          string
+	skipRefresh      bool
+}
+
+func newChartBumpCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewChartBump()
+	o := &chartBumpOptions{}
+	var level, appVersionLevel string
+
+	cmd := &cobra.Command{
+		Use:   "bump CHART",
+		Short: "bump the version of a chart",
+		Long:  chartBumpDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				// Allow file completion when completing the argument for the chart
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			// No more completions, so disable file completion
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client.Level = action.BumpLevel(level)
+			client.AppVersionLevel = action.BumpLevel(appVersionLevel)
+			if client.Level == "" && client.Version == "" && client.AppVersionLevel == "" && client.AppVersion == "" && len(o.dependencies) == 0 {
+				client.Level = action.BumpPatch
+			}
+			if len(o.dependencies) > 0 {
+				client.Dependencies = make(map[string]string)
+				for _, d := range o.dependencies {
+					parts := strings.SplitN(d, "=", 2)
+					if len(parts) != 2 || parts[0] == "" {
+						return errors.Errorf("invalid dependency %q, expected NAME=CONSTRAINT", d)
+					}
+					client.Dependencies[parts[0]] = parts[1]
+				}
+			}
+			return o.run(out, cfg, client, args[0])
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&level, "level", "", fmt.Sprintf("bump the chart version at this semantic version level, patch by default when nothing else is set. Allowed values: %s", strings.Join(action.BumpLevels, ", ")))
+	f.StringVar(&client.Version, "version", "", "set the chart version to this semantic version")
+	f.StringVar(&appVersionLevel, "app-version-level", "", fmt.Sprintf("bump the appVersion at this semantic version level. Allowed values: %s", strings.Join(action.BumpLevels, ", ")))
+	f.StringVar(&client.AppVersion, "app-version", "", "set the appVersion to this version")
+	f.StringArrayVar(&o.dependencies, "dependency", []string{}, "set the version constraint of a dependency, by name or alias, as NAME=CONSTRAINT (can specify multiple)")
+	f.BoolVar(&o.dependencyUpdate, "dependency-update", false, "update the dependencies of a chart directory when Chart.lock no longer satisfies them")
+	f.StringVar(&o.keyring, "keyring", defaultKeyring(), "keyring containing public keys, used with --dependency-update")
+	f.BoolVar(&o.skipRefresh, "skip-refresh", false, "do not refresh the local repository cache, used with --dependency-update")
+	f.StringVar(&client.IndexFile, "index", "", "add the bumped chart archive to this repository index file, created if missing")
+	f.StringVar(&client.IndexURL, "url", "", "URL of the repository of the index")
+
+	for _, name := range []string{"level", "app-version-level"} {
+		cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return action.BumpLevels, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+
+	return cmd
+}
+
+func (o *chartBumpOptions) run(out io.Writer, cfg *action.Configuration, client *action.ChartBump, chartPath string) error {
+	if o.dependencyUpdate {
+		if fi, err := os.Stat(chartPath); err == nil && !fi.IsDir() {
+			return errors.New("--dependency-update needs a chart directory")
+		}
+	}
+
+	name, md, err := client.Run(chartPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Bumped chart %s from %s to %s\n", md.Name, client.PreviousVersion, md.Version)
+	if md.AppVersion != client.PreviousAppVersion {
+		fmt.Fprintf(out, "Bumped appVersion from %s to %s\n", client.PreviousAppVersion, md.AppVersion)
+	}
+	if name != chartPath {
+		fmt.Fprintf(out, "Saved the chart to %s\n", name)
+	}
+	if client.IndexFile != "" {
+		fmt.Fprintf(out, "Added the chart to %s\n", client.IndexFile)
+	}
+
+	if !client.LockOutdated {
+		return nil
+	}
+	if !o.dependencyUpdate {
+		warning("Chart.lock no longer satisfies the dependencies, run 'helm dependency update %s'", chartPath)
+		return nil
+	}
+	man := &downloader.Manager{
+		Out:              out,
+		ChartPath:        filepath.Clean(chartPath),
+		Keyring:          o.keyring,
+		SkipUpdate:       o.skipRefresh,
+		Getters:          getter.All(settings),
+		RegistryClient:   cfg.RegistryClient,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+		Debug:            settings.Debug,
+	}
+	return man.Update()
+}
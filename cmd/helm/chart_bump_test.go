@@ -0,0 +1,206 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+// bumpChart returns a chart with a dependency locked at 1.2.0.
+func bumpChart() *chart.Chart {
+	dep := &chart.Dependency{Name: "sub", Version: "^1.0.0", Repository: "https://example.com/charts"}
+	return &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   chart.APIVersionV2,
+			Name:         "bumped",
+			Version:      "1.2.3",
+			AppVersion:   "v2.0.1",
+			Dependencies: []*chart.Dependency{dep},
+		},
+		Lock: &chart.Lock{
+			Digest:       "sha256:old",
+			Dependencies: []*chart.Dependency{{Name: "sub", Version: "1.2.0", Repository: dep.Repository}},
+		},
+	}
+}
+
+// saveBumpChart saves the chart to a temporary chart directory, with its
+// Chart.lock, and returns its path.
+func saveBumpChart(t *testing.T, ch *chart.Chart) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := chartutil.SaveDir(ch, dir); err != nil {
+		t.Fatal(err)
+	}
+	data, err := yaml.Marshal(ch.Lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chartPath := filepath.Join(dir, ch.Name())
+	if err := ioutil.WriteFile(filepath.Join(chartPath, "Chart.lock"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return chartPath
+}
+
+func TestChartBump(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      string
+		version    string
+		appVersion string
+		constraint string
+		output     string
+		wantError  bool
+	}{{
+		name:       "patch by default",
+		version:    "1.2.4",
+		appVersion: "v2.0.1",
+		output:     "Bumped chart bumped from 1.2.3 to 1.2.4\n",
+	}, {
+		name:       "minor and app version",
+		flags:      "--level minor --app-version-level major",
+		version:    "1.3.0",
+		appVersion: "v3.0.0",
+		output:     "Bumped chart bumped from 1.2.3 to 1.3.0\nBumped appVersion from v2.0.1 to v3.0.0\n",
+	}, {
+		name:       "explicit versions",
+		flags:      "--version 2.0.0-rc.1 --app-version latest",
+		version:    "2.0.0-rc.1",
+		appVersion: "latest",
+	}, {
+		name:       "dependency constraint still satisfied",
+		flags:      "--dependency sub=~1.2.0",
+		version:    "1.2.3",
+		appVersion: "v2.0.1",
+		constraint: "~1.2.0",
+	}, {
+		name:      "invalid level",
+		flags:     "--level huge",
+		wantError: true,
+	}, {
+		name:      "invalid version",
+		flags:     "--version next",
+		wantError: true,
+	}, {
+		name:      "unknown dependency",
+		flags:     "--dependency other=^1.0.0",
+		wantError: true,
+	}, {
+		name:      "dependency without constraint",
+		flags:     "--dependency sub",
+		wantError: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartPath := saveBumpChart(t, bumpChart())
+			_, out, err := executeActionCommand("chart bump " + chartPath + " " + tt.flags)
+			if tt.wantError {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.output != "" && out != tt.output {
+				t.Errorf("expected output %q, got %q", tt.output, out)
+			}
+			md, err := chartutil.LoadChartfile(filepath.Join(chartPath, chartutil.ChartfileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if md.Version != tt.version || md.AppVersion != tt.appVersion {
+				t.Errorf("expected version %s and appVersion %s, got %s and %s", tt.version, tt.appVersion, md.Version, md.AppVersion)
+			}
+			if tt.constraint != "" && md.Dependencies[0].Version != tt.constraint {
+				t.Errorf("expected dependency constraint %s, got %s", tt.constraint, md.Dependencies[0].Version)
+			}
+		})
+	}
+}
+
+func TestChartBumpOutdatedLock(t *testing.T) {
+	chartPath := saveBumpChart(t, bumpChart())
+	if _, _, err := executeActionCommand("chart bump " + chartPath + " --dependency sub=^2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Metadata.Dependencies[0].Version != "^2.0.0" {
+		t.Errorf("expected dependency constraint ^2.0.0, got %s", ch.Metadata.Dependencies[0].Version)
+	}
+	// The outdated lock must not look up to date.
+	if ch.Lock.Digest != "sha256:old" {
+		t.Errorf("expected the outdated Chart.lock to be left as is, got digest %s", ch.Lock.Digest)
+	}
+}
+
+func TestChartBumpArchiveIndex(t *testing.T) {
+	ch := bumpChart()
+	ch.Metadata.Dependencies, ch.Lock = nil, nil
+	dir := t.TempDir()
+	archive, err := chartutil.Save(ch, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dir, "index.yaml")
+
+	_, out, err := executeActionCommand("chart bump " + archive + " --level major --index " + index + " --url https://example.com/charts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bumped := filepath.Join(dir, "bumped-2.0.0.tgz")
+	if !strings.Contains(out, "Saved the chart to "+bumped) {
+		t.Errorf("expected the new archive in the output, got %q", out)
+	}
+	if _, err := loader.Load(archive); err == nil {
+		t.Errorf("expected the archive %s to be replaced", archive)
+	}
+	idx, err := repo.LoadIndexFile(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, err := idx.Get("bumped", "2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cv.URLs) != 1 || cv.URLs[0] != "https://example.com/charts/bumped-2.0.0.tgz" || cv.Digest == "" {
+		t.Errorf("unexpected index entry %+v", cv)
+	}
+
+	if _, _, err := executeActionCommand("chart bump " + bumped + " --version 2.0.0 --index " + index); err == nil {
+		t.Error("expected adding the same version to the index to fail")
+	}
+	if _, _, err := executeActionCommand("chart bump " + saveBumpChart(t, bumpChart()) + " --index " + index); err == nil {
+		t.Error("expected adding a chart directory to the index to fail")
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+const chartDocsDesc = `
+Generate the reference of the values of a chart.
+
+The values are the leaves of the values.yaml file of the chart, with their type,
+default value and description. A value is described by the comment above its
+key, without the leading '--' marking descriptions, or else by the description
+of its property in the values.schema.json file of the chart. The properties of
+the schema missing from the values.yaml file are documented too.
+
+The reference is a markdown table, or a JSON list with '--output json'. It is
+written to stdout, or with '--output-file' to a file of the chart, which must
+then be a chart directory rather than a packaged chart.
+`
+
+type chartDocsOptions struct {
+	output     string
+	outputFile string
+}
+
+func newChartDocsCmd(out io.Writer) *cobra.Command {
+	o := &chartDocsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "docs CHART",
+		Short: "generate the reference of the values of a chart",
+		Long:  chartDocsDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				// Allow file completion when completing the argument for the directory
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			// No more completions, so disable file completion
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(out, args[0])
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&o.output, outputFlag, "o", "markdown", "format of the reference. Allowed values: markdown, json")
+	f.StringVar(&o.outputFile, "output-file", "", fmt.Sprintf("write the reference to this file of the chart directory instead of stdout, e.g. %s", chartutil.ValuesDocsFileName))
+
+	cmd.RegisterFlagCompletionFunc(outputFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"markdown", "json"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
+
+func (o *chartDocsOptions) run(out io.Writer, chartPath string) error {
+	if o.output != "markdown" && o.output != "json" {
+		return errors.Errorf("unknown output format %q. Try 'markdown' or 'json'", o.output)
+	}
+	if o.outputFile != "" {
+		if fi, err := os.Stat(chartPath); err == nil && !fi.IsDir() {
+			return errors.Errorf("--output-file writes to a chart directory, %s is a packaged chart", chartPath)
+		}
+	}
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return err
+	}
+	docs, err := chartutil.ChartValuesDocs(ch)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if o.output == "json" {
+		data, err := json.MarshalIndent(docs, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteString("\n")
+	} else if err := chartutil.WriteValuesDocsMarkdown(&buf, docs); err != nil {
+		return err
+	}
+
+	if o.outputFile == "" {
+		_, err := out.Write(buf.Bytes())
+		return err
+	}
+	filename := filepath.Join(chartPath, o.outputFile)
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote the reference of the values to %s\n", filename)
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/internal/test"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+func TestChartDocs(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "values reference as markdown",
+		cmd:    "chart docs testdata/testcharts/chart-with-commented-values",
+		golden: "output/chart-docs.md",
+	}, {
+		name:   "values reference with a schema as json",
+		cmd:    "chart docs testdata/testcharts/chart-with-schema -o json",
+		golden: "output/chart-docs-schema.json",
+	}, {
+		name:      "values reference in an unknown format",
+		cmd:       "chart docs testdata/testcharts/chart-with-commented-values -o html",
+		wantError: true,
+	}, {
+		name:      "values reference of a missing chart",
+		cmd:       "chart docs testdata/testcharts/missing",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestChartDocsOutputFile(t *testing.T) {
+	ch, err := loader.Load("testdata/testcharts/chart-with-commented-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := chartutil.SaveDir(ch, dir); err != nil {
+		t.Fatal(err)
+	}
+	chartPath := filepath.Join(dir, ch.Name())
+	if _, _, err := executeActionCommand("chart docs " + chartPath + " --output-file " + chartutil.ValuesDocsFileName); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(chartPath, chartutil.ValuesDocsFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, string(data), "output/chart-docs.md")
+
+	archive, err := chartutil.Save(ch, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = executeActionCommand("chart docs " + archive + " --output-file " + chartutil.ValuesDocsFileName)
+	if err == nil || !strings.Contains(err.Error(), "is a packaged chart") {
+		t.Errorf("expected an error for a packaged chart, got %v", err)
+	}
+	if _, _, err := executeActionCommand("chart docs " + archive); err != nil {
+		t.Errorf("expected the reference of a packaged chart to be written to stdout, got %v", err)
+	}
+}
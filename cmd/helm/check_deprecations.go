@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/cli/values"
+	"github.com/open-hand/helm/pkg/deprecations"
+	"github.com/open-hand/helm/pkg/getter"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+const checkDeprecationsHelp = `
+This command reports the resources of a release or a chart using Kubernetes APIs
+that are deprecated or removed in a version of Kubernetes, along with the
+templates they are rendered from and the APIs replacing them, so that upgrades
+of the cluster can be planned.
+
+The argument is a release if a release of that name exists, and a chart
+otherwise: a path to a chart, a chart reference ('example/mariadb'), or a chart
+name with '--repo'. Releases are checked as stored, with their hooks. Charts are
+rendered without a cluster for the Kubernetes version checked against, as with
+'helm template', so that the templates selecting their APIs by
+'.Capabilities.KubeVersion' are checked with the APIs they would choose.
+
+The version of Kubernetes defaults to the version of the cluster for releases.
+Use '--kube-version' to check against the version the cluster will be upgraded
+to:
+
+    $ helm check-deprecations my-release --kube-version 1.25
+
+The deprecated APIs are taken from a database bundled with Helm. When a resource
+uses an API removed from the version checked against, helm exits with status 2.
+`
+
+// removedAPIsError is returned when 'helm check-deprecations' finds APIs
+// removed from the version of Kubernetes checked against, so that helm exits
+// with a distinct status.
+type removedAPIsError struct {
+	kubeVersion string
+}
+
+func (e removedAPIsError) Error() string {
+	return fmt.Sprintf("resources use APIs removed in Kubernetes %s", e.kubeVersion)
+}
+
+func newCheckDeprecationsCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewCheckDeprecations(cfg)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+	var kubeVersion string
+
+	cmd := &cobra.Command{
+		Use:   "check-deprecations [RELEASE|CHART]",
+		Short: "report the use of deprecated Kubernetes APIs by a release or a chart",
+		Long:  checkDeprecationsHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if kubeVersion != "" {
+				parsed, err := chartutil.ParseKubeVersion(kubeVersion)
+				if err != nil {
+					return errors.Wrapf(err, "invalid kube version '%s'", kubeVersion)
+				}
+				client.KubeVersion = parsed
+			}
+			client.Namespace = settings.Namespace()
+
+			report, err := checkDeprecations(client, args[0], valueOpts)
+			if err != nil {
+				return err
+			}
+			if report.Removed() {
+				err = removedAPIsError{kubeVersion: report.KubeVersion}
+			}
+			return writeResult(out, outfmt, &deprecationReportWriter{report}, err)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&kubeVersion, "kube-version", "", "the version of Kubernetes to check against. Defaults to the version of the cluster for releases")
+	f.IntVar(&client.Version, "revision", 0, "check the named release with revision")
+	addValueOptionsFlags(f, valueOpts)
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+// checkDeprecations checks the release named arg if it exists, or the chart
+// arg refers to otherwise.
+func checkDeprecations(client *action.CheckDeprecations, arg string, valueOpts *values.Options) (*action.DeprecationReport, error) {
+	_, statErr := os.Stat(arg)
+	if statErr != nil && client.RepoURL == "" && !strings.Contains(arg, "/") {
+		report, err := client.RunRelease(arg)
+		if errors.Cause(err) != driver.ErrReleaseNotFound {
+			return report, err
+		}
+		debug("no release named %q, checking it as a chart", arg)
+	}
+
+	cp := arg
+	if statErr != nil {
+		var err error
+		if cp, err = client.ChartPathOptions.LocateChart(arg, settings); err != nil {
+			return nil, err
+		}
+	}
+	chrt, err := loader.Load(cp)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := valueOpts.MergeValues(getter.All(settings))
+	if err != nil {
+		return nil, err
+	}
+	return client.RunChart(chrt, vals)
+}
+
+type deprecationReportWriter struct {
+	report *action.DeprecationReport
+}
+
+func (w *deprecationReportWriter) WriteTable(out io.Writer) error {
+	r := w.report
+	if r.Release != "" {
+		fmt.Fprintf(out, "RELEASE: %s\n", r.Release)
+		fmt.Fprintf(out, "REVISION: %d\n", r.Revision)
+	}
+	fmt.Fprintf(out, "CHART: %s\n", r.Chart)
+	fmt.Fprintf(out, "KUBERNETES VERSION: %s\n", r.KubeVersion)
+	if len(r.Findings) == 0 {
+		fmt.Fprintln(out, "No deprecated APIs found")
+		return nil
+	}
+	table := uitable.New()
+	table.AddRow("TEMPLATE", "KIND", "NAME", "API VERSION", "STATUS", "REPLACEMENT")
+	for _, f := range r.Findings {
+		status := fmt.Sprintf("%s in %s", f.Status, f.DeprecatedIn)
+		if f.Status == deprecations.Removed {
+			status = fmt.Sprintf("%s in %s", f.Status, f.RemovedIn)
+		} else if f.RemovedIn != "" {
+			status += fmt.Sprintf(", removed in %s", f.RemovedIn)
+		}
+		replacement := f.Replacement
+		if replacement == "" {
+			replacement = "none"
+		}
+		table.AddRow(f.Source, f.Kind, f.Name, f.APIVersion, status, replacement)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (w *deprecationReportWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.report)
+}
+
+func (w *deprecationReportWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.report)
+}
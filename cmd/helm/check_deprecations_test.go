@@ -0,0 +1,64 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestCheckDeprecationsCmd(t *testing.T) {
+	chartPath := "testdata/testcharts/chart-with-deprecated-apis"
+	rel := release.Mock(&release.MockReleaseOptions{Name: "web"})
+	rel.Manifest = `---
+# Source: web/templates/ingress.yaml
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	tests := []cmdTestCase{{
+		name:   "check a release for deprecations",
+		cmd:    "check-deprecations web --kube-version 1.20",
+		golden: "output/check-deprecations-release.txt",
+		rels:   []*release.Release{rel},
+	}, {
+		name:      "check a release for removed APIs",
+		cmd:       "check-deprecations web --kube-version 1.22 -o json",
+		golden:    "output/check-deprecations-release-removed.json",
+		rels:      []*release.Release{rel},
+		wantError: true,
+	}, {
+		name:   "check a chart rendered for an older Kubernetes",
+		cmd:    "check-deprecations " + chartPath + " --kube-version 1.18",
+		golden: "output/check-deprecations-chart-1.18.txt",
+	}, {
+		name:      "check a chart rendered for a newer Kubernetes",
+		cmd:       "check-deprecations " + chartPath + " --kube-version 1.25",
+		golden:    "output/check-deprecations-chart-1.25.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
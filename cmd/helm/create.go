@@ -17,16 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/Masterminds/vcs"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/helmpath"
+	"github.com/open-hand/helm/pkg/registry"
 )
 
 const createDesc = `
@@ -48,12 +57,41 @@ something like this:
 do not exist, Helm will attempt to create them as it goes. If the given
 destination exists and there are files in that directory, conflicting files
 will be overwritten, but other files will be left alone.
+
+With --starter, the chart is scaffolded from a starter: the name of a starter
+in the starters directory of Helm, a path, a chart in an OCI registry
+("oci://registry.example.com/starters/web") or a git repository
+("git+https://github.com/example/starter.git", or a URL ending in ".git").
+--starter-version selects the tag of an OCI starter or the ref of a git one.
+
+A starter may declare parameters and post-generation hooks in a 'starter.yaml'
+file:
+
+    parameters:
+    - name: port
+      description: the port of the service
+      default: "8080"
+    - name: team
+      required: true
+    hooks:
+    - command: ["git", "init"]
+
+The placeholder '<PARAM:name>' is replaced with the value of the parameter in
+the templates and values of the chart, as '<CHARTNAME>' is with the name of the
+chart. The values are given with --set-param, or prompted for when the input
+is a terminal. The hooks run arbitrary commands, so they are only run, in the
+directory of the chart once it is created, if --run-starter-hooks is set.
+Otherwise they are listed.
 `
 
 type createOptions struct {
-	starter    string // --starter
-	name       string
-	starterDir string
+	starter        string   // --starter
+	starterVersion string   // --starter-version
+	params         []string // --set-param
+	runHooks       bool     // --run-starter-hooks
+	name           string
+	starterDir     string
+	in             io.Reader
 }
 
 func newCreateCmd(out io.Writer) *cobra.Command {
@@ -76,11 +114,16 @@ func newCreateCmd(out io.Writer) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			o.name = args[0]
 			o.starterDir = helmpath.DataPath("starters")
+			o.in = cmd.InOrStdin()
 			return o.run(out)
 		},
 	}
 
-	cmd.Flags().StringVarP(&o.starter, "starter", "p", "", "the name or absolute path to Helm starter scaffold")
+	f := cmd.Flags()
+	f.StringVarP(&o.starter, "starter", "p", "", "the name or absolute path to Helm starter scaffold, or an oci:// or git reference to a remote starter")
+	f.StringVar(&o.starterVersion, "starter-version", "", "the tag of an OCI starter or the ref of a git starter")
+	f.StringArrayVar(&o.params, "set-param", []string{}, "set a parameter of the starter (can specify multiple: key1=val1)")
+	f.BoolVar(&o.runHooks, "run-starter-hooks", false, "run the post-generation hooks of the starter")
 	return cmd
 }
 
@@ -98,16 +141,131 @@ func (o *createOptions) run(out io.Writer) error {
 	}
 
 	if o.starter != "" {
-		// Create from the starter
-		lstarter := filepath.Join(o.starterDir, o.starter)
-		// If path is absolute, we don't want to prefix it with helm starters folder
-		if filepath.IsAbs(o.starter) {
-			lstarter = o.starter
-		}
-		return chartutil.CreateFrom(cfile, filepath.Dir(o.name), lstarter)
+		return o.createFromStarter(cfile, out)
 	}
 
 	chartutil.Stderr = out
 	_, err := chartutil.Create(chartname, filepath.Dir(o.name))
 	return err
 }
+
+func (o *createOptions) createFromStarter(cfile *chart.Metadata, out io.Writer) error {
+	// Create from the starter
+	lstarter := filepath.Join(o.starterDir, o.starter)
+	switch {
+	case isRemoteStarter(o.starter):
+		tmp, err := ioutil.TempDir("", "helm-starter-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		if lstarter, err = fetchStarter(o.starter, o.starterVersion, tmp); err != nil {
+			return err
+		}
+	case filepath.IsAbs(o.starter):
+		// If path is absolute, we don't want to prefix it with helm starters folder
+		lstarter = o.starter
+	}
+
+	starter, err := chartutil.LoadStarterfile(lstarter)
+	if err != nil {
+		return err
+	}
+	params := make(map[string]string, len(o.params))
+	for _, p := range o.params {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid starter parameter %q, expected key=value", p)
+		}
+		params[kv[0]] = kv[1]
+	}
+	var prompt func(chartutil.StarterParameter) (string, error)
+	if f, ok := o.in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		prompt = newStarterPrompt(o.in, out)
+	}
+	values, err := starter.Resolve(params, prompt)
+	if err != nil {
+		return err
+	}
+
+	if err := chartutil.CreateFromStarter(cfile, filepath.Dir(o.name), lstarter, values); err != nil {
+		return err
+	}
+	if !o.runHooks {
+		for _, h := range starter.Hooks {
+			fmt.Fprintf(out, "Skipping starter hook %s, run with --run-starter-hooks to run it\n", strings.Join(h.Command, " "))
+		}
+		return nil
+	}
+	return starter.RunHooks(o.name, cfile.Name, values, out)
+}
+
+// newStarterPrompt returns a function prompting for the values of the
+// parameters of a starter on in.
+func newStarterPrompt(in io.Reader, out io.Writer) func(chartutil.StarterParameter) (string, error) {
+	r := bufio.NewReader(in)
+	return func(p chartutil.StarterParameter) (string, error) {
+		fmt.Fprint(out, p.Name)
+		if p.Description != "" {
+			fmt.Fprintf(out, " (%s)", p.Description)
+		}
+		if p.Default != "" {
+			fmt.Fprintf(out, " [%s]", p.Default)
+		}
+		fmt.Fprint(out, ": ")
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
+func isRemoteStarter(ref string) bool {
+	return registry.IsOCI(ref) || strings.HasPrefix(ref, "git+") || strings.HasSuffix(ref, ".git")
+}
+
+// fetchStarter fetches the remote starter of ref at version into dir and
+// returns the directory of the starter.
+func fetchStarter(ref, version, dir string) (string, error) {
+	if registry.IsOCI(ref) {
+		client, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+		if err != nil {
+			return "", err
+		}
+		ref = strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme))
+		if version != "" {
+			ref += ":" + version
+		}
+		result, err := client.Pull(ref, registry.PullOptWithChart(true))
+		if err != nil {
+			return "", errors.Wrapf(err, "could not pull starter %s", ref)
+		}
+		if err := chartutil.Expand(dir, bytes.NewReader(result.Chart.Data)); err != nil {
+			return "", err
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+		if len(entries) != 1 || !entries[0].IsDir() {
+			return "", errors.Errorf("starter %s is not a chart", ref)
+		}
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+
+	local := filepath.Join(dir, "starter")
+	repo, err := vcs.NewRepo(strings.TrimPrefix(ref, "git+"), local)
+	if err != nil {
+		return "", err
+	}
+	if err := repo.Get(); err != nil {
+		return "", errors.Wrapf(err, "could not clone starter %s", ref)
+	}
+	if version != "" {
+		if err := repo.UpdateVersion(version); err != nil {
+			return "", errors.Wrapf(err, "could not check out %s of starter %s", version, ref)
+		}
+	}
+	return local, nil
+}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/open-hand/helm/internal/test/ensure"
@@ -197,3 +198,72 @@ func TestCreateFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "create", true)
 	checkFileCompletion(t, "create myname", false)
 }
+
+func TestCreateStarterParamsCmd(t *testing.T) {
+	defer resetEnv()()
+	defer ensure.HelmHome(t)()
+
+	starterchart := helmpath.DataPath("starters")
+	os.MkdirAll(starterchart, 0755)
+	if _, err := chartutil.Create("paramchart", starterchart); err != nil {
+		t.Fatalf("Could not create chart: %s", err)
+	}
+	starterdir := filepath.Join(starterchart, "paramchart")
+	tplpath := filepath.Join(starterdir, "templates", "port.tpl")
+	if err := ioutil.WriteFile(tplpath, []byte("<CHARTNAME>:<PARAM:port> <PARAM:team>"), 0644); err != nil {
+		t.Fatalf("Could not write template: %s", err)
+	}
+	starterfile := `parameters:
+- name: port
+  default: "8080"
+- name: team
+  required: true
+hooks:
+- command: ["sh", "-c", "echo $HELM_CHART_NAME $HELM_PARAM_TEAM > hook.txt"]
+`
+	if err := ioutil.WriteFile(filepath.Join(starterdir, chartutil.StarterfileName), []byte(starterfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.MkdirAll(helmpath.CachePath(), 0755)
+	defer testChdir(t, helmpath.CachePath())()
+
+	if _, _, err := executeActionCommand("create --starter=paramchart nameless"); err == nil {
+		t.Error("expected an error without a value for the required parameter")
+	}
+	if _, _, err := executeActionCommand("create --starter=paramchart --set-param team=web --set-param color=red colored"); err == nil {
+		t.Error("expected an error with an unknown parameter")
+	}
+
+	_, out, err := executeActionCommand("create --starter=paramchart --set-param team=web testchart")
+	if err != nil {
+		t.Fatalf("Failed to run create: %s", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join("testchart", "templates", "port.tpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "testchart:8080 web" {
+		t.Errorf("unexpected template %q", data)
+	}
+	if _, err := os.Stat(filepath.Join("testchart", chartutil.StarterfileName)); !os.IsNotExist(err) {
+		t.Errorf("expected the starter file not to be copied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("testchart", "hook.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the hooks not to run without --run-starter-hooks, got %v", err)
+	}
+	if !strings.Contains(out, "Skipping starter hook sh -c echo $HELM_CHART_NAME $HELM_PARAM_TEAM > hook.txt") {
+		t.Errorf("expected the skipped hook to be listed, got %q", out)
+	}
+
+	if _, _, err := executeActionCommand("create --starter=paramchart --set-param team=web --run-starter-hooks hooked"); err != nil {
+		t.Fatalf("Failed to run create: %s", err)
+	}
+	data, err = ioutil.ReadFile(filepath.Join("hooked", "hook.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hooked web\n" {
+		t.Errorf("unexpected output of the hook %q", data)
+	}
+}
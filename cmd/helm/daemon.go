@@ -0,0 +1,139 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/controller"
+	"github.com/open-hand/helm/pkg/engine"
+)
+
+const daemonDesc = `
+This command runs a controller reconciling releases with their desired state,
+until it is interrupted.
+
+The desired releases are read from the file of --file, checked for changes
+every --file-interval:
+
+    releases:
+    - name: web
+      namespace: apps
+      chart: web
+      version: 1.2.0
+      repoURL: https://charts.example.com/
+      values:
+        replicas: 2
+
+or, with --custom-resources, from the HelmRelease custom resources
+(helm.sh/v1alpha1) of the cluster, whose spec has the same fields and whose
+status records the result of their reconciliation. A custom resource only
+manages a release of its own namespace, and cannot use a local chart.
+
+The missing releases are installed and the ones whose chart or values differ
+are upgraded. All releases are reconciled again every --interval, and with
+--detect-drift the releases whose resources drifted from their manifest are
+upgraded to repair them.
+`
+
+type daemonOptions struct {
+	file              string
+	fileInterval      time.Duration
+	customResources   bool
+	watchNamespace    string
+	interval          time.Duration
+	detectDrift       bool
+	rollbackOnFailure bool
+	renderLimits      engine.Limits
+}
+
+func newDaemonCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	o := &daemonOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "daemon",
+		Short:             "reconcile releases with their desired state",
+		Long:              daemonDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cfg)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&o.file, "file", "f", "", "file listing the desired releases")
+	f.DurationVar(&o.fileInterval, "file-interval", 10*time.Second, "interval between the checks for changes of the file")
+	f.BoolVar(&o.customResources, "custom-resources", false, "read the desired releases from the HelmRelease custom resources")
+	f.StringVar(&o.watchNamespace, "watch-namespace", "", "namespace of the HelmRelease custom resources, all if empty")
+	f.DurationVar(&o.interval, "interval", controller.DefaultInterval, "interval between the reconciliations of all releases")
+	f.BoolVar(&o.detectDrift, "detect-drift", false, "upgrade the releases whose resources drifted from their manifest")
+	f.BoolVar(&o.rollbackOnFailure, "rollback-on-failure", false, "roll the failed upgrades back to the last deployed revision")
+	bindRenderLimitsFlags(cmd, &o.renderLimits, engine.DefaultLimits)
+
+	return cmd
+}
+
+func (o *daemonOptions) run(actionConfig *action.Configuration) error {
+	logger := settings.Logger()
+
+	var source controller.Source
+	switch {
+	case o.file != "" && o.customResources:
+		return errors.New("--file and --custom-resources cannot be used together")
+	case o.file != "":
+		source = &controller.FileSource{Path: o.file, Interval: o.fileInterval, Logger: logger}
+	case o.customResources:
+		restConfig, err := settings.RESTClientGetter().ToRESTConfig()
+		if err != nil {
+			return err
+		}
+		client, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+		source = &controller.CustomResourceSource{Client: client, Namespace: o.watchNamespace, Logger: logger}
+	default:
+		return errors.New("either --file or --custom-resources is required")
+	}
+
+	actionConfig.RenderLimits = o.renderLimits
+	c := &controller.Controller{
+		Source:            source,
+		Config:            namespaceConfigs(actionConfig),
+		Settings:          settings,
+		Interval:          o.interval,
+		DetectDrift:       o.detectDrift,
+		RollbackOnFailure: o.rollbackOnFailure,
+		Logger:            logger,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("reconciling releases")
+	return c.Run(ctx)
+}
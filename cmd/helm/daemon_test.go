@@ -0,0 +1,41 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDaemonCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "daemon without source",
+		cmd:       "daemon",
+		golden:    "output/daemon-no-source.txt",
+		wantError: true,
+	}, {
+		name:      "daemon with both sources",
+		cmd:       "daemon --file releases.yaml --custom-resources",
+		golden:    "output/daemon-both-sources.txt",
+		wantError: true,
+	}, {
+		name:      "daemon with a missing file",
+		cmd:       "daemon --file testdata/releases-missing.yaml",
+		golden:    "output/daemon-missing-file.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
 )
 
 const dependencyDesc = `
@@ -100,6 +101,7 @@ func newDependencyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 func newDependencyListCmd(out io.Writer) *cobra.Command {
 	client := action.NewDependency()
+	var outfmt output.Format
 	cmd := &cobra.Command{
 		Use:     "list CHART",
 		Aliases: []string{"ls"},
@@ -111,12 +113,41 @@ func newDependencyListCmd(out io.Writer) *cobra.Command {
 			if len(args) > 0 {
 				chartpath = filepath.Clean(args[0])
 			}
-			return client.List(chartpath, out)
+			w := &dependencyListWriter{client: client, chartpath: chartpath}
+			if outfmt != output.Table {
+				var err error
+				if w.statuses, err = client.Statuses(chartpath); err != nil {
+					return err
+				}
+			}
+			return outfmt.Write(out, w)
 		},
 	}
 
 	f := cmd.Flags()
 
 	f.UintVar(&client.ColumnWidth, "max-col-width", 80, "maximum column width for output table")
+	bindOutputFlag(cmd, &outfmt)
 	return cmd
 }
+
+// dependencyListWriter writes the dependencies of the chart at chartpath. The
+// table is written by client, with the warnings about the charts missing
+// from Chart.yaml.
+type dependencyListWriter struct {
+	client    *action.Dependency
+	chartpath string
+	statuses  []action.DependencyStatus
+}
+
+func (w *dependencyListWriter) WriteTable(out io.Writer) error {
+	return w.client.List(w.chartpath, out)
+}
+
+func (w *dependencyListWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.statuses)
+}
+
+func (w *dependencyListWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.statuses)
+}
@@ -24,13 +24,21 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/cli/output"
 )
 
 var envHelp = `
 Env prints out all the environment information in use by Helm.
+
+With '--output json' or '--output yaml', each setting is printed along with
+where its value comes from (a flag, an environment variable or the defaults),
+the environment variable it was read from and the flags that set it.
 `
 
 func newEnvCmd(out io.Writer) *cobra.Command {
+	var outfmt output.Format
+
 	cmd := &cobra.Command{
 		Use:   "env",
 		Short: "helm client environment information",
@@ -44,25 +52,54 @@ func newEnvCmd(out io.Writer) *cobra.Command {
 
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		},
-		Run: func(cmd *cobra.Command, args []string) {
-			envVars := settings.EnvVars()
-
-			if len(args) == 0 {
-				// Sort the variables by alphabetical order.
-				// This allows for a constant output across calls to 'helm env'.
-				keys := getSortedEnvVarKeys()
-
-				for _, k := range keys {
-					fmt.Fprintf(out, "%s=\"%s\"\n", k, envVars[k])
-				}
-			} else {
-				fmt.Fprintf(out, "%s\n", envVars[args[0]])
-			}
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return outfmt.Write(out, &envWriter{settings: settings.Settings(), args: args})
 		},
 	}
+
+	bindOutputFlag(cmd, &outfmt)
 	return cmd
 }
 
+type envWriter struct {
+	settings map[string]cli.Setting
+	args     []string
+}
+
+func (w *envWriter) WriteTable(out io.Writer) error {
+	if len(w.args) > 0 {
+		_, err := fmt.Fprintf(out, "%s\n", w.settings[w.args[0]].Value)
+		return err
+	}
+	// Sort the variables by alphabetical order.
+	// This allows for a constant output across calls to 'helm env'.
+	for _, k := range getSortedEnvVarKeys() {
+		if _, err := fmt.Fprintf(out, "%s=\"%s\"\n", k, w.settings[k].Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *envWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.structured())
+}
+
+func (w *envWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.structured())
+}
+
+// structured returns the setting named on the command line, or all of them.
+func (w *envWriter) structured() interface{} {
+	if len(w.args) > 0 {
+		if s, ok := w.settings[w.args[0]]; ok {
+			return s
+		}
+		return nil
+	}
+	return w.settings
+}
+
 func getSortedEnvVarKeys() []string {
 	envVars := settings.EnvVars()
 
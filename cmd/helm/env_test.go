@@ -17,7 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/open-hand/helm/pkg/cli"
 )
 
 func TestEnv(t *testing.T) {
@@ -29,6 +32,39 @@ func TestEnv(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestEnvOutputJSON(t *testing.T) {
+	_, out, err := executeActionCommand("env --output json --repository-config /tmp/repositories.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var settings map[string]cli.Setting
+	if err := json.Unmarshal([]byte(out), &settings); err != nil {
+		t.Fatalf("expected JSON output, got %q: %s", out, err)
+	}
+	if s := settings["HELM_REPOSITORY_CONFIG"]; s.Value != "/tmp/repositories.yaml" || s.Source != cli.SourceFlag {
+		t.Errorf("expected the repository config to be set by flag, got %+v", s)
+	}
+	if _, ok := settings["HELM_REPOSITORY_CACHE"]; !ok {
+		t.Error("expected the repository cache to be listed")
+	}
+
+	_, out, err = executeActionCommand("env HELM_REPOSITORY_CONFIG --output json --repository-config /tmp/repositories.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var setting cli.Setting
+	if err := json.Unmarshal([]byte(out), &setting); err != nil {
+		t.Fatalf("expected JSON output, got %q: %s", out, err)
+	}
+	if setting.Value != "/tmp/repositories.yaml" {
+		t.Errorf("expected the repository config, got %+v", setting)
+	}
+}
+
+func TestEnvOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "env")
+}
+
 func TestEnvFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "env", false)
 	checkFileCompletion(t, "env HELM_BIN", false)
@@ -17,29 +17,69 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"k8s.io/klog/v2"
 
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/cli/values"
+	"github.com/open-hand/helm/pkg/engine"
 	"github.com/open-hand/helm/pkg/helmpath"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/policy"
 	"github.com/open-hand/helm/pkg/postrender"
+	"github.com/open-hand/helm/pkg/release"
 	"github.com/open-hand/helm/pkg/repo"
+	"github.com/open-hand/helm/pkg/storage"
 )
 
 const (
-	outputFlag         = "output"
-	postRenderFlag     = "post-renderer"
-	postRenderArgsFlag = "post-renderer-args"
+	outputFlag             = "output"
+	postRenderFlag         = "post-renderer"
+	postRenderArgsFlag     = "post-renderer-args"
+	policyCheckFlag        = "policy-check"
+	notesToFlag            = "notes-to"
+	reuseValuesFlag        = "reuse-values"
+	preflightFlag          = "preflight"
+	migrateAPIsFlag        = "migrate-apis"
+	waitStrategyFlag       = "wait-strategy"
+	applyConcurrencyFlag   = "apply-concurrency"
+	serverSideFlag         = "server-side"
+	forceConflictsFlag     = "force-conflicts"
+	fieldManagerFlag       = "field-manager"
+	namespacePolicyFlag    = "namespace-policy"
+	allowNamespaceFlag     = "allow-namespace"
+	denyClusterKindFlag    = "deny-cluster-kind"
+	allowClusterKindFlag   = "allow-cluster-kind"
+	duplicateResourcesFlag = "duplicate-resources"
+	detectFeaturesFlag     = "detect-features"
+	capabilitiesFileFlag   = "capabilities-file"
+	pruneHistoryFlag       = "prune-history"
+)
+
+// The flags of the render limits.
+const (
+	renderTimeoutFlag         = "render-timeout"
+	renderMaxOutputSizeFlag   = "render-max-output-size"
+	renderMaxIncludeDepthFlag = "render-max-include-depth"
+	renderMaxListLengthFlag   = "render-max-list-length"
 )
 
 func addValueOptionsFlags(f *pflag.FlagSet, v *values.Options) {
@@ -50,10 +90,11 @@ func addValueOptionsFlags(f *pflag.FlagSet, v *values.Options) {
 }
 
 func addChartPathOptionsFlags(f *pflag.FlagSet, c *action.ChartPathOptions) {
-	f.StringVar(&c.Version, "version", "", "specify a version constraint for the chart version to use. This constraint can be a specific tag (e.g. 1.1.1) or it may reference a valid range (e.g. ^2.0.0). If this is not specified, the latest version is used")
+	f.StringVar(&c.Version, "version", "", "specify a version constraint for the chart version to use. This constraint can be a specific tag (e.g. 1.1.1) or it may reference a valid range (e.g. ^2.0.0). A digest (e.g. sha256:...) pins the chart archive with that digest in the repository index, or the chart manifest in an OCI registry. If this is not specified, the latest version is used")
+	f.StringVar(&c.Channel, "channel", "", fmt.Sprintf("only use the chart versions available in this release channel, declared by their %q annotation. Allowed values: %s", repo.ChannelAnnotation, strings.Join(repo.Channels, ", ")))
 	f.BoolVar(&c.Verify, "verify", false, "verify the package before using it")
 	f.StringVar(&c.Keyring, "keyring", defaultKeyring(), "location of public keys used for verification")
-	f.StringVar(&c.RepoURL, "repo", "", "chart repository url where to locate the requested chart")
+	f.StringVar(&c.RepoURL, "repo", "", "chart repository url, or name or alias of a configured repository, where to locate the requested chart")
 	f.StringVar(&c.Username, "username", "", "chart repository username where to locate the requested chart")
 	f.StringVar(&c.Password, "password", "", "chart repository password where to locate the requested chart")
 	f.StringVar(&c.CertFile, "cert-file", "", "identify HTTPS client using this SSL certificate file")
@@ -61,6 +102,12 @@ func addChartPathOptionsFlags(f *pflag.FlagSet, c *action.ChartPathOptions) {
 	f.BoolVar(&c.InsecureSkipTLSverify, "insecure-skip-tls-verify", false, "skip tls certificate checks for the chart download")
 	f.StringVar(&c.CaFile, "ca-file", "", "verify certificates of HTTPS-enabled servers using this CA bundle")
 	f.BoolVar(&c.PassCredentialsAll, "pass-credentials", false, "pass credentials to all domains")
+	f.BoolVar(&c.VerifyCosign, "verify-cosign", false, "verify the cosign signature of the chart before using it")
+	f.StringVar(&c.Cosign.KeyFile, "cosign-key", "", "public key to verify cosign signatures with")
+	f.StringVar(&c.Cosign.Identity, "cosign-identity", "", "regular expression the identity of keyless cosign signers must match")
+	f.StringVar(&c.Cosign.Issuer, "cosign-oidc-issuer", "", "OIDC issuer that must have authenticated keyless cosign signers")
+	f.StringVar(&c.Cosign.RootsFile, "cosign-roots", "", "root certificates of the authority issuing keyless signing certificates")
+	f.StringVar(&c.Cosign.RekorKeyFile, "cosign-rekor-key", "", "public key of the transparency log keyless cosign signatures are recorded in")
 }
 
 // bindOutputFlag will add the output flag to the given command and bind the
@@ -112,12 +159,418 @@ func (o *outputValue) Set(s string) error {
 	return nil
 }
 
+// resultError is the error of a command that failed after producing a
+// result, such as the report of a drifted release or the releases
+// uninstalled before one failed.
+type resultError struct {
+	err    error
+	result output.Writer
+}
+
+func (e *resultError) Error() string {
+	return e.err.Error()
+}
+
+func (e *resultError) Cause() error {
+	return e.err
+}
+
+// writeResult writes the result of a command that failed with err, if not
+// nil, after producing it. The table output is written at once and err is
+// returned, while the JSON and YAML output is left to writeErrorOutput so
+// that the result and the error are written in a single document.
+func writeResult(out io.Writer, outfmt output.Format, result output.Writer, err error) error {
+	if err == nil || outfmt == output.Table {
+		if werr := outfmt.Write(out, result); werr != nil {
+			return werr
+		}
+		return err
+	}
+	return &resultError{err: err, result: result}
+}
+
+// writeErrorOutput writes the error of the command in the format of its output
+// flag, if it has one, so that its JSON and YAML output has the same schema
+// whether it succeeds or fails. The result the command produced before
+// failing, if any, is written along with the error.
+func writeErrorOutput(cmd *cobra.Command, out io.Writer, err error) {
+	// Commands with an output flag of their own, such as lint, report
+	// their errors in their output.
+	f := cmd.Flags().Lookup(outputFlag)
+	if f == nil {
+		return
+	}
+	format, ok := f.Value.(*outputValue)
+	if !ok {
+		return
+	}
+	var result output.Writer
+	if rerr, ok := err.(*resultError); ok {
+		err, result = rerr.err, rerr.result
+	}
+	if werr := output.Format(*format).WriteError(out, err, result); werr != nil {
+		debug("%+v", werr)
+	}
+}
+
 func bindPostRenderFlag(cmd *cobra.Command, varRef *postrender.PostRenderer) {
 	p := &postRendererOptions{varRef, "", []string{}}
-	cmd.Flags().Var(&postRendererString{p}, postRenderFlag, "the path to an executable to be used for post rendering. If it exists in $PATH, the binary will be used, otherwise it will try to look for the executable at the given path")
+	cmd.Flags().Var(&postRendererString{p}, postRenderFlag, "the path to an executable to be used for post rendering, or the name of a plugin providing a post-renderer. If it exists in $PATH, the binary will be used, otherwise it will try to look for the executable at the given path")
 	cmd.Flags().Var(&postRendererArgsSlice{p}, postRenderArgsFlag, "an argument to the post-renderer (can specify multiple)")
 }
 
+// addNamespaceOptionsFlags adds the flags configuring the namespace created
+// with --create-namespace.
+func addNamespaceOptionsFlags(f *pflag.FlagSet, opts *action.NamespaceOptions) {
+	f.StringToStringVar(&opts.Labels, "namespace-labels", nil, "labels to set on the namespace created with --create-namespace, e.g. pod-security.kubernetes.io/enforce=restricted")
+	f.StringToStringVar(&opts.Annotations, "namespace-annotations", nil, "annotations to set on the namespace created with --create-namespace")
+	f.Var(&namespaceExistsPolicyValue{&opts.ExistsPolicy}, "namespace-exists", "how --create-namespace handles an existing namespace: 'use' it, use it only if it has the --namespace-labels ('require-labels'), or 'fail'")
+}
+
+type namespaceExistsPolicyValue struct {
+	policy *action.NamespaceExistsPolicy
+}
+
+func (v *namespaceExistsPolicyValue) String() string {
+	return string(*v.policy)
+}
+
+func (v *namespaceExistsPolicyValue) Type() string {
+	return "string"
+}
+
+func (v *namespaceExistsPolicyValue) Set(val string) error {
+	p, err := action.ParseNamespaceExistsPolicy(val)
+	if err != nil {
+		return err
+	}
+	*v.policy = p
+	return nil
+}
+
+func bindReuseValuesFlag(cmd *cobra.Command, client *action.Upgrade) {
+	cmd.Flags().Var(&reuseValuesValue{client}, reuseValuesFlag, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. With 'smart', merge them with the defaults of the new chart rather than the ones of the last release's chart. If '--reset-values' is specified, this is ignored")
+	cmd.Flags().Lookup(reuseValuesFlag).NoOptDefVal = "true"
+}
+
+// reuseValuesValue is the value of --reuse-values: a boolean, or "smart".
+type reuseValuesValue struct {
+	client *action.Upgrade
+}
+
+func (v *reuseValuesValue) String() string {
+	if v.client.ReuseValues && v.client.SmartReuseValues {
+		return "smart"
+	}
+	return strconv.FormatBool(v.client.ReuseValues)
+}
+
+func (v *reuseValuesValue) Type() string {
+	return "string"
+}
+
+func (v *reuseValuesValue) Set(val string) error {
+	if val == "smart" {
+		v.client.ReuseValues, v.client.SmartReuseValues = true, true
+		return nil
+	}
+	reuse, err := strconv.ParseBool(val)
+	if err != nil {
+		return errors.Errorf("invalid value %q, must be a boolean or \"smart\"", val)
+	}
+	v.client.ReuseValues, v.client.SmartReuseValues = reuse, false
+	return nil
+}
+
+func bindPolicyCheckFlag(cmd *cobra.Command, varRef **policy.Checker) {
+	cmd.Flags().Var(&policyCheckSlice{checker: varRef}, policyCheckFlag, "a file of CEL policies to enforce on the rendered resources before they are applied. Violations of policies enforced with 'deny' fail the operation, the ones of policies enforced with 'warn' are printed (can specify multiple)")
+}
+
+func bindPreflightFlag(cmd *cobra.Command, varRef *bool) {
+	cmd.Flags().BoolVar(varRef, preflightFlag, false, "before applying anything, check that the CPU and memory requested by the rendered workloads fit in the ResourceQuotas of the namespace and on the nodes of the cluster")
+}
+
+func bindMigrateAPIsFlag(cmd *cobra.Command, varRef *bool) {
+	cmd.Flags().BoolVar(varRef, migrateAPIsFlag, false, "convert the Kubernetes APIs removed from the cluster in the stored manifests of the release to the APIs replacing them before computing the changes, as 'helm release convert-apis' does")
+}
+
+func bindWaitStrategyFlag(cmd *cobra.Command, varRef *kube.WaitStrategy) {
+	cmd.Flags().Var(&waitStrategyValue{varRef}, waitStrategyFlag, "how --wait checks the resources: 'watch' them and check them as soon as they change, or 'poll' them every two seconds. Defaults to $HELM_WAIT_STRATEGY, or 'watch'")
+	err := cmd.RegisterFlagCompletionFunc(waitStrategyFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		for _, s := range kube.WaitStrategies {
+			names = append(names, string(s))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func bindApplyConcurrencyFlag(cmd *cobra.Command, varRef *int) {
+	cmd.Flags().IntVar(varRef, applyConcurrencyFlag, 0, "the maximum number of resources of a kind created or updated at the same time. The kinds are still applied one after the other. By default new resources are all created at once and existing ones updated one at a time")
+}
+
+func bindServerSideApplyFlags(cmd *cobra.Command, opts *kube.ApplyOptions) {
+	f := cmd.Flags()
+	f.BoolVar(&opts.ServerSide, serverSideFlag, false, "create and update the resources with server-side applies instead of three-way merge patches")
+	f.BoolVar(&opts.ForceConflicts, forceConflictsFlag, false, "with --server-side, take the ownership of the fields of the other field managers conflicting with the applies")
+	f.StringVar(&opts.FieldManager, fieldManagerFlag, "", "the name of the manager of the fields set on the resources. Defaults to 'helm'")
+}
+
+func bindNamespacePolicyFlags(cmd *cobra.Command, p *action.NamespacePolicy) {
+	f := cmd.Flags()
+	f.Var(&namespacePolicyValue{&p.Mode}, namespacePolicyFlag, "what to do with the rendered resources targeting other namespaces than the release namespace, or cluster-scoped of a kind not allowed: 'allow' them, 'warn' about them, or 'block' the release")
+	f.StringArrayVar(&p.AllowedNamespaces, allowNamespaceFlag, nil, "a namespace the rendered resources may target whatever --namespace-policy, or a pattern such as 'shared-*' (can specify multiple)")
+	f.StringArrayVar(&p.DeniedClusterKinds, denyClusterKindFlag, nil, "with --namespace-policy warn or block, a kind of cluster-scoped resources not allowed, or a pattern such as '*WebhookConfiguration' (can specify multiple)")
+	f.StringArrayVar(&p.AllowedClusterKinds, allowClusterKindFlag, nil, "with --namespace-policy warn or block, the only kinds of cluster-scoped resources allowed, or patterns (can specify multiple)")
+	err := cmd.RegisterFlagCompletionFunc(namespacePolicyFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		for _, m := range action.NamespacePolicyModes {
+			names = append(names, string(m))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type namespacePolicyValue struct {
+	mode *action.NamespacePolicyMode
+}
+
+func (v *namespacePolicyValue) String() string {
+	return string(*v.mode)
+}
+
+func (v *namespacePolicyValue) Type() string {
+	return "string"
+}
+
+func (v *namespacePolicyValue) Set(val string) error {
+	m, err := action.ParseNamespacePolicyMode(val)
+	if err != nil {
+		return err
+	}
+	*v.mode = m
+	return nil
+}
+
+// bindRenderLimitsFlags binds the flags of limits, defaulting to defaults.
+func bindRenderLimitsFlags(cmd *cobra.Command, limits *engine.Limits, defaults engine.Limits) {
+	f := cmd.Flags()
+	f.DurationVar(&limits.Timeout, renderTimeoutFlag, defaults.Timeout, "the maximum time to render each template of a chart, unlimited if 0")
+	f.IntVar(&limits.MaxOutputSize, renderMaxOutputSizeFlag, defaults.MaxOutputSize, "the maximum size in bytes of each rendered template, and of the strings the template functions return, unlimited if 0")
+	f.IntVar(&limits.MaxIncludeDepth, renderMaxIncludeDepthFlag, defaults.MaxIncludeDepth, "the maximum depth of the nested include and tpl calls of the templates, unlimited if 0")
+	f.IntVar(&limits.MaxListLength, renderMaxListLengthFlag, defaults.MaxListLength, "the maximum length of the lists made by until, untilStep and seq in the templates, unlimited if 0")
+}
+
+func bindDetectFeaturesFlag(cmd *cobra.Command, detect *bool) {
+	cmd.Flags().BoolVar(detect, detectFeaturesFlag, false, "detect the cloud provider, network plugin, default storage class, IP families and pod security level of the cluster for the templates as .Capabilities.Features, with extra requests to the cluster")
+}
+
+func bindPruneHistoryFlag(cmd *cobra.Command, retention **storage.RetentionPolicy) {
+	f := cmd.Flags().VarPF(&pruneHistoryValue{retention}, pruneHistoryFlag, "", "once the release succeeds, delete its old revisions as 'helm release prune' does with its default policy")
+	f.NoOptDefVal = "true"
+}
+
+// pruneHistoryValue sets the retention policy of the action configuration to
+// the default one when true.
+type pruneHistoryValue struct {
+	retention **storage.RetentionPolicy
+}
+
+func (v *pruneHistoryValue) String() string {
+	return strconv.FormatBool(*v.retention != nil)
+}
+
+func (v *pruneHistoryValue) Type() string {
+	return "bool"
+}
+
+func (v *pruneHistoryValue) Set(s string) error {
+	prune, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !prune:
+		*v.retention = nil
+	case *v.retention == nil:
+		defaults := storage.DefaultRetentionPolicy
+		*v.retention = &defaults
+	}
+	return nil
+}
+
+func bindCapabilitiesFileFlag(cmd *cobra.Command, caps **chartutil.Capabilities) {
+	cmd.Flags().Var(&capabilitiesFileValue{caps: caps}, capabilitiesFileFlag, "render with the Kubernetes version, API versions and features of a capabilities file written by 'helm capabilities export' instead of the ones of the cluster or the defaults")
+	err := cmd.RegisterFlagCompletionFunc(capabilitiesFileFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"json", "yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// capabilitiesFileValue loads the capabilities file given to a flag.
+type capabilitiesFileValue struct {
+	path string
+	caps **chartutil.Capabilities
+}
+
+func (v *capabilitiesFileValue) String() string {
+	return v.path
+}
+
+func (v *capabilitiesFileValue) Type() string {
+	return "string"
+}
+
+func (v *capabilitiesFileValue) Set(path string) error {
+	caps, err := chartutil.LoadCapabilities(path)
+	if err != nil {
+		return err
+	}
+	v.path, *v.caps = path, caps
+	return nil
+}
+
+func bindDuplicateResourcesFlag(cmd *cobra.Command, mode *action.DuplicateResourcesMode) {
+	cmd.Flags().Var(&duplicateResourcesValue{mode}, duplicateResourcesFlag, "what to do with the rendered resources sharing the same kind, namespace and name: 'error' to reject the release, or 'warn' about them and let the last one win. Defaults to 'error'")
+	err := cmd.RegisterFlagCompletionFunc(duplicateResourcesFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var names []string
+		for _, m := range action.DuplicateResourcesModes {
+			names = append(names, string(m))
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type duplicateResourcesValue struct {
+	mode *action.DuplicateResourcesMode
+}
+
+func (v *duplicateResourcesValue) String() string {
+	return string(*v.mode)
+}
+
+func (v *duplicateResourcesValue) Type() string {
+	return "string"
+}
+
+func (v *duplicateResourcesValue) Set(val string) error {
+	m, err := action.ParseDuplicateResourcesMode(val)
+	if err != nil {
+		return err
+	}
+	*v.mode = m
+	return nil
+}
+
+// warnDuplicateResources warns about the resources rendered more than once.
+func warnDuplicateResources(duplicates []action.DuplicateResource) {
+	for _, d := range duplicates {
+		warning("%s, the last one is applied", d)
+	}
+}
+
+// debugSkippedResources reports the rendered resources left out by their
+// only-on annotations.
+func debugSkippedResources(skipped []action.SkippedResource) {
+	for _, s := range skipped {
+		debug("skipped %s", s)
+	}
+}
+
+// warnCrossNamespace warns about the rendered resources targeting other
+// namespaces than the release namespace.
+func warnCrossNamespace(resources []release.ResourceIdentity) {
+	for _, r := range resources {
+		if r.Namespace == "" {
+			warning("%s is a cluster-scoped resource of a kind not allowed", r)
+			continue
+		}
+		warning("%s targets another namespace than the release namespace", r)
+	}
+}
+
+type waitStrategyValue struct {
+	strategy *kube.WaitStrategy
+}
+
+func (v *waitStrategyValue) String() string {
+	return string(*v.strategy)
+}
+
+func (v *waitStrategyValue) Type() string {
+	return "string"
+}
+
+func (v *waitStrategyValue) Set(val string) error {
+	s, err := kube.ParseWaitStrategy(val)
+	if err != nil {
+		return err
+	}
+	*v.strategy = s
+	return nil
+}
+
+func bindNotesToFlag(cmd *cobra.Command, varRef *[]string) {
+	cmd.Flags().StringArrayVar(varRef, notesToFlag, nil, "a file or an http(s) URL to which the notes of the release are written as JSON once it is deployed (can specify multiple)")
+}
+
+// deliverNotes writes the notes of rel to the targets of --notes-to, once
+// the release is deployed. Since the release succeeded, failures to deliver
+// the notes are only warned about.
+func deliverNotes(rel *release.Release, targets []string) {
+	if rel.Info == nil || rel.Info.Status != release.StatusDeployed {
+		return
+	}
+	for _, target := range targets {
+		if err := action.DeliverNotes(rel, target); err != nil {
+			warning("%s", err)
+		}
+	}
+}
+
+type policyCheckSlice struct {
+	checker **policy.Checker
+	files   []string
+}
+
+func (p *policyCheckSlice) String() string {
+	return "[" + strings.Join(p.files, ",") + "]"
+}
+
+func (p *policyCheckSlice) Type() string {
+	return "stringSlice"
+}
+
+func (p *policyCheckSlice) Set(val string) error {
+	for _, file := range strings.Split(val, ",") {
+		policies, err := policy.LoadCELFile(file)
+		if err != nil {
+			return err
+		}
+		if *p.checker == nil {
+			*p.checker = &policy.Checker{
+				Warn: func(v policy.Violation) {
+					warning("%s", v)
+				},
+			}
+		}
+		(*p.checker).Policies = append((*p.checker).Policies, policies...)
+		p.files = append(p.files, file)
+	}
+	return nil
+}
+
 type postRendererOptions struct {
 	renderer   *postrender.PostRenderer
 	binaryPath string
@@ -141,7 +594,7 @@ func (p *postRendererString) Set(val string) error {
 		return nil
 	}
 	p.options.binaryPath = val
-	pr, err := postrender.NewExec(p.options.binaryPath, p.options.args...)
+	pr, err := newPostRenderer(p.options.binaryPath, p.options.args...)
 	if err != nil {
 		return err
 	}
@@ -170,7 +623,7 @@ func (p *postRendererArgsSlice) Set(val string) error {
 		return nil
 	}
 	// overwrite if already create PostRenderer by `post-renderer` flags
-	pr, err := postrender.NewExec(p.options.binaryPath, p.options.args...)
+	pr, err := newPostRenderer(p.options.binaryPath, p.options.args...)
 	if err != nil {
 		return err
 	}
@@ -227,6 +680,133 @@ func compVersionFlag(chartRef string, toComplete string) ([]string, cobra.ShellC
 	return versions, cobra.ShellCompDirectiveNoFileComp
 }
 
+// bindSetFlagsCompletion completes the flags setting values with the keys of
+// the values of the chart chartRef returns, if any.
+func bindSetFlagsCompletion(cmd *cobra.Command, chartRef func(args []string) (string, bool), version *string) {
+	for _, name := range []string{"set", "set-string", "set-file"} {
+		name := name
+		err := cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			ref, ok := chartRef(args)
+			if !ok {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			// The value of a file is a path the shell completes.
+			if name == "set-file" && strings.Contains(toComplete[strings.LastIndex(toComplete, ",")+1:], "=") {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			return compValuesKeys(ref, *version, toComplete)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// compValuesKeys completes the key being set by toComplete, a list of
+// key=value pairs separated by commas, with the keys of the values of the
+// chart chartRef at the given version. The keys are the ones declared by
+// the values schema of the chart, described by their description, and the
+// ones of its default values.
+//
+// The chart is a local chart, or a chart of a repository downloaded to the
+// repository cache, found from the cached index of the repository: charts
+// are never downloaded to complete their values.
+func compValuesKeys(chartRef, version, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix, current := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, current = toComplete[:i+1], toComplete[i+1:]
+	}
+	if strings.Contains(current, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	chrt, err := loadCachedChart(chartRef, version)
+	if err != nil {
+		cobra.CompDebugln(fmt.Sprintf("unable to load chart %s: %s", chartRef, err), settings.Debug)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	keys := map[string]string{}
+	valuesKeys("", chrt.Values, keys)
+	if len(chrt.Schema) > 0 {
+		var schema map[string]interface{}
+		if err := json.Unmarshal(chrt.Schema, &schema); err == nil {
+			schemaKeys("", schema, keys)
+		}
+	}
+
+	var completions []string
+	for key, desc := range keys {
+		if !strings.HasPrefix(key, current) {
+			continue
+		}
+		completion := prefix + key + "="
+		if desc != "" {
+			completion += "\t" + desc
+		}
+		completions = append(completions, completion)
+	}
+	sort.Strings(completions)
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// loadCachedChart loads the local chart chartRef, or the chart "repo/name"
+// at the given version, or its latest one, from the repository cache.
+func loadCachedChart(chartRef, version string) (*chart.Chart, error) {
+	if _, err := os.Stat(chartRef); err == nil {
+		return loader.Load(chartRef)
+	}
+	chartInfo := strings.Split(chartRef, "/")
+	if len(chartInfo) != 2 {
+		return nil, errors.Errorf("%s is neither a local chart nor a chart of a repository", chartRef)
+	}
+	indexFile, err := repo.LoadIndexFile(filepath.Join(settings.RepositoryCache, helmpath.CacheIndexFile(chartInfo[0])))
+	if err != nil {
+		return nil, err
+	}
+	cv, err := indexFile.Get(chartInfo[1], version)
+	if err != nil {
+		return nil, err
+	}
+	if len(cv.URLs) == 0 {
+		return nil, errors.Errorf("chart %s has no URL", chartRef)
+	}
+	return loader.Load(filepath.Join(settings.RepositoryCache, path.Base(cv.URLs[0])))
+}
+
+// valuesKeys adds the dotted keys of the leaves of the values to keys.
+func valuesKeys(prefix string, values map[string]interface{}, keys map[string]string) {
+	for name, value := range values {
+		key := prefix + name
+		if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+			valuesKeys(key+".", m, keys)
+			continue
+		}
+		if _, ok := keys[key]; !ok {
+			keys[key] = ""
+		}
+	}
+}
+
+// schemaKeys adds the dotted keys of the leaf properties of the JSON schema
+// to keys, with their descriptions.
+func schemaKeys(prefix string, schema map[string]interface{}, keys map[string]string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, property := range properties {
+		property, ok := property.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key := prefix + name
+		if sub, ok := property["properties"].(map[string]interface{}); ok && len(sub) > 0 {
+			schemaKeys(key+".", property, keys)
+			continue
+		}
+		desc, _ := property["description"].(string)
+		keys[key] = desc
+	}
+}
+
 // addKlogFlags adds flags from k8s.io/klog
 // marks the flags as hidden to avoid polluting the help text
 func addKlogFlags(fs *pflag.FlagSet) {
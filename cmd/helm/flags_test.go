@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -93,3 +94,55 @@ func outputFlagCompletionTest(t *testing.T, cmdName string) {
 	}}
 	runTestCmd(t, tests)
 }
+
+func TestWriteErrorOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    string
+		rels   []*release.Release
+		expect string
+	}{{
+		name:   "json output",
+		cmd:    "uninstall missing -o json",
+		expect: "{\"error\":\"uninstall: Release not loaded: missing: release: not found\"}\n",
+	}, {
+		name:   "yaml output",
+		cmd:    "uninstall missing -o yaml",
+		expect: "error: 'uninstall: Release not loaded: missing: release: not found'\n",
+	}, {
+		name:   "json output with the result before the error",
+		cmd:    "uninstall aeneas missing -o json",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+		expect: "{\"error\":\"uninstall: Release not loaded: missing: release: not found\",\"result\":[{\"name\":\"aeneas\",\"status\":\"uninstalled\"}]}\n",
+	}, {
+		name:   "yaml output with the result before the error",
+		cmd:    "uninstall aeneas missing -o yaml",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+		expect: "error: 'uninstall: Release not loaded: missing: release: not found'\nresult:\n- name: aeneas\n  status: uninstalled\n",
+	}, {
+		name: "table output",
+		cmd:  "uninstall missing",
+	}, {
+		name: "command without output flag",
+		cmd:  "get manifest missing",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storageFixture()
+			for _, rel := range tt.rels {
+				if err := store.Create(rel); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, _, err := executeActionCommandC(store, tt.cmd)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var out bytes.Buffer
+			writeErrorOutput(c, &out, err)
+			if out.String() != tt.expect {
+				t.Errorf("expected %q, got %q", tt.expect, out.String())
+			}
+		})
+	}
+}
@@ -17,8 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
+	"strings"
+
+	"github.com/gosuri/uitable"
 
 	"github.com/spf13/cobra"
 
@@ -30,10 +35,17 @@ import (
 var getAllHelp = `
 This command prints a human readable collection of information about the
 notes, hooks, supplied values, and generated manifest file of the given release.
+
+With --live, the resources of the manifest are joined with their live state in
+the cluster: whether they exist, whether they are ready and which of their
+fields differ from the manifest. With --output json or yaml, the release and
+the state of its resources are printed as a structured document.
 `
 
 func newGetAllCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	var template string
+	var live bool
+	var outfmt output.Format
 	client := action.NewGet(cfg)
 
 	cmd := &cobra.Command{
@@ -48,18 +60,25 @@ func newGetAllCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			res, err := client.Run(args[0])
+			res := &action.LiveRelease{}
+			var err error
+			if live {
+				res, err = client.RunLive(context.Background(), args[0])
+			} else {
+				res.Release, err = client.Run(args[0])
+			}
 			if err != nil {
 				return err
 			}
 			if template != "" {
 				data := map[string]interface{}{
-					"Release": res,
+					"Release":   res.Release,
+					"Resources": res.Resources,
 				}
 				return tpl(template, data, out)
 			}
 
-			return output.Table.Write(out, &statusPrinter{res, true, false})
+			return outfmt.Write(out, &getAllWriter{res, live})
 		},
 	}
 
@@ -77,6 +96,46 @@ func newGetAllCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	}
 
 	f.StringVar(&template, "template", "", "go template for formatting the output, eg: {{.Release.Name}}")
+	f.BoolVar(&live, "live", false, "join the resources of the release with their live state in the cluster")
+	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
 }
+
+type getAllWriter struct {
+	release *action.LiveRelease
+	live    bool
+}
+
+func (w *getAllWriter) WriteTable(out io.Writer) error {
+	if err := (statusPrinter{w.release.Release, true, false, nil}).WriteTable(out); err != nil {
+		return err
+	}
+	if !w.live {
+		return nil
+	}
+
+	fmt.Fprintln(out, "LIVE STATE:")
+	tbl := uitable.New()
+	tbl.AddRow("KIND", "NAMESPACE", "NAME", "EXISTS", "READY", "DRIFT")
+	for _, r := range w.release.Resources {
+		fields := make([]string, 0, len(r.Drift))
+		for _, d := range r.Drift {
+			fields = append(fields, d.Path)
+		}
+		drift := strings.Join(fields, ",")
+		if r.Error != "" {
+			drift = "error: " + r.Error
+		}
+		tbl.AddRow(r.Kind, r.Namespace, r.Name, r.Exists, r.Ready, drift)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *getAllWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.release)
+}
+
+func (w *getAllWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.release)
+}
@@ -33,6 +33,11 @@ func TestGetCmd(t *testing.T) {
 		cmd:    "get all elevated-turkey --template {{.Release.Chart.Metadata.Version}}",
 		golden: "output/get-release-template.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "elevated-turkey"})},
+	}, {
+		name:   "get all with the live state of the resources",
+		cmd:    "get all thomas-guide --live",
+		golden: "output/get-release-live.txt",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "thomas-guide"})},
 	}, {
 		name:      "get all requires release name arg",
 		cmd:       "get all",
@@ -25,16 +25,28 @@ import (
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
 )
 
 const getHooksHelp = `
 This command downloads hooks for a given release.
 
 Hooks are formatted in YAML and separated by the YAML '---\n' separator.
+
+With '--output json' or '--output yaml', the events, weight and delete
+policies of each hook are shown along with the result of its last execution.
+
+The hooks can be filtered by the events they run on with '--event', and by
+the phase of their last execution with '--phase', e.g. to find the pre-upgrade
+hooks that failed the last time they ran:
+
+    $ helm get hooks RELEASE_NAME --event pre-upgrade --phase failed -o json
 `
 
 func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
-	client := action.NewGet(cfg)
+	client := action.NewGetHooks(cfg)
+	var outfmt output.Format
 
 	cmd := &cobra.Command{
 		Use:   "hooks RELEASE_NAME",
@@ -48,18 +60,19 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			res, err := client.Run(args[0])
+			hooks, err := client.Run(args[0])
 			if err != nil {
 				return err
 			}
-			for _, hook := range res.Hooks {
-				fmt.Fprintf(out, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
-			}
-			return nil
+			return outfmt.Write(out, hooksWriter(hooks))
 		},
 	}
 
-	cmd.Flags().IntVar(&client.Version, "revision", 0, "get the named release with revision")
+	f := cmd.Flags()
+	f.IntVar(&client.Version, "revision", 0, "get the named release with revision")
+	f.StringSliceVar(&client.Events, "event", nil, "only get the hooks run on these events, e.g. pre-upgrade (can specify multiple or separate values with commas)")
+	f.StringSliceVar(&client.Phases, "phase", nil, "only get the hooks whose last execution has these phases: unknown, running, succeeded or failed (can specify multiple or separate values with commas)")
+	bindOutputFlag(cmd, &outfmt)
 	err := cmd.RegisterFlagCompletionFunc("revision", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 1 {
 			return compListRevisions(toComplete, cfg, args[0])
@@ -73,3 +86,20 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	return cmd
 }
+
+type hooksWriter []*release.Hook
+
+func (w hooksWriter) WriteTable(out io.Writer) error {
+	for _, hook := range w {
+		fmt.Fprintf(out, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+	}
+	return nil
+}
+
+func (w hooksWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w)
+}
+
+func (w hooksWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w)
+}
@@ -23,11 +23,48 @@ import (
 )
 
 func TestGetHooks(t *testing.T) {
+	rel := release.Mock(&release.MockReleaseOptions{Name: "aeneas"})
+	rel.Hooks = append(rel.Hooks, &release.Hook{
+		Name:           "migrate",
+		Kind:           "Job",
+		Path:           "templates/migrate.yaml",
+		Manifest:       "kind: Job\nmetadata:\n  name: migrate",
+		Events:         []release.HookEvent{release.HookPreInstall, release.HookPreUpgrade},
+		Weight:         -5,
+		DeletePolicies: []release.HookDeletePolicy{release.HookBeforeHookCreation},
+		LastRun: release.HookExecution{
+			StartedAt:   mustParseTime("2006-01-02T15:04:05Z"),
+			CompletedAt: mustParseTime("2006-01-02T15:04:07Z"),
+			Phase:       release.HookPhaseFailed,
+		},
+	})
+
 	tests := []cmdTestCase{{
 		name:   "get hooks with release",
 		cmd:    "get hooks aeneas",
 		golden: "output/get-hooks.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+	}, {
+		name:   "get hooks filtered by event and phase",
+		cmd:    "get hooks aeneas --event pre-upgrade --phase failed",
+		golden: "output/get-hooks-filtered.txt",
+		rels:   []*release.Release{rel},
+	}, {
+		name:   "get hooks with json output",
+		cmd:    "get hooks aeneas -o json",
+		golden: "output/get-hooks.json",
+		rels:   []*release.Release{rel},
+	}, {
+		name:   "get hooks of never run hooks",
+		cmd:    "get hooks aeneas --phase unknown -o yaml",
+		golden: "output/get-hooks-unknown.yaml",
+		rels:   []*release.Release{rel},
+	}, {
+		name:      "get hooks with an unknown event",
+		cmd:       "get hooks aeneas --event pre-deploy",
+		golden:    "output/get-hooks-unknown-event.txt",
+		rels:      []*release.Release{rel},
+		wantError: true,
 	}, {
 		name:      "get hooks without args",
 		cmd:       "get hooks",
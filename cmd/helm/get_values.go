@@ -26,15 +26,21 @@ import (
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
 )
 
 var getValuesHelp = `
 This command downloads a values file for a given release.
+
+The values pinned on the release with 'helm values set' are listed after the
+user-supplied values, with the revision and time they were pinned at. They are
+applied over the computed values given with '--all'.
 `
 
 type valuesWriter struct {
 	vals      map[string]interface{}
 	allValues bool
+	overrides []*release.PinnedOverride
 }
 
 func newGetValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -57,7 +63,7 @@ func newGetValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return outfmt.Write(out, &valuesWriter{vals, client.AllValues})
+			return outfmt.Write(out, &valuesWriter{vals, client.AllValues, client.Overrides})
 		},
 	}
 
@@ -86,7 +92,14 @@ func (v valuesWriter) WriteTable(out io.Writer) error {
 	} else {
 		fmt.Fprintln(out, "USER-SUPPLIED VALUES:")
 	}
-	return output.EncodeYAML(out, v.vals)
+	if err := output.EncodeYAML(out, v.vals); err != nil {
+		return err
+	}
+	if len(v.overrides) > 0 {
+		fmt.Fprintln(out, "PINNED OVERRIDES:")
+		return output.EncodeTable(out, overridesTable(v.overrides))
+	}
+	return nil
 }
 
 func (v valuesWriter) WriteJSON(out io.Writer) error {
@@ -23,6 +23,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/yaml"
 
@@ -44,15 +45,11 @@ func init() {
 }
 
 func debug(format string, v ...interface{}) {
-	if settings.Debug {
-		format = fmt.Sprintf("[debug] %s\n", format)
-		log.Output(2, fmt.Sprintf(format, v...))
-	}
+	settings.Logger().Debug(fmt.Sprintf(format, v...))
 }
 
 func warning(format string, v ...interface{}) {
-	format = fmt.Sprintf("WARNING: %s\n", format)
-	fmt.Fprintf(os.Stderr, format, v...)
+	settings.Logger().Warn(fmt.Sprintf(format, v...))
 }
 
 func main() {
@@ -62,6 +59,10 @@ func main() {
 	// manager as picked up by the automated name detection.
 	kube.ManagedFieldsManager = "helm"
 
+	if err := settings.LoadProjectConfig(); err != nil {
+		warning("%s", err)
+	}
+
 	actionConfig := new(action.Configuration)
 	cmd, err := newRootCmd(actionConfig, os.Stdout, os.Args[1:])
 	if err != nil {
@@ -72,6 +73,7 @@ func main() {
 	// run when each command's execute method is called
 	cobra.OnInitialize(func() {
 		helmDriver := os.Getenv("HELM_DRIVER")
+		actionConfig.Logger = settings.Logger()
 		if err := actionConfig.Init(settings.RESTClientGetter(), settings.Namespace(), helmDriver, debug); err != nil {
 			log.Fatal(err)
 		}
@@ -80,11 +82,18 @@ func main() {
 		}
 	})
 
-	if err := cmd.Execute(); err != nil {
+	c, err := cmd.ExecuteC()
+	if cerr := actionConfig.Close(); cerr != nil {
+		debug("%+v", cerr)
+	}
+	if err != nil {
 		debug("%+v", err)
-		switch e := err.(type) {
+		writeErrorOutput(c, os.Stdout, err)
+		switch e := errors.Cause(err).(type) {
 		case pluginError:
 			os.Exit(e.code)
+		case driftError, removedAPIsError:
+			os.Exit(2)
 		default:
 			os.Exit(1)
 		}
@@ -18,24 +18,20 @@ package main
 
 import (
 	"bytes"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"testing"
 
-	shellwords "github.com/mattn/go-shellwords"
 	"github.com/spf13/cobra"
 
-	"github.com/open-hand/helm/internal/test"
 	"github.com/open-hand/helm/pkg/action"
-	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/cli"
-	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/helmtesting/cmdtest"
 	"github.com/open-hand/helm/pkg/release"
 	"github.com/open-hand/helm/pkg/storage"
-	"github.com/open-hand/helm/pkg/storage/driver"
 	"github.com/open-hand/helm/pkg/time"
 )
 
@@ -45,37 +41,33 @@ func init() {
 	action.Timestamper = testTimestamper
 }
 
+// cmdRunner runs the command lines of the tests with the root command of
+// helm.
+var cmdRunner = &cmdtest.Runner{
+	NewRootCmd: newRootCmd,
+	Namespace:  func() string { return settings.Namespace() },
+	Reset:      resetEnv,
+	WriteError: writeErrorOutput,
+}
+
 func runTestCmd(t *testing.T, tests []cmdTestCase) {
 	t.Helper()
-	for _, tt := range tests {
-		for i := 0; i <= tt.repeat; i++ {
-			t.Run(tt.name, func(t *testing.T) {
-				defer resetEnv()()
-
-				storage := storageFixture()
-				for _, rel := range tt.rels {
-					if err := storage.Create(rel); err != nil {
-						t.Fatal(err)
-					}
-				}
-				t.Logf("running cmd (attempt %d): %s", i+1, tt.cmd)
-				_, out, err := executeActionCommandC(storage, tt.cmd)
-				if tt.wantError && err == nil {
-					t.Errorf("expected error, got success with the following output:\n%s", out)
-				}
-				if !tt.wantError && err != nil {
-					t.Errorf("expected no error, got: '%v'", err)
-				}
-				if tt.golden != "" {
-					test.AssertGoldenString(t, out, tt.golden)
-				}
-			})
+	cases := make([]cmdtest.Case, len(tests))
+	for i, tt := range tests {
+		cases[i] = cmdtest.Case{
+			Name:      tt.name,
+			Cmd:       tt.cmd,
+			Golden:    tt.golden,
+			WantError: tt.wantError,
+			Releases:  tt.rels,
+			Repeat:    tt.repeat,
 		}
 	}
+	cmdRunner.Run(t, cases)
 }
 
 func storageFixture() *storage.Storage {
-	return storage.Init(driver.NewMemory())
+	return helmtesting.NewStorage()
 }
 
 func executeActionCommandC(store *storage.Storage, cmd string) (*cobra.Command, string, error) {
@@ -83,45 +75,7 @@ func executeActionCommandC(store *storage.Storage, cmd string) (*cobra.Command,
 }
 
 func executeActionCommandStdinC(store *storage.Storage, in *os.File, cmd string) (*cobra.Command, string, error) {
-	args, err := shellwords.Parse(cmd)
-	if err != nil {
-		return nil, "", err
-	}
-
-	buf := new(bytes.Buffer)
-
-	actionConfig := &action.Configuration{
-		Releases:     store,
-		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
-		Capabilities: chartutil.DefaultCapabilities,
-		Log:          func(format string, v ...interface{}) {},
-	}
-
-	root, err := newRootCmd(actionConfig, buf, args)
-	if err != nil {
-		return nil, "", err
-	}
-
-	root.SetOut(buf)
-	root.SetErr(buf)
-	root.SetArgs(args)
-
-	oldStdin := os.Stdin
-	if in != nil {
-		root.SetIn(in)
-		os.Stdin = in
-	}
-
-	if mem, ok := store.Driver.(*driver.Memory); ok {
-		mem.SetNamespace(settings.Namespace())
-	}
-	c, err := root.ExecuteC()
-
-	result := buf.String()
-
-	os.Stdin = oldStdin
-
-	return c, result, err
+	return cmdRunner.Execute(store, in, cmd)
 }
 
 // cmdTestCase describes a test case that works with releases.
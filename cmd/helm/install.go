@@ -37,6 +37,7 @@ import (
 	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/cli/values"
 	"github.com/open-hand/helm/pkg/downloader"
+	"github.com/open-hand/helm/pkg/engine"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/release"
 )
@@ -106,12 +107,40 @@ supply a version number with the '--version' flag.
 
 To see the list of chart repositories, use 'helm repo list'. To search for
 charts in a repository, use 'helm search'.
+
+With '--preflight', the CPU and memory requested by the pods of the rendered
+workloads are compared with the ResourceQuotas of the namespace and with the
+capacity of the nodes before anything is applied. The install fails with a
+report of the exceeded resources rather than leaving pods pending.
+
+With '--dry-run', '--capabilities-file' renders the chart with the capabilities
+written by 'helm capabilities export' instead of the ones of the cluster.
+
+With '--check-permissions', nothing is installed: the chart is rendered and the
+command lists the permissions the install would need, on the resources, hooks
+and CRDs of the chart and on the release records, that the current identity
+lacks, e.g. "create on deployments.apps in namespace web". It fails if any is
+missing.
+
+NOTES
+
+Charts annotated with 'helm.sh/notes-post-process: "true"' in their Chart.yaml
+have their NOTES.txt rendered a second time once the hooks have run, with the
+delimiters '[[' and ']]'. The notes are then given the results of the hooks as
+.Hooks and the rendered resources by "Kind/name" as .Resources, e.g.
+
+    Password: [[ index .Resources "Secret/db" "data" "password" | b64dec ]]
+
+With '--notes-to', the notes of the deployed release are written as JSON to a
+file, or posted to an http(s) URL.
 `
 
 func newInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewInstall(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", "", 0, "", "", "", false)
 	valueOpts := &values.Options{}
 	var outfmt output.Format
+	var notesTo []string
+	var plan, checkPermissions bool
 
 	cmd := &cobra.Command{
 		Use:   "install [NAME] [CHART]",
@@ -122,40 +151,74 @@ func newInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compInstall(args, toComplete, client)
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			if plan {
+				p, err := planInstall(args, client, valueOpts, out)
+				if err != nil {
+					return errors.Wrap(err, "PLAN FAILED")
+				}
+				return outfmt.Write(out, &releasePlanWriter{p})
+			}
+			if checkPermissions {
+				report, err := checkInstallPermissions(args, client, valueOpts, out)
+				if err != nil {
+					return errors.Wrap(err, "PERMISSION CHECK FAILED")
+				}
+				return writePermissionReport(out, outfmt, report)
+			}
+
 			rel, err := runInstall(args, client, valueOpts, out)
 			if err != nil {
 				return errors.Wrap(err, "INSTALLATION FAILED")
 			}
+			deliverNotes(rel, notesTo)
 
-			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false})
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, nil})
 		},
 	}
 
 	addInstallFlags(cmd, cmd.Flags(), client, valueOpts)
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer)
+	bindPolicyCheckFlag(cmd, &client.PolicyChecker)
+	bindNamespacePolicyFlags(cmd, &cfg.NamespacePolicy)
+	bindRenderLimitsFlags(cmd, &cfg.RenderLimits, engine.Limits{})
+	bindDuplicateResourcesFlag(cmd, &cfg.DuplicateResources)
+	bindDetectFeaturesFlag(cmd, &cfg.DetectFeatures)
+	bindPruneHistoryFlag(cmd, &cfg.Retention)
+	bindPreflightFlag(cmd, &client.Preflight)
+	bindWaitStrategyFlag(cmd, &client.WaitStrategy)
+	bindApplyConcurrencyFlag(cmd, &client.ApplyConcurrency)
+	bindServerSideApplyFlags(cmd, &cfg.ApplyOptions)
+	bindNotesToFlag(cmd, &notesTo)
+	bindPlanFlag(cmd, &plan)
+	bindCheckPermissionsFlag(cmd, &checkPermissions)
+	bindCapabilitiesFileFlag(cmd, &client.Capabilities)
 
 	return cmd
 }
 
 func addInstallFlags(cmd *cobra.Command, f *pflag.FlagSet, client *action.Install, valueOpts *values.Options) {
 	f.BoolVar(&client.CreateNamespace, "create-namespace", false, "create the release namespace if not present")
+	addNamespaceOptionsFlags(f, &client.NamespaceOptions)
 	f.BoolVar(&client.DryRun, "dry-run", false, "simulate an install")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during install")
 	f.BoolVar(&client.Replace, "replace", false, "re-use the given name, only if that name is a deleted release which remains in the history. This is unsafe in production")
+	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "adopt the existing resources owned by other releases or not managed by Helm instead of failing")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.Waves, "waves", false, "apply the resources in the waves of their \"helm.sh/wave\" annotation, each once the resources of the previous one are ready. It will wait for as long as --timeout for each wave")
 	f.BoolVarP(&client.GenerateName, "generate-name", "g", false, "generate the name (and omit the NAME parameter)")
 	f.StringVar(&client.NameTemplate, "name-template", "", "specify template used to name the release")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
-	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to --channel edge")
 	f.BoolVar(&client.DependencyUpdate, "dependency-update", false, "update dependencies if they are missing before installing the chart")
 	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the installation process will not validate rendered templates against the Kubernetes OpenAPI Schema")
 	f.BoolVar(&client.Atomic, "atomic", false, "if set, the installation process deletes the installation on failure. The --wait flag will be set automatically if --atomic is used")
 	f.BoolVar(&client.SkipCRDs, "skip-crds", false, "if set, no CRDs will be installed. By default, CRDs are installed if not already present")
 	f.BoolVar(&client.SubNotes, "render-subchart-notes", false, "if set, render subchart notes along with the parent")
 	addValueOptionsFlags(f, valueOpts)
+	valueOpts.DefaultValueFiles = settings.ValuesFiles()
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 
 	err := cmd.RegisterFlagCompletionFunc("version", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -172,24 +235,81 @@ func addInstallFlags(cmd *cobra.Command, f *pflag.FlagSet, client *action.Instal
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	bindSetFlagsCompletion(cmd, func(args []string) (string, bool) {
+		requiredArgs := 2
+		if client.GenerateName {
+			requiredArgs = 1
+		}
+		if len(args) != requiredArgs {
+			return "", false
+		}
+		return args[requiredArgs-1], true
+	}, &client.Version)
 }
 
 func runInstall(args []string, client *action.Install, valueOpts *values.Options, out io.Writer) (*release.Release, error) {
-	debug("Original chart version: %q", client.Version)
-	if client.Version == "" && client.Devel {
-		debug("setting version to >0.0.0-0")
-		client.Version = ">0.0.0-0"
+	chartRequested, vals, err := loadInstallChart(args, client, valueOpts, out)
+	if err != nil {
+		return nil, err
 	}
 
-	name, chart, err := client.NameAndChart(args)
+	// Create context and prepare the handle of SIGTERM
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Set up channel on which to send signal notifications.
+	// We must use a buffered channel or risk missing the signal
+	// if we're not ready to receive when the signal is sent.
+	cSignal := make(chan os.Signal, 2)
+	signal.Notify(cSignal, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-cSignal
+		fmt.Fprintf(out, "Release %s has been cancelled.\n", args[0])
+		cancel()
+	}()
+
+	rel, err := client.RunWithContext(ctx, chartRequested, vals, "")
+	warnCrossNamespace(client.CrossNamespaceResources)
+	warnDuplicateResources(client.DuplicateResources)
+	debugSkippedResources(client.SkippedResources)
+	return rel, err
+}
+
+// planInstall returns the plan of installing the chart of args.
+func planInstall(args []string, client *action.Install, valueOpts *values.Options, out io.Writer) (*action.ReleasePlan, error) {
+	chartRequested, vals, err := loadInstallChart(args, client, valueOpts, out)
+	if err != nil {
+		return nil, err
+	}
+	return client.Plan(chartRequested, vals)
+}
+
+// checkInstallPermissions returns the permissions installing the chart of
+// args needs.
+func checkInstallPermissions(args []string, client *action.Install, valueOpts *values.Options, out io.Writer) (*action.PermissionReport, error) {
+	chartRequested, vals, err := loadInstallChart(args, client, valueOpts, out)
 	if err != nil {
 		return nil, err
 	}
+	return client.CheckPermissions(chartRequested, vals)
+}
+
+// loadInstallChart locates and loads the chart of args and merges the values
+// to install it with.
+func loadInstallChart(args []string, client *action.Install, valueOpts *values.Options, out io.Writer) (*chart.Chart, map[string]interface{}, error) {
+	debug("Original chart version: %q", client.Version)
+	client.UseDevel(client.Devel)
+
+	name, chart, err := client.NameAndChart(args)
+	if err != nil {
+		return nil, nil, err
+	}
 	client.ReleaseName = name
 
 	cp, err := client.ChartPathOptions.LocateChart(chart, settings)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	debug("CHART PATH: %s\n", cp)
@@ -197,17 +317,17 @@ func runInstall(args []string, client *action.Install, valueOpts *values.Options
 	p := getter.All(settings)
 	vals, err := valueOpts.MergeValues(p)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Check chart dependencies to make sure all are present in /charts
 	chartRequested, err := loader.Load(cp)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err := checkIfInstallable(chartRequested); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if chartRequested.Metadata.Deprecated {
@@ -232,36 +352,20 @@ func runInstall(args []string, client *action.Install, valueOpts *values.Options
 					Debug:            settings.Debug,
 				}
 				if err := man.Update(); err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				// Reload the chart with the updated Chart.lock file.
 				if chartRequested, err = loader.Load(cp); err != nil {
-					return nil, errors.Wrap(err, "failed reloading chart after repo update")
+					return nil, nil, errors.Wrap(err, "failed reloading chart after repo update")
 				}
 			} else {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	}
 
 	client.Namespace = settings.Namespace()
-
-	// Create context and prepare the handle of SIGTERM
-	ctx := context.Background()
-	ctx, cancel := context.WithCancel(ctx)
-
-	// Set up channel on which to send signal notifications.
-	// We must use a buffered channel or risk missing the signal
-	// if we're not ready to receive when the signal is sent.
-	cSignal := make(chan os.Signal, 2)
-	signal.Notify(cSignal, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-cSignal
-		fmt.Fprintf(out, "Release %s has been cancelled.\n", args[0])
-		cancel()
-	}()
-
-	return client.RunWithContext(ctx, chartRequested, vals, "")
+	return chartRequested, vals, nil
 }
 
 // checkIfInstallable validates if a chart can be installed
@@ -17,12 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/repo"
 	"github.com/open-hand/helm/pkg/repo/repotest"
 )
 
@@ -295,9 +308,261 @@ func TestInstallVersionCompletion(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestInstallSetCompletion(t *testing.T) {
+	ch, err := loader.Load("testdata/testcharts/chart-with-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoCache := t.TempDir()
+	archive, err := chartutil.Save(ch, repoCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := repo.NewIndexFile()
+	if err := index.MustAdd(ch.Metadata, filepath.Base(archive), "https://example.com/charts", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.WriteFile(filepath.Join(repoCache, "testing-index.yaml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []cmdTestCase{{
+		name:   "completion for install set flag with a local chart",
+		cmd:    "__complete install releasename testdata/testcharts/chart-with-schema --set ''",
+		golden: "output/set-comp.txt",
+	}, {
+		name:   "completion for install set flag with a cached chart",
+		cmd:    fmt.Sprintf("--repository-cache %s __complete install releasename testing/%s --set ''", repoCache, ch.Name()),
+		golden: "output/set-comp.txt",
+	}, {
+		name:   "completion for install set-string flag after another key",
+		cmd:    "__complete install --generate-name testdata/testcharts/chart-with-schema --set-string firstname=John,employ",
+		golden: "output/set-next-comp.txt",
+	}, {
+		name:   "completion for install set flag value",
+		cmd:    "__complete install releasename testdata/testcharts/chart-with-schema --set firstname=",
+		golden: "output/version-invalid-comp.txt",
+	}, {
+		name:   "completion for install set flag without chart",
+		cmd:    "__complete install releasename --set ''",
+		golden: "output/version-invalid-comp.txt",
+	}, {
+		name:   "completion for install set flag with a chart not cached",
+		cmd:    "__complete install releasename testing/alpine --set ''",
+		golden: "output/version-invalid-comp.txt",
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestInstallFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "install", false)
 	checkFileCompletion(t, "install --generate-name", true)
 	checkFileCompletion(t, "install myname", true)
 	checkFileCompletion(t, "install myname mychart", false)
 }
+
+func TestInstallNamespaceExistsFlag(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "install with an invalid namespace policy",
+		cmd:       "install --create-namespace --namespace-exists replace aeneas testdata/testcharts/empty",
+		golden:    "output/install-invalid-namespace-exists.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestInstallNotesTo(t *testing.T) {
+	defer resetEnv()()
+
+	ch, err := loader.Load("testdata/testcharts/chart-with-post-processed-notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+
+	notesFile := filepath.Join(t.TempDir(), "notes.json")
+	cmd := fmt.Sprintf("install hello chart-with-post-processed-notes --version 0.1.0 --repo %s --notes-to %s", repoURL, notesFile)
+	if _, _, err := executeActionCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(notesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notes action.ReleaseNotes
+	if err := json.Unmarshal(data, &notes); err != nil {
+		t.Fatal(err)
+	}
+	if notes.Release != "hello" || notes.Status != release.StatusDeployed || notes.Chart != "chart-with-post-processed-notes" {
+		t.Errorf("unexpected notes %+v", notes)
+	}
+	expect := "Release hello revision 1.\nHost: hello-db.default\nPassword shown: false\n"
+	if notes.Notes != expect {
+		t.Errorf("expected notes %q, got %q", expect, notes.Notes)
+	}
+}
+
+func TestInstallNamespacePolicy(t *testing.T) {
+	defer resetEnv()()
+
+	ch, err := loader.Load("testdata/testcharts/cross-namespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+	install := fmt.Sprintf("install tenant cross-namespace --version 0.1.0 --repo %s", repoURL)
+
+	_, _, err = executeActionCommand(install + " --namespace-policy block")
+	if !action.IsCrossNamespace(err) {
+		t.Fatalf("expected a cross namespace error, got %v", err)
+	}
+	expect := `resources target other namespaces than the release namespace "default": ConfigMap shared-config/tenant-shared`
+	if !strings.Contains(err.Error(), expect) {
+		t.Errorf("expected %q in the error, got %q", expect, err)
+	}
+
+	for _, flags := range []string{
+		" --namespace-policy block --allow-namespace 'shared-*'",
+		" --namespace-policy warn",
+		"",
+	} {
+		if _, _, err := executeActionCommand(install + flags); err != nil {
+			t.Errorf("%s: %s", flags, err)
+		}
+	}
+
+	if _, _, err := executeActionCommand(install + " --namespace-policy deny"); err == nil || !strings.Contains(err.Error(), "must be one of allow, warn, block") {
+		t.Errorf("expected an invalid namespace policy error, got %v", err)
+	}
+}
+
+func TestInstallNamespacePolicyClusterKinds(t *testing.T) {
+	defer resetEnv()()
+
+	repoURL := newChartServer(t, clusterRoleChart("rbac"))
+	install := fmt.Sprintf("install web rbac --version 0.1.0 --repo %s --namespace-policy block", repoURL)
+	runner := *cmdRunner
+	runner.NewKubeClient = func() kube.Interface { return helmtesting.NewKubeClient() }
+
+	for _, tt := range []struct {
+		flags  string
+		reject bool
+	}{
+		{"", false},
+		{" --deny-cluster-kind ClusterRole", true},
+		{" --deny-cluster-kind '*WebhookConfiguration'", false},
+		{" --allow-cluster-kind ClusterRoleBinding", true},
+		{" --allow-cluster-kind 'Cluster*'", false},
+		{" --allow-cluster-kind 'Cluster*' --deny-cluster-kind ClusterRole", true},
+	} {
+		_, _, err := runner.Execute(storageFixture(), nil, install+tt.flags)
+		if !tt.reject {
+			if err != nil {
+				t.Errorf("%q: %s", tt.flags, err)
+			}
+			continue
+		}
+		expect := "cluster-scoped resources of kinds not allowed: ClusterRole shared"
+		if !action.IsCrossNamespace(err) || !strings.Contains(err.Error(), expect) {
+			t.Errorf("%q: expected %q, got %v", tt.flags, expect, err)
+		}
+	}
+}
+
+func TestInstallDuplicateResources(t *testing.T) {
+	defer resetEnv()()
+
+	ch, err := loader.Load("testdata/testcharts/duplicate-resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+	install := fmt.Sprintf("install dup duplicate-resources --version 0.1.0 --repo %s", repoURL)
+
+	_, _, err = executeActionCommand(install)
+	if !action.IsDuplicateResource(err) {
+		t.Fatalf("expected a duplicate resource error, got %v", err)
+	}
+	expect := "duplicate resources: ConfigMap default/dup-config is rendered by duplicate-resources/templates/config.yaml and duplicate-resources/templates/override.yaml"
+	if !strings.Contains(err.Error(), expect) {
+		t.Errorf("expected %q in the error, got %q", expect, err)
+	}
+
+	if _, _, err := executeActionCommand(install + " --duplicate-resources warn"); err != nil {
+		t.Error(err)
+	}
+
+	if _, _, err := executeActionCommand(install + " --duplicate-resources ignore"); err == nil || !strings.Contains(err.Error(), "must be one of error, warn") {
+		t.Errorf("expected an invalid duplicate resources mode error, got %v", err)
+	}
+}
+
+// clusterRoleChart renders the ClusterRole "shared".
+func clusterRoleChart(name string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/clusterrole.yaml", Data: []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: shared
+`)}},
+	}
+}
+
+// ownedClusterRole returns the ClusterRole "shared" owned by the release
+// in the default namespace.
+func ownedClusterRole(releaseName string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("rbac.authorization.k8s.io/v1")
+	obj.SetKind("ClusterRole")
+	obj.SetName("shared")
+	obj.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "Helm"})
+	obj.SetAnnotations(map[string]string{
+		"meta.helm.sh/release-name":      releaseName,
+		"meta.helm.sh/release-namespace": "default",
+	})
+	return obj
+}
+
+func TestInstallOwnership(t *testing.T) {
+	repoURL := newChartServer(t, clusterRoleChart("rbac"))
+	install := fmt.Sprintf("install web rbac --version 0.1.0 --repo %s", repoURL)
+
+	kc := helmtesting.NewKubeClient()
+	kc.Add(ownedClusterRole("other"))
+	runner := *cmdRunner
+	runner.NewKubeClient = func() kube.Interface { return kc }
+
+	_, _, err := runner.Execute(storageFixture(), nil, install)
+	if !action.IsResourceOwnership(err) {
+		t.Fatalf("expected a resource ownership error, got %v", err)
+	}
+	expect := `ClusterRole shared is owned by release "other" in namespace "default"`
+	if !strings.Contains(err.Error(), expect) {
+		t.Errorf("expected %q in the error, got %q", expect, err)
+	}
+
+	if _, _, err := runner.Execute(storageFixture(), nil, install+" --take-ownership"); err != nil {
+		t.Fatal(err)
+	}
+	obj, _ := kc.Get("rbac.authorization.k8s.io/v1", "ClusterRole", "", "shared")
+	if owner := obj.GetAnnotations()["meta.helm.sh/release-name"]; owner != "web" {
+		t.Errorf("expected the cluster role to be owned by web, got %q", owner)
+	}
+}
+
+// newChartServer serves the packaged charts as a repository, returning its
+// URL.
+func newChartServer(t *testing.T, charts ...*chart.Chart) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, ch := range charts {
+		if _, err := chartutil.Save(ch, filepath.Join(root, "charts")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(root)))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/"
+}
@@ -26,9 +26,12 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
+	"github.com/open-hand/helm/internal/version"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/cli/values"
 	"github.com/open-hand/helm/pkg/getter"
+	"github.com/open-hand/helm/pkg/lint/policy"
 	"github.com/open-hand/helm/pkg/lint/support"
 )
 
@@ -39,11 +42,71 @@ the chart is well-formed.
 If the linter encounters things that will cause the chart to fail installation,
 it will emit [ERROR] messages. If it encounters issues that break with convention
 or recommendation, it will emit [WARNING] messages.
+
+Rule packs can be run over the objects rendered from the chart with the
+'--policy' flag. The 'security' pack checks that containers run as non-root
+users, set resource limits and use pinned images, and that the chart includes
+a NetworkPolicy.
+
+Charts can be linted with several sets of values in one run with the
+'--values-dir' flag. The chart is linted once with each values file in the
+directory, such as the 'ci' directory of the chart, and the messages are
+reported for each values file.
+
+  $ helm lint --values-dir ci ./mychart
+
+Rules can be configured in a .helmlint.yaml file in the chart, or in a file
+given with '--lint-config' that applies to all charts, such as the one of an
+organization. The given file takes precedence over the one of the chart. Rules are
+identified by stable IDs, which are included in the JSON and SARIF output.
+
+  rules:
+    chart/icon:
+      disabled: true
+    security/*:
+      severity: error
+  suppressions:
+    - rule: security/image-tag
+      path: templates/job.yaml
+      reason: the job runs the image built with the chart
+
+The messages can be written as JSON, or in the Static Analysis Results
+Interchange Format (SARIF) consumed by code scanning services, with '--output'.
+
+  $ helm lint --policy security --output sarif ./mychart > lint.sarif
+
+With '--unused-values', the chart is rendered again with each of the given
+values and default values changed, and the values that change nothing are
+reported: the given ones as warnings, which catches misspelled keys such as
+'replicasCount', and the default ones as information.
+
+  $ helm lint --unused-values -f production.yaml ./mychart
 `
 
+// lintOutputFormats are the formats of the output of 'helm lint'.
+var lintOutputFormats = []string{"table", "json", "sarif"}
+
+// lintChartResult is the result of the linting of a chart, as output in JSON.
+type lintChartResult struct {
+	Chart      string                `json:"chart"`
+	Messages   []support.Message     `json:"messages"`
+	Errors     []string              `json:"errors,omitempty"`
+	ValuesSets []lintValuesSetResult `json:"valuesSets,omitempty"`
+}
+
+// lintValuesSetResult is the result of the linting of a chart with a values
+// file, as output in JSON.
+type lintValuesSetResult struct {
+	Values   string            `json:"values"`
+	Messages []support.Message `json:"messages"`
+	Errors   []string          `json:"errors,omitempty"`
+}
+
 func newLintCmd(out io.Writer) *cobra.Command {
 	client := action.NewLint()
 	valueOpts := &values.Options{}
+	var policies []string
+	outfmt := "table"
 
 	cmd := &cobra.Command{
 		Use:   "lint PATH",
@@ -69,6 +132,18 @@ func newLintCmd(out io.Writer) *cobra.Command {
 				}
 			}
 
+			if !isLintOutputFormat(outfmt) {
+				return errors.Errorf("invalid output format %q, must be one of: %s", outfmt, strings.Join(lintOutputFormats, ", "))
+			}
+			client.Analyzers = nil
+			for _, name := range policies {
+				analyzer, err := policy.Get(name)
+				if err != nil {
+					return err
+				}
+				client.Analyzers = append(client.Analyzers, analyzer)
+			}
+
 			client.Namespace = settings.Namespace()
 			vals, err := valueOpts.MergeValues(getter.All(settings))
 			if err != nil {
@@ -78,10 +153,25 @@ func newLintCmd(out io.Writer) *cobra.Command {
 			var message strings.Builder
 			failed := 0
 			errorsOrWarnings := 0
+			var results []lintChartResult
 
 			for _, path := range paths {
 				result := client.Run([]string{path}, vals)
 
+				r := lintChartResult{Chart: path, Messages: result.Messages}
+				if r.Messages == nil {
+					r.Messages = []support.Message{}
+				}
+				r.Errors = errorStrings(result.Errors)
+				for _, set := range result.ValuesSets {
+					vr := lintValuesSetResult{Values: set.Name, Messages: set.Messages, Errors: errorStrings(set.Errors)}
+					if vr.Messages == nil {
+						vr.Messages = []support.Message{}
+					}
+					r.ValuesSets = append(r.ValuesSets, vr)
+				}
+				results = append(results, r)
+
 				// If there is no errors/warnings and quiet flag is set
 				// go to the next chart
 				hasWarningsOrErrors := action.HasWarningsOrErrors(result)
@@ -94,20 +184,13 @@ func newLintCmd(out io.Writer) *cobra.Command {
 
 				fmt.Fprintf(&message, "==> Linting %s\n", path)
 
-				// All the Errors that are generated by a chart
-				// that failed a lint will be included in the
-				// results.Messages so we only need to print
-				// the Errors if there are no Messages.
-				if len(result.Messages) == 0 {
-					for _, err := range result.Errors {
-						fmt.Fprintf(&message, "Error %s\n", err)
-					}
-				}
-
-				for _, msg := range result.Messages {
-					if !client.Quiet || msg.Severity > support.InfoSev {
-						fmt.Fprintf(&message, "%s\n", msg)
+				if len(result.ValuesSets) > 0 {
+					for _, set := range result.ValuesSets {
+						fmt.Fprintf(&message, "--> Values %s\n", set.Name)
+						writeLintMessages(&message, set.Messages, set.Errors, client.Quiet)
 					}
+				} else {
+					writeLintMessages(&message, result.Messages, result.Errors, client.Quiet)
 				}
 
 				if len(result.Errors) != 0 {
@@ -120,12 +203,30 @@ func newLintCmd(out io.Writer) *cobra.Command {
 				fmt.Fprint(&message, "\n")
 			}
 
-			fmt.Fprint(out, message.String())
-
 			summary := fmt.Sprintf("%d chart(s) linted, %d chart(s) failed", len(paths), failed)
+			switch outfmt {
+			case "json":
+				if err := output.EncodeJSON(out, results); err != nil {
+					return err
+				}
+			case "sarif":
+				var charts []support.ChartMessages
+				for _, r := range results {
+					charts = append(charts, support.ChartMessages{Chart: r.Chart, Messages: r.Messages})
+				}
+				if err := support.WriteSARIF(out, version.GetVersion(), charts); err != nil {
+					return err
+				}
+			default:
+				fmt.Fprint(out, message.String())
+			}
+
 			if failed > 0 {
 				return errors.New(summary)
 			}
+			if outfmt != "table" {
+				return nil
+			}
 			if !client.Quiet || errorsOrWarnings > 0 {
 				fmt.Fprintln(out, summary)
 			}
@@ -137,7 +238,55 @@ func newLintCmd(out io.Writer) *cobra.Command {
 	f.BoolVar(&client.Strict, "strict", false, "fail on lint warnings")
 	f.BoolVar(&client.WithSubcharts, "with-subcharts", false, "lint dependent charts")
 	f.BoolVar(&client.Quiet, "quiet", false, "print only warnings and errors")
+	f.BoolVar(&client.UnusedValues, "unused-values", false, "warn about the given values that no template uses, such as misspelled keys, and report the default values that no template uses")
+	f.StringVar(&client.ValuesDir, "values-dir", "", "lint the charts once with each values file in this directory, merged under the values given with --set and --values. A relative directory is looked up in the chart first, e.g. 'ci'")
+	f.StringVar(&client.ConfigFile, "lint-config", "", "apply the lint configuration in this file to all the charts. It takes precedence over the .helmlint.yaml file of a chart")
+	f.StringSliceVar(&policies, "policy", nil, fmt.Sprintf("run the rule packs with the given names over the rendered objects. Allowed values: %s", strings.Join(policy.Names(), ", ")))
+	f.StringVarP(&outfmt, "output", "o", outfmt, fmt.Sprintf("prints the output in the specified format. Allowed values: %s", strings.Join(lintOutputFormats, ", ")))
 	addValueOptionsFlags(f, valueOpts)
+	bindCapabilitiesFileFlag(cmd, &client.Capabilities)
+
+	cmd.RegisterFlagCompletionFunc("policy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return policy.Names(), cobra.ShellCompDirectiveNoFileComp
+	})
+	cmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return lintOutputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
 
 	return cmd
 }
+
+func writeLintMessages(w io.Writer, messages []support.Message, errs []error, quiet bool) {
+	// All the Errors that are generated by a chart
+	// that failed a lint will be included in the
+	// results.Messages so we only need to print
+	// the Errors if there are no Messages.
+	if len(messages) == 0 {
+		for _, err := range errs {
+			fmt.Fprintf(w, "Error %s\n", err)
+		}
+	}
+
+	for _, msg := range messages {
+		if !quiet || msg.Severity > support.InfoSev {
+			fmt.Fprintf(w, "%s\n", msg)
+		}
+	}
+}
+
+func errorStrings(errs []error) []string {
+	var result []string
+	for _, err := range errs {
+		result = append(result, err.Error())
+	}
+	return result
+}
+
+func isLintOutputFormat(format string) bool {
+	for _, f := range lintOutputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
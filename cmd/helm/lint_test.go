@@ -62,3 +62,86 @@ func TestLintFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "lint", true)
 	checkFileCompletion(t, "lint mypath", true) // Multiple paths can be given
 }
+
+func TestLintCmdWithPolicy(t *testing.T) {
+	testChart := "testdata/testcharts/alpine"
+	tests := []cmdTestCase{{
+		name:   "lint chart with the security policy",
+		cmd:    fmt.Sprintf("lint --policy security %s", testChart),
+		golden: "output/lint-policy-security.txt",
+	}, {
+		name:   "lint chart with the security policy in JSON",
+		cmd:    fmt.Sprintf("lint --policy security -o json %s", testChart),
+		golden: "output/lint-policy-security-json.txt",
+	}, {
+		name:   "lint chart with the security policy in SARIF",
+		cmd:    fmt.Sprintf("lint --policy security -o sarif %s", testChart),
+		golden: "output/lint-policy-security-sarif.txt",
+	}, {
+		name:      "lint chart with an unknown policy",
+		cmd:       fmt.Sprintf("lint --policy nope %s", testChart),
+		golden:    "output/lint-policy-unknown.txt",
+		wantError: true,
+	}, {
+		name:      "lint chart with an invalid output format",
+		cmd:       fmt.Sprintf("lint -o xml %s", testChart),
+		golden:    "output/lint-invalid-output.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestLintCmdWithValuesDir(t *testing.T) {
+	testChart := "testdata/testcharts/chart-with-values-dir"
+	tests := []cmdTestCase{{
+		name:      "lint chart with each values file of its ci directory",
+		cmd:       fmt.Sprintf("lint --values-dir ci %s", testChart),
+		golden:    "output/lint-values-dir.txt",
+		wantError: true,
+	}, {
+		name:      "lint chart with each values file of its ci directory in JSON",
+		cmd:       fmt.Sprintf("lint --values-dir ci -o json %s", testChart),
+		golden:    "output/lint-values-dir-json.txt",
+		wantError: true,
+	}, {
+		name:   "lint chart with a values file overriding the ci directory",
+		cmd:    fmt.Sprintf("lint --values-dir ci --set service.port=8080 %s", testChart),
+		golden: "output/lint-values-dir-override.txt",
+	}, {
+		name:      "lint chart with a missing values directory",
+		cmd:       fmt.Sprintf("lint --values-dir nope %s", testChart),
+		golden:    "output/lint-values-dir-missing.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestLintCmdWithLintConfig(t *testing.T) {
+	testChart := "testdata/testcharts/alpine"
+	tests := []cmdTestCase{{
+		name:      "lint chart with a lint configuration",
+		cmd:       fmt.Sprintf("lint --policy security --lint-config testdata/lint/helmlint.yaml %s", testChart),
+		golden:    "output/lint-config.txt",
+		wantError: true,
+	}, {
+		name:      "lint chart with a lint configuration in JSON",
+		cmd:       fmt.Sprintf("lint --policy security --lint-config testdata/lint/helmlint.yaml -o json %s", testChart),
+		golden:    "output/lint-config-json.txt",
+		wantError: true,
+	}, {
+		name:      "lint chart with the lint configuration of the chart",
+		cmd:       "lint testdata/testcharts/chart-with-lint-config",
+		golden:    "output/lint-config-chart.txt",
+		wantError: true,
+	}, {
+		name:   "lint chart with the given lint configuration taking precedence",
+		cmd:    "lint --lint-config testdata/lint/helmlint.yaml testdata/testcharts/chart-with-lint-config",
+		golden: "output/lint-config-precedence.txt",
+	}, {
+		name:      "lint chart with an invalid lint configuration",
+		cmd:       fmt.Sprintf("lint --lint-config testdata/lint/helmlint-invalid.yaml %s", testChart),
+		golden:    "output/lint-config-invalid.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
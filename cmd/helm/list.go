@@ -19,10 +19,13 @@ package main
 import (
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
@@ -56,11 +59,29 @@ By default, up to 256 items may be returned. To limit this, use the '--max' flag
 Setting '--max' to 0 will not return all results. Rather, it will return the
 server's default, which may be much higher than 256. Pairing the '--max'
 flag with the '--offset' flag allows you to page through results.
+
+When more releases are available than were returned, a continue token is
+printed to standard error. Passing it with '--continue' returns the next page.
+Unlike '--offset', a continue token is not affected by releases installed or
+uninstalled between requests:
+
+    $ helm list --max 50 --sort-by updated
+    $ helm list --max 50 --sort-by updated --continue <token>
+
+With '--all-namespaces', namespaces in which you are not allowed to list
+releases are skipped with a warning rather than failing the whole listing.
+
+Use '--sort-by' to order releases by name, updated, chart or status, and
+'--fields' to select the columns to show:
+
+    $ helm list --fields name,status,chart
 `
 
 func newListCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewList(cfg)
 	var outfmt output.Format
+	var sortBy string
+	var fields []string
 
 	cmd := &cobra.Command{
 		Use:               "list",
@@ -76,11 +97,30 @@ func newListCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				}
 			}
 			client.SetStateMask()
+			client.SortBy = action.SortKey(sortBy)
+			if fields != nil {
+				client.Fields = []string{}
+				for _, f := range fields {
+					if !isListColumn(f) {
+						return errors.Errorf("invalid field %q, must be one of %s", f, strings.Join(listColumns, ", "))
+					}
+					if f == "chart" || f == "app_version" {
+						client.Fields = []string{"chart"}
+					}
+				}
+			}
 
-			results, err := client.Run()
+			page, err := client.RunPage()
 			if err != nil {
 				return err
 			}
+			results := page.Releases
+			for _, skipped := range page.Skipped {
+				fmt.Fprintf(cmd.ErrOrStderr(), "WARNING: skipped namespace %q: %s\n", skipped.Namespace, skipped.Err)
+			}
+			if page.Continue != "" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "more releases are available, use --continue %s to list them\n", page.Continue)
+			}
 
 			if client.Short {
 
@@ -104,11 +144,11 @@ func newListCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					}
 					return nil
 				default:
-					return outfmt.Write(out, newReleaseListWriter(results, client.TimeFormat))
+					return outfmt.Write(out, newReleaseListWriter(results, client.TimeFormat, fields))
 				}
 			}
 
-			return outfmt.Write(out, newReleaseListWriter(results, client.TimeFormat))
+			return outfmt.Write(out, newReleaseListWriter(results, client.TimeFormat, fields))
 		},
 	}
 
@@ -127,13 +167,39 @@ func newListCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVarP(&client.AllNamespaces, "all-namespaces", "A", false, "list releases across all namespaces")
 	f.IntVarP(&client.Limit, "max", "m", 256, "maximum number of releases to fetch")
 	f.IntVar(&client.Offset, "offset", 0, "next release index in the list, used to offset from start value")
+	f.StringVar(&client.Continue, "continue", "", "continue token returned by a previous listing, used to fetch the next page")
+	f.StringVar(&sortBy, "sort-by", "", "sort by one of name, updated, chart or status. Overrides --date")
+	f.StringSliceVar(&fields, "fields", nil, fmt.Sprintf("comma-separated list of columns to show, any of %s", strings.Join(listColumns, ", ")))
 	f.StringVarP(&client.Filter, "filter", "f", "", "a regular expression (Perl compatible). Any releases that match the expression will be included in the results")
 	f.StringVarP(&client.Selector, "selector", "l", "", "Selector (label query) to filter on, supports '=', '==', and '!='.(e.g. -l key1=value1,key2=value2). Works only for secret(default) and configmap storage backends.")
 	bindOutputFlag(cmd, &outfmt)
 
+	err := cmd.RegisterFlagCompletionFunc("sort-by", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		keys := make([]string, 0, len(action.SortKeys))
+		for _, k := range action.SortKeys {
+			keys = append(keys, string(k))
+		}
+		return keys, cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	return cmd
 }
 
+// listColumns are the columns of 'helm list' output, in order.
+var listColumns = []string{"name", "namespace", "revision", "updated", "status", "chart", "app_version"}
+
+func isListColumn(name string) bool {
+	for _, c := range listColumns {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
 type releaseElement struct {
 	Name       string `json:"name"`
 	Namespace  string `json:"namespace"`
@@ -146,9 +212,11 @@ type releaseElement struct {
 
 type releaseListWriter struct {
 	releases []releaseElement
+	// columns, if not nil, are the columns to write
+	columns []string
 }
 
-func newReleaseListWriter(releases []*release.Release, timeFormat string) *releaseListWriter {
+func newReleaseListWriter(releases []*release.Release, timeFormat string, columns []string) *releaseListWriter {
 	// Initialize the array so no results returns an empty array instead of null
 	elements := make([]releaseElement, 0, len(releases))
 	for _, r := range releases {
@@ -173,24 +241,77 @@ func newReleaseListWriter(releases []*release.Release, timeFormat string) *relea
 
 		elements = append(elements, element)
 	}
-	return &releaseListWriter{elements}
+	return &releaseListWriter{elements, columns}
 }
 
 func (r *releaseListWriter) WriteTable(out io.Writer) error {
 	table := uitable.New()
-	table.AddRow("NAME", "NAMESPACE", "REVISION", "UPDATED", "STATUS", "CHART", "APP VERSION")
-	for _, r := range r.releases {
-		table.AddRow(r.Name, r.Namespace, r.Revision, r.Updated, r.Status, r.Chart, r.AppVersion)
+	if r.columns == nil {
+		table.AddRow("NAME", "NAMESPACE", "REVISION", "UPDATED", "STATUS", "CHART", "APP VERSION")
+		for _, r := range r.releases {
+			table.AddRow(r.Name, r.Namespace, r.Revision, r.Updated, r.Status, r.Chart, r.AppVersion)
+		}
+		return output.EncodeTable(out, table)
+	}
+
+	header := make([]interface{}, 0, len(r.columns))
+	for _, c := range r.columns {
+		header = append(header, strings.ToUpper(strings.ReplaceAll(c, "_", " ")))
+	}
+	table.AddRow(header...)
+	for _, e := range r.releases {
+		row := make([]interface{}, 0, len(r.columns))
+		for _, c := range r.columns {
+			row = append(row, e.field(c))
+		}
+		table.AddRow(row...)
 	}
 	return output.EncodeTable(out, table)
 }
 
 func (r *releaseListWriter) WriteJSON(out io.Writer) error {
-	return output.EncodeJSON(out, r.releases)
+	return output.EncodeJSON(out, r.elements())
 }
 
 func (r *releaseListWriter) WriteYAML(out io.Writer) error {
-	return output.EncodeYAML(out, r.releases)
+	return output.EncodeYAML(out, r.elements())
+}
+
+// elements returns the releases to encode, restricted to r.columns if set.
+func (r *releaseListWriter) elements() interface{} {
+	if r.columns == nil {
+		return r.releases
+	}
+	elements := make([]map[string]string, 0, len(r.releases))
+	for _, e := range r.releases {
+		m := make(map[string]string, len(r.columns))
+		for _, c := range r.columns {
+			m[c] = e.field(c)
+		}
+		elements = append(elements, m)
+	}
+	return elements
+}
+
+// field returns the value of the named column of the list output.
+func (e releaseElement) field(name string) string {
+	switch name {
+	case "name":
+		return e.Name
+	case "namespace":
+		return e.Namespace
+	case "revision":
+		return e.Revision
+	case "updated":
+		return e.Updated
+	case "status":
+		return e.Status
+	case "chart":
+		return e.Chart
+	case "app_version":
+		return e.AppVersion
+	}
+	return ""
 }
 
 // Returns all releases from 'releases', except those with names matching 'ignoredReleases'
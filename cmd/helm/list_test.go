@@ -223,6 +223,54 @@ func TestListCmd(t *testing.T) {
 		cmd:    "list --uninstalling",
 		golden: "output/list-uninstalling.txt",
 		rels:   releaseFixture,
+	}, {
+		name:   "list releases sorted by status",
+		cmd:    "list --all --sort-by status",
+		golden: "output/list-sort-by-status.txt",
+		rels:   releaseFixture,
+	}, {
+		name:   "list releases sorted by date with --sort-by",
+		cmd:    "list --sort-by updated --reverse",
+		golden: "output/list-date-reversed.txt",
+		rels:   releaseFixture,
+	}, {
+		name:   "list a page of releases",
+		cmd:    "list --max 2",
+		golden: "output/list-page.txt",
+		rels:   releaseFixture,
+	}, {
+		name:   "list the next page of releases",
+		cmd:    "list --max 2 --continue eyJzIjoibmFtZSIsIm4iOiJpZ3VhbmEiLCJucyI6ImRlZmF1bHQifQ",
+		golden: "output/list-continue.txt",
+		rels:   releaseFixture,
+	}, {
+		name:      "list with a continue token for another sort order",
+		cmd:       "list --sort-by status --continue eyJzIjoibmFtZSIsIm4iOiJpZ3VhbmEiLCJucyI6ImRlZmF1bHQifQ",
+		golden:    "output/list-continue-mismatch.txt",
+		rels:      releaseFixture,
+		wantError: true,
+	}, {
+		name:      "list with an invalid sort key",
+		cmd:       "list --sort-by size",
+		golden:    "output/list-sort-by-invalid.txt",
+		rels:      releaseFixture,
+		wantError: true,
+	}, {
+		name:   "list selected fields",
+		cmd:    "list --fields name,status,app_version",
+		golden: "output/list-fields.txt",
+		rels:   releaseFixture,
+	}, {
+		name:   "list selected fields in json",
+		cmd:    "list --fields name,chart --output json",
+		golden: "output/list-fields.json",
+		rels:   releaseFixture,
+	}, {
+		name:      "list an unknown field",
+		cmd:       "list --fields name,size",
+		golden:    "output/list-fields-invalid.txt",
+		rels:      releaseFixture,
+		wantError: true,
 	}, {
 		name:   "list releases in another namespace",
 		cmd:    "list -n milano",
@@ -33,6 +33,8 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/pkg/plugin"
+	"github.com/open-hand/helm/pkg/postrender"
+	"github.com/open-hand/helm/pkg/registry"
 )
 
 const (
@@ -67,6 +69,10 @@ func loadPlugins(baseCmd *cobra.Command, out io.Writer) {
 	for _, plug := range found {
 		plug := plug
 		md := plug.Metadata
+		// Protocol plugins without a command only provide capabilities.
+		if md.Protocol != nil && md.Command == "" && len(md.PlatformCommand) == 0 {
+			continue
+		}
 		if md.Usage == "" {
 			md.Usage = fmt.Sprintf("the %q plugin", md.Name)
 		}
@@ -375,3 +381,31 @@ func pluginDynamicComp(plug *plugin.Plugin, cmd *cobra.Command, args []string, t
 
 	return completions, directive
 }
+
+// pluginCredentialProvider returns the provider of credentials chaining the
+// plugins with the credentials capability, nil if there are none.
+func pluginCredentialProvider() registry.CredentialProvider {
+	if os.Getenv("HELM_NO_PLUGINS") == "1" {
+		return nil
+	}
+	plugins, err := plugin.FindProviders(settings.PluginsDirectory, plugin.CapabilityCredentials)
+	if err != nil || len(plugins) == 0 {
+		return nil
+	}
+	providers := make([]registry.CredentialProvider, 0, len(plugins))
+	for _, p := range plugins {
+		providers = append(providers, plugin.NewCredentialProvider(p, settings))
+	}
+	return registry.ChainCredentialProviders(providers...)
+}
+
+// newPostRenderer returns the post-renderer of the plugin named path if
+// one provides the postrenderer capability, or else the executable at path.
+func newPostRenderer(path string, args ...string) (postrender.PostRenderer, error) {
+	if os.Getenv("HELM_NO_PLUGINS") != "1" {
+		if p, err := plugin.FindProvider(settings.PluginsDirectory, path, plugin.CapabilityPostRenderer); err == nil {
+			return plugin.NewPostRenderer(p, settings, args...), nil
+		}
+	}
+	return postrender.NewExec(path, args...)
+}
@@ -22,14 +22,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/cli/values"
 	"github.com/open-hand/helm/pkg/downloader"
 	"github.com/open-hand/helm/pkg/getter"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 const packageDesc = `
@@ -46,11 +50,33 @@ provide '--keyring path/to/secret/keys' and '--key keyname'.
 
 If '--keyring' is not specified, Helm usually defaults to the public keyring
 unless your environment is otherwise configured.
+
+To sign with a key held by gpg-agent, such as a key on a smartcard, use the
+'--gpg-agent' flag. The gpg command signs the chart, so the private key is never
+read by Helm, and '--keyring' is not required.
+
+  $ helm package --sign --gpg-agent --key mykey ./mychart
+
+To generate a software bill of materials (SBOM) of the chart, use the '--sbom'
+flag with the format of the SBOM. It is written next to the chart archive and
+attached to the chart when it is pushed to an OCI registry.
+
+  $ helm package --sbom spdx ./mychart
+
+To package all the charts of a directory, use the '--recursive' flag. The charts
+are packaged after the charts they depend on through a 'file://' repository, so
+that '--dependency-update' bundles up to date archives of them. The
+'--index-url' flag regenerates the index of the destination afterwards, and
+'--output json' prints a summary of the packaged charts.
+
+  $ helm package --recursive ./charts/... -d ./repo --index-url https://example.com/charts
 `
 
 func newPackageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewPackage()
 	valueOpts := &values.Options{}
+	var recursive bool
+	var outfmt output.Format
 
 	cmd := &cobra.Command{
 		Use:   "package [CHART_PATH] [...]",
@@ -64,7 +90,7 @@ func newPackageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				if client.Key == "" {
 					return errors.New("--key is required for signing a package")
 				}
-				if client.Keyring == "" {
+				if client.Keyring == "" && !client.GPGAgent {
 					return errors.New("--keyring is required for signing a package")
 				}
 			}
@@ -76,6 +102,35 @@ func newPackageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				return err
 			}
 
+			client.DependencyUpdater = func(path string) error {
+				downloadManager := &downloader.Manager{
+					Out:              ioutil.Discard,
+					ChartPath:        path,
+					Keyring:          client.Keyring,
+					Getters:          p,
+					Debug:            settings.Debug,
+					RegistryClient:   cfg.RegistryClient,
+					RepositoryConfig: settings.RepositoryConfig,
+					RepositoryCache:  settings.RepositoryCache,
+				}
+				return downloadManager.Update()
+			}
+
+			if recursive {
+				var packaged []action.PackagedChart
+				for _, arg := range args {
+					charts, err := client.RunRecursive(strings.TrimSuffix(arg, "..."), vals)
+					packaged = append(packaged, charts...)
+					if err != nil {
+						return err
+					}
+				}
+				return outfmt.Write(out, &packageWriter{packaged})
+			}
+			if client.IndexURL != "" {
+				return errors.New("--index-url requires --recursive")
+			}
+
 			for i := 0; i < len(args); i++ {
 				path, err := filepath.Abs(args[i])
 				if err != nil {
@@ -86,18 +141,7 @@ func newPackageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				}
 
 				if client.DependencyUpdate {
-					downloadManager := &downloader.Manager{
-						Out:              ioutil.Discard,
-						ChartPath:        path,
-						Keyring:          client.Keyring,
-						Getters:          p,
-						Debug:            settings.Debug,
-						RegistryClient:   cfg.RegistryClient,
-						RepositoryConfig: settings.RepositoryConfig,
-						RepositoryCache:  settings.RepositoryCache,
-					}
-
-					if err := downloadManager.Update(); err != nil {
+					if err := client.DependencyUpdater(path); err != nil {
 						return err
 					}
 				}
@@ -115,11 +159,36 @@ func newPackageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.Sign, "sign", false, "use a PGP private key to sign this package")
 	f.StringVar(&client.Key, "key", "", "name of the key to use when signing. Used if --sign is true")
 	f.StringVar(&client.Keyring, "keyring", defaultKeyring(), "location of a public keyring")
+	f.BoolVar(&client.GPGAgent, "gpg-agent", false, "sign with the gpg command, which leaves the private key to gpg-agent. Used if --sign is true")
 	f.StringVar(&client.PassphraseFile, "passphrase-file", "", `location of a file which contains the passphrase for the signing key. Use "-" in order to read from stdin.`)
 	f.StringVar(&client.Version, "version", "", "set the version on the chart to this semver version")
 	f.StringVar(&client.AppVersion, "app-version", "", "set the appVersion on the chart to this version")
 	f.StringVarP(&client.Destination, "destination", "d", ".", "location to write the chart.")
 	f.BoolVarP(&client.DependencyUpdate, "dependency-update", "u", false, `update dependencies from "Chart.yaml" to dir "charts/" before packaging`)
+	f.BoolVar(&client.ValuesDocs, "values-docs", false, fmt.Sprintf("add the reference of the values of the chart to the package as its %s file, see 'helm chart docs'", chartutil.ValuesDocsFileName))
+	f.BoolVarP(&recursive, "recursive", "r", false, "package all the charts under the given directories, each after the charts it depends on through a file:// repository")
+	f.StringVar(&client.IndexURL, "index-url", "", "regenerate the index of the destination, a repository served at this URL, after packaging the charts. Used if --recursive is true")
+	f.StringVar(&client.SBOM, "sbom", "", fmt.Sprintf("generate a software bill of materials of the chart in this format next to the package. Allowed values: %s", strings.Join(sbom.Formats(), ", ")))
+	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
 }
+
+type packageWriter struct {
+	charts []action.PackagedChart
+}
+
+func (w *packageWriter) WriteTable(out io.Writer) error {
+	for _, c := range w.charts {
+		fmt.Fprintf(out, "Successfully packaged chart and saved it to: %s\n", c.Archive)
+	}
+	return nil
+}
+
+func (w *packageWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.charts)
+}
+
+func (w *packageWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.charts)
+}
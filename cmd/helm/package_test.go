@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,9 +24,13 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/open-hand/helm/internal/test"
 	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/repo"
 )
 
 func TestPackage(t *testing.T) {
@@ -91,6 +96,20 @@ func TestPackage(t *testing.T) {
 			expect:  "",
 			hasfile: "alpine-0.1.0.tgz",
 		},
+		{
+			name:    "package --sbom=spdx testdata/testcharts/alpine",
+			args:    []string{"testdata/testcharts/alpine"},
+			flags:   map[string]string{"sbom": "spdx"},
+			expect:  "",
+			hasfile: "alpine-0.1.0.tgz",
+		},
+		{
+			name:   "package --sbom=swid testdata/testcharts/alpine",
+			args:   []string{"testdata/testcharts/alpine"},
+			flags:  map[string]string{"sbom": "swid"},
+			expect: "invalid SBOM format",
+			err:    true,
+		},
 		{
 			name:    "package testdata/testcharts/chart-missing-deps",
 			args:    []string{"testdata/testcharts/chart-missing-deps"},
@@ -162,6 +181,14 @@ func TestPackage(t *testing.T) {
 					t.Errorf("%q: provenance file is empty", tt.name)
 				}
 			}
+
+			if _, ok := tt.flags["sbom"]; ok {
+				if fi, err := os.Stat(tt.hasfile + ".sbom.json"); err != nil {
+					t.Errorf("%q: expected SBOM file", tt.name)
+				} else if fi.Size() == 0 {
+					t.Errorf("%q: SBOM file is empty", tt.name)
+				}
+			}
 		})
 	}
 }
@@ -192,6 +219,104 @@ func TestSetAppVersion(t *testing.T) {
 	}
 }
 
+func TestPackageValuesDocs(t *testing.T) {
+	dir := ensure.TempDir(t)
+	cmd := fmt.Sprintf("package testdata/testcharts/chart-with-commented-values --destination=%s --values-docs", dir)
+	if _, output, err := executeActionCommand(cmd); err != nil {
+		t.Logf("Output: %s", output)
+		t.Fatal(err)
+	}
+	ch, err := loader.Load(filepath.Join(dir, "chart-with-commented-values-0.1.0.tgz"))
+	if err != nil {
+		t.Fatalf("unexpected error loading packaged chart: %v", err)
+	}
+	for _, f := range ch.Files {
+		if f.Name == chartutil.ValuesDocsFileName {
+			test.AssertGoldenString(t, string(f.Data), "output/chart-docs.md")
+			return
+		}
+	}
+	t.Errorf("expected the package to contain %s", chartutil.ValuesDocsFileName)
+}
+
+// saveMonorepoChart saves a chart depending on the given local charts in the
+// charts directory of root.
+func saveMonorepoChart(t *testing.T, root, name string, deps ...string) {
+	t.Helper()
+	md := &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "0.1.0"}
+	for _, dep := range deps {
+		md.Dependencies = append(md.Dependencies, &chart.Dependency{Name: dep, Version: "0.1.0", Repository: "file://../" + dep})
+	}
+	if err := chartutil.SaveDir(&chart.Chart{Metadata: md}, filepath.Join(root, "charts")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackageRecursive(t *testing.T) {
+	root := t.TempDir()
+	saveMonorepoChart(t, root, "app", "common", "db")
+	saveMonorepoChart(t, root, "db", "common")
+	saveMonorepoChart(t, root, "common")
+	dest := t.TempDir()
+
+	cmd := fmt.Sprintf("package --recursive %s/charts/... -d %s --dependency-update --index-url https://example.com/charts -o json", root, dest)
+	_, out, err := executeActionCommand(cmd)
+	if err != nil {
+		t.Logf("Output: %s", out)
+		t.Fatal(err)
+	}
+	var packaged []action.PackagedChart
+	if err := json.Unmarshal([]byte(out), &packaged); err != nil {
+		t.Fatalf("unable to decode the summary %q: %s", out, err)
+	}
+	var names []string
+	for _, c := range packaged {
+		names = append(names, c.Name)
+		if c.Archive != filepath.Join(dest, c.Name+"-0.1.0.tgz") {
+			t.Errorf("unexpected archive %s of %s", c.Archive, c.Name)
+		}
+	}
+	if strings.Join(names, ",") != "common,db,app" {
+		t.Errorf("expected the charts to be packaged after their dependencies, got %v", names)
+	}
+	if len(packaged) == 3 && len(packaged[2].Dependencies) != 2 {
+		t.Errorf("expected app to depend on 2 local charts, got %v", packaged[2].Dependencies)
+	}
+
+	ch, err := loader.Load(filepath.Join(dest, "app-0.1.0.tgz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.Dependencies()) != 2 {
+		t.Errorf("expected the archive of app to bundle its 2 dependencies, got %d", len(ch.Dependencies()))
+	}
+	index, err := repo.LoadIndexFile(filepath.Join(dest, "index.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if !index.Has(name, "0.1.0") {
+			t.Errorf("expected %s in the index", name)
+		}
+	}
+}
+
+func TestPackageRecursiveErrors(t *testing.T) {
+	root := t.TempDir()
+	saveMonorepoChart(t, root, "a", "b")
+	saveMonorepoChart(t, root, "b", "a")
+
+	if _, _, err := executeActionCommand(fmt.Sprintf("package --recursive %s -d %s", root, t.TempDir())); err == nil || !strings.Contains(err.Error(), "circular dependency") {
+		t.Errorf("expected a circular dependency error, got %v", err)
+	}
+	if _, _, err := executeActionCommand(fmt.Sprintf("package --recursive %s -d %s", t.TempDir(), t.TempDir())); err == nil {
+		t.Error("expected an error without charts")
+	}
+	if _, _, err := executeActionCommand("package testdata/testcharts/alpine --index-url https://example.com/charts"); err == nil {
+		t.Error("expected --index-url to require --recursive")
+	}
+}
+
 func TestPackageFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "package", true)
 	checkFileCompletion(t, "package mypath", true) // Multiple paths can be given
@@ -0,0 +1,78 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+func bindCheckPermissionsFlag(cmd *cobra.Command, varRef *bool) {
+	cmd.Flags().BoolVar(varRef, "check-permissions", false, "print the permissions the operation needs that the current identity lacks, checked with SelfSubjectAccessReviews, without running it")
+}
+
+// writePermissionReport writes the report, failing if permissions are
+// missing.
+func writePermissionReport(out io.Writer, outfmt output.Format, report *action.PermissionReport) error {
+	var err error
+	if report.Failed() {
+		err = errors.Errorf("%d of the %d permissions the %s needs are missing", len(report.Missing), len(report.Required), report.Operation)
+	}
+	return writeResult(out, outfmt, &permissionReportWriter{report}, err)
+}
+
+type permissionReportWriter struct {
+	report *action.PermissionReport
+}
+
+func (w *permissionReportWriter) WriteTable(out io.Writer) error {
+	r := w.report
+	fmt.Fprintf(out, "RELEASE: %s\n", r.Release)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", r.Namespace)
+	fmt.Fprintf(out, "OPERATION: %s\n", r.Operation)
+	if !r.Failed() {
+		fmt.Fprintf(out, "PERMISSIONS: all the %d required permissions are granted\n", len(r.Required))
+		return nil
+	}
+	fmt.Fprintln(out, "MISSING PERMISSIONS:")
+	tbl := uitable.New()
+	tbl.AddRow("VERB", "RESOURCE", "NAMESPACE", "FOR")
+	for _, p := range r.Missing {
+		namespace := p.Namespace
+		if namespace == "" {
+			namespace = "(cluster)"
+		}
+		tbl.AddRow(p.Verb, p.GroupResource(), namespace, strings.Join(p.For, ", "))
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *permissionReportWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.report)
+}
+
+func (w *permissionReportWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.report)
+}
@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/helmtesting/cmdtest"
+	"github.com/open-hand/helm/pkg/kube"
+	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// accessKubeClient is a fake Kubernetes client whose identity is denied the
+// permissions of denied.
+type accessKubeClient struct {
+	kube.Interface
+	denied map[kube.Permission]bool
+}
+
+func (c *accessKubeClient) ReviewAccess(perms []kube.Permission) ([]kube.Permission, error) {
+	var denied []kube.Permission
+	for _, p := range perms {
+		if c.denied[p] {
+			denied = append(denied, p)
+		}
+	}
+	return denied, nil
+}
+
+// staticRESTClientGetter maps the kinds of the built-in APIs to their
+// resources, without a cluster.
+type staticRESTClientGetter struct{}
+
+func (staticRESTClientGetter) ToRESTConfig() (*rest.Config, error) { return &rest.Config{}, nil }
+
+func (staticRESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return nil, fmt.Errorf("no discovery without a cluster")
+}
+
+func (staticRESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	return testrestmapper.TestOnlyStaticRESTMapper(scheme.Scheme), nil
+}
+
+func TestCheckPermissions(t *testing.T) {
+	permissionsChart := func(version, templates string) *chart.Chart {
+		return &chart.Chart{
+			Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: version},
+			Templates: []*chart.File{
+				{Name: "templates/resources.yaml", Data: []byte(templates)},
+				{Name: "templates/hook.yaml", Data: []byte(planHook)},
+			},
+		}
+	}
+	repoURL := newChartServer(t,
+		permissionsChart("0.2.0", planConfigMap+"---\n"+permissionsClusterRole),
+	)
+	rels := []*release.Release{{
+		Name:      "web",
+		Namespace: "default",
+		Version:   1,
+		Chart:     permissionsChart("0.1.0", ""),
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Manifest:  planConfigMapV1 + "---\n" + planService,
+	}}
+
+	runner := *cmdRunner
+	runner.NewKubeClient = func() kube.Interface {
+		return &accessKubeClient{
+			Interface: &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			denied: map[kube.Permission]bool{
+				{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Verb: "create"}: true,
+				{Resource: "services", Namespace: "default", Verb: "delete"}:                   true,
+				{Group: "batch", Resource: "jobs", Namespace: "default", Verb: "delete"}:       true,
+			},
+		}
+	}
+	runner.Configure = func(cfg *action.Configuration) {
+		cfg.RESTClientGetter = staticRESTClientGetter{}
+	}
+
+	runner.Run(t, []cmdtest.Case{{
+		Name:      "check the permissions of an install",
+		Cmd:       fmt.Sprintf("install api web --version 0.2.0 --repo %s --check-permissions", repoURL),
+		Golden:    "output/install-check-permissions.txt",
+		WantError: true,
+	}, {
+		Name:   "check the permissions of an install without hooks",
+		Cmd:    fmt.Sprintf("install api web --version 0.2.0 --repo %s --check-permissions --no-hooks -o json", repoURL),
+		Golden: "output/install-check-permissions.json",
+		// The ClusterRole still cannot be created.
+		WantError: true,
+	}, {
+		Name:      "check the permissions of an upgrade",
+		Cmd:       fmt.Sprintf("upgrade web web --version 0.2.0 --repo %s --check-permissions -o json", repoURL),
+		Golden:    "output/upgrade-check-permissions.json",
+		WantError: true,
+		Releases:  rels,
+	}})
+}
+
+const permissionsClusterRole = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+`
@@ -0,0 +1,92 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+func bindPlanFlag(cmd *cobra.Command, varRef *bool) {
+	cmd.Flags().BoolVar(varRef, "plan", false, "print the resources, hooks and CRDs the operation would add, change or delete without running it")
+}
+
+type releasePlanWriter struct {
+	plan *action.ReleasePlan
+}
+
+func (w *releasePlanWriter) WriteTable(out io.Writer) error {
+	p := w.plan
+	fmt.Fprintf(out, "RELEASE: %s\n", p.Release)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", p.Namespace)
+	if p.Operation == action.PlanUpgrade {
+		fmt.Fprintf(out, "OPERATION: %s (revision %d -> %d)\n", p.Operation, p.CurrentRevision, p.Revision)
+		fmt.Fprintf(out, "CHART: %s -> %s\n", p.CurrentChart, p.Chart)
+	} else {
+		fmt.Fprintf(out, "OPERATION: %s (revision %d)\n", p.Operation, p.Revision)
+		fmt.Fprintf(out, "CHART: %s\n", p.Chart)
+	}
+
+	if !p.Changed() {
+		fmt.Fprintln(out, "RESOURCES: no change")
+	} else {
+		fmt.Fprintln(out, "RESOURCES:")
+		tbl := uitable.New()
+		tbl.AddRow("CHANGE", "KIND", "NAMESPACE", "NAME")
+		for _, r := range p.Resources {
+			tbl.AddRow(r.Change, r.Kind, r.Namespace, r.Name)
+		}
+		if err := output.EncodeTable(out, tbl); err != nil {
+			return err
+		}
+	}
+
+	if len(p.Hooks) > 0 {
+		fmt.Fprintln(out, "HOOKS:")
+		tbl := uitable.New()
+		tbl.AddRow("EVENT", "KIND", "NAME", "WEIGHT")
+		for _, h := range p.Hooks {
+			tbl.AddRow(strings.Join(h.Events, ","), h.Kind, h.Name, h.Weight)
+		}
+		if err := output.EncodeTable(out, tbl); err != nil {
+			return err
+		}
+	}
+
+	if len(p.CRDs) > 0 {
+		fmt.Fprintln(out, "CRDS:")
+		for _, crd := range p.CRDs {
+			fmt.Fprintf(out, "  %s\n", crd)
+		}
+	}
+	return nil
+}
+
+func (w *releasePlanWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.plan)
+}
+
+func (w *releasePlanWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.plan)
+}
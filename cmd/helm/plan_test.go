@@ -0,0 +1,109 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestUpgradePlan(t *testing.T) {
+	planChart := func(version, templates string) *chart.Chart {
+		return &chart.Chart{
+			Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: version},
+			Templates: []*chart.File{
+				{Name: "templates/resources.yaml", Data: []byte(templates)},
+				{Name: "templates/hook.yaml", Data: []byte(planHook)},
+			},
+		}
+	}
+	current := planChart("0.1.0", "")
+	repoURL := newChartServer(t,
+		planChart("0.2.0", planConfigMap+"---\n"+planSecret),
+	)
+
+	rels := []*release.Release{{
+		Name:      "web",
+		Namespace: "default",
+		Version:   1,
+		Chart:     current,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Manifest:  planConfigMapV1 + "---\n" + planService,
+	}}
+
+	tests := []cmdTestCase{{
+		name:   "plan an upgrade",
+		cmd:    fmt.Sprintf("upgrade web web --version 0.2.0 --repo %s --plan", repoURL),
+		golden: "output/upgrade-plan.txt",
+		rels:   rels,
+	}, {
+		name:   "plan an install with upgrade --install",
+		cmd:    fmt.Sprintf("upgrade api web --install --version 0.2.0 --repo %s --plan -o json", repoURL),
+		golden: "output/upgrade-install-plan.json",
+		rels:   rels,
+	}}
+	runTestCmd(t, tests)
+}
+
+const planHook = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: migrate:1.0
+      restartPolicy: Never
+`
+
+const planConfigMapV1 = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  replicas: "1"
+`
+
+const planConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  replicas: "2"
+`
+
+const planSecret = `apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+`
+
+const planService = `apiVersion: v1
+kind: Service
+metadata:
+  name: web-legacy
+spec:
+  ports:
+  - port: 80
+`
@@ -18,6 +18,7 @@ package main
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -25,15 +26,28 @@ import (
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/plugin"
 	"github.com/open-hand/helm/pkg/plugin/installer"
+	"github.com/open-hand/helm/pkg/provenance"
 )
 
 type pluginInstallOptions struct {
-	source  string
-	version string
+	source       string
+	version      string
+	fromLock     string
+	verifyCosign bool
+	cosign       provenance.CosignOptions
 }
 
 const pluginInstallDesc = `
-This command allows you to install a plugin from a url to a VCS repo or a local path.
+This command allows you to install a plugin from a url to a VCS repo, a local
+path, or an OCI registry, as in 'oci://registry.example.com/plugins/diff:3.1.0'.
+
+The digest of a plugin installed from an OCI registry can be pinned by ending
+its reference with '@sha256:<digest>', and its cosign signature verified with
+--verify-cosign.
+
+The installed plugins are recorded in the lock file plugins.lock of the plugins
+directory. With --from-lock, the plugins of a lock file are installed at the
+versions and digests it records.
 `
 
 func newPluginInstallCmd(out io.Writer) *cobra.Command {
@@ -43,7 +57,7 @@ func newPluginInstallCmd(out io.Writer) *cobra.Command {
 		Short:   "install one or more Helm plugins",
 		Long:    pluginInstallDesc,
 		Aliases: []string{"add"},
-		Args:    require.ExactArgs(1),
+		Args:    require.MaximumNArgs(1),
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) == 0 {
 				// We do file completion, in case the plugin is local
@@ -59,22 +73,95 @@ func newPluginInstallCmd(out io.Writer) *cobra.Command {
 			return o.run(out)
 		},
 	}
-	cmd.Flags().StringVar(&o.version, "version", "", "specify a version constraint. If this is not specified, the latest version is installed")
+	f := cmd.Flags()
+	f.StringVar(&o.version, "version", "", "specify a version constraint. If this is not specified, the latest version is installed")
+	f.StringVar(&o.fromLock, "from-lock", "", "install the plugins recorded in a plugin lock file")
+	f.BoolVar(&o.verifyCosign, "verify-cosign", false, "verify the cosign signature of plugins installed from OCI registries")
+	f.StringVar(&o.cosign.KeyFile, "cosign-key", "", "public key to verify cosign signatures with")
+	f.StringVar(&o.cosign.Identity, "cosign-identity", "", "regular expression the identity of keyless cosign signers must match")
+	f.StringVar(&o.cosign.Issuer, "cosign-oidc-issuer", "", "OIDC issuer that must have authenticated keyless cosign signers")
+	f.StringVar(&o.cosign.RootsFile, "cosign-roots", "", "root certificates of the authority issuing keyless signing certificates")
+	f.StringVar(&o.cosign.RekorKeyFile, "cosign-rekor-key", "", "public key of the transparency log keyless cosign signatures are recorded in")
 	return cmd
 }
 
 func (o *pluginInstallOptions) complete(args []string) error {
-	o.source = args[0]
+	switch {
+	case o.fromLock != "" && len(args) > 0:
+		return errors.New("a plugin source cannot be given with --from-lock")
+	case o.fromLock == "" && len(args) == 0:
+		return errors.New("\"helm plugin install\" requires a plugin source or --from-lock")
+	case len(args) > 0:
+		o.source = args[0]
+	}
 	return nil
 }
 
 func (o *pluginInstallOptions) run(out io.Writer) error {
 	installer.Debug = settings.Debug
 
-	i, err := installer.NewForSource(o.source, o.version)
+	var verifier provenance.SignatureVerifier
+	if o.verifyCosign {
+		v, err := provenance.NewCosignVerifier(o.cosign)
+		if err != nil {
+			return err
+		}
+		verifier = v
+	}
+
+	lockPath := installer.LockPath(settings.PluginsDirectory)
+	lock, err := installer.LoadLock(lockPath)
 	if err != nil {
 		return err
 	}
+
+	if o.fromLock == "" {
+		if err := o.install(out, lock, o.source, o.version, verifier); err != nil {
+			return err
+		}
+		return lock.Save(lockPath)
+	}
+
+	locked, err := installer.LoadLock(o.fromLock)
+	if err != nil {
+		return err
+	}
+	installed, err := plugin.FindPlugins(settings.PluginsDirectory)
+	if err != nil {
+		return err
+	}
+	for _, l := range locked.Plugins {
+		if p := findPlugin(installed, l.Name); p != nil {
+			if p.Metadata.Version != l.Version {
+				return errors.Errorf("plugin %s %s is installed, the lock file requires version %s", l.Name, p.Metadata.Version, l.Version)
+			}
+			fmt.Fprintf(out, "Plugin %s %s is already installed\n", l.Name, l.Version)
+			continue
+		}
+		version := ""
+		if l.Digest == "" {
+			version = l.Version
+		}
+		if err := o.install(out, lock, l.PinnedSource(), version, verifier); err != nil {
+			return errors.Wrapf(err, "failed to install plugin %s", l.Name)
+		}
+	}
+	return lock.Save(lockPath)
+}
+
+// install installs a plugin and records it in lock.
+func (o *pluginInstallOptions) install(out io.Writer, lock *installer.Lock, source, version string, verifier provenance.SignatureVerifier) error {
+	i, err := installer.NewForSource(source, version)
+	if err != nil {
+		return err
+	}
+	oci, isOCI := i.(*installer.OCIInstaller)
+	if verifier != nil {
+		if !isOCI {
+			return errors.New("signatures can only be verified for plugins installed from OCI registries")
+		}
+		oci.Verifier = verifier
+	}
 	if err := installer.Install(i); err != nil {
 		return err
 	}
@@ -89,6 +176,17 @@ func (o *pluginInstallOptions) run(out io.Writer) error {
 		return err
 	}
 
+	locked := &installer.LockedPlugin{
+		Name:    p.Metadata.Name,
+		Version: p.Metadata.Version,
+		Source:  source,
+	}
+	if isOCI {
+		locked.Source = strings.SplitN(source, "@", 2)[0]
+		locked.Digest = oci.Digest
+	}
+	lock.Set(locked)
+
 	fmt.Fprintf(out, "Installed plugin: %s\n", p.Metadata.Name)
 	return nil
 }
@@ -22,10 +22,12 @@ import (
 	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/plugin"
 )
 
 func newPluginListCmd(out io.Writer) *cobra.Command {
+	var outfmt output.Format
 	cmd := &cobra.Command{
 		Use:               "list",
 		Aliases:           []string{"ls"},
@@ -38,18 +40,50 @@ func newPluginListCmd(out io.Writer) *cobra.Command {
 				return err
 			}
 
-			table := uitable.New()
-			table.AddRow("NAME", "VERSION", "DESCRIPTION")
-			for _, p := range plugins {
-				table.AddRow(p.Metadata.Name, p.Metadata.Version, p.Metadata.Description)
-			}
-			fmt.Fprintln(out, table)
-			return nil
+			return outfmt.Write(out, &pluginListWriter{plugins})
 		},
 	}
+	bindOutputFlag(cmd, &outfmt)
 	return cmd
 }
 
+type pluginElement struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type pluginListWriter struct {
+	plugins []*plugin.Plugin
+}
+
+func (w *pluginListWriter) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("NAME", "VERSION", "DESCRIPTION")
+	for _, p := range w.plugins {
+		table.AddRow(p.Metadata.Name, p.Metadata.Version, p.Metadata.Description)
+	}
+	_, err := fmt.Fprintln(out, table)
+	return err
+}
+
+func (w *pluginListWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.elements())
+}
+
+func (w *pluginListWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.elements())
+}
+
+func (w *pluginListWriter) elements() []pluginElement {
+	// Initialize the array so no results returns an empty array instead of null
+	elements := make([]pluginElement, 0, len(w.plugins))
+	for _, p := range w.plugins {
+		elements = append(elements, pluginElement{Name: p.Metadata.Name, Version: p.Metadata.Version, Description: p.Metadata.Description})
+	}
+	return elements
+}
+
 // Returns all plugins from plugins, except those with names matching ignoredPluginNames
 func filterPlugins(plugins []*plugin.Plugin, ignoredPluginNames []string) []*plugin.Plugin {
 	// if ignoredPluginNames is nil, just return plugins
@@ -18,6 +18,7 @@ package main
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	"github.com/open-hand/helm/pkg/plugin/installer"
 	"github.com/open-hand/helm/pkg/release"
 )
 
@@ -376,3 +378,50 @@ func TestPluginUpdateFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "plugin update", false)
 	checkFileCompletion(t, "plugin update myplugin", false)
 }
+
+func TestPluginInstallLock(t *testing.T) {
+	defer resetEnv()()
+	os.Setenv("HELM_PLUGINS", t.TempDir())
+	settings.PluginsDirectory = os.Getenv("HELM_PLUGINS")
+	source, err := filepath.Abs("testdata/helmhome/helm/plugins/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockPath := filepath.Join(settings.PluginsDirectory, installer.LockFileName)
+
+	if _, _, err := executeActionCommand("plugin install"); err == nil {
+		t.Error("expected an error installing without a source")
+	}
+	if _, _, err := executeActionCommand("plugin install " + source); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := installer.LoadLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Plugins) != 1 || lock.Plugins[0].Name != "echo" || lock.Plugins[0].Source != source {
+		t.Fatalf("unexpected locked plugins %v", lock.Plugins)
+	}
+	saved := filepath.Join(t.TempDir(), installer.LockFileName)
+	if err := lock.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeActionCommand("plugin uninstall echo"); err != nil {
+		t.Fatal(err)
+	}
+	if lock, _ = installer.LoadLock(lockPath); len(lock.Plugins) != 0 {
+		t.Fatalf("expected the uninstalled plugin to be removed from the lock file, got %v", lock.Plugins)
+	}
+
+	_, out, err := executeActionCommand("plugin install --from-lock " + saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Installed plugin: echo\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(settings.PluginsDirectory, "echo", "plugin.yaml")); err != nil {
+		t.Error(err)
+	}
+}
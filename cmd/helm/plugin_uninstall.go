@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/pkg/plugin"
+	"github.com/open-hand/helm/pkg/plugin/installer"
 )
 
 type pluginUninstallOptions struct {
@@ -65,18 +66,33 @@ func (o *pluginUninstallOptions) run(out io.Writer) error {
 	if err != nil {
 		return err
 	}
+	lockPath := installer.LockPath(settings.PluginsDirectory)
+	lock, err := installer.LoadLock(lockPath)
+	if err != nil {
+		return err
+	}
 	var errorPlugins []string
+	var lockChanged bool
 	for _, name := range o.names {
 		if found := findPlugin(plugins, name); found != nil {
 			if err := uninstallPlugin(found); err != nil {
 				errorPlugins = append(errorPlugins, fmt.Sprintf("Failed to uninstall plugin %s, got error (%v)", name, err))
 			} else {
+				if lock.Get(name) != nil {
+					lock.Remove(name)
+					lockChanged = true
+				}
 				fmt.Fprintf(out, "Uninstalled plugin: %s\n", name)
 			}
 		} else {
 			errorPlugins = append(errorPlugins, fmt.Sprintf("Plugin: %s not found", name))
 		}
 	}
+	if lockChanged {
+		if err := lock.Save(lockPath); err != nil {
+			errorPlugins = append(errorPlugins, fmt.Sprintf("Failed to update the plugin lock file, got error (%v)", err))
+		}
+	}
 	if len(errorPlugins) > 0 {
 		return errors.Errorf(strings.Join(errorPlugins, "\n"))
 	}
@@ -40,6 +40,12 @@ directory for the chart and uncompress into that directory.
 If the --verify flag is specified, the requested chart MUST have a provenance
 file, and MUST pass the verification process. Failure in any part of this will
 result in an error, and the chart will not be saved locally.
+
+The --metadata flag unpacks the chart once its digest is verified against the
+repository index, and its provenance verified if it has a provenance file. A
+'<chart>.pull.json' file recording the source repository, resolved version,
+digest and pull time of the chart is written next to the chart directory, for
+the build systems tracing the charts they use.
 `
 
 func newPullCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -59,10 +65,7 @@ func newPullCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			client.Settings = settings
-			if client.Version == "" && client.Devel {
-				debug("setting version to >0.0.0-0")
-				client.Version = ">0.0.0-0"
-			}
+			client.UseDevel(client.Devel)
 
 			for i := 0; i < len(args); i++ {
 				output, err := client.Run(args[i])
@@ -76,9 +79,10 @@ func newPullCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	}
 
 	f := cmd.Flags()
-	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored.")
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to --channel edge")
 	f.BoolVar(&client.Untar, "untar", false, "if set to true, will untar the chart after downloading it")
 	f.BoolVar(&client.VerifyLater, "prov", false, "fetch the provenance file, but don't perform verification")
+	f.BoolVar(&client.Metadata, "metadata", false, "untar the chart once its digest and provenance are verified, and write a metadata file recording where it comes from next to it")
 	f.StringVar(&client.UntarDir, "untardir", ".", "if untar is specified, this flag specifies the name of the directory into which the chart is expanded")
 	f.StringVarP(&client.DestDir, "destination", "d", ".", "location to write the chart. If this and untardir are specified, untardir is appended to this")
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
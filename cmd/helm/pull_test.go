@@ -17,13 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/repo/repotest"
 )
 
@@ -72,6 +75,32 @@ func TestPullCmd(t *testing.T) {
 			args:       "test/signtest --version=0.1.0",
 			expectFile: "./signtest-0.1.0.tgz",
 		},
+		{
+			name:       "Chart fetch with @repo reference",
+			args:       "@test/signtest",
+			expectFile: "./signtest-0.1.0.tgz",
+		},
+		{
+			name:       "Chart fetch with configured repository given to --repo",
+			args:       "signtest --repo test",
+			expectFile: "./signtest-0.1.0.tgz",
+		},
+		{
+			name:       "Chart fetch with digest",
+			args:       "test/signtest --version=sha256:e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55",
+			expectFile: "./signtest-0.1.0.tgz",
+		},
+		{
+			name:       "Chart fetch with digest and repository URL",
+			args:       "signtest --version=sha256:e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55 --repo " + srv.URL(),
+			expectFile: "./signtest-0.1.0.tgz",
+		},
+		{
+			name:       "Fail chart fetch with unknown digest",
+			args:       "test/signtest --version=sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			wantError:  true,
+			failExpect: "no chart version found for signtest with digest",
+		},
 		{
 			name:       "Fail chart fetch with non-existent version",
 			args:       "test/signtest --version=99.1.0",
@@ -251,6 +280,51 @@ func TestPullCmd(t *testing.T) {
 	}
 }
 
+func TestPullWithMetadata(t *testing.T) {
+	srv, err := repotest.NewTempServerWithCleanup(t, "testdata/testcharts/*.tgz*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	if err := srv.LinkIndices(); err != nil {
+		t.Fatal(err)
+	}
+
+	outdir := srv.Root()
+	cmd := fmt.Sprintf("pull test/signtest --metadata --untardir meta --keyring=testdata/helm-test-key.pub -d '%s' --repository-config %s --repository-cache %s",
+		outdir,
+		filepath.Join(outdir, "repositories.yaml"),
+		outdir,
+	)
+	if _, _, err := executeActionCommand(cmd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "meta", "signtest", "Chart.yaml")); err != nil {
+		t.Errorf("expected the chart to be unpacked: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(outdir, "meta", "signtest"+action.PullMetadataFileSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m action.PullMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Chart != "signtest" || m.Version != "0.1.0" || m.Source != "test/signtest" || m.Repository != srv.URL() {
+		t.Errorf("unexpected chart source in metadata %s", data)
+	}
+	if m.Digest != "sha256:e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55" || !m.DigestVerified {
+		t.Errorf("unexpected digest in metadata %s", data)
+	}
+	if m.Provenance == nil || m.Provenance.Fingerprint != "5E615389B53CA37F0EE60BD3843BBF981FC18762" {
+		t.Errorf("expected a verified provenance in metadata %s", data)
+	}
+	if !m.PulledAt.Equal(testTimestamper()) {
+		t.Errorf("expected the pull time %s, got %s", testTimestamper(), m.PulledAt)
+	}
+}
+
 func TestPullWithCredentialsCmd(t *testing.T) {
 	srv, err := repotest.NewTempServerWithCleanup(t, "testdata/testcharts/*.tgz*")
 	if err != nil {
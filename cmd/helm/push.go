@@ -19,19 +19,32 @@ package main
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/pusher"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 const pushDesc = `
-Upload a chart to a registry.
+Upload a chart to a registry, or to a chart repository served over HTTP that
+implements the upload API of ChartMuseum, such as ChartMuseum and Harbor.
 
 If the chart has an associated provenance file,
 it will also be uploaded.
+
+If the chart has an associated software bill of materials (SBOM), written by
+'helm package --sbom', it is attached to the chart in the registry. Use the
+'--sbom' flag to generate one for charts without it. The attached SBOM can be
+displayed with 'helm show sbom'.
+
+A chart repository is given by its URL or by its name in the repositories file,
+e.g. 'helm push mychart-0.1.0.tgz https://charts.example.com/org/repo' or
+'helm push mychart-0.1.0.tgz myrepo'. The credentials and TLS settings of the
+repository in the repositories file are used unless they are given as flags.
 `
 
 func newPushCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -72,5 +85,14 @@ func newPushCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		},
 	}
 
+	f := cmd.Flags()
+	f.StringVar(&client.SBOM, "sbom", "", fmt.Sprintf("generate and attach a software bill of materials in this format if the chart has none. Allowed values: %s", strings.Join(sbom.Formats(), ", ")))
+	f.StringVar(&client.Username, "username", "", "chart repository username")
+	f.StringVar(&client.Password, "password", "", "chart repository password")
+	f.StringVar(&client.CertFile, "cert-file", "", "identify HTTPS client using this SSL certificate file")
+	f.StringVar(&client.KeyFile, "key-file", "", "identify HTTPS client using this SSL key file")
+	f.StringVar(&client.CaFile, "ca-file", "", "verify certificates of HTTPS-enabled servers using this CA bundle")
+	f.BoolVar(&client.InsecureSkipTLSverify, "insecure-skip-tls-verify", false, "skip tls certificate checks for the chart upload")
+
 	return cmd
 }
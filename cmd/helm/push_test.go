@@ -17,7 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/open-hand/helm/pkg/repo"
 )
 
 func TestPushFileCompletion(t *testing.T) {
@@ -25,3 +32,50 @@ func TestPushFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "push package.tgz", false)
 	checkFileCompletion(t, "push package.tgz oci://localhost:5000", false)
 }
+
+func TestPushToChartRepository(t *testing.T) {
+	var uploaded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		uploaded = append(uploaded, r.URL.Path+" "+r.MultipartForm.File["chart"][0].Filename)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	repoFile := filepath.Join(t.TempDir(), "repositories.yaml")
+	rf := repo.NewFile()
+	rf.Add(&repo.Entry{Name: "internal", URL: srv.URL + "/org/repo", Username: "user", Password: "pass"})
+	if err := rf.WriteFile(repoFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	chart := "testdata/testcharts/compressedchart-0.1.0.tgz"
+	for _, remote := range []string{"internal", srv.URL + "/org/repo/"} {
+		_, out, err := executeActionCommand(fmt.Sprintf("push %s %s --repository-config %s", chart, remote, repoFile))
+		if err != nil {
+			t.Fatalf("pushing to %s: %s", remote, err)
+		}
+		if want := "Pushed: compressedchart-0.1.0.tgz to " + srv.URL + "/org/repo\n"; out != want {
+			t.Errorf("expected %q, got %q", want, out)
+		}
+	}
+	if want := "/api/org/repo/charts compressedchart-0.1.0.tgz"; len(uploaded) != 2 || uploaded[0] != want || uploaded[1] != want {
+		t.Errorf("unexpected uploads %v", uploaded)
+	}
+
+	_, _, err := executeActionCommand(fmt.Sprintf("push %s %s/other --repository-config %s --username user --password wrong", chart, srv.URL, repoFile))
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected an authorization error, got %v", err)
+	}
+
+	_, _, err = executeActionCommand(fmt.Sprintf("push %s missing --repository-config %s", chart, repoFile))
+	if err == nil || !strings.Contains(err.Error(), `no repository named "missing" found`) {
+		t.Errorf("expected an unknown repository error, got %v", err)
+	}
+}
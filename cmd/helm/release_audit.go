@@ -0,0 +1,151 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+const releaseAuditHelp = `
+This command compares the resources recorded in a release with the cluster and
+reports drift:
+
+- resources of the release manifest that no longer exist
+- resources whose fields differ from the manifest. Fields that are not set in
+  the manifest, such as defaults and the status, are not compared
+- resources annotated as belonging to the release that are not in its manifest
+
+When drift is found, helm exits with status 2, so that the command can be used
+as a check in CI pipelines.
+`
+
+// driftError is returned when 'helm release audit' finds drift, so that helm
+// exits with a distinct status.
+type driftError struct {
+	release string
+}
+
+func (e driftError) Error() string {
+	return fmt.Sprintf("release %q has drifted from its manifest", e.release)
+}
+
+func newReleaseAuditCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseAudit(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "audit RELEASE_NAME",
+		Short: "compare a release with the cluster",
+		Long:  releaseAuditHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			if report.Drifted() {
+				err = driftError{release: report.Release}
+			}
+			return writeResult(out, outfmt, &auditWriter{report}, err)
+		},
+	}
+
+	cmd.Flags().IntVar(&client.Version, "revision", 0, "audit the named release with revision")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type auditWriter struct {
+	report *action.AuditReport
+}
+
+func (w *auditWriter) WriteTable(out io.Writer) error {
+	r := w.report
+	fmt.Fprintf(out, "NAME: %s\n", r.Release)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", r.Namespace)
+	fmt.Fprintf(out, "REVISION: %d\n", r.Revision)
+	if !r.Drifted() {
+		fmt.Fprintln(out, "No drift detected")
+		return nil
+	}
+	if len(r.Missing) > 0 {
+		fmt.Fprintln(out, "MISSING:")
+		for _, res := range r.Missing {
+			fmt.Fprintf(out, "  %s\n", formatAuditResource(res))
+		}
+	}
+	if len(r.Modified) > 0 {
+		fmt.Fprintln(out, "MODIFIED:")
+		for _, res := range r.Modified {
+			fmt.Fprintf(out, "  %s\n", formatAuditResource(res.AuditResource))
+			for _, f := range res.Fields {
+				fmt.Fprintf(out, "    %s:\n", f.Path)
+				fmt.Fprintf(out, "      - %s\n", formatAuditValue(f.Desired))
+				fmt.Fprintf(out, "      + %s\n", formatAuditValue(f.Live))
+			}
+		}
+	}
+	if len(r.Orphaned) > 0 {
+		fmt.Fprintln(out, "ORPHANED:")
+		for _, res := range r.Orphaned {
+			fmt.Fprintf(out, "  %s\n", formatAuditResource(res))
+		}
+	}
+	return nil
+}
+
+func (w *auditWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.report)
+}
+
+func (w *auditWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.report)
+}
+
+func formatAuditResource(res action.AuditResource) string {
+	if res.Namespace == "" {
+		return fmt.Sprintf("%s/%s", res.Kind, res.Name)
+	}
+	return fmt.Sprintf("%s/%s (namespace %s)", res.Kind, res.Name, res.Namespace)
+}
+
+// formatAuditValue renders a field value on a single line.
+func formatAuditValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+const releaseConvertAPIsHelp = `
+This command rewrites the Kubernetes APIs removed from the cluster in the
+manifests stored in the latest revision of a release to the APIs replacing
+them, e.g. extensions/v1beta1 Ingresses to networking.k8s.io/v1.
+
+Helm computes the changes of upgrades and rollbacks from the stored manifest of
+the release, so a release installed with APIs the cluster no longer serves
+cannot be upgraded, rolled back or uninstalled until its manifest is converted.
+Only the API versions are rewritten: the chart still has to be updated to use
+the new APIs. The resources themselves are not changed, since the cluster
+already serves them with the new APIs.
+
+The removed APIs are the ones of the version of the cluster, or of
+'--kube-version'. 'helm upgrade --migrate-apis' and 'helm rollback
+--migrate-apis' convert the release before computing their changes.
+`
+
+func newReleaseConvertAPIsCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseConvertAPIs(cfg)
+	var outfmt output.Format
+	var kubeVersion string
+
+	cmd := &cobra.Command{
+		Use:   "convert-apis RELEASE_NAME",
+		Short: "convert the removed Kubernetes APIs of a release",
+		Long:  releaseConvertAPIsHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if kubeVersion != "" {
+				parsed, err := chartutil.ParseKubeVersion(kubeVersion)
+				if err != nil {
+					return errors.Wrapf(err, "invalid kube version '%s'", kubeVersion)
+				}
+				client.KubeVersion = parsed
+			}
+			conversion, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &apiConversionWriter{conversion, client.DryRun})
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&kubeVersion, "kube-version", "", "the version of Kubernetes whose removed APIs are converted. Defaults to the version of the cluster")
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the resources that would be converted without storing the release")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type apiConversionWriter struct {
+	conversion *action.APIConversion
+	dryRun     bool
+}
+
+func (w *apiConversionWriter) WriteTable(out io.Writer) error {
+	c := w.conversion
+	if len(c.Converted) == 0 {
+		fmt.Fprintf(out, "Release %q (revision %d) uses no APIs removed in Kubernetes %s\n", c.Release, c.Revision, c.KubeVersion)
+		return nil
+	}
+	if w.dryRun {
+		fmt.Fprintf(out, "Release %q (revision %d) would be converted for Kubernetes %s:\n", c.Release, c.Revision, c.KubeVersion)
+	} else {
+		fmt.Fprintf(out, "Release %q (revision %d) has been converted for Kubernetes %s:\n", c.Release, c.Revision, c.KubeVersion)
+	}
+	table := uitable.New()
+	table.AddRow("TEMPLATE", "KIND", "NAME", "FROM", "TO")
+	for _, f := range c.Converted {
+		table.AddRow(f.Source, f.Kind, f.Name, f.APIVersion, f.Replacement)
+	}
+	return output.EncodeTable(out, table)
+}
+
+func (w *apiConversionWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.conversion)
+}
+
+func (w *apiConversionWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.conversion)
+}
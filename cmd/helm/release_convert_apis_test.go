@@ -0,0 +1,89 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func convertAPIsRelease() *release.Release {
+	rel := release.Mock(&release.MockReleaseOptions{Name: "web"})
+	rel.Manifest = `---
+# Source: web/templates/ingress.yaml
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+`
+	rel.Hooks = []*release.Hook{{
+		Name:     "cleanup",
+		Kind:     "CronJob",
+		Path:     "web/templates/cleanup.yaml",
+		Manifest: "apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: cleanup\n",
+		Events:   []release.HookEvent{release.HookPostInstall},
+	}}
+	return rel
+}
+
+func TestReleaseConvertAPIsCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "convert the removed APIs of a release",
+		cmd:    "release convert-apis web --kube-version 1.25",
+		golden: "output/release-convert-apis.txt",
+		rels:   []*release.Release{convertAPIsRelease()},
+	}, {
+		name:   "convert a release using no removed APIs",
+		cmd:    "release convert-apis web --kube-version 1.21 --dry-run",
+		golden: "output/release-convert-apis-none.txt",
+		rels:   []*release.Release{convertAPIsRelease()},
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseConvertAPIsStore(t *testing.T) {
+	store := storageFixture()
+	if err := store.Create(convertAPIsRelease()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeActionCommandC(store, "release convert-apis web --kube-version 1.25 --dry-run"); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := store.Get("web", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rel.Manifest, "extensions/v1beta1") {
+		t.Errorf("Expected a dry run not to store the converted manifest, got\n%s", rel.Manifest)
+	}
+
+	if _, _, err := executeActionCommandC(store, "release convert-apis web --kube-version 1.25"); err != nil {
+		t.Fatal(err)
+	}
+	if rel, err = store.Get("web", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rel.Manifest, "apiVersion: networking.k8s.io/v1") {
+		t.Errorf("Expected the converted manifest to be stored, got\n%s", rel.Manifest)
+	}
+	if !strings.Contains(rel.Hooks[0].Manifest, "apiVersion: batch/v1") || strings.Contains(rel.Hooks[0].Manifest, "v1beta1") {
+		t.Errorf("Expected the converted hook to be stored, got\n%s", rel.Hooks[0].Manifest)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+)
+
+const releaseExportHelp = `
+This command writes the full history of a release, including the chart, values
+and metadata of every revision, to standard output as a gzipped archive.
+
+The archive can be restored with 'helm release import', for example into
+another cluster during a migration:
+
+    $ helm release export my-release -n prod > my-release.tgz
+    $ helm release import my-release.tgz -n prod --kube-context new-cluster
+`
+
+func newReleaseExportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseExport(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "export RELEASE_NAME",
+		Short: "export the history of a release",
+		Long:  releaseExportHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return client.Run(args[0], out)
+		},
+	}
+
+	return cmd
+}
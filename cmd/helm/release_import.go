@@ -0,0 +1,76 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+)
+
+const releaseImportHelp = `
+This command restores a release exported with 'helm release export'.
+
+Every revision in the archive is stored in the current namespace, using the
+storage driver selected by $HELM_DRIVER, so a release can be moved between
+namespaces and storage backends as well as clusters. Use '-' to read the
+archive from standard input.
+
+Only the release records are restored. The resources of the release are
+expected to exist in the target cluster already, or to be created by a
+subsequent 'helm upgrade'.
+`
+
+func newReleaseImportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseImport(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "import BUNDLE",
+		Short: "import the history of a release",
+		Long:  releaseImportHelp,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+
+			client.Namespace = settings.Namespace()
+			history, err := client.Run(in)
+			if err != nil {
+				return err
+			}
+			last := history[len(history)-1]
+			fmt.Fprintf(out, "Imported release %q into namespace %q with %d revisions\n", last.Name, last.Namespace, len(history))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&client.Replace, "replace", false, "replace the history of an existing release of the same name with the imported one")
+
+	return cmd
+}
@@ -0,0 +1,67 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+)
+
+const releaseMigrateStorageHelp = `
+This command moves the release records of namespaces into the namespace
+dedicated to release history set by $HELM_DRIVER_NAMESPACE, so that the access
+to the history can be restricted apart from the namespaces the releases deploy
+to. Use --revert to move them back into the namespaces of their releases.
+
+The records of the current namespace are moved if no namespace is given. The
+command can be run again to resume an interrupted migration.
+`
+
+func newReleaseMigrateStorageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewMigrateStorage(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "migrate-storage [NAMESPACE...]",
+		Short: "move release records to or from the storage namespace",
+		Long:  releaseMigrateStorageHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client.Namespaces = args
+			if len(args) == 0 {
+				client.Namespaces = []string{settings.Namespace()}
+			}
+			moved, err := client.Run()
+			for _, rls := range moved {
+				if client.DryRun {
+					fmt.Fprintf(out, "Would move revision %d of release %q in namespace %q\n", rls.Version, rls.Name, rls.Namespace)
+				} else {
+					fmt.Fprintf(out, "Moved revision %d of release %q in namespace %q\n", rls.Version, rls.Name, rls.Namespace)
+				}
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Revert, "revert", false, "move the records back from the storage namespace to the namespaces of their releases")
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the records to move without moving them")
+
+	return cmd
+}
@@ -0,0 +1,132 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
+	helmtime "github.com/open-hand/helm/pkg/time"
+)
+
+var releasePruneHelp = `
+Prune deletes old revisions from a release's history.
+
+The most recent revision, and any revision that is deployed or has an operation
+in progress, is never deleted. Of the remaining revisions, those matched by any
+of the '--keep' flags are retained and all others are deleted.
+
+Use '--all' instead of a release name to prune the history of every release in
+the namespace, and '--dry-run' to list the revisions that would be deleted.
+`
+
+func newReleasePruneCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewHistoryPrune(cfg)
+	var outfmt output.Format
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "prune [RELEASE_NAME]",
+		Short: "delete old revisions from release history",
+		Long:  releasePruneHelp,
+		Args:  require.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return errors.New("either a release name or --all must be specified")
+			}
+
+			var pruned []*release.Release
+			var err error
+			if all {
+				pruned, err = client.RunAll()
+			} else {
+				pruned, err = client.Run(args[0])
+			}
+			// Report the revisions pruned before a failure too.
+			if err != nil && len(pruned) == 0 {
+				return err
+			}
+			return writeResult(out, outfmt, newPrunedRevisions(pruned), err)
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&all, "all", false, "prune the history of every release in the namespace")
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the revisions that would be deleted without deleting them")
+	f.IntVar(&client.Policy.KeepSuperseded, "keep", client.Policy.KeepSuperseded, "number of superseded revisions to keep. A negative value keeps all")
+	f.DurationVar(&client.Policy.KeepYoungerThan, "keep-younger-than", client.Policy.KeepYoungerThan, "keep every revision last deployed within this duration")
+	f.BoolVar(&client.Policy.KeepLastSuccessful, "keep-last-successful", client.Policy.KeepLastSuccessful, "keep the most recent successfully deployed revision")
+	f.BoolVar(&client.Policy.KeepLastFailed, "keep-last-failed", client.Policy.KeepLastFailed, "keep the most recent failed revision")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+type prunedRevision struct {
+	Name     string        `json:"name"`
+	Revision int           `json:"revision"`
+	Updated  helmtime.Time `json:"updated"`
+	Status   string        `json:"status"`
+	Chart    string        `json:"chart"`
+}
+
+type prunedRevisions []prunedRevision
+
+func newPrunedRevisions(rels []*release.Release) prunedRevisions {
+	revisions := prunedRevisions{}
+	for _, r := range rels {
+		revisions = append(revisions, prunedRevision{
+			Name:     r.Name,
+			Revision: r.Version,
+			Updated:  r.Info.LastDeployed,
+			Status:   r.Info.Status.String(),
+			Chart:    formatChartname(r.Chart),
+		})
+	}
+	return revisions
+}
+
+func (r prunedRevisions) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, r)
+}
+
+func (r prunedRevisions) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, r)
+}
+
+func (r prunedRevisions) WriteTable(out io.Writer) error {
+	tbl := uitable.New()
+	tbl.AddRow("NAME", "REVISION", "UPDATED", "STATUS", "CHART")
+	for _, item := range r {
+		tbl.AddRow(item.Name, item.Revision, item.Updated.Format(time.ANSIC), item.Status, item.Chart)
+	}
+	return output.EncodeTable(out, tbl)
+}
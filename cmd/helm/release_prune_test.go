@@ -0,0 +1,67 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestReleasePruneCmd(t *testing.T) {
+	mk := func(name string, vers int, status release.Status) *release.Release {
+		return release.Mock(&release.MockReleaseOptions{
+			Name:    name,
+			Version: vers,
+			Status:  status,
+		})
+	}
+	rels := func() []*release.Release {
+		return []*release.Release{
+			mk("angry-bird", 5, release.StatusDeployed),
+			mk("angry-bird", 4, release.StatusFailed),
+			mk("angry-bird", 3, release.StatusSuperseded),
+			mk("angry-bird", 2, release.StatusSuperseded),
+			mk("angry-bird", 1, release.StatusSuperseded),
+			mk("blue-bird", 2, release.StatusDeployed),
+			mk("blue-bird", 1, release.StatusSuperseded),
+		}
+	}
+
+	tests := []cmdTestCase{{
+		name:   "prune history of a release",
+		cmd:    "release prune angry-bird --keep 1 --keep-last-failed=false",
+		rels:   rels(),
+		golden: "output/release-prune.txt",
+	}, {
+		name:   "prune history of all releases in dry-run mode",
+		cmd:    "release prune --all --keep 0 --dry-run -o json",
+		rels:   rels(),
+		golden: "output/release-prune-all.json",
+	}, {
+		name:      "prune requires a release name or --all",
+		cmd:       "release prune",
+		rels:      rels(),
+		wantError: true,
+	}, {
+		name:      "prune rejects a release name with --all",
+		cmd:       "release prune angry-bird --all",
+		rels:      rels(),
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+)
+
+const releaseRebuildHelp = `
+This command reconstructs the record of a release whose release Secret was
+lost or corrupted, so that it can be managed by Helm again without being
+uninstalled and reinstalled.
+
+With '--from-cluster', the resources in the current namespace, and cluster
+scoped resources, that carry the ownership label and annotations Helm sets on
+the resources of the release are recorded as the manifest of a new, deployed
+revision.
+
+The record is a best effort. The values the release was installed with cannot
+be recovered, and the chart is only described by the 'helm.sh/chart' label of
+its resources. Run 'helm upgrade' with the chart and values afterwards to bring
+the record fully up to date. Use '--dry-run' to print the manifest that would be
+recorded.
+`
+
+func newReleaseRebuildCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseRebuild(cfg)
+	var fromCluster bool
+
+	cmd := &cobra.Command{
+		Use:   "rebuild RELEASE_NAME --from-cluster",
+		Short: "rebuild a lost release record",
+		Long:  releaseRebuildHelp,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !fromCluster {
+				return errors.New("a source to rebuild the release from is required, e.g. --from-cluster")
+			}
+			client.Namespace = settings.Namespace()
+			rel, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			if client.DryRun {
+				fmt.Fprintln(out, rel.Manifest)
+				return nil
+			}
+			fmt.Fprintf(out, "Release %q rebuilt as revision %d from %s\n", rel.Name, rel.Version, formatChartname(rel.Chart))
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&fromCluster, "from-cluster", false, "rebuild the release from the resources in the cluster")
+	f.BoolVar(&client.Force, "force", false, "rebuild even if a deployed revision of the release can be read")
+	f.BoolVar(&client.DryRun, "dry-run", false, "print the rebuilt manifest without storing the release")
+
+	return cmd
+}
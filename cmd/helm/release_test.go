@@ -0,0 +1,176 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/open-hand/helm/internal/test"
+	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestReleaseExportImport(t *testing.T) {
+	defer ensure.HelmHome(t)()
+	dir := ensure.TempDir(t)
+
+	src := storageFixture()
+	for _, rls := range []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-bird", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "angry-bird", Version: 2, Status: release.StatusDeployed}),
+	} {
+		if err := src.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, out, err := executeActionCommandC(src, "release export angry-bird")
+	if err != nil {
+		t.Fatalf("Failed to export release: %s", err)
+	}
+	bundle := filepath.Join(dir, "angry-bird.tgz")
+	if err := ioutil.WriteFile(bundle, []byte(out), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := storageFixture()
+	_, out, err = executeActionCommandC(dst, "release import "+bundle+" -n birds")
+	if err != nil {
+		t.Fatalf("Failed to import release: %s", err)
+	}
+	if want := "Imported release \"angry-bird\" into namespace \"birds\" with 2 revisions\n"; out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+	h, err := dst.History("angry-bird")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 {
+		t.Fatalf("Expected 2 imported revisions, got %d", len(h))
+	}
+	for _, rls := range h {
+		if rls.Namespace != "birds" {
+			t.Errorf("Expected revision %d in namespace birds, got %q", rls.Version, rls.Namespace)
+		}
+	}
+
+	// importing over an existing release requires --replace
+	if _, _, err := executeActionCommandC(dst, "release import "+bundle+" -n birds"); err == nil {
+		t.Error("Expected an error importing over an existing release")
+	}
+	// replacing drops the revisions the bundle does not have
+	if err := dst.Create(release.Mock(&release.MockReleaseOptions{Name: "angry-bird", Version: 3, Namespace: "birds"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := executeActionCommandC(dst, "release import "+bundle+" -n birds --replace"); err != nil {
+		t.Errorf("Failed to replace release: %s", err)
+	}
+	if h, err = dst.History("angry-bird"); err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 {
+		t.Errorf("Expected the 2 imported revisions to replace the history, got %d revisions", len(h))
+	}
+}
+
+func TestReleaseExportNotFound(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "export a missing release",
+		cmd:       "release export angry-bird",
+		golden:    "output/release-export-not-found.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseAuditCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-bird", Version: 1, Status: release.StatusDeployed}),
+	}
+	tests := []cmdTestCase{{
+		name:   "audit a release without drift",
+		cmd:    "release audit angry-bird",
+		rels:   rels,
+		golden: "output/release-audit.txt",
+	}, {
+		name:   "audit a release in json",
+		cmd:    "release audit angry-bird -o json",
+		rels:   rels,
+		golden: "output/release-audit.json",
+	}, {
+		name:      "audit a missing release",
+		cmd:       "release audit blue-bird",
+		rels:      rels,
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseRebuildCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-bird", Version: 1, Status: release.StatusDeployed}),
+	}
+	tests := []cmdTestCase{{
+		name:      "rebuild requires a source",
+		cmd:       "release rebuild angry-bird",
+		rels:      rels,
+		golden:    "output/release-rebuild-no-source.txt",
+		wantError: true,
+	}, {
+		name:      "rebuild refuses a release with a deployed revision",
+		cmd:       "release rebuild angry-bird --from-cluster",
+		rels:      rels,
+		golden:    "output/release-rebuild-deployed.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseAuditWriter(t *testing.T) {
+	deployment := action.AuditResource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web"}
+	report := &action.AuditReport{
+		Release:   "angry-bird",
+		Namespace: "default",
+		Revision:  2,
+		Missing:   []action.AuditResource{{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web"}},
+		Modified: []action.ModifiedResource{{
+			AuditResource: deployment,
+			Fields: []kube.FieldDrift{
+				{Path: "spec.replicas", Desired: 3, Live: 5},
+				{Path: "spec.template.metadata.labels.tier", Desired: "web"},
+			},
+		}},
+		Orphaned: []action.AuditResource{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "web-old"}},
+	}
+	var buf bytes.Buffer
+	if err := (&auditWriter{report}).WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, buf.String(), "output/release-audit-drift.txt")
+}
+
+func TestReleaseMigrateStorageWithoutStorageNamespace(t *testing.T) {
+	_, _, err := executeActionCommandC(storageFixture(), "release migrate-storage apps")
+	if err == nil || err.Error() != "no storage namespace is configured: set $HELM_DRIVER_NAMESPACE with the secret or configmap driver" {
+		t.Errorf("expected an error about the storage namespace, got %v", err)
+	}
+}
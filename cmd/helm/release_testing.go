@@ -23,11 +23,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
 )
 
 const releaseTestHelp = `
@@ -35,11 +37,23 @@ The test command runs the tests for a release.
 
 The argument this command takes is the name of a deployed release.
 The tests to be run are defined in the chart that was installed.
+
+Use '--test' to run a single test again, e.g. one that failed, keeping the
+last results of the other tests.
+
+The resources of the tests are deleted according to their delete policy,
+unless '--cleanup-on-success' deletes the ones of the tests that succeed, or
+'--retain-failed' keeps the ones of the tests that fail for some time to
+inspect them. Retained resources are deleted by the first test run after that
+time, or before the test runs again.
+
+With '--output json' or '--output yaml', the result of each test run is
+given with its duration, the exit code of its pod and the end of its logs.
 `
 
 func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewReleaseTesting(cfg)
-	var outfmt = output.Table
+	var outfmt output.Format
 	var outputLogs bool
 	var filter []string
 
@@ -64,15 +78,16 @@ func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 					client.Filters["!name"] = append(client.Filters["!name"], notName.ReplaceAllLiteralString(f, ""))
 				}
 			}
-			rel, runErr := client.Run(args[0])
+			res, runErr := client.RunTests(args[0])
 			// We only return an error if we weren't even able to get the
 			// release, otherwise we keep going so we can print status and logs
 			// if requested
-			if runErr != nil && rel == nil {
+			if runErr != nil && (res == nil || res.Release == nil) {
 				return runErr
 			}
+			rel := res.Release
 
-			if err := outfmt.Write(out, &statusPrinter{rel, settings.Debug, false}); err != nil {
+			if err := outfmt.Write(out, &testResultsWriter{statusPrinter{rel, settings.Debug, false, nil}, res}); err != nil {
 				return err
 			}
 
@@ -91,7 +106,49 @@ func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 	f := cmd.Flags()
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.BoolVar(&outputLogs, "logs", false, "dump the logs from test pods (this runs after all tests are complete, but before any cleanup)")
+	f.StringVar(&client.Test, "test", "", "run only the test of this name, e.g. to re-run a test that failed")
+	f.BoolVar(&client.CleanupOnSuccess, "cleanup-on-success", false, "delete the resources of the tests that succeed, whatever their delete policy")
+	f.DurationVar(&client.RetainFailed, "retain-failed", 0, "keep the resources of the tests that fail for this long, whatever their delete policy (like 30m or 24h)")
+	bindOutputFlag(cmd, &outfmt)
 	f.StringSliceVar(&filter, "filter", []string{}, "specify tests by attribute (currently \"name\") using attribute=value syntax or '!attribute=value' to exclude a test (can specify multiple or separate values with commas: name=test1,name=test2)")
 
 	return cmd
 }
+
+// testResultsWriter writes the status of a tested release followed by the
+// results of the tests run, or the results alone as JSON or YAML.
+type testResultsWriter struct {
+	status  statusPrinter
+	results *release.TestReleaseResponse
+}
+
+func (w *testResultsWriter) WriteTable(out io.Writer) error {
+	if err := w.status.WriteTable(out); err != nil {
+		return err
+	}
+	if len(w.results.Tests) == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "TEST RESULTS:")
+	tbl := uitable.New()
+	tbl.AddRow("NAME", "PHASE", "DURATION", "EXIT CODE", "RETAINED UNTIL")
+	for _, t := range w.results.Tests {
+		code, until := "-", "-"
+		if t.ExitCode != nil {
+			code = fmt.Sprint(*t.ExitCode)
+		}
+		if t.RetainedUntil != nil {
+			until = t.RetainedUntil.Format(time.RFC3339)
+		}
+		tbl.AddRow(t.Name, t.Phase, t.Duration, code, until)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *testResultsWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.results)
+}
+
+func (w *testResultsWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.results)
+}
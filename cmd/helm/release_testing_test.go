@@ -17,9 +17,63 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/open-hand/helm/pkg/release"
 )
 
+func TestReleaseTesting(t *testing.T) {
+	store := storageFixture()
+	rel := release.Mock(&release.MockReleaseOptions{Name: "aeneas", Status: release.StatusDeployed})
+	rel.Hooks = []*release.Hook{
+		{Name: "smoke", Kind: "Pod", Events: []release.HookEvent{release.HookTest}},
+		{Name: "load", Kind: "Pod", Events: []release.HookEvent{release.HookTest}, Weight: 1},
+	}
+	if err := store.Create(rel); err != nil {
+		t.Fatal(err)
+	}
+
+	_, out, err := executeActionCommandC(store, "test aeneas -o json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res release.TestReleaseResponse
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid output %q: %s", out, err)
+	}
+	if len(res.Tests) != 2 || res.Tests[0].Name != "smoke" || res.Tests[1].Name != "load" {
+		t.Fatalf("expected the results of smoke and load, got %+v", res.Tests)
+	}
+	for _, r := range res.Tests {
+		if r.Phase != release.HookPhaseSucceeded || r.StartedAt.IsZero() || r.Duration < 0 {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+
+	// Re-run a single test, keeping the results of the other one.
+	_, out, err = executeActionCommandC(store, "test aeneas --test load -o json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res = release.TestReleaseResponse{}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid output %q: %s", out, err)
+	}
+	if len(res.Tests) != 1 || res.Tests[0].Name != "load" {
+		t.Fatalf("expected the result of load, got %+v", res.Tests)
+	}
+	if len(res.Release.Hooks) != 2 {
+		t.Errorf("expected the release to keep its 2 test hooks, got %d", len(res.Release.Hooks))
+	}
+
+	_, out, err = executeActionCommandC(store, "test aeneas --test missing")
+	if err == nil || !strings.Contains(err.Error(), `has no test named "missing"`) {
+		t.Errorf("expected an error for an unknown test, got %v: %s", err, out)
+	}
+}
+
 func TestReleaseTestingCompletion(t *testing.T) {
 	checkReleaseCompletion(t, "test", false)
 }
@@ -17,13 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/repo"
 )
 
 var repoHelm = `
@@ -45,6 +50,7 @@ func newRepoCmd(out io.Writer) *cobra.Command {
 	cmd.AddCommand(newRepoRemoveCmd(out))
 	cmd.AddCommand(newRepoIndexCmd(out))
 	cmd.AddCommand(newRepoUpdateCmd(out))
+	cmd.AddCommand(newRepoMigrateCredentialsCmd(out))
 
 	return cmd
 }
@@ -52,3 +58,92 @@ func newRepoCmd(out io.Writer) *cobra.Command {
 func isNotExist(err error) bool {
 	return os.IsNotExist(errors.Cause(err))
 }
+
+// addRepoLockFlags adds the flags controlling how long the commands changing
+// the repositories file wait for another process to release it.
+func addRepoLockFlags(f *pflag.FlagSet, opts *repo.LockOptions) {
+	f.Var((*lockTimeoutValue)(opts), "lock-timeout", "how long to wait for another process to release the repositories file. 0 fails right away if it is locked")
+}
+
+// lockTimeoutValue sets the timeout of repo.LockOptions, where a zero or
+// negative duration means not to retry.
+type lockTimeoutValue repo.LockOptions
+
+func (v *lockTimeoutValue) String() string {
+	switch {
+	case v.Timeout == 0:
+		return repo.DefaultLockTimeout.String()
+	case v.Timeout < 0:
+		return "0s"
+	}
+	return v.Timeout.String()
+}
+
+func (v *lockTimeoutValue) Type() string {
+	return "duration"
+}
+
+func (v *lockTimeoutValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		d = -1
+	}
+	v.Timeout = d
+	return nil
+}
+
+// repoChangeStatus is the outcome of the change of a repository by 'helm repo
+// add' or 'helm repo remove'.
+type repoChangeStatus string
+
+const (
+	repoAdded     repoChangeStatus = "added"
+	repoUnchanged repoChangeStatus = "unchanged"
+	repoRemoved   repoChangeStatus = "removed"
+)
+
+// repoChange is the change of a repository.
+type repoChange struct {
+	Name   string           `json:"name"`
+	URL    string           `json:"url,omitempty"`
+	Status repoChangeStatus `json:"status"`
+}
+
+// writeRepoChanges writes the changes of the repositories made before the
+// command failed with err, if not nil, in the format outfmt, the table
+// format if it is not set.
+func writeRepoChanges(out io.Writer, outfmt output.Format, changes []repoChange, err error) error {
+	if outfmt != output.JSON && outfmt != output.YAML {
+		outfmt = output.Table
+	}
+	return writeResult(out, outfmt, &repoChangeWriter{changes}, err)
+}
+
+type repoChangeWriter struct {
+	changes []repoChange
+}
+
+func (w *repoChangeWriter) WriteTable(out io.Writer) error {
+	for _, c := range w.changes {
+		switch c.Status {
+		case repoAdded:
+			fmt.Fprintf(out, "%q has been added to your repositories\n", c.Name)
+		case repoUnchanged:
+			fmt.Fprintf(out, "%q already exists with the same configuration, skipping\n", c.Name)
+		case repoRemoved:
+			fmt.Fprintf(out, "%q has been removed from your repositories\n", c.Name)
+		}
+	}
+	return nil
+}
+
+func (w *repoChangeWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.changes)
+}
+
+func (w *repoChangeWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.changes)
+}
@@ -17,22 +17,18 @@ limitations under the License.
 package main
 
 import (
-	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"path/filepath"
+	"reflect"
 	"strings"
-	"time"
 
-	"github.com/gofrs/flock"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
-	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/repo"
 )
@@ -52,6 +48,8 @@ type repoAddOptions struct {
 	passCredentialsAll   bool
 	forceUpdate          bool
 	allowDeprecatedRepos bool
+	credentialStore      string
+	aliases              []string
 
 	certFile              string
 	keyFile               string
@@ -60,6 +58,8 @@ type repoAddOptions struct {
 
 	repoFile  string
 	repoCache string
+	lock      repo.LockOptions
+	outfmt    output.Format
 
 	// Deprecated, but cannot be removed until Helm 4
 	deprecatedNoUpdate bool
@@ -95,6 +95,10 @@ func newRepoAddCmd(out io.Writer) *cobra.Command {
 	f.BoolVar(&o.insecureSkipTLSverify, "insecure-skip-tls-verify", false, "skip tls certificate checks for the repository")
 	f.BoolVar(&o.allowDeprecatedRepos, "allow-deprecated-repos", false, "by default, this command will not allow adding official repos that have been permanently deleted. This disables that behavior")
 	f.BoolVar(&o.passCredentialsAll, "pass-credentials", false, "pass credentials to all domains")
+	f.StringArrayVar(&o.aliases, "alias", nil, "other name the repository can be referred to by, such as a former name. This flag can be repeated")
+	f.StringVar(&o.credentialStore, "credential-store", "", fmt.Sprintf("store the username and password in this credential store instead of the repositories file: %q for the keychain of the operating system, or the name of a docker-credential-<name> helper", repo.KeychainCredentialStore))
+	addRepoLockFlags(f, &o.lock)
+	bindOutputFlag(cmd, &o.outfmt)
 
 	return cmd
 }
@@ -109,113 +113,107 @@ func (o *repoAddOptions) run(out io.Writer) error {
 		}
 	}
 
-	// Ensure the file directory exists as it is required for file locking
-	err := os.MkdirAll(filepath.Dir(o.repoFile), os.ModePerm)
-	if err != nil && !os.IsExist(err) {
-		return err
-	}
-
-	// Acquire a file lock for process synchronization
-	repoFileExt := filepath.Ext(o.repoFile)
-	var lockPath string
-	if len(repoFileExt) > 0 && len(repoFileExt) < len(o.repoFile) {
-		lockPath = strings.TrimSuffix(o.repoFile, repoFileExt) + ".lock"
-	} else {
-		lockPath = o.repoFile + ".lock"
-	}
-	fileLock := flock.New(lockPath)
-	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	locked, err := fileLock.TryLockContext(lockCtx, time.Second)
-	if err == nil && locked {
-		defer fileLock.Unlock()
-	}
-	if err != nil {
-		return err
-	}
-
-	b, err := ioutil.ReadFile(o.repoFile)
-	if err != nil && !os.IsNotExist(err) {
-		return err
-	}
+	// The file is locked while it is read and written for process
+	// synchronization.
+	status := repoAdded
+	err := repo.UpdateFile(o.repoFile, o.lock, func(f *repo.File) error {
+		if o.username != "" && o.password == "" {
+			if o.passwordFromStdinOpt {
+				passwordFromStdin, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return err
+				}
+				password := strings.TrimSuffix(string(passwordFromStdin), "\n")
+				password = strings.TrimSuffix(password, "\r")
+				o.password = password
+			} else {
+				fd := int(os.Stdin.Fd())
+				fmt.Fprint(out, "Password: ")
+				password, err := term.ReadPassword(fd)
+				fmt.Fprintln(out)
+				if err != nil {
+					return err
+				}
+				o.password = string(password)
+			}
+		}
 
-	var f repo.File
-	if err := yaml.Unmarshal(b, &f); err != nil {
-		return err
-	}
+		c := repo.Entry{
+			Name:                  o.name,
+			URL:                   o.url,
+			Username:              o.username,
+			Password:              o.password,
+			PassCredentialsAll:    o.passCredentialsAll,
+			CertFile:              o.certFile,
+			KeyFile:               o.keyFile,
+			CAFile:                o.caFile,
+			InsecureSkipTLSverify: o.insecureSkipTLSverify,
+			Aliases:               o.aliases,
+		}
 
-	if o.username != "" && o.password == "" {
-		if o.passwordFromStdinOpt {
-			passwordFromStdin, err := io.ReadAll(os.Stdin)
-			if err != nil {
-				return err
+		// Check if the repo name is legal
+		for _, name := range append([]string{o.name}, o.aliases...) {
+			if strings.Contains(name, "/") {
+				return errors.Errorf("repository name (%s) contains '/', please specify a different name without '/'", name)
 			}
-			password := strings.TrimSuffix(string(passwordFromStdin), "\n")
-			password = strings.TrimSuffix(password, "\r")
-			o.password = password
-		} else {
-			fd := int(os.Stdin.Fd())
-			fmt.Fprint(out, "Password: ")
-			password, err := term.ReadPassword(fd)
-			fmt.Fprintln(out)
-			if err != nil {
-				return err
+		}
+		// The aliases of a repository are kept when it is added again without them.
+		if existing := f.Get(o.name); existing != nil && existing.Name == o.name && len(o.aliases) == 0 {
+			c.Aliases = existing.Aliases
+		}
+		for _, alias := range o.aliases {
+			if other := f.Get(alias); other != nil && other.Name != o.name {
+				return errors.Errorf("repository name (%s) already exists, please specify a different alias", alias)
 			}
-			o.password = string(password)
 		}
-	}
 
-	c := repo.Entry{
-		Name:                  o.name,
-		URL:                   o.url,
-		Username:              o.username,
-		Password:              o.password,
-		PassCredentialsAll:    o.passCredentialsAll,
-		CertFile:              o.certFile,
-		KeyFile:               o.keyFile,
-		CAFile:                o.caFile,
-		InsecureSkipTLSverify: o.insecureSkipTLSverify,
-	}
+		// If the repo exists do one of two things:
+		// 1. If the configuration for the name is the same continue without error
+		// 2. When the config is different require --force-update
+		if !o.forceUpdate && f.Has(o.name) {
+			// The credentials of the existing repository are compared with the
+			// new ones even if they are in a credential store.
+			existing, configured := *f.Get(o.name), c
+			if existing.CredentialStore != "" {
+				existing.Username, existing.Password, _ = existing.Credentials()
+			}
+			if o.credentialStore != "" && (c.Username != "" || c.Password != "") {
+				configured.CredentialStore = o.credentialStore
+			}
+			if !reflect.DeepEqual(configured, existing) {
 
-	// Check if the repo name is legal
-	if strings.Contains(o.name, "/") {
-		return errors.Errorf("repository name (%s) contains '/', please specify a different name without '/'", o.name)
-	}
+				// The input coming in for the name is different from what is already
+				// configured. Return an error.
+				return errors.Errorf("repository name (%s) already exists, please specify a different name", o.name)
+			}
 
-	// If the repo exists do one of two things:
-	// 1. If the configuration for the name is the same continue without error
-	// 2. When the config is different require --force-update
-	if !o.forceUpdate && f.Has(o.name) {
-		existing := f.Get(o.name)
-		if c != *existing {
+			// The add is idempotent so do nothing
+			status = repoUnchanged
+			return repo.ErrSkipWrite
+		}
 
-			// The input coming in for the name is different from what is already
-			// configured. Return an error.
-			return errors.Errorf("repository name (%s) already exists, please specify a different name", o.name)
+		r, err := repo.NewChartRepository(&c, getter.All(settings))
+		if err != nil {
+			return err
 		}
 
-		// The add is idempotent so do nothing
-		fmt.Fprintf(out, "%q already exists with the same configuration, skipping\n", o.name)
-		return nil
-	}
+		if o.repoCache != "" {
+			r.CachePath = o.repoCache
+		}
+		if _, _, err := r.DownloadIndexFile(); err != nil {
+			return errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", o.url)
+		}
 
-	r, err := repo.NewChartRepository(&c, getter.All(settings))
+		if o.credentialStore != "" {
+			if err := c.StoreCredentials(o.credentialStore); err != nil {
+				return err
+			}
+		}
+		f.Update(&c)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-
-	if o.repoCache != "" {
-		r.CachePath = o.repoCache
-	}
-	if _, _, err := r.DownloadIndexFile(); err != nil {
-		return errors.Wrapf(err, "looks like %q is not a valid chart repository or cannot be reached", o.url)
-	}
-
-	f.Update(&c)
-
-	if err := f.WriteFile(o.repoFile, 0644); err != nil {
-		return err
-	}
-	fmt.Fprintf(out, "%q has been added to your repositories\n", o.name)
-	return nil
+	return writeRepoChanges(out, o.outfmt, []repoChange{{Name: o.name, URL: o.url, Status: status}}, nil)
 }
@@ -25,6 +25,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/pkg/errors"
 	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/internal/test/ensure"
@@ -135,6 +136,104 @@ func TestRepoAdd(t *testing.T) {
 	}
 }
 
+func TestRepoAddAlias(t *testing.T) {
+	ts, err := repotest.NewTempServerWithCleanup(t, "testdata/testserver/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Stop()
+	defer resetEnv()()
+
+	rootDir := ensure.TempDir(t)
+	repoFile := filepath.Join(rootDir, "repositories.yaml")
+	os.Setenv(xdg.CacheHomeEnvVar, rootDir)
+
+	o := &repoAddOptions{
+		name:     "renamed",
+		url:      ts.URL(),
+		aliases:  []string{"former"},
+		repoFile: repoFile,
+	}
+	if err := o.run(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := repo.LoadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := f.Get("former"); e == nil || e.Name != "renamed" {
+		t.Fatalf("expected the alias to refer to the repository, got %+v", e)
+	}
+
+	// Adding the repository again without its aliases keeps them.
+	o.aliases = nil
+	if err := o.run(ioutil.Discard); err != nil {
+		t.Errorf("expected adding the same repository again to succeed: %s", err)
+	}
+
+	// An alias cannot be the name of another repository.
+	o = &repoAddOptions{
+		name:     "former",
+		url:      ts.URL() + "/other",
+		repoFile: repoFile,
+	}
+	if err := o.run(ioutil.Discard); err == nil {
+		t.Error("expected an error adding a repository named like an alias")
+	}
+}
+
+func TestRepoAddLocked(t *testing.T) {
+	ts, err := repotest.NewTempServerWithCleanup(t, "testdata/testserver/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Stop()
+	defer resetEnv()()
+
+	rootDir := ensure.TempDir(t)
+	repoFile := filepath.Join(rootDir, "repositories.yaml")
+	os.Setenv(xdg.CacheHomeEnvVar, rootDir)
+	if err := ioutil.WriteFile(repoFile, []byte("apiVersion: v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := repo.LockFile(repoFile, repo.LockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &repoAddOptions{
+		name:     "test-name",
+		url:      ts.URL(),
+		repoFile: repoFile,
+		lock:     repo.LockOptions{Timeout: -1},
+	}
+	if err := o.run(ioutil.Discard); !errors.Is(err, repo.ErrFileLocked) {
+		t.Fatalf("expected the repositories file to be locked, got %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.run(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the repositories file to keep its permissions 0600, got %o", fi.Mode().Perm())
+	}
+	f, err := repo.LoadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Has("test-name") {
+		t.Errorf("test-name was not successfully inserted into %s", repoFile)
+	}
+}
+
 func TestRepoAddCheckLegalName(t *testing.T) {
 	ts, err := repotest.NewTempServerWithCleanup(t, "testdata/testserver/*.*")
 	if err != nil {
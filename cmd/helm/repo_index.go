@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -25,6 +26,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/repo"
 )
 
@@ -37,12 +39,21 @@ set an absolute URL to the charts, use '--url' flag.
 To merge the generated index with an existing index file, use the '--merge'
 flag. In this case, the charts found in the current directory will be merged
 into the existing index, with local charts taking priority over existing charts.
+
+To index the charts of an S3 or GCS bucket instead, without downloading them,
+use the '--bucket' flag, e.g. '--bucket s3://bucket/charts'. Only the beginning
+of the chart archives is read, up to their Chart.yaml, and the index is written
+to the given directory. The charts of the index given with '--merge' are not
+read again. The digests of the charts are left out unless the '--digests' flag
+is set, which downloads the whole archives.
 `
 
 type repoIndexOptions struct {
-	dir   string
-	url   string
-	merge string
+	dir     string
+	url     string
+	merge   string
+	bucket  string
+	digests bool
 }
 
 func newRepoIndexCmd(out io.Writer) *cobra.Command {
@@ -70,6 +81,8 @@ func newRepoIndexCmd(out io.Writer) *cobra.Command {
 	f := cmd.Flags()
 	f.StringVar(&o.url, "url", "", "url of chart repository")
 	f.StringVar(&o.merge, "merge", "", "merge the generated index into the given index")
+	f.StringVar(&o.bucket, "bucket", "", "index the charts of this S3 or GCS bucket URL, e.g. s3://bucket/charts, instead of the directory")
+	f.BoolVar(&o.digests, "digests", false, "with --bucket, download the whole chart archives to record their digests")
 
 	return cmd
 }
@@ -80,9 +93,36 @@ func (i *repoIndexOptions) run(out io.Writer) error {
 		return err
 	}
 
+	if i.bucket != "" {
+		return indexBucket(path, i.bucket, i.url, i.merge, i.digests)
+	}
 	return index(path, i.url, i.merge)
 }
 
+func indexBucket(dir, bucket, url, mergeTo string, digests bool) error {
+	opts := repo.IndexBucketOptions{
+		Getters: getter.All(settings),
+		BaseURL: url,
+		Digests: digests,
+	}
+	if mergeTo != "" {
+		if _, err := os.Stat(mergeTo); err == nil {
+			if opts.Previous, err = repo.LoadIndexFile(mergeTo); err != nil {
+				return errors.Wrap(err, "merge failed")
+			}
+		}
+	}
+	i, err := repo.IndexBucket(context.Background(), bucket, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Previous != nil {
+		i.Merge(opts.Previous)
+		i.SortEntries()
+	}
+	return i.WriteFile(filepath.Join(dir, "index.yaml"), 0644)
+}
+
 func index(dir, url, mergeTo string) error {
 	out := filepath.Join(dir, "index.yaml")
 
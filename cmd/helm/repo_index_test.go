@@ -18,10 +18,17 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/open-hand/helm/internal/test/ensure"
 	"github.com/open-hand/helm/pkg/repo"
@@ -166,6 +173,60 @@ func copyFile(dst, src string) error {
 	return err
 }
 
+func TestRepoIndexBucket(t *testing.T) {
+	charts := []string{"compressedchart-0.1.0.tgz", "compressedchart-0.2.0.tgz", "signtest-0.1.0.tgz"}
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket" {
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, c := range charts {
+				fmt.Fprintf(w, "<Contents><Key>charts/%s</Key><LastModified>2022-01-01T00:00:00Z</LastModified></Contents>", c)
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+			return
+		}
+		data, err := ioutil.ReadFile(filepath.Join("testdata/testcharts", strings.TrimPrefix(r.URL.Path, "/bucket/charts/")))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "chart", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+
+	dir := ensure.TempDir(t)
+	buf := bytes.NewBuffer(nil)
+	c := newRepoIndexCmd(buf)
+	c.Flags().Set("bucket", "s3://bucket/charts")
+	c.Flags().Set("url", "https://charts.example.com")
+	if err := c.RunE(c, []string{dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := repo.LoadIndexFile(filepath.Join(dir, "index.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Entries["compressedchart"]) != 2 || len(index.Entries["signtest"]) != 1 {
+		t.Fatalf("unexpected entries %#v", index.Entries)
+	}
+	cv := index.Entries["compressedchart"][0]
+	if cv.Version != "0.2.0" || cv.URLs[0] != "https://charts.example.com/compressedchart-0.2.0.tgz" || cv.Digest != "" {
+		t.Errorf("unexpected entry %#v", cv)
+	}
+	if len(ranges) != 3 || ranges[0] == "" {
+		t.Errorf("expected the charts to be read partially, got ranges %v", ranges)
+	}
+}
+
 func TestRepoIndexFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "repo index", true)
 	checkFileCompletion(t, "repo index mydir", false)
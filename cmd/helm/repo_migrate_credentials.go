@@ -0,0 +1,104 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+const repoMigrateCredentialsDesc = `
+Move the usernames and passwords of chart repositories from the repositories
+file to a credential store, such as the keychain of the operating system (the
+macOS Keychain, the Windows Credential Manager, or the Secret Service), or an
+external credential helper.
+
+The credentials of the repositories given as arguments are moved, or the ones
+of all the repositories if none are given. A credential store named "pass"
+is implemented by the docker-credential-pass helper, which must be in the
+PATH. The credentials are then looked up in the credential store whenever
+the repository is accessed.
+
+    $ helm repo migrate-credentials
+    $ helm repo migrate-credentials private --credential-store pass
+`
+
+type repoMigrateCredentialsOptions struct {
+	names           []string
+	credentialStore string
+	repoFile        string
+	lock            repo.LockOptions
+}
+
+func newRepoMigrateCredentialsCmd(out io.Writer) *cobra.Command {
+	o := &repoMigrateCredentialsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate-credentials [REPO1 [REPO2 ...]]",
+		Short: "move the credentials of chart repositories to a credential store",
+		Long:  repoMigrateCredentialsDesc,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compListRepos(toComplete, args), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.repoFile = settings.RepositoryConfig
+			o.names = args
+			return o.run(out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&o.credentialStore, "credential-store", repo.KeychainCredentialStore, fmt.Sprintf("the credential store: %q for the keychain of the operating system, or the name of a docker-credential-<name> helper", repo.KeychainCredentialStore))
+	addRepoLockFlags(f, &o.lock)
+	return cmd
+}
+
+func (o *repoMigrateCredentialsOptions) run(out io.Writer) error {
+	var migrated []string
+	var migrateErr error
+	err := repo.UpdateFile(o.repoFile, o.lock, func(r *repo.File) error {
+		if len(r.Repositories) == 0 {
+			return errors.New("no repositories configured")
+		}
+		migrated, migrateErr = r.MigrateCredentials(o.credentialStore, o.names...)
+		// The repositories whose credentials were stored are written even
+		// if others failed, so that their credentials are no longer in
+		// plain text.
+		if len(migrated) == 0 {
+			return repo.ErrSkipWrite
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range migrated {
+		fmt.Fprintf(out, "The credentials of %q have been moved to the credential store %q\n", name, o.credentialStore)
+	}
+	if migrateErr != nil {
+		return migrateErr
+	}
+	if len(migrated) == 0 {
+		fmt.Fprintln(out, "No credentials to migrate")
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/repo"
+	"github.com/open-hand/helm/pkg/repo/repotest"
+)
+
+// fakeCredentialHelper installs the credential helper
+// docker-credential-helmtest in the PATH, storing the credentials in files
+// of the returned directory.
+func fakeCredentialHelper(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+	dir := ensure.TempDir(t)
+	store := filepath.Join(dir, "store")
+	if err := os.Mkdir(store, 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := `#!/bin/sh
+read -r input
+key() { printf '%s' "$1" | cksum | cut -d' ' -f1; }
+case "$1" in
+store)
+	url=$(printf '%s' "$input" | sed 's/.*"ServerURL":"\([^"]*\)".*/\1/')
+	printf '%s' "$input" > "` + store + `/$(key "$url")"
+	;;
+get)
+	file="` + store + `/$(key "$input")"
+	if [ ! -f "$file" ]; then
+		echo "credentials not found in native keychain"
+		exit 1
+	fi
+	cat "$file"
+	;;
+erase)
+	rm -f "` + store + `/$(key "$input")"
+	;;
+esac
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-helmtest"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return store
+}
+
+func TestRepoCredentialStore(t *testing.T) {
+	store := fakeCredentialHelper(t)
+	srv := repotest.NewTempServerWithCleanupAndBasicAuth(t, "testdata/testserver/*.*")
+	defer srv.Stop()
+	if _, err := srv.CopyCharts("testdata/testcharts/compressedchart-0.1.0.tgz"); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpdir := ensure.TempDir(t)
+	repoFile := filepath.Join(tmpdir, "repositories.yaml")
+	flags := fmt.Sprintf("--repository-config %s --repository-cache %s", repoFile, tmpdir)
+
+	if _, _, err := executeActionCommand(fmt.Sprintf("repo add private %s --username username --password password --credential-store helmtest %s", srv.URL(), flags)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := repo.LoadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := f.Get("private")
+	if entry.Username != "" || entry.Password != "" || entry.CredentialStore != "helmtest" {
+		t.Errorf("expected the credentials to be in the credential store helmtest, got %+v", entry)
+	}
+	if username, password, err := entry.Credentials(); err != nil || username != "username" || password != "password" {
+		t.Errorf("expected the stored credentials, got %q, %q, %v", username, password, err)
+	}
+
+	// The index is downloaded with the stored credentials, which the server
+	// checks.
+	if _, _, err := executeActionCommand(fmt.Sprintf("repo update private %s", flags)); err != nil {
+		t.Fatal(err)
+	}
+	// So is a chart pulled by its URL in the repository.
+	if _, _, err := executeActionCommand(fmt.Sprintf("pull %s/compressedchart-0.1.0.tgz -d %s %s", srv.URL(), tmpdir, flags)); err != nil {
+		t.Fatal(err)
+	}
+	_, out, err := executeActionCommand(fmt.Sprintf("repo add private %s --username username --password password --credential-store helmtest %s", srv.URL(), flags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "already exists with the same configuration") {
+		t.Errorf("expected the repository to be unchanged, got %s", out)
+	}
+
+	if _, _, err := executeActionCommand(fmt.Sprintf("repo remove private %s", flags)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(store); len(files) != 0 {
+		t.Errorf("expected the credentials to be erased from the credential store, got %d", len(files))
+	}
+}
+
+func TestRepoMigrateCredentials(t *testing.T) {
+	fakeCredentialHelper(t)
+
+	tmpdir := ensure.TempDir(t)
+	repoFile := filepath.Join(tmpdir, "repositories.yaml")
+	f := repo.NewFile()
+	f.Add(
+		&repo.Entry{Name: "private", URL: "https://charts.example.com/private", Username: "admin", Password: "s3cret"},
+		&repo.Entry{Name: "public", URL: "https://charts.example.com/public"},
+	)
+	if err := f.WriteFile(repoFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeActionCommand(fmt.Sprintf("repo migrate-credentials missing --credential-store helmtest --repository-config %s", repoFile)); err == nil || !strings.Contains(err.Error(), `no repo named "missing" found`) {
+		t.Errorf("expected an error for a missing repository, got %v", err)
+	}
+
+	_, out, err := executeActionCommand(fmt.Sprintf("repo migrate-credentials --credential-store helmtest --repository-config %s", repoFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "The credentials of \"private\" have been moved to the credential store \"helmtest\"\n"; out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	data, err := ioutil.ReadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("expected the password to be removed from the repositories file, got\n%s", data)
+	}
+	f, err = repo.LoadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username, password, err := f.Get("private").Credentials(); err != nil || username != "admin" || password != "s3cret" {
+		t.Errorf("expected the migrated credentials, got %q, %q, %v", username, password, err)
+	}
+	if f.Get("public").CredentialStore != "" {
+		t.Error("expected the repository without credentials to be left as it is")
+	}
+
+	_, out, err = executeActionCommand(fmt.Sprintf("repo migrate-credentials --credential-store helmtest --repository-config %s", repoFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "No credentials to migrate\n" {
+		t.Errorf("expected nothing to migrate, got %q", out)
+	}
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -26,6 +25,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/helmpath"
 	"github.com/open-hand/helm/pkg/repo"
 )
@@ -34,6 +34,8 @@ type repoRemoveOptions struct {
 	names     []string
 	repoFile  string
 	repoCache string
+	lock      repo.LockOptions
+	outfmt    output.Format
 }
 
 func newRepoRemoveCmd(out io.Writer) *cobra.Command {
@@ -54,30 +56,47 @@ func newRepoRemoveCmd(out io.Writer) *cobra.Command {
 			return o.run(out)
 		},
 	}
+
+	addRepoLockFlags(cmd.Flags(), &o.lock)
+	bindOutputFlag(cmd, &o.outfmt)
 	return cmd
 }
 
 func (o *repoRemoveOptions) run(out io.Writer) error {
-	r, err := repo.LoadFile(o.repoFile)
-	if isNotExist(err) || len(r.Repositories) == 0 {
-		return errors.New("no repositories configured")
-	}
-
-	for _, name := range o.names {
-		if !r.Remove(name) {
-			return errors.Errorf("no repo named %q found", name)
+	// The repositories removed before a failure are written and reported
+	// with it.
+	var changes []repoChange
+	var removeErr error
+	err := repo.UpdateFile(o.repoFile, o.lock, func(r *repo.File) error {
+		if len(r.Repositories) == 0 {
+			return errors.New("no repositories configured")
 		}
-		if err := r.WriteFile(o.repoFile, 0644); err != nil {
-			return err
+		for _, name := range o.names {
+			entry := r.Get(name)
+			if !r.Remove(name) {
+				removeErr = errors.Errorf("no repo named %q found", name)
+				break
+			}
+			if err := entry.EraseCredentials(); err != nil {
+				warning("%s", err)
+			}
+			changes = append(changes, repoChange{Name: name, Status: repoRemoved})
 		}
-
-		if err := removeRepoCache(o.repoCache, name); err != nil {
-			return err
+		if len(changes) == 0 {
+			return removeErr
 		}
-		fmt.Fprintf(out, "%q has been removed from your repositories\n", name)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return nil
+	for _, c := range changes {
+		if err := removeRepoCache(o.repoCache, c.Name); err != nil && removeErr == nil {
+			removeErr = err
+		}
+	}
+	return writeRepoChanges(out, o.outfmt, changes, removeErr)
 }
 
 func removeRepoCache(root, name string) error {
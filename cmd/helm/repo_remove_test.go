@@ -215,3 +215,34 @@ func TestRepoRemoveFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "repo remove", false)
 	checkFileCompletion(t, "repo remove repo1", false)
 }
+
+func TestRepoRemoveOutput(t *testing.T) {
+	rootDir := ensure.TempDir(t)
+	repoFile := filepath.Join(rootDir, "repositories.yaml")
+	f := repo.NewFile()
+	f.Add(&repo.Entry{Name: "foo", URL: "https://example.com/foo"}, &repo.Entry{Name: "bar", URL: "https://example.com/bar"})
+	if err := f.WriteFile(repoFile, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repositories removed before the failure are reported with it.
+	cmd := fmt.Sprintf("repo remove foo missing bar -o json --repository-config %s --repository-cache %s", repoFile, rootDir)
+	c, _, err := executeActionCommand(cmd)
+	if err == nil {
+		t.Fatal("expected an error removing a missing repository")
+	}
+	var out bytes.Buffer
+	writeErrorOutput(c, &out, err)
+	expect := "{\"error\":\"no repo named \\\"missing\\\" found\",\"result\":[{\"name\":\"foo\",\"status\":\"removed\"}]}\n"
+	if out.String() != expect {
+		t.Errorf("expected %q, got %q", expect, out.String())
+	}
+
+	f, err = repo.LoadFile(repoFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Has("foo") || !f.Has("bar") {
+		t.Errorf("expected only foo to be removed, got %v", f.Repositories)
+	}
+}
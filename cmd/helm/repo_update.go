@@ -19,12 +19,16 @@ package main
 import (
 	"fmt"
 	"io"
+	"log"
 	"sync"
+	"time"
 
+	"github.com/gosuri/uitable"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/repo"
 )
@@ -33,9 +37,20 @@ const updateDesc = `
 Update gets the latest information about charts from the respective chart repositories.
 Information is cached locally, where it is used by commands like 'helm search'.
 
-You can optionally specify a list of repositories you want to update.
+You can optionally specify a list of repositories you want to update, as
+arguments or with '--repo'.
 	$ helm repo update <repo_name> ...
+	$ helm repo update --repo <repo_name> --repo <repo_name>
 To update all the repositories, use 'helm repo update'.
+
+The outcome of the update of each repository is reported with its duration.
+By default, the command succeeds even if repositories fail to update; with
+'--fail-on-repo-update-fail', it fails if any does.
+With '--output json' or '--output yaml', a summary of the updates is printed
+instead:
+
+	$ helm repo update -o json
+	{"repositories":[{"name":"stable","url":"https://charts.example.com","status":"updated","duration":"412ms"}],"updated":1,"failed":0}
 `
 
 var errNoRepositories = errors.New("no repositories found. You must add one before updating")
@@ -46,10 +61,38 @@ type repoUpdateOptions struct {
 	repoCache            string
 	names                []string
 	failOnRepoUpdateFail bool
+	outfmt               output.Format
+}
+
+// repoUpdateStatus is the outcome of the update of a repository.
+type repoUpdateStatus string
+
+const (
+	repoUpdated      repoUpdateStatus = "updated"
+	repoUpdateFailed repoUpdateStatus = "failed"
+)
+
+// repoUpdateResult is the outcome of the update of a repository.
+type repoUpdateResult struct {
+	Name   string           `json:"name"`
+	URL    string           `json:"url"`
+	Status repoUpdateStatus `json:"status"`
+	// Duration is the rounded duration of the update, such as "412ms".
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// repoUpdateSummary is the outcome of the update of the repositories, in the
+// order of the repositories file.
+type repoUpdateSummary struct {
+	Repositories []repoUpdateResult `json:"repositories"`
+	Updated      int                `json:"updated"`
+	Failed       int                `json:"failed"`
 }
 
 func newRepoUpdateCmd(out io.Writer) *cobra.Command {
 	o := &repoUpdateOptions{update: updateCharts}
+	var repos []string
 
 	cmd := &cobra.Command{
 		Use:     "update [REPO1 [REPO2 ...]]",
@@ -63,7 +106,7 @@ func newRepoUpdateCmd(out io.Writer) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			o.repoFile = settings.RepositoryConfig
 			o.repoCache = settings.RepositoryCache
-			o.names = args
+			o.names = append(args, repos...)
 			return o.run(out)
 		},
 	}
@@ -73,6 +116,15 @@ func newRepoUpdateCmd(out io.Writer) *cobra.Command {
 	// Adding this flag for Helm 3 as stop gap functionality for https://github.com/helm/helm/issues/10016.
 	// This should be deprecated in Helm 4 by update to the behaviour of `helm repo update` command.
 	f.BoolVar(&o.failOnRepoUpdateFail, "fail-on-repo-update-fail", false, "update fails if any of the repository updates fail")
+	f.StringSliceVar(&repos, "repo", nil, "update only the repository with this name (can specify multiple)")
+	bindOutputFlag(cmd, &o.outfmt)
+
+	err := cmd.RegisterFlagCompletionFunc("repo", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return compListRepos(toComplete, repos), cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	return cmd
 }
@@ -111,36 +163,138 @@ func (o *repoUpdateOptions) run(out io.Writer) error {
 		}
 	}
 
-	return o.update(repos, out, o.failOnRepoUpdateFail)
+	switch o.outfmt {
+	case output.JSON, output.YAML:
+		err = writeRepoUpdateSummary(repos, out, o.outfmt, o.failOnRepoUpdateFail)
+	default:
+		err = o.update(repos, out, o.failOnRepoUpdateFail)
+	}
+	if err != nil {
+		return err
+	}
+	if o.repoCache != "" {
+		// Index the refreshed repositories now so that the next search
+		// does not have to.
+		updateSearchCache(f, o.repoCache)
+	}
+	return nil
 }
 
 func updateCharts(repos []*repo.ChartRepository, out io.Writer, failOnRepoUpdateFail bool) error {
 	fmt.Fprintln(out, "Hang tight while we grab the latest from your chart repositories...")
+	var mu sync.Mutex
+	results := updateRepos(repos, func(r repoUpdateResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Status == repoUpdateFailed {
+			fmt.Fprintf(out, "...Unable to get an update from the %q chart repository (%s) after %s:\n\t%s\n", r.Name, r.URL, r.Duration, r.Error)
+		} else {
+			fmt.Fprintf(out, "...Successfully got an update from the %q chart repository in %s\n", r.Name, r.Duration)
+		}
+	})
+
+	if err := repoUpdateError(results); err != nil && failOnRepoUpdateFail {
+		return err
+	}
+
+	fmt.Fprintln(out, "Update Complete. ⎈Happy Helming!⎈")
+	return nil
+}
+
+// writeRepoUpdateSummary updates the repositories and writes the summary of
+// the updates in the format outfmt.
+func writeRepoUpdateSummary(repos []*repo.ChartRepository, out io.Writer, outfmt output.Format, failOnRepoUpdateFail bool) error {
+	summary := repoUpdateSummary{Repositories: updateRepos(repos, nil)}
+	for _, r := range summary.Repositories {
+		if r.Status == repoUpdated {
+			summary.Updated++
+		} else {
+			summary.Failed++
+		}
+	}
+	var err error
+	if failOnRepoUpdateFail {
+		err = repoUpdateError(summary.Repositories)
+	}
+	return writeResult(out, outfmt, &repoUpdateWriter{summary}, err)
+}
+
+// updateRepos downloads the indexes of the repositories concurrently, calling
+// done, if not nil, with the outcome of each update as it completes. The
+// outcomes are returned in the order of repos.
+func updateRepos(repos []*repo.ChartRepository, done func(repoUpdateResult)) []repoUpdateResult {
+	results := make([]repoUpdateResult, len(repos))
 	var wg sync.WaitGroup
-	var repoFailList []string
-	for _, re := range repos {
+	for i, re := range repos {
 		wg.Add(1)
-		go func(re *repo.ChartRepository) {
+		go func(i int, re *repo.ChartRepository) {
 			defer wg.Done()
-			if _, _, err := re.DownloadIndexFile(); err != nil {
-				fmt.Fprintf(out, "...Unable to get an update from the %q chart repository (%s):\n\t%s\n", re.Config.Name, re.Config.URL, err)
-				repoFailList = append(repoFailList, re.Config.URL)
-			} else {
-				fmt.Fprintf(out, "...Successfully got an update from the %q chart repository\n", re.Config.Name)
+			start := time.Now()
+			_, _, err := re.DownloadIndexFile()
+			r := repoUpdateResult{
+				Name:     re.Config.Name,
+				URL:      re.Config.URL,
+				Status:   repoUpdated,
+				Duration: roundDuration(time.Since(start)).String(),
+			}
+			if err != nil {
+				r.Status, r.Error = repoUpdateFailed, err.Error()
+			}
+			results[i] = r
+			if done != nil {
+				done(r)
 			}
-		}(re)
+		}(i, re)
 	}
 	wg.Wait()
+	return results
+}
 
-	if len(repoFailList) > 0 && failOnRepoUpdateFail {
+// repoUpdateError returns an error listing the URLs of the repositories that
+// failed to update, if any.
+func repoUpdateError(results []repoUpdateResult) error {
+	var repoFailList []string
+	for _, r := range results {
+		if r.Status == repoUpdateFailed {
+			repoFailList = append(repoFailList, r.URL)
+		}
+	}
+	if len(repoFailList) > 0 {
 		return fmt.Errorf("Failed to update the following repositories: %s",
 			repoFailList)
 	}
-
-	fmt.Fprintln(out, "Update Complete. ⎈Happy Helming!⎈")
 	return nil
 }
 
+// roundDuration rounds the duration of an update for display.
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Millisecond)
+}
+
+type repoUpdateWriter struct {
+	summary repoUpdateSummary
+}
+
+func (w *repoUpdateWriter) WriteTable(out io.Writer) error {
+	tbl := uitable.New()
+	tbl.AddRow("NAME", "URL", "STATUS", "DURATION")
+	for _, r := range w.summary.Repositories {
+		tbl.AddRow(r.Name, r.URL, r.Status, r.Duration)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *repoUpdateWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.summary)
+}
+
+func (w *repoUpdateWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.summary)
+}
+
 func checkRequestedRepos(requestedRepos []string, validRepos []*repo.Entry) error {
 	for _, requestedRepo := range requestedRepos {
 		found := false
@@ -17,6 +17,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,8 +25,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/repo"
 	"github.com/open-hand/helm/pkg/repo/repotest"
@@ -238,3 +241,81 @@ func TestUpdateChartsFailWithError(t *testing.T) {
 		t.Error("Update was not successful and should return error message because 'fail-on-repo-update-fail' flag set")
 	}
 }
+
+func TestUpdateChartsSummary(t *testing.T) {
+	defer resetEnv()()
+	defer ensure.HelmHome(t)()
+
+	ts, err := repotest.NewTempServerWithCleanup(t, "testdata/testserver/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Stop()
+
+	var repos []*repo.ChartRepository
+	for _, e := range []*repo.Entry{
+		{Name: "charts", URL: ts.URL()},
+		{Name: "broken", URL: ts.URL() + "55"},
+	} {
+		r, err := repo.NewChartRepository(e, getter.All(settings))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, r)
+	}
+
+	b := bytes.NewBuffer(nil)
+	if err := writeRepoUpdateSummary(repos, b, output.JSON, false); err != nil {
+		t.Fatalf("Repo update should not return error if update of repository fails: %s", err)
+	}
+	var summary repoUpdateSummary
+	if err := json.Unmarshal(b.Bytes(), &summary); err != nil {
+		t.Fatalf("invalid summary %q: %s", b.String(), err)
+	}
+	if summary.Updated != 1 || summary.Failed != 1 || len(summary.Repositories) != 2 {
+		t.Fatalf("expected 1 updated and 1 failed repository, got %+v", summary)
+	}
+	if r := summary.Repositories[0]; r.Name != "charts" || r.Status != repoUpdated || r.Error != "" {
+		t.Errorf("expected the first repository to be updated, got %+v", r)
+	}
+	if _, err := time.ParseDuration(summary.Repositories[0].Duration); err != nil {
+		t.Errorf("expected the duration of the update as a duration string: %s", err)
+	}
+	if r := summary.Repositories[1]; r.Name != "broken" || r.Status != repoUpdateFailed || r.Error == "" {
+		t.Errorf("expected the second repository to fail, got %+v", r)
+	}
+
+	err = writeRepoUpdateSummary(repos, ioutil.Discard, output.JSON, true)
+	if err == nil || !strings.Contains(err.Error(), ts.URL()+"55") {
+		t.Errorf("expected an error listing the broken repository, got %v", err)
+	}
+}
+
+func TestUpdateCmdRepoFlag(t *testing.T) {
+	defer resetEnv()()
+	defer ensure.HelmHome(t)()
+
+	ts, err := repotest.NewTempServerWithCleanup(t, "testdata/testserver/*.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Stop()
+
+	cmd := fmt.Sprintf("repo update --repo test -o json --repository-config %s --repository-cache %s",
+		filepath.Join(ts.Root(), "repositories.yaml"), ensure.TempDir(t))
+	_, out, err := executeActionCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary repoUpdateSummary
+	if err := json.Unmarshal([]byte(out), &summary); err != nil {
+		t.Fatalf("invalid summary %q: %s", out, err)
+	}
+	if summary.Updated != 1 || len(summary.Repositories) != 1 || summary.Repositories[0].Name != "test" {
+		t.Errorf("expected the test repository to be updated, got %+v", summary)
+	}
+
+	if _, _, err := executeActionCommand(cmd + " --repo missing"); err == nil {
+		t.Error("expected an error updating a missing repository")
+	}
+}
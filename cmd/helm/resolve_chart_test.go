@@ -0,0 +1,156 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/repo/repotest"
+)
+
+// TestResolveChart covers action.ResolveChart against the chart fixtures of
+// the command line tests.
+func TestResolveChart(t *testing.T) {
+	srv, err := repotest.NewTempServerWithCleanup(t, "testdata/testcharts/*.tgz*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	if err := srv.LinkIndices(); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := cli.New()
+	settings.RepositoryConfig = filepath.Join(srv.Root(), "repositories.yaml")
+	settings.RepositoryCache = srv.Root()
+
+	localArchive, err := filepath.Abs("testdata/testcharts/compressedchart-0.1.0.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localDir, err := filepath.Abs("testdata/testcharts/alpine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ref        string
+		opts       action.ChartPathOptions
+		source     action.ChartSource
+		path       string
+		repository string
+		repoName   string
+		version    string
+		digest     bool
+		wantErr    string
+	}{{
+		name:    "local chart directory",
+		ref:     "testdata/testcharts/alpine",
+		source:  action.ChartSourceLocal,
+		path:    localDir,
+		version: "0.1.0",
+	}, {
+		name:    "local chart archive",
+		ref:     "testdata/testcharts/compressedchart-0.1.0.tgz",
+		source:  action.ChartSourceLocal,
+		path:    localArchive,
+		version: "0.1.0",
+		digest:  true,
+	}, {
+		name:    "missing local path",
+		ref:     "./testdata/testcharts/nosuchchart",
+		wantErr: "not found",
+	}, {
+		name:       "repository reference",
+		ref:        "test/compressedchart",
+		source:     action.ChartSourceRepository,
+		repository: srv.URL(),
+		repoName:   "test",
+		version:    "0.3.0",
+		digest:     true,
+	}, {
+		name:       "repository reference with version",
+		ref:        "@test/compressedchart",
+		opts:       action.ChartPathOptions{Version: "0.2.0"},
+		source:     action.ChartSourceRepository,
+		repository: srv.URL(),
+		repoName:   "test",
+		version:    "0.2.0",
+		digest:     true,
+	}, {
+		name:       "chart of a repository URL",
+		ref:        "compressedchart",
+		opts:       action.ChartPathOptions{RepoURL: srv.URL(), Version: "0.1.0"},
+		source:     action.ChartSourceRepository,
+		repository: srv.URL(),
+		version:    "0.1.0",
+		digest:     true,
+	}, {
+		name:    "chart URL",
+		ref:     srv.URL() + "/signtest-0.1.0.tgz",
+		source:  action.ChartSourceURL,
+		version: "0.1.0",
+		digest:  true,
+	}, {
+		name:    "unknown repository",
+		ref:     "nosuchrepo/compressedchart",
+		wantErr: "repo nosuchrepo not found",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.opts.ResolveChart(context.Background(), tt.ref, settings)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Source != tt.source {
+				t.Errorf("expected source %q, got %q", tt.source, res.Source)
+			}
+			if tt.path != "" && res.Path != tt.path {
+				t.Errorf("expected path %q, got %q", tt.path, res.Path)
+			}
+			if !filepath.IsAbs(res.Path) {
+				t.Errorf("expected an absolute path, got %q", res.Path)
+			}
+			if res.Repository != tt.repository || res.RepositoryName != tt.repoName {
+				t.Errorf("expected repository %q (%q), got %q (%q)", tt.repository, tt.repoName, res.Repository, res.RepositoryName)
+			}
+			if res.Version != tt.version {
+				t.Errorf("expected version %q, got %q", tt.version, res.Version)
+			}
+			if tt.digest != strings.HasPrefix(res.Digest, "sha256:") {
+				t.Errorf("unexpected digest %q", res.Digest)
+			}
+		})
+	}
+
+	if _, err := action.ResolveChart(context.Background(), "test/signtest", settings); err != nil {
+		t.Errorf("expected the default options to resolve a repository reference: %s", err)
+	}
+}
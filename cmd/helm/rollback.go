@@ -22,10 +22,12 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/gosuri/uitable"
 	"github.com/spf13/cobra"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
 )
 
 const rollbackDesc = `
@@ -36,10 +38,16 @@ second is a revision (version) number. If this argument is omitted, it will
 roll back to the previous release.
 
 To see revision numbers, run 'helm history RELEASE'.
+
+With '--dry-run', nothing is rolled back and the changes the rollback would
+make are shown instead: the resources it would add, change or delete, and the
+diffs of the manifests and of the user-supplied values of the current and the
+target revisions.
 `
 
 func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewRollback(cfg)
+	var outfmt output.Format
 
 	cmd := &cobra.Command{
 		Use:   "rollback <RELEASE> [REVISION]",
@@ -66,12 +74,30 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				client.Version = ver
 			}
 
+			if client.DryRun {
+				diff, err := client.Diff(args[0])
+				if err != nil {
+					return err
+				}
+				return outfmt.Write(out, &rollbackWriter{rollbackResult{Name: args[0], DryRun: true, Diff: diff}})
+			}
+
 			if err := client.Run(args[0]); err != nil {
 				return err
 			}
+			warnCrossNamespace(client.CrossNamespaceResources)
 
-			fmt.Fprintf(out, "Rollback was a success! Happy Helming!\n")
-			return nil
+			result := rollbackResult{Name: args[0], DryRun: client.DryRun}
+			if !client.DryRun {
+				rel, err := cfg.Releases.Last(args[0])
+				if err != nil {
+					return err
+				}
+				result.Revision = rel.Version
+				result.Status = rel.Info.Status.String()
+				result.Description = rel.Info.Description
+			}
+			return outfmt.Write(out, &rollbackWriter{result})
 		},
 	}
 
@@ -85,6 +111,71 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this rollback when rollback fails")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
+	bindMigrateAPIsFlag(cmd, &client.MigrateAPIs)
+	bindWaitStrategyFlag(cmd, &client.WaitStrategy)
+	bindApplyConcurrencyFlag(cmd, &client.ApplyConcurrency)
+	bindServerSideApplyFlags(cmd, &cfg.ApplyOptions)
+	bindNamespacePolicyFlags(cmd, &cfg.NamespacePolicy)
+	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
 }
+
+type rollbackResult struct {
+	Name        string `json:"name"`
+	Revision    int    `json:"revision,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Description string `json:"description,omitempty"`
+	DryRun      bool   `json:"dryRun,omitempty"`
+	// Diff is what the rollback would change, for dry runs.
+	Diff *action.RollbackDiff `json:"diff,omitempty"`
+}
+
+type rollbackWriter struct {
+	result rollbackResult
+}
+
+func (w *rollbackWriter) WriteTable(out io.Writer) error {
+	if d := w.result.Diff; d != nil {
+		return writeRollbackDiff(out, d)
+	}
+	_, err := fmt.Fprintf(out, "Rollback was a success! Happy Helming!\n")
+	return err
+}
+
+// writeRollbackDiff writes the changes a rollback would make.
+func writeRollbackDiff(out io.Writer, d *action.RollbackDiff) error {
+	fmt.Fprintf(out, "RELEASE: %s\n", d.Release)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", d.Namespace)
+	fmt.Fprintf(out, "OPERATION: rollback (revision %d -> %d)\n", d.CurrentRevision, d.TargetRevision)
+	fmt.Fprintf(out, "CHART: %s -> %s\n", d.CurrentChart, d.TargetChart)
+
+	if len(d.Resources) == 0 {
+		fmt.Fprintln(out, "RESOURCES: no change")
+	} else {
+		fmt.Fprintln(out, "RESOURCES:")
+		tbl := uitable.New()
+		tbl.AddRow("CHANGE", "KIND", "NAMESPACE", "NAME")
+		for _, r := range d.Resources {
+			tbl.AddRow(r.Change, r.Kind, r.Namespace, r.Name)
+		}
+		if err := output.EncodeTable(out, tbl); err != nil {
+			return err
+		}
+	}
+	if d.Values != "" {
+		fmt.Fprintf(out, "VALUES:\n%s", d.Values)
+	}
+	if d.Manifest != "" {
+		fmt.Fprintf(out, "MANIFEST:\n%s", d.Manifest)
+	}
+	return nil
+}
+
+func (w *rollbackWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.result)
+}
+
+func (w *rollbackWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.result)
+}
@@ -17,10 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
 )
 
 func TestRollbackCmd(t *testing.T) {
@@ -64,6 +69,11 @@ func TestRollbackCmd(t *testing.T) {
 		cmd:    "rollback funny-honey",
 		golden: "output/rollback-no-revision.txt",
 		rels:   rels,
+	}, {
+		name:   "rollback a release with json output",
+		cmd:    "rollback funny-honey 1 -o json",
+		golden: "output/rollback-json.txt",
+		rels:   rels,
 	}, {
 		name:      "rollback a release without release name",
 		cmd:       "rollback",
@@ -74,6 +84,39 @@ func TestRollbackCmd(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestRollbackDryRun(t *testing.T) {
+	revision := func(version int, status release.Status, chartVersion string, replicas int, manifest string) *release.Release {
+		return &release.Release{
+			Name:      "funny-honey",
+			Namespace: "default",
+			Info:      &release.Info{Status: status},
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "honey", Version: chartVersion}},
+			Config:    map[string]interface{}{"replicas": replicas},
+			Version:   version,
+			Manifest:  manifest,
+		}
+	}
+	service := "---\n# Source: honey/templates/service.yaml\napiVersion: v1\nkind: Service\nmetadata:\n  name: honey\n"
+	configMap := "---\n# Source: honey/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: honey\ndata:\n  color: %s\n"
+	rels := []*release.Release{
+		revision(1, release.StatusSuperseded, "0.1.0", 1, service+fmt.Sprintf(configMap, "yellow")),
+		revision(2, release.StatusDeployed, "0.2.0", 3, fmt.Sprintf(configMap, "orange")),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "preview a rollback",
+		cmd:    "rollback funny-honey 1 --dry-run",
+		golden: "output/rollback-dry-run.txt",
+		rels:   rels,
+	}, {
+		name:   "preview a rollback with json output",
+		cmd:    "rollback funny-honey --dry-run -o json",
+		golden: "output/rollback-dry-run.json",
+		rels:   rels,
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestRollbackRevisionCompletion(t *testing.T) {
 	mk := func(name string, vers int, status release.Status) *release.Release {
 		return release.Mock(&release.MockReleaseOptions{
@@ -110,8 +153,96 @@ func TestRollbackRevisionCompletion(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestRollbackOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "rollback")
+}
+
 func TestRollbackFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "rollback", false)
 	checkFileCompletion(t, "rollback myrelease", false)
 	checkFileCompletion(t, "rollback myrelease 1", false)
 }
+
+func TestRollbackFreezeWindow(t *testing.T) {
+	defer resetEnv()()
+
+	now := testTimestamper().Time
+	freeze := &action.FreezeWindow{
+		Start:      now.Add(-time.Hour),
+		End:        now.Add(time.Hour),
+		Namespaces: []string{"default"},
+		Operations: []action.OperationKind{action.OperationUpgrade, action.OperationUninstall},
+		Reason:     "release freeze",
+	}
+	runner := *cmdRunner
+	runner.Configure = func(cfg *action.Configuration) {
+		cfg.OperationPolicies = []action.OperationPolicy{freeze}
+	}
+
+	newStore := func(namespace string) *storage.Storage {
+		store := storageFixture()
+		for v, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+			rel := release.Mock(&release.MockReleaseOptions{Name: "funny-honey", Version: v + 1, Status: status, Namespace: namespace})
+			if err := store.Create(rel); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return store
+	}
+
+	for _, tt := range []struct {
+		cmd       string
+		namespace string
+		reject    bool
+	}{
+		{"uninstall funny-honey", "default", true},
+		{"uninstall funny-honey --dry-run", "default", false},
+		{"rollback funny-honey 1", "default", false},
+		{"uninstall funny-honey --namespace other", "other", false},
+	} {
+		_, _, err := runner.Execute(newStore(tt.namespace), nil, tt.cmd)
+		if tt.reject {
+			expect := fmt.Sprintf(`uninstall of release "funny-honey" in namespace "default" rejected: release freeze from %s to %s`,
+				freeze.Start.Format(time.RFC3339), freeze.End.Format(time.RFC3339))
+			if err == nil || !strings.Contains(err.Error(), expect) {
+				t.Errorf("%q: expected the error %q, got %v", tt.cmd, expect, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: expected no error, got %v", tt.cmd, err)
+		}
+	}
+}
+
+func TestRollbackNamespacePolicy(t *testing.T) {
+	defer resetEnv()()
+
+	store := storageFixture()
+	for v, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+		rel := release.Mock(&release.MockReleaseOptions{Name: "tenant", Version: v + 1, Status: status})
+		if v == 0 {
+			rel.Manifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tenant-shared
+  namespace: shared-config
+`
+		}
+		if err := store.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err := executeActionCommandC(store, "rollback tenant 1 --namespace-policy block")
+	if !action.IsCrossNamespace(err) {
+		t.Fatalf("expected a cross namespace error, got %v", err)
+	}
+	if last, _ := store.Last("tenant"); last.Version != 2 {
+		t.Errorf("expected the rollback not to be recorded, got revision %d", last.Version)
+	}
+
+	if _, _, err := executeActionCommandC(store, "rollback tenant 1 --namespace-policy block --allow-namespace shared-config"); err != nil {
+		t.Error(err)
+	}
+}
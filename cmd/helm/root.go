@@ -51,15 +51,27 @@ Environment variables:
 | $HELM_CONFIG_HOME                  | set an alternative location for storing Helm configuration.                       |
 | $HELM_DATA_HOME                    | set an alternative location for storing Helm data.                                |
 | $HELM_DEBUG                        | indicate whether or not Helm is running in Debug mode                             |
-| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql.       |
+| $HELM_LOG_LEVEL                    | set the minimum level of the logged messages: debug, info, warn or error.         |
+| $HELM_LOG_FORMAT                   | set the format of the logged messages: text or json.                              |
+| $HELM_DRIVER                       | set the storage driver: configmap, secret, memory, sql or plugin:NAME.            |
+| $HELM_DRIVER_NAMESPACE             | set the namespace the secret and configmap drivers keep the release records in.   |
 | $HELM_DRIVER_SQL_CONNECTION_STRING | set the connection string the SQL storage driver should use.                      |
+| $HELM_DRIVER_COMPRESSION           | set the compression of new release records. Values are: gzip, zstd.               |
+| $HELM_DRIVER_ENCRYPTION_KEY        | set the base64 encoded AES key used to encrypt release records.                   |
+| $HELM_DRIVER_ENCRYPTION_KEY_FILE   | set the path to a file holding the base64 encoded release encryption key.         |
+| $HELM_WAIT_IGNORE_KINDS            | set the custom resource kinds --wait considers ready regardless of their status.  |
+| $HELM_WAIT_STRATEGY                | set how --wait checks the resources: watch (default) or poll.                     |
 | $HELM_MAX_HISTORY                  | set the maximum number of helm release history.                                   |
 | $HELM_NAMESPACE                    | set the namespace used for the helm operations.                                   |
 | $HELM_NO_PLUGINS                   | disable plugins. Set HELM_NO_PLUGINS=1 to disable plugins.                        |
 | $HELM_PLUGINS                      | set the path to the plugins directory                                             |
+| $HELM_PROJECT_CONFIG               | set the path to the project configuration file, empty to disable its discovery.   |
 | $HELM_REGISTRY_CONFIG              | set the path to the registry config file.                                         |
+| $HELM_REGISTRY_MIRRORS_CONFIG      | set the path to the file mapping the registries to their mirrors.                 |
 | $HELM_REPOSITORY_CACHE             | set the path to the repository cache directory                                    |
 | $HELM_REPOSITORY_CONFIG            | set the path to the repositories file.                                            |
+| $HELM_STRICT_CREDENTIALS           | only send repository credentials to URLs under the repository URL.                |
+| $HELM_CREDENTIAL_AUDIT_LOG         | set the path to the file logging the requests credentials are sent with.          |
 | $KUBECONFIG                        | set an alternative Kubernetes configuration file (default "~/.kube/config")       |
 | $HELM_KUBEAPISERVER                | set the Kubernetes API Server Endpoint for authentication                         |
 | $HELM_KUBECAFILE                   | set the Kubernetes certificate authority file.                                    |
@@ -67,6 +79,21 @@ Environment variables:
 | $HELM_KUBEASUSER                   | set the Username to impersonate for the operation.                                |
 | $HELM_KUBECONTEXT                  | set the name of the kubeconfig context.                                           |
 | $HELM_KUBETOKEN                    | set the Bearer KubeToken used for authentication.                                 |
+| $HELM_KUBEQPS                      | set the maximum number of queries per second to the Kubernetes API.               |
+| $HELM_KUBEBURST                    | set the maximum number of queries sent at once to the Kubernetes API.             |
+| $HELM_KUBEADAPTIVERATELIMIT        | lower the rate of the queries when the Kubernetes API throttles them.             |
+
+A project configuration file, .helmrc or helm.yaml, found in the working directory
+or its parents can set the default namespace, kube-context, repository aliases usable
+with --repo and values files of the commands run in the project. Environment variables
+and flags take precedence over it:
+
+    namespace: team-a
+    kubeContext: staging
+    repoAliases:
+      internal: https://charts.example.com/internal/
+    valuesFiles:
+      - deploy/common-values.yaml
 
 Helm stores cache, configuration, and data based on the following configuration order:
 
@@ -149,11 +176,17 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 	flags.ParseErrorsWhitelist.UnknownFlags = true
 	flags.Parse(args)
 
+	mirrors, err := registry.LoadRegistriesConfig(settings.RegistryMirrorsConfig)
+	if err != nil {
+		return nil, err
+	}
 	registryClient, err := registry.NewClient(
 		registry.ClientOptDebug(settings.Debug),
 		registry.ClientOptEnableCache(true),
 		registry.ClientOptWriter(out),
 		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptCredentialProvider(pluginCredentialProvider()),
+		registry.ClientOptMirrors(mirrors),
 	)
 	if err != nil {
 		return nil, err
@@ -163,6 +196,8 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 	// Add subcommands
 	cmd.AddCommand(
 		// chart commands
+		newBundleCmd(actionConfig, out),
+		newChartCmd(actionConfig, out),
 		newCreateCmd(out),
 		newDependencyCmd(actionConfig, out),
 		newPullCmd(actionConfig, out),
@@ -174,16 +209,23 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 		newVerifyCmd(out),
 
 		// release commands
+		newApplyCmd(actionConfig, out),
+		newCapabilitiesCmd(actionConfig, out),
+		newCheckDeprecationsCmd(actionConfig, out),
 		newGetCmd(actionConfig, out),
 		newHistoryCmd(actionConfig, out),
 		newInstallCmd(actionConfig, out),
 		newListCmd(actionConfig, out),
+		newReleaseCmd(actionConfig, out),
 		newReleaseTestCmd(actionConfig, out),
 		newRollbackCmd(actionConfig, out),
 		newStatusCmd(actionConfig, out),
+		newDaemonCmd(actionConfig, out),
+		newServeCmd(actionConfig, out),
 		newTemplateCmd(actionConfig, out),
 		newUninstallCmd(actionConfig, out),
 		newUpgradeCmd(actionConfig, out),
+		newValuesCmd(actionConfig, out),
 
 		newCompletionCmd(out),
 		newEnvCmd(out),
@@ -0,0 +1,306 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/fileutil"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+// CacheFile is the name of the search cache in the repository cache directory.
+const CacheFile = "search-index.gob"
+
+// cacheVersion is bumped whenever the layout of Cache changes, so that caches
+// written by other Helm versions are rebuilt instead of misread.
+const cacheVersion = 2
+
+// RepoIndexFile names the index file of a configured repository.
+type RepoIndexFile struct {
+	Name string
+	Path string
+}
+
+// Cache is a persistent search index over the charts of several repositories.
+//
+// It holds the searchable fields of every chart version along with a trigram
+// index of their search lines, so that searching hundreds of repositories does
+// not require parsing each of their index files.
+type Cache struct {
+	Version  int
+	Repos    []CachedRepo
+	Entries  []CacheEntry
+	Trigrams map[string][]int32
+}
+
+// CachedRepo records the state of a repository index file when it was cached.
+type CachedRepo struct {
+	Name    string
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// CacheEntry holds the searchable fields of a chart version.
+type CacheEntry struct {
+	Repo        string
+	Name        string
+	Version     string
+	AppVersion  string
+	Description string
+	Keywords    []string
+	Maintainers []string
+	Deprecated  bool
+	// Channel is the release channel declared by the chart version, if any.
+	Channel string
+	// Latest is set on the newest version of each chart.
+	Latest bool
+}
+
+// LoadCache reads a search cache from path.
+//
+// A missing, unreadable or outdated cache yields an empty cache, which is
+// filled by the next call to Update.
+func LoadCache(path string) *Cache {
+	c := &Cache{Version: cacheVersion}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	loaded := &Cache{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(loaded); err != nil || loaded.Version != cacheVersion {
+		return c
+	}
+	return loaded
+}
+
+// Save writes the cache to path.
+func (c *Cache) Save(path string) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(c); err != nil {
+		return errors.Wrap(err, "cannot encode search cache")
+	}
+	return fileutil.AtomicWriteFile(path, &b, 0644)
+}
+
+// Update brings the cache in line with the given repositories.
+//
+// Repositories whose index file changed since it was cached are parsed again,
+// and repositories that are no longer given are dropped. It reports whether
+// the cache changed, and the error of each repository whose index file could
+// not be loaded; those repositories are left out of the cache.
+func (c *Cache) Update(repos []RepoIndexFile) (bool, map[string]error) {
+	cached := make(map[string]CachedRepo, len(c.Repos))
+	for _, r := range c.Repos {
+		cached[r.Name] = r
+	}
+	entries := make(map[string][]CacheEntry, len(c.Repos))
+	for _, e := range c.Entries {
+		entries[e.Repo] = append(entries[e.Repo], e)
+	}
+
+	changed := len(repos) != len(c.Repos)
+	failed := map[string]error{}
+	var newRepos []CachedRepo
+	var newEntries []CacheEntry
+	for _, r := range repos {
+		fi, err := os.Stat(r.Path)
+		if err != nil {
+			failed[r.Name] = err
+			changed = true
+			continue
+		}
+		state := CachedRepo{Name: r.Name, Path: r.Path, Size: fi.Size(), ModTime: fi.ModTime()}
+		if old, ok := cached[r.Name]; ok && old.Path == state.Path && old.Size == state.Size && old.ModTime.Equal(state.ModTime) {
+			newRepos = append(newRepos, old)
+			newEntries = append(newEntries, entries[r.Name]...)
+			continue
+		}
+
+		changed = true
+		ind, err := repo.LoadIndexFile(r.Path)
+		if err != nil {
+			failed[r.Name] = err
+			continue
+		}
+		newRepos = append(newRepos, state)
+		newEntries = append(newEntries, cacheEntries(r.Name, ind)...)
+	}
+
+	if changed {
+		c.Version = cacheVersion
+		c.Repos = newRepos
+		c.Entries = newEntries
+		c.Trigrams = buildTrigrams(newEntries)
+	}
+	return changed, failed
+}
+
+// cacheEntries returns the cache entries of every chart version in an index.
+func cacheEntries(rname string, ind *repo.IndexFile) []CacheEntry {
+	ind.SortEntries()
+	names := make([]string, 0, len(ind.Entries))
+	for name := range ind.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var entries []CacheEntry
+	for _, name := range names {
+		for j, cv := range ind.Entries[name] {
+			e := CacheEntry{
+				Repo:        rname,
+				Name:        cv.Name,
+				Version:     cv.Version,
+				AppVersion:  cv.AppVersion,
+				Description: cv.Description,
+				Keywords:    cv.Keywords,
+				Deprecated:  cv.Deprecated,
+				Channel:     cv.Annotations[repo.ChannelAnnotation],
+				Latest:      j == 0,
+			}
+			for _, m := range cv.Maintainers {
+				if m != nil && m.Name != "" {
+					e.Maintainers = append(e.Maintainers, m.Name)
+				}
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// chartVersion rebuilds the chart version of a cache entry.
+func (e *CacheEntry) chartVersion() *repo.ChartVersion {
+	md := &chart.Metadata{
+		Name:        e.Name,
+		Version:     e.Version,
+		AppVersion:  e.AppVersion,
+		Description: e.Description,
+		Keywords:    e.Keywords,
+		Deprecated:  e.Deprecated,
+	}
+	for _, m := range e.Maintainers {
+		md.Maintainers = append(md.Maintainers, &chart.Maintainer{Name: m})
+	}
+	if e.Channel != "" {
+		md.Annotations = map[string]string{repo.ChannelAnnotation: e.Channel}
+	}
+	return &repo.ChartVersion{Metadata: md}
+}
+
+// buildTrigrams maps every trigram of the lower-cased search lines to the
+// sorted list of entries containing it.
+func buildTrigrams(entries []CacheEntry) map[string][]int32 {
+	trigrams := map[string][]int32{}
+	for id := range entries {
+		e := &entries[id]
+		line := strings.ToLower(indstr(e.Repo, e.chartVersion()))
+		seen := map[string]bool{}
+		for k := 0; k+3 <= len(line); k++ {
+			tri := line[k : k+3]
+			if seen[tri] {
+				continue
+			}
+			seen[tri] = true
+			trigrams[tri] = append(trigrams[tri], int32(id))
+		}
+	}
+	return trigrams
+}
+
+// NewIndexFromCache creates an Index over the charts of a cache.
+//
+// Literal searches of at least three characters only look at the entries
+// whose search line holds every trigram of the term.
+func NewIndexFromCache(c *Cache, all bool) *Index {
+	i := NewIndex()
+	i.keys = make([]string, len(c.Entries))
+	i.trigrams = c.Trigrams
+	for id := range c.Entries {
+		e := &c.Entries[id]
+		if !all && !e.Latest {
+			continue
+		}
+		// Note: Do not use filePath.Join since on Windows it will return \
+		//       which results in a repo name that cannot be understood.
+		key := path.Join(e.Repo, e.Name)
+		if all {
+			key += verSep + e.Version
+		}
+		cv := e.chartVersion()
+		i.keys[id] = key
+		i.lines[key] = indstr(e.Repo, cv)
+		i.charts[key] = cv
+	}
+	return i
+}
+
+// candidates returns the keys of the lines that may contain the lower-cased
+// term, and false if the term cannot be looked up in the trigram index.
+func (i *Index) candidates(term string) ([]string, bool) {
+	if i.trigrams == nil || len(term) < 3 {
+		return nil, false
+	}
+	var ids []int32
+	for k := 0; k+3 <= len(term); k++ {
+		posting := i.trigrams[term[k:k+3]]
+		if k == 0 {
+			ids = posting
+		} else {
+			ids = intersect(ids, posting)
+		}
+		if len(ids) == 0 {
+			return nil, true
+		}
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if key := i.keys[id]; key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, true
+}
+
+// intersect returns the ids present in both sorted lists.
+func intersect(a, b []int32) []int32 {
+	out := make([]int32, 0, len(a))
+	for x, y := 0, 0; x < len(a) && y < len(b); {
+		switch {
+		case a[x] < b[y]:
+			x++
+		case a[x] > b[y]:
+			y++
+		default:
+			out = append(out, a[x])
+			x++
+			y++
+		}
+	}
+	return out
+}
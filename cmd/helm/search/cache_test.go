@@ -0,0 +1,121 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+func writeTestIndex(t *testing.T, path string, entries map[string]repo.ChartVersions) {
+	t.Helper()
+	b, err := yaml.Marshal(&repo.IndexFile{APIVersion: repo.APIVersionV1, Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	testingIndex := filepath.Join(dir, "testing-index.yaml")
+	writeTestIndex(t, testingIndex, indexfileEntries)
+	ztesting := filepath.Join(dir, "ztesting-index.yaml")
+	writeTestIndex(t, ztesting, map[string]repo.ChartVersions{
+		"pinta": {{
+			URLs: []string{"http://example.com/charts/pinta-2.0.0.tgz"},
+			Metadata: &chart.Metadata{
+				Name:        "pinta",
+				Version:     "2.0.0",
+				Description: "Two ship, version two",
+				Maintainers: []*chart.Maintainer{{Name: "Columbus"}},
+			},
+		}},
+	})
+	repos := []RepoIndexFile{
+		{Name: "testing", Path: testingIndex},
+		{Name: "ztesting", Path: ztesting},
+		{Name: "missing", Path: filepath.Join(dir, "missing-index.yaml")},
+	}
+
+	cacheFile := filepath.Join(dir, CacheFile)
+	c := LoadCache(cacheFile)
+	changed, failed := c.Update(repos)
+	if !changed {
+		t.Error("expected an empty cache to change")
+	}
+	if _, ok := failed["missing"]; !ok || len(failed) != 1 {
+		t.Errorf("expected only the missing repository to fail, got %v", failed)
+	}
+	if err := c.Save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+
+	c = LoadCache(cacheFile)
+	if changed, _ := c.Update(repos[:2]); changed {
+		t.Error("expected a saved cache of unchanged repositories to be up to date")
+	}
+
+	tests := []struct {
+		query  string
+		all    bool
+		expect []string
+	}{
+		{query: "pinta", expect: []string{"testing/pinta", "ztesting/pinta"}},
+		{query: "santa-maria", all: true, expect: []string{"testing/santa-maria", "testing/santa-maria"}},
+		{query: "columbus", expect: []string{"ztesting/pinta"}},
+		{query: "ni", expect: []string{"testing/niña"}},
+		{query: "NIÑA", expect: []string{"testing/niña"}},
+		{query: "mayflower"},
+	}
+	for _, tt := range tests {
+		res, err := NewIndexFromCache(c, tt.all).Search(tt.query, 100, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SortScore(res)
+		if len(res) != len(tt.expect) {
+			t.Errorf("%q: expected %d results, got %d", tt.query, len(tt.expect), len(res))
+			continue
+		}
+		for i, r := range res {
+			if r.Name != tt.expect[i] {
+				t.Errorf("%q: expected %q at %d, got %q", tt.query, tt.expect[i], i, r.Name)
+			}
+		}
+	}
+
+	if all := NewIndexFromCache(c, false).All(); len(all) != 4 {
+		t.Errorf("expected 4 charts, got %d", len(all))
+	}
+
+	// Dropping a repository removes its charts from the cache.
+	if changed, _ := c.Update(repos[:1]); !changed {
+		t.Error("expected removing a repository to change the cache")
+	}
+	if res := NewIndexFromCache(c, false).SearchLiteral("pinta", 100); len(res) != 1 {
+		t.Errorf("expected 1 result after removing ztesting, got %d", len(res))
+	}
+}
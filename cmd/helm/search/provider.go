@@ -0,0 +1,208 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/monocular"
+	"github.com/open-hand/helm/internal/version"
+)
+
+// ChartResult is a chart found by a Provider.
+type ChartResult struct {
+	Name string `json:"name"`
+	// URL is the page of the chart in the catalog of the provider.
+	URL         string `json:"url"`
+	Version     string `json:"version"`
+	AppVersion  string `json:"app_version"`
+	Description string `json:"description"`
+	Repository  Repo   `json:"repository"`
+}
+
+// Repo is the repository of a ChartResult.
+type Repo struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Provider searches a catalog of charts, such as the Artifact Hub or an
+// internal catalog.
+type Provider interface {
+	// Search returns the charts matching the query, in the order of
+	// relevance of the provider.
+	Search(ctx context.Context, query string) ([]ChartResult, error)
+}
+
+// Provider names accepted by NewProvider.
+const (
+	// ArtifactHubProvider searches an Artifact Hub or Monocular instance.
+	ArtifactHubProvider = "artifacthub"
+	// IndexServiceProvider searches a self-hosted index aggregation service.
+	IndexServiceProvider = "index"
+)
+
+// DefaultHubEndpoint is the endpoint of the Artifact Hub.
+const DefaultHubEndpoint = "https://hub.helm.sh"
+
+// ProviderOptions configure the Provider returned by NewProvider.
+type ProviderOptions struct {
+	// Endpoint is the URL of the catalog. It defaults to DefaultHubEndpoint
+	// for the Artifact Hub.
+	Endpoint string
+	// Token is sent as a bearer token to the index service.
+	Token string
+	// Username and Password are sent with basic authentication to the index
+	// service, unless a token is given.
+	Username string
+	Password string
+	// Client sends the requests to the index service. It defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+// NewProvider returns the provider of the given name.
+func NewProvider(name string, opts ProviderOptions) (Provider, error) {
+	switch name {
+	case ArtifactHubProvider, "":
+		endpoint := opts.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultHubEndpoint
+		}
+		c, err := monocular.New(endpoint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create connection to %q", endpoint)
+		}
+		return &ArtifactHub{client: c}, nil
+	case IndexServiceProvider:
+		return NewIndexService(opts)
+	default:
+		return nil, errors.Errorf("unknown search provider %q, expected %q or %q", name, ArtifactHubProvider, IndexServiceProvider)
+	}
+}
+
+// ArtifactHub is a Provider searching an Artifact Hub instance, or any
+// endpoint implementing the Monocular search API.
+type ArtifactHub struct {
+	client *monocular.Client
+}
+
+// Search searches the hub. The query accepts the rich query options of the
+// Artifact Hub.
+func (a *ArtifactHub) Search(_ context.Context, query string) ([]ChartResult, error) {
+	results, err := a.client.Search(query)
+	if err != nil {
+		return nil, err
+	}
+	charts := make([]ChartResult, 0, len(results))
+	for _, r := range results {
+		// Backwards compatibility for Monocular
+		u := a.client.BaseURL + "/charts/" + r.ID
+
+		// Check for artifactHub compatibility
+		if r.ArtifactHub.PackageURL != "" {
+			u = r.ArtifactHub.PackageURL
+		}
+
+		charts = append(charts, ChartResult{
+			Name:        r.Attributes.Name,
+			URL:         u,
+			Version:     r.Relationships.LatestChartVersion.Data.Version,
+			AppVersion:  r.Relationships.LatestChartVersion.Data.AppVersion,
+			Description: r.Attributes.Description,
+			Repository:  Repo{Name: r.Attributes.Repo.Name, URL: r.Attributes.Repo.URL},
+		})
+	}
+	return charts, nil
+}
+
+// IndexServicePath is the path of the search API of an index service.
+const IndexServicePath = "api/v1/charts/search"
+
+// IndexService is a Provider searching a self-hosted service aggregating the
+// indexes of chart repositories, such as an internal catalog.
+//
+// The service answers GET requests on IndexServicePath, with the query in the
+// "q" parameter, with a JSON object whose "results" are the ChartResults
+// found.
+type IndexService struct {
+	endpoint *url.URL
+	opts     ProviderOptions
+}
+
+// NewIndexService returns a Provider searching the index service at
+// opts.Endpoint.
+func NewIndexService(opts ProviderOptions) (*IndexService, error) {
+	if opts.Endpoint == "" {
+		return nil, errors.New("the endpoint of the index service is required")
+	}
+	u, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid index service endpoint %q", opts.Endpoint)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.Errorf("invalid index service endpoint %q: no hostname provided", opts.Endpoint)
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &IndexService{endpoint: u, opts: opts}, nil
+}
+
+// Search queries the index service.
+func (s *IndexService) Search(ctx context.Context, query string) ([]ChartResult, error) {
+	u := *s.endpoint
+	u.Path = path.Join(u.Path, IndexServicePath)
+	u.RawQuery = url.Values{"q": {query}}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", version.GetUserAgent())
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case s.opts.Token != "":
+		req.Header.Set("Authorization", "Bearer "+s.opts.Token)
+	case s.opts.Username != "" || s.opts.Password != "":
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+
+	res, err := s.opts.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s : %s", u.String(), res.Status)
+	}
+
+	var body struct {
+		Results []ChartResult `json:"results"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, errors.Wrapf(err, "invalid response from %s", u.String())
+	}
+	return body.Results, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIndexService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/catalog/"+IndexServicePath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"results":[{"name":"nginx","url":"https://catalog.example.com/nginx","version":"1.2.3","app_version":"1.21","description":"web server for %s","repository":{"name":"internal","url":"https://charts.example.com"}}]}`, r.URL.Query().Get("q"))
+	}))
+	defer ts.Close()
+
+	p, err := NewProvider(IndexServiceProvider, ProviderOptions{Endpoint: ts.URL + "/catalog", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := p.Search(context.Background(), "web server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ChartResult{
+		Name:        "nginx",
+		URL:         "https://catalog.example.com/nginx",
+		Version:     "1.2.3",
+		AppVersion:  "1.21",
+		Description: "web server for web server",
+		Repository:  Repo{Name: "internal", URL: "https://charts.example.com"},
+	}
+	if len(results) != 1 || results[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, results)
+	}
+
+	p, err = NewProvider(IndexServiceProvider, ProviderOptions{Endpoint: ts.URL + "/catalog", Username: "user", Password: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Search(context.Background(), "nginx"); err == nil {
+		t.Error("expected an error for an unauthorized request")
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	if _, err := NewProvider(IndexServiceProvider, ProviderOptions{}); err == nil {
+		t.Error("expected an error for an index service without endpoint")
+	}
+	if _, err := NewProvider("catalog", ProviderOptions{}); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+	p, err := NewProvider("", ProviderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hub, ok := p.(*ArtifactHub); !ok || hub.client.BaseURL != DefaultHubEndpoint {
+		t.Errorf("expected the Artifact Hub by default, got %#v", p)
+	}
+}
@@ -18,7 +18,8 @@ limitations under the License.
 
 This supports building an in-memory search index based on the contents of
 multiple repositories, and then using string matching or regular expressions
-to find matches.
+to find matches. The index can be built from a Cache persisted alongside the
+repository index files, which avoids parsing every index file on each search.
 */
 package search
 
@@ -47,6 +48,11 @@ type Result struct {
 type Index struct {
 	lines  map[string]string
 	charts map[string]*repo.ChartVersion
+
+	// keys and trigrams are set on indexes built from a Cache, and narrow
+	// literal searches down to the lines that may match.
+	keys     []string
+	trigrams map[string][]int32
 }
 
 const sep = "\v"
@@ -145,7 +151,7 @@ func (i *Index) calcScore(index int, matchline string) int {
 func (i *Index) SearchLiteral(term string, threshold int) []*Result {
 	term = strings.ToLower(term)
 	buf := []*Result{}
-	for k, v := range i.lines {
+	match := func(k, v string) {
 		lk := strings.ToLower(k)
 		lv := strings.ToLower(v)
 		res := strings.Index(lv, term)
@@ -154,6 +160,15 @@ func (i *Index) SearchLiteral(term string, threshold int) []*Result {
 			buf = append(buf, &Result{Name: parts[0], Score: score, Chart: i.charts[k]})
 		}
 	}
+	if keys, ok := i.candidates(term); ok {
+		for _, k := range keys {
+			match(k, i.lines[k])
+		}
+		return buf
+	}
+	for k, v := range i.lines {
+		match(k, v)
+	}
 	return buf
 }
 
@@ -223,5 +238,14 @@ func (s scoreSorter) Less(a, b int) bool {
 func indstr(name string, ref *repo.ChartVersion) string {
 	i := ref.Name + sep + name + "/" + ref.Name + sep +
 		ref.Description + sep + strings.Join(ref.Keywords, " ")
+	if len(ref.Maintainers) > 0 {
+		maintainers := make([]string, 0, len(ref.Maintainers))
+		for _, m := range ref.Maintainers {
+			if m != nil && m.Name != "" {
+				maintainers = append(maintainers, m.Name)
+			}
+		}
+		i += sep + strings.Join(maintainers, " ")
+	}
 	return i
 }
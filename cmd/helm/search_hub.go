@@ -17,15 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"strings"
 
 	"github.com/gosuri/uitable"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
-	"github.com/open-hand/helm/internal/monocular"
+	"github.com/open-hand/helm/cmd/helm/search"
 	"github.com/open-hand/helm/pkg/cli/output"
 )
 
@@ -47,10 +47,22 @@ Monocular search API. Similarly, when setting the 'endpoint' flag, the specified
 endpoint must also be implement a Monocular compatible search API endpoint.
 Note that when specifying a Monocular instance as the 'endpoint', rich queries
 are not supported. For API details, see https://github.com/helm/monocular
+
+With '--provider index', the search is sent to a self-hosted service
+aggregating the indexes of chart repositories, such as an internal catalog,
+at the required 'endpoint'. The service answers GET requests on
+'/api/v1/charts/search?q=KEYWORD' with a JSON object whose "results" list the
+charts found, each with its "name", "url", "version", "app_version",
+"description" and "repository" ("name" and "url"). Requests are authenticated
+with '--token' as a bearer token, or with '--username' and '--password'.
 `
 
 type searchHubOptions struct {
+	provider       string
 	searchEndpoint string
+	token          string
+	username       string
+	password       string
 	maxColWidth    uint
 	outputFormat   output.Format
 	listRepoURL    bool
@@ -69,7 +81,11 @@ func newSearchHubCmd(out io.Writer) *cobra.Command {
 	}
 
 	f := cmd.Flags()
-	f.StringVar(&o.searchEndpoint, "endpoint", "https://hub.helm.sh", "Hub instance to query for charts")
+	f.StringVar(&o.provider, "provider", search.ArtifactHubProvider, fmt.Sprintf("search provider: %q for the Artifact Hub or a Monocular instance, or %q for an index aggregation service", search.ArtifactHubProvider, search.IndexServiceProvider))
+	f.StringVar(&o.searchEndpoint, "endpoint", "", fmt.Sprintf("Hub instance or index service to query for charts (default %q for the Artifact Hub)", search.DefaultHubEndpoint))
+	f.StringVar(&o.token, "token", "", "bearer token authenticating the requests to the index service")
+	f.StringVar(&o.username, "username", "", "username authenticating the requests to the index service")
+	f.StringVar(&o.password, "password", "", "password authenticating the requests to the index service")
 	f.UintVar(&o.maxColWidth, "max-col-width", 50, "maximum column width for output table")
 	f.BoolVar(&o.listRepoURL, "list-repo-url", false, "print charts repository URL")
 
@@ -79,19 +95,28 @@ func newSearchHubCmd(out io.Writer) *cobra.Command {
 }
 
 func (o *searchHubOptions) run(out io.Writer, args []string) error {
-	c, err := monocular.New(o.searchEndpoint)
+	p, err := search.NewProvider(o.provider, search.ProviderOptions{
+		Endpoint: o.searchEndpoint,
+		Token:    o.token,
+		Username: o.username,
+		Password: o.password,
+	})
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("unable to create connection to %q", o.searchEndpoint))
+		return err
+	}
+	endpoint := o.searchEndpoint
+	if endpoint == "" {
+		endpoint = search.DefaultHubEndpoint
 	}
 
 	q := strings.Join(args, " ")
-	results, err := c.Search(q)
+	results, err := p.Search(context.Background(), q)
 	if err != nil {
 		debug("%s", err)
-		return fmt.Errorf("unable to perform search against %q", o.searchEndpoint)
+		return fmt.Errorf("unable to perform search against %q", endpoint)
 	}
 
-	return o.outputFormat.Write(out, newHubSearchWriter(results, o.searchEndpoint, o.maxColWidth, o.listRepoURL))
+	return o.outputFormat.Write(out, newHubSearchWriter(results, o.maxColWidth, o.listRepoURL))
 }
 
 type hubChartRepo struct {
@@ -113,18 +138,10 @@ type hubSearchWriter struct {
 	listRepoURL bool
 }
 
-func newHubSearchWriter(results []monocular.SearchResult, endpoint string, columnWidth uint, listRepoURL bool) *hubSearchWriter {
+func newHubSearchWriter(results []search.ChartResult, columnWidth uint, listRepoURL bool) *hubSearchWriter {
 	var elements []hubChartElement
 	for _, r := range results {
-		// Backwards compatibility for Monocular
-		url := endpoint + "/charts/" + r.ID
-
-		// Check for artifactHub compatibility
-		if r.ArtifactHub.PackageURL != "" {
-			url = r.ArtifactHub.PackageURL
-		}
-
-		elements = append(elements, hubChartElement{url, r.Relationships.LatestChartVersion.Data.Version, r.Relationships.LatestChartVersion.Data.AppVersion, r.Attributes.Description, hubChartRepo{URL: r.Attributes.Repo.URL, Name: r.Attributes.Repo.Name}})
+		elements = append(elements, hubChartElement{r.URL, r.Version, r.AppVersion, r.Description, hubChartRepo{URL: r.Repository.URL, Name: r.Repository.Name}})
 	}
 	return &hubSearchWriter{elements, columnWidth, listRepoURL}
 }
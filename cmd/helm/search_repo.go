@@ -44,7 +44,11 @@ the system.
 
 It will display the latest stable versions of the charts found. If you
 specify the --devel flag, the output will include pre-release versions.
-If you want to search using a version constraint, use --version.
+If you want to search using a version constraint, use --version. The
+--channel flag restricts the versions to the ones available in a release
+channel: charts declare the channel of their versions with the
+"helm.sh/channel" annotation, and pre-releases are in the edge channel by
+default.
 
 Examples:
 
@@ -54,6 +58,9 @@ Examples:
     # Search for release versions matching the keyword "nginx", including pre-release versions
     $ helm search repo nginx --devel
 
+    # Search for the latest versions matching the keyword "nginx" in the beta channel
+    $ helm search repo nginx --channel beta
+
     # Search for the latest stable release for nginx-ingress with a major version of 1
     $ helm search repo nginx-ingress --version ^1.0.0
 
@@ -67,6 +74,7 @@ type searchRepoOptions struct {
 	versions     bool
 	regexp       bool
 	devel        bool
+	channel      string
 	version      string
 	maxColWidth  uint
 	repoFile     string
@@ -91,7 +99,8 @@ func newSearchRepoCmd(out io.Writer) *cobra.Command {
 	f := cmd.Flags()
 	f.BoolVarP(&o.regexp, "regexp", "r", false, "use regular expressions for searching repositories you have added")
 	f.BoolVarP(&o.versions, "versions", "l", false, "show the long listing, with each version of each chart on its own line, for repositories you have added")
-	f.BoolVar(&o.devel, "devel", false, "use development versions (alpha, beta, and release candidate releases), too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&o.devel, "devel", false, "use development versions (alpha, beta, and release candidate releases), too. Equivalent to --channel edge")
+	f.StringVar(&o.channel, "channel", "", fmt.Sprintf("only show the chart versions available in this release channel. Allowed values: %s", strings.Join(repo.Channels, ", ")))
 	f.StringVar(&o.version, "version", "", "search using semantic versioning constraints on repositories you have added")
 	f.UintVar(&o.maxColWidth, "max-col-width", 50, "maximum column width for output table")
 	bindOutputFlag(cmd, &o.outputFormat)
@@ -100,6 +109,11 @@ func newSearchRepoCmd(out io.Writer) *cobra.Command {
 }
 
 func (o *searchRepoOptions) run(out io.Writer, args []string) error {
+	if o.channel != "" {
+		if err := repo.ValidateChannel(o.channel); err != nil {
+			return err
+		}
+	}
 	o.setupSearchedVersion()
 
 	index, err := o.buildIndex()
@@ -134,7 +148,10 @@ func (o *searchRepoOptions) setupSearchedVersion() {
 		return
 	}
 
-	if o.devel { // search for releases and prereleases (alpha, beta, and release candidate releases).
+	if o.devel && o.channel == "" {
+		o.channel = repo.ChannelEdge
+	}
+	if o.channel != "" { // search for the versions of the channel, prereleases included.
 		debug("setting version to >0.0.0-0")
 		o.version = ">0.0.0-0"
 	} else { // search only for stable releases, prerelease versions will be skip
@@ -165,6 +182,9 @@ func (o *searchRepoOptions) applyConstraint(res []*search.Result) ([]*search.Res
 		if err != nil {
 			continue
 		}
+		if o.channel != "" && !r.Chart.InChannel(o.channel) {
+			continue
+		}
 		if constraint.Check(v) {
 			data = append(data, r)
 			foundNames[r.Name] = true
@@ -181,20 +201,39 @@ func (o *searchRepoOptions) buildIndex() (*search.Index, error) {
 		return nil, errors.New("no repositories configured")
 	}
 
-	i := search.NewIndex()
+	c, failed := updateSearchCache(rf, o.repoCacheDir)
 	for _, re := range rf.Repositories {
-		n := re.Name
-		f := filepath.Join(o.repoCacheDir, helmpath.CacheIndexFile(n))
-		ind, err := repo.LoadIndexFile(f)
-		if err != nil {
-			warning("Repo %q is corrupt or missing. Try 'helm repo update'.", n)
+		if err, ok := failed[re.Name]; ok {
+			warning("Repo %q is corrupt or missing. Try 'helm repo update'.", re.Name)
 			warning("%s", err)
-			continue
 		}
+	}
+	return search.NewIndexFromCache(c, o.versions || len(o.version) > 0), nil
+}
 
-		i.AddRepo(n, ind, o.versions || len(o.version) > 0)
+// updateSearchCache refreshes the search cache kept in the repository cache
+// directory with the index files of the configured repositories, and returns
+// it along with the repositories whose index could not be loaded.
+func updateSearchCache(rf *repo.File, cacheDir string) (*search.Cache, map[string]error) {
+	files := make([]search.RepoIndexFile, 0, len(rf.Repositories))
+	for _, re := range rf.Repositories {
+		files = append(files, search.RepoIndexFile{
+			Name: re.Name,
+			Path: filepath.Join(cacheDir, helmpath.CacheIndexFile(re.Name)),
+		})
+	}
+
+	cacheFile := filepath.Join(cacheDir, search.CacheFile)
+	c := search.LoadCache(cacheFile)
+	changed, failed := c.Update(files)
+	if changed {
+		// A cache that cannot be written only costs the next search the
+		// time needed to parse the index files again.
+		if err := c.Save(cacheFile); err != nil {
+			debug("failed to save search cache: %s", err)
+		}
 	}
-	return i, nil
+	return c, failed
 }
 
 type repoChartElement struct {
@@ -17,12 +17,35 @@ limitations under the License.
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/open-hand/helm/cmd/helm/search"
 )
 
+// testRepoCache copies the repository cache of the test data to a temporary
+// directory, so that the search cache written next to it is discarded.
+func testRepoCache(t *testing.T) string {
+	t.Helper()
+	src := "testdata/helmhome/helm/repository"
+	dir := t.TempDir()
+	files, err := os.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if err := copyFile(filepath.Join(src, f.Name()), filepath.Join(dir, f.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
 func TestSearchRepositoriesCmd(t *testing.T) {
 	repoFile := "testdata/helmhome/helm/repositories.yaml"
-	repoCache := "testdata/helmhome/helm/repository"
+	repoCache := testRepoCache(t)
 
 	tests := []cmdTestCase{{
 		name:   "search for 'alpine', expect one match with latest stable version",
@@ -32,6 +55,22 @@ func TestSearchRepositoriesCmd(t *testing.T) {
 		name:   "search for 'alpine', expect one match with newest development version",
 		cmd:    "search repo alpine --devel",
 		golden: "output/search-multiple-devel-release.txt",
+	}, {
+		name:   "search for 'alpine' in the stable channel, expect one match with the newest stable version",
+		cmd:    "search repo alpine --channel stable",
+		golden: "output/search-channel-stable.txt",
+	}, {
+		name:   "search for 'alpine' in the beta channel, expect one match with the newest beta version",
+		cmd:    "search repo alpine --channel beta",
+		golden: "output/search-channel-beta.txt",
+	}, {
+		name:   "search for 'alpine' in the edge channel, expect one match with newest development version",
+		cmd:    "search repo alpine --channel edge",
+		golden: "output/search-multiple-devel-release.txt",
+	}, {
+		name:      "search for 'alpine' in an unknown channel, expect failure",
+		cmd:       "search repo alpine --channel nightly",
+		wantError: true,
 	}, {
 		name:   "search for 'alpine' with versions, expect three matches",
 		cmd:    "search repo alpine --versions",
@@ -72,6 +111,10 @@ func TestSearchRepositoriesCmd(t *testing.T) {
 		name:   "search for 'alpine', expect valid yaml output",
 		cmd:    "search repo alpine --output yaml",
 		golden: "output/search-output-yaml.txt",
+	}, {
+		name:   "search for 'bitnami', expect match on maintainer",
+		cmd:    "search repo bitnami",
+		golden: "output/search-maintainer.txt",
 	}}
 
 	settings.Debug = true
@@ -84,6 +127,36 @@ func TestSearchRepositoriesCmd(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestSearchRepoCache(t *testing.T) {
+	repoFile := "testdata/helmhome/helm/repositories.yaml"
+	repoCache := testRepoCache(t)
+	args := " --repository-config " + repoFile + " --repository-cache " + repoCache
+
+	_, out, err := executeActionCommand("search repo mariadb" + args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "testing/mariadb") {
+		t.Fatalf("expected mariadb in search results, got:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(repoCache, search.CacheFile)); err != nil {
+		t.Fatalf("expected the search cache to be written: %s", err)
+	}
+
+	// Replacing an index file must invalidate its cached entries.
+	index := filepath.Join(repoCache, "testing-index.yaml")
+	if err := copyFile(filepath.Join(repoCache, "test-name-index.yaml"), index); err != nil {
+		t.Fatal(err)
+	}
+	_, out, err = executeActionCommand("search repo mariadb" + args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "testing/mariadb") {
+		t.Errorf("expected stale entries to be dropped from the search cache, got:\n%s", out)
+	}
+}
+
 func TestSearchRepoOutputCompletion(t *testing.T) {
 	outputFlagCompletionTest(t, "search repo")
 }
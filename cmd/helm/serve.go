@@ -0,0 +1,249 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/engine"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/server"
+)
+
+const serveDesc = `
+This command runs an API server exposing the install, upgrade, uninstall,
+rollback, status and list operations over REST and gRPC, with the releases
+as payloads. It is meant to run in a cluster as a deployment service.
+
+The REST API is served on --address, under /v1/namespaces/NAMESPACE/releases,
+and the gRPC service helm.v1.Releases on --grpc-address, with JSON messages.
+Both authenticate the requests by their bearer token, which must be one of
+the tokens of --token-file, one per line, or of $HELM_SERVE_TOKEN.
+
+The operations run with the Kubernetes credentials of helm, in the namespace
+of each request. With --read-only, only the status and list operations are
+allowed, the others fail with 403 Forbidden or PERMISSION_DENIED.
+
+The charts of the requests are the charts of the repositories configured on
+the server, named as "repo/chart". The requests can only download charts
+from other repositories or registries, with a repoURL or an oci:// or https://
+chart reference, under the URLs of --allow-repository, and cannot install the
+charts at paths of the server unless --allow-local-charts is set.
+`
+
+type serveOptions struct {
+	address     string
+	grpcAddress string
+	tokenFile   string
+	certFile    string
+	keyFile     string
+	readOnly    bool
+	allowLocal  bool
+	allowRepos  []string
+	// renderLimits bound the renders of the charts of the requests.
+	renderLimits engine.Limits
+}
+
+func newServeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	o := &serveOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "serve",
+		Short:             "run an API server exposing the release operations",
+		Long:              serveDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(cfg, out)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&o.address, "address", ":8080", "address of the REST API")
+	f.StringVar(&o.grpcAddress, "grpc-address", "", "address of the gRPC API, not served if empty")
+	f.StringVar(&o.tokenFile, "token-file", "", "file listing the tokens allowed to call the server, one per line")
+	f.StringVar(&o.certFile, "tls-cert", "", "TLS certificate file of the server")
+	f.StringVar(&o.keyFile, "tls-key", "", "TLS key file of the server")
+	f.BoolVar(&o.readOnly, "read-only", false, "refuse the operations changing the releases")
+	f.BoolVar(&o.allowLocal, "allow-local-charts", false, "allow the requests to install the charts at paths of the server")
+	f.StringArrayVar(&o.allowRepos, "allow-repository", nil, "URL of a repository or registry the requests can download charts from, including the ones under its path (can specify multiple)")
+	bindRenderLimitsFlags(cmd, &o.renderLimits, engine.DefaultLimits)
+
+	return cmd
+}
+
+func (o *serveOptions) run(actionConfig *action.Configuration, out io.Writer) error {
+	tokens, err := o.tokens()
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return errors.New("no token allowed to call the server, set --token-file or $HELM_SERVE_TOKEN")
+	}
+	if (o.certFile == "") != (o.keyFile == "") {
+		return errors.New("--tls-cert and --tls-key must be set together")
+	}
+
+	if o.readOnly {
+		actionConfig.ReadOnly = true
+	}
+	actionConfig.RenderLimits = o.renderLimits
+	opts := []server.Option{server.AllowRepositories(o.allowRepos...)}
+	if o.allowLocal {
+		opts = append(opts, server.AllowLocalCharts())
+	}
+	srv, err := server.New(settings, namespaceConfigs(actionConfig), server.Tokens(tokens...), opts...)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if o.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to load the TLS certificate")
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 2)
+
+	httpServer := &http.Server{
+		Addr:              o.address,
+		Handler:           srv.Handler(),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		fmt.Fprintf(out, "Serving the REST API on %s\n", o.address)
+		var err error
+		if tlsConfig != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			errs <- errors.Wrap(err, "REST API")
+		}
+	}()
+
+	var grpcServer *grpc.Server
+	if o.grpcAddress != "" {
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = grpc.NewServer(opts...)
+		srv.RegisterGRPC(grpcServer)
+		l, err := net.Listen("tcp", o.grpcAddress)
+		if err != nil {
+			httpServer.Close()
+			return errors.Wrap(err, "gRPC API")
+		}
+		go func() {
+			fmt.Fprintf(out, "Serving the gRPC API on %s\n", o.grpcAddress)
+			if err := grpcServer.Serve(l); err != nil {
+				errs <- errors.Wrap(err, "gRPC API")
+			}
+		}()
+	}
+
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	httpServer.Shutdown(shutdown)
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	return err
+}
+
+// namespaceConfigs returns a function initializing the action configuration
+// of a namespace, sharing the clients and the options of actionConfig.
+func namespaceConfigs(actionConfig *action.Configuration) func(namespace string) (*action.Configuration, error) {
+	discovery := actionConfig.DiscoveryCache
+	if discovery == nil {
+		discovery = kube.NewDiscoveryCache(10 * time.Minute)
+	}
+	return func(namespace string) (*action.Configuration, error) {
+		if namespace == "" {
+			namespace = settings.Namespace()
+		}
+		cfg := &action.Configuration{
+			RegistryClient:  actionConfig.RegistryClient,
+			Retention:       actionConfig.Retention,
+			SignaturePolicy: actionConfig.SignaturePolicy,
+			ReadOnly:        actionConfig.ReadOnly,
+			RateLimit:       actionConfig.RateLimit,
+			DiscoveryCache:  discovery,
+			Metrics:         actionConfig.Metrics,
+			TracerProvider:  actionConfig.TracerProvider,
+			Logger:          actionConfig.Logger,
+			RenderLimits:    actionConfig.RenderLimits,
+		}
+		if err := cfg.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debug); err != nil {
+			return nil, err
+		}
+		if kc, ok := cfg.KubeClient.(*kube.Client); ok {
+			kc.Namespace = namespace
+		}
+		return cfg, nil
+	}
+}
+
+// tokens returns the tokens of the token file and of $HELM_SERVE_TOKEN.
+func (o *serveOptions) tokens() ([]string, error) {
+	var tokens []string
+	if o.tokenFile != "" {
+		b, err := ioutil.ReadFile(o.tokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the token file")
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				tokens = append(tokens, line)
+			}
+		}
+	}
+	if token := strings.TrimSpace(os.Getenv("HELM_SERVE_TOKEN")); token != "" {
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/engine"
+)
+
+func TestServeRequiresToken(t *testing.T) {
+	t.Setenv("HELM_SERVE_TOKEN", "")
+	_, _, err := executeActionCommand("serve")
+	if err == nil || !strings.Contains(err.Error(), "no token allowed") {
+		t.Errorf("expected an error without tokens, got %v", err)
+	}
+}
+
+func TestServeTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens")
+	if err := ioutil.WriteFile(file, []byte("# deploy service\none\n\n  two  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HELM_SERVE_TOKEN", "three")
+
+	o := &serveOptions{tokenFile: file}
+	tokens, err := o.tokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "two", "three"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("expected tokens %v, got %v", want, tokens)
+	}
+}
+
+func TestServeRenderLimits(t *testing.T) {
+	for _, cmd := range []*cobra.Command{newServeCmd(&action.Configuration{}, ioutil.Discard), newDaemonCmd(&action.Configuration{}, ioutil.Discard)} {
+		f := cmd.Flags()
+		if timeout := f.Lookup("render-timeout").DefValue; timeout != engine.DefaultLimits.Timeout.String() {
+			t.Errorf("%s: expected the default render timeout, got %s", cmd.Name(), timeout)
+		}
+		if length := f.Lookup("render-max-list-length").DefValue; length != fmt.Sprint(engine.DefaultLimits.MaxListLength) {
+			t.Errorf("%s: expected the default maximum list length, got %s", cmd.Name(), length)
+		}
+	}
+
+	limits := engine.Limits{Timeout: time.Second, MaxOutputSize: 1024}
+	cfg, err := namespaceConfigs(&action.Configuration{RenderLimits: limits})("apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RenderLimits != limits {
+		t.Errorf("expected the render limits to be shared, got %+v", cfg.RenderLimits)
+	}
+}
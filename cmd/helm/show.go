@@ -23,9 +23,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"io"
+	"os"
+	"strings"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/registry"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 const showDesc = `
@@ -40,11 +44,24 @@ This command inspects a chart (directory, file, or URL) and displays all its con
 const showValuesDesc = `
 This command inspects a chart (directory, file, or URL) and displays the contents
 of the values.yaml file
+
+The --key flag shows only the section of the values.yaml file setting a dotted
+key, with its comments, such as 'ingress.tls'. The --output flag shows the
+values, or the ones under the key, encoded again in YAML or JSON instead.
+
+Only the Chart.yaml and values.yaml files are read from the archive of a
+chart in a repository, with HTTP range requests when the repository supports
+them, instead of downloading the whole chart, unless the chart is verified.
 `
 
 const showChartDesc = `
 This command inspects a chart (directory, file, or URL) and displays the contents
 of the Chart.yaml file
+
+Only the Chart.yaml file is read from the archive of a chart in a repository,
+with HTTP range requests when the repository supports them, or only the chart
+metadata is pulled from an OCI registry, instead of downloading the whole
+chart, unless the chart is verified.
 `
 
 const readmeChartDesc = `
@@ -62,6 +79,18 @@ This command inspects a chart (directory, file, or URL) and displays the content
 of hooks
 `
 
+const showSBOMDesc = `
+This command inspects a chart (directory, file, URL, or OCI reference) and displays
+its software bill of materials (SBOM).
+
+The SBOM written next to a chart archive by 'helm package --sbom' is displayed
+if there is one, otherwise an SBOM of the chart is generated. For a chart in an
+OCI registry, the SBOM attached to the chart when it was pushed is displayed.
+
+  $ helm show sbom ./mychart-0.1.0.tgz
+  $ helm show sbom oci://example.com/charts/mychart --version 0.1.0 --format cyclonedx
+`
+
 func newShowCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewShowWithConfig(action.ShowAll, cfg)
 
@@ -193,7 +222,33 @@ func newShowCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		},
 	}
 
-	cmds := []*cobra.Command{all, readmeSubCmd, valuesSubCmd, chartSubCmd, hookSubCmd, crdsSubCmd}
+	sbomSubCmd := &cobra.Command{
+		Use:               "sbom [CHART]",
+		Short:             "show the chart's software bill of materials",
+		Long:              showSBOMDesc,
+		Args:              require.ExactArgs(1),
+		ValidArgsFunction: validArgsFunc,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client.OutputFormat = action.ShowSBOM
+			var output string
+			var err error
+			if registry.IsOCI(args[0]) {
+				output, err = client.AttachedSBOM(args[0])
+			} else if _, statErr := os.Stat(args[0]); statErr == nil {
+				output, err = client.Run(args[0], nil)
+			} else {
+				output, err = runShow(args, client, nil)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(out, output)
+			return nil
+		},
+	}
+	sbomSubCmd.Flags().StringVar(&client.SBOMFormat, "format", "", fmt.Sprintf("format of the SBOM. Allowed values: %s", strings.Join(sbom.Formats(), ", ")))
+
+	cmds := []*cobra.Command{all, readmeSubCmd, valuesSubCmd, chartSubCmd, hookSubCmd, crdsSubCmd, sbomSubCmd}
 	for _, subCmd := range cmds {
 		addShowFlags(subCmd, client)
 		showCommand.AddCommand(subCmd)
@@ -205,9 +260,20 @@ func newShowCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 func addShowFlags(subCmd *cobra.Command, client *action.Show) {
 	f := subCmd.Flags()
 
-	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to --channel edge")
 	if subCmd.Name() == "values" {
 		f.StringVar(&client.JSONPathTemplate, "jsonpath", "", "supply a JSONPath expression to filter the output")
+		f.StringVar(&client.ValuesKey, "key", "", "show only the values under this dotted key (e.g. ingress.tls), with their comments unless --output is set")
+		f.StringVarP(&client.ValuesOutput, outputFlag, "o", "", "encode the values again in the given format instead of showing the values.yaml file as written. Allowed values: yaml, json")
+		err := subCmd.RegisterFlagCompletionFunc(outputFlag, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return []string{"yaml", "json"}, cobra.ShellCompDirectiveNoFileComp
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if subCmd.Name() == "hooks" || subCmd.Name() == "all" {
+		bindCapabilitiesFileFlag(subCmd, &client.Capabilities)
 	}
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 
@@ -225,14 +291,7 @@ func addShowFlags(subCmd *cobra.Command, client *action.Show) {
 
 func runShow(args []string, client *action.Show, vals map[string]interface{}) (string, error) {
 	debug("Original chart version: %q", client.Version)
-	if client.Version == "" && client.Devel {
-		debug("setting version to >0.0.0-0")
-		client.Version = ">0.0.0-0"
-	}
+	client.UseDevel(client.Devel)
 
-	cp, err := client.ChartPathOptions.LocateChart(args[0], settings)
-	if err != nil {
-		return "", err
-	}
-	return client.Run(cp, vals)
+	return client.RunRemote(args[0], settings, vals)
 }
@@ -17,11 +17,21 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/repo/repotest"
 )
 
@@ -88,6 +98,46 @@ func TestShowPreReleaseChart(t *testing.T) {
 	}
 }
 
+func TestShowValuesKeyAndOutput(t *testing.T) {
+	ch, err := loader.Load("testdata/testcharts/chart-with-commented-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+	showValues := fmt.Sprintf("show values %s --repo %s --version %s", ch.Name(), repoURL, ch.Metadata.Version)
+
+	tests := []cmdTestCase{{
+		name:   "show values section with comments",
+		cmd:    showValues + " --key ingress",
+		golden: "output/show-values-key.txt",
+	}, {
+		name:   "show values item of a list",
+		cmd:    showValues + " --key ingress.hosts.0",
+		golden: "output/show-values-key-index.txt",
+	}, {
+		name:   "show values section as json",
+		cmd:    showValues + " --key ingress --output json",
+		golden: "output/show-values-key-json.txt",
+	}, {
+		name:   "show values as yaml",
+		cmd:    showValues + " -o yaml",
+		golden: "output/show-values-yaml.txt",
+	}, {
+		name:   "show values section with jsonpath",
+		cmd:    showValues + " --key ingress --jsonpath {.enabled}",
+		golden: "output/show-values-key-jsonpath.txt",
+	}, {
+		name:      "show values missing key",
+		cmd:       showValues + " --key ingress.missing",
+		wantError: true,
+	}, {
+		name:      "show values invalid output",
+		cmd:       showValues + " -o table",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestShowVersionCompletion(t *testing.T) {
 	repoFile := "testdata/helmhome/helm/repositories.yaml"
 	repoCache := "testdata/helmhome/helm/repository"
@@ -153,3 +203,110 @@ func TestShowValuesFileCompletion(t *testing.T) {
 func TestShowCRDsFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "show crds", true)
 }
+
+func TestShowSBOM(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "show the SBOM stored next to a chart archive",
+		cmd:    "show sbom testdata/sbom/alpine-0.1.0.tgz",
+		golden: "output/show-sbom.txt",
+	}, {
+		name:      "show an SBOM in an unknown format",
+		cmd:       "show sbom testdata/sbom/alpine-0.1.0.tgz --format swid",
+		golden:    "output/show-sbom-invalid-format.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+
+	_, out, err := executeActionCommand("show sbom testdata/testcharts/alpine --format cyclonedx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"bomFormat": "CycloneDX"`) || !strings.Contains(out, `"name": "alpine"`) {
+		t.Errorf("expected a CycloneDX SBOM of the chart, got %s", out)
+	}
+}
+
+func TestShowRemoteChartPartially(t *testing.T) {
+	assets := make([]byte, 2*1024*1024)
+	if _, err := rand.Read(assets); err != nil {
+		t.Fatal(err)
+	}
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "bundled", Version: "0.1.0", Description: "A chart bundling assets"},
+		Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("replicas: 3\n")}},
+		Files:    []*chart.File{{Name: "files/assets.bin", Data: assets}},
+	}
+	path, err := chartutil.Save(c, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		served int
+		ranges []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/charts/bundled-0.1.0.tgz" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		cw := &countingWriter{ResponseWriter: w}
+		http.ServeContent(cw, r, "bundled-0.1.0.tgz", time.Time{}, bytes.NewReader(archive))
+		mu.Lock()
+		served += cw.n
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		cmd  string
+		want string
+	}{
+		{cmd: "show values", want: "replicas: 3"},
+		{cmd: "show chart", want: "description: A chart bundling assets"},
+	} {
+		ranges, served = nil, 0
+		cmd := fmt.Sprintf("%s bundled --repo %s/ --version 0.1.0 --repository-config %s --repository-cache %s",
+			tt.cmd, srv.URL, filepath.Join(dir, "repositories.yaml"), dir)
+		_, out, err := executeActionCommand(cmd)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.cmd, err)
+		}
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("%s: expected %q in the output, got %s", tt.cmd, tt.want, out)
+		}
+		for _, r := range ranges {
+			if r == "" {
+				t.Errorf("%s: expected only range requests, got %q", tt.cmd, ranges)
+			}
+		}
+		if served >= len(archive)/2 {
+			t.Errorf("%s: expected a small part of the %d bytes of the archive to be downloaded, got %d bytes", tt.cmd, len(archive), served)
+		}
+	}
+}
+
+// countingWriter counts the bytes of the body of a response.
+type countingWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += n
+	return n, err
+}
+
+func TestShowSBOMFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "show sbom", true)
+}
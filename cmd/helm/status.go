@@ -17,13 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/duration"
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
@@ -42,13 +46,26 @@ The status consists of:
 - revision of the release
 - description of the release (can be completion message or error message, need to enable --show-desc)
 - list of resources that this release consists of, sorted by kind
+  (with --show-resources, along with their readiness, age and recent warning
+  events in the cluster)
 - details on last test suite run, if applicable
 - additional notes provided by the chart
+
+With --watch, the readiness of the resources of the release and the progress
+of its hooks are shown and updated every --watch-interval until the release
+settles: it is no longer pending and either failed or has all of its resources
+ready. The command fails if the release does not settle within --timeout.
+
+With --timeline, the installs, upgrades, rollbacks, test runs and uninstall
+across the history of the release are shown in chronological order, with who
+made them, when, the chart version, their result and how long they took.
 `
 
 func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewStatus(cfg)
 	var outfmt output.Format
+	var watch, timeline bool
+	var watchInterval, timeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "status RELEASE_NAME",
@@ -62,15 +79,39 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if watch {
+				if watchInterval <= 0 {
+					return errors.Errorf("--watch-interval must be positive, got %s", watchInterval)
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				w := newStatusWatchWriter(out, outfmt)
+				return client.Watch(ctx, args[0], watchInterval, w.write)
+			}
+			if timeline {
+				t, err := client.Timeline(args[0])
+				if err != nil {
+					return err
+				}
+				return outfmt.Write(out, &timelineWriter{t})
+			}
+
 			rel, err := client.Run(args[0])
 			if err != nil {
 				return err
 			}
 
+			var resources []action.ResourceStatus
+			if client.ShowResources {
+				if resources, err = client.Resources(context.Background(), rel); err != nil {
+					return err
+				}
+			}
+
 			// strip chart metadata from the output
 			rel.Chart = nil
 
-			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription})
+			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription, resources})
 		},
 	}
 
@@ -91,22 +132,74 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	bindOutputFlag(cmd, &outfmt)
 	f.BoolVar(&client.ShowDescription, "show-desc", false, "if set, display the description message of the named release")
+	f.BoolVar(&client.ShowResources, "show-resources", false, "if set, display the resources of the named release with their readiness, age and recent warning events")
+	f.BoolVar(&watch, "watch", false, "watch the readiness of the resources and hooks of the release until it settles")
+	f.BoolVar(&timeline, "timeline", false, "display the operations across the history of the release in chronological order")
+	f.DurationVar(&watchInterval, "watch-interval", 2*time.Second, "time between two updates with --watch")
+	f.DurationVar(&timeout, "timeout", 5*time.Minute, "time to wait for the release to settle with --watch (like 5s, 2m, or 3h)")
 
 	return cmd
 }
 
+type timelineWriter struct {
+	timeline *action.ReleaseTimeline
+}
+
+func (w *timelineWriter) WriteTable(out io.Writer) error {
+	tbl := uitable.New()
+	tbl.MaxColWidth = 60
+	tbl.AddRow("TIME", "REVISION", "EVENT", "CHART", "APP VERSION", "USER", "RESULT", "DURATION", "DESCRIPTION")
+	for _, e := range w.timeline.Events {
+		d := "-"
+		if e.Duration > 0 {
+			d = e.Duration.String()
+		}
+		user := e.User
+		if user == "" {
+			user = "-"
+		}
+		tbl.AddRow(e.Time.Format(time.ANSIC), e.Revision, e.Kind, e.Chart, e.AppVersion, user, e.Result, d, e.Description)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (w *timelineWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.timeline)
+}
+
+func (w *timelineWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.timeline)
+}
+
 type statusPrinter struct {
 	release         *release.Release
 	debug           bool
 	showDescription bool
+	// resources are the resources of the release shown with
+	// --show-resources, if any.
+	resources []action.ResourceStatus
+}
+
+// releaseWithResources is the release output by statusPrinter with the
+// state of its resources.
+type releaseWithResources struct {
+	*release.Release
+	Resources []action.ResourceStatus `json:"resources"`
+}
+
+func (s statusPrinter) object() interface{} {
+	if s.resources == nil {
+		return s.release
+	}
+	return releaseWithResources{s.release, s.resources}
 }
 
 func (s statusPrinter) WriteJSON(out io.Writer) error {
-	return output.EncodeJSON(out, s.release)
+	return output.EncodeJSON(out, s.object())
 }
 
 func (s statusPrinter) WriteYAML(out io.Writer) error {
-	return output.EncodeYAML(out, s.release)
+	return output.EncodeYAML(out, s.object())
 }
 
 func (s statusPrinter) WriteTable(out io.Writer) error {
@@ -173,12 +266,55 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 		fmt.Fprintf(out, "MANIFEST:\n%s\n", s.release.Manifest)
 	}
 
+	if s.resources != nil {
+		if err := writeResourceStatuses(out, s.resources); err != nil {
+			return err
+		}
+	}
+
 	if len(s.release.Info.Notes) > 0 {
 		fmt.Fprintf(out, "NOTES:\n%s\n", strings.TrimSpace(s.release.Info.Notes))
 	}
 	return nil
 }
 
+// writeResourceStatuses writes the table of the resources of a release,
+// followed by their recent warning events.
+func writeResourceStatuses(out io.Writer, resources []action.ResourceStatus) error {
+	if len(resources) == 0 {
+		fmt.Fprintln(out, "RESOURCES: None")
+		return nil
+	}
+	fmt.Fprintln(out, "RESOURCES:")
+	tbl := uitable.New()
+	tbl.AddRow("KIND", "NAMESPACE", "NAME", "READY", "AGE")
+	for _, r := range resources {
+		ready := fmt.Sprint(r.Ready)
+		if r.Error != "" {
+			ready += ": " + r.Error
+		}
+		age := "<none>"
+		if r.Created != nil {
+			age = duration.HumanDuration(time.Since(*r.Created))
+		}
+		tbl.AddRow(r.Kind, r.Namespace, r.Name, ready, age)
+	}
+	if err := output.EncodeTable(out, tbl); err != nil {
+		return err
+	}
+
+	for _, r := range resources {
+		for _, e := range r.Warnings {
+			count := ""
+			if e.Count > 1 {
+				count = fmt.Sprintf(" (x%d)", e.Count)
+			}
+			fmt.Fprintf(out, "WARNING: %s/%s: %s%s: %s\n", r.Kind, r.Name, e.Reason, count, e.Message)
+		}
+	}
+	return nil
+}
+
 func executionsByHookEvent(rel *release.Release) map[release.HookEvent][]*release.Hook {
 	result := make(map[release.HookEvent][]*release.Hook)
 	for _, h := range rel.Hooks {
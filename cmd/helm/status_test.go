@@ -52,6 +52,13 @@ func TestStatusCmd(t *testing.T) {
 			Status:      release.StatusDeployed,
 			Description: "Mock description",
 		}),
+	}, {
+		name:   "get status of a deployed release, with resources",
+		cmd:    "status --show-resources flummoxed-chickadee",
+		golden: "output/status-with-resources.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusDeployed,
+		}),
 	}, {
 		name:   "get status of a deployed release with notes",
 		cmd:    "status flummoxed-chickadee",
@@ -108,6 +115,89 @@ func TestStatusCmd(t *testing.T) {
 				},
 			},
 		),
+	}, {
+		name:   "watch a settled release",
+		cmd:    "status flummoxed-chickadee --watch",
+		golden: "output/status-watch.txt",
+		rels: releasesMockWithStatus(
+			&release.Info{
+				Status: release.StatusDeployed,
+			},
+			&release.Hook{
+				Name:    "migrate",
+				Kind:    "Job",
+				Events:  []release.HookEvent{release.HookPreUpgrade},
+				LastRun: release.HookExecution{Phase: release.HookPhaseSucceeded},
+			},
+		),
+	}, {
+		name:      "watch a pending release until the timeout",
+		cmd:       "status flummoxed-chickadee --watch --watch-interval 10ms --timeout 50ms",
+		golden:    "output/status-watch-timeout.txt",
+		wantError: true,
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusPendingUpgrade,
+		}),
+	}, {
+		name:      "watch with an invalid interval",
+		cmd:       "status flummoxed-chickadee --watch --watch-interval 0s",
+		golden:    "output/status-watch-invalid-interval.txt",
+		wantError: true,
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusPendingUpgrade,
+		}),
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStatusTimeline(t *testing.T) {
+	revision := func(version int, chartVersion, user, description string, status release.Status, deployed, completed string, hooks ...*release.Hook) *release.Release {
+		c := mustParseTime(completed)
+		return &release.Release{
+			Name:      "flummoxed-chickadee",
+			Namespace: "default",
+			Version:   version,
+			Chart: &chart.Chart{Metadata: &chart.Metadata{
+				Name:       "chickadee",
+				Version:    chartVersion,
+				AppVersion: "1.0",
+			}},
+			Info: &release.Info{
+				LastDeployed: mustParseTime(deployed),
+				Completed:    &c,
+				DeployedBy:   user,
+				Description:  description,
+				Status:       status,
+			},
+			Hooks: hooks,
+		}
+	}
+	rels := []*release.Release{
+		revision(1, "0.1.0", "alice", "Install complete", release.StatusSuperseded, "2006-01-02T15:00:00Z", "2006-01-02T15:00:30Z"),
+		revision(2, "0.2.0", "bob", "Upgrade \"flummoxed-chickadee\" failed: timed out", release.StatusFailed, "2006-01-03T10:00:00Z", "2006-01-03T10:05:00Z"),
+		revision(3, "0.1.0", "alice", "Rollback to 1", release.StatusDeployed, "2006-01-03T10:10:00Z", "2006-01-03T10:10:20Z",
+			&release.Hook{
+				Name:   "smoke-test",
+				Events: []release.HookEvent{release.HookTest},
+				LastRun: release.HookExecution{
+					StartedAt:   mustParseTime("2006-01-03T11:00:00Z"),
+					CompletedAt: mustParseTime("2006-01-03T11:00:12Z"),
+					Phase:       release.HookPhaseSucceeded,
+				},
+			},
+		),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "timeline of a release",
+		cmd:    "status flummoxed-chickadee --timeline",
+		golden: "output/status-timeline.txt",
+		rels:   rels,
+	}, {
+		name:   "timeline of a release in json",
+		cmd:    "status flummoxed-chickadee --timeline -o json",
+		golden: "output/status-timeline.json",
+		rels:   rels,
 	}}
 	runTestCmd(t, tests)
 }
@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"golang.org/x/term"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+)
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\033[H\033[2J"
+
+// statusWatchWriter writes the updates of 'helm status --watch'. On a
+// terminal the table is redrawn in place, otherwise only the updates that
+// differ from the previous one are written.
+type statusWatchWriter struct {
+	out      io.Writer
+	format   output.Format
+	terminal bool
+	last     []byte
+}
+
+func newStatusWatchWriter(out io.Writer, format output.Format) *statusWatchWriter {
+	w := &statusWatchWriter{out: out, format: format}
+	if f, ok := out.(*os.File); ok && format == output.Table {
+		w.terminal = term.IsTerminal(int(f.Fd()))
+	}
+	return w
+}
+
+func (w *statusWatchWriter) write(update *action.StatusUpdate) error {
+	var buf bytes.Buffer
+	if err := w.format.Write(&buf, &statusUpdateWriter{update}); err != nil {
+		return err
+	}
+	if bytes.Equal(buf.Bytes(), w.last) {
+		return nil
+	}
+	switch {
+	case w.terminal:
+		fmt.Fprint(w.out, clearScreen)
+	case w.last != nil && w.format == output.Table:
+		fmt.Fprintln(w.out)
+	case w.format == output.YAML:
+		fmt.Fprintln(w.out, "---")
+	}
+	w.last = buf.Bytes()
+	_, err := w.out.Write(w.last)
+	return err
+}
+
+type statusUpdateWriter struct {
+	update *action.StatusUpdate
+}
+
+func (s *statusUpdateWriter) WriteTable(out io.Writer) error {
+	u := s.update
+	fmt.Fprintf(out, "NAME: %s\n", u.Release)
+	fmt.Fprintf(out, "NAMESPACE: %s\n", u.Namespace)
+	fmt.Fprintf(out, "STATUS: %s\n", u.Status)
+	fmt.Fprintf(out, "REVISION: %d\n", u.Revision)
+	fmt.Fprintf(out, "READY: %d/%d\n", u.Ready(), len(u.Resources))
+
+	if len(u.Resources) > 0 {
+		tbl := uitable.New()
+		tbl.AddRow("KIND", "NAMESPACE", "NAME", "READY")
+		for _, r := range u.Resources {
+			ready := fmt.Sprint(r.Ready)
+			if r.Error != "" {
+				ready += ": " + r.Error
+			}
+			tbl.AddRow(r.Kind, r.Namespace, r.Name, ready)
+		}
+		fmt.Fprintln(out)
+		if err := output.EncodeTable(out, tbl); err != nil {
+			return err
+		}
+	}
+
+	if len(u.Hooks) > 0 {
+		tbl := uitable.New()
+		tbl.AddRow("HOOK", "KIND", "EVENTS", "PHASE")
+		for _, h := range u.Hooks {
+			tbl.AddRow(h.Name, h.Kind, strings.Join(h.Events, ","), h.Phase)
+		}
+		fmt.Fprintln(out)
+		if err := output.EncodeTable(out, tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *statusUpdateWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, s.update)
+}
+
+func (s *statusUpdateWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, s.update)
+}
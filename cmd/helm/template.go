@@ -35,6 +35,8 @@ import (
 	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/cli/values"
+	"github.com/open-hand/helm/pkg/engine"
+	"github.com/open-hand/helm/pkg/kube"
 	"github.com/open-hand/helm/pkg/releaseutil"
 )
 
@@ -44,10 +46,30 @@ Render chart templates locally and display the output.
 Any values that would normally be looked up or retrieved in-cluster will be
 faked locally. Additionally, none of the server-side testing of chart validity
 (e.g. whether an API is supported) is done.
+
+The rendered resources can be checked against the CEL policies enforced in the
+deploy path with '--policy-check':
+
+    $ helm template --policy-check policies.yaml ./mychart
+
+To render with the capabilities and the resources of a cluster, such as from a
+CI job using a read-only service account, use '--read-only-cluster'. Helm then
+only gets, lists and watches resources, and refuses any other request:
+
+    $ helm template --read-only-cluster --kube-context production ./mychart
+
+Without access to the cluster, '--capabilities-file' renders with the exact
+Kubernetes version and API versions of a cluster as exported beforehand by
+'helm capabilities export'. '--kube-version' and '--api-versions' still apply
+over them:
+
+    $ helm capabilities export --kube-context production > caps.json
+    $ helm template --capabilities-file caps.json ./mychart
 `
 
 func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	var validate bool
+	var readOnlyCluster bool
 	var includeCrds bool
 	var skipTests bool
 	client := action.NewInstall(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", "", 0, "", "", "", false)
@@ -76,7 +98,15 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			client.DryRun = true
 			client.ReleaseName = "release-name"
 			client.Replace = true // Skip the name check
-			client.ClientOnly = !validate
+			client.ClientOnly = !validate && !readOnlyCluster
+			if readOnlyCluster {
+				// Connect to the cluster again with clients that cannot
+				// change it, whatever the permissions of the credentials.
+				if err := cfg.Init(kube.WithReadOnly(settings.RESTClientGetter()), settings.Namespace(), os.Getenv("HELM_DRIVER"), debug); err != nil {
+					return err
+				}
+				client.ClusterLookup = true
+			}
 			client.APIVersions = chartutil.VersionSet(extraAPIs)
 			client.IncludeCRDs = includeCrds
 			rel, err := runInstall(args, client, valueOpts, out)
@@ -174,16 +204,23 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	f := cmd.Flags()
 	addInstallFlags(cmd, f, client, valueOpts)
+	bindCapabilitiesFileFlag(cmd, &client.Capabilities)
 	f.StringArrayVarP(&showFiles, "show-only", "s", []string{}, "only show manifests rendered from the given templates")
 	f.StringVar(&client.OutputDir, "output-dir", "", "writes the executed templates to files in output-dir instead of stdout")
 	f.BoolVar(&validate, "validate", false, "validate your manifests against the Kubernetes cluster you are currently pointing at. This is the same validation performed on an install")
 	f.BoolVar(&includeCrds, "include-crds", false, "include CRDs in the templated output")
 	f.BoolVar(&skipTests, "skip-tests", false, "skip tests from templated output")
+	f.BoolVar(&readOnlyCluster, "read-only-cluster", false, "render with the capabilities of the Kubernetes cluster you are currently pointing at and let the lookup function read its resources, with a client only allowed to get, list and watch them so that nothing is changed in the cluster. Implies --validate")
 	f.BoolVar(&client.IsUpgrade, "is-upgrade", false, "set .Release.IsUpgrade instead of .Release.IsInstall")
 	f.StringVar(&kubeVersion, "kube-version", "", "Kubernetes version used for Capabilities.KubeVersion")
 	f.StringArrayVarP(&extraAPIs, "api-versions", "a", []string{}, "Kubernetes api versions used for Capabilities.APIVersions")
 	f.BoolVar(&client.UseReleaseName, "release-name", false, "use release name in the output-dir path.")
 	bindPostRenderFlag(cmd, &client.PostRenderer)
+	bindPolicyCheckFlag(cmd, &client.PolicyChecker)
+	bindNamespacePolicyFlags(cmd, &cfg.NamespacePolicy)
+	bindRenderLimitsFlags(cmd, &cfg.RenderLimits, engine.Limits{})
+	bindDuplicateResourcesFlag(cmd, &cfg.DuplicateResources)
+	bindDetectFeaturesFlag(cmd, &cfg.DetectFeatures)
 
 	return cmd
 }
@@ -18,8 +18,13 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/engine"
 )
 
 var chartPath = "testdata/testcharts/subchart"
@@ -171,3 +176,103 @@ func TestTemplateFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "template myname", true)
 	checkFileCompletion(t, "template myname mychart", false)
 }
+
+func TestTemplatePolicyCheck(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "check template with an invalid policy file",
+		cmd:       fmt.Sprintf("template --policy-check testdata/policy/invalid.yaml '%s'", chartPath),
+		golden:    "output/template-policy-invalid.txt",
+		wantError: true,
+	}, {
+		name:      "check template with a missing policy file",
+		cmd:       fmt.Sprintf("template --policy-check testdata/policy/missing.yaml '%s'", chartPath),
+		golden:    "output/template-policy-missing.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestTemplateOnlyOn(t *testing.T) {
+	ch, err := loader.Load("testdata/testcharts/only-on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+	// Keep the chart out of the repositories of the test environment.
+	home := t.TempDir()
+	template := fmt.Sprintf("template only only-on --version 0.1.0 --repo %s --repository-config %s --repository-cache %s",
+		repoURL, filepath.Join(home, "repositories.yaml"), home)
+
+	tests := []cmdTestCase{{
+		name:   "skip the resources excluding the cluster",
+		cmd:    template + " --kube-version 1.24.0",
+		golden: "output/template-only-on-skipped.txt",
+	}, {
+		name:   "keep the resources including the cluster",
+		cmd:    template + " --kube-version 1.26.0 --api-versions monitoring.coreos.com/v1/ServiceMonitor",
+		golden: "output/template-only-on-kept.txt",
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestTemplateRenderLimits(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "loop", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: loop
+data:
+  keys: "{{ range until 100 }}x{{ end }}"
+`)}},
+	}
+	repoURL := newChartServer(t, ch)
+	home := t.TempDir()
+	template := fmt.Sprintf("template loop loop --version 0.1.0 --repo %s --repository-config %s --repository-cache %s",
+		repoURL, filepath.Join(home, "repositories.yaml"), home)
+
+	if _, _, err := executeActionCommand(template); err != nil {
+		t.Errorf("expected no limits by default, got %v", err)
+	}
+	_, _, err := executeActionCommand(template + " --render-max-list-length 10")
+	if !engine.IsLimitExceeded(err) {
+		t.Errorf("expected a limit error, got %v", err)
+	}
+}
+
+func TestTemplateCapabilitiesFile(t *testing.T) {
+	ch, err := loader.Load("testdata/testcharts/only-on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := newChartServer(t, ch)
+	home := t.TempDir()
+	template := fmt.Sprintf("template only only-on --version 0.1.0 --repo %s --repository-config %s --repository-cache %s",
+		repoURL, filepath.Join(home, "repositories.yaml"), home)
+
+	files := map[string]string{
+		"old.json":     `{"kubeVersion": "v1.24.3", "apiVersions": ["v1", "apps/v1"]}`,
+		"recent.yaml":  "kubeVersion: v1.26.1\napiVersions: [v1, apps/v1, monitoring.coreos.com/v1, monitoring.coreos.com/v1/ServiceMonitor]\n",
+		"invalid.json": `{"apiVersions": ["v1"]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []cmdTestCase{{
+		name:   "render with the capabilities of an old cluster",
+		cmd:    template + " --capabilities-file " + filepath.Join(home, "old.json"),
+		golden: "output/template-only-on-skipped.txt",
+	}, {
+		name:   "render with the capabilities of a recent cluster",
+		cmd:    template + " --capabilities-file " + filepath.Join(home, "recent.yaml"),
+		golden: "output/template-only-on-kept.txt",
+	}, {
+		name:      "invalid capabilities file",
+		cmd:       template + " --capabilities-file " + filepath.Join(home, "invalid.json"),
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
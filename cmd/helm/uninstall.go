@@ -25,6 +25,8 @@ import (
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
 )
 
 const uninstallDesc = `
@@ -34,11 +36,15 @@ It removes all of the resources associated with the last release of the chart
 as well as the release history, freeing it up for future use.
 
 Use the '--dry-run' flag to see which releases will be uninstalled without actually
-uninstalling them.
+uninstalling them. The resources of the release that exist in the cluster and
+would be deleted, the ones that would be kept due to the "helm.sh/resource-policy"
+annotation and the delete hooks that would run are listed, e.g. as JSON with
+'--dry-run -o json'.
 `
 
 func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewUninstall(cfg)
+	var outfmt output.Format
 
 	cmd := &cobra.Command{
 		Use:        "uninstall RELEASE_NAME [...]",
@@ -51,19 +57,41 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// The releases are written as they are uninstalled in the table
+			// format, and all at once in the other formats.
+			results := make([]uninstallResult, 0, len(args))
 			for i := 0; i < len(args); i++ {
 
 				res, err := client.Run(args[i])
 				if err != nil {
+					// Report the releases uninstalled before the failure.
+					if outfmt != output.Table && len(results) > 0 {
+						return &resultError{err: err, result: &uninstallWriter{results}}
+					}
 					return err
 				}
-				if res != nil && res.Info != "" {
-					fmt.Fprintln(out, res.Info)
+				result := uninstallResult{Name: args[i], DryRun: client.DryRun}
+				if res != nil {
+					result.Info = res.Info
+					result.Deleted = res.Deleted
+					result.Kept = res.Kept
+					result.Hooks = res.Hooks
+					if res.Release != nil && res.Release.Info != nil {
+						result.Status = res.Release.Info.Status.String()
+					}
 				}
-
-				fmt.Fprintf(out, "release \"%s\" uninstalled\n", args[i])
+				if outfmt == output.Table {
+					if err := outfmt.Write(out, &uninstallWriter{[]uninstallResult{result}}); err != nil {
+						return err
+					}
+					continue
+				}
+				results = append(results, result)
+			}
+			if outfmt == output.Table {
+				return nil
 			}
-			return nil
+			return outfmt.Write(out, &uninstallWriter{results})
 		},
 	}
 
@@ -71,9 +99,76 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.DryRun, "dry-run", false, "simulate a uninstall")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during uninstallation")
 	f.BoolVar(&client.KeepHistory, "keep-history", false, "remove all associated resources and mark the release as deleted, but retain the release history")
+	f.BoolVar(&client.DeleteNamespace, "delete-namespace", false, "delete the release namespace if it was created by the installation of the release with --create-namespace, and neither other releases nor other resources are left in it. Ignored with --keep-history")
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all the resources are deleted before returning. It will wait for as long as --timeout")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
+	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
 }
+
+type uninstallResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status,omitempty"`
+	Info   string `json:"info,omitempty"`
+	DryRun bool   `json:"dryRun,omitempty"`
+	// Deleted are the resources deleted, or that would be deleted by a
+	// dry run.
+	Deleted []release.ResourceIdentity `json:"deleted,omitempty"`
+	// Kept are the resources kept due to their resource policy.
+	Kept []release.ResourceIdentity `json:"kept,omitempty"`
+	// Hooks are the delete hooks run, or that would be run by a dry run.
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+type uninstallWriter struct {
+	results []uninstallResult
+}
+
+func (w *uninstallWriter) WriteTable(out io.Writer) error {
+	for _, r := range w.results {
+		if r.DryRun {
+			writeUninstallPlan(out, r)
+			continue
+		}
+		if r.Info != "" {
+			fmt.Fprintln(out, r.Info)
+		}
+		fmt.Fprintf(out, "release \"%s\" uninstalled\n", r.Name)
+	}
+	return nil
+}
+
+// writeUninstallPlan writes what the dry run of the uninstallation of a
+// release would do.
+func writeUninstallPlan(out io.Writer, r uninstallResult) {
+	fmt.Fprintf(out, "release \"%s\" would be uninstalled\n", r.Name)
+	list := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintln(out, title)
+		for _, item := range items {
+			fmt.Fprintf(out, "  %s\n", item)
+		}
+	}
+	ids := func(resources []release.ResourceIdentity) []string {
+		items := make([]string, 0, len(resources))
+		for _, id := range resources {
+			items = append(items, id.String())
+		}
+		return items
+	}
+	list("RESOURCES TO DELETE:", ids(r.Deleted))
+	list("RESOURCES KEPT DUE TO THE RESOURCE POLICY:", ids(r.Kept))
+	list("HOOKS TO RUN:", r.Hooks)
+}
+
+func (w *uninstallWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.results)
+}
+
+func (w *uninstallWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.results)
+}
@@ -17,12 +17,23 @@ limitations under the License.
 package main
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/kube"
 	"github.com/open-hand/helm/pkg/release"
 )
 
 func TestUninstall(t *testing.T) {
+	withDeleteHook := release.Mock(&release.MockReleaseOptions{Name: "aeneas"})
+	withDeleteHook.Hooks = append(withDeleteHook.Hooks, &release.Hook{
+		Name:   "cleanup",
+		Kind:   "Job",
+		Events: []release.HookEvent{release.HookPreDelete},
+	})
+
 	tests := []cmdTestCase{
 		{
 			name:   "basic uninstall",
@@ -63,6 +74,27 @@ func TestUninstall(t *testing.T) {
 			golden: "output/uninstall-wait.txt",
 			rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
 		},
+		{
+			name:   "multiple uninstall with yaml output",
+			cmd:    "uninstall aeneas aeneas2 -o yaml",
+			golden: "output/uninstall-multiple-yaml.txt",
+			rels: []*release.Release{
+				release.Mock(&release.MockReleaseOptions{Name: "aeneas"}),
+				release.Mock(&release.MockReleaseOptions{Name: "aeneas2"}),
+			},
+		},
+		{
+			name:   "dry run",
+			cmd:    "uninstall aeneas --dry-run",
+			golden: "output/uninstall-dry-run.txt",
+			rels:   []*release.Release{withDeleteHook},
+		},
+		{
+			name:   "dry run with json output",
+			cmd:    "uninstall aeneas --dry-run -o json",
+			golden: "output/uninstall-dry-run.json",
+			rels:   []*release.Release{withDeleteHook},
+		},
 		{
 			name:      "uninstall without release",
 			cmd:       "uninstall",
@@ -81,3 +113,57 @@ func TestUninstallFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "uninstall", false)
 	checkFileCompletion(t, "uninstall myrelease", false)
 }
+
+func TestUninstallMetrics(t *testing.T) {
+	store := storageFixture()
+	if err := store.Create(release.Mock(&release.MockReleaseOptions{Name: "aeneas"})); err != nil {
+		t.Fatal(err)
+	}
+	m := &recordingMetrics{}
+	if _, err := executeWithMetrics(store, m, "uninstall aeneas"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := executeWithMetrics(store, m, "uninstall aeneas"); err == nil {
+		t.Fatal("expected uninstalling a missing release to fail")
+	}
+	expect := []string{"action uninstall false", "action uninstall true"}
+	if !reflect.DeepEqual(m.records, expect) {
+		t.Errorf("expected %v, got %v", expect, m.records)
+	}
+}
+
+func TestUninstallOwnership(t *testing.T) {
+	kc := helmtesting.NewKubeClient()
+	kc.Add(ownedClusterRole("other"), pruneConfigMap("web", nil))
+	rel := release.Mock(&release.MockReleaseOptions{Name: "web", Status: release.StatusDeployed})
+	rel.Manifest = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: shared
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`
+	store := storageFixture()
+	if err := store.Create(rel); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := *cmdRunner
+	runner.NewKubeClient = func() kube.Interface { return kc }
+	_, out, err := runner.Execute(store, nil, "uninstall web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "[ClusterRole] shared") {
+		t.Errorf("expected the cluster role to be listed as kept, got %q", out)
+	}
+	if _, ok := kc.Get("rbac.authorization.k8s.io/v1", "ClusterRole", "", "shared"); !ok {
+		t.Error("expected the cluster role owned by other to be kept")
+	}
+	if _, ok := kc.Get("v1", "ConfigMap", "default", "web"); ok {
+		t.Error("expected the config map of web to be deleted")
+	}
+}
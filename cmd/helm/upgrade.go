@@ -35,6 +35,7 @@ import (
 	"github.com/open-hand/helm/pkg/cli/output"
 	"github.com/open-hand/helm/pkg/cli/values"
 	"github.com/open-hand/helm/pkg/downloader"
+	"github.com/open-hand/helm/pkg/engine"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/storage/driver"
 )
@@ -64,13 +65,38 @@ last (right-most) set specified. For example, if both 'bar' and 'newbar' values
 set for a key called 'foo', the 'newbar' value would take precedence:
 
     $ helm upgrade --set foo=bar --set foo=newbar redis ./redis
+
+With '--reuse-values', the values of the last release are reused along with the
+default values of its chart, so that the defaults of the new chart are ignored.
+With '--reuse-values=smart', the values of the last release are merged with the
+defaults of the new chart instead: the values equal to the old defaults of their
+keys take the new defaults, and a warning is printed for them and for the values
+whose defaults were removed or renamed by the new chart.
+
+The values pinned on the release with 'helm values set' are applied over all of
+these, whether the values are reused or reset, until they are removed with
+'helm values unset'.
+
+With '--plan', nothing is changed: the command prints whether the release
+would be installed or upgraded, the resources that would be added, changed or
+deleted, the hooks that would run and the CRDs involved. With '--output json'
+the plan can be consumed by approval workflows before running the upgrade:
+
+    $ helm upgrade --install --plan -o json redis ./redis
+
+With '--check-permissions', nothing is changed either: the command lists the
+permissions the upgrade would need that the current identity lacks, and fails
+if any is missing.
 `
 
 func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewUpgrade(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", 0, "", "", false, "")
 	valueOpts := &values.Options{}
 	var outfmt output.Format
+	var notesTo []string
+	var plan, checkPermissions bool
 	var createNamespace bool
+	var namespaceOptions action.NamespaceOptions
 
 	cmd := &cobra.Command{
 		Use:   "upgrade [RELEASE] [CHART]",
@@ -102,6 +128,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					}
 					instClient := action.NewInstall(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", "", 0, "", "", "", false)
 					instClient.CreateNamespace = createNamespace
+					instClient.NamespaceOptions = namespaceOptions
 					instClient.ChartPathOptions = client.ChartPathOptions
 					instClient.DryRun = client.DryRun
 					instClient.DisableHooks = client.DisableHooks
@@ -109,29 +136,47 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					instClient.Timeout = client.Timeout
 					instClient.Wait = client.Wait
 					instClient.WaitForJobs = client.WaitForJobs
+					instClient.WaitStrategy = client.WaitStrategy
+					instClient.ApplyConcurrency = client.ApplyConcurrency
+					instClient.TakeOwnership = client.TakeOwnership
+					instClient.Waves = client.Waves
 					instClient.Devel = client.Devel
 					instClient.Namespace = client.Namespace
 					instClient.Atomic = client.Atomic
 					instClient.PostRenderer = client.PostRenderer
+					instClient.PolicyChecker = client.PolicyChecker
+					instClient.Preflight = client.Preflight
 					instClient.DisableOpenAPIValidation = client.DisableOpenAPIValidation
 					instClient.SubNotes = client.SubNotes
 					instClient.Description = client.Description
 					instClient.DependencyUpdate = client.DependencyUpdate
 
+					if plan {
+						p, err := planInstall(args, instClient, valueOpts, out)
+						if err != nil {
+							return errors.Wrap(err, "PLAN FAILED")
+						}
+						return outfmt.Write(out, &releasePlanWriter{p})
+					}
+					if checkPermissions {
+						report, err := checkInstallPermissions(args, instClient, valueOpts, out)
+						if err != nil {
+							return errors.Wrap(err, "PERMISSION CHECK FAILED")
+						}
+						return writePermissionReport(out, outfmt, report)
+					}
 					rel, err := runInstall(args, instClient, valueOpts, out)
 					if err != nil {
 						return err
 					}
-					return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false})
+					deliverNotes(rel, notesTo)
+					return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, nil})
 				} else if err != nil {
 					return err
 				}
 			}
 
-			if client.Version == "" && client.Devel {
-				debug("setting version to >0.0.0-0")
-				client.Version = ">0.0.0-0"
-			}
+			client.UseDevel(client.Devel)
 
 			chartPath, err := client.ChartPathOptions.LocateChart(args[1], settings)
 			if err != nil {
@@ -195,23 +240,47 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				cancel()
 			}()
 
+			if plan {
+				p, err := client.Plan(ctx, args[0], ch, vals)
+				if err != nil {
+					return errors.Wrap(err, "PLAN FAILED")
+				}
+				return outfmt.Write(out, &releasePlanWriter{p})
+			}
+			if checkPermissions {
+				report, err := client.CheckPermissions(ctx, args[0], ch, vals)
+				if err != nil {
+					return errors.Wrap(err, "PERMISSION CHECK FAILED")
+				}
+				return writePermissionReport(out, outfmt, report)
+			}
+
 			rel, err := client.RunWithContext(ctx, args[0], ch, vals, "")
+			for _, w := range client.ReuseValuesWarnings {
+				warning("%s", w)
+			}
+			warnCrossNamespace(client.CrossNamespaceResources)
+			warnDuplicateResources(client.DuplicateResources)
+			debugSkippedResources(client.SkippedResources)
 			if err != nil {
 				return errors.Wrap(err, "UPGRADE FAILED")
 			}
+			deliverNotes(rel, notesTo)
 
 			if outfmt == output.Table {
 				fmt.Fprintf(out, "Release %q has been upgraded. Happy Helming!\n", args[0])
+				printPruned(out, client)
 			}
 
-			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false})
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, nil})
 		},
 	}
 
 	f := cmd.Flags()
 	f.BoolVar(&createNamespace, "create-namespace", false, "if --install is set, create the release namespace if not present")
+	addNamespaceOptionsFlags(f, &namespaceOptions)
 	f.BoolVarP(&client.Install, "install", "i", false, "if a release by this name doesn't already exist, run an install")
-	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to --channel edge")
 	f.BoolVar(&client.DryRun, "dry-run", false, "simulate an upgrade")
 	f.BoolVar(&client.Recreate, "recreate-pods", false, "performs pods restart for the resource if applicable")
 	f.MarkDeprecated("recreate-pods", "functionality will no longer be updated. Consult the documentation for other methods to recreate pods")
@@ -221,19 +290,39 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.SkipCRDs, "skip-crds", false, "if set, no CRDs will be installed when an upgrade is performed with install flag enabled. By default, CRDs are installed if not already present, when an upgrade is performed with install flag enabled")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
 	f.BoolVar(&client.ResetValues, "reset-values", false, "when upgrading, reset the values to the ones built into the chart")
-	f.BoolVar(&client.ReuseValues, "reuse-values", false, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' is specified, this is ignored")
+	bindReuseValuesFlag(cmd, client)
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.Waves, "waves", false, "apply the resources in the waves of their \"helm.sh/wave\" annotation, each once the resources of the previous one are ready. It will wait for as long as --timeout for each wave")
+	f.BoolVar(&client.ResumeWaves, "resume-waves", false, "if set with --waves and the last release failed with the same manifest, skip the waves it completed")
 	f.BoolVar(&client.Atomic, "atomic", false, "if set, upgrade process rolls back changes made in case of failed upgrade. The --wait flag will be set automatically if --atomic is used")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
+	f.BoolVar(&client.Prune, "prune", false, "delete the resources applied by the previous revision, including the resources of its hooks, that the new revision does not apply. With --dry-run, list them")
+	f.BoolVar(&client.TakeOwnership, "take-ownership", false, "adopt the existing resources owned by other releases or not managed by Helm instead of failing")
+	f.BoolVar(&client.SkipUnchanged, "skip-unchanged", false, "do not patch the resources identical to the ones applied by the previous revision, if it succeeded. The digests of the resources are recorded in the release, so the first upgrade with this flag still patches them all")
 	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this upgrade when upgrade fails")
 	f.BoolVar(&client.SubNotes, "render-subchart-notes", false, "if set, render subchart notes along with the parent")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
 	f.BoolVar(&client.DependencyUpdate, "dependency-update", false, "update dependencies if they are missing before installing the chart")
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 	addValueOptionsFlags(f, valueOpts)
+	valueOpts.DefaultValueFiles = settings.ValuesFiles()
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer)
+	bindPolicyCheckFlag(cmd, &client.PolicyChecker)
+	bindNamespacePolicyFlags(cmd, &cfg.NamespacePolicy)
+	bindRenderLimitsFlags(cmd, &cfg.RenderLimits, engine.Limits{})
+	bindDuplicateResourcesFlag(cmd, &cfg.DuplicateResources)
+	bindDetectFeaturesFlag(cmd, &cfg.DetectFeatures)
+	bindPruneHistoryFlag(cmd, &cfg.Retention)
+	bindPreflightFlag(cmd, &client.Preflight)
+	bindWaitStrategyFlag(cmd, &client.WaitStrategy)
+	bindApplyConcurrencyFlag(cmd, &client.ApplyConcurrency)
+	bindServerSideApplyFlags(cmd, &cfg.ApplyOptions)
+	bindMigrateAPIsFlag(cmd, &client.MigrateAPIs)
+	bindNotesToFlag(cmd, &notesTo)
+	bindPlanFlag(cmd, &plan)
+	bindCheckPermissionsFlag(cmd, &checkPermissions)
 
 	err := cmd.RegisterFlagCompletionFunc("version", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) != 2 {
@@ -246,5 +335,27 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		log.Fatal(err)
 	}
 
+	bindSetFlagsCompletion(cmd, func(args []string) (string, bool) {
+		if len(args) != 2 {
+			return "", false
+		}
+		return args[1], true
+	}, &client.Version)
+
 	return cmd
 }
+
+// printPruned lists the resources pruned by the upgrade.
+func printPruned(out io.Writer, client *action.Upgrade) {
+	if len(client.Pruned) == 0 {
+		return
+	}
+	if client.DryRun {
+		fmt.Fprintln(out, "Resources that would be pruned:")
+	} else {
+		fmt.Fprintln(out, "Pruned resources:")
+	}
+	for _, id := range client.Pruned {
+		fmt.Fprintf(out, "  %s\n", id)
+	}
+}
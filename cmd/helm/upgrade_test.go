@@ -21,14 +21,24 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/open-hand/helm/internal/test"
 	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/action"
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/kube"
 	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
 )
 
 func TestUpgradeCmd(t *testing.T) {
@@ -392,6 +402,276 @@ func prepareMockRelease(releaseName string, t *testing.T) (func(n string, v int,
 	return relMock, ch, chartPath
 }
 
+func TestUpgradeWithSmartReuseValues(t *testing.T) {
+	webChart := func(version, tag string) *chart.Chart {
+		return &chart.Chart{
+			Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: version},
+			Values:   map[string]interface{}{"tag": tag, "replicas": 1},
+			Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("tag: \"" + tag + "\"\nreplicas: 1\n")}},
+			Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  tag: {{ .Values.tag | quote }}
+  replicas: {{ .Values.replicas | quote }}
+`)}},
+		}
+	}
+	repoURL := newChartServer(t, webChart("0.2.0", "2.0"))
+
+	for _, tt := range []struct {
+		reuse  string
+		expect string
+	}{
+		{"--reuse-values", `tag: "1.0"`},
+		{"--reuse-values=smart", `tag: "2.0"`},
+	} {
+		store := storageFixture()
+		if err := store.Create(&release.Release{
+			Name:      "web",
+			Namespace: "default",
+			Version:   1,
+			Chart:     webChart("0.1.0", "1.0"),
+			Config:    map[string]interface{}{"replicas": 3},
+			Info:      &release.Info{Status: release.StatusDeployed},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := fmt.Sprintf("upgrade web web --version 0.2.0 --repo %s %s", repoURL, tt.reuse)
+		if _, _, err := executeActionCommandC(store, cmd); err != nil {
+			t.Fatalf("%s: %s", tt.reuse, err)
+		}
+		rel, err := store.Get("web", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(rel.Manifest, tt.expect) || !strings.Contains(rel.Manifest, `replicas: "3"`) {
+			t.Errorf("%s: expected %s and the reused replicas in the manifest, got\n%s", tt.reuse, tt.expect, rel.Manifest)
+		}
+	}
+}
+
+func TestUpgradePruneHistory(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`)}},
+	}
+	repoURL := newChartServer(t, ch)
+
+	for _, tt := range []struct {
+		flags  string
+		expect int
+	}{
+		{"", 14},
+		{"--prune-history", 11},
+	} {
+		store := storageFixture()
+		for v := 1; v <= 13; v++ {
+			status := release.StatusSuperseded
+			if v == 13 {
+				status = release.StatusDeployed
+			}
+			if err := store.Create(release.Mock(&release.MockReleaseOptions{Name: "web", Version: v, Chart: ch, Status: status})); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cmd := fmt.Sprintf("upgrade web web --version 0.1.0 --repo %s --history-max 0 %s", repoURL, tt.flags)
+		if _, _, err := executeActionCommandC(store, cmd); err != nil {
+			t.Fatalf("%q: %s", tt.flags, err)
+		}
+		h, err := store.History("web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(h) != tt.expect {
+			t.Errorf("%q: expected %d revisions, got %d", tt.flags, tt.expect, len(h))
+		}
+	}
+}
+
+func pruneConfigMap(name string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func TestUpgradePrune(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`)}},
+	}
+	repoURL := newChartServer(t, ch)
+
+	for _, tt := range []struct {
+		flags  string
+		golden string
+		exists bool
+	}{
+		{"--dry-run", "output/upgrade-prune-dry-run.txt", true},
+		{"", "output/upgrade-prune.txt", false},
+	} {
+		kc := helmtesting.NewKubeClient()
+		kc.Add(
+			pruneConfigMap("web", nil),
+			pruneConfigMap("stale", nil),
+			pruneConfigMap("kept", map[string]string{kube.ResourcePolicyAnno: kube.KeepPolicy}),
+			pruneConfigMap("shared", map[string]string{"meta.helm.sh/release-name": "other"}),
+		)
+		rel := release.Mock(&release.MockReleaseOptions{Name: "web", Chart: ch, Status: release.StatusDeployed})
+		rel.Manifest = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n"
+		for _, name := range []string{"web", "stale", "kept", "shared", "deleted"} {
+			rel.Info.Resources = append(rel.Info.Resources, release.ResourceIdentity{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: name})
+		}
+		store := storageFixture()
+		if err := store.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+
+		runner := *cmdRunner
+		runner.NewKubeClient = func() kube.Interface { return kc }
+		cmd := fmt.Sprintf("upgrade web web --version 0.1.0 --repo %s --prune %s", repoURL, tt.flags)
+		_, out, err := runner.Execute(store, nil, cmd)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.flags, err)
+		}
+		test.AssertGoldenString(t, out, tt.golden)
+
+		if _, ok := kc.Get("v1", "ConfigMap", "default", "stale"); ok != tt.exists {
+			t.Errorf("%q: expected the stale config map to exist: %t", tt.flags, tt.exists)
+		}
+		for _, name := range []string{"web", "kept", "shared"} {
+			if _, ok := kc.Get("v1", "ConfigMap", "default", name); !ok {
+				t.Errorf("%q: expected config map %s not to be pruned", tt.flags, name)
+			}
+		}
+	}
+}
+
+func TestUpgradeOwnership(t *testing.T) {
+	ch := clusterRoleChart("rbac")
+	repoURL := newChartServer(t, ch)
+	upgrade := fmt.Sprintf("upgrade web rbac --version 0.1.0 --repo %s", repoURL)
+
+	for _, tt := range []struct {
+		owner string
+		flags string
+		fails bool
+	}{
+		{"web", "", false},
+		{"other", "", true},
+		{"other", " --take-ownership", false},
+	} {
+		kc := helmtesting.NewKubeClient()
+		kc.Add(ownedClusterRole(tt.owner))
+		rel := release.Mock(&release.MockReleaseOptions{Name: "web", Chart: ch, Status: release.StatusDeployed})
+		rel.Manifest = "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: shared\n"
+		store := storageFixture()
+		if err := store.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+
+		runner := *cmdRunner
+		runner.NewKubeClient = func() kube.Interface { return kc }
+		_, _, err := runner.Execute(store, nil, upgrade+tt.flags)
+		if tt.fails {
+			if !action.IsResourceOwnership(err) {
+				t.Errorf("%s%s: expected a resource ownership error, got %v", tt.owner, tt.flags, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s%s: %s", tt.owner, tt.flags, err)
+		}
+	}
+}
+
+// recordingMetrics records the measurements of the actions as
+// "<kind> <name> <error>" lines.
+type recordingMetrics struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (m *recordingMetrics) record(kind, name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, fmt.Sprintf("%s %s %t", kind, name, err != nil))
+}
+
+func (m *recordingMetrics) ObserveOperation(string, string, time.Duration, error) {}
+func (m *recordingMetrics) ObserveRecordSize(string, int)                         {}
+
+func (m *recordingMetrics) ObserveAction(name string, _ time.Duration, err error) {
+	m.record("action", name, err)
+}
+
+func (m *recordingMetrics) ObserveHook(event string, _ time.Duration, err error) {
+	m.record("hook", event, err)
+}
+
+func (m *recordingMetrics) ObserveChartDownload(chart string, _ time.Duration, err error) {
+	m.record("download", chart, err)
+}
+
+// executeWithMetrics runs cmd against store, recording the measurements of
+// its actions in m.
+func executeWithMetrics(store *storage.Storage, m *recordingMetrics, cmd string) (string, error) {
+	runner := *cmdRunner
+	runner.Configure = func(cfg *action.Configuration) { cfg.Metrics = m }
+	_, out, err := runner.Execute(store, nil, cmd)
+	return out, err
+}
+
+func TestUpgradeMetrics(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`)}},
+	}
+	repoURL := newChartServer(t, ch)
+	chartPath := filepath.Join(t.TempDir(), "web")
+	if err := chartutil.SaveDir(ch, filepath.Dir(chartPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	store := storageFixture()
+	m := &recordingMetrics{}
+	if _, err := executeWithMetrics(store, m, fmt.Sprintf("upgrade web web --install --version 0.1.0 --repo %s", repoURL)); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"download web false", "action install false"}
+	if !reflect.DeepEqual(m.records, expect) {
+		t.Errorf("expected %v, got %v", expect, m.records)
+	}
+
+	// Charts on disk are not downloaded, whether or not they load.
+	m = &recordingMetrics{}
+	executeWithMetrics(store, m, "upgrade web "+chartPath)
+	for _, r := range m.records {
+		if strings.HasPrefix(r, "download ") {
+			t.Errorf("expected no download of a chart on disk, got %v", m.records)
+		}
+	}
+}
+
 func TestUpgradeOutputCompletion(t *testing.T) {
 	outputFlagCompletionTest(t, "upgrade")
 }
@@ -0,0 +1,186 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/cmd/helm/require"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+const valuesHelp = `
+This command consists of multiple subcommands to manage the values pinned on a
+release.
+
+Pinned overrides are stored with the release, apart from its values, and are
+applied over the values on every upgrade of the release until they are
+removed, whether the values of the upgrade are reused with '--reuse-values' or
+reset with '--reset-values'. They take precedence over the values given to the
+upgrade.
+
+'helm get values' lists the overrides pinned on a release along with its values.
+`
+
+const valuesSetHelp = `
+This command pins values on a release. The values are given as with '--set',
+one key=value pair per argument, and replace the overrides already pinned on
+the same keys. They are applied from the next upgrade of the release.
+
+    $ helm values set my-release image.tag=1.2.3 replicaCount=3
+`
+
+const valuesUnsetHelp = `
+This command removes overrides pinned on a release. The values of the release
+are left as they are until its next upgrade.
+
+    $ helm values unset my-release image.tag
+`
+
+func newValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "values",
+		Short: "manage the values pinned on a release",
+		Long:  valuesHelp,
+		Args:  require.NoArgs,
+	}
+	cmd.AddCommand(
+		newValuesSetCmd(cfg, out),
+		newValuesUnsetCmd(cfg, out),
+		newValuesListCmd(cfg, out),
+	)
+	return cmd
+}
+
+func newValuesSetCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseOverrides(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:               "set RELEASE_NAME KEY=VALUE [...]",
+		Short:             "pin values on a release",
+		Long:              valuesSetHelp,
+		Args:              require.MinimumNArgs(2),
+		ValidArgsFunction: compReleaseFirstArg(cfg),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := client.Set(args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &overridesWriter{overrides})
+		},
+	}
+	bindOutputFlag(cmd, &outfmt)
+	return cmd
+}
+
+func newValuesUnsetCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseOverrides(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:               "unset RELEASE_NAME KEY [...]",
+		Short:             "remove values pinned on a release",
+		Long:              valuesUnsetHelp,
+		Args:              require.MinimumNArgs(2),
+		ValidArgsFunction: compReleaseFirstArg(cfg),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := client.Unset(args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &overridesWriter{overrides})
+		},
+	}
+	bindOutputFlag(cmd, &outfmt)
+	return cmd
+}
+
+func newValuesListCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReleaseOverrides(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:               "list RELEASE_NAME",
+		Aliases:           []string{"ls"},
+		Short:             "list the values pinned on a release",
+		Args:              require.ExactArgs(1),
+		ValidArgsFunction: compReleaseFirstArg(cfg),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := client.List(args[0])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &overridesWriter{overrides})
+		},
+	}
+	bindOutputFlag(cmd, &outfmt)
+	return cmd
+}
+
+// compReleaseFirstArg completes the release name given as the first argument.
+func compReleaseFirstArg(cfg *action.Configuration) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return compListReleases(toComplete, args, cfg)
+	}
+}
+
+type overridesWriter struct {
+	overrides []*release.PinnedOverride
+}
+
+func (w *overridesWriter) WriteTable(out io.Writer) error {
+	if len(w.overrides) == 0 {
+		fmt.Fprintln(out, "No pinned overrides")
+		return nil
+	}
+	return output.EncodeTable(out, overridesTable(w.overrides))
+}
+
+func (w *overridesWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, w.list())
+}
+
+func (w *overridesWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, w.list())
+}
+
+func (w *overridesWriter) list() []*release.PinnedOverride {
+	if w.overrides == nil {
+		return []*release.PinnedOverride{}
+	}
+	return w.overrides
+}
+
+func overridesTable(overrides []*release.PinnedOverride) *uitable.Table {
+	table := uitable.New()
+	table.AddRow("KEY", "VALUE", "REVISION", "SET AT")
+	for _, o := range overrides {
+		table.AddRow(o.Key, o.Value, o.Revision, o.SetAt.Format(time.ANSIC))
+	}
+	return table
+}
@@ -0,0 +1,151 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+	helmtime "github.com/open-hand/helm/pkg/time"
+)
+
+func pinnedRelease() *release.Release {
+	rel := release.Mock(&release.MockReleaseOptions{Name: "thomas-guide", Version: 2})
+	rel.Config = map[string]interface{}{"name": "value"}
+	rel.Overrides = []*release.PinnedOverride{
+		{Key: "image.tag", Value: "1.2.3", SetAt: helmtime.Unix(242085845, 0).UTC(), Revision: 1},
+	}
+	return rel
+}
+
+func TestValuesCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "list pinned overrides",
+		cmd:    "values list thomas-guide",
+		golden: "output/values-list.txt",
+		rels:   []*release.Release{pinnedRelease()},
+	}, {
+		name:   "list pinned overrides as json",
+		cmd:    "values list thomas-guide -o json",
+		golden: "output/values-list.json",
+		rels:   []*release.Release{pinnedRelease()},
+	}, {
+		name:   "get values with pinned overrides",
+		cmd:    "get values thomas-guide",
+		golden: "output/get-values-pinned.txt",
+		rels:   []*release.Release{pinnedRelease()},
+	}, {
+		name:      "set a malformed override",
+		cmd:       "values set thomas-guide image.tag",
+		golden:    "output/values-set-malformed.txt",
+		rels:      []*release.Release{pinnedRelease()},
+		wantError: true,
+	}, {
+		name:      "unset a key without an override",
+		cmd:       "values unset thomas-guide replicaCount",
+		golden:    "output/values-unset-missing.txt",
+		rels:      []*release.Release{pinnedRelease()},
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestValuesSetUnset(t *testing.T) {
+	store := storageFixture()
+	if err := store.Create(pinnedRelease()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := executeActionCommandC(store, "values set thomas-guide image.tag=2.0.0 replicaCount=3"); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := store.Last("thomas-guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rel.Overrides) != 2 {
+		t.Fatalf("Expected 2 overrides, got %d", len(rel.Overrides))
+	}
+	for i, want := range []string{"image.tag=2.0.0", "replicaCount=3"} {
+		if got := rel.Overrides[i].Key + "=" + rel.Overrides[i].Value; got != want {
+			t.Errorf("Expected override %q, got %q", want, got)
+		}
+		if rel.Overrides[i].Revision != 2 {
+			t.Errorf("Expected override %q pinned on revision 2, got %d", want, rel.Overrides[i].Revision)
+		}
+	}
+
+	if _, _, err := executeActionCommandC(store, "values unset thomas-guide image.tag"); err != nil {
+		t.Fatal(err)
+	}
+	rel, err = store.Last("thomas-guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rel.Overrides) != 1 || rel.Overrides[0].Key != "replicaCount" {
+		t.Errorf("Expected only the override of replicaCount, got %v", rel.Overrides)
+	}
+}
+
+func TestUpgradeAppliesPinnedOverrides(t *testing.T) {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "web", Version: "0.2.0"},
+		Values:   map[string]interface{}{"tag": "latest"},
+		Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  tag: {{ .Values.tag }}
+`)}},
+	}
+	repoURL := newChartServer(t, ch)
+
+	store := storageFixture()
+	if err := store.Create(&release.Release{
+		Name:      "web",
+		Namespace: "default",
+		Version:   1,
+		Chart:     ch,
+		Config:    map[string]interface{}{"tag": "1.0.0"},
+		Overrides: []*release.PinnedOverride{{Key: "tag", Value: "1.0.1-hotfix", Revision: 1}},
+		Info:      &release.Info{Status: release.StatusDeployed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := fmt.Sprintf("upgrade web web --version 0.2.0 --repo %s --reset-values --set tag=2.0.0", repoURL)
+	if _, _, err := executeActionCommandC(store, cmd); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := store.Get("web", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rel.Manifest, "tag: 1.0.1-hotfix") {
+		t.Errorf("Expected the pinned override in the manifest, got\n%s", rel.Manifest)
+	}
+	if rel.Config["tag"] != "2.0.0" {
+		t.Errorf("Expected the values of the upgrade to be stored without the overrides, got %v", rel.Config)
+	}
+	if len(rel.Overrides) != 1 || rel.Overrides[0].Key != "tag" {
+		t.Errorf("Expected the pinned override to be kept, got %v", rel.Overrides)
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/open-hand/helm/cmd/helm/require"
 	"github.com/open-hand/helm/internal/version"
+	"github.com/open-hand/helm/pkg/cli/output"
 )
 
 const versionDesc = `
@@ -50,11 +51,14 @@ the template:
 - .GoVersion contains the version of Go that Helm was compiled with
 
 For example, --template='Version: {{.Version}}' outputs 'Version: v3.2.1'.
+
+The --output flag prints the version information in JSON or YAML instead.
 `
 
 type versionOptions struct {
 	short    bool
 	template string
+	outfmt   output.Format
 }
 
 func newVersionCmd(out io.Writer) *cobra.Command {
@@ -75,11 +79,18 @@ func newVersionCmd(out io.Writer) *cobra.Command {
 	f.StringVar(&o.template, "template", "", "template for version string format")
 	f.BoolP("client", "c", true, "display client version information")
 	f.MarkHidden("client")
+	bindOutputFlag(cmd, &o.outfmt)
 
 	return cmd
 }
 
 func (o *versionOptions) run(out io.Writer) error {
+	switch o.outfmt {
+	case output.JSON:
+		return output.EncodeJSON(out, version.Get())
+	case output.YAML:
+		return output.EncodeYAML(out, version.Get())
+	}
 	if o.template != "" {
 		tt, err := template.New("_").Parse(o.template)
 		if err != nil {
@@ -32,6 +32,10 @@ func TestVersion(t *testing.T) {
 		name:   "template",
 		cmd:    "version --template='Version: {{.Version}}'",
 		golden: "output/version-template.txt",
+	}, {
+		name:   "json",
+		cmd:    "version -o json",
+		golden: "output/version-json.txt",
 	}, {
 		name:   "client",
 		cmd:    "version --client",
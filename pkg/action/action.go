@@ -18,14 +18,19 @@ package action
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/discovery"
@@ -34,8 +39,12 @@ import (
 
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
 	"github.com/open-hand/helm/pkg/engine"
 	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/logging"
+	"github.com/open-hand/helm/pkg/plugin"
+	"github.com/open-hand/helm/pkg/policy"
 	"github.com/open-hand/helm/pkg/postrender"
 	"github.com/open-hand/helm/pkg/registry"
 	"github.com/open-hand/helm/pkg/release"
@@ -96,26 +105,130 @@ type Configuration struct {
 	// Capabilities describes the capabilities of the Kubernetes cluster.
 	Capabilities *chartutil.Capabilities
 
+	// NamespaceStorage returns the release storage of a single namespace.
+	// It is set by Init for drivers that keep releases in namespaced objects,
+	// and lets List query the namespaces of a cluster in parallel.
+	NamespaceStorage func(namespace string) *storage.Storage
+
+	// ReadOnly makes the actions changing the cluster or the release records,
+	// such as Install, Upgrade and Uninstall, fail with a ReadOnlyError,
+	// while the actions reading them, such as Get, List, Status and the dry
+	// runs, work normally. If set before calling Init, the Kubernetes
+	// clients and the release storage built by Init also refuse to change
+	// anything, guarding against the code paths missing a check.
+	ReadOnly bool
+
+	// StorageNamespace, if set, is the namespace the secret and configmap
+	// drivers keep the release records in instead of the namespaces of the
+	// releases, so that the access to the release history can be restricted
+	// apart from the namespaces the releases deploy to. Init sets it from
+	// $HELM_DRIVER_NAMESPACE if empty. See MigrateStorage to move existing
+	// records.
+	StorageNamespace string
+
+	// ReleaseNamespaceStorage returns the storage of the release records
+	// kept in the namespace of their release, as when StorageNamespace is
+	// empty. Init sets it along with StorageNamespace, for MigrateStorage.
+	ReleaseNamespaceStorage func(namespace string) *storage.Storage
+
+	// Retention is the policy HistoryPrune applies when pruning release
+	// history. If set, Install and Upgrade also apply it to the history of
+	// the release once they succeed. If nil, HistoryPrune uses
+	// storage.DefaultRetentionPolicy and the history is never pruned
+	// automatically.
+	Retention *storage.RetentionPolicy
+
+	// SignaturePolicy, if set, requires the charts downloaded by actions to
+	// carry a verified cosign signature.
+	SignaturePolicy *SignaturePolicy
+
+	// OperationPolicies are checked before Install, Upgrade, Rollback and
+	// Uninstall change anything, and can reject their operation with an
+	// OperationRejectedError. They are not checked for dry runs.
+	OperationPolicies []OperationPolicy
+
+	// RateLimit configures the client-side rate limiting of the Kubernetes
+	// clients built by Init. It must be set before calling Init.
+	RateLimit kube.RateLimitOptions
+
+	// DetectFeatures enables the detection of the features of the cluster
+	// exposed to the templates as .Capabilities.Features, such as its cloud
+	// provider and its default StorageClass. The detection makes extra
+	// requests to the cluster the first time the capabilities are needed.
+	DetectFeatures bool
+
+	// DiscoveryCache, if set, shares the discovery information of the
+	// cluster with the other Configurations using it. It must be set before
+	// calling Init.
+	DiscoveryCache *kube.DiscoveryCache
+
+	// Metrics, if set, receives the measurements of the actions and of the
+	// release storage. It must be set before calling Init.
+	Metrics Metrics
+
+	// TracerProvider, if set, provides the tracer recording the spans of
+	// the phases of the actions, such as rendering, applying the resources
+	// and waiting for them.
+	TracerProvider trace.TracerProvider
+
+	// ApplyOptions are the options Install, Upgrade and Rollback create and
+	// update the resources with, such as the manager of the fields they set
+	// and how server-side applies resolve conflicts with other managers.
+	// The concurrency set by the actions overrides the one set here.
+	ApplyOptions kube.ApplyOptions
+
+	// NamespacePolicy controls the rendered resources of Install and Upgrade,
+	// and the resources Rollback rolls back to, targeting other namespaces
+	// than the namespace of their release or cluster-scoped.
+	NamespacePolicy NamespacePolicy
+
+	// DuplicateResources is what Install and Upgrade do with the rendered
+	// resources of a release sharing the same identity,
+	// DuplicateResourcesError if empty.
+	DuplicateResources DuplicateResourcesMode
+
+	// RenderLimits bound the time and the memory used to render the
+	// templates of the charts, for the services rendering untrusted charts.
+	// A render exceeding them fails with an engine.LimitError. The zero value
+	// sets no limits, see engine.DefaultLimits for sane ones.
+	RenderLimits engine.Limits
+
+	// Logger, if set, receives the messages of the actions with their level
+	// and the fields identifying their release. Init logs the debug messages
+	// of the Kubernetes client and of the storage to it if given no log
+	// function.
+	Logger logging.Logger
+
+	// User is recorded as the author of the release revisions created by
+	// the actions. Init sets it to the user of the Kubernetes clients if it
+	// is empty.
+	User string
+
 	Log func(string, ...interface{})
+
+	// closers are closed by Close, e.g. the plugin storage driver.
+	closers []io.Closer
 }
 
-// renderResources renders the templates in a chart
+// renderResources renders the templates in a chart. The resources whose
+// only-on annotations exclude the cluster are left out and returned as
+// skipped.
 //
 // TODO: This function is badly in need of a refactor.
 // TODO: As part of the refactor the duplicate code in cmd/helm/template.go should be removed
 //       This code has to do with writing files to disk.
-func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Values, releaseName, outputDir string, subNotes, useReleaseName, includeCrds bool, pr postrender.PostRenderer, dryRun bool) ([]*release.Hook, *bytes.Buffer, string, error) {
+func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Values, releaseName, outputDir string, subNotes, useReleaseName, includeCrds bool, pr postrender.PostRenderer, dryRun bool) ([]*release.Hook, *bytes.Buffer, string, []SkippedResource, error) {
 	hs := []*release.Hook{}
 	b := bytes.NewBuffer(nil)
 
 	caps, err := cfg.getCapabilities()
 	if err != nil {
-		return hs, b, "", err
+		return hs, b, "", nil, err
 	}
 
 	if ch.Metadata.KubeVersion != "" {
 		if !chartutil.IsCompatibleRange(ch.Metadata.KubeVersion, caps.KubeVersion.String()) {
-			return hs, b, "", errors.Errorf("chart requires kubeVersion: %s which is incompatible with Kubernetes %s", ch.Metadata.KubeVersion, caps.KubeVersion.String())
+			return hs, b, "", nil, errors.Errorf("chart requires kubeVersion: %s which is incompatible with Kubernetes %s", ch.Metadata.KubeVersion, caps.KubeVersion.String())
 		}
 	}
 
@@ -130,15 +243,17 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 	if !dryRun && cfg.RESTClientGetter != nil {
 		restConfig, err := cfg.RESTClientGetter.ToRESTConfig()
 		if err != nil {
-			return hs, b, "", err
+			return hs, b, "", nil, err
 		}
-		files, err2 = engine.RenderWithClient(ch, values, restConfig)
+		e := engine.New(restConfig)
+		e.Limits = cfg.RenderLimits
+		files, err2 = e.Render(ch, values)
 	} else {
-		files, err2 = engine.Render(ch, values)
+		files, err2 = engine.Engine{Limits: cfg.RenderLimits}.Render(ch, values)
 	}
 
 	if err2 != nil {
-		return hs, b, "", err2
+		return hs, b, "", nil, err2
 	}
 
 	// NOTES.txt gets rendered like all the other files, but because it's not a hook nor a resource,
@@ -147,7 +262,8 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 	// look for terminating NOTES.txt. We also remove it from the files so that we don't have to skip
 	// it in the sortHooks.
 	var notesBuffer bytes.Buffer
-	for k, v := range files {
+	for _, k := range sortedFileNames(files) {
+		v := files[k]
 		if strings.HasSuffix(k, notesFileSuffix) {
 			if subNotes || (k == path.Join(ch.Name(), "templates", notesFileSuffix)) {
 				// If buffer contains data, add newline before adding more
@@ -171,13 +287,29 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 		//
 		// We return the files as a big blob of data to help the user debug parser
 		// errors.
-		for name, content := range files {
+		for _, name := range sortedFileNames(files) {
+			content := files[name]
 			if strings.TrimSpace(content) == "" {
 				continue
 			}
 			fmt.Fprintf(b, "---\n# Source: %s\n%s\n", name, content)
 		}
-		return hs, b, "", err
+		return hs, b, "", nil, err
+	}
+	// Hooks are not post-rendered, while the only-on annotations of the
+	// manifest are checked after the post-renderer runs when there is one,
+	// as it may add or change them. The files of outputDir are written
+	// before post-rendering, so the manifests are checked first for them.
+	hs, skipped, err := skipHooks(hs, caps)
+	if err != nil {
+		return hs, b, "", nil, err
+	}
+	if pr == nil || outputDir != "" {
+		var skippedManifests []SkippedResource
+		if manifests, skippedManifests, err = skipManifests(manifests, caps); err != nil {
+			return hs, b, "", nil, err
+		}
+		skipped = append(skipped, skippedManifests...)
 	}
 
 	// Aggregate all valid manifests into one big doc.
@@ -186,11 +318,13 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 	if includeCrds {
 		for _, crd := range ch.CRDObjects() {
 			if outputDir == "" {
-				fmt.Fprintf(b, "---\n# Source: %s\n%s\n", crd.Name, string(crd.File.Data[:]))
+				for _, doc := range releaseutil.SplitManifestDocuments(string(crd.File.Data)) {
+					fmt.Fprintf(b, "---\n# Source: %s\n%s\n", crd.Name, doc)
+				}
 			} else {
 				err = writeToFile(outputDir, crd.Filename, string(crd.File.Data[:]), fileWritten[crd.Name])
 				if err != nil {
-					return hs, b, "", err
+					return hs, b, "", nil, err
 				}
 				fileWritten[crd.Name] = true
 			}
@@ -211,7 +345,7 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 			// used by install or upgrade
 			err = writeToFile(newDir, m.Name, m.Content, fileWritten[m.Name])
 			if err != nil {
-				return hs, b, "", err
+				return hs, b, "", nil, err
 			}
 			fileWritten[m.Name] = true
 		}
@@ -220,11 +354,39 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 	if pr != nil {
 		b, err = pr.Run(b)
 		if err != nil {
-			return hs, b, notes, errors.Wrap(err, "error while running post render on files")
+			return hs, b, notes, nil, errors.Wrap(err, "error while running post render on files")
+		}
+		var skippedManifests []SkippedResource
+		if b, skippedManifests, err = skipPostRendered(b, caps); err != nil {
+			return hs, b, notes, nil, err
 		}
+		skipped = append(skipped, skippedManifests...)
 	}
 
-	return hs, b, notes, nil
+	return hs, b, notes, skipped, nil
+}
+
+// sortedFileNames returns the names of the rendered files in order.
+func sortedFileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkPolicies evaluates the policies of checker on the rendered manifest
+// and hooks of rel.
+func checkPolicies(checker *policy.Checker, rel *release.Release) error {
+	if checker == nil {
+		return nil
+	}
+	manifests := []string{rel.Manifest}
+	for _, h := range rel.Hooks {
+		manifests = append(manifests, fmt.Sprintf("---\n# Source: %s\n%s", h.Path, h.Manifest))
+	}
+	return checker.Check(manifests...)
 }
 
 // RESTClientGetter gets the rest client
@@ -242,12 +404,18 @@ func (cfg *Configuration) getCapabilities() (*chartutil.Capabilities, error) {
 	if cfg.Capabilities != nil {
 		return cfg.Capabilities, nil
 	}
+	if cfg.RESTClientGetter == nil {
+		return nil, errors.New("could not get Kubernetes discovery client: no Kubernetes configuration")
+	}
 	dc, err := cfg.RESTClientGetter.ToDiscoveryClient()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get Kubernetes discovery client")
 	}
-	// force a discovery cache invalidation to always fetch the latest server version/capabilities.
-	dc.Invalidate()
+	// force a discovery cache invalidation to always fetch the latest server version/capabilities,
+	// unless the information is shared, in which case it expires on its own.
+	if cfg.DiscoveryCache == nil {
+		dc.Invalidate()
+	}
 	kubeVersion, err := dc.ServerVersion()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get server version from Kubernetes")
@@ -267,7 +435,7 @@ func (cfg *Configuration) getCapabilities() (*chartutil.Capabilities, error) {
 		}
 	}
 
-	cfg.Capabilities = &chartutil.Capabilities{
+	caps := &chartutil.Capabilities{
 		APIVersions: apiVersions,
 		KubeVersion: chartutil.KubeVersion{
 			Version: kubeVersion.GitVersion,
@@ -276,11 +444,32 @@ func (cfg *Configuration) getCapabilities() (*chartutil.Capabilities, error) {
 		},
 		HelmVersion: chartutil.DefaultCapabilities.HelmVersion,
 	}
+	if cfg.DetectFeatures {
+		if kc, ok := cfg.KubeClient.(kube.InterfaceFeatures); ok {
+			features, err := kc.DetectFeatures()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not detect the features of the cluster")
+			}
+			caps.Features = chartutil.Features{
+				Detected:            true,
+				CloudProvider:       features.CloudProvider,
+				CNI:                 features.CNI,
+				DefaultStorageClass: features.DefaultStorageClass,
+				IPv6:                features.IPv6,
+				DualStack:           features.DualStack,
+				PodSecurity:         features.PodSecurity,
+			}
+		}
+	}
+	cfg.Capabilities = caps
 	return cfg.Capabilities, nil
 }
 
 // KubernetesClientSet creates a new kubernetes ClientSet based on the configuration
 func (cfg *Configuration) KubernetesClientSet() (kubernetes.Interface, error) {
+	if cfg.RESTClientGetter == nil {
+		return nil, errors.New("unable to generate config for kubernetes client: the configuration is not initialized")
+	}
 	conf, err := cfg.RESTClientGetter.ToRESTConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to generate config for kubernetes client")
@@ -297,6 +486,13 @@ func (cfg *Configuration) Now() time.Time {
 	return Timestamper()
 }
 
+// markCompleted records that the operation creating the revision rel has
+// completed, successfully or not.
+func (cfg *Configuration) markCompleted(rel *release.Release) {
+	now := cfg.Now()
+	rel.Info.Completed = &now
+}
+
 func (cfg *Configuration) releaseContent(name string, version int) (*release.Release, error) {
 	if err := chartutil.ValidateReleaseName(name); err != nil {
 		return nil, errors.Errorf("releaseContent: Release name is invalid: %s", name)
@@ -360,35 +556,97 @@ func GetVersionSet(client discovery.ServerResourcesInterface) (chartutil.Version
 // recordRelease with an update operation in case reuse has been set.
 func (cfg *Configuration) recordRelease(r *release.Release) {
 	if err := cfg.Releases.Update(r); err != nil {
-		cfg.Log("warning: Failed to update release %s: %s", r.Name, err)
+		cfg.releaseLogger(r).Warn("failed to update release", "error", err)
+	}
+}
+
+// logger returns the logger of the actions, which writes to Log if Logger is
+// not set.
+func (cfg *Configuration) logger() logging.Logger {
+	if cfg.Logger != nil {
+		return cfg.Logger
 	}
+	return logging.FromPrintf(cfg.Log)
+}
+
+// releaseLogger returns the logger of the actions on the release, which adds
+// the fields identifying it.
+func (cfg *Configuration) releaseLogger(rel *release.Release) logging.Logger {
+	l := cfg.logger().With(logging.ReleaseField, rel.Name, logging.NamespaceField, rel.Namespace, logging.RevisionField, rel.Version)
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		l = l.With(logging.ChartField, rel.Chart.Metadata.Name+"-"+rel.Chart.Metadata.Version)
+	}
+	return l
 }
 
 // Init initializes the action configuration
 func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namespace, helmDriver string, log DebugLog) error {
+	if log == nil && cfg.Logger != nil {
+		log = logging.Printf(cfg.Logger)
+	}
+	if cfg.ReadOnly {
+		getter = kube.WithReadOnly(getter)
+	}
+	getter = kube.WithRateLimit(getter, cfg.RateLimit)
+	getter = kube.WithDiscoveryCache(getter, cfg.DiscoveryCache)
 	kc := kube.New(getter)
 	kc.Log = log
-
-	lazyClient := &lazyClient{
-		namespace: namespace,
-		clientFn:  kc.Factory.KubernetesClientSet,
+	if cfg.User == "" {
+		cfg.User = kube.UserName(getter)
+	}
+	kc.WaitIgnoreKinds = strings.FieldsFunc(os.Getenv("HELM_WAIT_IGNORE_KINDS"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	waitStrategy, err := kube.ParseWaitStrategy(os.Getenv("HELM_WAIT_STRATEGY"))
+	if err != nil {
+		return err
 	}
+	kc.WaitStrategy = waitStrategy
 
 	clientset, err := kc.Factory.KubernetesClientSet()
 	if err != nil {
 		return err
 	}
 
+	compression, err := driver.ParseCompression(os.Getenv("HELM_DRIVER_COMPRESSION"))
+	if err != nil {
+		return err
+	}
+	keys, err := storageKeysFromEnv()
+	if err != nil {
+		return err
+	}
+
 	var store *storage.Storage
+	var namespaceStorage func(namespace string) *storage.Storage
+	// recordStorage returns the storage of the records kept in the Secrets
+	// or the ConfigMaps of a namespace.
+	var recordStorage func(namespace string) *storage.Storage
 	switch helmDriver {
 	case "secret", "secrets", "":
-		d := driver.NewSecrets(newSecretClient(lazyClient))
-		d.Log = log
-		store = storage.Init(d)
+		recordStorage = func(namespace string) *storage.Storage {
+			d := driver.NewSecrets(newSecretClient(&lazyClient{
+				namespace: namespace,
+				clientFn:  kc.Factory.KubernetesClientSet,
+			}))
+			d.Log = log
+			d.Compression = compression
+			d.Keys = keys
+			d.Metrics = cfg.Metrics
+			return cfg.newStorage(d)
+		}
 	case "configmap", "configmaps":
-		d := driver.NewConfigMaps(newConfigMapClient(lazyClient))
-		d.Log = log
-		store = storage.Init(d)
+		recordStorage = func(namespace string) *storage.Storage {
+			d := driver.NewConfigMaps(newConfigMapClient(&lazyClient{
+				namespace: namespace,
+				clientFn:  kc.Factory.KubernetesClientSet,
+			}))
+			d.Log = log
+			d.Compression = compression
+			d.Keys = keys
+			d.Metrics = cfg.Metrics
+			return cfg.newStorage(d)
+		}
 	case "memory":
 		var d *driver.Memory
 		if cfg.Releases != nil {
@@ -403,7 +661,7 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 			d = driver.NewMemory()
 		}
 		d.SetNamespace(namespace)
-		store = storage.Init(d)
+		store = cfg.newStorage(d)
 	case "sql":
 		d, err := driver.NewSQL(
 			os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING"),
@@ -413,16 +671,104 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 		if err != nil {
 			panic(fmt.Sprintf("Unable to instantiate SQL driver: %v", err))
 		}
-		store = storage.Init(d)
+		d.Keys = keys
+		d.Metrics = cfg.Metrics
+		store = cfg.newStorage(d)
 	default:
-		// Not sure what to do here.
-		panic("Unknown driver in HELM_DRIVER: " + helmDriver)
+		name := strings.TrimPrefix(helmDriver, "plugin:")
+		if name == helmDriver {
+			// Not sure what to do here.
+			panic("Unknown driver in HELM_DRIVER: " + helmDriver)
+		}
+		settings := cli.New()
+		p, err := plugin.FindProvider(settings.PluginsDirectory, name, plugin.CapabilityStorage)
+		if err != nil {
+			return err
+		}
+		// The drivers of all namespaces call the same plugin process, stopped
+		// by Close.
+		d := plugin.NewStorageDriver(p, settings, namespace)
+		cfg.closers = append(cfg.closers, d)
+		namespaceStorage = func(namespace string) *storage.Storage {
+			return cfg.newStorage(d.ForNamespace(namespace))
+		}
+		store = cfg.newStorage(d)
+	}
+	if recordStorage != nil {
+		namespaceStorage = recordStorage
+		if cfg.StorageNamespace == "" {
+			cfg.StorageNamespace = os.Getenv("HELM_DRIVER_NAMESPACE")
+		}
+		if cfg.StorageNamespace != "" {
+			cfg.ReleaseNamespaceStorage = recordStorage
+			namespaceStorage = func(namespace string) *storage.Storage {
+				s := recordStorage(cfg.StorageNamespace)
+				s.Driver = driver.NewScoped(s.Driver, namespace)
+				return s
+			}
+		}
+		store = namespaceStorage(namespace)
 	}
 
 	cfg.RESTClientGetter = getter
 	cfg.KubeClient = kc
 	cfg.Releases = store
+	cfg.NamespaceStorage = namespaceStorage
 	cfg.Log = log
 	cfg.ClientSet = clientset
 	return nil
 }
+
+// Close releases the resources held by the configuration once the actions
+// are done, such as the process of a plugin storage driver. They are
+// acquired again if the configuration is used afterwards.
+func (cfg *Configuration) Close() error {
+	var errs []string
+	for _, c := range cfg.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// newStorage returns the release storage of the driver, reporting to the
+// metrics of the configuration.
+func (cfg *Configuration) newStorage(d driver.Driver) *storage.Storage {
+	if cfg.ReadOnly {
+		d = readOnlyDriver{d}
+	}
+	s := storage.Init(d)
+	s.Metrics = cfg.Metrics
+	return s
+}
+
+// storageKeysFromEnv returns the KeyProvider used to encrypt release records,
+// configured by a base64 encoded key in $HELM_DRIVER_ENCRYPTION_KEY or in the
+// file named by $HELM_DRIVER_ENCRYPTION_KEY_FILE. It returns nil if neither
+// is set.
+func storageKeysFromEnv() (driver.KeyProvider, error) {
+	encoded := os.Getenv("HELM_DRIVER_ENCRYPTION_KEY")
+	if file := os.Getenv("HELM_DRIVER_ENCRYPTION_KEY_FILE"); file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read release encryption key")
+		}
+		encoded = string(b)
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.Wrap(err, "release encryption key is not valid base64")
+	}
+	kp, err := driver.NewLocalKeyProvider(key)
+	if err != nil {
+		return nil, err
+	}
+	return kp, nil
+}
@@ -0,0 +1,246 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// checkOrdering checks that the resources can be ordered before applying
+// them.
+func checkOrdering(resources kube.ResourceList, ordering kube.Ordering, waves bool) error {
+	var err error
+	if waves {
+		_, err = ordering.Waves(resources)
+	} else {
+		_, err = ordering.Stages(resources)
+	}
+	return err
+}
+
+// applyStages applies the stages one after the other with apply, waiting up
+// to timeout for the resources other stages depend on to be ready before
+// applying the next stage.
+func (cfg *Configuration) applyStages(stages []kube.Stage, timeout time.Duration, apply func(kube.ResourceList) error) error {
+	for i, stage := range stages {
+		if len(stages) > 1 {
+			cfg.Log("applying stage %d/%d: %d resource(s)", i+1, len(stages), len(stage.Resources))
+		}
+		if err := apply(stage.Resources); err != nil {
+			return err
+		}
+		if len(stage.Wait) > 0 && i < len(stages)-1 {
+			cfg.Log("waiting for %d resource(s) the next stages depend on", len(stage.Wait))
+			if err := cfg.KubeClient.Wait(stage.Wait, timeout); err != nil {
+				return errors.Wrap(err, "resources the next stages depend on are not ready")
+			}
+		}
+	}
+	return nil
+}
+
+// waitForResources waits up to timeout for the resources of the release to
+// be ready, including the jobs if jobs is set, with the given strategy if the
+// Kubernetes client supports it. It records how long each resource took to
+// be ready in the release.
+func (cfg *Configuration) waitForResources(rel *release.Release, resources kube.ResourceList, timeout time.Duration, jobs bool, strategy kube.WaitStrategy) error {
+	kc, ok := cfg.KubeClient.(kube.InterfaceWaitStrategy)
+	if !ok {
+		if jobs {
+			return cfg.KubeClient.WaitWithJobs(resources, timeout)
+		}
+		return cfg.KubeClient.Wait(resources, timeout)
+	}
+	timings, err := kc.WaitWithStrategy(resources, timeout, strategy, jobs)
+	rel.Info.WaitTimings = nil
+	for _, t := range timings {
+		rel.Info.WaitTimings = append(rel.Info.WaitTimings, release.WaitTiming{
+			Kind:      t.Kind,
+			Namespace: t.Namespace,
+			Name:      t.Name,
+			Duration:  t.Duration,
+			Ready:     t.Ready,
+		})
+	}
+	return err
+}
+
+// applyWaves applies the waves of the resources one after the other with
+// apply, waiting up to timeout for the resources of a wave to be ready before
+// applying the next. The progress is recorded in the release after each wave,
+// and the waves the release records as completed are skipped, so that a
+// failed release can be resumed.
+func (cfg *Configuration) applyWaves(rel *release.Release, resources kube.ResourceList, ordering kube.Ordering, timeout time.Duration, apply func(kube.ResourceList) error) error {
+	waves, err := ordering.Waves(resources)
+	if err != nil {
+		return err
+	}
+	numbers := make([]int, len(waves))
+	for i, wave := range waves {
+		numbers[i] = wave.Number
+	}
+	progress := rel.Info.Waves
+	if progress == nil || !reflect.DeepEqual(progress.Waves, numbers) {
+		progress = &release.WaveProgress{Waves: numbers}
+		rel.Info.Waves = progress
+	}
+
+	for i, wave := range waves {
+		if i < progress.Completed {
+			cfg.Log("skipping wave %d, completed by a previous attempt", wave.Number)
+			continue
+		}
+		cfg.Log("applying wave %d (%d/%d)", wave.Number, i+1, len(waves))
+		if err := cfg.applyStages(wave.Stages, timeout, apply); err != nil {
+			return errors.Wrapf(err, "wave %d failed", wave.Number)
+		}
+		if i < len(waves)-1 {
+			if err := cfg.KubeClient.WaitWithJobs(wave.Resources(), timeout); err != nil {
+				return errors.Wrapf(err, "wave %d is not ready", wave.Number)
+			}
+		}
+		progress.Completed = i + 1
+		cfg.recordRelease(rel)
+	}
+	return nil
+}
+
+// applyOptions returns the options to create and update the resources with,
+// with the given concurrency if set.
+func (cfg *Configuration) applyOptions(concurrency int) kube.ApplyOptions {
+	opts := cfg.ApplyOptions
+	if concurrency != 0 {
+		opts.Concurrency = concurrency
+	}
+	return opts
+}
+
+// create creates the resources with the given options if the Kubernetes
+// client supports them.
+func (cfg *Configuration) create(resources kube.ResourceList, opts kube.ApplyOptions) (*kube.Result, error) {
+	if kc, ok := cfg.KubeClient.(kube.InterfaceApply); ok {
+		return kc.CreateWithOptions(resources, opts)
+	}
+	return cfg.KubeClient.Create(resources)
+}
+
+// update updates the resources with the given options if the Kubernetes
+// client supports them.
+func (cfg *Configuration) update(current, target kube.ResourceList, force bool, opts kube.ApplyOptions) (*kube.Result, error) {
+	if kc, ok := cfg.KubeClient.(kube.InterfaceApply); ok {
+		return kc.UpdateWithOptions(current, target, force, opts)
+	}
+	return cfg.KubeClient.Update(current, target, force)
+}
+
+// createResources creates the resources of the release in the order of
+// ordering, in waves if waves is true.
+func (cfg *Configuration) createResources(rel *release.Release, resources kube.ResourceList, ordering kube.Ordering, waves bool, timeout time.Duration, opts kube.ApplyOptions) error {
+	create := func(rl kube.ResourceList) error {
+		_, err := cfg.create(rl, opts)
+		return err
+	}
+	if waves {
+		return cfg.applyWaves(rel, resources, ordering, timeout, create)
+	}
+	stages, err := ordering.Stages(resources)
+	if err != nil {
+		return err
+	}
+	return cfg.applyStages(stages, timeout, create)
+}
+
+// updateResources updates the current resources to the target ones of the
+// release in the order of ordering, in waves if waves is true, then deletes
+// the current resources missing from the target. The result gathers the
+// changes of all stages, even on errors.
+func (cfg *Configuration) updateResources(rel *release.Release, current, target kube.ResourceList, force bool, ordering kube.Ordering, waves bool, timeout time.Duration, opts kube.ApplyOptions) (*kube.Result, error) {
+	var stages []kube.Stage
+	if !waves {
+		var err error
+		if stages, err = ordering.Stages(target); err != nil {
+			return &kube.Result{}, err
+		}
+		if len(stages) <= 1 {
+			return cfg.update(current, target, force, opts)
+		}
+	}
+
+	result := &kube.Result{}
+	collect := func(r *kube.Result) {
+		if r == nil {
+			return
+		}
+		result.Created = append(result.Created, r.Created...)
+		result.Updated = append(result.Updated, r.Updated...)
+		result.Deleted = append(result.Deleted, r.Deleted...)
+		result.Unchanged = append(result.Unchanged, r.Unchanged...)
+	}
+	update := func(rl kube.ResourceList) error {
+		r, err := cfg.update(current.Intersect(rl), rl, force, opts)
+		collect(r)
+		return err
+	}
+
+	var err error
+	if waves {
+		err = cfg.applyWaves(rel, target, ordering, timeout, update)
+	} else {
+		err = cfg.applyStages(stages, timeout, update)
+	}
+	if err != nil {
+		return result, err
+	}
+	r, err := cfg.update(current.Difference(target), kube.ResourceList{}, force, opts)
+	collect(r)
+	return result, err
+}
+
+// resourceDigests returns the SHA-256 digests of the resources as applied,
+// by the key of their identity.
+func resourceDigests(resources kube.ResourceList) (map[string]string, error) {
+	digests := make(map[string]string, len(resources))
+	for _, info := range resources {
+		data, err := json.Marshal(info.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to compute the digest of %s", resourceIdentity(info))
+		}
+		sum := sha256.Sum256(data)
+		digests[resourceIdentity(info).Key()] = hex.EncodeToString(sum[:])
+	}
+	return digests, nil
+}
+
+// unchangedSince returns a function reporting whether a resource has the same
+// digest in previous and current.
+func unchangedSince(previous, current map[string]string) func(*resource.Info) bool {
+	return func(info *resource.Info) bool {
+		key := resourceIdentity(info).Key()
+		digest, ok := previous[key]
+		return ok && digest == current[key]
+	}
+}
@@ -0,0 +1,387 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// DeclaredRelease is the desired state of a release in a ReleasesManifest.
+type DeclaredRelease struct {
+	Name string `json:"name"`
+	// Namespace defaults to the namespace of the Apply action.
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is the name of the chart, downloaded with its Version from the
+	// repository at RepoURL, as by 'helm install --repo'.
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	RepoURL string `json:"repoURL,omitempty"`
+	// Values are the values overriding the ones of the chart.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// Installed set to false uninstalls the release.
+	Installed *bool `json:"installed,omitempty"`
+	// Needs are the releases applied before this one, as "name" for the
+	// releases of the same namespace or "namespace/name".
+	Needs   []string        `json:"needs,omitempty"`
+	Wait    bool            `json:"wait,omitempty"`
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// desired returns the chart and values the release is declared with.
+func (r *DeclaredRelease) desired() DesiredChart {
+	return DesiredChart{Chart: r.Chart, Version: r.Version, RepoURL: r.RepoURL, Values: r.Values}
+}
+
+func (r *DeclaredRelease) key() string {
+	return r.Namespace + "/" + r.Name
+}
+
+func (r *DeclaredRelease) installed() bool {
+	return r.Installed == nil || *r.Installed
+}
+
+func (r *DeclaredRelease) timeout() time.Duration {
+	if r.Timeout.Duration <= 0 {
+		return 300 * time.Second
+	}
+	return r.Timeout.Duration
+}
+
+// needKeys returns the keys of the releases the release needs.
+func (r *DeclaredRelease) needKeys() []string {
+	keys := make([]string, 0, len(r.Needs))
+	for _, need := range r.Needs {
+		if strings.Contains(need, "/") {
+			keys = append(keys, need)
+		} else {
+			keys = append(keys, r.Namespace+"/"+need)
+		}
+	}
+	return keys
+}
+
+// ReleasesManifest declares the releases applied by the Apply action.
+type ReleasesManifest struct {
+	Releases []DeclaredRelease `json:"releases"`
+}
+
+// LoadReleasesManifest parses a ReleasesManifest.
+func LoadReleasesManifest(data []byte) (*ReleasesManifest, error) {
+	m := &ReleasesManifest{}
+	if err := yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, errors.Wrap(err, "invalid releases manifest")
+	}
+	return m, nil
+}
+
+// ApplyAction is the operation the Apply action runs on a release.
+type ApplyAction string
+
+const (
+	// ApplyInstall installs a missing release.
+	ApplyInstall ApplyAction = "install"
+	// ApplyUpgrade upgrades a release whose chart or values differ, or
+	// whose last revision failed.
+	ApplyUpgrade ApplyAction = "upgrade"
+	// ApplyDelete uninstalls a release declared as not installed.
+	ApplyDelete ApplyAction = "delete"
+	// ApplyNone leaves an up to date release unchanged.
+	ApplyNone ApplyAction = "none"
+)
+
+// ApplyStatus is the status of a step of an ApplyPlan.
+type ApplyStatus string
+
+const (
+	// ApplyPending is the status of the steps that did not run.
+	ApplyPending ApplyStatus = "pending"
+	// ApplyApplied is the status of the steps that succeeded.
+	ApplyApplied ApplyStatus = "applied"
+	// ApplyFailed is the status of the step that failed.
+	ApplyFailed ApplyStatus = "failed"
+	// ApplySkipped is the status of the steps not run after a failure.
+	ApplySkipped ApplyStatus = "skipped"
+)
+
+// ApplyStep is the operation on a release of an ApplyPlan.
+type ApplyStep struct {
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+	Action    ApplyAction `json:"action"`
+	// Chart is the declared chart, as name-version.
+	Chart string `json:"chart,omitempty"`
+	// CurrentChart is the chart of the last revision of the release.
+	CurrentChart string      `json:"currentChart,omitempty"`
+	Status       ApplyStatus `json:"status"`
+	// Revision is the revision of the release once applied.
+	Revision int    `json:"revision,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	release *DeclaredRelease
+}
+
+// ApplyPlan lists the steps applying a ReleasesManifest, in order: the
+// installs and upgrades after the releases they need, then the deletes in
+// the reverse order.
+type ApplyPlan struct {
+	Steps []*ApplyStep `json:"steps"`
+}
+
+// Changed reports whether the plan changes releases.
+func (p *ApplyPlan) Changed() bool {
+	for _, s := range p.Steps {
+		if s.Action != ApplyNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply is the action applying the releases of a ReleasesManifest.
+//
+// It provides the implementation of 'helm apply'.
+type Apply struct {
+	configs  func(namespace string) (*Configuration, error)
+	settings *cli.EnvSettings
+
+	// Namespace is the namespace of the releases that do not declare one.
+	Namespace string
+	// DryRun computes the plan without applying it.
+	DryRun bool
+}
+
+// NewApply creates a new Apply object, running the operations on the
+// releases of a namespace with the Configuration returned by configs, closed
+// once they are done, and locating the charts with settings.
+func NewApply(configs func(namespace string) (*Configuration, error), settings *cli.EnvSettings) *Apply {
+	return &Apply{configs: configs, settings: settings}
+}
+
+// Plan computes the plan applying the manifest.
+func (a *Apply) Plan(m *ReleasesManifest) (*ApplyPlan, error) {
+	releases := make([]*DeclaredRelease, len(m.Releases))
+	byKey := make(map[string]*DeclaredRelease, len(m.Releases))
+	for i := range m.Releases {
+		r := m.Releases[i]
+		if r.Namespace == "" {
+			r.Namespace = a.Namespace
+		}
+		if err := chartutil.ValidateReleaseName(r.Name); err != nil {
+			return nil, errors.Wrapf(err, "release %q", r.Name)
+		}
+		if r.Chart == "" && r.installed() {
+			return nil, errors.Errorf("release %s has no chart", r.key())
+		}
+		if byKey[r.key()] != nil {
+			return nil, errors.Errorf("release %s is declared more than once", r.key())
+		}
+		releases[i] = &r
+		byKey[r.key()] = &r
+	}
+	ordered, err := orderReleases(releases, byKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ApplyPlan{}
+	var deletes []*ApplyStep
+	for _, r := range ordered {
+		step, err := a.planRelease(r)
+		if err != nil {
+			return nil, err
+		}
+		if step.Action == ApplyDelete {
+			deletes = append([]*ApplyStep{step}, deletes...)
+		} else {
+			plan.Steps = append(plan.Steps, step)
+		}
+	}
+	plan.Steps = append(plan.Steps, deletes...)
+	return plan, nil
+}
+
+// orderReleases orders the releases after the ones they need.
+func orderReleases(releases []*DeclaredRelease, byKey map[string]*DeclaredRelease) ([]*DeclaredRelease, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(releases))
+	ordered := make([]*DeclaredRelease, 0, len(releases))
+	var visit func(r *DeclaredRelease, path []string) error
+	visit = func(r *DeclaredRelease, path []string) error {
+		switch state[r.key()] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("releases need each other: %s", strings.Join(append(path, r.key()), " -> "))
+		}
+		state[r.key()] = visiting
+		for _, key := range r.needKeys() {
+			need, ok := byKey[key]
+			if !ok {
+				return errors.Errorf("release %s needs %s, which is not declared", r.key(), key)
+			}
+			if err := visit(need, append(path, r.key())); err != nil {
+				return err
+			}
+		}
+		state[r.key()] = visited
+		ordered = append(ordered, r)
+		return nil
+	}
+	for _, r := range releases {
+		if err := visit(r, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func (a *Apply) planRelease(r *DeclaredRelease) (*ApplyStep, error) {
+	step := &ApplyStep{
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Action:    ApplyNone,
+		Status:    ApplyPending,
+		release:   r,
+	}
+	if r.installed() {
+		step.Chart = strings.TrimSuffix(r.Chart+"-"+r.Version, "-")
+	}
+
+	cfg, err := a.configs(r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	last, err := cfg.Releases.Last(r.Name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, errors.Wrapf(err, "unable to get release %s", r.key())
+	}
+	exists := err == nil && last.Info.Status != release.StatusUninstalled
+	if exists && last.Chart != nil && last.Chart.Metadata != nil {
+		step.CurrentChart = last.Chart.Metadata.Name + "-" + last.Chart.Metadata.Version
+	}
+
+	switch {
+	case !r.installed():
+		if exists {
+			step.Action = ApplyDelete
+		}
+	case !exists:
+		step.Action = ApplyInstall
+	default:
+		if last.Info.Status.IsPending() {
+			return nil, errors.Errorf("release %s has an operation in progress", r.key())
+		}
+		upToDate, err := r.desired().Matches(last)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid values of release %s", r.key())
+		}
+		if !upToDate || last.Info.Status != release.StatusDeployed {
+			step.Action = ApplyUpgrade
+		}
+	}
+	return step, nil
+}
+
+// Run computes the plan applying the manifest and, unless DryRun is set,
+// runs its steps in order. The steps after a failed one are skipped. It
+// returns the plan with the status of each step.
+func (a *Apply) Run(ctx context.Context, m *ReleasesManifest) (*ApplyPlan, error) {
+	plan, err := a.Plan(m)
+	if err != nil || a.DryRun {
+		return plan, err
+	}
+
+	var failed error
+	for _, step := range plan.Steps {
+		if failed != nil {
+			if step.Action != ApplyNone {
+				step.Status = ApplySkipped
+			}
+			continue
+		}
+		rel, err := a.runStep(ctx, step)
+		if err != nil {
+			step.Status = ApplyFailed
+			step.Error = err.Error()
+			failed = errors.Wrapf(err, "unable to %s release %s/%s", step.Action, step.Namespace, step.Name)
+			continue
+		}
+		step.Status = ApplyApplied
+		if rel != nil {
+			step.Revision = rel.Version
+		}
+	}
+	return plan, failed
+}
+
+func (a *Apply) runStep(ctx context.Context, step *ApplyStep) (*release.Release, error) {
+	r := step.release
+	cfg, err := a.configs(r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	switch step.Action {
+	case ApplyInstall:
+		client := NewInstall(cfg, ChartPathOptions{Version: r.Version, RepoURL: r.RepoURL},
+			"", 0, "", nil, r.Namespace, r.Name, "", "", 0, "", "", "", false)
+		client.Replace = true
+		client.Wait = r.Wait
+		client.Timeout = r.timeout()
+		chrt, err := client.ChartPathOptions.LoadChart(r.Chart, a.settings)
+		if err != nil {
+			return nil, err
+		}
+		return client.RunWithContext(ctx, chrt, r.desired().OverrideValues(), "")
+	case ApplyUpgrade:
+		client := NewUpgrade(cfg, ChartPathOptions{Version: r.Version, RepoURL: r.RepoURL},
+			"", 0, "", nil, r.Name, "", "", 0, "", "", false, "")
+		client.Namespace = r.Namespace
+		client.Wait = r.Wait
+		client.Timeout = r.timeout()
+		chrt, err := client.ChartPathOptions.LoadChart(r.Chart, a.settings)
+		if err != nil {
+			return nil, err
+		}
+		return client.RunWithContext(ctx, r.Name, chrt, r.desired().OverrideValues(), "")
+	case ApplyDelete:
+		client := NewUninstall(cfg)
+		client.Wait = r.Wait
+		client.Timeout = r.timeout()
+		res, err := client.Run(r.Name)
+		if res != nil {
+			return res.Release, err
+		}
+		return nil, err
+	}
+	return nil, nil
+}
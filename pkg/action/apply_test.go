@@ -0,0 +1,111 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func waveInfo(name, wave string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetName(name)
+	obj.SetAnnotations(map[string]string{kube.WaveAnnotation: wave})
+	return &resource.Info{Name: name, Object: obj}
+}
+
+func TestApplyWavesResume(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	resources := kube.ResourceList{waveInfo("first", "0"), waveInfo("second", "1"), waveInfo("third", "2")}
+
+	var applied []string
+	failOn := ""
+	apply := func(rl kube.ResourceList) error {
+		for _, info := range rl {
+			if info.Name == failOn {
+				return errors.Errorf("unable to apply %s", info.Name)
+			}
+			applied = append(applied, info.Name)
+		}
+		return nil
+	}
+
+	// The first attempt fails in the second wave.
+	failed := releaseStub()
+	failed.Info.Status = release.StatusFailed
+	if err := cfg.Releases.Create(failed); err != nil {
+		t.Fatal(err)
+	}
+	failOn = "second"
+	if err := cfg.applyWaves(failed, resources, kube.Ordering{}, time.Second, apply); err == nil {
+		t.Fatal("expected the second wave to fail")
+	}
+	if want := []string{"first"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, applied)
+	}
+	stored, err := cfg.Releases.Get(failed.Name, failed.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Info.Waves == nil || stored.Info.Waves.Completed != 1 {
+		t.Fatalf("expected the release to record 1 completed wave, got %+v", stored.Info.Waves)
+	}
+
+	// The resumed attempt starts from the wave that failed.
+	resumed := releaseStub()
+	resumed.Version = 2
+	progress := *stored.Info.Waves
+	resumed.Info.Waves = &progress
+	if err := cfg.Releases.Create(resumed); err != nil {
+		t.Fatal(err)
+	}
+	applied, failOn = nil, ""
+	if err := cfg.applyWaves(resumed, resources, kube.Ordering{}, time.Second, apply); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"second", "third"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, applied)
+	}
+	if !resumed.Info.Waves.Done() {
+		t.Errorf("expected all the waves to be completed, got %+v", resumed.Info.Waves)
+	}
+
+	// Progress recorded for other waves is not resumed.
+	changed := releaseStub()
+	changed.Version = 3
+	changed.Info.Waves = &release.WaveProgress{Waves: []int{0, 5}, Completed: 1}
+	if err := cfg.Releases.Create(changed); err != nil {
+		t.Fatal(err)
+	}
+	applied = nil
+	if err := cfg.applyWaves(changed, resources, kube.Ordering{}, time.Second, apply); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("expected %v to be applied, got %v", want, applied)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/bundle"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
+)
+
+// BundleCreate is the action for creating an offline bundle of a chart, see
+// the bundle package.
+//
+// It provides the implementation of 'helm bundle create'.
+type BundleCreate struct {
+	ChartPathOptions
+
+	cfg *Configuration
+}
+
+// NewBundleCreate creates a new BundleCreate object with the given configuration.
+func NewBundleCreate(cfg *Configuration) *BundleCreate {
+	b := &BundleCreate{cfg: cfg}
+	b.ChartPathOptions.registryClient = cfg.RegistryClient
+	b.ChartPathOptions.signaturePolicy = cfg.SignaturePolicy
+	b.ChartPathOptions.tracerProvider = cfg.TracerProvider
+	b.ChartPathOptions.metrics = cfg.Metrics
+	return b
+}
+
+// Run returns the bundle of the chart. The chart is either a chart directory,
+// which is packaged, a chart archive, whose provenance file is bundled if it
+// is next to it, or a chart located with the ChartPathOptions. The
+// dependencies of the chart must have been built into it.
+func (b *BundleCreate) Run(chartRef string, settings *cli.EnvSettings) (*bundle.Bundle, error) {
+	var archive, prov []byte
+	fi, err := os.Stat(chartRef)
+	switch {
+	case err == nil && fi.IsDir():
+		if archive, err = packageChartDir(chartRef); err != nil {
+			return nil, err
+		}
+	default:
+		path := chartRef
+		if err != nil || !strings.HasSuffix(chartRef, ".tgz") {
+			if path, err = b.LocateChart(chartRef, settings); err != nil {
+				return nil, err
+			}
+		}
+		if archive, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+		if prov, err = ioutil.ReadFile(path + ".prov"); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	bdl, err := bundle.New(archive, prov, b.cfg.Now().Time)
+	if err != nil {
+		return nil, err
+	}
+	if b.Verify {
+		if _, err := bdl.Verify(b.Keyring); err != nil {
+			return nil, err
+		}
+	}
+	return bdl, nil
+}
+
+// packageChartDir returns the archive of the chart directory, whose
+// dependencies must all be in its charts directory.
+func packageChartDir(dir string) ([]byte, error) {
+	ch, err := loader.LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if req := ch.Metadata.Dependencies; req != nil {
+		if err := CheckDependencies(ch, req); err != nil {
+			return nil, errors.Wrap(err, "An error occurred while checking for chart dependencies. You may need to run `helm dependency build` to fetch missing dependencies")
+		}
+	}
+
+	tmp, err := ioutil.TempDir("", "helm-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	name, err := chartutil.Save(ch, tmp)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(name)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+// CapabilitiesExport is the action for exporting the capabilities of a
+// cluster, to render charts offline as on that cluster.
+//
+// It provides the implementation of 'helm capabilities export'.
+type CapabilitiesExport struct {
+	cfg *Configuration
+}
+
+// NewCapabilitiesExport creates a new CapabilitiesExport object with the
+// given configuration.
+func NewCapabilitiesExport(cfg *Configuration) *CapabilitiesExport {
+	return &CapabilitiesExport{
+		cfg: cfg,
+	}
+}
+
+// Run returns the capabilities of the cluster, its Kubernetes version and
+// API versions, and its features if the configuration detects them.
+func (c *CapabilitiesExport) Run() (*chartutil.Capabilities, error) {
+	if err := c.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	return c.cfg.getCapabilities()
+}
@@ -0,0 +1,244 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+// BumpLevel is the semantic version level a version is bumped at.
+type BumpLevel string
+
+const (
+	// BumpMajor bumps the major version, e.g. from 1.2.3 to 2.0.0.
+	BumpMajor BumpLevel = "major"
+	// BumpMinor bumps the minor version, e.g. from 1.2.3 to 1.3.0.
+	BumpMinor BumpLevel = "minor"
+	// BumpPatch bumps the patch version, e.g. from 1.2.3 to 1.2.4, or
+	// releases a pre-release version, e.g. from 1.2.3-rc.1 to 1.2.3.
+	BumpPatch BumpLevel = "patch"
+)
+
+// BumpLevels are the levels a version can be bumped at.
+var BumpLevels = []string{string(BumpMajor), string(BumpMinor), string(BumpPatch)}
+
+// Bump returns version bumped at the level.
+func (l BumpLevel) Bump(version string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to bump version %q", version)
+	}
+	var bumped semver.Version
+	switch l {
+	case BumpMajor:
+		bumped = v.IncMajor()
+	case BumpMinor:
+		bumped = v.IncMinor()
+	case BumpPatch:
+		bumped = v.IncPatch()
+	default:
+		return "", errors.Errorf("invalid bump level %q, allowed values: %s", l, strings.Join(BumpLevels, ", "))
+	}
+	// Keep the "v" prefix of versions like v1.2.3.
+	if strings.HasPrefix(version, "v") {
+		return "v" + bumped.String(), nil
+	}
+	return bumped.String(), nil
+}
+
+// ChartBump is the action for bumping the version of a chart to release it.
+//
+// It provides the implementation of 'helm chart bump'.
+type ChartBump struct {
+	// Level is the level the chart version is bumped at, unless Version is
+	// set.
+	Level BumpLevel
+	// Version is the new chart version.
+	Version string
+	// AppVersionLevel is the level the appVersion of the chart is bumped at,
+	// unless AppVersion is set. The appVersion is kept when both are empty.
+	AppVersionLevel BumpLevel
+	// AppVersion is the new appVersion of the chart.
+	AppVersion string
+	// Dependencies are the new version constraints of dependencies, by name
+	// or alias.
+	Dependencies map[string]string
+	// IndexFile is the repository index the bumped chart is added to. The
+	// chart must be an archive.
+	IndexFile string
+	// IndexURL is the URL of the repository of IndexFile.
+	IndexURL string
+
+	// PreviousVersion and PreviousAppVersion are set by Run to the versions
+	// of the chart before the bump.
+	PreviousVersion    string
+	PreviousAppVersion string
+	// LockOutdated is set by Run when the versions locked by Chart.lock no
+	// longer match the dependencies, which must be updated. The Chart.lock
+	// file of a chart directory is then left as is, and the one of an
+	// archive is removed.
+	LockOutdated bool
+}
+
+// NewChartBump creates a new ChartBump object.
+func NewChartBump() *ChartBump {
+	return &ChartBump{}
+}
+
+// Run bumps the chart at path, a chart directory or archive, see
+// chartutil.Modify, and returns the path of the bumped chart and its
+// metadata. The chart is added to IndexFile when set.
+func (b *ChartBump) Run(path string) (string, *chart.Metadata, error) {
+	if b.IndexFile != "" {
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			return "", nil, errors.New("only a chart archive can be added to a repository index")
+		}
+	}
+
+	var md *chart.Metadata
+	name, err := chartutil.Modify(path, func(c *chart.Chart) error {
+		md = c.Metadata
+		b.PreviousVersion, b.PreviousAppVersion = md.Version, md.AppVersion
+		if err := b.bump(md); err != nil {
+			return err
+		}
+		if c.Lock != nil && !lockSatisfies(md.Dependencies, c.Lock.Dependencies) {
+			// Do not save the lock with the digest of the new dependencies,
+			// the dependency commands would take it for up to date.
+			b.LockOutdated = true
+			c.Lock = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	if b.IndexFile != "" {
+		if err := b.addToIndex(name, md); err != nil {
+			return name, md, err
+		}
+	}
+	return name, md, nil
+}
+
+// bump sets the versions of the metadata.
+func (b *ChartBump) bump(md *chart.Metadata) error {
+	switch {
+	case b.Version != "":
+		if _, err := semver.StrictNewVersion(b.Version); err != nil {
+			return errors.Wrapf(err, "invalid chart version %q", b.Version)
+		}
+		md.Version = b.Version
+	case b.Level != "":
+		v, err := b.Level.Bump(md.Version)
+		if err != nil {
+			return err
+		}
+		md.Version = v
+	}
+
+	switch {
+	case b.AppVersion != "":
+		md.AppVersion = b.AppVersion
+	case b.AppVersionLevel != "":
+		v, err := b.AppVersionLevel.Bump(md.AppVersion)
+		if err != nil {
+			return errors.Wrap(err, "unable to bump the appVersion")
+		}
+		md.AppVersion = v
+	}
+
+	for name, constraint := range b.Dependencies {
+		if _, err := semver.NewConstraint(constraint); err != nil {
+			return errors.Wrapf(err, "invalid version constraint %q of dependency %s", constraint, name)
+		}
+		found := false
+		for _, dep := range md.Dependencies {
+			if dep.Name == name || dep.Alias == name {
+				dep.Version = constraint
+				found = true
+			}
+		}
+		if !found {
+			return errors.Errorf("chart %s has no dependency %s", md.Name, name)
+		}
+	}
+	return nil
+}
+
+// addToIndex adds the chart archive to IndexFile, creating it if needed.
+func (b *ChartBump) addToIndex(archive string, md *chart.Metadata) error {
+	index := repo.NewIndexFile()
+	if _, err := os.Stat(b.IndexFile); err == nil {
+		if index, err = repo.LoadIndexFile(b.IndexFile); err != nil {
+			return err
+		}
+	}
+	if index.Has(md.Name, md.Version) {
+		return errors.Errorf("version %s of chart %s is already in %s", md.Version, md.Name, b.IndexFile)
+	}
+	digest, err := provenance.DigestFile(archive)
+	if err != nil {
+		return err
+	}
+	if err := index.MustAdd(md, filepath.Base(archive), b.IndexURL, digest); err != nil {
+		return err
+	}
+	index.SortEntries()
+	return index.WriteFile(b.IndexFile, 0644)
+}
+
+// lockSatisfies tells whether the locked dependencies match the
+// dependencies: same charts and repositories, and locked versions
+// satisfying the version constraints.
+func lockSatisfies(deps, locked []*chart.Dependency) bool {
+	if len(deps) != len(locked) {
+		return false
+	}
+	for _, dep := range deps {
+		found := false
+		for _, l := range locked {
+			if l.Name != dep.Name || l.Repository != dep.Repository {
+				continue
+			}
+			found = true
+			c, err := semver.NewConstraint(dep.Version)
+			if err != nil {
+				return false
+			}
+			v, err := semver.NewVersion(l.Version)
+			if err != nil || !c.Check(v) {
+				return false
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
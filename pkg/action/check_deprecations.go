@@ -0,0 +1,154 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/deprecations"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// CheckDeprecations is the action for checking releases and charts for the
+// use of deprecated and removed Kubernetes APIs.
+//
+// It provides the implementation of 'helm check-deprecations'.
+type CheckDeprecations struct {
+	cfg *Configuration
+
+	ChartPathOptions
+
+	// KubeVersion is the version of Kubernetes to check against. It defaults
+	// to the version of the cluster for releases, and to the default version
+	// of 'helm template' for charts, which are rendered for it.
+	KubeVersion *chartutil.KubeVersion
+	// Version is the revision of the release to check, the latest if 0.
+	Version int
+	// Namespace is the namespace charts are rendered for.
+	Namespace string
+	// Database is the database of deprecated APIs, deprecations.Default()
+	// if nil.
+	Database *deprecations.Database
+}
+
+// DeprecationReport lists the resources of a release or a chart using
+// deprecated or removed APIs.
+type DeprecationReport struct {
+	// Release and Revision are the ones of the release checked, if any.
+	Release     string                 `json:"release,omitempty"`
+	Revision    int                    `json:"revision,omitempty"`
+	Chart       string                 `json:"chart"`
+	KubeVersion string                 `json:"kubeVersion"`
+	Findings    []deprecations.Finding `json:"findings"`
+}
+
+// Removed reports whether any resource uses an API removed from the version
+// of Kubernetes checked against.
+func (r *DeprecationReport) Removed() bool {
+	for _, f := range r.Findings {
+		if f.Status == deprecations.Removed {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCheckDeprecations creates a new CheckDeprecations object with the given
+// configuration.
+func NewCheckDeprecations(cfg *Configuration) *CheckDeprecations {
+	return &CheckDeprecations{
+		cfg: cfg,
+	}
+}
+
+// RunRelease checks the manifest and hooks of the release name, as stored.
+func (c *CheckDeprecations) RunRelease(name string) (*DeprecationReport, error) {
+	if err := c.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := c.cfg.releaseContent(name, c.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeVersion := c.KubeVersion
+	if kubeVersion == nil {
+		caps, err := c.cfg.getCapabilities()
+		if err != nil {
+			return nil, err
+		}
+		kubeVersion = &caps.KubeVersion
+	}
+	report, err := c.scan(rel, kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+	report.Release = rel.Name
+	report.Revision = rel.Version
+	return report, nil
+}
+
+// RunChart renders chrt with vals without a cluster, as 'helm template'
+// does, and checks the rendered manifests and hooks.
+func (c *CheckDeprecations) RunChart(chrt *chart.Chart, vals map[string]interface{}) (*DeprecationReport, error) {
+	kubeVersion := c.KubeVersion
+	if kubeVersion == nil {
+		kubeVersion = &chartutil.DefaultCapabilities.KubeVersion
+	}
+
+	// Rendering without a cluster replaces the clients of the configuration.
+	cfg := *c.cfg
+	install := NewInstall(&cfg, c.ChartPathOptions, "", 0, "", nil, c.Namespace, "release-name", "", "", 0, "", "", "", false)
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.KubeVersion = kubeVersion
+	rel, err := install.Run(chrt, vals, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering the chart")
+	}
+	return c.scan(rel, kubeVersion)
+}
+
+// scan checks the manifest and the hooks of rel.
+func (c *CheckDeprecations) scan(rel *release.Release, kubeVersion *chartutil.KubeVersion) (*DeprecationReport, error) {
+	db := c.Database
+	if db == nil {
+		db = deprecations.Default()
+	}
+	manifest := rel.Manifest
+	for _, h := range rel.Hooks {
+		manifest += fmt.Sprintf("\n---\n# Source: %s\n%s", h.Path, h.Manifest)
+	}
+	findings, err := db.Scan(manifest, kubeVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	if findings == nil {
+		findings = []deprecations.Finding{}
+	}
+	return &DeprecationReport{
+		Chart:       chartFullName(rel.Chart),
+		KubeVersion: kubeVersion.Version,
+		Findings:    findings,
+	}, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+
+	"github.com/open-hand/helm/pkg/kube"
+)
+
+// Cluster describes how to connect to a Kubernetes cluster.
+type Cluster struct {
+	// KubeConfig is the path to the kubeconfig file. The default loading
+	// rules apply if it is empty.
+	KubeConfig string
+	// Context is the name of the kubeconfig context.
+	Context string
+	// APIServer is the address of the Kubernetes API server, overriding the
+	// one of the kubeconfig.
+	APIServer string
+	// BearerToken is used for authentication, overriding the credentials of
+	// the kubeconfig.
+	BearerToken string
+	// CAFile is the certificate authority file of the API server connection.
+	CAFile string
+	// Insecure skips the verification of the certificate of the API server
+	// if true, and enforces it if false. If nil, the kubeconfig decides.
+	Insecure *bool
+	// Namespace is the namespace scope of the operations.
+	Namespace string
+	// Auth sets the credentials and the identity to impersonate of the
+	// requests, e.g. those of the user requesting an operation.
+	Auth kube.AuthOptions
+	// RateLimit configures the client-side rate limiting of the requests.
+	RateLimit kube.RateLimitOptions
+}
+
+// Override returns the cluster with the non-empty settings of o.
+func (c Cluster) Override(o Cluster) Cluster {
+	if o.KubeConfig != "" {
+		c.KubeConfig = o.KubeConfig
+	}
+	if o.Context != "" {
+		c.Context = o.Context
+	}
+	if o.APIServer != "" {
+		c.APIServer = o.APIServer
+	}
+	if o.BearerToken != "" {
+		c.BearerToken = o.BearerToken
+	}
+	if o.CAFile != "" {
+		c.CAFile = o.CAFile
+	}
+	if o.Insecure != nil {
+		c.Insecure = o.Insecure
+	}
+	if o.Namespace != "" {
+		c.Namespace = o.Namespace
+	}
+	c.Auth = c.Auth.Override(o.Auth)
+	if !o.RateLimit.IsZero() {
+		c.RateLimit = o.RateLimit
+	}
+	return c
+}
+
+// key identifies the connection settings of the cluster, leaving out the
+// credentials: the identities connecting to the same cluster share its
+// getter, which therefore neither keeps their tokens nor multiplies as they
+// rotate.
+func (c Cluster) key() string {
+	c.BearerToken, c.Auth = "", kube.AuthOptions{}
+	b, _ := json.Marshal(c)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// RESTClientGetter returns a RESTClientGetter connecting to the cluster. It
+// does not share state with the getters of other clusters.
+func (c Cluster) RESTClientGetter() genericclioptions.RESTClientGetter {
+	return c.withCredentials(c.connection())
+}
+
+// connection returns a RESTClientGetter connecting to the cluster with the
+// credentials of the kubeconfig.
+func (c Cluster) connection() genericclioptions.RESTClientGetter {
+	flags := genericclioptions.NewConfigFlags(true)
+	flags.KubeConfig = stringPtr(c.KubeConfig)
+	flags.Context = stringPtr(c.Context)
+	flags.APIServer = stringPtr(c.APIServer)
+	flags.CAFile = stringPtr(c.CAFile)
+	flags.Namespace = stringPtr(c.Namespace)
+	rateLimit := c.RateLimit.ConfigWrapper()
+	flags.WrapConfigFn = rateLimit
+	if c.Insecure != nil {
+		insecure := *c.Insecure
+		flags.Insecure = &insecure
+		// The flags only ever skip the verification
+		flags.WrapConfigFn = func(config *rest.Config) *rest.Config {
+			config.Insecure = insecure
+			return rateLimit(config)
+		}
+	}
+	return flags
+}
+
+// withCredentials returns getter authenticating with the credentials of the
+// cluster, if any.
+func (c Cluster) withCredentials(getter genericclioptions.RESTClientGetter) genericclioptions.RESTClientGetter {
+	auth := c.Auth
+	if auth.BearerToken == "" {
+		auth.BearerToken = c.BearerToken
+	}
+	return kube.WithAuth(getter, auth)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// ConfigurationFactory builds Configurations targeting several clusters from
+// one process, e.g. in a controller managing releases on many clusters.
+//
+// Configurations are built concurrently and safely: each cluster has its own
+// clients, discovery cache and rate limiter, which the Configurations of the
+// same cluster share.
+type ConfigurationFactory struct {
+	// Defaults are the connection settings of all Configurations, which the
+	// settings given to NewConfiguration override.
+	Defaults Cluster
+	// HelmDriver is the storage driver of the releases, e.g. "secret".
+	HelmDriver string
+	// Log logs the operations of the Configurations.
+	Log DebugLog
+	// DiscoveryCache, if set, is shared by the Configurations, so that the
+	// actions on a cluster reuse its discovery information.
+	DiscoveryCache *kube.DiscoveryCache
+
+	mu      sync.Mutex
+	getters map[string]genericclioptions.RESTClientGetter
+}
+
+// NewConfiguration returns a new Configuration connecting to the cluster of
+// the defaults of the factory, overridden by the non-empty settings of
+// override. It is closed by the caller once its actions are done.
+func (f *ConfigurationFactory) NewConfiguration(override Cluster) (*Configuration, error) {
+	cluster := f.Defaults.Override(override)
+	log := f.Log
+	if log == nil {
+		log = func(string, ...interface{}) {}
+	}
+
+	cfg := &Configuration{DiscoveryCache: f.DiscoveryCache}
+	if err := cfg.Init(f.getter(cluster), cluster.Namespace, f.HelmDriver, log); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// getter returns the RESTClientGetter of the cluster with its credentials.
+// The connection to the cluster is created on first use and shared.
+func (f *ConfigurationFactory) getter(cluster Cluster) genericclioptions.RESTClientGetter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := cluster.key()
+	g, ok := f.getters[key]
+	if !ok {
+		if f.getters == nil {
+			f.getters = make(map[string]genericclioptions.RESTClientGetter)
+		}
+		g = cluster.connection()
+		f.getters[key] = g
+	}
+	return cluster.withCredentials(g)
+}
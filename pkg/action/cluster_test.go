@@ -0,0 +1,102 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/kube"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://k8s.example.com
+    insecure-skip-tls-verify: true
+users:
+- name: test
+  user:
+    token: kubeconfig-token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestClusterOverride(t *testing.T) {
+	defaults := Cluster{Context: "prod", Namespace: "default", Insecure: boolPtr(true)}
+
+	c := defaults.Override(Cluster{Namespace: "web"})
+	if c.Context != "prod" || c.Namespace != "web" || c.Insecure == nil || !*c.Insecure {
+		t.Errorf("expected the empty settings to be kept, got %+v", c)
+	}
+	c = defaults.Override(Cluster{Insecure: boolPtr(false)})
+	if c.Insecure == nil || *c.Insecure {
+		t.Errorf("expected the verification of the certificate to be enforced, got %+v", c)
+	}
+}
+
+func TestConfigurationFactoryGetter(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(kubeconfig, []byte(testKubeConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f := &ConfigurationFactory{Defaults: Cluster{KubeConfig: kubeconfig}}
+
+	for _, tt := range []struct {
+		name     string
+		cluster  Cluster
+		token    string
+		insecure bool
+	}{
+		{"kubeconfig", Cluster{}, "kubeconfig-token", true},
+		{"bearer token", Cluster{BearerToken: "first-token"}, "first-token", true},
+		{"rotated token", Cluster{BearerToken: "second-token"}, "second-token", true},
+		{"auth token", Cluster{BearerToken: "first-token", Auth: kube.AuthOptions{BearerToken: "user-token"}}, "user-token", true},
+		{"secure", Cluster{Insecure: boolPtr(false)}, "kubeconfig-token", false},
+	} {
+		config, err := f.getter(f.Defaults.Override(tt.cluster)).ToRESTConfig()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if config.BearerToken != tt.token || config.Insecure != tt.insecure {
+			t.Errorf("%s: expected token %q and insecure %t, got %q and %t", tt.name, tt.token, tt.insecure, config.BearerToken, config.Insecure)
+		}
+	}
+
+	// The identities share the connection of the cluster, whose key does
+	// not hold their credentials.
+	if len(f.getters) != 2 {
+		t.Errorf("expected a connection per cluster, got %d", len(f.getters))
+	}
+	for key := range f.getters {
+		if strings.Contains(key, "token") {
+			t.Errorf("expected no credentials in the key %q", key)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/deprecations"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// ReleaseConvertAPIs is the action for rewriting the APIs removed from the
+// cluster in the manifests stored in a release, so that the release can be
+// upgraded, rolled back or uninstalled.
+//
+// It provides the implementation of 'helm release convert-apis'.
+type ReleaseConvertAPIs struct {
+	cfg *Configuration
+
+	// KubeVersion is the version of Kubernetes whose removed APIs are
+	// converted. It defaults to the version of the cluster.
+	KubeVersion *chartutil.KubeVersion
+	// DryRun reports the conversions without storing them.
+	DryRun bool
+}
+
+// APIConversion is the result of converting the APIs of a release.
+type APIConversion struct {
+	Release     string `json:"release"`
+	Revision    int    `json:"revision"`
+	KubeVersion string `json:"kubeVersion"`
+	// Converted are the resources whose API versions were rewritten, with
+	// the APIs they used.
+	Converted []deprecations.Finding `json:"converted"`
+}
+
+// NewReleaseConvertAPIs creates a new ReleaseConvertAPIs object with the
+// given configuration.
+func NewReleaseConvertAPIs(cfg *Configuration) *ReleaseConvertAPIs {
+	return &ReleaseConvertAPIs{
+		cfg: cfg,
+	}
+}
+
+// Run converts the APIs of the latest revision of the release name.
+func (c *ReleaseConvertAPIs) Run(name string) (*APIConversion, error) {
+	if !c.DryRun {
+		if err := c.cfg.checkWritable("convert APIs"); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := c.cfg.releaseContent(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	kubeVersion := c.KubeVersion
+	if kubeVersion == nil {
+		caps, err := c.cfg.getCapabilities()
+		if err != nil {
+			return nil, err
+		}
+		kubeVersion = &caps.KubeVersion
+	}
+
+	rel, converted, err := convertReleaseAPIs(rel, kubeVersion.Version)
+	if err != nil {
+		return nil, err
+	}
+	if len(converted) > 0 && !c.DryRun {
+		if err := c.cfg.Releases.Update(rel); err != nil {
+			return nil, errors.Wrapf(err, "failed to store the converted release %q", name)
+		}
+	}
+	if converted == nil {
+		converted = []deprecations.Finding{}
+	}
+	return &APIConversion{
+		Release:     rel.Name,
+		Revision:    rel.Version,
+		KubeVersion: kubeVersion.Version,
+		Converted:   converted,
+	}, nil
+}
+
+// migrateReleaseAPIs returns rel with the APIs removed from the cluster
+// converted in its manifests, storing the converted release unless dryRun is
+// set.
+func (cfg *Configuration) migrateReleaseAPIs(rel *release.Release, dryRun bool) (*release.Release, error) {
+	caps, err := cfg.getCapabilities()
+	if err != nil {
+		return nil, err
+	}
+	rel, converted, err := convertReleaseAPIs(rel, caps.KubeVersion.Version)
+	if err != nil || len(converted) == 0 {
+		return rel, err
+	}
+	for _, f := range converted {
+		cfg.Log("converting %s %s of release %s (v%d) from %s to %s", f.Kind, f.Name, rel.Name, rel.Version, f.APIVersion, f.Replacement)
+	}
+	if dryRun {
+		return rel, nil
+	}
+	return rel, errors.Wrapf(cfg.Releases.Update(rel), "failed to store the converted release %q", rel.Name)
+}
+
+// convertReleaseAPIs returns a copy of rel with the APIs removed in
+// kubeVersion rewritten in its manifest and hooks, and in the resources it
+// records, along with the resources it rewrote.
+func convertReleaseAPIs(rel *release.Release, kubeVersion string) (*release.Release, []deprecations.Finding, error) {
+	db := deprecations.Default()
+	manifest, converted, err := db.Migrate(rel.Manifest, kubeVersion)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "converting the manifest of release %q", rel.Name)
+	}
+	copied := *rel
+	rel = &copied
+	rel.Manifest = manifest
+	hooks := make([]*release.Hook, len(rel.Hooks))
+	for i, h := range rel.Hooks {
+		manifest, hookConverted, err := db.Migrate(h.Manifest, kubeVersion)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "converting the hook %s of release %q", h.Name, rel.Name)
+		}
+		hooks[i] = h
+		if len(hookConverted) == 0 {
+			continue
+		}
+		for j := range hookConverted {
+			hookConverted[j].Source = h.Path
+		}
+		hook := *h
+		hook.Manifest = manifest
+		hooks[i] = &hook
+		converted = append(converted, hookConverted...)
+	}
+	if len(rel.Hooks) > 0 {
+		rel.Hooks = hooks
+	}
+	if rel.Info != nil {
+		info := *rel.Info
+		info.Resources = append([]release.ResourceIdentity(nil), info.Resources...)
+		for i, id := range info.Resources {
+			if api, ok := db.Lookup(id.APIVersion, id.Kind); ok && api.Replacement != "" {
+				if status, _ := api.Status(kubeVersion); status == deprecations.Removed {
+					info.Resources[i].APIVersion = api.Replacement
+				}
+			}
+		}
+		rel.Info = &info
+	}
+	return rel, converted, nil
+}
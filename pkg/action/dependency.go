@@ -65,6 +65,33 @@ func (d *Dependency) List(chartpath string, out io.Writer) error {
 	return nil
 }
 
+// DependencyStatus is the status of a dependency of a chart, as listed by
+// 'helm dependency list'.
+type DependencyStatus struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+	Status     string `json:"status"`
+}
+
+// Statuses returns the status of the dependencies of the chart at chartpath.
+func (d *Dependency) Statuses(chartpath string) ([]DependencyStatus, error) {
+	c, err := loader.Load(chartpath)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]DependencyStatus, 0, len(c.Metadata.Dependencies))
+	for _, dep := range c.Metadata.Dependencies {
+		statuses = append(statuses, DependencyStatus{
+			Name:       dep.Name,
+			Version:    dep.Version,
+			Repository: dep.Repository,
+			Status:     d.dependencyStatus(chartpath, dep, c),
+		})
+	}
+	return statuses, nil
+}
+
 // dependencyStatus returns a string describing the status of a dependency viz a viz the parent chart.
 func (d *Dependency) dependencyStatus(chartpath string, dep *chart.Dependency, parent *chart.Chart) string {
 	filename := fmt.Sprintf("%s-%s.tgz", dep.Name, "*")
@@ -0,0 +1,148 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// DesiredChart is the chart and values a release is declared with, as by
+// 'helm apply' or the controller, which install or upgrade the release
+// unless it was deployed from them.
+type DesiredChart struct {
+	// Chart is the chart as given to 'helm install', downloaded with its
+	// Version from the repository at RepoURL if set.
+	Chart   string
+	Version string
+	RepoURL string
+	// Values are the values overriding the ones of the chart.
+	Values map[string]interface{}
+}
+
+// Matches reports whether the release was deployed from the chart and
+// values it declares. The chart matches if it has the name the chart
+// reference resolves to and its version satisfies the declared version,
+// an exact version or a constraint.
+func (d DesiredChart) Matches(rel *release.Release) (bool, error) {
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return false, nil
+	}
+	if rel.Chart.Metadata.Name != chartRefName(d.Chart) || !versionMatches(rel.Chart.Metadata.Version, d.Version) {
+		return false, nil
+	}
+	// Compare the values through JSON, as the ones of the release are
+	// decoded from their storage.
+	want, err := normalizeValues(d.Values)
+	if err != nil {
+		return false, err
+	}
+	got, err := normalizeValues(rel.Config)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(want, got), nil
+}
+
+// OverrideValues returns the declared values, never nil as the
+// install and upgrade actions expect.
+func (d DesiredChart) OverrideValues() map[string]interface{} {
+	if d.Values == nil {
+		return map[string]interface{}{}
+	}
+	return d.Values
+}
+
+// chartRefName returns the name of the chart a reference resolves to:
+// "repo/name", "oci://registry/path/name:tag", a path, or the URL or path of
+// a "name-version.tgz" archive.
+func chartRefName(ref string) string {
+	name := path.Base(strings.TrimSuffix(strings.ReplaceAll(ref, "\\", "/"), "/"))
+	if strings.HasPrefix(ref, "oci://") {
+		if i := strings.LastIndex(name, ":"); i > 0 {
+			name = name[:i]
+		}
+		return name
+	}
+	if strings.HasSuffix(name, ".tgz") {
+		name = strings.TrimSuffix(name, ".tgz")
+		for i := 0; i < len(name); i++ {
+			if name[i] != '-' {
+				continue
+			}
+			if _, err := semver.StrictNewVersion(name[i+1:]); err == nil {
+				return name[:i]
+			}
+		}
+	}
+	return name
+}
+
+// versionMatches reports whether the version satisfies the wanted one, an
+// exact version or a constraint, any version if empty.
+func versionMatches(version, want string) bool {
+	if want == "" || version == want {
+		return true
+	}
+	c, err := semver.NewConstraint(want)
+	if err != nil {
+		return false
+	}
+	v, err := semver.NewVersion(version)
+	return err == nil && c.Check(v)
+}
+
+// normalizeValues returns the values as decoded from JSON, to compare them
+// with the values of stored releases.
+func normalizeValues(vals map[string]interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if len(vals) == 0 {
+		return out, nil
+	}
+	b, err := json.Marshal(vals)
+	if err != nil {
+		return nil, err
+	}
+	return out, json.Unmarshal(b, &out)
+}
+
+// LoadChart locates and loads the chart, which must have its dependencies.
+func (c *ChartPathOptions) LoadChart(name string, settings *cli.EnvSettings) (*chart.Chart, error) {
+	chartPath, err := c.LocateChart(name, settings)
+	if err != nil {
+		return nil, err
+	}
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if req := chrt.Metadata.Dependencies; req != nil {
+		if err := CheckDependencies(chrt, req); err != nil {
+			return nil, err
+		}
+	}
+	return chrt, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestDesiredChartMatches(t *testing.T) {
+	rel := &release.Release{
+		Chart:  &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.2.3"}},
+		Config: map[string]interface{}{"replicas": float64(2)},
+	}
+	values := map[string]interface{}{"replicas": 2}
+	tests := []struct {
+		chart, version string
+		values         map[string]interface{}
+		expect         bool
+	}{
+		{"web", "1.2.3", values, true},
+		{"web", "", values, true},
+		{"stable/web", "1.2.3", values, true},
+		{"oci://registry.example.com/team/web", "1.2.3", values, true},
+		{"oci://registry.example.com/team/web:1.2.3", "", values, true},
+		{"https://charts.example.com/web-1.2.3.tgz", "", values, true},
+		{"./charts/web", "", values, true},
+		{"charts/my-web-1.2.3.tgz", "", values, false},
+		{"web", "^1.2.0", values, true},
+		{"web", "~1.1.0", values, false},
+		{"web", "1.2.4", values, false},
+		{"api", "1.2.3", values, false},
+		{"web", "1.2.3", map[string]interface{}{"replicas": 3}, false},
+		{"web", "1.2.3", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := DesiredChart{Chart: tt.chart, Version: tt.version, Values: tt.values}.Matches(rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expect {
+			t.Errorf("%s %s %v: expected %t, got %t", tt.chart, tt.version, tt.values, tt.expect, got)
+		}
+	}
+}
+
+func TestApplyPlanUnchanged(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	rel := namedReleaseStub("web", release.StatusDeployed)
+	rel.Namespace = "apps"
+	if err := cfg.Releases.Create(rel); err != nil {
+		t.Fatal(err)
+	}
+	apply := NewApply(func(string) (*Configuration, error) { return cfg, nil }, nil)
+
+	for _, tt := range []struct {
+		declared DeclaredRelease
+		action   ApplyAction
+	}{
+		{DeclaredRelease{Name: "web", Namespace: "apps", Chart: "stable/hello", Version: "^0.1.0", Values: map[string]interface{}{"name": "value"}}, ApplyNone},
+		{DeclaredRelease{Name: "web", Namespace: "apps", Chart: "oci://registry.example.com/hello", Version: "0.1.0", Values: map[string]interface{}{"name": "value"}}, ApplyNone},
+		{DeclaredRelease{Name: "web", Namespace: "apps", Chart: "stable/hello", Version: "0.2.0", Values: map[string]interface{}{"name": "value"}}, ApplyUpgrade},
+		{DeclaredRelease{Name: "web", Namespace: "apps", Chart: "stable/hello", Values: map[string]interface{}{"name": "other"}}, ApplyUpgrade},
+	} {
+		plan, err := apply.Plan(&ReleasesManifest{Releases: []DeclaredRelease{tt.declared}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := plan.Steps[0].Action; got != tt.action {
+			t.Errorf("%s %s %v: expected %s, got %s", tt.declared.Chart, tt.declared.Version, tt.declared.Values, tt.action, got)
+		}
+	}
+}
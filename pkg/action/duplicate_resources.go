@@ -0,0 +1,150 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// DuplicateResourcesMode is what is done with the rendered resources of a
+// release sharing the same identity, the second of which would otherwise
+// silently overwrite the first when applied.
+type DuplicateResourcesMode string
+
+const (
+	// DuplicateResourcesError rejects the releases rendering duplicate
+	// resources.
+	DuplicateResourcesError DuplicateResourcesMode = "error"
+	// DuplicateResourcesWarn applies the releases rendering duplicate
+	// resources with a warning.
+	DuplicateResourcesWarn DuplicateResourcesMode = "warn"
+)
+
+// DuplicateResourcesModes are the valid duplicate resources modes.
+var DuplicateResourcesModes = []DuplicateResourcesMode{DuplicateResourcesError, DuplicateResourcesWarn}
+
+// ParseDuplicateResourcesMode parses a duplicate resources mode,
+// DuplicateResourcesError if empty.
+func ParseDuplicateResourcesMode(s string) (DuplicateResourcesMode, error) {
+	if s == "" {
+		return DuplicateResourcesError, nil
+	}
+	for _, m := range DuplicateResourcesModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	names := make([]string, len(DuplicateResourcesModes))
+	for i, m := range DuplicateResourcesModes {
+		names[i] = string(m)
+	}
+	return "", errors.Errorf("invalid duplicate resources mode %q, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// DuplicateResource is a resource rendered more than once by a release.
+type DuplicateResource struct {
+	// Resource identifies the resource, in the namespace of the release if
+	// the rendered documents do not set one.
+	Resource release.ResourceIdentity
+	// Sources are the paths of the templates rendering the resource, in the
+	// order of the manifest. A path is empty if the post-renderer dropped
+	// the source comments.
+	Sources []string
+}
+
+func (d DuplicateResource) String() string {
+	return fmt.Sprintf("%s is rendered by %s", d.Resource, strings.Join(d.Sources, " and "))
+}
+
+// DuplicateResourceError is returned by the actions rendering several
+// resources with the same identity while the configuration rejects them.
+type DuplicateResourceError struct {
+	Resources []DuplicateResource
+}
+
+func (e *DuplicateResourceError) Error() string {
+	names := make([]string, len(e.Resources))
+	for i, d := range e.Resources {
+		names[i] = d.String()
+	}
+	return "duplicate resources: " + strings.Join(names, "; ")
+}
+
+// IsDuplicateResource reports whether err is, or wraps, a
+// DuplicateResourceError.
+func IsDuplicateResource(err error) bool {
+	var duplicate *DuplicateResourceError
+	return errors.As(err, &duplicate)
+}
+
+// checkDuplicateResources looks for the resources of the rendered manifest of
+// rel sharing the same group, kind, namespace and name. It returns the ones
+// the DuplicateResources mode of the configuration lets through with a
+// warning, or a DuplicateResourceError if it rejects them. The hooks are not
+// checked, as several hooks may recreate the same resource for different
+// events.
+func (cfg *Configuration) checkDuplicateResources(rel *release.Release) ([]DuplicateResource, error) {
+	resources, err := releaseutil.ParseManifest(rel.Manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the rendered resources")
+	}
+
+	var duplicates []*DuplicateResource
+	seen := make(map[string]*DuplicateResource)
+	for _, r := range resources {
+		if r.Kind == "" || r.Name == "" {
+			continue
+		}
+		id := release.ResourceIdentity{
+			APIVersion: r.APIVersion(),
+			Kind:       r.Kind,
+			Namespace:  r.Namespace,
+			Name:       r.Name,
+		}
+		if id.Namespace == "" {
+			id.Namespace = rel.Namespace
+		}
+		if d, ok := seen[id.Key()]; ok {
+			if len(d.Sources) == 1 {
+				duplicates = append(duplicates, d)
+			}
+			d.Sources = append(d.Sources, r.Source)
+			continue
+		}
+		seen[id.Key()] = &DuplicateResource{Resource: id, Sources: []string{r.Source}}
+	}
+	if len(duplicates) == 0 {
+		return nil, nil
+	}
+	found := make([]DuplicateResource, len(duplicates))
+	for i, d := range duplicates {
+		found[i] = *d
+	}
+	if cfg.DuplicateResources == "" || cfg.DuplicateResources == DuplicateResourcesError {
+		return nil, &DuplicateResourceError{Resources: found}
+	}
+	for _, d := range found {
+		cfg.Log("warning: %s", d)
+	}
+	return found, nil
+}
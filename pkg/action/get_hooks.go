@@ -0,0 +1,117 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// GetHooks is the action for checking the hooks of a given release, with
+// the result of their last execution.
+//
+// It provides the implementation of 'helm get hooks'.
+type GetHooks struct {
+	cfg *Configuration
+
+	Version int
+	// Events, if set, restricts the hooks to the ones run on any of these
+	// events, e.g. "pre-upgrade".
+	Events []string
+	// Phases, if set, restricts the hooks to the ones whose last execution
+	// has any of these phases, e.g. "Failed". Hooks that never ran have the
+	// phase "Unknown".
+	Phases []string
+}
+
+// NewGetHooks creates a new GetHooks object with the given configuration.
+func NewGetHooks(cfg *Configuration) *GetHooks {
+	return &GetHooks{
+		cfg: cfg,
+	}
+}
+
+// Run executes 'helm get hooks' against the given release.
+func (g *GetHooks) Run(name string) ([]*release.Hook, error) {
+	events := make(map[release.HookEvent]bool, len(g.Events))
+	for _, e := range g.Events {
+		event, ok := releaseutil.ParseHookEvent(e)
+		if !ok {
+			return nil, errors.Errorf("unknown hook event %q", e)
+		}
+		events[event] = true
+	}
+	phases := make(map[release.HookPhase]bool, len(g.Phases))
+	for _, p := range g.Phases {
+		phase, ok := parseHookPhase(p)
+		if !ok {
+			return nil, errors.Errorf("unknown hook phase %q", p)
+		}
+		phases[phase] = true
+	}
+
+	if err := g.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := g.cfg.releaseContent(name, g.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := []*release.Hook{}
+	for _, h := range rel.Hooks {
+		if len(phases) > 0 && !phases[hookPhase(h)] {
+			continue
+		}
+		if len(events) > 0 && !hasAnyEvent(h, events) {
+			continue
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// hookPhase returns the phase of the last execution of the hook, which is
+// unknown if it never ran.
+func hookPhase(h *release.Hook) release.HookPhase {
+	if h.LastRun.Phase == "" {
+		return release.HookPhaseUnknown
+	}
+	return h.LastRun.Phase
+}
+
+func hasAnyEvent(h *release.Hook, events map[release.HookEvent]bool) bool {
+	for _, e := range h.Events {
+		if events[e] {
+			return true
+		}
+	}
+	return false
+}
+
+func parseHookPhase(s string) (release.HookPhase, bool) {
+	for _, p := range []release.HookPhase{release.HookPhaseUnknown, release.HookPhaseRunning, release.HookPhaseSucceeded, release.HookPhaseFailed} {
+		if strings.EqualFold(s, p.String()) {
+			return p, true
+		}
+	}
+	return "", false
+}
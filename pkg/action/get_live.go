@@ -0,0 +1,115 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// LiveResource is the live state of a resource of the manifest of a release.
+type LiveResource struct {
+	AuditResource
+	// Exists is false if the resource is missing from the cluster.
+	Exists bool `json:"exists"`
+	// Ready is the readiness of the resource, see kube.ReadyChecker.
+	Ready bool `json:"ready"`
+	// Drift are the fields of the resource whose live value differs from
+	// the manifest.
+	Drift []kube.FieldDrift `json:"drift,omitempty"`
+	// Error is the error met getting the state of the resource.
+	Error string `json:"error,omitempty"`
+}
+
+// LiveRelease is a release joined with the live state of its resources.
+type LiveRelease struct {
+	Release *release.Release `json:"release"`
+	// Resources is the live state of the resources of the manifest of the
+	// release, in the order of the manifest.
+	Resources []LiveResource `json:"resources,omitempty"`
+}
+
+// RunLive gets the release like Run and the live state of the resources of
+// its manifest.
+func (g *Get) RunLive(ctx context.Context, name string) (*LiveRelease, error) {
+	rel, err := g.Run(name)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := g.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+
+	live := &LiveRelease{Release: rel, Resources: []LiveResource{}}
+	if len(resources) == 0 {
+		return live, nil
+	}
+	cs, err := g.cfg.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	checker := kube.NewReadyChecker(cs, g.cfg.Log, kube.PausedAsReady(true), kube.CheckJobs(true))
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		state := LiveResource{AuditResource: auditResource(info.Mapping.GroupVersionKind, info.Namespace, info.Name)}
+		if err := liveState(ctx, &state, info, checker); err != nil {
+			state.Error = err.Error()
+		}
+		live.Resources = append(live.Resources, state)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+// liveState fills state with the live state of the resource of info.
+func liveState(ctx context.Context, state *LiveResource, info *resource.Info, checker kube.ReadyChecker) error {
+	obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state.Exists = true
+
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+	if err != nil {
+		return err
+	}
+	current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	state.Drift = kube.Drift(desired, current)
+
+	state.Ready, err = checker.IsReady(ctx, info)
+	return err
+}
@@ -18,6 +18,7 @@ package action
 
 import (
 	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
 )
 
 // GetValues is the action for checking a given release's values.
@@ -28,6 +29,11 @@ type GetValues struct {
 
 	Version   int
 	AllValues bool
+
+	// Overrides are the values pinned on the release, set by Run. They are
+	// not part of the user-supplied values, and are applied over the
+	// computed values.
+	Overrides []*release.PinnedOverride
 }
 
 // NewGetValues creates a new GetValues object with the given configuration.
@@ -47,10 +53,15 @@ func (g *GetValues) Run(name string) (map[string]interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	g.Overrides = rel.Overrides
 
 	// If the user wants all values, compute the values and return.
 	if g.AllValues {
-		cfg, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+		vals, err := applyOverrides(rel.Config, rel.Overrides)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := chartutil.CoalesceValues(rel.Chart, vals)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,160 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+)
+
+// HistoryPrune is the action for deleting old revisions from a release's
+// ledger according to a storage.RetentionPolicy.
+//
+// It provides the implementation of 'helm release prune'. Embedders that
+// want to garbage collect release history on a schedule can call RunAll
+// periodically.
+type HistoryPrune struct {
+	cfg *Configuration
+
+	Policy storage.RetentionPolicy
+	DryRun bool
+}
+
+// NewHistoryPrune creates a new HistoryPrune object with the given
+// configuration. Its policy defaults to the configuration's Retention.
+func NewHistoryPrune(cfg *Configuration) *HistoryPrune {
+	p := &HistoryPrune{
+		cfg:    cfg,
+		Policy: storage.DefaultRetentionPolicy,
+	}
+	if cfg.Retention != nil {
+		p.Policy = *cfg.Retention
+	}
+	return p
+}
+
+// Run prunes the history of the named release and returns the revisions
+// that were deleted, or that would be deleted in dry-run mode.
+func (p *HistoryPrune) Run(name string) ([]*release.Release, error) {
+	if !p.DryRun {
+		if err := p.cfg.checkWritable("release prune"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	h, err := p.cfg.Releases.History(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.prune(name, h)
+}
+
+// RunAll prunes the history of every release in the configured namespace
+// and returns the revisions that were deleted, or that would be deleted in
+// dry-run mode.
+func (p *HistoryPrune) RunAll() ([]*release.Release, error) {
+	if !p.DryRun {
+		if err := p.cfg.checkWritable("release prune"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	all, err := p.cfg.Releases.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	histories := make(map[string][]*release.Release)
+	for _, rel := range all {
+		histories[rel.Name] = append(histories[rel.Name], rel)
+	}
+	names := make([]string, 0, len(histories))
+	for name := range histories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pruned []*release.Release
+	for _, name := range names {
+		rels, err := p.prune(name, histories[name])
+		pruned = append(pruned, rels...)
+		if err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+// applyRetention prunes the history of the named release according to the
+// configured Retention policy, if any. Install and Upgrade call it once the
+// new revision is recorded; failures are only logged since the operation
+// itself succeeded.
+func (cfg *Configuration) applyRetention(name string) {
+	if cfg.Retention == nil {
+		return
+	}
+	h, err := cfg.Releases.History(name)
+	if err == nil {
+		_, err = NewHistoryPrune(cfg).prune(name, h)
+	}
+	if err != nil {
+		cfg.Log("failed to apply the retention policy to %s: %s", name, err)
+	}
+}
+
+func (p *HistoryPrune) prune(name string, history []*release.Release) ([]*release.Release, error) {
+	selected := p.Policy.Select(history, p.cfg.Now().Time)
+	if p.DryRun {
+		return selected, nil
+	}
+
+	// Delete as many as possible, so that repeated invocations eventually
+	// converge even if some deletions fail.
+	var pruned []*release.Release
+	var errs []error
+	for _, rel := range selected {
+		if _, err := p.cfg.Releases.Delete(rel.Name, rel.Version); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, rel)
+	}
+
+	p.cfg.Log("pruned %d record(s) from %s with %d error(s)", len(pruned), name, len(errs))
+	switch c := len(errs); c {
+	case 0:
+		return pruned, nil
+	case 1:
+		return pruned, errs[0]
+	default:
+		return pruned, errors.Errorf("encountered %d deletion errors. First is: %s", c, errs[0])
+	}
+}
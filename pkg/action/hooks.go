@@ -42,7 +42,7 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 	agentVersion,
 	testLabel,
 	namespace string,
-	isTest bool) error {
+	isTest bool) (err error) {
 	executingHooks := []*release.Hook{}
 
 	for _, h := range rl.Hooks {
@@ -53,6 +53,13 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 		}
 	}
 
+	if len(executingHooks) > 0 && cfg.Metrics != nil {
+		start := time.Now()
+		defer func() {
+			cfg.Metrics.ObserveHook(hook.String(), time.Since(start), err)
+		}()
+	}
+
 	// hooke are pre-ordered by kind, so keep order stable
 	sort.Stable(hookByWeight(executingHooks))
 
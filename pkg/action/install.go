@@ -33,23 +33,26 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/resource"
-	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/cli"
 	"github.com/open-hand/helm/pkg/downloader"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/kube"
 	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/policy"
 	"github.com/open-hand/helm/pkg/postrender"
+	"github.com/open-hand/helm/pkg/provenance"
 	"github.com/open-hand/helm/pkg/registry"
 	"github.com/open-hand/helm/pkg/release"
 	"github.com/open-hand/helm/pkg/releaseutil"
+	"github.com/open-hand/helm/pkg/repo"
 	"github.com/open-hand/helm/pkg/storage"
 	"github.com/open-hand/helm/pkg/storage/driver"
 )
@@ -75,6 +78,8 @@ type Install struct {
 	Replace                  bool
 	Wait                     bool
 	WaitForJobs              bool
+	WaitStrategy             kube.WaitStrategy // how the resources are waited for with Wait, the default of the Kubernetes client if empty
+	ApplyConcurrency         int               // the maximum number of resources of a kind created or updated at the same time, all at once if unset
 	Devel                    bool
 	DependencyUpdate         bool
 	Timeout                  time.Duration
@@ -94,12 +99,54 @@ type Install struct {
 	// (for things like templating). These are ignored if ClientOnly is false
 	KubeVersion *chartutil.KubeVersion
 	APIVersions chartutil.VersionSet
+	// Capabilities are the capabilities of the target cluster, such as the
+	// ones of a capabilities file, to render the chart with instead of the
+	// default ones if ClientOnly, KubeVersion and APIVersions applying over
+	// them, or instead of the ones of the cluster for a dry run. They cannot
+	// be given for an actual install.
+	Capabilities *chartutil.Capabilities
 	// Used by helm template to render charts with .Release.IsUpgrade. Ignored if Dry-Run is false
 	IsUpgrade bool
 	// Used by helm template to add the release as part of OutputDir path
 	// OutputDir/<ReleaseName>
 	UseReleaseName bool
 	PostRenderer   postrender.PostRenderer
+	// PolicyChecker enforces policies on the rendered resources before they
+	// are applied, or printed on dry runs.
+	PolicyChecker *policy.Checker
+	// ClusterLookup lets the lookup function of the templates read the
+	// resources of the cluster on dry runs, where it otherwise finds
+	// nothing. See kube.WithReadOnly to guarantee that dry runs connecting
+	// to the cluster do not change it.
+	ClusterLookup bool
+	// TakeOwnership adopts the existing resources owned by other releases
+	// or not managed by Helm instead of failing.
+	TakeOwnership bool
+	// CrossNamespaceResources lists the rendered resources targeting other
+	// namespaces than the namespace of the release, or cluster-scoped
+	// resources of kinds not allowed, that the NamespacePolicy of the
+	// configuration lets through with a warning.
+	CrossNamespaceResources []release.ResourceIdentity
+	// DuplicateResources lists the resources rendered more than once that
+	// the DuplicateResources mode of the configuration lets through with a
+	// warning.
+	DuplicateResources []DuplicateResource
+	// SkippedResources lists the rendered resources left out of the release
+	// because their only-on annotations, such as helm.sh/only-kube-version,
+	// exclude the cluster.
+	SkippedResources []SkippedResource
+	// Preflight checks the compute resources requested by the rendered
+	// workloads against the ResourceQuotas of the namespace and the capacity
+	// of the nodes before anything is applied. See Configuration.Preflight.
+	Preflight bool
+	// NamespaceOptions configure the namespace created with CreateNamespace.
+	NamespaceOptions NamespaceOptions
+	// Ordering customizes the order in which the resources are applied.
+	Ordering kube.Ordering
+	// Waves applies the resources in the waves of their kube.WaveAnnotation,
+	// each once the previous one is ready, and records the progress in the
+	// release.
+	Waves bool
 
 	Command         int64
 	V1Command       string
@@ -128,11 +175,39 @@ type ChartPathOptions struct {
 	RepoURL               string // --repo
 	Username              string // --username
 	Verify                bool   // --verify
-	Version               string // --version
+	Version               string // --version, a version constraint or a digest
+	VerifyCosign          bool   // --verify-cosign
+	// Channel restricts the versions of the charts of repositories to the
+	// ones available in the release channel, see repo.ChannelStable.
+	Channel string // --channel
+
+	// Cosign configures the verification of cosign signatures, see
+	// VerifyCosign.
+	Cosign provenance.CosignOptions // --cosign-*
 
 	// registryClient provides a registry client but is not added with
 	// options from a flag
 	registryClient *registry.Client
+	// signaturePolicy is the signature policy of the configuration
+	signaturePolicy *SignaturePolicy
+	// tracerProvider is the tracer provider of the configuration
+	tracerProvider trace.TracerProvider
+	// metrics are the metrics of the configuration
+	metrics Metrics
+}
+
+// UseDevel makes the development versions of the charts available, as the
+// edge channel does, unless another channel is set.
+func (c *ChartPathOptions) UseDevel(devel bool) {
+	if devel && c.Channel == "" {
+		c.Channel = repo.ChannelEdge
+	}
+}
+
+// SetRegistryClient sets the registry client used to locate the charts of
+// OCI registries, for options built outside of an action.
+func (c *ChartPathOptions) SetRegistryClient(client *registry.Client) {
+	c.registryClient = client
 }
 
 // NewInstall creates a new Install object with the given configuration.
@@ -170,6 +245,9 @@ func NewInstall(cfg *Configuration,
 		CreateNamespace:  true,
 	}
 	in.ChartPathOptions.registryClient = cfg.RegistryClient
+	in.ChartPathOptions.signaturePolicy = cfg.SignaturePolicy
+	in.ChartPathOptions.tracerProvider = cfg.TracerProvider
+	in.ChartPathOptions.metrics = cfg.Metrics
 
 	return in
 }
@@ -228,6 +306,28 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}, valsRaw st
 
 // Run executes the installation with Context
 func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals map[string]interface{}, valsRaw string) (*release.Release, error) {
+	ctx, span := i.cfg.tracer().Start(ctx, "helm.install", trace.WithAttributes(
+		releaseNameKey.String(i.ReleaseName),
+		releaseNamespaceKey.String(i.Namespace)))
+	start := time.Now()
+	rel, err := i.run(ctx, chrt, vals, valsRaw)
+	observeAction(i.cfg.Metrics, "install", start, err)
+	if err == nil && !i.DryRun && !i.ClientOnly {
+		i.cfg.applyRetention(rel.Name)
+	}
+	if rel != nil {
+		span.SetAttributes(releaseAttributes(rel)...)
+	}
+	endSpan(span, err)
+	return rel, err
+}
+
+func (i *Install) run(ctx context.Context, chrt *chart.Chart, vals map[string]interface{}, valsRaw string) (*release.Release, error) {
+	if !i.DryRun && !i.ClientOnly {
+		if err := i.cfg.checkWritable("install"); err != nil {
+			return nil, err
+		}
+	}
 	// Check reachability of cluster unless in client-only mode (e.g. `helm template` without `--validate`)
 	if !i.ClientOnly {
 		if err := i.cfg.KubeClient.IsReachable(); err != nil {
@@ -239,6 +339,12 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 		return nil, err
 	}
 
+	if !i.DryRun && !i.ClientOnly {
+		if err := i.cfg.checkOperation(OperationInstall, i.ReleaseName, i.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := chartutil.ProcessDependencies(chrt, vals); err != nil {
 		return nil, err
 	}
@@ -258,6 +364,9 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 		// Add mock objects in here so it doesn't use Kube API server
 		// NOTE(bacongobbler): used for `helm template`
 		i.cfg.Capabilities = chartutil.DefaultCapabilities.Copy()
+		if i.Capabilities != nil {
+			i.cfg.Capabilities = i.Capabilities.Copy()
+		}
 		if i.KubeVersion != nil {
 			i.cfg.Capabilities.KubeVersion = *i.KubeVersion
 		}
@@ -270,6 +379,12 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	} else if !i.ClientOnly && len(i.APIVersions) > 0 {
 		i.cfg.Log("API Version list given outside of client only mode, this list will be ignored")
 	}
+	if i.Capabilities != nil && !i.ClientOnly {
+		if !i.DryRun {
+			return nil, errors.New("the capabilities of the cluster can only be given for a dry run")
+		}
+		i.cfg.Capabilities = i.Capabilities.Copy()
+	}
 
 	// Make sure if Atomic is set, that wait is set as well. This makes it so
 	// the user doesn't have to specify both
@@ -297,7 +412,11 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	rel := i.createRelease(chrt, vals, valsRaw)
 
 	var manifestDoc *bytes.Buffer
-	rel.Hooks, manifestDoc, rel.Info.Notes, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, i.DryRun)
+	err = i.cfg.traced(ctx, "helm.render", func(context.Context) error {
+		var err error
+		rel.Hooks, manifestDoc, rel.Info.Notes, i.SkippedResources, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, i.DryRun && !i.ClusterLookup)
+		return err
+	})
 	// Even for errors, attach this if available
 	if manifestDoc != nil {
 		rel.Manifest = manifestDoc.String()
@@ -309,6 +428,22 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 		return rel, err
 	}
 
+	if err := checkPolicies(i.PolicyChecker, rel); err != nil {
+		return nil, err
+	}
+	if i.CrossNamespaceResources, err = i.cfg.checkNamespacePolicy(rel); err != nil {
+		return nil, err
+	}
+	if i.DuplicateResources, err = i.cfg.checkDuplicateResources(rel); err != nil {
+		return nil, err
+	}
+
+	if i.Preflight && !i.ClientOnly {
+		if err := i.cfg.checkPreflight(i.Namespace, rel.Manifest, ""); err != nil {
+			return nil, err
+		}
+	}
+
 	// Mark this release as in-progress
 	rel.SetStatus(release.StatusPendingInstall, "Initial install underway")
 
@@ -318,6 +453,9 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
 	}
+	if err := checkOrdering(resources, i.Ordering, i.Waves); err != nil {
+		return nil, errors.Wrap(err, "unable to order kubernetes objects of release manifest")
+	}
 
 	// 在这里对要创建的对象添加标签
 	for _, r := range resources {
@@ -332,6 +470,7 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	if err != nil {
 		return nil, err
 	}
+	rel.Info.Resources = i.cfg.resourceIdentities(rel, resources)
 
 	// Install requires an extra validation step of checking that resources
 	// don't already exist before we actually create resources. If we continue
@@ -340,7 +479,7 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	// deleting the release because the manifest will be pointing at that
 	// resource
 	if !i.ClientOnly && !isUpgrade && len(resources) > 0 {
-		toBeAdopted, err = existingResourceConflict(resources, rel.Name, rel.Namespace)
+		toBeAdopted, err = existingResourceConflict(resources, rel.Name, rel.Namespace, i.TakeOwnership)
 		if err != nil {
 			return nil, errors.Wrap(err, "rendered manifests contain a resource that already exists. Unable to continue with install")
 		}
@@ -353,28 +492,7 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	}
 
 	if i.CreateNamespace {
-		ns := &v1.Namespace{
-			TypeMeta: metav1.TypeMeta{
-				APIVersion: "v1",
-				Kind:       "Namespace",
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: i.Namespace,
-				Labels: map[string]string{
-					"name": i.Namespace,
-					"helm": "helm3",
-				},
-			},
-		}
-		buf, err := yaml.Marshal(ns)
-		if err != nil {
-			return nil, err
-		}
-		resourceList, err := i.cfg.KubeClient.Build(bytes.NewBuffer(buf), true)
-		if err != nil {
-			return nil, err
-		}
-		if _, err := i.cfg.KubeClient.Create(resourceList); err != nil && !apierrors.IsAlreadyExists(err) {
+		if err := i.cfg.createNamespace(i.Namespace, i.ReleaseName, i.NamespaceOptions); err != nil {
 			return nil, err
 		}
 	}
@@ -397,18 +515,20 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	rChan := make(chan resultMessage)
 	doneChan := make(chan struct{})
 	defer close(doneChan)
-	go i.performInstall(rChan, rel, toBeAdopted, resources)
+	go i.performInstall(ctx, rChan, rel, toBeAdopted, resources)
 	go i.handleContext(ctx, rChan, doneChan, rel)
 	result := <-rChan
 	//start preformInstall go routine
 	return result.r, result.e
 }
 
-func (i *Install) performInstall(c chan<- resultMessage, rel *release.Release, toBeAdopted kube.ResourceList, resources kube.ResourceList) {
+func (i *Install) performInstall(ctx context.Context, c chan<- resultMessage, rel *release.Release, toBeAdopted kube.ResourceList, resources kube.ResourceList) {
 
 	// pre-install hooks
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPreInstall, i.Timeout, i.ImagePullSecret, i.Command, i.V1Command, i.AppServiceId, i.V1AppServiceId, i.Commit, i.ChartVersion, i.ReleaseName, i.ChartName, i.AgentVersion, i.TestLabel, i.Namespace, i.IsTest); err != nil {
+		if err := i.cfg.traced(ctx, "helm.hooks.pre-install", func(context.Context) error {
+			return i.cfg.execHook(rel, release.HookPreInstall, i.Timeout, i.ImagePullSecret, i.Command, i.V1Command, i.AppServiceId, i.V1AppServiceId, i.Commit, i.ChartVersion, i.ReleaseName, i.ChartName, i.AgentVersion, i.TestLabel, i.Namespace, i.IsTest)
+		}); err != nil {
 			i.reportToRun(c, rel, fmt.Errorf("failed pre-install: %s", err))
 			return
 		}
@@ -418,38 +538,47 @@ func (i *Install) performInstall(c chan<- resultMessage, rel *release.Release, t
 	// do an update, but it's not clear whether we WANT to do an update if the re-use is set
 	// to true, since that is basically an upgrade operation.
 	if len(toBeAdopted) == 0 && len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Create(resources); err != nil {
+		if err := i.cfg.traced(ctx, "helm.kube.create", func(context.Context) error {
+			return i.cfg.createResources(rel, resources, i.Ordering, i.Waves, i.Timeout, i.cfg.applyOptions(i.ApplyConcurrency))
+		}, resourceCountKey.Int(len(resources))); err != nil {
 			i.reportToRun(c, rel, err)
 			return
 		}
 	} else if len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Update(toBeAdopted, resources, false); err != nil {
+		if err := i.cfg.traced(ctx, "helm.kube.update", func(context.Context) error {
+			_, err := i.cfg.updateResources(rel, toBeAdopted, resources, false, i.Ordering, i.Waves, i.Timeout, i.cfg.applyOptions(i.ApplyConcurrency))
+			return err
+		}, resourceCountKey.Int(len(resources))); err != nil {
 			i.reportToRun(c, rel, err)
 			return
 		}
 	}
 
 	if i.Wait {
-		if i.WaitForJobs {
-			if err := i.cfg.KubeClient.WaitWithJobs(resources, i.Timeout); err != nil {
-				i.reportToRun(c, rel, err)
-				return
-			}
-		} else {
-			if err := i.cfg.KubeClient.Wait(resources, i.Timeout); err != nil {
-				i.reportToRun(c, rel, err)
-				return
-			}
+		if err := i.cfg.traced(ctx, "helm.kube.wait", func(context.Context) error {
+			return i.cfg.waitForResources(rel, resources, i.Timeout, i.WaitForJobs, i.WaitStrategy)
+		}, resourceCountKey.Int(len(resources))); err != nil {
+			i.reportToRun(c, rel, err)
+			return
 		}
 	}
 
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPostInstall, i.Timeout, i.ImagePullSecret, i.Command, i.V1Command, i.AppServiceId, i.V1AppServiceId, i.Commit, i.ChartVersion, i.ReleaseName, i.ChartName, i.AgentVersion, i.TestLabel, i.Namespace, i.IsTest); err != nil {
+		if err := i.cfg.traced(ctx, "helm.hooks.post-install", func(context.Context) error {
+			return i.cfg.execHook(rel, release.HookPostInstall, i.Timeout, i.ImagePullSecret, i.Command, i.V1Command, i.AppServiceId, i.V1AppServiceId, i.Commit, i.ChartVersion, i.ReleaseName, i.ChartName, i.AgentVersion, i.TestLabel, i.Namespace, i.IsTest)
+		}); err != nil {
 			i.reportToRun(c, rel, fmt.Errorf("failed post-install: %s", err))
 			return
 		}
 	}
 
+	notes, err := postProcessNotes(rel)
+	if err != nil {
+		i.cfg.releaseLogger(rel).Warn("unable to post-process the notes", "error", err)
+	}
+	rel.Info.Notes = notes
+
+	i.cfg.markCompleted(rel)
 	if len(i.Description) > 0 {
 		rel.SetStatus(release.StatusDeployed, i.Description)
 	} else {
@@ -464,9 +593,10 @@ func (i *Install) performInstall(c chan<- resultMessage, rel *release.Release, t
 	// One possible strategy would be to do a timed retry to see if we can get
 	// this stored in the future.
 	if err := i.recordRelease(rel); err != nil {
-		i.cfg.Log("failed to record the release: %s", err)
+		i.cfg.releaseLogger(rel).Error("failed to record the release", "error", err)
 	}
 
+	i.cfg.releaseLogger(rel).Info("release installed")
 	i.reportToRun(c, rel, nil)
 }
 func (i *Install) handleContext(ctx context.Context, c chan<- resultMessage, done chan struct{}, rel *release.Release) {
@@ -487,7 +617,9 @@ func (i *Install) reportToRun(c chan<- resultMessage, rel *release.Release, err
 	i.Lock.Unlock()
 }
 func (i *Install) failRelease(rel *release.Release, err error) (*release.Release, error) {
+	i.cfg.markCompleted(rel)
 	rel.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", i.ReleaseName, err.Error()))
+	i.cfg.releaseLogger(rel).Warn("install failed", "error", err)
 	if i.Atomic {
 		i.cfg.Log("Install failed and atomic is set, uninstalling release")
 		uninstall := NewUninstall(i.cfg)
@@ -547,6 +679,7 @@ func (i *Install) createRelease(chrt *chart.Chart, values map[string]interface{}
 			FirstDeployed: ts,
 			LastDeployed:  ts,
 			Status:        release.StatusUnknown,
+			DeployedBy:    i.cfg.User,
 		},
 		Version: 1,
 	}
@@ -724,7 +857,137 @@ OUTER:
 // - URL
 //
 // If 'verify' was set on ChartPathOptions, this will attempt to also verify the chart.
+//
+// Library users should prefer ResolveChart, which resolves local paths,
+// repository references, URLs and OCI references alike and reports where the
+// chart was found.
 func (c *ChartPathOptions) LocateChart(name string, settings *cli.EnvSettings) (string, error) {
+	ctx, span := tracerProvider(c.tracerProvider).Tracer(tracerName).Start(context.Background(), "helm.chart.locate",
+		trace.WithAttributes(chartNameKey.String(name), chartVersionKey.String(c.Version)))
+	start := time.Now()
+	path, err := c.locateChart(ctx, name, settings)
+	if c.metrics != nil && !c.isLocalChart(name) {
+		c.metrics.ObserveChartDownload(name, time.Since(start), err)
+	}
+	endSpan(span, err)
+	return path, err
+}
+
+// isLocalChart tells whether name is the path of a chart on disk rather than
+// a chart to download.
+func (c *ChartPathOptions) isLocalChart(name string) bool {
+	if c.RepoURL != "" {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSpace(name))
+	return err == nil
+}
+
+// newChartDownloader returns the downloader of the charts located with the
+// options.
+func (c *ChartPathOptions) newChartDownloader(ctx context.Context, settings *cli.EnvSettings) (*downloader.ChartDownloader, error) {
+	dl := &downloader.ChartDownloader{
+		Out:     os.Stdout,
+		Keyring: c.Keyring,
+		Getters: getter.All(settings),
+		Options: []getter.Option{
+			getter.WithBasicAuth(c.Username, c.Password),
+			getter.WithPassCredentialsAll(c.PassCredentialsAll),
+			getter.WithTLSClientConfig(c.CertFile, c.KeyFile, c.CaFile),
+			getter.WithInsecureSkipVerifyTLS(c.InsecureSkipTLSverify),
+			getter.WithContext(ctx),
+			getter.WithTracerProvider(c.tracerProvider),
+		},
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+		RegistryClient:   c.registryClient,
+		Channel:          c.Channel,
+	}
+	if err := c.applySignaturePolicy(dl, c.signaturePolicy); err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
+
+// chartFiles returns the files with the given names of the chart name
+// located like LocateChart, without downloading its whole archive when
+// possible, see downloader.ChartDownloader.ChartFiles.
+func (c *ChartPathOptions) chartFiles(name string, settings *cli.EnvSettings, names ...string) ([]*loader.BufferedFile, error) {
+	ctx, span := tracerProvider(c.tracerProvider).Tracer(tracerName).Start(context.Background(), "helm.chart.files",
+		trace.WithAttributes(chartNameKey.String(name), chartVersionKey.String(c.Version)))
+	version := strings.TrimSpace(c.Version)
+	ref, err := c.chartRef(name, settings)
+
+	var dl *downloader.ChartDownloader
+	if err == nil {
+		dl, err = c.newChartDownloader(ctx, settings)
+	}
+	var files []*loader.BufferedFile
+	if err == nil {
+		files, err = dl.ChartFiles(ref, version, names...)
+	}
+	endSpan(span, err)
+	if err != nil {
+		atVersion := ""
+		if version != "" {
+			atVersion = fmt.Sprintf(" at version %q", version)
+		}
+		return nil, errors.Wrapf(err, "failed to fetch %q%s", name, atVersion)
+	}
+	return files, nil
+}
+
+// chartRef returns the reference downloaded for the chart name.
+//
+// A "repo/chart" or "@repo/chart" name refers to a chart of a configured
+// repository, by its name or one of its aliases, when no repository is given.
+// A repository given by the name or alias of a configured repository, or by
+// an alias of the project configuration, is resolved the same way. Otherwise
+// the chart is the archive of its version in the repository given by URL, or
+// the one with its digest in the index of the repository when the version is
+// a digest.
+func (c *ChartPathOptions) chartRef(name string, settings *cli.EnvSettings) (string, error) {
+	name = strings.TrimSpace(name)
+	repoURL := settings.ResolveRepo(c.RepoURL)
+	if repoURL == "" && isRepoChartRef(name) {
+		return name, nil
+	}
+	if e := configuredRepo(repoURL, settings); e != nil {
+		return path.Join(e.Name, name), nil
+	}
+	version := strings.TrimSpace(c.Version)
+	if repo.IsDigest(version) {
+		return repo.FindChartInChannelRepoURL(repoURL, c.Username, c.Password, name, version, c.Channel,
+			c.CertFile, c.KeyFile, c.CaFile, getter.All(settings))
+	}
+	return fmt.Sprintf("%scharts/%s-%s.tgz", repoURL, name, version), nil
+}
+
+// configuredRepo returns the configured repository named, or aliased, name,
+// optionally prefixed with "@". It returns nil when name is a URL or no
+// repository has that name.
+func configuredRepo(name string, settings *cli.EnvSettings) *repo.Entry {
+	if name == "" || strings.Contains(name, "://") {
+		return nil
+	}
+	rf, err := repo.LoadFile(settings.RepositoryConfig)
+	if err != nil {
+		return nil
+	}
+	return rf.Get(strings.TrimPrefix(name, "@"))
+}
+
+// isRepoChartRef tells whether name refers to a chart of a configured
+// repository, like "repo/chart" or "@repo/chart".
+func isRepoChartRef(name string) bool {
+	if strings.Count(name, "/") != 1 || strings.Contains(name, "://") || strings.HasPrefix(name, ".") || filepath.IsAbs(name) {
+		return false
+	}
+	_, err := os.Stat(name)
+	return os.IsNotExist(err)
+}
+
+func (c *ChartPathOptions) locateChart(ctx context.Context, name string, settings *cli.EnvSettings) (string, error) {
 	//// If there is no registry client and the name is in an OCI registry return
 	//// an error and a lookup will not occur.
 	//if registry.IsOCI(name) && c.registryClient == nil {
@@ -733,7 +996,10 @@ func (c *ChartPathOptions) LocateChart(name string, settings *cli.EnvSettings) (
 	//
 	//name = strings.TrimSpace(name)
 	version := strings.TrimSpace(c.Version)
-	name = fmt.Sprintf("%scharts/%s-%s.tgz", c.RepoURL, strings.TrimSpace(name), version)
+	name, err := c.chartRef(name, settings)
+	if err != nil {
+		return "", err
+	}
 	//if _, err := os.Stat(name); err == nil && strings.HasSuffix(name, ".tgz") {
 	//	abs, err := filepath.Abs(name)
 	//	if err != nil {
@@ -750,19 +1016,9 @@ func (c *ChartPathOptions) LocateChart(name string, settings *cli.EnvSettings) (
 	//	return name, errors.Errorf("path %q not found", name)
 	//}
 	//
-	dl := downloader.ChartDownloader{
-		Out:     os.Stdout,
-		Keyring: c.Keyring,
-		Getters: getter.All(settings),
-		Options: []getter.Option{
-			getter.WithBasicAuth(c.Username, c.Password),
-			getter.WithPassCredentialsAll(c.PassCredentialsAll),
-			getter.WithTLSClientConfig(c.CertFile, c.KeyFile, c.CaFile),
-			getter.WithInsecureSkipVerifyTLS(c.InsecureSkipTLSverify),
-		},
-		RepositoryConfig: settings.RepositoryConfig,
-		RepositoryCache:  settings.RepositoryCache,
-		RegistryClient:   c.registryClient,
+	dl, err := c.newChartDownloader(ctx, settings)
+	if err != nil {
+		return "", err
 	}
 
 	//if c.Verify {
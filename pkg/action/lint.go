@@ -22,10 +22,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mitchellh/copystructure"
 	"github.com/pkg/errors"
 
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/lint"
+	"github.com/open-hand/helm/pkg/lint/rules"
 	"github.com/open-hand/helm/pkg/lint/support"
 )
 
@@ -37,6 +39,25 @@ type Lint struct {
 	Namespace     string
 	WithSubcharts bool
 	Quiet         bool
+	// Analyzers are run over the objects rendered from the charts, after
+	// the built-in rules.
+	Analyzers []support.Analyzer
+	// ValuesDir is a directory of values files, such as the "ci" directory of
+	// a chart. When it is set, charts are linted once with each values file
+	// in it, merged under the given values. A relative directory is looked up
+	// in the chart directory first.
+	ValuesDir string
+	// ConfigFile is a lint configuration file applied to all the charts,
+	// such as the one of an organization. It takes precedence over the
+	// .helmlint.yaml file of a chart, which only configures the rules it
+	// leaves out.
+	ConfigFile string
+	// UnusedValues reports the given values and the default values of the
+	// charts that no template uses.
+	UnusedValues bool
+	// Capabilities are the capabilities to render the charts with, such as
+	// the ones of a capabilities file, instead of the default ones.
+	Capabilities *chartutil.Capabilities
 }
 
 // LintResult is the result of Lint
@@ -44,6 +65,18 @@ type LintResult struct {
 	TotalChartsLinted int
 	Messages          []support.Message
 	Errors            []error
+	// ValuesSets are the results of linting with each values file of the
+	// values directory. Messages and Errors hold the ones of all the sets,
+	// without duplicates.
+	ValuesSets []ValuesSetResult
+}
+
+// ValuesSetResult is the result of linting a chart with a values file.
+type ValuesSetResult struct {
+	// Name is the path of the values file.
+	Name     string
+	Messages []support.Message
+	Errors   []error
 }
 
 // NewLint creates a new Lint object with the given configuration.
@@ -58,8 +91,21 @@ func (l *Lint) Run(paths []string, vals map[string]interface{}) *LintResult {
 		lowestTolerance = support.WarningSev
 	}
 	result := &LintResult{}
+	var config *support.Config
+	if l.ConfigFile != "" {
+		var err error
+		if config, err = support.LoadConfig(l.ConfigFile); err != nil {
+			result.Errors = append(result.Errors, err)
+			return result
+		}
+	}
 	for _, path := range paths {
-		linter, err := lintChart(path, vals, l.Namespace, l.Strict)
+		if l.ValuesDir != "" {
+			l.runValuesSets(result, path, vals, config, lowestTolerance)
+			continue
+		}
+
+		linter, err := lintChart(path, vals, l.Namespace, l.Strict, l.UnusedValues, l.Analyzers, config, l.Capabilities)
 		if err != nil {
 			result.Errors = append(result.Errors, err)
 			continue
@@ -76,6 +122,96 @@ func (l *Lint) Run(paths []string, vals map[string]interface{}) *LintResult {
 	return result
 }
 
+// runValuesSets lints the chart at path once with each values file of the
+// values directory.
+func (l *Lint) runValuesSets(result *LintResult, path string, vals map[string]interface{}, config *support.Config, lowestTolerance int) {
+	files, err := valuesFiles(path, l.ValuesDir)
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+		return
+	}
+
+	seen := make(map[string]bool)
+	linted := false
+	for _, file := range files {
+		set := ValuesSetResult{Name: file}
+		setVals, err := valuesSet(file, vals)
+		if err == nil {
+			var linter support.Linter
+			linter, err = lintChart(path, setVals, l.Namespace, l.Strict, l.UnusedValues, l.Analyzers, config, l.Capabilities)
+			set.Messages = linter.Messages
+		}
+		if err != nil {
+			set.Errors = append(set.Errors, err)
+			result.Errors = append(result.Errors, errors.Wrapf(err, "values %s", file))
+			result.ValuesSets = append(result.ValuesSets, set)
+			continue
+		}
+
+		linted = true
+		for _, msg := range set.Messages {
+			if msg.Severity >= lowestTolerance {
+				set.Errors = append(set.Errors, msg.Err)
+			}
+			// Messages that do not depend on values, such as those on
+			// Chart.yaml, are reported once.
+			if seen[msg.Error()] {
+				continue
+			}
+			seen[msg.Error()] = true
+			result.Messages = append(result.Messages, msg)
+			if msg.Severity >= lowestTolerance {
+				result.Errors = append(result.Errors, msg.Err)
+			}
+		}
+		result.ValuesSets = append(result.ValuesSets, set)
+	}
+	if linted {
+		result.TotalChartsLinted++
+	}
+}
+
+// valuesFiles returns the values files in dir, sorted. A relative dir is
+// looked up in the chart directory first.
+func valuesFiles(chartPath, dir string) ([]string, error) {
+	if !filepath.IsAbs(dir) {
+		if fi, err := os.Stat(filepath.Join(chartPath, dir)); err == nil && fi.IsDir() {
+			dir = filepath.Join(chartPath, dir)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read values directory")
+	}
+	var files []string
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no values files found in %s", dir)
+	}
+	return files, nil
+}
+
+// valuesSet returns the values of file merged under vals.
+func valuesSet(file string, vals map[string]interface{}) (map[string]interface{}, error) {
+	fileVals, err := chartutil.ReadValuesFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", file)
+	}
+	copied, err := copystructure.Copy(vals)
+	if err != nil {
+		return nil, err
+	}
+	base, _ := copied.(map[string]interface{})
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	return chartutil.CoalesceTables(base, fileVals), nil
+}
+
 // HasWaringsOrErrors checks is LintResult has any warnings or errors
 func HasWarningsOrErrors(result *LintResult) bool {
 	for _, msg := range result.Messages {
@@ -86,7 +222,7 @@ func HasWarningsOrErrors(result *LintResult) bool {
 	return false
 }
 
-func lintChart(path string, vals map[string]interface{}, namespace string, strict bool) (support.Linter, error) {
+func lintChart(path string, vals map[string]interface{}, namespace string, strict, unusedValues bool, analyzers []support.Analyzer, config *support.Config, caps *chartutil.Capabilities) (support.Linter, error) {
 	var chartPath string
 	linter := support.Linter{}
 
@@ -125,5 +261,20 @@ func lintChart(path string, vals map[string]interface{}, namespace string, stric
 		return linter, errors.Wrap(err, "unable to check Chart.yaml file in chart")
 	}
 
-	return lint.All(chartPath, vals, namespace, strict), nil
+	// The given configuration takes precedence over the one of the chart
+	chartConfigFile := filepath.Join(chartPath, support.ConfigFileName)
+	if _, err := os.Stat(chartConfigFile); err == nil {
+		chartConfig, err := support.LoadConfig(chartConfigFile)
+		if err != nil {
+			return linter, err
+		}
+		config = config.Merge(chartConfig)
+	}
+
+	linter = lint.AllWithCapabilities(chartPath, vals, namespace, strict, caps, analyzers...)
+	if unusedValues {
+		rules.UnusedValues(&linter, vals, namespace)
+	}
+	config.Apply(&linter)
+	return linter, nil
 }
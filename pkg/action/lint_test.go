@@ -78,7 +78,7 @@ func TestLintChart(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := lintChart(tt.chartPath, map[string]interface{}{}, namespace, strict)
+			_, err := lintChart(tt.chartPath, map[string]interface{}{}, namespace, strict, false, nil, nil, nil)
 			switch {
 			case err != nil && !tt.err:
 				t.Errorf("%s", err)
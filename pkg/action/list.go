@@ -17,13 +17,22 @@ limitations under the License.
 package action
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"path"
 	"regexp"
+	"sort"
 
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
+	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/release"
-	"github.com/open-hand/helm/pkg/releaseutil"
+	"github.com/open-hand/helm/pkg/storage"
+	"github.com/open-hand/helm/pkg/storage/driver"
 )
 
 // ListStates represents zero or more status codes that a list item may have set
@@ -80,6 +89,26 @@ func (s ListStates) FromName(str string) ListStates {
 	return ListUnknown
 }
 
+// statuses returns the names of the statuses enabled in s.
+func (s ListStates) statuses() []string {
+	var names []string
+	for _, status := range []release.Status{
+		release.StatusDeployed,
+		release.StatusUninstalled,
+		release.StatusSuperseded,
+		release.StatusFailed,
+		release.StatusUninstalling,
+		release.StatusPendingInstall,
+		release.StatusPendingUpgrade,
+		release.StatusPendingRollback,
+	} {
+		if s&s.FromName(status.String()) != 0 {
+			names = append(names, status.String())
+		}
+	}
+	return names
+}
+
 // ListAll is a convenience for enabling all list filters
 const ListAll = ListDeployed | ListUninstalled | ListUninstalling | ListPendingInstall | ListPendingRollback | ListPendingUpgrade | ListSuperseded | ListFailed
 
@@ -95,6 +124,39 @@ const (
 	ByDateDesc
 )
 
+// SortKey is a release attribute that List can order results by.
+type SortKey string
+
+const (
+	// SortByName orders releases by name
+	SortByName SortKey = "name"
+	// SortByUpdated orders releases by the time they were last deployed
+	SortByUpdated SortKey = "updated"
+	// SortByChart orders releases by chart name and version
+	SortByChart SortKey = "chart"
+	// SortByStatus orders releases by status
+	SortByStatus SortKey = "status"
+)
+
+// SortKeys lists the valid values of List.SortBy.
+var SortKeys = []SortKey{SortByName, SortByUpdated, SortByChart, SortByStatus}
+
+// ListFields lists the optional release fields that can be selected with
+// List.Fields. The name, namespace, revision and info (except the notes) of a
+// release are always returned.
+var ListFields = []string{"chart", "config", "manifest", "hooks", "notes", "labels"}
+
+// ListPage is a page of releases returned by List.RunPage.
+type ListPage struct {
+	Releases []*release.Release
+	// Continue is the token to set as List.Continue to fetch the next page.
+	// It is empty on the last page.
+	Continue string
+	// Skipped lists the namespaces that were left out of an AllNamespaces
+	// listing because the caller is not allowed to list releases in them.
+	Skipped []*storage.NamespaceError
+}
+
 // List is the action for listing releases.
 //
 // It provides, for example, the implementation of 'helm list'.
@@ -106,8 +168,13 @@ type List struct {
 
 	// All ignores the limit/offset
 	All bool
-	// AllNamespaces searches across namespaces
+	// AllNamespaces searches across namespaces. If the storage driver keeps
+	// releases in namespaced objects, the namespaces are queried in parallel
+	// and those the caller cannot list releases in are skipped.
 	AllNamespaces bool
+	// Parallelism is the number of namespaces queried at a time when listing
+	// across namespaces. Values of 0 or less use a default.
+	Parallelism int
 	// Sort indicates the sort to use
 	//
 	// see pkg/releaseutil for several useful sorters
@@ -115,6 +182,15 @@ type List struct {
 	// Overrides the default lexicographic sorting
 	ByDate      bool
 	SortReverse bool
+	// SortBy orders results by the given key, ascending unless SortReverse
+	// is set. It takes precedence over Sort and ByDate.
+	SortBy SortKey
+	// Continue is a token returned by RunPage. If set, listing resumes
+	// after the last release of the page that returned it.
+	Continue string
+	// Fields, if not nil, restricts the optional fields populated in the
+	// returned releases to those named. See ListFields.
+	Fields []string
 	// StateMask accepts a bitmask of states for items to show.
 	// The default is ListDeployed
 	StateMask ListStates
@@ -145,20 +221,91 @@ func NewList(cfg *Configuration) *List {
 
 // Run executes the list command, returning a set of matches.
 func (l *List) Run() ([]*release.Release, error) {
+	page, err := l.RunPage()
+	if err != nil {
+		return nil, err
+	}
+	return page.Releases, nil
+}
+
+// RunPage executes the list command, returning a page of at most Limit
+// matches and the token to fetch the next one.
+func (l *List) RunPage() (*ListPage, error) {
 	if err := l.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
 
+	key, desc := l.sortOrder()
+	if !validSortKey(key) {
+		return nil, errors.Errorf("invalid sort key %q, must be one of %v", key, SortKeys)
+	}
+	if err := validateListFields(l.Fields); err != nil {
+		return nil, err
+	}
+	var after *listCursor
+	if l.Continue != "" {
+		var err error
+		if after, err = parseListCursor(l.Continue); err != nil {
+			return nil, err
+		}
+		if after.SortBy != key || after.Reverse != desc {
+			return nil, errors.New("continue token was issued for a different sort order")
+		}
+	}
+
+	var skipped []*storage.NamespaceError
+	results, ok, err := l.listLatest(key, desc, after)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if results, skipped, err = l.listAll(key, desc, after); err != nil {
+			return nil, err
+		}
+	}
+	if results == nil {
+		return &ListPage{Skipped: skipped}, nil
+	}
+
+	// Guard on offset
+	if l.Offset >= len(results) {
+		return &ListPage{Releases: []*release.Release{}, Skipped: skipped}, nil
+	}
+
+	// Calculate the limit and offset, and then truncate results if necessary.
+	limit := len(results)
+	if l.Limit > 0 && l.Limit < limit {
+		limit = l.Limit
+	}
+	last := l.Offset + limit
+	if l := len(results); l < last {
+		last = l
+	}
+	page := &ListPage{Releases: results[l.Offset:last], Skipped: skipped}
+	if last < len(results) {
+		page.Continue = newListCursor(key, desc, results[last-1]).String()
+	}
+	if l.Fields != nil {
+		for i, rls := range page.Releases {
+			page.Releases[i] = maskRelease(rls, l.Fields)
+		}
+	}
+	return page, nil
+}
+
+// listAll loads every release from storage and filters, sorts and skips
+// past the cursor in memory.
+func (l *List) listAll(key SortKey, desc bool, after *listCursor) ([]*release.Release, []*storage.NamespaceError, error) {
 	var filter *regexp.Regexp
 	if l.Filter != "" {
 		var err error
 		filter, err = regexp.Compile(l.Filter)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	results, err := l.cfg.Releases.List(func(rel *release.Release) bool {
+	results, skipped, err := l.listReleases(func(rel *release.Release) bool {
 		// Skip anything that doesn't match the filter.
 		if filter != nil && !filter.MatchString(rel.Name) {
 			return false
@@ -168,11 +315,11 @@ func (l *List) Run() ([]*release.Release, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, skipped, err
 	}
 
 	if results == nil {
-		return results, nil
+		return results, skipped, nil
 	}
 
 	// by definition, superseded releases are never shown if
@@ -189,55 +336,251 @@ func (l *List) Run() ([]*release.Release, error) {
 	// Skip anything that doesn't match the selector
 	selectorObj, err := labels.Parse(l.Selector)
 	if err != nil {
-		return nil, err
+		return nil, skipped, err
 	}
 	results = l.filterSelector(results, selectorObj)
 
 	// Unfortunately, we have to sort before truncating, which can incur substantial overhead
-	l.sort(results)
+	sortReleases(results, key, desc)
 
-	// Guard on offset
-	if l.Offset >= len(results) {
-		return []*release.Release{}, nil
+	if after != nil {
+		i := sort.Search(len(results), func(i int) bool {
+			return after.less(newListCursor(key, desc, results[i]))
+		})
+		results = results[i:]
 	}
+	return results, skipped, nil
+}
 
-	// Calculate the limit and offset, and then truncate results if necessary.
-	limit := len(results)
-	if l.Limit > 0 && l.Limit < limit {
-		limit = l.Limit
+// listReleases returns every release matching filter. When listing across
+// namespaces with a driver that stores releases per namespace, each namespace
+// is listed separately so that namespaces the caller has no access to can be
+// skipped. If the namespaces themselves cannot be listed, a single listing
+// across all namespaces is attempted instead.
+func (l *List) listReleases(filter func(*release.Release) bool) ([]*release.Release, []*storage.NamespaceError, error) {
+	if !l.AllNamespaces || l.cfg.NamespaceStorage == nil {
+		results, err := l.cfg.Releases.List(filter)
+		return results, nil, err
 	}
-	last := l.Offset + limit
-	if l := len(results); l < last {
-		last = l
+	namespaces, err := l.namespaces()
+	if err != nil {
+		l.cfg.Log("unable to list namespaces, listing releases across all namespaces at once: %s", err)
+		results, err := l.cfg.Releases.List(filter)
+		return results, nil, err
 	}
-	results = results[l.Offset:last]
+	return storage.ListNamespaces(namespaces, l.cfg.NamespaceStorage, l.Parallelism, filter)
+}
 
-	return results, err
+func (l *List) namespaces() ([]string, error) {
+	clientset, err := l.cfg.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	list, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
 }
 
-// sort is an in-place sort where order is based on the value of a.Sort
-func (l *List) sort(rels []*release.Release) {
-	if l.SortReverse {
-		l.Sort = ByNameDesc
+// listLatest pushes the listing down into the storage driver if it supports
+// it and nothing is requested that only the in-memory listing handles. ok
+// reports whether the driver was used. One more release than the page holds
+// is fetched so that RunPage can tell whether another page follows.
+func (l *List) listLatest(key SortKey, desc bool, after *listCursor) (results []*release.Release, ok bool, err error) {
+	if key != SortByName || l.Filter != "" || l.Selector != "" || l.StateMask == ListSuperseded {
+		return nil, false, nil
+	}
+	opts := driver.ListOptions{
+		Statuses:   l.StateMask.statuses(),
+		Descending: desc,
 	}
+	if l.Limit > 0 {
+		opts.Limit = l.Offset + l.Limit + 1
+	}
+	if after != nil {
+		opts.AfterName, opts.AfterNamespace = after.Name, after.Namespace
+	}
+	results, ok, err = l.cfg.Releases.ListLatest(opts)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	return l.filterStateMask(results), true, nil
+}
 
+// sortOrder resolves SortBy, Sort, ByDate and SortReverse into a sort key
+// and direction.
+func (l *List) sortOrder() (key SortKey, desc bool) {
+	if l.SortBy != "" {
+		return l.SortBy, l.SortReverse
+	}
+	// ByDateDesc has always listed the oldest release first
 	if l.ByDate {
-		l.Sort = ByDateDesc
-		if l.SortReverse {
-			l.Sort = ByDateAsc
-		}
+		return SortByUpdated, l.SortReverse
+	}
+	if l.SortReverse {
+		return SortByName, true
 	}
-
 	switch l.Sort {
 	case ByDateDesc:
-		releaseutil.SortByDate(rels)
+		return SortByUpdated, false
 	case ByDateAsc:
-		releaseutil.Reverse(rels, releaseutil.SortByDate)
+		return SortByUpdated, true
 	case ByNameDesc:
-		releaseutil.Reverse(rels, releaseutil.SortByName)
-	default:
-		releaseutil.SortByName(rels)
+		return SortByName, true
+	}
+	return SortByName, false
+}
+
+func validSortKey(key SortKey) bool {
+	for _, k := range SortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sortReleases is an in-place sort by key. Releases with equal keys are
+// ordered by name and then namespace, so that the order is total and a
+// listing can be resumed from a cursor.
+func sortReleases(rels []*release.Release, key SortKey, desc bool) {
+	cursors := make(map[*release.Release]*listCursor, len(rels))
+	for _, rls := range rels {
+		cursors[rls] = newListCursor(key, desc, rls)
+	}
+	sort.SliceStable(rels, func(i, j int) bool {
+		return cursors[rels[i]].less(cursors[rels[j]])
+	})
+}
+
+// listCursor is the position of a release in a sorted listing. Its encoded
+// form is the continue token returned by RunPage.
+type listCursor struct {
+	SortBy    SortKey `json:"s"`
+	Reverse   bool    `json:"r,omitempty"`
+	Value     string  `json:"v,omitempty"`
+	Name      string  `json:"n"`
+	Namespace string  `json:"ns,omitempty"`
+}
+
+func newListCursor(key SortKey, desc bool, rls *release.Release) *listCursor {
+	return &listCursor{
+		SortBy:    key,
+		Reverse:   desc,
+		Value:     sortValue(key, rls),
+		Name:      rls.Name,
+		Namespace: rls.Namespace,
+	}
+}
+
+func parseListCursor(token string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid continue token")
+	}
+	c := &listCursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, errors.Wrap(err, "invalid continue token")
+	}
+	return c, nil
+}
+
+// String encodes the cursor as a continue token.
+func (c *listCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// less reports whether c sorts before o.
+func (c *listCursor) less(o *listCursor) bool {
+	a, b := c, o
+	if c.Reverse {
+		a, b = o, c
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.Namespace < b.Namespace
+}
+
+// sortValue returns the value rls is ordered by for key. Times are formatted
+// with a fixed width so that they order correctly as strings.
+func sortValue(key SortKey, rls *release.Release) string {
+	switch key {
+	case SortByUpdated:
+		if rls.Info != nil {
+			return rls.Info.LastDeployed.UTC().Format("2006-01-02T15:04:05.000000000")
+		}
+	case SortByChart:
+		if rls.Chart != nil && rls.Chart.Metadata != nil {
+			return fmt.Sprintf("%s-%s", rls.Chart.Name(), rls.Chart.Metadata.Version)
+		}
+	case SortByStatus:
+		if rls.Info != nil {
+			return rls.Info.Status.String()
+		}
+	}
+	return ""
+}
+
+func validateListFields(fields []string) error {
+	for _, f := range fields {
+		valid := false
+		for _, v := range ListFields {
+			if f == v {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return errors.Errorf("invalid field %q, must be one of %v", f, ListFields)
+		}
+	}
+	return nil
+}
+
+// maskRelease returns a shallow copy of rls populating only the optional
+// fields named in fields. Only the metadata of the chart is kept.
+func maskRelease(rls *release.Release, fields []string) *release.Release {
+	m := &release.Release{
+		Name:      rls.Name,
+		Namespace: rls.Namespace,
+		Version:   rls.Version,
+	}
+	if rls.Info != nil {
+		info := *rls.Info
+		info.Notes = ""
+		m.Info = &info
+	}
+	for _, f := range fields {
+		switch f {
+		case "chart":
+			if rls.Chart != nil {
+				m.Chart = &chart.Chart{Metadata: rls.Chart.Metadata}
+			}
+		case "config":
+			m.Config = rls.Config
+		case "manifest":
+			m.Manifest = rls.Manifest
+		case "hooks":
+			m.Hooks = rls.Hooks
+		case "notes":
+			if m.Info != nil {
+				m.Info.Notes = rls.Info.Notes
+			}
+		case "labels":
+			m.Labels = rls.Labels
+		}
 	}
+	return m
 }
 
 // filterLatestReleases returns a list scrubbed of old releases.
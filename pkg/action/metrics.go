@@ -0,0 +1,52 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"time"
+
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// Metrics receives measurements of the actions. It is meant to be
+// implemented by embedders that export them, e.g. as Prometheus counters and
+// histograms, so that they can follow the outcome and the latency of Helm
+// operations without wrapping every call. It also receives the measurements
+// of the release storage created by Init. Implementations must be safe for
+// concurrent use.
+type Metrics interface {
+	driver.Metrics
+	// ObserveAction records an action: one of "install", "upgrade",
+	// "rollback" or "uninstall", how long it took and the error it returned,
+	// if any.
+	ObserveAction(action string, d time.Duration, err error)
+	// ObserveHook records the run of the hooks of a release for an event,
+	// e.g. "pre-install", how long it took and the error it returned, if
+	// any. Events without hooks are not recorded.
+	ObserveHook(event string, d time.Duration, err error)
+	// ObserveChartDownload records the download of a chart, how long it took
+	// and the error it returned, if any. Charts given as paths on disk are
+	// not recorded.
+	ObserveChartDownload(chart string, d time.Duration, err error)
+}
+
+// observeAction reports an action that began at start to m, if set.
+func observeAction(m Metrics, action string, start time.Time, err error) {
+	if m != nil {
+		m.ObserveAction(action, time.Since(start), err)
+	}
+}
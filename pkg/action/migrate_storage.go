@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+)
+
+// MigrateStorage is the action for moving the release records between the
+// namespaces of their releases and the StorageNamespace of the
+// configuration.
+//
+// It provides the implementation of 'helm release migrate-storage'.
+type MigrateStorage struct {
+	cfg *Configuration
+
+	// Namespaces are the namespaces of the releases whose records are moved.
+	Namespaces []string
+	// Revert moves the records back from the storage namespace to the
+	// namespaces of their releases.
+	Revert bool
+	// DryRun returns the records that would be moved without moving them.
+	DryRun bool
+}
+
+// NewMigrateStorage creates a new MigrateStorage object with the given
+// configuration.
+func NewMigrateStorage(cfg *Configuration) *MigrateStorage {
+	return &MigrateStorage{cfg: cfg}
+}
+
+// Run moves the release records of the namespaces, returning the moved
+// records.
+func (m *MigrateStorage) Run() ([]*release.Release, error) {
+	if m.cfg.StorageNamespace == "" || m.cfg.ReleaseNamespaceStorage == nil || m.cfg.NamespaceStorage == nil {
+		return nil, errors.New("no storage namespace is configured: set $HELM_DRIVER_NAMESPACE with the secret or configmap driver")
+	}
+	if len(m.Namespaces) == 0 {
+		return nil, errors.New("no namespace to migrate")
+	}
+	if !m.DryRun {
+		if err := m.cfg.checkWritable("migrate storage"); err != nil {
+			return nil, err
+		}
+	}
+	var moved []*release.Release
+	for _, ns := range m.Namespaces {
+		from, to := m.cfg.ReleaseNamespaceStorage(ns), m.cfg.NamespaceStorage(ns)
+		if m.Revert {
+			from, to = to, from
+		}
+		inNamespace := func(rls *release.Release) bool { return rls.Namespace == ns }
+		if m.DryRun {
+			releases, err := from.List(inNamespace)
+			if err != nil {
+				return moved, errors.Wrapf(err, "unable to list the releases of namespace %q", ns)
+			}
+			moved = append(moved, releases...)
+			continue
+		}
+		releases, err := storage.MoveReleases(from, to, inNamespace)
+		if err != nil {
+			return moved, errors.Wrapf(err, "unable to migrate the releases of namespace %q", ns)
+		}
+		moved = append(moved, releases...)
+	}
+	return moved, nil
+}
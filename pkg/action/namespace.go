@@ -0,0 +1,210 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// namespaceCreatedByAnnotation records the release that created a namespace,
+// so that it is only deleted on the uninstallation of that release.
+const namespaceCreatedByAnnotation = "helm.sh/created-by-release"
+
+// NamespaceExistsPolicy is how the creation of the namespace of a release
+// handles an existing namespace.
+type NamespaceExistsPolicy string
+
+const (
+	// NamespaceExistsUse uses the existing namespace as is.
+	NamespaceExistsUse NamespaceExistsPolicy = "use"
+	// NamespaceExistsRequireLabels uses the existing namespace only if it has
+	// the labels the namespace would be created with, such as pod security
+	// labels.
+	NamespaceExistsRequireLabels NamespaceExistsPolicy = "require-labels"
+	// NamespaceExistsFail fails if the namespace exists.
+	NamespaceExistsFail NamespaceExistsPolicy = "fail"
+)
+
+// ParseNamespaceExistsPolicy returns the policy with the given name. The
+// empty string is NamespaceExistsUse.
+func ParseNamespaceExistsPolicy(s string) (NamespaceExistsPolicy, error) {
+	switch p := NamespaceExistsPolicy(s); p {
+	case "":
+		return NamespaceExistsUse, nil
+	case NamespaceExistsUse, NamespaceExistsRequireLabels, NamespaceExistsFail:
+		return p, nil
+	}
+	return "", errors.Errorf("invalid namespace policy %q, must be one of: use, require-labels, fail", s)
+}
+
+// NamespaceOptions configure the creation of the namespace of a release.
+type NamespaceOptions struct {
+	// Labels are set on the created namespace, e.g. the
+	// "pod-security.kubernetes.io/enforce" label.
+	Labels map[string]string
+	// Annotations are set on the created namespace.
+	Annotations map[string]string
+	// ExistsPolicy handles an existing namespace.
+	ExistsPolicy NamespaceExistsPolicy
+}
+
+// createNamespace creates the namespace of the release rel, or checks the
+// existing one against the policy of opts.
+func (cfg *Configuration) createNamespace(namespace, rel string, opts NamespaceOptions) error {
+	labels := map[string]string{
+		"name": namespace,
+		"helm": "helm3",
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	annotations := map[string]string{namespaceCreatedByAnnotation: rel}
+	for k, v := range opts.Annotations {
+		annotations[k] = v
+	}
+	ns := &v1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	buf, err := yaml.Marshal(ns)
+	if err != nil {
+		return err
+	}
+	resourceList, err := cfg.KubeClient.Build(bytes.NewBuffer(buf), true)
+	if err != nil {
+		return err
+	}
+	_, err = cfg.KubeClient.Create(resourceList)
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	switch opts.ExistsPolicy {
+	case NamespaceExistsFail:
+		return errors.Errorf("namespace %q already exists", namespace)
+	case NamespaceExistsRequireLabels:
+		if len(opts.Labels) == 0 {
+			return nil
+		}
+		clientset, err := cfg.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+		existing, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "unable to get namespace %q", namespace)
+		}
+		var missing []string
+		for k, v := range opts.Labels {
+			if existing.Labels[k] != v {
+				missing = append(missing, k+"="+v)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return errors.Errorf("namespace %q already exists without the required labels: %s", namespace, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// deleteCreatedNamespace deletes the namespace if the release rel created it
+// and neither other releases nor other resources are left in it. It returns
+// why the namespace was kept if it was created by rel.
+func (cfg *Configuration) deleteCreatedNamespace(namespace, rel string) (string, error) {
+	clientset, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return "", err
+	}
+	ns, err := clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get namespace %q", namespace)
+	}
+	if ns.Annotations[namespaceCreatedByAnnotation] != rel {
+		cfg.Log("namespace %s was not created by release %s, keeping it", namespace, rel)
+		return "", nil
+	}
+
+	others, err := cfg.namespaceReleases(namespace, rel)
+	if err != nil {
+		return "", err
+	}
+	if len(others) > 0 {
+		return fmt.Sprintf("Namespace %q was kept since it holds other releases: %s", namespace, strings.Join(others, ", ")), nil
+	}
+	kubeClient, ok := cfg.KubeClient.(kube.InterfaceNamespaces)
+	if !ok {
+		return fmt.Sprintf("Namespace %q was kept since the resources left in it cannot be checked", namespace), nil
+	}
+	remaining, err := kubeClient.RemainingResources(namespace)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to check the resources left in namespace %q", namespace)
+	}
+	if len(remaining) > 0 {
+		return fmt.Sprintf("Namespace %q was kept since resources are left in it: %s", namespace, strings.Join(remaining, ", ")), nil
+	}
+
+	cfg.Log("deleting namespace %s created by release %s", namespace, rel)
+	err = clientset.CoreV1().Namespaces().Delete(context.Background(), namespace, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "unable to delete namespace %q", namespace)
+	}
+	return "", nil
+}
+
+// namespaceReleases returns the names of the releases other than rel that
+// are installed in namespace.
+func (cfg *Configuration) namespaceReleases(namespace, rel string) ([]string, error) {
+	rels, err := cfg.Releases.ListReleases()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the releases")
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range rels {
+		if r.Name == rel || r.Namespace != namespace || seen[r.Name] || r.Info == nil || r.Info.Status == release.StatusUninstalled {
+			continue
+		}
+		seen[r.Name] = true
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,232 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// NamespacePolicyMode is what is done with the rendered resources targeting
+// other namespaces than the namespace of their release.
+type NamespacePolicyMode string
+
+const (
+	// NamespacePolicyAllow applies the resources targeting other namespaces.
+	NamespacePolicyAllow NamespacePolicyMode = "allow"
+	// NamespacePolicyWarn applies the resources targeting other namespaces
+	// with a warning.
+	NamespacePolicyWarn NamespacePolicyMode = "warn"
+	// NamespacePolicyBlock rejects the releases with resources targeting
+	// other namespaces.
+	NamespacePolicyBlock NamespacePolicyMode = "block"
+)
+
+// NamespacePolicyModes are the valid namespace policy modes.
+var NamespacePolicyModes = []NamespacePolicyMode{NamespacePolicyAllow, NamespacePolicyWarn, NamespacePolicyBlock}
+
+// ParseNamespacePolicyMode parses a namespace policy mode, NamespacePolicyAllow
+// if empty.
+func ParseNamespacePolicyMode(s string) (NamespacePolicyMode, error) {
+	if s == "" {
+		return NamespacePolicyAllow, nil
+	}
+	for _, m := range NamespacePolicyModes {
+		if string(m) == s {
+			return m, nil
+		}
+	}
+	return "", errors.Errorf("invalid namespace policy %q, must be one of %s", s, joinNamespacePolicyModes())
+}
+
+func joinNamespacePolicyModes() string {
+	names := make([]string, len(NamespacePolicyModes))
+	for i, m := range NamespacePolicyModes {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
+
+// NamespacePolicy controls the rendered resources, including the resources
+// of the hooks, targeting other namespaces than the namespace of their
+// release, so that the charts installed by the tenants of a cluster cannot
+// write into the namespaces of the others. It controls the cluster-scoped
+// resources the same way, by their kind.
+type NamespacePolicy struct {
+	// Mode is what is done with the resources targeting other namespaces,
+	// and with the cluster-scoped resources of the kinds not allowed,
+	// NamespacePolicyAllow if empty.
+	Mode NamespacePolicyMode
+	// AllowedNamespaces are the other namespaces the resources may target
+	// whatever the mode, as path.Match patterns such as "shared-*".
+	AllowedNamespaces []string
+	// DeniedClusterKinds are the kinds of the cluster-scoped resources that
+	// are not allowed, as path.Match patterns such as "*WebhookConfiguration".
+	DeniedClusterKinds []string
+	// AllowedClusterKinds, when set, are the only kinds of the
+	// cluster-scoped resources that are allowed, as path.Match patterns. The
+	// resources whose scope the Kubernetes client cannot tell are not
+	// checked against them.
+	AllowedClusterKinds []string
+}
+
+// CrossNamespaceError is returned by the actions whose rendered resources
+// target other namespaces than the namespace of the release, or are
+// cluster-scoped resources of kinds not allowed, while the NamespacePolicy
+// of the configuration blocks them.
+type CrossNamespaceError struct {
+	// Namespace is the namespace of the release.
+	Namespace string
+	// Resources are the resources targeting other namespaces.
+	Resources []release.ResourceIdentity
+	// ClusterResources are the cluster-scoped resources of kinds not
+	// allowed.
+	ClusterResources []release.ResourceIdentity
+}
+
+func (e *CrossNamespaceError) Error() string {
+	var msgs []string
+	if len(e.Resources) > 0 {
+		msgs = append(msgs, fmt.Sprintf("resources target other namespaces than the release namespace %q: %s", e.Namespace, joinResourceIdentities(e.Resources)))
+	}
+	if len(e.ClusterResources) > 0 {
+		msgs = append(msgs, fmt.Sprintf("cluster-scoped resources of kinds not allowed: %s", joinResourceIdentities(e.ClusterResources)))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func joinResourceIdentities(resources []release.ResourceIdentity) string {
+	names := make([]string, len(resources))
+	for i, r := range resources {
+		names[i] = r.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// IsCrossNamespace reports whether err is, or wraps, a CrossNamespaceError.
+func IsCrossNamespace(err error) bool {
+	var crossNamespace *CrossNamespaceError
+	return errors.As(err, &crossNamespace)
+}
+
+// allowed reports whether the resources may target namespace.
+func (p NamespacePolicy) allowed(namespace string) bool {
+	return matchAny(p.AllowedNamespaces, namespace)
+}
+
+// kindAllowed reports whether the cluster-scoped resources may be of kind.
+// known tells whether the resource of kind is known to be cluster-scoped,
+// rather than only lacking a namespace.
+func (p NamespacePolicy) kindAllowed(kind string, known bool) bool {
+	if matchAny(p.DeniedClusterKinds, kind) {
+		return false
+	}
+	return !known || len(p.AllowedClusterKinds) == 0 || matchAny(p.AllowedClusterKinds, kind)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNamespacePolicy checks the rendered manifest and hooks of rel with the
+// NamespacePolicy of the configuration. It returns the resources targeting
+// other namespaces, and the cluster-scoped resources of kinds not allowed,
+// that the policy lets through with a warning, or a CrossNamespaceError if
+// it blocks them.
+func (cfg *Configuration) checkNamespacePolicy(rel *release.Release) ([]release.ResourceIdentity, error) {
+	p := cfg.NamespacePolicy
+	if p.Mode == "" || p.Mode == NamespacePolicyAllow {
+		return nil, nil
+	}
+	manifests := []string{rel.Manifest}
+	for _, h := range rel.Hooks {
+		manifests = append(manifests, h.Manifest)
+	}
+	checkKinds := len(p.DeniedClusterKinds) > 0 || len(p.AllowedClusterKinds) > 0
+	var foreign, cluster []release.ResourceIdentity
+	for _, m := range manifests {
+		resources, err := releaseutil.ParseManifest(m)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse the rendered resources")
+		}
+		var scopes map[resourceKey]bool
+		if checkKinds {
+			scopes = cfg.clusterScopes(m)
+		}
+		for _, r := range resources {
+			id := release.ResourceIdentity{APIVersion: r.APIVersion(), Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}
+			if r.Namespace != "" {
+				if r.Namespace != rel.Namespace && !p.allowed(r.Namespace) {
+					foreign = append(foreign, id)
+				}
+				continue
+			}
+			if !checkKinds {
+				continue
+			}
+			clusterScoped, known := scopes[resourceKey{Group: r.Group, Kind: r.Kind, Name: r.Name}]
+			if known && !clusterScoped {
+				continue
+			}
+			if !p.kindAllowed(r.Kind, known) {
+				cluster = append(cluster, id)
+			}
+		}
+	}
+	if len(foreign) == 0 && len(cluster) == 0 {
+		return nil, nil
+	}
+	if p.Mode == NamespacePolicyBlock {
+		return nil, &CrossNamespaceError{Namespace: rel.Namespace, Resources: foreign, ClusterResources: cluster}
+	}
+	for _, r := range foreign {
+		cfg.Log("warning: %s targets another namespace than the release namespace %q", r, rel.Namespace)
+	}
+	for _, r := range cluster {
+		cfg.Log("warning: %s is a cluster-scoped resource of a kind not allowed", r)
+	}
+	return append(foreign, cluster...), nil
+}
+
+// clusterScopes tells, for the resources of manifest the Kubernetes client
+// knows, whether they are cluster-scoped. The resources are keyed without
+// their namespace.
+func (cfg *Configuration) clusterScopes(manifest string) map[resourceKey]bool {
+	infos, err := cfg.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		cfg.Log("unable to tell the scope of the rendered resources: %s", err)
+		return nil
+	}
+	scopes := make(map[resourceKey]bool, len(infos))
+	for _, info := range infos {
+		gvk := info.Mapping.GroupVersionKind
+		scopes[resourceKey{Group: gvk.Group, Kind: gvk.Kind, Name: info.Name}] = !info.Namespaced()
+	}
+	return scopes
+}
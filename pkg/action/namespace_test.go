@@ -0,0 +1,59 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestNamespaceReleases(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	for _, rel := range []*release.Release{
+		namedReleaseStub("other", release.StatusDeployed),
+		namedReleaseStub("web", release.StatusDeployed),
+		namedReleaseStub("api", release.StatusDeployed),
+		namedReleaseStub("db", release.StatusFailed),
+		namedReleaseStub("old", release.StatusUninstalled),
+	} {
+		rel.Namespace = "shop"
+		if rel.Name == "other" {
+			rel.Namespace = "elsewhere"
+		}
+		if err := cfg.Releases.Create(rel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names, err := cfg.namespaceReleases("shop", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"api", "db"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected releases %v, got %v", want, names)
+	}
+
+	names, err = cfg.namespaceReleases("empty", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("expected no releases, got %v", names)
+	}
+}
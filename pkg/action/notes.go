@@ -0,0 +1,174 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/engine"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// notesPostProcessAnnotation is the annotation of Chart.yaml opting the
+// notes of a chart into post-processing.
+const notesPostProcessAnnotation = "helm.sh/notes-post-process"
+
+// notesHook is the result of a hook given to the post-processing of notes.
+type notesHook struct {
+	Events      []string
+	Phase       release.HookPhase
+	StartedAt   time.Time
+	CompletedAt time.Time
+}
+
+// postProcessNotes renders the notes of rel a second time, once the hooks
+// of the release have run, if the chart opts in with the annotation
+// "helm.sh/notes-post-process: true". The notes are rendered as a template
+// with the delimiters "[[" and "]]" so that they are left alone by the
+// first rendering, and given:
+//
+//   - .Hooks, the results of the hooks of the release by name
+//   - .Resources, the resources of the manifest by "Kind/name", which hold
+//     the values generated while rendering, except the data of the Secrets
+//   - .Release, the name, namespace and revision of the release
+//
+// The functions are the ones of the chart templates, which cannot read the
+// environment.
+func postProcessNotes(rel *release.Release) (string, error) {
+	if rel.Chart == nil || rel.Chart.Metadata == nil || rel.Chart.Metadata.Annotations[notesPostProcessAnnotation] != "true" {
+		return rel.Info.Notes, nil
+	}
+
+	hooks := make(map[string]notesHook, len(rel.Hooks))
+	for _, h := range rel.Hooks {
+		events := make([]string, 0, len(h.Events))
+		for _, e := range h.Events {
+			events = append(events, e.String())
+		}
+		hooks[h.Name] = notesHook{
+			Events:      events,
+			Phase:       h.LastRun.Phase,
+			StartedAt:   h.LastRun.StartedAt.Time,
+			CompletedAt: h.LastRun.CompletedAt.Time,
+		}
+	}
+
+	resources := make(map[string]map[string]interface{})
+	for _, m := range releaseutil.SplitManifests(rel.Manifest) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(m), &obj); err != nil || obj == nil {
+			continue
+		}
+		kind, _ := obj["kind"].(string)
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		// The notes are shown and delivered to webhooks, they must not
+		// disclose secrets.
+		if kind == "Secret" {
+			delete(obj, "data")
+			delete(obj, "stringData")
+		}
+		resources[kind+"/"+name] = obj
+	}
+
+	t, err := template.New("NOTES.txt").Delims("[[", "]]").Funcs(engine.FuncMap()).Option("missingkey=zero").Parse(rel.Info.Notes)
+	if err != nil {
+		return rel.Info.Notes, errors.Wrap(err, "parsing the notes")
+	}
+	var b strings.Builder
+	err = t.Execute(&b, map[string]interface{}{
+		"Hooks":     hooks,
+		"Resources": resources,
+		"Release": map[string]interface{}{
+			"Name":      rel.Name,
+			"Namespace": rel.Namespace,
+			"Revision":  rel.Version,
+		},
+	})
+	if err != nil {
+		return rel.Info.Notes, errors.Wrap(err, "rendering the notes")
+	}
+	return b.String(), nil
+}
+
+// ReleaseNotes are the notes of a release in a machine-readable form, as
+// delivered by DeliverNotes.
+type ReleaseNotes struct {
+	Release      string         `json:"release"`
+	Namespace    string         `json:"namespace"`
+	Revision     int            `json:"revision"`
+	Status       release.Status `json:"status"`
+	Chart        string         `json:"chart,omitempty"`
+	ChartVersion string         `json:"chartVersion,omitempty"`
+	AppVersion   string         `json:"appVersion,omitempty"`
+	Notes        string         `json:"notes"`
+}
+
+// NewReleaseNotes returns the notes of rel.
+func NewReleaseNotes(rel *release.Release) *ReleaseNotes {
+	n := &ReleaseNotes{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+	}
+	if rel.Info != nil {
+		n.Status = rel.Info.Status
+		n.Notes = rel.Info.Notes
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		n.Chart = rel.Chart.Metadata.Name
+		n.ChartVersion = rel.Chart.Metadata.Version
+		n.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return n
+}
+
+// notesClient is the HTTP client delivering notes to webhooks.
+var notesClient = &http.Client{Timeout: 30 * time.Second}
+
+// DeliverNotes writes the notes of rel as JSON to target: a webhook when
+// target is an http or https URL, to which they are posted, or a file
+// otherwise.
+func DeliverNotes(rel *release.Release, target string) error {
+	data, err := json.Marshal(NewReleaseNotes(rel))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return errors.Wrapf(ioutil.WriteFile(target, append(data, '\n'), 0644), "writing the notes to %s", target)
+	}
+
+	resp, err := notesClient.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "posting the notes to %s", target)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("posting the notes to %s: %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestPostProcessNotes(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+data:
+  password: czNjcjN0
+stringData:
+  user: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+data:
+  host: db.default
+`
+	newRelease := func(notes string) *release.Release {
+		return &release.Release{
+			Name:     "web",
+			Version:  2,
+			Manifest: manifest,
+			Info:     &release.Info{Notes: notes},
+			Chart: &chart.Chart{Metadata: &chart.Metadata{
+				Name:        "web",
+				Annotations: map[string]string{notesPostProcessAnnotation: "true"},
+			}},
+		}
+	}
+
+	notes, err := postProcessNotes(newRelease(`[[ .Release.Name ]] [[ .Release.Revision ]] [[ index .Resources "ConfigMap/db" "data" "host" | upper ]] [[ index .Resources "Secret/db" | keys | sortAlpha | join "," ]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "web 2 DB.DEFAULT apiVersion,kind,metadata"; notes != expect {
+		t.Errorf("expected notes %q, got %q", expect, notes)
+	}
+
+	for _, fn := range []string{"env", "expandenv", "include", "lookup"} {
+		_, err := postProcessNotes(newRelease(`[[ ` + fn + ` "HOME" ]]`))
+		if err == nil || !strings.Contains(err.Error(), `function "`+fn+`" not defined`) {
+			t.Errorf("expected %s not to be defined, got %v", fn, err)
+		}
+	}
+}
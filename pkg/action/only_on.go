@@ -0,0 +1,196 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+const (
+	// OnlyKubeVersionAnnotation restricts a rendered resource to the
+	// clusters whose Kubernetes version satisfies its value, a semantic
+	// version constraint such as ">=1.25". The pre-release part of the
+	// version of the cluster, such as the "-gke.1200" of the managed
+	// clusters' "v1.25.10-gke.1200", is ignored.
+	OnlyKubeVersionAnnotation = "helm.sh/only-kube-version"
+	// OnlyCapabilityAnnotation restricts a rendered resource to the
+	// clusters serving all the APIs of its value, a comma separated list of
+	// API versions such as "policy/v1" or of resources such as
+	// "monitoring.coreos.com/v1/ServiceMonitor".
+	OnlyCapabilityAnnotation = "helm.sh/only-capability"
+)
+
+// SkippedResource is a rendered resource left out of a release because its
+// only-on annotations exclude the cluster.
+type SkippedResource struct {
+	Resource release.ResourceIdentity
+	// Source is the path of the template rendering the resource.
+	Source string
+	// Reason is the annotation excluding the cluster.
+	Reason string
+}
+
+func (s SkippedResource) String() string {
+	return fmt.Sprintf("%s from %s: %s", s.Resource, s.Source, s.Reason)
+}
+
+// skippedResource returns the rendered resource r from source as a
+// SkippedResource if its only-on annotations exclude the cluster with caps,
+// or nil if it is to be applied.
+func skippedResource(r releaseutil.Resource, source string, caps *chartutil.Capabilities) (*SkippedResource, error) {
+	reason, err := onlyOn(r.Annotations, caps)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid annotation in %s", source)
+	}
+	if reason == "" {
+		return nil, nil
+	}
+	return &SkippedResource{
+		Resource: release.ResourceIdentity{
+			APIVersion: r.APIVersion(),
+			Kind:       r.Kind,
+			Namespace:  r.Namespace,
+			Name:       r.Name,
+		},
+		Source: source,
+		Reason: reason,
+	}, nil
+}
+
+// skippedDocument is skippedResource for the rendered document doc from
+// source.
+func skippedDocument(source, doc string, caps *chartutil.Capabilities) (*SkippedResource, error) {
+	resources, err := releaseutil.ParseManifest(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", source)
+	}
+	for _, r := range resources {
+		if s, err := skippedResource(r, source, caps); s != nil || err != nil {
+			return s, err
+		}
+	}
+	return nil, nil
+}
+
+// onlyOn returns why the annotations of a resource exclude the cluster with
+// caps, or an empty string if they do not.
+func onlyOn(annotations map[string]string, caps *chartutil.Capabilities) (string, error) {
+	if constraint, ok := annotations[OnlyKubeVersionAnnotation]; ok {
+		c, err := semver.NewConstraint(constraint)
+		if err != nil {
+			return "", errors.Wrapf(err, "%s %q", OnlyKubeVersionAnnotation, constraint)
+		}
+		v, err := semver.NewVersion(caps.KubeVersion.Version)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid Kubernetes version %q", caps.KubeVersion.Version)
+		}
+		// A constraint without a pre-release matches no pre-release, while
+		// the pre-release of a cluster is usually the build of a provider.
+		core, _ := v.SetPrerelease("")
+		if !c.Check(&core) {
+			return fmt.Sprintf("Kubernetes %s does not satisfy %s %q", caps.KubeVersion.Version, OnlyKubeVersionAnnotation, constraint), nil
+		}
+	}
+	if apis, ok := annotations[OnlyCapabilityAnnotation]; ok {
+		for _, api := range strings.Split(apis, ",") {
+			api = strings.TrimSpace(api)
+			if api == "" {
+				continue
+			}
+			if !caps.APIVersions.Has(api) {
+				return fmt.Sprintf("the cluster does not serve %s required by %s", api, OnlyCapabilityAnnotation), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// skipManifests leaves out the manifests whose only-on annotations exclude
+// the cluster with caps, returning the ones to apply and the skipped
+// resources.
+func skipManifests(manifests []releaseutil.Manifest, caps *chartutil.Capabilities) ([]releaseutil.Manifest, []SkippedResource, error) {
+	var skipped []SkippedResource
+	kept := manifests[:0]
+	for _, m := range manifests {
+		s, err := skippedDocument(m.Name, m.Content, caps)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s != nil {
+			skipped = append(skipped, *s)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept, skipped, nil
+}
+
+// skipHooks leaves out the hooks whose only-on annotations exclude the
+// cluster with caps, returning the ones to run and the skipped resources.
+func skipHooks(hooks []*release.Hook, caps *chartutil.Capabilities) ([]*release.Hook, []SkippedResource, error) {
+	var skipped []SkippedResource
+	kept := hooks[:0]
+	for _, h := range hooks {
+		s, err := skippedDocument(h.Path, h.Manifest, caps)
+		if err != nil {
+			return nil, nil, err
+		}
+		if s != nil {
+			skipped = append(skipped, *s)
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept, skipped, nil
+}
+
+// skipPostRendered leaves out the resources of the post-rendered manifest
+// whose only-on annotations exclude the cluster with caps, as the
+// post-renderer may add or change the annotations.
+func skipPostRendered(manifest *bytes.Buffer, caps *chartutil.Capabilities) (*bytes.Buffer, []SkippedResource, error) {
+	resources, err := releaseutil.ParseManifest(manifest.String())
+	if err != nil {
+		return manifest, nil, errors.Wrap(err, "unable to parse the post-rendered resources")
+	}
+	var skipped []SkippedResource
+	kept := bytes.NewBuffer(nil)
+	for _, r := range resources {
+		s, err := skippedResource(r, r.Source, caps)
+		if err != nil {
+			return manifest, nil, err
+		}
+		if s != nil {
+			skipped = append(skipped, *s)
+			continue
+		}
+		if r.Source != "" {
+			fmt.Fprintf(kept, "---\n# Source: %s\n%s\n", r.Source, r.Content)
+		} else {
+			fmt.Fprintf(kept, "---\n%s\n", r.Content)
+		}
+	}
+	return kept, skipped, nil
+}
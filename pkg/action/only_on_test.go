@@ -0,0 +1,83 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+func TestOnlyOnKubeVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		skip       bool
+	}{
+		{"v1.25.0", ">=1.25", false},
+		{"v1.25.10-gke.1200", ">=1.25", false},
+		{"v1.25.10-gke.1200", ">=1.25.0-0", false},
+		{"v1.24.8-eks-ffeb93d", ">=1.25", true},
+		{"v1.24.8", "<1.25", false},
+	}
+	for _, tt := range tests {
+		caps := &chartutil.Capabilities{KubeVersion: chartutil.KubeVersion{Version: tt.version}}
+		reason, err := onlyOn(map[string]string{OnlyKubeVersionAnnotation: tt.constraint}, caps)
+		if err != nil {
+			t.Fatalf("%s %s: %s", tt.version, tt.constraint, err)
+		}
+		if skip := reason != ""; skip != tt.skip {
+			t.Errorf("%s %s: expected skip %t, got %t (%q)", tt.version, tt.constraint, tt.skip, skip, reason)
+		}
+	}
+}
+
+// annotatingPostRenderer restricts the rendered resources to Kubernetes 2,
+// as a post-renderer patching in the only-on annotations would.
+type annotatingPostRenderer struct{}
+
+func (annotatingPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	s := strings.ReplaceAll(renderedManifests.String(), "  name: skipped\n",
+		"  name: skipped\n  annotations:\n    helm.sh/only-kube-version: \">=2\"\n")
+	return bytes.NewBufferString(s), nil
+}
+
+func TestRenderResourcesOnlyOnPostRendered(t *testing.T) {
+	config := actionConfigFixture(t)
+	ch := buildChart()
+	ch.Templates = []*chart.File{
+		{Name: "templates/kept.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: kept\n")},
+		{Name: "templates/skipped.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: skipped\n")},
+	}
+
+	_, manifest, _, skipped, err := config.renderResources(ch, chartutil.Values{}, "test", "", false, false, false, annotatingPostRenderer{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].Resource.Name != "skipped" || skipped[0].Source != "hello/templates/skipped.yaml" {
+		t.Fatalf("expected the post-rendered resource to be skipped, got %v", skipped)
+	}
+	if strings.Contains(manifest.String(), "name: skipped") {
+		t.Errorf("expected the skipped resource to be left out, got:\n%s", manifest)
+	}
+	if !strings.Contains(manifest.String(), "# Source: hello/templates/kept.yaml\napiVersion: v1") {
+		t.Errorf("expected the kept resource with its source, got:\n%s", manifest)
+	}
+}
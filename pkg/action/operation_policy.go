@@ -0,0 +1,149 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OperationKind is the kind of an operation changing a release.
+type OperationKind string
+
+const (
+	// OperationInstall installs a release.
+	OperationInstall OperationKind = "install"
+	// OperationUpgrade upgrades a release.
+	OperationUpgrade OperationKind = "upgrade"
+	// OperationRollback rolls a release back.
+	OperationRollback OperationKind = "rollback"
+	// OperationUninstall uninstalls a release.
+	OperationUninstall OperationKind = "uninstall"
+)
+
+// Operation is an operation changing a release, checked by the
+// OperationPolicies of a Configuration before it changes anything.
+type Operation struct {
+	Kind      OperationKind
+	Release   string
+	Namespace string
+	// Time is the time of the operation, see Configuration.Now.
+	Time time.Time
+}
+
+// OperationPolicy vetoes operations changing releases, for example during
+// deploy freezes or in protected namespaces.
+type OperationPolicy interface {
+	// Check returns an error explaining why the operation is rejected, or
+	// nil if the operation is allowed.
+	Check(op Operation) error
+}
+
+// OperationPolicyFunc adapts a function to an OperationPolicy.
+type OperationPolicyFunc func(op Operation) error
+
+// Check calls f(op).
+func (f OperationPolicyFunc) Check(op Operation) error {
+	return f(op)
+}
+
+// OperationRejectedError is returned by the actions whose operation an
+// OperationPolicy rejected.
+type OperationRejectedError struct {
+	Operation Operation
+	// Reason is the error returned by the policy.
+	Reason error
+}
+
+func (e *OperationRejectedError) Error() string {
+	return fmt.Sprintf("%s of release %q in namespace %q rejected: %s", e.Operation.Kind, e.Operation.Release, e.Operation.Namespace, e.Reason)
+}
+
+// Unwrap returns the error returned by the policy.
+func (e *OperationRejectedError) Unwrap() error {
+	return e.Reason
+}
+
+// IsOperationRejected reports whether err is, or wraps, an
+// OperationRejectedError.
+func IsOperationRejected(err error) bool {
+	var rejected *OperationRejectedError
+	return errors.As(err, &rejected)
+}
+
+// checkOperation checks the operation with the OperationPolicies of the
+// configuration, returning an OperationRejectedError for the first policy
+// rejecting it.
+func (cfg *Configuration) checkOperation(kind OperationKind, name, namespace string) error {
+	op := Operation{Kind: kind, Release: name, Namespace: namespace, Time: cfg.Now().Time}
+	for _, p := range cfg.OperationPolicies {
+		if err := p.Check(op); err != nil {
+			return &OperationRejectedError{Operation: op, Reason: err}
+		}
+	}
+	return nil
+}
+
+// FreezeWindow is an OperationPolicy rejecting the operations between
+// Start and End, such as during a deploy freeze.
+type FreezeWindow struct {
+	Start time.Time
+	End   time.Time
+	// Namespaces restricts the freeze to the releases of these namespaces.
+	// All namespaces are frozen if it is empty.
+	Namespaces []string
+	// Operations restricts the freeze to these operations, e.g. to allow
+	// rollbacks during a freeze. All operations are frozen if it is empty.
+	Operations []OperationKind
+	// Reason is given in the errors of the rejected operations.
+	Reason string
+}
+
+// Check rejects op if it happens during the window.
+func (w *FreezeWindow) Check(op Operation) error {
+	if op.Time.Before(w.Start) || !op.Time.Before(w.End) {
+		return nil
+	}
+	if len(w.Namespaces) > 0 && !containsString(w.Namespaces, op.Namespace) {
+		return nil
+	}
+	if len(w.Operations) > 0 {
+		frozen := false
+		for _, kind := range w.Operations {
+			frozen = frozen || kind == op.Kind
+		}
+		if !frozen {
+			return nil
+		}
+	}
+	reason := w.Reason
+	if reason == "" {
+		reason = "deploy freeze"
+	}
+	return errors.Errorf("%s from %s to %s", reason, w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
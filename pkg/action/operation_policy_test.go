@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestFreezeWindowCheck(t *testing.T) {
+	start := time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)
+	end := start.Add(14 * 24 * time.Hour)
+	op := func(kind OperationKind, namespace string, at time.Time) Operation {
+		return Operation{Kind: kind, Release: "web", Namespace: namespace, Time: at}
+	}
+
+	tests := []struct {
+		name   string
+		window FreezeWindow
+		op     Operation
+		reject bool
+	}{
+		{"before the window", FreezeWindow{Start: start, End: end}, op(OperationUpgrade, "prod", start.Add(-time.Second)), false},
+		{"at the start", FreezeWindow{Start: start, End: end}, op(OperationUpgrade, "prod", start), true},
+		{"at the end", FreezeWindow{Start: start, End: end}, op(OperationUpgrade, "prod", end), false},
+		{"frozen namespace", FreezeWindow{Start: start, End: end, Namespaces: []string{"prod"}}, op(OperationInstall, "prod", start), true},
+		{"other namespace", FreezeWindow{Start: start, End: end, Namespaces: []string{"prod"}}, op(OperationInstall, "dev", start), false},
+		{"frozen operation", FreezeWindow{Start: start, End: end, Operations: []OperationKind{OperationUpgrade}}, op(OperationUpgrade, "prod", start), true},
+		{"allowed operation", FreezeWindow{Start: start, End: end, Operations: []OperationKind{OperationUpgrade}}, op(OperationRollback, "prod", start), false},
+	}
+	for _, tt := range tests {
+		err := tt.window.Check(tt.op)
+		if (err != nil) != tt.reject {
+			t.Errorf("%s: expected rejection %t, got %v", tt.name, tt.reject, err)
+		}
+	}
+
+	err := (&FreezeWindow{Start: start, End: end, Reason: "year-end freeze"}).Check(op(OperationUpgrade, "prod", start))
+	if err == nil || !strings.HasPrefix(err.Error(), "year-end freeze from 2026-12-20T00:00:00Z") {
+		t.Errorf("expected the reason in the error, got %v", err)
+	}
+}
+
+func TestCheckOperation(t *testing.T) {
+	cfg := actionConfigFixture(t)
+	errFrozen := errors.New("frozen")
+	var second []OperationKind
+	cfg.OperationPolicies = []OperationPolicy{
+		OperationPolicyFunc(func(op Operation) error {
+			if op.Kind == OperationUninstall {
+				return errFrozen
+			}
+			return nil
+		}),
+		OperationPolicyFunc(func(op Operation) error {
+			second = append(second, op.Kind)
+			return nil
+		}),
+	}
+
+	if err := cfg.checkOperation(OperationUpgrade, "web", "prod"); err != nil {
+		t.Errorf("expected the upgrade to be allowed, got %v", err)
+	}
+	err := cfg.checkOperation(OperationUninstall, "web", "prod")
+	if !IsOperationRejected(err) {
+		t.Fatalf("expected an OperationRejectedError, got %v", err)
+	}
+	if !errors.Is(err, errFrozen) {
+		t.Errorf("expected the error to wrap the reason of the policy, got %v", err)
+	}
+	if expect := `uninstall of release "web" in namespace "prod" rejected: frozen`; err.Error() != expect {
+		t.Errorf("expected %q, got %q", expect, err.Error())
+	}
+	// The policies after the one rejecting an operation are not checked.
+	if len(second) != 1 || second[0] != OperationUpgrade {
+		t.Errorf("expected the second policy to check the upgrade only, got %v", second)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/strvals"
+)
+
+// ReleaseOverrides is the action for managing the values pinned on a
+// release, which are applied over the values of the release on every
+// upgrade until they are removed.
+//
+// It provides the implementation of 'helm values'.
+type ReleaseOverrides struct {
+	cfg *Configuration
+}
+
+// NewReleaseOverrides creates a new ReleaseOverrides object with the given
+// configuration.
+func NewReleaseOverrides(cfg *Configuration) *ReleaseOverrides {
+	return &ReleaseOverrides{
+		cfg: cfg,
+	}
+}
+
+// List returns the overrides pinned on the release name, ordered by key.
+func (o *ReleaseOverrides) List(name string) ([]*release.PinnedOverride, error) {
+	if err := o.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := o.cfg.releaseContent(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	return rel.Overrides, nil
+}
+
+// Set pins the values given as "key=value", as with --set, on the release
+// name, replacing the overrides already pinned on the same keys. The
+// overrides are recorded on the latest revision of the release and applied
+// from its next upgrade.
+func (o *ReleaseOverrides) Set(name string, values []string) ([]*release.PinnedOverride, error) {
+	if err := o.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := o.cfg.releaseContent(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	now := o.cfg.Now()
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("override %q is not of the form key=value", v)
+		}
+		if err := strvals.ParseInto(v, map[string]interface{}{}); err != nil {
+			return nil, errors.Wrapf(err, "failed parsing override %q", v)
+		}
+		override := &release.PinnedOverride{Key: kv[0], Value: kv[1], SetAt: now, Revision: rel.Version}
+		replaced := false
+		for i, existing := range rel.Overrides {
+			if existing.Key == override.Key {
+				rel.Overrides[i] = override
+				replaced = true
+			}
+		}
+		if !replaced {
+			rel.Overrides = append(rel.Overrides, override)
+		}
+	}
+	sort.SliceStable(rel.Overrides, func(i, j int) bool { return rel.Overrides[i].Key < rel.Overrides[j].Key })
+
+	if err := o.cfg.Releases.Update(rel); err != nil {
+		return nil, errors.Wrapf(err, "failed to record the overrides of release %q", name)
+	}
+	return rel.Overrides, nil
+}
+
+// Unset removes the overrides pinned on keys from the release name. An error
+// is returned for the keys without an override.
+func (o *ReleaseOverrides) Unset(name string, keys []string) ([]*release.PinnedOverride, error) {
+	if err := o.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	rel, err := o.cfg.releaseContent(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		found := false
+		for i, existing := range rel.Overrides {
+			if existing.Key == key {
+				rel.Overrides = append(rel.Overrides[:i], rel.Overrides[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("release %q has no override pinned on %q", name, key)
+		}
+	}
+
+	if err := o.cfg.Releases.Update(rel); err != nil {
+		return nil, errors.Wrapf(err, "failed to record the overrides of release %q", name)
+	}
+	return rel.Overrides, nil
+}
+
+// applyOverrides returns a copy of vals with the overrides applied over
+// them. vals is returned as is if there are no overrides.
+func applyOverrides(vals map[string]interface{}, overrides []*release.PinnedOverride) (map[string]interface{}, error) {
+	if len(overrides) == 0 {
+		return vals, nil
+	}
+	c, err := copystructure.Copy(vals)
+	if err != nil {
+		return nil, err
+	}
+	applied, _ := c.(map[string]interface{})
+	if applied == nil {
+		applied = map[string]interface{}{}
+	}
+	for _, o := range overrides {
+		if err := strvals.ParseInto(o.Key+"="+o.Value, applied); err != nil {
+			return nil, errors.Wrapf(err, "failed applying the override of %q", o.Key)
+		}
+	}
+	return applied, nil
+}
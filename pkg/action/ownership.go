@@ -0,0 +1,116 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// ResourceOwnershipError is returned by the actions applying a resource
+// owned by another release, such as a ClusterRole or a CRD that two releases
+// render.
+type ResourceOwnershipError struct {
+	// Resource is the resource owned by another release.
+	Resource release.ResourceIdentity
+	// Release and Namespace identify the release owning the resource.
+	Release   string
+	Namespace string
+}
+
+func (e *ResourceOwnershipError) Error() string {
+	return fmt.Sprintf("%s is owned by release %q in namespace %q", e.Resource, e.Release, e.Namespace)
+}
+
+// IsResourceOwnership reports whether err is, or wraps, a
+// ResourceOwnershipError.
+func IsResourceOwnership(err error) bool {
+	var ownership *ResourceOwnershipError
+	return errors.As(err, &ownership)
+}
+
+// releaseOwner returns the release owning obj according to its Helm
+// ownership metadata, if it has any.
+func releaseOwner(obj runtime.Object) (name, namespace string, ok bool) {
+	annos, err := accessor.Annotations(obj)
+	if err != nil {
+		return "", "", false
+	}
+	name, ok = annos[helmReleaseNameAnnotation]
+	if !ok {
+		return "", "", false
+	}
+	return name, annos[helmReleaseNamespaceAnnotation], true
+}
+
+// ownedByOtherRelease returns a ResourceOwnershipError if obj, the existing
+// object of info, is owned by another release than the given one.
+func ownedByOtherRelease(info *resource.Info, obj runtime.Object, releaseName, releaseNamespace string) error {
+	name, namespace, ok := releaseOwner(obj)
+	if !ok || (name == releaseName && namespace == releaseNamespace) {
+		return nil
+	}
+	return &ResourceOwnershipError{Resource: resourceIdentity(info), Release: name, Namespace: namespace}
+}
+
+// checkClusterScopedOwnership checks that the existing cluster-scoped
+// resources are not owned by another release, since unlike namespaced ones
+// they are easily rendered by the charts of several releases.
+func checkClusterScopedOwnership(resources kube.ResourceList, releaseName, releaseNamespace string) error {
+	for _, info := range resources {
+		if info.Namespaced() {
+			continue
+		}
+		existing, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return errors.Wrapf(err, "could not get information about the resource %s", resourceString(info))
+		}
+		if err := ownedByOtherRelease(info, existing, releaseName, releaseNamespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitOwnedByOthers splits the resources of a release in the ones it owns
+// and the cluster-scoped ones another release took the ownership of, which
+// must not be deleted with the release. The latter are refreshed with
+// their existing objects.
+func splitOwnedByOthers(resources kube.ResourceList, releaseName, releaseNamespace string) (owned, others kube.ResourceList) {
+	for _, info := range resources {
+		if !info.Namespaced() {
+			existing, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+			if err == nil && ownedByOtherRelease(info, existing, releaseName, releaseNamespace) != nil {
+				info.Refresh(existing, true)
+				others.Append(info)
+				continue
+			}
+		}
+		owned.Append(info)
+	}
+	return owned, others
+}
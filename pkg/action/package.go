@@ -18,6 +18,7 @@ package action
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -27,9 +28,11 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/term"
 
+	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 // Package is the action for packaging a chart.
@@ -44,6 +47,24 @@ type Package struct {
 	AppVersion       string
 	Destination      string
 	DependencyUpdate bool
+	// SBOM is the format of the software bill of materials to write next to
+	// the chart archive. No bill of materials is written when it is empty.
+	SBOM string
+	// DependencyUpdater updates the dependencies of a chart directory before
+	// RunRecursive packages it, when DependencyUpdate is set.
+	DependencyUpdater func(chartPath string) error
+	// IndexURL is the URL of the repository of the destination, whose index
+	// RunRecursive regenerates when set.
+	IndexURL string
+	// ValuesDocs adds the reference of the values of the chart, generated
+	// like chartutil.ValuesDocs, to the package as its VALUES.md file.
+	ValuesDocs bool
+	// GPGAgent signs with the gpg command, leaving the private key to
+	// gpg-agent, instead of reading it from Keyring.
+	GPGAgent bool
+	// Signer signs the package with a private key held outside of Helm. It
+	// takes precedence over GPGAgent and Keyring.
+	Signer provenance.ExternalSigner
 
 	RepositoryConfig string
 	RepositoryCache  string
@@ -60,7 +81,13 @@ func (p *Package) Run(path string, vals map[string]interface{}) (string, error)
 	if err != nil {
 		return "", err
 	}
+	return p.RunChart(ch, vals)
+}
 
+// RunChart packages the chart ch, loaded e.g. with loader.LoadFS, and
+// returns the path to the packaged chart.
+func (p *Package) RunChart(ch *chart.Chart, vals map[string]interface{}) (string, error) {
+	var err error
 	// If version is set, modify the version.
 	if p.Version != "" {
 		ch.Metadata.Version = p.Version
@@ -80,6 +107,18 @@ func (p *Package) Run(path string, vals map[string]interface{}) (string, error)
 		}
 	}
 
+	if p.SBOM != "" {
+		if _, err := sbom.ParseFormat(p.SBOM); err != nil {
+			return "", err
+		}
+	}
+
+	if p.ValuesDocs {
+		if err := addValuesDocs(ch); err != nil {
+			return "", err
+		}
+	}
+
 	var dest string
 	if p.Destination == "." {
 		// Save to the current working directory.
@@ -97,6 +136,12 @@ func (p *Package) Run(path string, vals map[string]interface{}) (string, error)
 		return "", errors.Wrap(err, "failed to save")
 	}
 
+	if p.SBOM != "" {
+		if err := p.writeSBOM(ch, name); err != nil {
+			return "", err
+		}
+	}
+
 	if p.Sign {
 		err = p.Clearsign(name)
 	}
@@ -104,6 +149,40 @@ func (p *Package) Run(path string, vals map[string]interface{}) (string, error)
 	return name, err
 }
 
+// addValuesDocs adds the reference of the values of ch to its files,
+// replacing the existing one.
+func addValuesDocs(ch *chart.Chart) error {
+	docs, err := chartutil.ChartValuesDocs(ch)
+	if err != nil {
+		return errors.Wrap(err, "unable to document the values")
+	}
+	var buf bytes.Buffer
+	if err := chartutil.WriteValuesDocsMarkdown(&buf, docs); err != nil {
+		return err
+	}
+	for _, f := range ch.Files {
+		if f.Name == chartutil.ValuesDocsFileName {
+			f.Data = buf.Bytes()
+			return nil
+		}
+	}
+	ch.Files = append(ch.Files, &chart.File{Name: chartutil.ValuesDocsFileName, Data: buf.Bytes()})
+	return nil
+}
+
+// writeSBOM writes the bill of materials of ch next to the chart archive.
+func (p *Package) writeSBOM(ch *chart.Chart, filename string) error {
+	format, err := sbom.ParseFormat(p.SBOM)
+	if err != nil {
+		return err
+	}
+	data, err := sbom.Generate(ch, sbom.Options{Format: format})
+	if err != nil {
+		return errors.Wrap(err, "failed to generate SBOM")
+	}
+	return ioutil.WriteFile(filename+sbom.FileSuffix, data, 0644)
+}
+
 // validateVersion Verify that version is a Version, and error out if it is not.
 func validateVersion(ver string) error {
 	if _, err := semver.NewVersion(ver); err != nil {
@@ -114,6 +193,14 @@ func validateVersion(ver string) error {
 
 // Clearsign signs a chart
 func (p *Package) Clearsign(filename string) error {
+	if signer := p.externalSigner(); signer != nil {
+		sig, err := (&provenance.Signatory{Signer: signer}).ClearSign(filename)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filename+".prov", []byte(sig), 0644)
+	}
+
 	// Load keyring
 	signer, err := provenance.NewFromKeyring(p.Keyring, p.Key)
 	if err != nil {
@@ -140,6 +227,18 @@ func (p *Package) Clearsign(filename string) error {
 	return ioutil.WriteFile(filename+".prov", []byte(sig), 0644)
 }
 
+// externalSigner returns the signer of the package when its private key is
+// not read from the keyring.
+func (p *Package) externalSigner() provenance.ExternalSigner {
+	if p.Signer != nil {
+		return p.Signer
+	}
+	if p.GPGAgent {
+		return &provenance.GPGAgentSigner{KeyID: p.Key}
+	}
+	return nil
+}
+
 // promptUser implements provenance.PassphraseFetcher
 func promptUser(name string) ([]byte, error) {
 	fmt.Printf("Password for key %q >  ", name)
@@ -0,0 +1,238 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+// PackagedChart describes a chart packaged by Package.RunRecursive.
+type PackagedChart struct {
+	// Name and Version are the name and version of the chart.
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Path is the path of the chart directory.
+	Path string `json:"path"`
+	// Archive is the path of the chart archive.
+	Archive string `json:"archive"`
+	// Dependencies are the paths of the charts found with the chart it
+	// depends on, through a "file://" repository.
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// localChart is a chart directory found by FindCharts.
+type localChart struct {
+	path string
+	deps []string
+}
+
+// FindCharts returns the chart directories under root, the ones a chart
+// depends on through a "file://" repository first. The directories of the
+// charts, such as their charts directory, and the hidden directories are not
+// searched.
+func FindCharts(root string) ([]string, error) {
+	charts, err := findLocalCharts(root)
+	if err != nil {
+		return nil, err
+	}
+	ordered, err := orderLocalCharts(charts)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(ordered))
+	for i, c := range ordered {
+		paths[i] = c.path
+	}
+	return paths, nil
+}
+
+// findLocalCharts returns the chart directories under root, sorted by path,
+// with their local dependencies found under root.
+func findLocalCharts(root string) ([]*localChart, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var charts []*localChart
+	found := map[string]bool{}
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, chartutil.ChartfileName)); err != nil {
+			return nil
+		}
+		md, err := chartutil.LoadChartfile(filepath.Join(path, chartutil.ChartfileName))
+		if err != nil {
+			return errors.Wrapf(err, "unable to load the chart %s", path)
+		}
+		c := &localChart{path: path}
+		for _, dep := range md.Dependencies {
+			if strings.HasPrefix(dep.Repository, "file://") {
+				c.deps = append(c.deps, filepath.Join(path, strings.TrimPrefix(dep.Repository, "file://")))
+			}
+		}
+		charts = append(charts, c)
+		found[path] = true
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(charts) == 0 {
+		return nil, errors.Errorf("no chart found under %s", root)
+	}
+	for _, c := range charts {
+		deps := c.deps[:0]
+		for _, dep := range c.deps {
+			if found[dep] {
+				deps = append(deps, dep)
+			}
+		}
+		c.deps = deps
+	}
+	return charts, nil
+}
+
+// orderLocalCharts sorts the charts so that each one comes after the charts
+// it depends on, failing on circular dependencies.
+func orderLocalCharts(charts []*localChart) ([]*localChart, error) {
+	byPath := make(map[string]*localChart, len(charts))
+	for _, c := range charts {
+		byPath[c.path] = c
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var ordered []*localChart
+	var visit func(c *localChart, stack []string) error
+	visit = func(c *localChart, stack []string) error {
+		switch state[c.path] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("circular dependency between the charts %s", strings.Join(append(stack, c.path), " -> "))
+		}
+		state[c.path] = visiting
+		deps := append([]string(nil), c.deps...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if err := visit(byPath[dep], append(stack, c.path)); err != nil {
+				return err
+			}
+		}
+		state[c.path] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+	for _, c := range charts {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// RunRecursive packages the charts found under root like FindCharts, each
+// after the charts it depends on, and returns them in that order. With
+// DependencyUpdate, the dependencies of each chart are updated with
+// DependencyUpdater before it is packaged, so that the archives of its local
+// dependencies are up to date. With IndexURL, the index of the repository
+// of the destination is regenerated, see repo.IndexDirectory.
+func (p *Package) RunRecursive(root string, vals map[string]interface{}) ([]PackagedChart, error) {
+	charts, err := findLocalCharts(root)
+	if err != nil {
+		return nil, err
+	}
+	if charts, err = orderLocalCharts(charts); err != nil {
+		return nil, err
+	}
+
+	packaged := make([]PackagedChart, 0, len(charts))
+	for _, c := range charts {
+		if p.DependencyUpdate && p.DependencyUpdater != nil {
+			if err := p.DependencyUpdater(c.path); err != nil {
+				return packaged, errors.Wrapf(err, "unable to update the dependencies of %s", c.path)
+			}
+		}
+		ch, err := loader.LoadDir(c.path)
+		if err != nil {
+			return packaged, err
+		}
+		archive, err := p.RunChart(ch, vals)
+		if err != nil {
+			return packaged, errors.Wrapf(err, "unable to package %s", c.path)
+		}
+		packaged = append(packaged, PackagedChart{
+			Name:         ch.Metadata.Name,
+			Version:      ch.Metadata.Version,
+			Path:         c.path,
+			Archive:      archive,
+			Dependencies: c.deps,
+		})
+	}
+
+	if p.IndexURL != "" {
+		if err := p.writeIndex(); err != nil {
+			return packaged, err
+		}
+	}
+	return packaged, nil
+}
+
+// writeIndex regenerates the index of the charts of the destination,
+// merging the existing one.
+func (p *Package) writeIndex() error {
+	dest := p.Destination
+	if dest == "." || dest == "" {
+		var err error
+		if dest, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	index, err := repo.IndexDirectory(dest, p.IndexURL)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(dest, "index.yaml")
+	if _, err := os.Stat(filename); err == nil {
+		existing, err := repo.LoadIndexFile(filename)
+		if err != nil {
+			return err
+		}
+		index.Merge(existing)
+	}
+	index.SortEntries()
+	return index.WriteFile(filename, 0644)
+}
@@ -0,0 +1,385 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// The verbs an operation needs on the resources of a release.
+var (
+	addVerbs    = []string{"get", "create"}
+	modifyVerbs = []string{"get", "patch"}
+	keepVerbs   = []string{"get"}
+	deleteVerbs = []string{"delete"}
+	// Hooks are deleted before being created again, and watched until they
+	// are ready.
+	hookVerbs   = []string{"get", "list", "watch", "create", "delete"}
+	recordVerbs = []string{"get", "list", "create", "update"}
+)
+
+// verbOrder orders the permissions of a resource.
+var verbOrder = map[string]int{"get": 0, "list": 1, "watch": 2, "create": 3, "update": 4, "patch": 5, "delete": 6}
+
+// RequiredPermission is a permission an operation needs.
+type RequiredPermission struct {
+	kube.Permission
+	// For are the resources needing the permission, "Kind/name", including
+	// the hooks, the CRDs and the release records.
+	For []string `json:"for"`
+}
+
+// PermissionReport lists the permissions installing or upgrading a release
+// needs, and the ones the current identity lacks.
+type PermissionReport struct {
+	Release   string        `json:"release"`
+	Namespace string        `json:"namespace"`
+	Operation PlanOperation `json:"operation"`
+	// Required are all the permissions the operation needs, ordered by
+	// namespace, resource and verb.
+	Required []RequiredPermission `json:"required"`
+	// Missing are the required permissions the current identity is denied.
+	Missing []RequiredPermission `json:"missing"`
+}
+
+// Failed reports whether any required permission is missing.
+func (r *PermissionReport) Failed() bool {
+	return len(r.Missing) > 0
+}
+
+// CheckPermissions renders chrt with vals as for a dry run, and reports the
+// permissions installing it needs, on its resources, its hooks, its CRDs, the
+// release records and, with CreateNamespace, the namespace, along with the
+// ones the current identity lacks according to SelfSubjectAccessReviews.
+//
+// The permissions are checked on the types of resources, not on the named
+// resources, so a permission granted only for some resource names is
+// reported as missing.
+func (i *Install) CheckPermissions(chrt *chart.Chart, vals map[string]interface{}) (*PermissionReport, error) {
+	dryRun := i.DryRun
+	i.DryRun = true
+	defer func() { i.DryRun = dryRun }()
+
+	rel, err := i.Run(chrt, vals, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var crds []string
+	if !i.SkipCRDs {
+		for _, crd := range chrt.CRDObjects() {
+			crds = append(crds, string(crd.File.Data))
+		}
+	}
+	var events []release.HookEvent
+	if !i.DisableHooks {
+		events = []release.HookEvent{release.HookPreInstall, release.HookPostInstall}
+	}
+	p := newPermissions(rel.Namespace)
+	if i.CreateNamespace {
+		p.add(kube.Permission{Resource: "namespaces"}, "Namespace/"+rel.Namespace, "get", "create")
+	}
+	return i.cfg.permissionReport(PlanInstall, p, nil, rel, crds, events...)
+}
+
+// CheckPermissions reports the permissions upgrading the release name to
+// chart with vals needs, and the ones the current identity lacks. See
+// Install.CheckPermissions.
+func (u *Upgrade) CheckPermissions(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}) (*PermissionReport, error) {
+	if err := u.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	current, upgraded, err := u.prepareUpgrade(ctx, name, chart, vals, "")
+	if err != nil {
+		return nil, err
+	}
+	var events []release.HookEvent
+	if !u.DisableHooks {
+		events = []release.HookEvent{release.HookPreUpgrade, release.HookPostUpgrade}
+	}
+	return u.cfg.permissionReport(PlanUpgrade, newPermissions(upgraded.Namespace), current, upgraded, nil, events...)
+}
+
+// permissionReport adds to p the permissions of moving from the current
+// release, nil when installing, to the next one, installing the CRDs crds
+// first and running the hooks of next on events, and reviews them.
+func (cfg *Configuration) permissionReport(op PlanOperation, p *permissions, current, next *release.Release, crds []string, events ...release.HookEvent) (*PermissionReport, error) {
+	if cfg.RESTClientGetter == nil {
+		return nil, errors.New("unable to check the permissions: the configuration is not initialized")
+	}
+	reviewer, ok := cfg.KubeClient.(kube.InterfaceAccess)
+	if !ok {
+		return nil, errors.New("unable to check the permissions: the Kubernetes client cannot review access")
+	}
+	mapper, err := cfg.RESTClientGetter.ToRESTMapper()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check the permissions")
+	}
+	p.mapper = mapper
+
+	manifests := append([]string{next.Manifest}, crds...)
+	for _, h := range next.Hooks {
+		manifests = append(manifests, h.Manifest)
+	}
+	if err := p.learnCRDs(manifests...); err != nil {
+		return nil, err
+	}
+
+	for _, crd := range crds {
+		if err := p.addManifest(crd, "", addVerbs...); err != nil {
+			return nil, errors.Wrap(err, "parsing the CRDs of the chart")
+		}
+	}
+
+	from := map[resourceKey]manifestObject{}
+	if current != nil {
+		if from, err = manifestObjects(current.Manifest, current.Namespace); err != nil {
+			return nil, errors.Wrap(err, "parsing the manifest of the current release")
+		}
+	}
+	to, err := manifestObjects(next.Manifest, next.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing the manifest of the new release")
+	}
+	for key, obj := range to {
+		verbs := addVerbs
+		if prev, ok := from[key]; ok {
+			verbs = keepVerbs
+			if !reflect.DeepEqual(prev.Object, obj.Object) {
+				verbs = modifyVerbs
+			}
+		}
+		if err := p.addResource(obj.AuditResource, verbs...); err != nil {
+			return nil, err
+		}
+	}
+	for key, obj := range from {
+		if _, ok := to[key]; !ok {
+			if err := p.addResource(obj.AuditResource, deleteVerbs...); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, h := range next.Hooks {
+		if !hookRunsOn(h, events) {
+			continue
+		}
+		if err := p.addManifest(h.Manifest, next.Namespace, hookVerbs...); err != nil {
+			return nil, errors.Wrapf(err, "parsing the hook %s", h.Path)
+		}
+	}
+
+	if cfg.Releases != nil {
+		var records string
+		switch cfg.Releases.Name() {
+		case driver.SecretsDriverName:
+			records = "secrets"
+		case driver.ConfigMapsDriverName:
+			records = "configmaps"
+		}
+		if records != "" {
+			namespace := cfg.StorageNamespace
+			if namespace == "" {
+				namespace = next.Namespace
+			}
+			p.add(kube.Permission{Resource: records, Namespace: namespace}, "release records", recordVerbs...)
+		}
+	}
+
+	report := &PermissionReport{
+		Release:   next.Name,
+		Namespace: next.Namespace,
+		Operation: op,
+		Required:  p.sorted(),
+		Missing:   []RequiredPermission{},
+	}
+	perms := make([]kube.Permission, len(report.Required))
+	for i, r := range report.Required {
+		perms[i] = r.Permission
+	}
+	denied, err := reviewer.ReviewAccess(perms)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to check the permissions")
+	}
+	missing := make(map[kube.Permission]bool, len(denied))
+	for _, d := range denied {
+		missing[d] = true
+	}
+	for _, r := range report.Required {
+		if missing[r.Permission] {
+			report.Missing = append(report.Missing, r)
+		}
+	}
+	return report, nil
+}
+
+// hookRunsOn reports whether the hook h runs on one of events.
+func hookRunsOn(h *release.Hook, events []release.HookEvent) bool {
+	for _, e := range h.Events {
+		for _, event := range events {
+			if e == event {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// permissions collects the permissions an operation needs.
+type permissions struct {
+	namespace string
+	mapper    meta.RESTMapper
+	// crds are the resources defined by the CRDs of the chart, which the
+	// mapper does not know before they are installed.
+	crds  map[schema.GroupKind]crdResource
+	perms map[kube.Permission][]string
+}
+
+type crdResource struct {
+	resource   string
+	namespaced bool
+}
+
+func newPermissions(namespace string) *permissions {
+	return &permissions{
+		namespace: namespace,
+		crds:      map[schema.GroupKind]crdResource{},
+		perms:     map[kube.Permission][]string{},
+	}
+}
+
+// add records that the resource source needs the verbs on the type of
+// resource of perm.
+func (p *permissions) add(perm kube.Permission, source string, verbs ...string) {
+	for _, verb := range verbs {
+		perm.Verb = verb
+		p.perms[perm] = append(p.perms[perm], source)
+	}
+}
+
+// addManifest records the verbs on the resources of manifest, giving the
+// resources without a namespace the namespace namespace.
+func (p *permissions) addManifest(manifest, namespace string, verbs ...string) error {
+	resources, err := releaseutil.ParseManifest(manifest)
+	if err != nil {
+		return err
+	}
+	for _, r := range resources {
+		res := AuditResource{APIVersion: r.APIVersion(), Kind: r.Kind, Name: r.Name, Namespace: namespace}
+		if r.Namespace != "" {
+			res.Namespace = r.Namespace
+		}
+		if err := p.addResource(res, verbs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addResource records the verbs on the type of resource of res.
+func (p *permissions) addResource(res AuditResource, verbs ...string) error {
+	gvk := schema.FromAPIVersionAndKind(res.APIVersion, res.Kind)
+	perm := kube.Permission{Group: gvk.Group}
+	namespaced := true
+	if crd, ok := p.crds[gvk.GroupKind()]; ok {
+		perm.Resource, namespaced = crd.resource, crd.namespaced
+	} else {
+		mapping, err := p.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return errors.Wrapf(err, "unable to find the resource of %s %q", res.Kind, res.Name)
+		}
+		perm.Resource = mapping.Resource.Resource
+		namespaced = mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	}
+	if namespaced {
+		perm.Namespace = res.Namespace
+		if perm.Namespace == "" {
+			perm.Namespace = p.namespace
+		}
+	}
+	p.add(perm, res.Kind+"/"+res.Name, verbs...)
+	return nil
+}
+
+// learnCRDs records the resources defined by the CustomResourceDefinitions
+// of manifests.
+func (p *permissions) learnCRDs(manifests ...string) error {
+	for _, manifest := range manifests {
+		for _, doc := range releaseutil.SplitManifests(manifest) {
+			var crd struct {
+				Kind string `json:"kind"`
+				Spec struct {
+					Group string `json:"group"`
+					Names struct {
+						Kind   string `json:"kind"`
+						Plural string `json:"plural"`
+					} `json:"names"`
+					Scope string `json:"scope"`
+				} `json:"spec"`
+			}
+			if err := yaml.Unmarshal([]byte(doc), &crd); err != nil {
+				return errors.Wrap(err, "parsing the CRDs")
+			}
+			if crd.Kind != "CustomResourceDefinition" || crd.Spec.Names.Plural == "" {
+				continue
+			}
+			gk := schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}
+			p.crds[gk] = crdResource{resource: crd.Spec.Names.Plural, namespaced: crd.Spec.Scope != "Cluster"}
+		}
+	}
+	return nil
+}
+
+// sorted returns the permissions ordered by namespace, resource and verb.
+func (p *permissions) sorted() []RequiredPermission {
+	required := make([]RequiredPermission, 0, len(p.perms))
+	for perm, sources := range p.perms {
+		sort.Strings(sources)
+		unique := sources[:0]
+		for i, s := range sources {
+			if i == 0 || s != sources[i-1] {
+				unique = append(unique, s)
+			}
+		}
+		required = append(required, RequiredPermission{Permission: perm, For: unique})
+	}
+	sort.Slice(required, func(i, j int) bool {
+		a, b := required[i], required[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if ra, rb := a.GroupResource(), b.GroupResource(); ra != rb {
+			return ra < rb
+		}
+		return verbOrder[a.Verb] < verbOrder[b.Verb]
+	})
+	return required
+}
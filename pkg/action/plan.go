@@ -0,0 +1,254 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// PlanOperation is the operation a ReleasePlan performs.
+type PlanOperation string
+
+const (
+	// PlanInstall installs a new release.
+	PlanInstall PlanOperation = "install"
+	// PlanUpgrade upgrades an existing release.
+	PlanUpgrade PlanOperation = "upgrade"
+)
+
+// ResourceChange is the change a ReleasePlan makes to a resource.
+type ResourceChange string
+
+const (
+	// ResourceAdd creates the resource.
+	ResourceAdd ResourceChange = "add"
+	// ResourceModify updates the resource.
+	ResourceModify ResourceChange = "change"
+	// ResourceDelete deletes the resource.
+	ResourceDelete ResourceChange = "delete"
+)
+
+// PlannedResource is a resource changed by a ReleasePlan.
+type PlannedResource struct {
+	AuditResource
+	Change ResourceChange `json:"change"`
+}
+
+// PlannedHook is a hook run by a ReleasePlan.
+type PlannedHook struct {
+	Name   string   `json:"name"`
+	Kind   string   `json:"kind"`
+	Events []string `json:"events"`
+	Weight int      `json:"weight"`
+}
+
+// ReleasePlan describes what installing or upgrading a release would do,
+// computed without changing the release or the cluster so that it can be
+// reviewed or approved before the operation is run.
+type ReleasePlan struct {
+	Release   string        `json:"release"`
+	Namespace string        `json:"namespace"`
+	Operation PlanOperation `json:"operation"`
+	// CurrentRevision and CurrentChart are the ones of the deployed release
+	// when upgrading.
+	CurrentRevision int    `json:"currentRevision,omitempty"`
+	CurrentChart    string `json:"currentChart,omitempty"`
+	Revision        int    `json:"revision"`
+	Chart           string `json:"chart"`
+	// Resources are the resources of the manifests that would be added,
+	// changed or deleted, ordered by kind, namespace and name. The resources
+	// left unchanged are not listed.
+	Resources []PlannedResource `json:"resources"`
+	// Hooks are the hooks that would be run, in the order they would run.
+	Hooks []PlannedHook `json:"hooks"`
+	// CRDs are the custom resource definitions that would be installed from
+	// the crds directory of the chart, or applied with the manifest.
+	CRDs []string `json:"crds"`
+}
+
+// Changed reports whether the plan changes any resource.
+func (p *ReleasePlan) Changed() bool {
+	return len(p.Resources) > 0
+}
+
+// Plan computes what installing chrt with vals would do, without installing
+// it. The chart is rendered as for a dry run.
+func (i *Install) Plan(chrt *chart.Chart, vals map[string]interface{}) (*ReleasePlan, error) {
+	dryRun := i.DryRun
+	i.DryRun = true
+	defer func() { i.DryRun = dryRun }()
+
+	rel, err := i.Run(chrt, vals, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var crds []string
+	if !i.SkipCRDs {
+		for _, crd := range chrt.CRDObjects() {
+			crds = append(crds, crd.Filename)
+		}
+	}
+	return newReleasePlan(PlanInstall, nil, rel, crds, release.HookPreInstall, release.HookPostInstall)
+}
+
+// Plan computes what upgrading the release name to chart with vals would do,
+// without upgrading it.
+func (u *Upgrade) Plan(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}) (*ReleasePlan, error) {
+	if err := u.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	current, upgraded, err := u.prepareUpgrade(ctx, name, chart, vals, "")
+	if err != nil {
+		return nil, err
+	}
+	return newReleasePlan(PlanUpgrade, current, upgraded, nil, release.HookPreUpgrade, release.HookPostUpgrade)
+}
+
+// newReleasePlan compares the manifests of the current release, nil when
+// installing, and the next one, and lists the hooks of the next release run
+// on the events.
+func newReleasePlan(op PlanOperation, current, next *release.Release, crds []string, events ...release.HookEvent) (*ReleasePlan, error) {
+	plan := &ReleasePlan{
+		Release:   next.Name,
+		Namespace: next.Namespace,
+		Operation: op,
+		Revision:  next.Version,
+		Chart:     chartFullName(next.Chart),
+		Resources: []PlannedResource{},
+		Hooks:     []PlannedHook{},
+		CRDs:      []string{},
+	}
+
+	from := map[resourceKey]manifestObject{}
+	if current != nil {
+		plan.CurrentRevision = current.Version
+		plan.CurrentChart = chartFullName(current.Chart)
+		var err error
+		if from, err = manifestObjects(current.Manifest, current.Namespace); err != nil {
+			return nil, errors.Wrap(err, "parsing the manifest of the current release")
+		}
+	}
+	to, err := manifestObjects(next.Manifest, next.Namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing the manifest of the new release")
+	}
+
+	for key, obj := range to {
+		prev, ok := from[key]
+		switch {
+		case !ok:
+			plan.Resources = append(plan.Resources, PlannedResource{AuditResource: obj.AuditResource, Change: ResourceAdd})
+		case !reflect.DeepEqual(prev.Object, obj.Object):
+			plan.Resources = append(plan.Resources, PlannedResource{AuditResource: obj.AuditResource, Change: ResourceModify})
+		}
+		if key.Kind == "CustomResourceDefinition" {
+			plan.CRDs = append(plan.CRDs, key.Name)
+		}
+	}
+	for key, obj := range from {
+		if _, ok := to[key]; !ok {
+			plan.Resources = append(plan.Resources, PlannedResource{AuditResource: obj.AuditResource, Change: ResourceDelete})
+		}
+	}
+	sort.Slice(plan.Resources, func(i, j int) bool {
+		a, b := plan.Resources[i], plan.Resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.APIVersion < b.APIVersion
+	})
+	plan.CRDs = append(crds, plan.CRDs...)
+	if plan.CRDs == nil {
+		plan.CRDs = []string{}
+	}
+
+	for _, event := range events {
+		var hooks []*release.Hook
+		for _, h := range next.Hooks {
+			for _, e := range h.Events {
+				if e == event {
+					hooks = append(hooks, h)
+					break
+				}
+			}
+		}
+		sort.Stable(hookByWeight(hooks))
+		for _, h := range hooks {
+			plan.Hooks = append(plan.Hooks, PlannedHook{Name: h.Name, Kind: h.Kind, Events: []string{event.String()}, Weight: h.Weight})
+		}
+	}
+	return plan, nil
+}
+
+// resourceKey identifies a resource across releases. Like the kube client
+// does when updating a release, it leaves out the version so that moving a
+// resource to another version of its API group modifies it instead of
+// replacing it.
+type resourceKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// manifestObject is a resource of a manifest and its content.
+type manifestObject struct {
+	AuditResource
+	Object map[string]interface{}
+}
+
+// manifestObjects parses the documents of manifest, giving the resources
+// without a namespace the namespace of the release.
+func manifestObjects(manifest, namespace string) (map[resourceKey]manifestObject, error) {
+	resources, err := releaseutil.ParseManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[resourceKey]manifestObject, len(resources))
+	for _, r := range resources {
+		res := AuditResource{APIVersion: r.APIVersion(), Kind: r.Kind, Name: r.Name, Namespace: namespace}
+		if r.Namespace != "" {
+			res.Namespace = r.Namespace
+		}
+		key := resourceKey{Group: r.Group, Kind: r.Kind, Namespace: res.Namespace, Name: r.Name}
+		objects[key] = manifestObject{AuditResource: res, Object: r.Object}
+	}
+	return objects, nil
+}
+
+// chartFullName returns the name and version of the chart, e.g. "web-1.2.0".
+func chartFullName(c *chart.Chart) string {
+	if c == nil || c.Metadata == nil {
+		return ""
+	}
+	return c.Metadata.Name + "-" + c.Metadata.Version
+}
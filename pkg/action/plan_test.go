@@ -0,0 +1,73 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+func TestNewReleasePlanAPIVersionChange(t *testing.T) {
+	current := &release.Release{
+		Name:      "web",
+		Namespace: "apps",
+		Version:   1,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"}},
+		Manifest: `apiVersion: autoscaling/v2beta2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`,
+	}
+	next := &release.Release{
+		Name:      "web",
+		Namespace: "apps",
+		Version:   2,
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.1.0"}},
+		Manifest: `apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: ConfigMap
+metadata:
+  name: old
+`,
+	}
+
+	plan, err := newReleasePlan(PlanUpgrade, current, next, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []PlannedResource{
+		{AuditResource{APIVersion: "batch/v1", Kind: "ConfigMap", Namespace: "apps", Name: "old"}, ResourceAdd},
+		{AuditResource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "apps", Name: "old"}, ResourceDelete},
+		{AuditResource{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Namespace: "apps", Name: "web"}, ResourceModify},
+	}
+	if !reflect.DeepEqual(plan.Resources, expect) {
+		t.Errorf("expected %v, got %v", expect, plan.Resources)
+	}
+}
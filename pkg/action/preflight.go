@@ -0,0 +1,464 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// The compute resources compared by the preflight checks, named as in
+// ResourceQuotas.
+const (
+	preflightPods           v1.ResourceName = "pods"
+	preflightRequestsCPU    v1.ResourceName = "requests.cpu"
+	preflightRequestsMemory v1.ResourceName = "requests.memory"
+	preflightLimitsCPU      v1.ResourceName = "limits.cpu"
+	preflightLimitsMemory   v1.ResourceName = "limits.memory"
+)
+
+// quotaAliases are the names of the quota resources that are aliases of the
+// preflight resources.
+var quotaAliases = map[v1.ResourceName]v1.ResourceName{
+	v1.ResourceCPU:    preflightRequestsCPU,
+	v1.ResourceMemory: preflightRequestsMemory,
+	"count/pods":      preflightPods,
+}
+
+// PreflightWorkload is a workload of a release, with the compute resources
+// requested by each of its pods.
+type PreflightWorkload struct {
+	Kind     string            `json:"kind"`
+	Name     string            `json:"name"`
+	Replicas int64             `json:"replicas"`
+	PerPod   map[string]string `json:"perPod"`
+}
+
+// PreflightViolation is a compute resource requested by a release beyond
+// what the namespace or the nodes of the cluster can provide.
+type PreflightViolation struct {
+	// Source is the ResourceQuota, "ResourceQuota/name", or the nodes,
+	// "nodes", limiting the resource, or the workload, "Kind/name", whose
+	// pods fit on none of the nodes.
+	Source    string `json:"source"`
+	Resource  string `json:"resource"`
+	Requested string `json:"requested"`
+	Available string `json:"available"`
+}
+
+func (v PreflightViolation) String() string {
+	return fmt.Sprintf("%s: %s requested %s, available %s", v.Source, v.Resource, v.Requested, v.Available)
+}
+
+// PreflightReport is the result of the preflight checks of a release.
+type PreflightReport struct {
+	Namespace string `json:"namespace"`
+	// Workloads are the workloads of the new manifest.
+	Workloads []PreflightWorkload `json:"workloads"`
+	// Requested are the compute resources the new manifest requests beyond
+	// the ones of the current manifest.
+	Requested  map[string]string    `json:"requested"`
+	Violations []PreflightViolation `json:"violations"`
+}
+
+// Failed reports whether the release requests more than is available.
+func (r *PreflightReport) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+// PreflightError is returned by the actions whose preflight checks failed.
+type PreflightError struct {
+	Report *PreflightReport
+}
+
+func (e *PreflightError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "preflight checks failed: the release requests more compute resources than available in namespace %q:", e.Report.Namespace)
+	for _, v := range e.Report.Violations {
+		fmt.Fprintf(&b, "\n  - %s", v)
+	}
+	return b.String()
+}
+
+// Preflight sums the compute resources requested by the workloads of
+// manifest, beyond the ones of the workloads of the current manifest of the
+// release when upgrading, and compares them with the ResourceQuotas of
+// namespace and with the capacity of the nodes, so that a release that
+// cannot be scheduled fails before anything is applied rather than being
+// left with pending pods.
+//
+// The capacity of the nodes is the allocatable resources of the schedulable
+// nodes, less the requests of the pods running on the cluster. Like the
+// ResourceQuotas of the namespace, it is not checked if the user is not
+// allowed to list the nodes or the pods of the cluster.
+func (cfg *Configuration) Preflight(namespace, manifest, currentManifest string) (*PreflightReport, error) {
+	clientset, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.preflight(context.Background(), clientset, namespace, manifest, currentManifest)
+}
+
+func (cfg *Configuration) preflight(ctx context.Context, clientset kubernetes.Interface, namespace, manifest, currentManifest string) (*PreflightReport, error) {
+	var nodes []v1.Node
+	nodeList, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	switch {
+	case apierrors.IsForbidden(err):
+		cfg.Log("preflight: skipping the node capacity checks: %s", err)
+	case err != nil:
+		return nil, errors.Wrap(err, "preflight: listing the nodes")
+	default:
+		for _, n := range nodeList.Items {
+			if !n.Spec.Unschedulable {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	workloads, requested, err := manifestRequests(manifest, len(nodes))
+	if err != nil {
+		return nil, errors.Wrap(err, "preflight: parsing the manifest")
+	}
+	_, current, err := manifestRequests(currentManifest, len(nodes))
+	if err != nil {
+		return nil, errors.Wrap(err, "preflight: parsing the current manifest")
+	}
+	for name, q := range current {
+		r := requested[name]
+		r.Sub(q)
+		requested[name] = r
+	}
+
+	report := &PreflightReport{
+		Namespace:  namespace,
+		Workloads:  []PreflightWorkload{},
+		Requested:  map[string]string{},
+		Violations: []PreflightViolation{},
+	}
+	for name, q := range requested {
+		if q.Sign() > 0 {
+			report.Requested[string(name)] = q.String()
+		}
+	}
+
+	quotas, err := clientset.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	switch {
+	case apierrors.IsForbidden(err):
+		cfg.Log("preflight: skipping the resource quota checks: %s", err)
+	case err != nil && !apierrors.IsNotFound(err):
+		return nil, errors.Wrapf(err, "preflight: listing the resource quotas of namespace %q", namespace)
+	case quotas != nil:
+		for _, quota := range quotas.Items {
+			hards := quota.Status.Hard
+			if len(hards) == 0 {
+				hards = quota.Spec.Hard
+			}
+			for name, hard := range hards {
+				want, ok := requested[preflightResourceName(name)]
+				if !ok || want.Sign() <= 0 {
+					continue
+				}
+				available := hard.DeepCopy()
+				if used, ok := quota.Status.Used[name]; ok {
+					available.Sub(used)
+				}
+				if want.Cmp(available) > 0 {
+					report.Violations = append(report.Violations, PreflightViolation{
+						Source:    "ResourceQuota/" + quota.Name,
+						Resource:  string(name),
+						Requested: want.String(),
+						Available: available.String(),
+					})
+				}
+			}
+		}
+	}
+
+	if len(nodes) > 0 {
+		violations, err := cfg.nodeViolations(ctx, clientset, nodes, workloads, requested)
+		if err != nil {
+			return nil, err
+		}
+		report.Violations = append(report.Violations, violations...)
+	}
+
+	for _, w := range workloads {
+		report.Workloads = append(report.Workloads, w.PreflightWorkload)
+	}
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		return a.Resource < b.Resource
+	})
+	return report, nil
+}
+
+// checkPreflight runs the preflight checks of the manifest of a release,
+// returning a PreflightError if they fail.
+func (cfg *Configuration) checkPreflight(namespace, manifest, currentManifest string) error {
+	report, err := cfg.Preflight(namespace, manifest, currentManifest)
+	if err != nil {
+		return err
+	}
+	if report.Failed() {
+		return &PreflightError{Report: report}
+	}
+	return nil
+}
+
+// nodeViolations checks the requests of the workloads against the capacity
+// of the nodes: each pod has to fit on a node, and all of them on the free
+// capacity of the nodes.
+func (cfg *Configuration) nodeViolations(ctx context.Context, clientset kubernetes.Interface, nodes []v1.Node, workloads []preflightWorkload, requested v1.ResourceList) ([]PreflightViolation, error) {
+	var violations []PreflightViolation
+	for _, w := range workloads {
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			want := w.perPod[preflightResourceName(name)]
+			if want.IsZero() {
+				continue
+			}
+			var largest resource.Quantity
+			fits := false
+			for _, n := range nodes {
+				allocatable := n.Status.Allocatable[name]
+				if allocatable.Cmp(largest) > 0 {
+					largest = allocatable
+				}
+				fits = fits || want.Cmp(allocatable) <= 0
+			}
+			if !fits {
+				violations = append(violations, PreflightViolation{
+					Source:    w.Kind + "/" + w.Name,
+					Resource:  "requests." + string(name),
+					Requested: want.String(),
+					Available: largest.String(),
+				})
+			}
+		}
+	}
+
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if apierrors.IsForbidden(err) {
+		cfg.Log("preflight: skipping the free node capacity check: %s", err)
+		return violations, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "preflight: listing the pods")
+	}
+	scheduled := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		scheduled[n.Name] = true
+	}
+	free := v1.ResourceList{}
+	for _, n := range nodes {
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			q := free[name]
+			q.Add(n.Status.Allocatable[name])
+			free[name] = q
+		}
+	}
+	for _, p := range pods.Items {
+		if !scheduled[p.Spec.NodeName] {
+			continue
+		}
+		used := podRequests(&p.Spec)
+		for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			q := free[name]
+			q.Sub(used[preflightResourceName(name)])
+			free[name] = q
+		}
+	}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		want := requested[preflightResourceName(name)]
+		if available := free[name]; want.Sign() > 0 && want.Cmp(available) > 0 {
+			violations = append(violations, PreflightViolation{
+				Source:    "nodes",
+				Resource:  "requests." + string(name),
+				Requested: want.String(),
+				Available: available.String(),
+			})
+		}
+	}
+	return violations, nil
+}
+
+type preflightWorkload struct {
+	PreflightWorkload
+	perPod v1.ResourceList
+}
+
+// manifestRequests returns the workloads of manifest, and the compute
+// resources requested by all their pods. DaemonSets are counted as running
+// a pod on each of the nodes, or on one node if the nodes are not known.
+func manifestRequests(manifest string, nodes int) ([]preflightWorkload, v1.ResourceList, error) {
+	var workloads []preflightWorkload
+	total := v1.ResourceList{}
+	for _, doc := range releaseutil.SplitManifests(manifest) {
+		var meta metav1.TypeMeta
+		if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+			return nil, nil, err
+		}
+		var (
+			obj      metav1.Object
+			replicas *int32
+			spec     *v1.PodSpec
+			err      error
+		)
+		switch meta.Kind {
+		case "Pod":
+			var o v1.Pod
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, spec = &o, &o.Spec
+		case "Deployment":
+			var o appsv1.Deployment
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas, spec = &o, o.Spec.Replicas, &o.Spec.Template.Spec
+		case "StatefulSet":
+			var o appsv1.StatefulSet
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas, spec = &o, o.Spec.Replicas, &o.Spec.Template.Spec
+		case "ReplicaSet":
+			var o appsv1.ReplicaSet
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas, spec = &o, o.Spec.Replicas, &o.Spec.Template.Spec
+		case "ReplicationController":
+			var o v1.ReplicationController
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas = &o, o.Spec.Replicas
+			if o.Spec.Template != nil {
+				spec = &o.Spec.Template.Spec
+			}
+		case "DaemonSet":
+			var o appsv1.DaemonSet
+			err = yaml.Unmarshal([]byte(doc), &o)
+			n := int32(nodes)
+			if n == 0 {
+				n = 1
+			}
+			obj, replicas, spec = &o, &n, &o.Spec.Template.Spec
+		case "Job":
+			var o batchv1.Job
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas, spec = &o, o.Spec.Parallelism, &o.Spec.Template.Spec
+		case "CronJob":
+			var o batchv1.CronJob
+			err = yaml.Unmarshal([]byte(doc), &o)
+			obj, replicas, spec = &o, o.Spec.JobTemplate.Spec.Parallelism, &o.Spec.JobTemplate.Spec.Template.Spec
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "parsing %s", meta.Kind)
+		}
+		if spec == nil {
+			continue
+		}
+
+		count := int64(1)
+		if replicas != nil {
+			count = int64(*replicas)
+		}
+		perPod := podRequests(spec)
+		perPod[preflightPods] = *resource.NewQuantity(1, resource.DecimalSI)
+		w := preflightWorkload{
+			PreflightWorkload: PreflightWorkload{Kind: meta.Kind, Name: obj.GetName(), Replicas: count, PerPod: map[string]string{}},
+			perPod:            perPod,
+		}
+		for name, q := range perPod {
+			w.PerPod[string(name)] = q.String()
+			t := total[name]
+			t.Add(mulQuantity(q, count))
+			total[name] = t
+		}
+		workloads = append(workloads, w)
+	}
+	return workloads, total, nil
+}
+
+// mulQuantity returns q multiplied by n.
+func mulQuantity(q resource.Quantity, n int64) resource.Quantity {
+	if milli := q.MilliValue(); milli%1000 != 0 {
+		return *resource.NewMilliQuantity(milli*n, q.Format)
+	}
+	return *resource.NewQuantity(q.Value()*n, q.Format)
+}
+
+// podRequests returns the compute resources requested by a pod, named as in
+// ResourceQuotas: the requests and limits of its containers, or of its
+// largest init container if they are larger. A container setting a limit
+// without a request requests its limit.
+func podRequests(spec *v1.PodSpec) v1.ResourceList {
+	sum := v1.ResourceList{}
+	for _, c := range spec.Containers {
+		for name, q := range containerRequests(c) {
+			t := sum[name]
+			t.Add(q)
+			sum[name] = t
+		}
+	}
+	for _, c := range spec.InitContainers {
+		for name, q := range containerRequests(c) {
+			if t := sum[name]; q.Cmp(t) > 0 {
+				sum[name] = q
+			}
+		}
+	}
+	return sum
+}
+
+func containerRequests(c v1.Container) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, name := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+		limit, hasLimit := c.Resources.Limits[name]
+		if hasLimit {
+			requests["limits."+name] = limit
+		}
+		if request, ok := c.Resources.Requests[name]; ok {
+			requests["requests."+name] = request
+		} else if hasLimit {
+			requests["requests."+name] = limit
+		}
+	}
+	return requests
+}
+
+// preflightResourceName returns the preflight resource a quota resource is
+// an alias of, or name itself.
+func preflightResourceName(name v1.ResourceName) v1.ResourceName {
+	if alias, ok := quotaAliases[name]; ok {
+		return alias
+	}
+	return name
+}
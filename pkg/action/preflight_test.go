@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const preflightManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        resources:
+          requests:
+            cpu: 250m
+            memory: 512Mi
+`
+
+func TestManifestRequests(t *testing.T) {
+	workloads, total, err := manifestRequests(preflightManifest, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := PreflightWorkload{
+		Kind:     "Deployment",
+		Name:     "web",
+		Replicas: 3,
+		PerPod:   map[string]string{"pods": "1", "requests.cpu": "250m", "requests.memory": "512Mi"},
+	}
+	if len(workloads) != 1 || !reflect.DeepEqual(workloads[0].PreflightWorkload, expect) {
+		t.Errorf("expected the workload %v, got %v", expect, workloads)
+	}
+	for name, want := range map[v1.ResourceName]string{"pods": "3", "requests.cpu": "750m", "requests.memory": "1536Mi"} {
+		if q := total[name]; q.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("expected %s to total %s, got %s", name, want, q.String())
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse("2"),
+			v1.ResourceMemory: resource.MustParse("1Gi"),
+		}},
+	}
+	quota := &v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "apps"},
+		Status: v1.ResourceQuotaStatus{
+			Hard: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("1")},
+			Used: v1.ResourceList{v1.ResourceRequestsCPU: resource.MustParse("500m")},
+		},
+	}
+	cfg := &Configuration{Log: t.Logf}
+
+	clientset := fakeclientset.NewSimpleClientset(node, quota)
+	report, err := cfg.preflight(context.Background(), clientset, "apps", preflightManifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []PreflightViolation{
+		{Source: "ResourceQuota/compute", Resource: "requests.cpu", Requested: "750m", Available: "500m"},
+		{Source: "nodes", Resource: "requests.memory", Requested: "1536Mi", Available: "1Gi"},
+	}
+	if !reflect.DeepEqual(report.Violations, expect) {
+		t.Errorf("expected the violations %v, got %v", expect, report.Violations)
+	}
+
+	// Only the new requests of an upgrade are checked.
+	report, err = cfg.preflight(context.Background(), clientset, "apps", preflightManifest, preflightManifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed() {
+		t.Errorf("expected an unchanged manifest to pass, got %v", report.Violations)
+	}
+
+	// The checks the user is not allowed to make are skipped.
+	clientset = fakeclientset.NewSimpleClientset(node, quota)
+	clientset.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gr := schema.GroupResource{Resource: action.GetResource().Resource}
+		return true, nil, apierrors.NewForbidden(gr, "", nil)
+	})
+	report, err = cfg.preflight(context.Background(), clientset, "apps", preflightManifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed() {
+		t.Errorf("expected the forbidden checks to be skipped, got %v", report.Violations)
+	}
+}
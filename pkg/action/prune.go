@@ -0,0 +1,174 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// resourceIdentity returns the identity of a resource.
+func resourceIdentity(info *resource.Info) release.ResourceIdentity {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	return release.ResourceIdentity{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+	}
+}
+
+// resourceIdentities returns the identities of the resources of the release
+// and of the resources of its hooks.
+func (cfg *Configuration) resourceIdentities(rel *release.Release, resources kube.ResourceList) []release.ResourceIdentity {
+	ids := make([]release.ResourceIdentity, 0, len(resources))
+	for _, info := range resources {
+		ids = append(ids, resourceIdentity(info))
+	}
+	for _, h := range rel.Hooks {
+		hookResources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+		if err != nil {
+			cfg.Log("unable to build the resources of hook %s: %s", h.Path, err)
+			continue
+		}
+		for _, info := range hookResources {
+			ids = append(ids, resourceIdentity(info))
+		}
+	}
+	return ids
+}
+
+// appliedResources returns the resources applied by the release. They are
+// computed from its manifest and hooks for releases that did not record them.
+func (cfg *Configuration) appliedResources(rel *release.Release) []release.ResourceIdentity {
+	if rel.Info.Resources != nil {
+		return rel.Info.Resources
+	}
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		cfg.Log("unable to build the resources of release %s revision %d: %s", rel.Name, rel.Version, err)
+	}
+	return cfg.resourceIdentities(rel, resources)
+}
+
+// pruneCandidates returns the resources applied by the previous revision
+// that are neither applied by the new one nor part of the manifest of the
+// previous revision, whose resources the upgrade deletes itself.
+func pruneCandidates(previous, next []release.ResourceIdentity, manifest kube.ResourceList) []release.ResourceIdentity {
+	keep := make(map[string]bool, len(next)+len(manifest))
+	for _, id := range next {
+		keep[id.Key()] = true
+	}
+	for _, info := range manifest {
+		keep[resourceIdentity(info).Key()] = true
+	}
+	var candidates []release.ResourceIdentity
+	for _, id := range previous {
+		if !keep[id.Key()] {
+			keep[id.Key()] = true
+			candidates = append(candidates, id)
+		}
+	}
+	return candidates
+}
+
+// pruneTargets returns the candidates that still exist, unless the resource
+// policy keeps them or they belong to another release.
+func (cfg *Configuration) pruneTargets(rel *release.Release, candidates []release.ResourceIdentity) (kube.ResourceList, error) {
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	var manifest bytes.Buffer
+	for _, id := range candidates {
+		doc, err := yaml.Marshal(map[string]interface{}{
+			"apiVersion": id.APIVersion,
+			"kind":       id.Kind,
+			"metadata": map[string]interface{}{
+				"name":      id.Name,
+				"namespace": id.Namespace,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest.WriteString("---\n")
+		manifest.Write(doc)
+	}
+	resources, err := cfg.KubeClient.Build(&manifest, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build the resources to prune")
+	}
+
+	var targets kube.ResourceList
+	for _, info := range resources {
+		if err := info.Get(); err != nil {
+			if !apierrors.IsNotFound(err) {
+				cfg.Log("unable to get %s %s: %s", info.Mapping.GroupVersionKind.Kind, info.ObjectName(), err)
+			}
+			continue
+		}
+		annotations, err := accessor.Annotations(info.Object)
+		if err != nil {
+			cfg.Log("unable to get the annotations of %s: %s", info.ObjectName(), err)
+			continue
+		}
+		if annotations[kube.ResourcePolicyAnno] == kube.KeepPolicy {
+			cfg.Log("skipping prune of %s due to annotation [%s=%s]", info.ObjectName(), kube.ResourcePolicyAnno, kube.KeepPolicy)
+			continue
+		}
+		if owner, ok := annotations[helmReleaseNameAnnotation]; ok && owner != rel.Name {
+			cfg.Log("skipping prune of %s owned by release %s", info.ObjectName(), owner)
+			continue
+		}
+		targets = append(targets, info)
+	}
+	return targets, nil
+}
+
+// prune deletes the targets of the candidates, as returned by pruneTargets.
+// It returns the deleted resources.
+func (cfg *Configuration) prune(rel *release.Release, candidates []release.ResourceIdentity) ([]release.ResourceIdentity, error) {
+	toDelete, err := cfg.pruneTargets(rel, candidates)
+	if err != nil || len(toDelete) == 0 {
+		return nil, err
+	}
+
+	cfg.Log("pruning %d resource(s)", len(toDelete))
+	result, errs := cfg.KubeClient.Delete(toDelete)
+	var pruned []release.ResourceIdentity
+	if result != nil {
+		for _, info := range result.Deleted {
+			pruned = append(pruned, resourceIdentity(info))
+		}
+	}
+	if len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return pruned, errors.Errorf("unable to prune resources: %s", strings.Join(msgs, "; "))
+	}
+	return pruned, nil
+}
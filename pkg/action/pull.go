@@ -17,20 +17,26 @@ limitations under the License.
 package action
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/cli"
 	"github.com/open-hand/helm/pkg/downloader"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/registry"
 	"github.com/open-hand/helm/pkg/repo"
+	helmtime "github.com/open-hand/helm/pkg/time"
 )
 
 // Pull is the action for checking a given release's information.
@@ -44,9 +50,46 @@ type Pull struct {
 	Devel       bool
 	Untar       bool
 	VerifyLater bool
-	UntarDir    string
-	DestDir     string
-	cfg         *Configuration
+	// Metadata unpacks the chart like Untar once its digest is verified
+	// against the repository index and its provenance, if it has one, is
+	// verified, and writes a PullMetadata file next to the chart directory.
+	Metadata bool
+	UntarDir string
+	DestDir  string
+	cfg      *Configuration
+}
+
+// PullMetadataFileSuffix is appended to the name of a chart to name the
+// metadata file written next to it by Pull.Metadata.
+const PullMetadataFileSuffix = ".pull.json"
+
+// PullMetadata describes where a pulled chart comes from, for the build
+// systems tracing the charts they use.
+type PullMetadata struct {
+	Chart      string `json:"chart"`
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion,omitempty"`
+	// Source is the chart reference given to pull.
+	Source string `json:"source"`
+	// Repository is the URL of the repository or registry of the chart.
+	Repository string `json:"repository,omitempty"`
+	// URL is the URL the chart archive was downloaded from.
+	URL string `json:"url"`
+	// Digest is the SHA-256 digest of the chart archive.
+	Digest string `json:"digest"`
+	// DigestVerified reports whether the digest matched the repository
+	// index.
+	DigestVerified bool `json:"digestVerified"`
+	// Provenance is the verification of the provenance of the chart, nil if
+	// the chart has no provenance file.
+	Provenance *PullProvenance `json:"provenance,omitempty"`
+	PulledAt   helmtime.Time   `json:"pulledAt"`
+}
+
+// PullProvenance is the verification of the provenance of a pulled chart.
+type PullProvenance struct {
+	SignedBy    []string `json:"signedBy"`
+	Fingerprint string   `json:"fingerprint"`
 }
 
 type PullOpt func(*Pull)
@@ -74,6 +117,15 @@ func NewPullWithOpts(opts ...PullOpt) *Pull {
 
 // Run executes 'helm pull' against the given release.
 func (p *Pull) Run(chartRef string) (string, error) {
+	start := time.Now()
+	out, err := p.run(chartRef)
+	if p.cfg != nil && p.cfg.Metrics != nil && !p.isLocalChart(chartRef) {
+		p.cfg.Metrics.ObserveChartDownload(chartRef, time.Since(start), err)
+	}
+	return out, err
+}
+
+func (p *Pull) run(chartRef string) (string, error) {
 	var out strings.Builder
 
 	c := downloader.ChartDownloader{
@@ -86,10 +138,12 @@ func (p *Pull) Run(chartRef string) (string, error) {
 			getter.WithPassCredentialsAll(p.PassCredentialsAll),
 			getter.WithTLSClientConfig(p.CertFile, p.KeyFile, p.CaFile),
 			getter.WithInsecureSkipVerifyTLS(p.InsecureSkipTLSverify),
+			getter.WithTracerProvider(p.cfg.TracerProvider),
 		},
 		RegistryClient:   p.cfg.RegistryClient,
 		RepositoryConfig: p.Settings.RepositoryConfig,
 		RepositoryCache:  p.Settings.RepositoryCache,
+		Channel:          p.Channel,
 	}
 
 	if registry.IsOCI(chartRef) {
@@ -97,16 +151,24 @@ func (p *Pull) Run(chartRef string) (string, error) {
 			getter.WithRegistryClient(p.cfg.RegistryClient))
 	}
 
+	if err := p.applySignaturePolicy(&c, p.cfg.SignaturePolicy); err != nil {
+		return out.String(), err
+	}
+
 	if p.Verify {
 		c.Verify = downloader.VerifyAlways
 	} else if p.VerifyLater {
 		c.Verify = downloader.VerifyLater
+	} else if p.Metadata {
+		c.Verify = downloader.VerifyIfPossible
 	}
+	c.VerifyDigest = p.Metadata
+	untar := p.Untar || p.Metadata
 
 	// If untar is set, we fetch to a tempdir, then untar and copy after
 	// verification.
 	dest := p.DestDir
-	if p.Untar {
+	if untar {
 		var err error
 		dest, err = ioutil.TempDir("", "helm-")
 		if err != nil {
@@ -115,18 +177,27 @@ func (p *Pull) Run(chartRef string) (string, error) {
 		defer os.RemoveAll(dest)
 	}
 
-	if p.RepoURL != "" {
-		chartURL, err := repo.FindChartInAuthRepoURL(p.RepoURL, p.Username, p.Password, chartRef, p.Version, p.CertFile, p.KeyFile, p.CaFile, getter.All(p.Settings))
+	source := chartRef
+	repoURL := p.Settings.ResolveRepo(p.RepoURL)
+	if e := configuredRepo(repoURL, p.Settings); e != nil {
+		// A configured repository given by name or alias is looked up
+		// like a "repo/chart" reference, with its own credentials.
+		chartRef = path.Join(e.Name, chartRef)
+		repoURL = ""
+	}
+	if repoURL != "" {
+		chartURL, err := repo.FindChartInChannelRepoURL(repoURL, p.Username, p.Password, chartRef, p.Version, p.Channel, p.CertFile, p.KeyFile, p.CaFile, getter.All(p.Settings))
 		if err != nil {
 			return out.String(), err
 		}
 		chartRef = chartURL
 	}
 
-	saved, v, err := c.DownloadTo(chartRef, p.Version, dest)
+	d, err := c.Download(chartRef, p.Version, dest)
 	if err != nil {
 		return out.String(), err
 	}
+	saved, v := d.Path, d.Verification
 
 	if p.Verify {
 		for name := range v.SignedBy.Identities {
@@ -137,7 +208,7 @@ func (p *Pull) Run(chartRef string) (string, error) {
 	}
 
 	// After verification, untar the chart into the requested directory.
-	if p.Untar {
+	if untar {
 		ud := p.UntarDir
 		if !filepath.IsAbs(ud) {
 			ud = filepath.Join(p.DestDir, ud)
@@ -160,7 +231,72 @@ func (p *Pull) Run(chartRef string) (string, error) {
 			return out.String(), errors.Errorf("failed to untar: a file or directory with the name %s already exists", udCheck)
 		}
 
-		return out.String(), chartutil.ExpandFile(ud, saved)
+		if err := chartutil.ExpandFile(ud, saved); err != nil {
+			return out.String(), err
+		}
+		if p.Metadata {
+			if err := p.writeMetadata(ud, source, d); err != nil {
+				return out.String(), err
+			}
+		}
 	}
 	return out.String(), nil
 }
+
+// writeMetadata writes the metadata of the chart downloaded from source
+// next to the chart directory in dir.
+func (p *Pull) writeMetadata(dir, source string, d *downloader.Download) error {
+	chrt, err := loader.Load(d.Path)
+	if err != nil {
+		return err
+	}
+	m := &PullMetadata{
+		Chart:          chrt.Metadata.Name,
+		Version:        chrt.Metadata.Version,
+		AppVersion:     chrt.Metadata.AppVersion,
+		Source:         source,
+		Repository:     p.pullRepository(source),
+		URL:            d.URL,
+		Digest:         "sha256:" + d.Digest,
+		DigestVerified: d.DigestVerified,
+		PulledAt:       p.cfg.Now(),
+	}
+	if v := d.Verification; v != nil && v.SignedBy != nil {
+		m.Provenance = &PullProvenance{Fingerprint: fmt.Sprintf("%X", v.SignedBy.PrimaryKey.Fingerprint)}
+		for name := range v.SignedBy.Identities {
+			m.Provenance.SignedBy = append(m.Provenance.SignedBy, name)
+		}
+		sort.Strings(m.Provenance.SignedBy)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, m.Chart+PullMetadataFileSuffix)
+	return errors.Wrap(ioutil.WriteFile(path, append(data, '\n'), 0644), "failed to write the pull metadata")
+}
+
+// pullRepository returns the URL of the repository or registry of the chart
+// reference, or "" if it is not known.
+func (p *Pull) pullRepository(chartRef string) string {
+	switch {
+	case p.RepoURL != "":
+		if e := configuredRepo(p.Settings.ResolveRepo(p.RepoURL), p.Settings); e != nil {
+			return e.URL
+		}
+		return p.Settings.ResolveRepo(p.RepoURL)
+	case registry.IsOCI(chartRef):
+		return chartRef
+	case strings.Contains(chartRef, "://"):
+		return ""
+	}
+	rf, err := repo.LoadFile(p.Settings.RepositoryConfig)
+	if err != nil {
+		return ""
+	}
+	if e := rf.Get(strings.TrimPrefix(strings.SplitN(chartRef, "/", 2)[0], "@")); e != nil {
+		return e.URL
+	}
+	return ""
+}
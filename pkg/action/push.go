@@ -17,11 +17,17 @@ limitations under the License.
 package action
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/open-hand/helm/pkg/cli"
 	"github.com/open-hand/helm/pkg/pusher"
 	"github.com/open-hand/helm/pkg/registry"
+	"github.com/open-hand/helm/pkg/repo"
+	"github.com/open-hand/helm/pkg/sbom"
 	"github.com/open-hand/helm/pkg/uploader"
 )
 
@@ -30,7 +36,20 @@ import (
 // It provides the implementation of 'helm push'.
 type Push struct {
 	Settings *cli.EnvSettings
-	cfg      *Configuration
+	// SBOM is the format of the software bill of materials to generate and
+	// attach to a chart pushed to an OCI registry, if none is stored next to
+	// the chart archive.
+	SBOM string
+	// Username, Password and the TLS settings are the ones used to push to
+	// a chart repository served over HTTP. They default to the ones of the
+	// repository in the repositories file.
+	Username              string
+	Password              string
+	CertFile              string
+	KeyFile               string
+	CaFile                string
+	InsecureSkipTLSverify bool
+	cfg                   *Configuration
 }
 
 // PushOpt is a type of function that sets options for a push action.
@@ -64,7 +83,63 @@ func (p *Push) Run(chartRef string, remote string) (string, error) {
 
 	if registry.IsOCI(remote) {
 		c.Options = append(c.Options, pusher.WithRegistryClient(p.cfg.RegistryClient))
+	} else {
+		entry, err := p.repositoryEntry(remote)
+		if err != nil {
+			return "", err
+		}
+		remote = entry.URL
+		username, password, err := entry.Credentials()
+		if err != nil {
+			return "", err
+		}
+		c.Options = append(c.Options,
+			pusher.WithBasicAuth(username, password),
+			pusher.WithTLSClientConfig(entry.CertFile, entry.KeyFile, entry.CAFile),
+			pusher.WithInsecureSkipVerifyTLS(entry.InsecureSkipTLSverify),
+		)
+	}
+	if p.SBOM != "" {
+		format, err := sbom.ParseFormat(p.SBOM)
+		if err != nil {
+			return "", err
+		}
+		c.Options = append(c.Options, pusher.WithSBOMFormat(format))
+	}
+
+	if err := c.UploadTo(chartRef, remote); err != nil {
+		return out.String(), err
+	}
+	if !registry.IsOCI(remote) {
+		fmt.Fprintf(&out, "Pushed: %s to %s\n", filepath.Base(chartRef), remote)
 	}
+	return out.String(), nil
+}
 
-	return out.String(), c.UploadTo(chartRef, remote)
+// repositoryEntry returns the chart repository remote, a URL or the name of
+// a repository of the repositories file, with the credentials and TLS
+// settings of the repositories file overridden by the ones of the push.
+func (p *Push) repositoryEntry(remote string) (*repo.Entry, error) {
+	entry := &repo.Entry{URL: remote}
+	if rf, err := repo.LoadFile(p.Settings.RepositoryConfig); err == nil {
+		for _, e := range rf.Repositories {
+			if e.Name == remote || strings.TrimSuffix(e.URL, "/") == strings.TrimSuffix(remote, "/") {
+				c := *e
+				entry = &c
+				break
+			}
+		}
+	}
+	if !strings.Contains(entry.URL, "://") {
+		return nil, errors.Errorf("no repository named %q found, and scheme prefix missing from remote (e.g. \"https://\" or \"%s://\")", remote, registry.OCIScheme)
+	}
+
+	if p.Username != "" || p.Password != "" {
+		entry.Username, entry.Password = p.Username, p.Password
+	}
+	if p.CertFile != "" || p.KeyFile != "" || p.CaFile != "" {
+		entry.CertFile, entry.KeyFile, entry.CAFile = p.CertFile, p.KeyFile, p.CaFile
+	}
+	entry.InsecureSkipTLSverify = entry.InsecureSkipTLSverify || p.InsecureSkipTLSverify
+	return entry, nil
 }
@@ -0,0 +1,72 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// ReadOnlyError is returned by the actions that would change the cluster or
+// the release records while the configuration is ReadOnly.
+type ReadOnlyError struct {
+	// Operation is the refused action, such as "install", or the refused
+	// operation of the release storage, such as "create release record".
+	Operation string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s refused: the configuration is read-only", e.Operation)
+}
+
+// IsReadOnly reports whether err is, or wraps, a ReadOnlyError, or a
+// kube.ReadOnlyError of the Kubernetes clients of a read-only configuration.
+func IsReadOnly(err error) bool {
+	var readOnly *ReadOnlyError
+	return errors.As(err, &readOnly) || kube.IsReadOnly(err)
+}
+
+// checkWritable returns a ReadOnlyError for operation if the configuration
+// is read-only.
+func (cfg *Configuration) checkWritable(operation string) error {
+	if cfg.ReadOnly {
+		return &ReadOnlyError{Operation: operation}
+	}
+	return nil
+}
+
+// readOnlyDriver is a storage driver refusing to change the release records.
+type readOnlyDriver struct {
+	driver.Driver
+}
+
+func (d readOnlyDriver) Create(key string, rls *release.Release) error {
+	return &ReadOnlyError{Operation: "create release record " + key}
+}
+
+func (d readOnlyDriver) Update(key string, rls *release.Release) error {
+	return &ReadOnlyError{Operation: "update release record " + key}
+}
+
+func (d readOnlyDriver) Delete(key string) (*release.Release, error) {
+	return nil, &ReadOnlyError{Operation: "delete release record " + key}
+}
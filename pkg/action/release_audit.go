@@ -0,0 +1,218 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/kube"
+)
+
+// ReleaseAudit is the action for comparing the resources recorded in a
+// release with what exists in the cluster.
+//
+// It provides the implementation of 'helm release audit'.
+type ReleaseAudit struct {
+	cfg *Configuration
+
+	// Version is the revision to audit. Zero audits the latest revision.
+	Version int
+}
+
+// AuditResource identifies a resource reported by a ReleaseAudit.
+type AuditResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// ModifiedResource is a resource of a release whose live state differs from
+// its manifest.
+type ModifiedResource struct {
+	AuditResource
+	Fields []kube.FieldDrift `json:"fields"`
+}
+
+// AuditReport is the result of a ReleaseAudit.
+type AuditReport struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	Revision  int    `json:"revision"`
+	// Missing are resources of the manifest that do not exist in the cluster.
+	Missing []AuditResource `json:"missing"`
+	// Modified are resources whose live state differs from the manifest.
+	Modified []ModifiedResource `json:"modified"`
+	// Orphaned are resources annotated as belonging to the release that
+	// are not part of its manifest. Only the kinds of resources found in the
+	// manifest are searched.
+	Orphaned []AuditResource `json:"orphaned"`
+}
+
+// Drifted reports whether the audit found any difference between the
+// release and the cluster.
+func (r *AuditReport) Drifted() bool {
+	return len(r.Missing) > 0 || len(r.Modified) > 0 || len(r.Orphaned) > 0
+}
+
+// NewReleaseAudit creates a new ReleaseAudit object with the given configuration.
+func NewReleaseAudit(cfg *Configuration) *ReleaseAudit {
+	return &ReleaseAudit{cfg: cfg}
+}
+
+// Run audits the named release.
+func (a *ReleaseAudit) Run(name string) (*AuditReport, error) {
+	if err := a.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	rel, err := a.cfg.releaseContent(name, a.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := a.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+
+	report := &AuditReport{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Missing:   []AuditResource{},
+		Modified:  []ModifiedResource{},
+		Orphaned:  []AuditResource{},
+	}
+
+	inManifest := make(map[AuditResource]bool)
+	kinds := make(map[schema.GroupVersionKind]*resource.Info)
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		res := auditResource(info.Mapping.GroupVersionKind, info.Namespace, info.Name)
+		inManifest[res] = true
+		if _, ok := kinds[info.Mapping.GroupVersionKind]; !ok {
+			kinds[info.Mapping.GroupVersionKind] = info
+		}
+
+		live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if apierrors.IsNotFound(err) {
+			report.Missing = append(report.Missing, res)
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not get information about the resource %s", resourceString(info))
+		}
+
+		desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return err
+		}
+		current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+		if err != nil {
+			return err
+		}
+		if drift := kube.Drift(desired, current); len(drift) > 0 {
+			report.Modified = append(report.Modified, ModifiedResource{AuditResource: res, Fields: drift})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for gvk, info := range kinds {
+		orphans, err := a.orphans(info, rel.Name, rel.Namespace, inManifest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list %s resources", gvk.Kind)
+		}
+		report.Orphaned = append(report.Orphaned, orphans...)
+	}
+	sort.Slice(report.Orphaned, func(i, j int) bool {
+		a, b := report.Orphaned[i], report.Orphaned[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// orphans lists the resources of the kind of info that are owned by the
+// release but not part of its manifest. Resources created by a controller,
+// such as the pods of a deployment, are not reported.
+func (a *ReleaseAudit) orphans(info *resource.Info, name, namespace string, inManifest map[AuditResource]bool) ([]AuditResource, error) {
+	ns := ""
+	if info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ns = info.Namespace
+	}
+	list, err := resource.NewHelper(info.Client, info.Mapping).List(ns, info.Mapping.GroupVersionKind.GroupVersion().String(), &metav1.ListOptions{
+		LabelSelector: appManagedByLabel + "=" + appManagedByHelm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []AuditResource
+	for _, item := range items {
+		obj, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		res := auditResource(info.Mapping.GroupVersionKind, obj.GetNamespace(), obj.GetName())
+		if inManifest[res] || metav1.GetControllerOf(obj) != nil {
+			continue
+		}
+		if checkOwnership(item, name, namespace) != nil {
+			continue
+		}
+		orphans = append(orphans, res)
+	}
+	return orphans, nil
+}
+
+func auditResource(gvk schema.GroupVersionKind, namespace, name string) AuditResource {
+	return AuditResource{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  namespace,
+		Name:       name,
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// ReleaseExport is the action for exporting the full history of a release
+// as a bundle, see releaseutil.WriteBundle.
+//
+// It provides the implementation of 'helm release export'.
+type ReleaseExport struct {
+	cfg *Configuration
+}
+
+// NewReleaseExport creates a new ReleaseExport object with the given configuration.
+func NewReleaseExport(cfg *Configuration) *ReleaseExport {
+	return &ReleaseExport{cfg: cfg}
+}
+
+// Run writes the history of the named release to out.
+func (e *ReleaseExport) Run(name string, out io.Writer) error {
+	if err := e.cfg.KubeClient.IsReachable(); err != nil {
+		return err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return errors.Errorf("release name is invalid: %s", name)
+	}
+
+	h, err := e.cfg.Releases.History(name)
+	if err != nil {
+		return err
+	}
+	if len(h) == 0 {
+		return driver.ErrReleaseNotFound
+	}
+	return releaseutil.WriteBundle(out, h)
+}
+
+// ReleaseImport is the action for restoring a release from a bundle written
+// by ReleaseExport, possibly into another cluster, namespace or storage
+// backend.
+//
+// It provides the implementation of 'helm release import'.
+type ReleaseImport struct {
+	cfg *Configuration
+
+	// Namespace, if set, is the namespace the release is restored into.
+	// Otherwise the namespace recorded in the bundle is kept.
+	Namespace string
+	// Replace replaces the history of an existing release of the same name
+	// once the bundle is imported. Without it, importing over an existing
+	// release fails.
+	Replace bool
+}
+
+// NewReleaseImport creates a new ReleaseImport object with the given configuration.
+func NewReleaseImport(cfg *Configuration) *ReleaseImport {
+	return &ReleaseImport{cfg: cfg}
+}
+
+// Run reads a bundle from in and stores every revision it holds. It returns
+// the imported revisions, oldest first.
+//
+// Only the release records are restored; the resources of the release are
+// expected to already exist in the target cluster, or to be created by a
+// subsequent upgrade.
+func (i *ReleaseImport) Run(in io.Reader) ([]*release.Release, error) {
+	if err := i.cfg.checkWritable("import"); err != nil {
+		return nil, err
+	}
+	if err := i.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	history, err := releaseutil.ReadBundle(in)
+	if err != nil {
+		return nil, err
+	}
+	name := history[0].Name
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	existing, err := i.cfg.Releases.History(name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, err
+	}
+	if len(existing) > 0 && !i.Replace {
+		return nil, errors.Errorf("release %q already exists, use replace to overwrite its history", name)
+	}
+	previous := make(map[int]*release.Release, len(existing))
+	for _, rls := range existing {
+		previous[rls.Version] = rls
+	}
+
+	// Store the imported history before touching the previous one, so that
+	// a failed import leaves the existing release as it was.
+	imported := make(map[int]bool, len(history))
+	for n, rls := range history {
+		if i.Namespace != "" {
+			rls.Namespace = i.Namespace
+		}
+		if _, ok := previous[rls.Version]; ok {
+			err = i.cfg.Releases.Update(rls)
+		} else {
+			err = i.cfg.Releases.Create(rls)
+		}
+		if err != nil {
+			i.restore(history[:n], previous)
+			return nil, errors.Wrapf(err, "failed to import revision %d of release %q", rls.Version, name)
+		}
+		imported[rls.Version] = true
+	}
+
+	// Only then drop the revisions of the previous history the bundle
+	// does not have.
+	for _, rls := range existing {
+		if imported[rls.Version] {
+			continue
+		}
+		if _, err := i.cfg.Releases.Delete(rls.Name, rls.Version); err != nil {
+			return history, errors.Wrapf(err, "failed to delete revision %d of the replaced release %q", rls.Version, name)
+		}
+	}
+	return history, nil
+}
+
+// restore undoes the import of the given revisions after a failure: the
+// revisions that replaced a previous one are restored and the others are
+// deleted, so that no partial history is left behind.
+func (i *ReleaseImport) restore(imported []*release.Release, previous map[int]*release.Release) {
+	for _, rls := range imported {
+		var err error
+		if old, ok := previous[rls.Version]; ok {
+			err = i.cfg.Releases.Update(old)
+		} else {
+			_, err = i.cfg.Releases.Delete(rls.Name, rls.Version)
+		}
+		if err != nil {
+			i.cfg.Log("failed to clean up revision %d of release %q: %s", rls.Version, rls.Name, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// maxRebuildAttempts bounds the revisions tried when the next revision is
+// taken by a record that exists but cannot be read.
+const maxRebuildAttempts = 10
+
+// ReleaseRebuild is the action for reconstructing a release record from the
+// resources of the release that exist in the cluster, for use when the
+// record was lost or corrupted.
+//
+// It provides the implementation of 'helm release rebuild'.
+type ReleaseRebuild struct {
+	cfg *Configuration
+
+	// Namespace is the namespace of the release.
+	Namespace string
+	// Force rebuilds the record even if a readable deployed revision of
+	// the release exists. That revision is marked as superseded.
+	Force bool
+	// DryRun returns the rebuilt release without storing it.
+	DryRun bool
+}
+
+// NewReleaseRebuild creates a new ReleaseRebuild object with the given configuration.
+func NewReleaseRebuild(cfg *Configuration) *ReleaseRebuild {
+	return &ReleaseRebuild{cfg: cfg}
+}
+
+// Run rebuilds the record of the named release in Namespace from the
+// resources annotated as belonging to it, and stores it as a new, deployed
+// revision.
+//
+// The result is a best effort: the values the release was installed with
+// cannot be recovered, the chart is only described by the metadata found in
+// the labels of its resources, and hooks are not recorded. It is sufficient
+// for the release to be upgraded, rolled forward or uninstalled.
+func (r *ReleaseRebuild) Run(name string) (*release.Release, error) {
+	if !r.DryRun {
+		if err := r.cfg.checkWritable("rebuild"); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	namespace := r.Namespace
+	history, err := r.cfg.Releases.History(name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, err
+	}
+	var deployed []*release.Release
+	version := 1
+	for _, rls := range history {
+		if rls.Info != nil && rls.Info.Status == release.StatusDeployed {
+			deployed = append(deployed, rls)
+		}
+		if rls.Version >= version {
+			version = rls.Version + 1
+		}
+	}
+	if len(deployed) > 0 && !r.Force {
+		return nil, errors.Errorf("release %q has a readable deployed revision %d, use force to rebuild it anyway", name, deployed[0].Version)
+	}
+
+	objs, err := r.liveObjects(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, errors.Errorf("no resources belonging to release %q found in namespace %q", name, namespace)
+	}
+	manifest, err := releaseutil.ManifestFromObjects(objs)
+	if err != nil {
+		return nil, err
+	}
+	md := releaseutil.ChartMetadataFromObjects(objs)
+	if md == nil {
+		md = &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name}
+	}
+	if md.Version == "" {
+		md.Version = "0.0.0"
+	}
+	md.Description = "Chart metadata reconstructed from live resources"
+
+	now := r.cfg.Now()
+	rel := &release.Release{
+		Name:      name,
+		Namespace: namespace,
+		Version:   version,
+		Chart:     &chart.Chart{Metadata: md},
+		Config:    map[string]interface{}{},
+		Manifest:  manifest,
+		Info: &release.Info{
+			FirstDeployed: now,
+			LastDeployed:  now,
+			Status:        release.StatusDeployed,
+			Description:   "Rebuilt from cluster state",
+		},
+	}
+	if r.DryRun {
+		return rel, nil
+	}
+
+	// Records that cannot be decoded are not part of the history, but
+	// still occupy their revision.
+	for i := 0; ; i++ {
+		err := r.cfg.Releases.Create(rel)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, driver.ErrReleaseExists) || i == maxRebuildAttempts-1 {
+			return nil, err
+		}
+		rel.Version++
+	}
+	// Supersede the previously deployed revisions only once the rebuilt one
+	// is stored, so that a failure never leaves the release without one.
+	for _, rls := range deployed {
+		rls.Info.Status = release.StatusSuperseded
+		if err := r.cfg.Releases.Update(rls); err != nil {
+			return rel, errors.Wrapf(err, "failed to supersede revision %d", rls.Version)
+		}
+	}
+	return rel, nil
+}
+
+// liveObjects returns the objects of every listable kind that carry the
+// ownership metadata of the release. Objects created by controllers are left
+// out, as are kinds the caller is not allowed to list.
+func (r *ReleaseRebuild) liveObjects(name, namespace string) ([]*unstructured.Unstructured, error) {
+	dc, err := r.cfg.RESTClientGetter.ToDiscoveryClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get Kubernetes discovery client")
+	}
+	lists, err := dc.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, errors.Wrap(err, "could not get server resources")
+	}
+	conf, err := r.cfg.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate config for kubernetes client")
+	}
+	client, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*unstructured.Unstructured
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, res := range list.APIResources {
+			if strings.Contains(res.Name, "/") || !containsVerb(res.Verbs, "list") {
+				continue
+			}
+			ri := client.Resource(gv.WithResource(res.Name))
+			var items *unstructured.UnstructuredList
+			opts := metav1.ListOptions{LabelSelector: appManagedByLabel + "=" + appManagedByHelm}
+			if res.Namespaced {
+				items, err = ri.Namespace(namespace).List(context.Background(), opts)
+			} else {
+				items, err = ri.List(context.Background(), opts)
+			}
+			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) || apierrors.IsMethodNotSupported(err) {
+				r.cfg.Log("skipping %s: %s", res.Name, err)
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not list %s", res.Name)
+			}
+			for i := range items.Items {
+				obj := &items.Items[i]
+				if metav1.GetControllerOf(obj) != nil || checkOwnership(obj, name, namespace) != nil {
+					continue
+				}
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, nil
+}
+
+func containsVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
@@ -17,15 +17,22 @@ limitations under the License.
 package action
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
 
 	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/kube"
 	"github.com/open-hand/helm/pkg/release"
 )
 
@@ -38,8 +45,27 @@ type ReleaseTesting struct {
 	// Used for fetching logs from test pods
 	Namespace string
 	Filters   map[string][]string
+	// Test, if set, runs only the test of this name, e.g. to re-run a test
+	// that failed. The last results of the other tests are kept.
+	Test string
+	// CleanupOnSuccess deletes the resources of the tests that succeed,
+	// whatever their delete policy.
+	CleanupOnSuccess bool
+	// RetainFailed keeps the resources of the tests that fail for this long,
+	// whatever their delete policy, so that they can be inspected. They are
+	// deleted by the first test run of the release after that time, or
+	// before the test runs again. Zero applies the delete policies.
+	RetainFailed time.Duration
 }
 
+// testRetainUntilAnnotation records until when the resources of a failed
+// test are retained.
+const testRetainUntilAnnotation = "helm.sh/test-retain-until"
+
+// testLogLines is the number of lines at the end of the logs of the test
+// pods included in the test results.
+const testLogLines = 20
+
 // NewReleaseTesting creates a new ReleaseTesting object with the given configuration.
 func NewReleaseTesting(cfg *Configuration) *ReleaseTesting {
 	return &ReleaseTesting{
@@ -50,6 +76,19 @@ func NewReleaseTesting(cfg *Configuration) *ReleaseTesting {
 
 // Run executes 'helm test' against the given release.
 func (r *ReleaseTesting) Run(name string) (*release.Release, error) {
+	res, err := r.RunTests(name)
+	if res == nil {
+		return nil, err
+	}
+	return res.Release, err
+}
+
+// RunTests runs the tests of the given release like Run, and returns the
+// results of each test run.
+func (r *ReleaseTesting) RunTests(name string) (*release.TestReleaseResponse, error) {
+	if err := r.cfg.checkWritable("test"); err != nil {
+		return nil, err
+	}
 	if err := r.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
@@ -61,8 +100,12 @@ func (r *ReleaseTesting) Run(name string) (*release.Release, error) {
 	// finds the non-deleted release with the given name
 	rel, err := r.cfg.Releases.Last(name)
 	if err != nil {
-		return rel, err
+		return &release.TestReleaseResponse{Release: rel}, err
+	}
+	if r.Test != "" && !hasTest(rel, r.Test) {
+		return nil, errors.Errorf("release %s has no test named %q", name, r.Test)
 	}
+	r.deleteExpiredTests(rel)
 
 	skippedHooks := []*release.Hook{}
 	executingHooks := []*release.Hook{}
@@ -87,15 +130,242 @@ func (r *ReleaseTesting) Run(name string) (*release.Release, error) {
 		}
 		rel.Hooks = executingHooks
 	}
+	if r.Test != "" {
+		executingHooks = nil
+		for _, h := range rel.Hooks {
+			if h.Name == r.Test {
+				executingHooks = append(executingHooks, h)
+			} else {
+				skippedHooks = append(skippedHooks, h)
+			}
+		}
+		rel.Hooks = executingHooks
+	}
+
+	restore := r.overrideDeletePolicies(rel.Hooks)
+	start := time.Now()
+	runErr := r.cfg.execHook(rel, release.HookTest, r.Timeout, nil, 0, "", 0, "", "", "", "", "", "", "", "", false)
+	restore()
+
+	res := &release.TestReleaseResponse{Release: rel, Tests: r.results(rel, start)}
+	r.cleanup(rel, res.Tests)
 
-	if err := r.cfg.execHook(rel, release.HookTest, r.Timeout, nil, 0, "", 0, "", "", "", "", "", "", "", "", false); err != nil {
-		rel.Hooks = append(skippedHooks, rel.Hooks...)
+	rel.Hooks = append(skippedHooks, rel.Hooks...)
+	if runErr != nil {
 		r.cfg.Releases.Update(rel)
-		return rel, err
+		return res, runErr
 	}
+	return res, r.cfg.Releases.Update(rel)
+}
 
-	rel.Hooks = append(skippedHooks, rel.Hooks...)
-	return rel, r.cfg.Releases.Update(rel)
+func hasTest(rel *release.Release, name string) bool {
+	for _, h := range rel.Hooks {
+		if h.Name == name && isTestHook(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// overrideDeletePolicies makes the test hooks keep their resources when
+// they fail if RetainFailed is set. Retained resources are deleted before
+// the test is created again. It returns a function restoring the delete
+// policies of the hooks.
+func (r *ReleaseTesting) overrideDeletePolicies(hooks []*release.Hook) func() {
+	if r.RetainFailed <= 0 {
+		return func() {}
+	}
+	original := make(map[*release.Hook][]release.HookDeletePolicy, len(hooks))
+	for _, h := range hooks {
+		if !isTestHook(h) || len(h.DeletePolicies) == 0 {
+			continue
+		}
+		original[h] = h.DeletePolicies
+		policies := []release.HookDeletePolicy{release.HookBeforeHookCreation}
+		for _, p := range h.DeletePolicies {
+			if p != release.HookFailed && p != release.HookBeforeHookCreation {
+				policies = append(policies, p)
+			}
+		}
+		h.DeletePolicies = policies
+	}
+	return func() {
+		for h, policies := range original {
+			h.DeletePolicies = policies
+		}
+	}
+}
+
+// results returns the results of the test hooks of rel run since start.
+func (r *ReleaseTesting) results(rel *release.Release, start time.Time) []release.TestResult {
+	results := []release.TestResult{}
+	for _, h := range rel.Hooks {
+		if !isTestHook(h) || h.LastRun.StartedAt.Time.Before(start.Truncate(time.Second)) {
+			continue
+		}
+		result := release.TestResult{
+			Name:        h.Name,
+			Kind:        h.Kind,
+			Phase:       h.LastRun.Phase,
+			StartedAt:   h.LastRun.StartedAt.Time,
+			CompletedAt: h.LastRun.CompletedAt.Time,
+		}
+		if !result.CompletedAt.IsZero() {
+			result.Duration = result.CompletedAt.Sub(result.StartedAt)
+		}
+		if h.Kind == "Pod" || h.Kind == "Job" {
+			if err := r.podResult(rel.Namespace, h, &result); err != nil {
+				r.cfg.Log("unable to get the pod of test %s: %s", h.Name, err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// podResult adds the exit code and the end of the logs of the pod running
+// the test h to result. The pod of a job is its most recent one.
+func (r *ReleaseTesting) podResult(namespace string, h *release.Hook, result *release.TestResult) error {
+	client, err := r.cfg.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	pods := client.CoreV1().Pods(namespace)
+
+	var pod *v1.Pod
+	if h.Kind == "Pod" {
+		if pod, err = pods.Get(ctx, h.Name, metav1.GetOptions{}); err != nil {
+			return err
+		}
+	} else {
+		list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + h.Name})
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			if pod == nil || pod.CreationTimestamp.Before(&list.Items[i].CreationTimestamp) {
+				pod = &list.Items[i]
+			}
+		}
+		if pod == nil {
+			return errors.Errorf("no pod found for job %s", h.Name)
+		}
+	}
+
+	for _, s := range pod.Status.ContainerStatuses {
+		if t := s.State.Terminated; t != nil {
+			code := t.ExitCode
+			result.ExitCode = &code
+			if code != 0 {
+				break
+			}
+		}
+	}
+
+	lines := int64(testLogLines)
+	logs, err := pods.GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &lines}).DoRaw(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get pod logs for %s", pod.Name)
+	}
+	result.Logs = string(logs)
+	return nil
+}
+
+// cleanup deletes the resources of the tests that succeeded if
+// CleanupOnSuccess is set, and marks the ones of the tests that failed as
+// retained until RetainFailed elapses.
+func (r *ReleaseTesting) cleanup(rel *release.Release, results []release.TestResult) {
+	hooks := make(map[string]*release.Hook, len(rel.Hooks))
+	for _, h := range rel.Hooks {
+		hooks[h.Name] = h
+	}
+	for i := range results {
+		result := &results[i]
+		h := hooks[result.Name]
+		switch {
+		case result.Phase == release.HookPhaseSucceeded && r.CleanupOnSuccess:
+			if err := r.deleteHookResources(h); err != nil {
+				r.cfg.Log("unable to delete the resources of test %s: %s", h.Name, err)
+			}
+		case result.Phase == release.HookPhaseFailed && r.RetainFailed > 0:
+			until := r.cfg.Now().Time.Add(r.RetainFailed).UTC().Truncate(time.Second)
+			if err := r.retain(h, until); err != nil {
+				r.cfg.Log("unable to retain the resources of test %s: %s", h.Name, err)
+				continue
+			}
+			result.RetainedUntil = &until
+		}
+	}
+}
+
+// retain annotates the resources of the test h with the time until which
+// they are retained.
+func (r *ReleaseTesting) retain(h *release.Hook, until time.Time) error {
+	resources, err := r.cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{testRetainUntilAnnotation: until.Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, info := range resources {
+		if _, err := resource.NewHelper(info.Client, info.Mapping).Patch(info.Namespace, info.Name, types.MergePatchType, patch, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteExpiredTests deletes the retained resources of the tests of rel
+// whose retention time is up.
+func (r *ReleaseTesting) deleteExpiredTests(rel *release.Release) {
+	now := r.cfg.Now().Time
+	for _, h := range rel.Hooks {
+		if !isTestHook(h) || h.LastRun.Phase != release.HookPhaseFailed {
+			continue
+		}
+		resources, err := r.cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+		if err != nil {
+			r.cfg.Log("unable to build the resources of test %s: %s", h.Name, err)
+			continue
+		}
+		var expired kube.ResourceList
+		for _, info := range resources {
+			if err := info.Get(); err != nil {
+				continue
+			}
+			obj, err := meta.Accessor(info.Object)
+			if err != nil {
+				continue
+			}
+			until, err := time.Parse(time.RFC3339, obj.GetAnnotations()[testRetainUntilAnnotation])
+			if err == nil && now.After(until) {
+				expired = append(expired, info)
+			}
+		}
+		if len(expired) > 0 {
+			if _, errs := r.cfg.KubeClient.Delete(expired); len(errs) > 0 {
+				r.cfg.Log("unable to delete the retained resources of test %s: %s", h.Name, joinErrors(errs))
+			}
+		}
+	}
+}
+
+func (r *ReleaseTesting) deleteHookResources(h *release.Hook) error {
+	resources, err := r.cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+	if err != nil {
+		return err
+	}
+	if _, errs := r.cfg.KubeClient.Delete(resources); len(errs) > 0 {
+		return errors.New(joinErrors(errs))
+	}
+	return nil
 }
 
 // GetPodLogs will write the logs for all test pods in the given release into
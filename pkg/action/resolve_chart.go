@@ -0,0 +1,241 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/downloader"
+	"github.com/open-hand/helm/pkg/getter"
+	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/registry"
+	"github.com/open-hand/helm/pkg/repo"
+)
+
+// ChartSource is the kind of location a chart reference is resolved from.
+type ChartSource string
+
+const (
+	// ChartSourceLocal is a chart directory or archive on the local filesystem.
+	ChartSourceLocal ChartSource = "local"
+	// ChartSourceRepository is a chart of a chart repository, referred to
+	// as "repo/chart" or by name along with the repository.
+	ChartSourceRepository ChartSource = "repository"
+	// ChartSourceURL is a chart archive referred to by its URL.
+	ChartSourceURL ChartSource = "url"
+	// ChartSourceOCI is a chart of an OCI registry, referred to by an
+	// oci:// reference.
+	ChartSourceOCI ChartSource = "oci"
+)
+
+// ResolvedChart describes the chart a reference was resolved to.
+type ResolvedChart struct {
+	// Path is the local path of the chart directory or archive.
+	Path string `json:"path"`
+	// Source is the kind of location the chart was resolved from.
+	Source ChartSource `json:"source"`
+	// Repository is the URL of the repository or the registry reference
+	// the chart was found in, empty for local charts and chart URLs.
+	Repository string `json:"repository,omitempty"`
+	// RepositoryName is the name of the configured repository used, if any.
+	RepositoryName string `json:"repository_name,omitempty"`
+	// Name is the name of the chart.
+	Name string `json:"name"`
+	// Version is the version of the chart the reference was resolved to.
+	Version string `json:"version"`
+	// URL is the URL the chart archive was downloaded from, empty for local
+	// charts.
+	URL string `json:"url,omitempty"`
+	// Digest is the digest of the chart archive, "sha256:<hex>", empty for
+	// chart directories.
+	Digest string `json:"digest,omitempty"`
+	// Verification is the verification of the provenance of the chart, when
+	// it was verified.
+	Verification *provenance.Verification `json:"-"`
+}
+
+// ResolveChart resolves a chart reference to a local chart with the default
+// chart path options, see ChartPathOptions.ResolveChart.
+func ResolveChart(ctx context.Context, ref string, settings *cli.EnvSettings) (*ResolvedChart, error) {
+	c := &ChartPathOptions{}
+	if registry.IsOCI(ref) {
+		client, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+		if err != nil {
+			return nil, err
+		}
+		c.SetRegistryClient(client)
+	}
+	return c.ResolveChart(ctx, ref, settings)
+}
+
+// ResolveChart resolves a chart reference to a local chart, downloading it
+// to the repository cache when it is not local.
+//
+// The reference is, in order:
+//   - the path of a chart directory or archive, unless a repository is given
+//   - an oci:// reference to a chart of a registry
+//   - the URL of a chart archive
+//   - a chart of a configured repository, "repo/chart" or "@repo/chart",
+//     where repo is the name or an alias of the repository
+//   - the name of a chart of the repository given in RepoURL, by URL or by
+//     the name or alias of a configured repository
+//
+// Unlike LocateChart, it reports where the chart was found, the version it
+// was resolved to and the digest of its archive. The download is canceled
+// with ctx.
+func (c *ChartPathOptions) ResolveChart(ctx context.Context, ref string, settings *cli.EnvSettings) (*ResolvedChart, error) {
+	ref = strings.TrimSpace(ref)
+	version := strings.TrimSpace(c.Version)
+
+	if c.RepoURL == "" {
+		if _, err := os.Stat(ref); err == nil {
+			return c.resolveLocalChart(ref)
+		}
+		if filepath.IsAbs(ref) || strings.HasPrefix(ref, ".") {
+			return nil, errors.Errorf("path %q not found", ref)
+		}
+	}
+
+	dl, err := c.newChartDownloader(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	if c.Verify {
+		dl.Verify = downloader.VerifyAlways
+	}
+
+	res := &ResolvedChart{}
+	dlRef := ref
+	switch repoURL := settings.ResolveRepo(c.RepoURL); {
+	case registry.IsOCI(ref):
+		if c.registryClient == nil {
+			return nil, errors.Errorf("unable to lookup chart %q, missing registry client", ref)
+		}
+		res.Source = ChartSourceOCI
+		res.Repository = ref
+	case repoURL == "" && strings.Contains(ref, "://"):
+		res.Source = ChartSourceURL
+	case repoURL == "":
+		res.Source = ChartSourceRepository
+		if !isRepoChartRef(ref) {
+			return nil, errors.Errorf("chart %q not found: not a local chart, nor a URL, nor of the form repo/chart", ref)
+		}
+		e := configuredRepo(strings.SplitN(ref, "/", 2)[0], settings)
+		if e == nil {
+			return nil, errors.Errorf("repo %s not found", strings.TrimPrefix(strings.SplitN(ref, "/", 2)[0], "@"))
+		}
+		res.Repository, res.RepositoryName = e.URL, e.Name
+	default:
+		res.Source = ChartSourceRepository
+		if e := configuredRepo(repoURL, settings); e != nil {
+			res.Repository, res.RepositoryName = e.URL, e.Name
+			dlRef = path.Join(e.Name, ref)
+			break
+		}
+		res.Repository = repoURL
+		chartURL, err := repo.FindChartInChannelRepoURL(repoURL, c.Username, c.Password, ref, version, c.Channel,
+			c.CertFile, c.KeyFile, c.CaFile, getter.All(settings))
+		if err != nil {
+			return nil, err
+		}
+		dlRef = chartURL
+		// Only send the credentials of the repository to the chart URL when
+		// asked to or when it is on the same host.
+		if !c.PassCredentialsAll && !sameHost(repoURL, chartURL) {
+			dl.Options = append(dl.Options, getter.WithBasicAuth("", ""))
+		}
+	}
+
+	if err := os.MkdirAll(settings.RepositoryCache, 0755); err != nil {
+		return nil, err
+	}
+	d, err := dl.Download(dlRef, version, settings.RepositoryCache)
+	if err != nil {
+		atVersion := ""
+		if version != "" {
+			atVersion = " at version " + version
+		}
+		return nil, errors.Wrapf(err, "failed to download %q%s", ref, atVersion)
+	}
+	if res.Path, err = filepath.Abs(d.Path); err != nil {
+		return nil, err
+	}
+	res.URL = d.URL
+	res.Digest = "sha256:" + d.Digest
+	res.Verification = d.Verification
+	if err := res.loadMetadata(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// resolveLocalChart resolves a chart directory or archive.
+func (c *ChartPathOptions) resolveLocalChart(name string) (*ResolvedChart, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	res := &ResolvedChart{Path: abs, Source: ChartSourceLocal}
+	if fi, err := os.Stat(abs); err == nil && !fi.IsDir() {
+		if c.Verify {
+			if res.Verification, err = downloader.VerifyChart(abs, c.Keyring); err != nil {
+				return nil, err
+			}
+		}
+		digest, err := provenance.DigestFile(abs)
+		if err != nil {
+			return nil, err
+		}
+		res.Digest = "sha256:" + digest
+	}
+	if err := res.loadMetadata(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// loadMetadata sets the name and version of the resolved chart.
+func (r *ResolvedChart) loadMetadata() error {
+	ch, err := loader.Load(r.Path)
+	if err != nil {
+		return err
+	}
+	r.Name, r.Version = ch.Metadata.Name, ch.Metadata.Version
+	return nil
+}
+
+// sameHost tells whether the URLs have the same scheme and host, port included.
+func sameHost(a, b string) bool {
+	u1, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	u2, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return u1.Scheme == u2.Scheme && u1.Host == u2.Host
+}
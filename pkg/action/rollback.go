@@ -18,11 +18,15 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/open-hand/helm/pkg/kube"
 
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/release"
@@ -39,12 +43,26 @@ type Rollback struct {
 	Timeout       time.Duration
 	Wait          bool
 	WaitForJobs   bool
+	WaitStrategy  kube.WaitStrategy // how the resources are waited for with Wait, the default of the Kubernetes client if empty
 	DisableHooks  bool
 	DryRun        bool
 	Recreate      bool // will (if true) recreate pods after a rollback.
 	Force         bool // will (if true) force resource upgrade through uninstall/recreate if needed
 	CleanupOnFail bool
 	MaxHistory    int // MaxHistory limits the maximum number of revisions saved per release
+	// MigrateAPIs converts the APIs removed from the cluster in the stored
+	// manifests of the current release, and in the manifests rolled back to,
+	// to the APIs replacing them. The converted current release is stored
+	// unless DryRun is set.
+	MigrateAPIs bool
+	// ApplyConcurrency is the maximum number of resources of a kind updated
+	// at the same time, one at a time if unset.
+	ApplyConcurrency int
+	// CrossNamespaceResources lists the resources rolled back to that
+	// target other namespaces than the namespace of the release, or are
+	// cluster-scoped resources of kinds not allowed, that the
+	// NamespacePolicy of the configuration lets through with a warning.
+	CrossNamespaceResources []release.ResourceIdentity
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
@@ -56,6 +74,20 @@ func NewRollback(cfg *Configuration) *Rollback {
 
 // Run executes 'helm rollback' against the given release.
 func (r *Rollback) Run(name string) error {
+	ctx, span := r.cfg.tracer().Start(context.Background(), "helm.rollback", trace.WithAttributes(releaseNameKey.String(name)))
+	start := time.Now()
+	err := r.run(ctx, name)
+	observeAction(r.cfg.Metrics, "rollback", start, err)
+	endSpan(span, err)
+	return err
+}
+
+func (r *Rollback) run(ctx context.Context, name string) error {
+	if !r.DryRun {
+		if err := r.cfg.checkWritable("rollback"); err != nil {
+			return err
+		}
+	}
 	if err := r.cfg.KubeClient.IsReachable(); err != nil {
 		return err
 	}
@@ -68,6 +100,25 @@ func (r *Rollback) Run(name string) error {
 		return err
 	}
 
+	if !r.DryRun {
+		if err := r.cfg.checkOperation(OperationRollback, name, currentRelease.Namespace); err != nil {
+			return err
+		}
+	}
+
+	if r.MigrateAPIs {
+		if currentRelease, err = r.cfg.migrateReleaseAPIs(currentRelease, r.DryRun); err != nil {
+			return err
+		}
+		if targetRelease, err = r.cfg.migrateReleaseAPIs(targetRelease, true); err != nil {
+			return err
+		}
+	}
+
+	if r.CrossNamespaceResources, err = r.cfg.checkNamespacePolicy(targetRelease); err != nil {
+		return err
+	}
+
 	if !r.DryRun {
 		r.cfg.Log("creating rolled back release for %s", name)
 		if err := r.cfg.Releases.Create(targetRelease); err != nil {
@@ -76,7 +127,8 @@ func (r *Rollback) Run(name string) error {
 	}
 
 	r.cfg.Log("performing rollback of %s", name)
-	if _, err := r.performRollback(currentRelease, targetRelease); err != nil {
+	trace.SpanFromContext(ctx).SetAttributes(releaseAttributes(targetRelease)...)
+	if _, err := r.performRollback(ctx, currentRelease, targetRelease); err != nil {
 		return err
 	}
 
@@ -85,6 +137,7 @@ func (r *Rollback) Run(name string) error {
 		if err := r.cfg.Releases.Update(targetRelease); err != nil {
 			return err
 		}
+		r.cfg.releaseLogger(targetRelease).Info("release rolled back")
 	}
 	return nil
 }
@@ -123,10 +176,13 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 		Namespace: currentRelease.Namespace,
 		Chart:     previousRelease.Chart,
 		Config:    previousRelease.Config,
+		// The pinned overrides are kept until they are removed.
+		Overrides: currentRelease.Overrides,
 		Info: &release.Info{
 			FirstDeployed: currentRelease.Info.FirstDeployed,
 			LastDeployed:  helmtime.Now(),
 			Status:        release.StatusPendingRollback,
+			DeployedBy:    r.cfg.User,
 			Notes:         previousRelease.Info.Notes,
 			// Because we lose the reference to previous version elsewhere, we set the
 			// message here, and only override it later if we experience failure.
@@ -140,7 +196,7 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 	return currentRelease, targetRelease, nil
 }
 
-func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release) (*release.Release, error) {
+func (r *Rollback) performRollback(ctx context.Context, currentRelease, targetRelease *release.Release) (*release.Release, error) {
 	if r.DryRun {
 		r.cfg.Log("dry run for %s", targetRelease.Name)
 		return targetRelease, nil
@@ -169,12 +225,18 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 	if err != nil {
 		return targetRelease, errors.Wrap(err, "unable to set metadata visitor from target release")
 	}
-	results, err := r.cfg.KubeClient.Update(current, target, r.Force)
+	var results *kube.Result
+	err = r.cfg.traced(ctx, "helm.kube.update", func(context.Context) error {
+		var err error
+		results, err = r.cfg.update(current, target, r.Force, r.cfg.applyOptions(r.ApplyConcurrency))
+		return err
+	}, resourceCountKey.Int(len(target)))
 
 	if err != nil {
 		msg := fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err)
-		r.cfg.Log("warning: %s", msg)
+		r.cfg.releaseLogger(targetRelease).Warn("rollback failed", "error", err)
 		currentRelease.Info.Status = release.StatusSuperseded
+		r.cfg.markCompleted(targetRelease)
 		targetRelease.Info.Status = release.StatusFailed
 		targetRelease.Info.Description = msg
 		r.cfg.recordRelease(currentRelease)
@@ -196,29 +258,22 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 
 	if r.Recreate {
 		// NOTE: Because this is not critical for a release to succeed, we just
-		// log if an error occurs and continue onward. If we ever introduce log
-		// levels, we should make these error level logs so users are notified
-		// that they'll need to go do the cleanup on their own
+		// log if an error occurs and continue onward, at error level so users
+		// are notified that they'll need to go do the cleanup on their own
 		if err := recreate(r.cfg, results.Updated); err != nil {
-			r.cfg.Log(err.Error())
+			r.cfg.releaseLogger(targetRelease).Error("unable to recreate pods", "error", err)
 		}
 	}
 
 	if r.Wait {
-		if r.WaitForJobs {
-			if err := r.cfg.KubeClient.WaitWithJobs(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
-		} else {
-			if err := r.cfg.KubeClient.Wait(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
+		if err := r.cfg.traced(ctx, "helm.kube.wait", func(context.Context) error {
+			return r.cfg.waitForResources(targetRelease, target, r.Timeout, r.WaitForJobs, r.WaitStrategy)
+		}, resourceCountKey.Int(len(target))); err != nil {
+			r.cfg.markCompleted(targetRelease)
+			targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
+			r.cfg.recordRelease(currentRelease)
+			r.cfg.recordRelease(targetRelease)
+			return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
 		}
 	}
 
@@ -240,6 +295,7 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		r.cfg.recordRelease(rel)
 	}
 
+	r.cfg.markCompleted(targetRelease)
 	targetRelease.Info.Status = release.StatusDeployed
 
 	return targetRelease, nil
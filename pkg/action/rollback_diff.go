@@ -0,0 +1,177 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// RollbackDiff is what rolling a release back would change, computed
+// without changing the release or the cluster.
+type RollbackDiff struct {
+	Release         string `json:"release"`
+	Namespace       string `json:"namespace"`
+	CurrentRevision int    `json:"currentRevision"`
+	CurrentChart    string `json:"currentChart"`
+	// TargetRevision is the revision rolled back to.
+	TargetRevision int    `json:"targetRevision"`
+	TargetChart    string `json:"targetChart"`
+	// Resources are the resources of the manifests that would be added,
+	// changed or deleted, ordered by kind, namespace and name.
+	Resources []PlannedResource `json:"resources"`
+	// Manifest is the unified diff of the canonical manifests of the current
+	// and target revisions. It is empty if they are the same.
+	Manifest string `json:"manifest"`
+	// Values is the unified diff of the user-supplied values of the current
+	// and target revisions. It is empty if they are the same.
+	Values string `json:"values"`
+}
+
+// Changed reports whether the rollback changes any resource or value.
+func (d *RollbackDiff) Changed() bool {
+	return len(d.Resources) > 0 || d.Manifest != "" || d.Values != ""
+}
+
+// Diff computes what rolling back the named release to Version would change
+// in its manifests and values, without rolling it back.
+func (r *Rollback) Diff(name string) (*RollbackDiff, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	current, target, err := r.prepareRollback(name)
+	if err != nil {
+		return nil, err
+	}
+	if r.MigrateAPIs {
+		if current, err = r.cfg.migrateReleaseAPIs(current, true); err != nil {
+			return nil, err
+		}
+		if target, err = r.cfg.migrateReleaseAPIs(target, true); err != nil {
+			return nil, err
+		}
+	}
+	previous, err := r.cfg.Releases.Get(name, r.targetVersion(current))
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := newReleasePlan(PlanUpgrade, current, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	diff := &RollbackDiff{
+		Release:         current.Name,
+		Namespace:       current.Namespace,
+		CurrentRevision: current.Version,
+		CurrentChart:    chartFullName(current.Chart),
+		TargetRevision:  previous.Version,
+		TargetChart:     chartFullName(target.Chart),
+		Resources:       plan.Resources,
+	}
+
+	currentRev, targetRev := revisionName(current), revisionName(previous)
+	if diff.Manifest, err = manifestDiff(current.Manifest, target.Manifest, currentRev, targetRev); err != nil {
+		return nil, err
+	}
+	if diff.Values, err = valuesDiff(current, target, currentRev, targetRev); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// targetVersion returns the revision a rollback of current goes back to.
+func (r *Rollback) targetVersion(current *release.Release) int {
+	if r.Version == 0 {
+		return current.Version - 1
+	}
+	return r.Version
+}
+
+func revisionName(rel *release.Release) string {
+	return fmt.Sprintf("revision %d", rel.Version)
+}
+
+// manifestDiff returns the unified diff of the canonical forms of two
+// manifests, so that the order of their documents and the formatting of the
+// templates do not show up as changes.
+func manifestDiff(from, to, fromName, toName string) (string, error) {
+	a, err := releaseutil.CanonicalizeManifest(from)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing the manifest of %s", fromName)
+	}
+	b, err := releaseutil.CanonicalizeManifest(to)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing the manifest of %s", toName)
+	}
+	return unifiedDiff(a, b, fromName, toName)
+}
+
+// valuesDiff returns the unified diff of the user-supplied values of two
+// releases.
+func valuesDiff(from, to *release.Release, fromName, toName string) (string, error) {
+	a, err := valuesYAML(from.Config)
+	if err != nil {
+		return "", err
+	}
+	b, err := valuesYAML(to.Config)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(a, b, fromName, toName)
+}
+
+func valuesYAML(values map[string]interface{}) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	b, err := yaml.Marshal(values)
+	return string(b), err
+}
+
+func unifiedDiff(a, b, fromName, toName string) (string, error) {
+	if a == b {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(a),
+		B:        diffLines(b),
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  3,
+	})
+}
+
+// diffLines splits s into lines ending with a newline.
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	lines := strings.SplitAfter(s, "\n")
+	return lines[:len(lines)-1]
+}
@@ -18,18 +18,27 @@ package action
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
 	"k8s.io/cli-runtime/pkg/printers"
 	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/registry"
 	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/repo"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 // ShowOutputFormat is the format of the output of `helm show`
@@ -48,6 +57,8 @@ const (
 	ShowHook ShowOutputFormat = "hook"
 	// ShowCRDs is the format which only shows the chart's CRDs
 	ShowCRDs ShowOutputFormat = "crds"
+	// ShowSBOM is the format which only shows the chart's software bill of materials
+	ShowSBOM ShowOutputFormat = "sbom"
 )
 
 var readmeFileNames = []string{"readme.md", "readme.txt", "readme"}
@@ -66,7 +77,22 @@ type Show struct {
 	Devel            bool
 	OutputFormat     ShowOutputFormat
 	JSONPathTemplate string
-	chart            *chart.Chart // for testing
+	// ValuesKey is the dotted path of the sub-tree of the values shown, such
+	// as "ingress.tls", instead of all of them. The items of lists are
+	// selected by their index.
+	ValuesKey string
+	// ValuesOutput is the format of the values shown: "yaml" or "json" to
+	// encode them again, or empty to show the values.yaml file as written,
+	// comments included.
+	ValuesOutput string
+	// SBOMFormat is the format of the software bill of materials shown. When
+	// it is empty, the one stored next to the chart archive is shown if any,
+	// and an SPDX one is generated otherwise.
+	SBOMFormat string
+	// Capabilities are the capabilities the hooks are rendered with, such
+	// as the ones of a capabilities file, instead of the ones of the cluster.
+	Capabilities *chartutil.Capabilities
+	chart        *chart.Chart // for testing
 }
 
 // NewShow creates a new Show object with the given configuration.
@@ -81,15 +107,22 @@ func NewShow(cfg *Configuration, output ShowOutputFormat, chartPathOptions Chart
 // NewShowWithConfig creates a new Show object with the given configuration.
 func NewShowWithConfig(output ShowOutputFormat, cfg *Configuration) *Show {
 	sh := &Show{
+		cfg:          cfg,
 		OutputFormat: output,
 	}
 	sh.ChartPathOptions.registryClient = cfg.RegistryClient
+	sh.ChartPathOptions.signaturePolicy = cfg.SignaturePolicy
+	sh.ChartPathOptions.tracerProvider = cfg.TracerProvider
+	sh.ChartPathOptions.metrics = cfg.Metrics
 
 	return sh
 }
 
 // Run executes 'helm show' against the given release.
 func (s *Show) Run(chartpath string, vals map[string]interface{}) (string, error) {
+	if s.OutputFormat == ShowSBOM {
+		return s.showSBOM(chartpath)
+	}
 	if s.chart == nil {
 		chrt, err := loader.Load(chartpath)
 		if err != nil {
@@ -113,18 +146,8 @@ func (s *Show) Run(chartpath string, vals map[string]interface{}) (string, error
 		if s.OutputFormat == ShowAll {
 			fmt.Fprintln(&out, "---")
 		}
-		if s.JSONPathTemplate != "" {
-			printer, err := printers.NewJSONPathPrinter(s.JSONPathTemplate)
-			if err != nil {
-				return "", errors.Wrapf(err, "error parsing jsonpath %s", s.JSONPathTemplate)
-			}
-			printer.Execute(&out, s.chart.Values)
-		} else {
-			for _, f := range s.chart.Raw {
-				if f.Name == chartutil.ValuesfileName {
-					fmt.Fprintln(&out, string(f.Data))
-				}
-			}
+		if err := s.showValues(&out); err != nil {
+			return "", err
 		}
 	}
 
@@ -168,6 +191,249 @@ func (s *Show) Run(chartpath string, vals map[string]interface{}) (string, error
 	return out.String(), nil
 }
 
+// showValues writes the values of the chart, or their sub-tree at
+// ValuesKey, in the ValuesOutput format or filtered by the JSONPath
+// template.
+func (s *Show) showValues(out io.Writer) error {
+	switch s.ValuesOutput {
+	case "", "yaml", "json":
+	default:
+		return errors.Errorf("invalid values output format %q, allowed values: yaml, json", s.ValuesOutput)
+	}
+
+	var path []string
+	var vals interface{} = map[string]interface{}(s.chart.Values)
+	if s.ValuesKey != "" {
+		path = strings.Split(s.ValuesKey, ".")
+		var ok bool
+		if vals, ok = valuesAt(vals, path); !ok {
+			return errors.Errorf("key %q not found in the values of the chart", s.ValuesKey)
+		}
+	}
+
+	if s.JSONPathTemplate != "" {
+		printer, err := printers.NewJSONPathPrinter(s.JSONPathTemplate)
+		if err != nil {
+			return errors.Wrapf(err, "error parsing jsonpath %s", s.JSONPathTemplate)
+		}
+		printer.Execute(out, vals)
+		return nil
+	}
+
+	switch s.ValuesOutput {
+	case "json":
+		data, err := json.MarshalIndent(vals, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	case "yaml":
+		data, err := yaml.Marshal(vals)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, string(data))
+		return nil
+	}
+
+	for _, f := range s.chart.Raw {
+		if f.Name != chartutil.ValuesfileName {
+			continue
+		}
+		if len(path) == 0 {
+			fmt.Fprintln(out, string(f.Data))
+			return nil
+		}
+		section, err := valuesSection(f.Data, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, string(section))
+		return nil
+	}
+	// The values were not loaded from a values.yaml file.
+	data, err := yaml.Marshal(vals)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, string(data))
+	return nil
+}
+
+// valuesAt returns the sub-tree of the values at the path.
+func valuesAt(vals interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch v := vals.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if vals, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			vals = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return vals, true
+}
+
+// valuesSection returns the section of the values.yaml file data setting
+// the key at the path, with its comments, as a YAML document of the last key
+// of the path and its value, or of a list of the item when the path ends
+// with the index of an item.
+func valuesSection(data []byte, path []string) ([]byte, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the values")
+	}
+	if len(doc.Content) == 0 {
+		return nil, errors.Errorf("key %q not found in the values of the chart", strings.Join(path, "."))
+	}
+	var section *yamlv3.Node
+	node := doc.Content[0]
+	for _, k := range path {
+		section = nil
+		for node.Kind == yamlv3.AliasNode {
+			node = node.Alias
+		}
+		switch node.Kind {
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == k {
+					section = &yamlv3.Node{Kind: yamlv3.MappingNode, Content: node.Content[i : i+2 : i+2]}
+					node = node.Content[i+1]
+					break
+				}
+			}
+		case yamlv3.SequenceNode:
+			if i, err := strconv.Atoi(k); err == nil && i >= 0 && i < len(node.Content) {
+				node = node.Content[i]
+				section = &yamlv3.Node{Kind: yamlv3.SequenceNode, Content: []*yamlv3.Node{node}}
+			}
+		}
+		if section == nil {
+			return nil, errors.Errorf("key %q not found in the values of the chart", strings.Join(path, "."))
+		}
+	}
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(section); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RunChart shows the chart ch, loaded e.g. with loader.LoadFS or
+// loader.LoadArchiveBytes, like Run shows the chart at a path.
+func (s *Show) RunChart(ch *chart.Chart, vals map[string]interface{}) (string, error) {
+	s.chart = ch
+	return s.Run("", vals)
+}
+
+// RunRemote locates the chart name like ChartPathOptions.LocateChart and
+// shows it like Run. For ShowChart and ShowValues, only the Chart.yaml and
+// values.yaml files are fetched from the chart archive, with range requests
+// when the repository supports them, so that charts bundling large files
+// are not downloaded. The whole chart is downloaded for the other formats,
+// and to verify it.
+func (s *Show) RunRemote(name string, settings *cli.EnvSettings, vals map[string]interface{}) (string, error) {
+	verify := s.Verify || s.VerifyCosign || (s.signaturePolicy != nil && s.signaturePolicy.Verifier != nil)
+	if (s.OutputFormat != ShowChart && s.OutputFormat != ShowValues) || verify || s.chart != nil {
+		cp, err := s.ChartPathOptions.LocateChart(name, settings)
+		if err != nil {
+			return "", err
+		}
+		return s.Run(cp, vals)
+	}
+
+	names := []string{chartutil.ChartfileName}
+	if s.OutputFormat == ShowValues {
+		names = append(names, chartutil.ValuesfileName)
+	}
+	files, err := s.ChartPathOptions.chartFiles(name, settings, names...)
+	if err != nil {
+		return "", err
+	}
+	chrt, err := loader.LoadFiles(files)
+	if err != nil {
+		return "", err
+	}
+	s.chart = chrt
+	return s.Run("", vals)
+}
+
+// showSBOM returns the software bill of materials stored next to the chart
+// archive at chartpath, or generates one.
+func (s *Show) showSBOM(chartpath string) (string, error) {
+	var format sbom.Format
+	if s.SBOMFormat != "" {
+		f, err := sbom.ParseFormat(s.SBOMFormat)
+		if err != nil {
+			return "", err
+		}
+		format = f
+	}
+	if data, err := ioutil.ReadFile(chartpath + sbom.FileSuffix); err == nil {
+		if f, err := sbom.Detect(data); err == nil && (format == "" || f == format) {
+			return string(data) + "\n", nil
+		}
+	}
+
+	if s.chart == nil {
+		chrt, err := loader.Load(chartpath)
+		if err != nil {
+			return "", err
+		}
+		s.chart = chrt
+	}
+	if format == "" {
+		format = sbom.SPDX
+	}
+	data, err := sbom.Generate(s.chart, sbom.Options{Format: format})
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// AttachedSBOM returns the software bill of materials attached to a chart
+// in an OCI registry when it was pushed.
+func (s *Show) AttachedSBOM(ref string) (string, error) {
+	if s.registryClient == nil {
+		return "", errors.Errorf("unable to lookup chart %q, missing registry client", ref)
+	}
+	ref = strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme))
+	if version := strings.TrimSpace(s.Version); repo.IsDigest(version) {
+		ref = fmt.Sprintf("%s@%s", ref, version)
+	} else if version != "" {
+		ref = fmt.Sprintf("%s:%s", ref, version)
+	}
+	result, err := s.registryClient.SBOM(ref)
+	if err != nil {
+		return "", err
+	}
+	if s.SBOMFormat != "" {
+		format, err := sbom.ParseFormat(s.SBOMFormat)
+		if err != nil {
+			return "", err
+		}
+		if format.MediaType() != result.MediaType {
+			return "", errors.Errorf("the SBOM attached to %s is of type %s, not %s", ref, result.MediaType, format)
+		}
+	}
+	return string(result.Data) + "\n", nil
+}
+
 func findReadme(files []*chart.File) (file *chart.File) {
 	for _, file := range files {
 		for _, n := range readmeFileNames {
@@ -186,7 +452,11 @@ func (s *Show) FindHooks(releaseName string, chrt *chart.Chart, vals map[string]
 		Revision:  1,
 	}
 	glog.V(1).Info("================================================================get capabitities")
-	caps, err := s.cfg.getCapabilities()
+	caps := s.Capabilities
+	var err error
+	if caps == nil {
+		caps, err = s.cfg.getCapabilities()
+	}
 	glog.V(1).Info("================================================================get capabitities done")
 	if err != nil {
 		return nil, err
@@ -195,7 +465,7 @@ func (s *Show) FindHooks(releaseName string, chrt *chart.Chart, vals map[string]
 	valuesToRender, err := chartutil.ToRenderValues(chrt, vals, options, caps)
 	glog.V(1).Info("================================================================render chart values done")
 	glog.V(1).Info("================================================================render resources")
-	hooks, _, _, err := s.cfg.renderResources(chrt, valuesToRender, releaseName, "", false, true, false, nil, false)
+	hooks, _, _, _, err := s.cfg.renderResources(chrt, valuesToRender, releaseName, "", false, true, false, nil, false)
 	glog.V(1).Info("================================================================render resources done")
 	if err != nil {
 		return nil, err
@@ -0,0 +1,51 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"github.com/open-hand/helm/pkg/downloader"
+	"github.com/open-hand/helm/pkg/provenance"
+)
+
+// SignaturePolicy requires the charts downloaded by actions to carry a
+// signature verified by Verifier. It is set on Configuration to enforce
+// verification for every action, regardless of their options.
+type SignaturePolicy struct {
+	// Verifier verifies the signatures of charts.
+	Verifier provenance.SignatureVerifier
+	// RequireAttestations additionally requires an attestation of the chart
+	// to be verified.
+	RequireAttestations bool
+}
+
+// applySignaturePolicy configures dl to verify the cosign signatures of the
+// chart it downloads, as requested by the options or required by policy.
+// With both, the verifier given by the options is used.
+func (c *ChartPathOptions) applySignaturePolicy(dl *downloader.ChartDownloader, policy *SignaturePolicy) error {
+	if policy != nil {
+		dl.SignatureVerifier = policy.Verifier
+		dl.RequireAttestations = policy.RequireAttestations
+	}
+	if c.VerifyCosign {
+		v, err := provenance.NewCosignVerifier(c.Cosign)
+		if err != nil {
+			return err
+		}
+		dl.SignatureVerifier = v
+	}
+	return nil
+}
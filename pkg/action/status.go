@@ -17,9 +17,40 @@ limitations under the License.
 package action
 
 import (
+	"context"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
 	"github.com/open-hand/helm/pkg/release"
 )
 
+// statusWarningEvents is the number of recent warning events listed for each
+// resource by Status.Resources.
+const statusWarningEvents = 5
+
+// ResourceStatus is the state of a resource of a release in the cluster.
+type ResourceStatus struct {
+	ResourceState
+	// Created is the creation time of the resource, nil if it does not exist.
+	Created *time.Time `json:"created,omitempty"`
+	// Warnings are the most recent warning events of the resource, the most
+	// recent first.
+	Warnings []ResourceEvent `json:"warnings,omitempty"`
+}
+
+// ResourceEvent is an event of a resource.
+type ResourceEvent struct {
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
 // Status is the action for checking the deployment status of releases.
 //
 // It provides the implementation of 'helm status'.
@@ -32,6 +63,9 @@ type Status struct {
 	// only affect print type table.
 	// TODO Helm 4: Remove this flag and output the description by default.
 	ShowDescription bool
+	// ShowResources makes 'helm status' list the resources of the release
+	// with their state, see Resources.
+	ShowResources bool
 }
 
 // NewStatus creates a new Status object with the given configuration.
@@ -49,3 +83,58 @@ func (s *Status) Run(name string) (*release.Release, error) {
 
 	return s.cfg.releaseContent(name, s.Version)
 }
+
+// Resources returns the state of the resources of the manifest of rel in the
+// cluster: their readiness, age and recent warning events. They are ordered
+// by kind and name.
+func (s *Status) Resources(ctx context.Context, rel *release.Release) ([]ResourceStatus, error) {
+	if err := s.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	statuses := []ResourceStatus{}
+	err := s.visitResources(ctx, rel, func(info *resource.Info, state ResourceState) error {
+		status := ResourceStatus{ResourceState: state}
+		if err := info.Get(); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			status.Ready, status.Error = false, "not found"
+			statuses = append(statuses, status)
+			return nil
+		}
+		if obj, err := meta.Accessor(info.Object); err == nil {
+			created := obj.GetCreationTimestamp().Time
+			status.Created = &created
+		}
+
+		cs, err := s.cfg.KubernetesClientSet()
+		if err != nil {
+			return err
+		}
+		events, err := kube.WarningEvents(ctx, cs, info.Namespace, state.Kind, info.Name, statusWarningEvents)
+		if err != nil {
+			s.cfg.Log("unable to list the events of %s %q: %s", state.Kind, info.Name, err)
+		}
+		for _, e := range events {
+			last := e.LastTimestamp.Time
+			if last.IsZero() {
+				last = e.EventTime.Time
+			}
+			status.Warnings = append(status.Warnings, ResourceEvent{Reason: e.Reason, Message: e.Message, Count: e.Count, LastSeen: last})
+		}
+		statuses = append(statuses, status)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		a, b := statuses[i], statuses[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return statuses, nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// ResourceState is the readiness of a resource of a release.
+type ResourceState struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	// Error is the error met checking the readiness of the resource.
+	Error string `json:"error,omitempty"`
+}
+
+// HookState is the progress of a hook of a release.
+type HookState struct {
+	Name   string            `json:"name"`
+	Kind   string            `json:"kind"`
+	Events []string          `json:"events"`
+	Phase  release.HookPhase `json:"phase"`
+}
+
+// StatusUpdate is the state of a release reported by Status.Watch.
+type StatusUpdate struct {
+	Release   string         `json:"release"`
+	Namespace string         `json:"namespace"`
+	Revision  int            `json:"revision"`
+	Status    release.Status `json:"status"`
+	// Resources is the readiness of the resources of the manifest of the
+	// release, ordered by kind and name.
+	Resources []ResourceState `json:"resources"`
+	// Hooks is the progress of the hooks of the release, in the order they
+	// are run.
+	Hooks []HookState `json:"hooks"`
+	// Settled is true once the release is no longer pending and either
+	// failed or has all of its resources ready.
+	Settled bool `json:"settled"`
+}
+
+// Ready returns the number of ready resources.
+func (u *StatusUpdate) Ready() int {
+	n := 0
+	for _, r := range u.Resources {
+		if r.Ready {
+			n++
+		}
+	}
+	return n
+}
+
+// Watch polls the named release every interval and calls fn with its state
+// until the release settles, ctx is done or fn returns an error. The
+// revision of s.Version is watched if set, otherwise the latest revision,
+// so that an upgrade started while watching is followed.
+func (s *Status) Watch(ctx context.Context, name string, interval time.Duration, fn func(*StatusUpdate) error) error {
+	if interval <= 0 {
+		return errors.Errorf("invalid watch interval %s", interval)
+	}
+	if err := s.cfg.KubeClient.IsReachable(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		update, err := s.update(ctx, name)
+		if err != nil {
+			return err
+		}
+		if err := fn(update); err != nil {
+			return err
+		}
+		if update.Settled {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "release %s did not settle", name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// update gets the state of the release.
+func (s *Status) update(ctx context.Context, name string) (*StatusUpdate, error) {
+	rel, err := s.cfg.releaseContent(name, s.Version)
+	if err != nil {
+		return nil, err
+	}
+	update := &StatusUpdate{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Status:    rel.Info.Status,
+		Resources: []ResourceState{},
+		Hooks:     []HookState{},
+	}
+
+	for _, h := range rel.Hooks {
+		events := make([]string, 0, len(h.Events))
+		for _, e := range h.Events {
+			events = append(events, e.String())
+		}
+		phase := h.LastRun.Phase
+		if phase == "" {
+			phase = release.HookPhaseUnknown
+		}
+		update.Hooks = append(update.Hooks, HookState{Name: h.Name, Kind: h.Kind, Events: events, Phase: phase})
+	}
+
+	err = s.visitResources(ctx, rel, func(info *resource.Info, state ResourceState) error {
+		update.Resources = append(update.Resources, state)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(update.Resources, func(i, j int) bool {
+		a, b := update.Resources[i], update.Resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	if !update.Status.IsPending() {
+		update.Settled = update.Status != release.StatusDeployed || update.Ready() == len(update.Resources)
+	}
+	return update, nil
+}
+
+// visitResources calls fn with each resource of the manifest of rel and its
+// readiness.
+func (s *Status) visitResources(ctx context.Context, rel *release.Release, fn func(*resource.Info, ResourceState) error) error {
+	resources, err := s.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	cs, err := s.cfg.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	checker := kube.NewReadyChecker(cs, s.cfg.Log, kube.PausedAsReady(true), kube.CheckJobs(true))
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		state := ResourceState{Kind: info.Mapping.GroupVersionKind.Kind, Namespace: info.Namespace, Name: info.Name}
+		state.Ready, err = checker.IsReady(ctx, info)
+		if err != nil {
+			state.Error = err.Error()
+		}
+		return fn(info, state)
+	})
+}
@@ -0,0 +1,197 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// TimelineEventKind is the kind of an event of a ReleaseTimeline.
+type TimelineEventKind string
+
+const (
+	// TimelineInstall is the installation of a release.
+	TimelineInstall TimelineEventKind = "install"
+	// TimelineUpgrade is the upgrade of a release.
+	TimelineUpgrade TimelineEventKind = "upgrade"
+	// TimelineRollback is the rollback of a release.
+	TimelineRollback TimelineEventKind = "rollback"
+	// TimelineTest is a run of the test suite of a release.
+	TimelineTest TimelineEventKind = "test"
+	// TimelineUninstall is the uninstallation of a release whose history
+	// was kept.
+	TimelineUninstall TimelineEventKind = "uninstall"
+)
+
+// TimelineEvent is an operation in the history of a release.
+type TimelineEvent struct {
+	Kind     TimelineEventKind `json:"kind"`
+	Revision int               `json:"revision"`
+	Time     time.Time         `json:"time"`
+	// User is the user who created the revision, if it was recorded.
+	User string `json:"user,omitempty"`
+	// Chart is the name and version of the chart of the revision.
+	Chart      string `json:"chart,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+	// Result is the status of the revision for deployments, and the phase
+	// of the test suite for tests.
+	Result string `json:"result"`
+	// Duration is how long the operation took. It is zero if unknown, e.g.
+	// for the revisions created before it was recorded.
+	Duration    time.Duration `json:"duration,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// ReleaseTimeline is the chronological report of the operations of a
+// release across its history.
+type ReleaseTimeline struct {
+	Release   string          `json:"release"`
+	Namespace string          `json:"namespace"`
+	Events    []TimelineEvent `json:"events"`
+}
+
+// NewReleaseTimeline assembles the timeline of the revisions of a release,
+// e.g. as returned by the History action.
+func NewReleaseTimeline(history []*release.Release) *ReleaseTimeline {
+	t := &ReleaseTimeline{Events: []TimelineEvent{}}
+	for _, rel := range history {
+		if rel == nil || rel.Info == nil {
+			continue
+		}
+		t.Release, t.Namespace = rel.Name, rel.Namespace
+
+		chart, appVersion := "", ""
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+			appVersion = rel.Chart.Metadata.AppVersion
+		}
+		event := func(kind TimelineEventKind, at time.Time) TimelineEvent {
+			return TimelineEvent{
+				Kind:       kind,
+				Revision:   rel.Version,
+				Time:       at,
+				User:       rel.Info.DeployedBy,
+				Chart:      chart,
+				AppVersion: appVersion,
+			}
+		}
+
+		deploy := event(deploymentKind(rel), rel.Info.LastDeployed.Time)
+		deploy.Result = rel.Info.Status.String()
+		deploy.Description = rel.Info.Description
+		if c := rel.Info.Completed; c != nil && !c.IsZero() {
+			deploy.Duration = c.Time.Sub(rel.Info.LastDeployed.Time)
+		}
+		t.Events = append(t.Events, deploy)
+
+		if test, ok := testEvent(rel); ok {
+			e := event(TimelineTest, test.start)
+			// Test runs do not record who ran them.
+			e.User = ""
+			e.Result = test.phase.String()
+			e.Duration = test.end.Sub(test.start)
+			e.Description = strings.Join(test.names, ", ")
+			t.Events = append(t.Events, e)
+		}
+
+		if !rel.Info.Deleted.IsZero() {
+			e := event(TimelineUninstall, rel.Info.Deleted.Time)
+			e.Result = release.StatusUninstalled.String()
+			t.Events = append(t.Events, e)
+		}
+	}
+
+	sort.SliceStable(t.Events, func(i, j int) bool {
+		a, b := t.Events[i], t.Events[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return a.Revision < b.Revision
+	})
+	return t
+}
+
+// deploymentKind returns the kind of operation that created the revision.
+func deploymentKind(rel *release.Release) TimelineEventKind {
+	switch {
+	case strings.HasPrefix(rel.Info.Description, "Rollback"):
+		return TimelineRollback
+	case rel.Version <= 1, rel.Info.Description == "Install complete":
+		return TimelineInstall
+	default:
+		return TimelineUpgrade
+	}
+}
+
+// testRun is the last run of the test suite of a revision.
+type testRun struct {
+	start, end time.Time
+	phase      release.HookPhase
+	names      []string
+}
+
+// testEvent returns the last run of the test hooks of rel, if they ran.
+func testEvent(rel *release.Release) (testRun, bool) {
+	var run testRun
+	for _, h := range rel.Hooks {
+		if !isTestHook(h) || h.LastRun.StartedAt.IsZero() {
+			continue
+		}
+		if run.start.IsZero() || h.LastRun.StartedAt.Time.Before(run.start) {
+			run.start = h.LastRun.StartedAt.Time
+		}
+		if h.LastRun.CompletedAt.Time.After(run.end) {
+			run.end = h.LastRun.CompletedAt.Time
+		}
+		switch {
+		case h.LastRun.Phase == release.HookPhaseFailed:
+			run.phase = release.HookPhaseFailed
+		case run.phase == "" || run.phase == release.HookPhaseSucceeded:
+			run.phase = h.LastRun.Phase
+		}
+		run.names = append(run.names, h.Name)
+	}
+	if run.start.IsZero() {
+		return run, false
+	}
+	if run.end.Before(run.start) {
+		run.end = run.start
+	}
+	return run, true
+}
+
+func isTestHook(h *release.Hook) bool {
+	for _, e := range h.Events {
+		if e == release.HookTest {
+			return true
+		}
+	}
+	return false
+}
+
+// Timeline returns the timeline of the named release across its history.
+func (s *Status) Timeline(name string) (*ReleaseTimeline, error) {
+	history, err := NewHistory(s.cfg).Run(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewReleaseTimeline(history), nil
+}
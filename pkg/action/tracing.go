@@ -0,0 +1,86 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// tracerName is the name of the tracer of the actions.
+const tracerName = "github.com/open-hand/helm/pkg/action"
+
+// Attributes of the spans of the actions.
+const (
+	releaseNameKey      = attribute.Key("helm.release.name")
+	releaseNamespaceKey = attribute.Key("helm.release.namespace")
+	releaseRevisionKey  = attribute.Key("helm.release.revision")
+	chartNameKey        = attribute.Key("helm.chart.name")
+	chartVersionKey     = attribute.Key("helm.chart.version")
+	resourceCountKey    = attribute.Key("helm.resources.count")
+)
+
+// tracer returns the tracer of the actions, which records nothing if the
+// configuration has no TracerProvider.
+func (cfg *Configuration) tracer() trace.Tracer {
+	return tracerProvider(cfg.TracerProvider).Tracer(tracerName)
+}
+
+func tracerProvider(tp trace.TracerProvider) trace.TracerProvider {
+	if tp == nil {
+		return trace.NewNoopTracerProvider()
+	}
+	return tp
+}
+
+// traced runs fn in a span, child of the span of ctx, which records the
+// error fn returns.
+func (cfg *Configuration) traced(ctx context.Context, name string, fn func(ctx context.Context) error, attrs ...attribute.KeyValue) error {
+	ctx, span := cfg.tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan ends the span, recording the error if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// releaseAttributes returns the attributes identifying the release.
+func releaseAttributes(rel *release.Release) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		releaseNameKey.String(rel.Name),
+		releaseNamespaceKey.String(rel.Namespace),
+		releaseRevisionKey.Int(rel.Version),
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		attrs = append(attrs,
+			chartNameKey.String(rel.Chart.Metadata.Name),
+			chartVersionKey.String(rel.Chart.Metadata.Version))
+	}
+	return attrs
+}
@@ -17,10 +17,12 @@ limitations under the License.
 package action
 
 import (
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/kube"
@@ -41,6 +43,10 @@ type Uninstall struct {
 	Wait         bool
 	Timeout      time.Duration
 	Description  string
+	// DeleteNamespace deletes the namespace of the release if it was created
+	// by the installation of the release. It is ignored with KeepHistory,
+	// which keeps the release in its namespace.
+	DeleteNamespace bool
 }
 
 // NewUninstall creates a new Uninstall object with the given configuration.
@@ -52,17 +58,24 @@ func NewUninstall(cfg *Configuration) *Uninstall {
 
 // Run uninstalls the given release.
 func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error) {
+	start := time.Now()
+	res, err := u.run(name)
+	observeAction(u.cfg.Metrics, "uninstall", start, err)
+	return res, err
+}
+
+func (u *Uninstall) run(name string) (*release.UninstallReleaseResponse, error) {
+	if !u.DryRun {
+		if err := u.cfg.checkWritable("uninstall"); err != nil {
+			return nil, err
+		}
+	}
 	if err := u.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
 
 	if u.DryRun {
-		// In the dry run case, just see if the release exists
-		r, err := u.cfg.releaseContent(name, 0)
-		if err != nil {
-			return &release.UninstallReleaseResponse{}, err
-		}
-		return &release.UninstallReleaseResponse{Release: r}, nil
+		return u.dryRun(name)
 	}
 
 	if err := chartutil.ValidateReleaseName(name); err != nil {
@@ -80,6 +93,10 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	releaseutil.SortByRevision(rels)
 	rel := rels[len(rels)-1]
 
+	if err := u.cfg.checkOperation(OperationUninstall, name, rel.Namespace); err != nil {
+		return nil, err
+	}
+
 	// TODO: Are there any cases where we want to force a delete even if it's
 	// already marked deleted?
 	if rel.Info.Status == release.StatusUninstalled {
@@ -96,7 +113,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	rel.Info.Status = release.StatusUninstalling
 	rel.Info.Deleted = helmtime.Now()
 	rel.Info.Description = "Deletion in progress (or silently failed)"
-	res := &release.UninstallReleaseResponse{Release: rel}
+	res := &release.UninstallReleaseResponse{Release: rel, Hooks: u.deleteHooks(rel)}
 
 	if !u.DisableHooks {
 		if err := u.cfg.execHook(rel, release.HookPreDelete, u.Timeout, nil, 0, "", 0, "", "", "", "", "", "", "", "", false); err != nil {
@@ -112,7 +129,13 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		u.cfg.Log("uninstall: Failed to store updated release: %s", err)
 	}
 
-	deletedResources, kept, errs := u.deleteRelease(rel)
+	deletedResources, keptResources, kept, errs := u.deleteRelease(rel)
+	for _, info := range deletedResources {
+		res.Deleted = append(res.Deleted, resourceIdentity(info))
+	}
+	for _, info := range keptResources {
+		res.Kept = append(res.Kept, resourceIdentity(info))
+	}
 
 	if kept != "" {
 		kept = "These resources were kept due to the resource policy:\n" + kept
@@ -147,11 +170,23 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 			errs = append(errs, errors.Wrap(err, "uninstall: Failed to purge the release"))
 		}
 
+		if u.DeleteNamespace {
+			kept, err := u.cfg.deleteCreatedNamespace(rel.Namespace, rel.Name)
+			if err != nil {
+				errs = append(errs, errors.Wrap(err, "uninstall: Failed to delete the namespace"))
+			}
+			if kept != "" {
+				u.cfg.Log("%s", kept)
+				res.Info = strings.TrimLeft(res.Info+"\n"+kept, "\n")
+			}
+		}
+
 		// Return the errors that occurred while deleting the release, if any
 		if len(errs) > 0 {
 			return res, errors.Errorf("uninstallation completed with %d error(s): %s", len(errs), joinErrors(errs))
 		}
 
+		u.cfg.releaseLogger(rel).Info("release uninstalled")
 		return res, nil
 	}
 
@@ -162,6 +197,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	if len(errs) > 0 {
 		return res, errors.Errorf("uninstallation completed with %d error(s): %s", len(errs), joinErrors(errs))
 	}
+	u.cfg.releaseLogger(rel).Info("release uninstalled")
 	return res, nil
 }
 
@@ -182,12 +218,82 @@ func joinErrors(errs []error) string {
 	return strings.Join(es, "; ")
 }
 
-// deleteRelease deletes the release and returns list of delete resources and manifests that were kept in the deletion process
-func (u *Uninstall) deleteRelease(rel *release.Release) (kube.ResourceList, string, []error) {
-	var errs []error
+// dryRun returns the resources that uninstalling the named release would
+// delete and keep, and the delete hooks it would run, without changing
+// anything. Only the resources that exist in the cluster are reported as
+// deleted.
+func (u *Uninstall) dryRun(name string) (*release.UninstallReleaseResponse, error) {
+	rel, err := u.cfg.releaseContent(name, 0)
+	if err != nil {
+		return &release.UninstallReleaseResponse{}, err
+	}
+	res := &release.UninstallReleaseResponse{Release: rel}
+	if rel.Info.Status == release.StatusUninstalled {
+		// Only the history of the release would be purged.
+		return res, nil
+	}
+	res.Hooks = u.deleteHooks(rel)
+
+	filesToKeep, filesToDelete, err := u.splitManifest(rel)
+	if err != nil {
+		return res, err
+	}
+	kept, err := u.cfg.KubeClient.Build(strings.NewReader(joinManifests(filesToKeep)), false)
+	if err != nil {
+		return res, errors.Wrap(err, "unable to build kubernetes objects of the kept resources")
+	}
+	for _, info := range kept {
+		res.Kept = append(res.Kept, resourceIdentity(info))
+	}
+
+	resources, err := u.cfg.KubeClient.Build(strings.NewReader(joinManifests(filesToDelete)), false)
+	if err != nil {
+		return res, errors.Wrap(err, "unable to build kubernetes objects for delete")
+	}
+	for _, info := range resources {
+		if err := info.Get(); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return res, errors.Wrapf(err, "could not get information about the resource %s", resourceString(info))
+		}
+		res.Deleted = append(res.Deleted, resourceIdentity(info))
+	}
+	return res, nil
+}
+
+// deleteHooks returns the names of the delete hooks of the release that the
+// uninstall runs, in execution order.
+func (u *Uninstall) deleteHooks(rel *release.Release) []string {
+	if u.DisableHooks {
+		return nil
+	}
+	var names []string
+	for _, event := range []release.HookEvent{release.HookPreDelete, release.HookPostDelete} {
+		var hooks []*release.Hook
+		for _, h := range rel.Hooks {
+			for _, e := range h.Events {
+				if e == event {
+					hooks = append(hooks, h)
+					break
+				}
+			}
+		}
+		sort.Stable(hookByWeight(hooks))
+		for _, h := range hooks {
+			names = append(names, h.Name)
+		}
+	}
+	return names
+}
+
+// splitManifest splits the manifest of the release, sorted in uninstall
+// order, into the resources kept due to their resource policy and the ones
+// to delete.
+func (u *Uninstall) splitManifest(rel *release.Release) (keep, remove []releaseutil.Manifest, err error) {
 	caps, err := u.cfg.getCapabilities()
 	if err != nil {
-		return nil, rel.Manifest, []error{errors.Wrap(err, "could not get apiVersions from Kubernetes")}
+		return nil, nil, errors.Wrap(err, "could not get apiVersions from Kubernetes")
 	}
 
 	manifests := releaseutil.SplitManifests(rel.Manifest)
@@ -197,26 +303,53 @@ func (u *Uninstall) deleteRelease(rel *release.Release) (kube.ResourceList, stri
 		// FIXME: One way to delete at this point would be to try a label-based
 		// deletion. The problem with this is that we could get a false positive
 		// and delete something that was not legitimately part of this release.
-		return nil, rel.Manifest, []error{errors.Wrap(err, "corrupted release record. You must manually delete the resources")}
+		return nil, nil, errors.Wrap(err, "corrupted release record. You must manually delete the resources")
+	}
+
+	keep, remove = filterManifestsToKeep(files)
+	return keep, remove, nil
+}
+
+func joinManifests(manifests []releaseutil.Manifest) string {
+	var builder strings.Builder
+	for _, file := range manifests {
+		builder.WriteString("\n---\n" + file.Content)
+	}
+	return builder.String()
+}
+
+// deleteRelease deletes the release and returns list of delete resources, the kept resources and manifests that were kept in the deletion process
+func (u *Uninstall) deleteRelease(rel *release.Release) (kube.ResourceList, kube.ResourceList, string, []error) {
+	var errs []error
+	filesToKeep, filesToDelete, err := u.splitManifest(rel)
+	if err != nil {
+		return nil, nil, rel.Manifest, []error{err}
 	}
 
-	filesToKeep, filesToDelete := filterManifestsToKeep(files)
 	var kept string
 	for _, f := range filesToKeep {
 		kept += "[" + f.Head.Kind + "] " + f.Head.Metadata.Name + "\n"
 	}
-
-	var builder strings.Builder
-	for _, file := range filesToDelete {
-		builder.WriteString("\n---\n" + file.Content)
+	keptResources, err := u.cfg.KubeClient.Build(strings.NewReader(joinManifests(filesToKeep)), false)
+	if err != nil {
+		u.cfg.Log("uninstall: unable to build kubernetes objects of the kept resources: %s", err)
 	}
 
-	resources, err := u.cfg.KubeClient.Build(strings.NewReader(builder.String()), false)
+	resources, err := u.cfg.KubeClient.Build(strings.NewReader(joinManifests(filesToDelete)), false)
 	if err != nil {
-		return nil, "", []error{errors.Wrap(err, "unable to build kubernetes objects for delete")}
+		return nil, keptResources, "", []error{errors.Wrap(err, "unable to build kubernetes objects for delete")}
+	}
+	// Another release may have taken the ownership of the cluster-scoped
+	// resources, deleting them would break it.
+	resources, ownedByOthers := splitOwnedByOthers(resources, rel.Name, rel.Namespace)
+	for _, info := range ownedByOthers {
+		name, namespace, _ := releaseOwner(info.Object)
+		u.cfg.Log("uninstall: keeping %s owned by release %q in namespace %q", resourceString(info), name, namespace)
+		kept += "[" + info.Mapping.GroupVersionKind.Kind + "] " + info.Name + "\n"
+		keptResources.Append(info)
 	}
 	if len(resources) > 0 {
 		_, errs = u.cfg.KubeClient.Delete(resources)
 	}
-	return resources, kept, errs
+	return resources, keptResources, kept, errs
 }
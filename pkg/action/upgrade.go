@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -35,6 +36,7 @@ import (
 	"github.com/open-hand/helm/pkg/chart"
 	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/policy"
 	"github.com/open-hand/helm/pkg/postrender"
 	"github.com/open-hand/helm/pkg/release"
 	"github.com/open-hand/helm/pkg/releaseutil"
@@ -78,6 +80,12 @@ type Upgrade struct {
 	Wait bool
 	// WaitForJobs determines whether the wait operation for the Jobs should be performed after the upgrade is requested.
 	WaitForJobs bool
+	// WaitStrategy is how the resources are waited for with Wait, see
+	// kube.WaitStrategy. The Kubernetes client picks it when empty.
+	WaitStrategy kube.WaitStrategy
+	// ApplyConcurrency is the maximum number of resources of a kind updated
+	// at the same time, one at a time if unset.
+	ApplyConcurrency int
 	// DisableHooks disables hook processing if set to true.
 	DisableHooks bool
 	// DryRun controls whether the operation is prepared, but not executed.
@@ -91,6 +99,13 @@ type Upgrade struct {
 	ResetValues bool
 	// ReuseValues will re-use the user's last supplied values.
 	ReuseValues bool
+	// SmartReuseValues makes ReuseValues merge the last supplied values with
+	// the defaults of the new chart, rather than with the defaults of the
+	// chart of the last release. See chartutil.MergeReusedValues.
+	SmartReuseValues bool
+	// ReuseValuesWarnings lists the values reused by SmartReuseValues whose
+	// defaults were changed, removed or renamed by the new chart.
+	ReuseValuesWarnings []string
 	// Recreate will (if true) recreate pods after a rollback.
 	Recreate bool
 	// MaxHistory limits the maximum number of revisions saved per release
@@ -108,6 +123,55 @@ type Upgrade struct {
 	// If this is non-nil, then after templates are rendered, they will be sent to the
 	// post renderer before sending to the Kubernetes API server.
 	PostRenderer postrender.PostRenderer
+	// PolicyChecker enforces policies on the rendered resources before they
+	// are applied.
+	PolicyChecker *policy.Checker
+	// TakeOwnership adopts the existing resources owned by other releases
+	// or not managed by Helm instead of failing.
+	TakeOwnership bool
+	// CrossNamespaceResources lists the rendered resources targeting other
+	// namespaces than the namespace of the release, or cluster-scoped
+	// resources of kinds not allowed, that the NamespacePolicy of the
+	// configuration lets through with a warning.
+	CrossNamespaceResources []release.ResourceIdentity
+	// DuplicateResources lists the resources rendered more than once that
+	// the DuplicateResources mode of the configuration lets through with a
+	// warning.
+	DuplicateResources []DuplicateResource
+	// SkippedResources lists the rendered resources left out of the release
+	// because their only-on annotations, such as helm.sh/only-kube-version,
+	// exclude the cluster.
+	SkippedResources []SkippedResource
+	// Preflight checks the compute resources requested by the rendered
+	// workloads beyond the ones of the current release against the
+	// ResourceQuotas of the namespace and the capacity of the nodes before
+	// anything is applied. See Configuration.Preflight.
+	Preflight bool
+	// MigrateAPIs converts the APIs removed from the cluster in the stored
+	// manifests of the current release to the APIs replacing them before the
+	// upgrade is computed, so that releases installed with removed APIs can
+	// be upgraded. The converted release is stored unless DryRun is set.
+	MigrateAPIs bool
+	// Ordering customizes the order in which the resources are applied.
+	Ordering kube.Ordering
+	// Waves applies the resources in the waves of their kube.WaveAnnotation,
+	// each once the previous one is ready, and records the progress in the
+	// release.
+	Waves bool
+	// Prune deletes the resources applied by the previous revision, including
+	// the resources of its hooks, that the new revision does not apply.
+	Prune bool
+	// Pruned lists the resources deleted by Prune, or that Prune would
+	// delete on dry runs.
+	Pruned []release.ResourceIdentity
+	// ResumeWaves skips the waves completed by the last release if it failed
+	// with the same manifest, resuming it from the wave that failed.
+	ResumeWaves bool
+	// SkipUnchanged leaves as is the resources identical to the ones applied
+	// by the previous revision instead of patching them. The digests of the
+	// resources are recorded in the release to compare them on the next
+	// upgrade, so the first upgrade skipping them still patches them all.
+	SkipUnchanged bool
 	// DisableOpenAPIValidation controls whether OpenAPI validation is enforced.
 	DisableOpenAPIValidation bool
 
@@ -167,6 +231,9 @@ func NewUpgrade(cfg *Configuration,
 	}
 
 	up.ChartPathOptions.registryClient = cfg.RegistryClient
+	up.ChartPathOptions.signaturePolicy = cfg.SignaturePolicy
+	up.ChartPathOptions.tracerProvider = cfg.TracerProvider
+	up.ChartPathOptions.metrics = cfg.Metrics
 
 	return up
 }
@@ -179,6 +246,28 @@ func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface
 
 // RunWithContext executes the upgrade on the given release with context.
 func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}, valuesRaw string) (*release.Release, error) {
+	ctx, span := u.cfg.tracer().Start(ctx, "helm.upgrade", trace.WithAttributes(
+		releaseNameKey.String(name),
+		releaseNamespaceKey.String(u.Namespace)))
+	start := time.Now()
+	rel, err := u.run(ctx, name, chart, vals, valuesRaw)
+	observeAction(u.cfg.Metrics, "upgrade", start, err)
+	if err == nil && !u.DryRun {
+		u.cfg.applyRetention(name)
+	}
+	if rel != nil {
+		span.SetAttributes(releaseAttributes(rel)...)
+	}
+	endSpan(span, err)
+	return rel, err
+}
+
+func (u *Upgrade) run(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}, valuesRaw string) (*release.Release, error) {
+	if !u.DryRun {
+		if err := u.cfg.checkWritable("upgrade"); err != nil {
+			return nil, err
+		}
+	}
 	glog.V(1).Info("================================================================check k8s reachable")
 	if err := u.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
@@ -193,11 +282,29 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.
 		return nil, errors.Errorf("release name is invalid: %s", name)
 	}
 	u.cfg.Log("preparing upgrade for %s", name)
-	currentRelease, upgradedRelease, err := u.prepareUpgrade(name, chart, vals, valuesRaw)
+	currentRelease, upgradedRelease, err := u.prepareUpgrade(ctx, name, chart, vals, valuesRaw)
 	if err != nil {
 		return nil, err
 	}
 
+	if !u.DryRun {
+		if err := u.cfg.checkOperation(OperationUpgrade, name, currentRelease.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.MigrateAPIs {
+		if currentRelease, err = u.cfg.migrateReleaseAPIs(currentRelease, u.DryRun); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.Preflight {
+		if err := u.cfg.checkPreflight(upgradedRelease.Namespace, upgradedRelease.Manifest, currentRelease.Manifest); err != nil {
+			return nil, err
+		}
+	}
+
 	u.cfg.Releases.MaxHistory = u.MaxHistory
 
 	u.cfg.Log("performing update for %s", name)
@@ -219,7 +326,7 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.
 }
 
 // prepareUpgrade builds an upgraded release for an upgrade operation.
-func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[string]interface{}, valuesRaw string) (*release.Release, *release.Release, error) {
+func (u *Upgrade) prepareUpgrade(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}, valuesRaw string) (*release.Release, *release.Release, error) {
 	if chart == nil {
 		return nil, nil, errMissingChart
 	}
@@ -265,7 +372,14 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		return nil, nil, err
 	}
 
-	if err := chartutil.ProcessDependencies(chart, vals); err != nil {
+	// The pinned overrides are recorded on the last release, and applied over
+	// the values without being stored in them.
+	renderVals, err := applyOverrides(vals, lastRelease.Overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := chartutil.ProcessDependencies(chart, renderVals); err != nil {
 		return nil, nil, err
 	}
 
@@ -284,13 +398,20 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 	if err != nil {
 		return nil, nil, err
 	}
-	valuesToRender, err := chartutil.ToRenderValues(chart, vals, options, caps)
+	valuesToRender, err := chartutil.ToRenderValues(chart, renderVals, options, caps)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	glog.V(1).Info("================================================================render chart values")
-	hooks, manifestDoc, notesTxt, err := u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, u.DryRun)
+	var hooks []*release.Hook
+	var manifestDoc *bytes.Buffer
+	var notesTxt string
+	err = u.cfg.traced(ctx, "helm.render", func(context.Context) error {
+		var err error
+		hooks, manifestDoc, notesTxt, u.SkippedResources, err = u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, u.DryRun)
+		return err
+	})
 	glog.V(1).Info("================================================================render chart values done")
 	if err != nil {
 		return nil, nil, err
@@ -303,10 +424,12 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		Chart:     chart,
 		Config:    vals,
 		ConfigRaw: valuesRaw,
+		Overrides: lastRelease.Overrides,
 		Info: &release.Info{
 			FirstDeployed: currentRelease.Info.FirstDeployed,
 			LastDeployed:  Timestamper(),
 			Status:        release.StatusPendingUpgrade,
+			DeployedBy:    u.cfg.User,
 			Description:   "Preparing upgrade", // This should be overwritten later.
 		},
 		Version:  revision,
@@ -317,6 +440,23 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 	if len(notesTxt) > 0 {
 		upgradedRelease.Info.Notes = notesTxt
 	}
+	// Resume the waves of the last release if it failed with the same
+	// manifest, skipping the ones it completed.
+	if u.Waves && u.ResumeWaves && lastRelease.Info.Status == release.StatusFailed &&
+		lastRelease.Info.Waves != nil && lastRelease.Manifest == upgradedRelease.Manifest {
+		progress := *lastRelease.Info.Waves
+		upgradedRelease.Info.Waves = &progress
+		u.cfg.Log("resuming release %s revision %d after %d completed wave(s)", name, lastRelease.Version, progress.Completed)
+	}
+	if err := checkPolicies(u.PolicyChecker, upgradedRelease); err != nil {
+		return nil, nil, err
+	}
+	if u.CrossNamespaceResources, err = u.cfg.checkNamespacePolicy(upgradedRelease); err != nil {
+		return nil, nil, err
+	}
+	if u.DuplicateResources, err = u.cfg.checkDuplicateResources(upgradedRelease); err != nil {
+		return nil, nil, err
+	}
 	glog.V(1).Info("================================================================validate manifest")
 	err = validateManifest(u.cfg.KubeClient, manifestDoc.Bytes(), !u.DisableOpenAPIValidation)
 	glog.V(1).Info("================================================================validate manifest done")
@@ -341,6 +481,9 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 	if err != nil {
 		return upgradedRelease, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
 	}
+	if err := checkOrdering(target, u.Ordering, u.Waves); err != nil {
+		return upgradedRelease, errors.Wrap(err, "unable to order kubernetes objects of new release manifest")
+	}
 
 	// 如果是agent升级，则跳过添加标签这一步，因为agent原本是直接在集群中安装的没有对应标签，如果在这里加标签k8s会报错
 	if u.ChartName != "choerodon-cluster-agent" {
@@ -362,6 +505,26 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 	if err != nil {
 		return upgradedRelease, err
 	}
+	upgradedRelease.Info.Resources = u.cfg.resourceIdentities(upgradedRelease, target)
+
+	opts := u.cfg.applyOptions(u.ApplyConcurrency)
+	if u.SkipUnchanged {
+		digests, err := resourceDigests(target)
+		if err != nil {
+			return upgradedRelease, err
+		}
+		upgradedRelease.Info.ResourceDigests = digests
+		// The resources are only known to be as the previous revision
+		// applied them if it is the last one and succeeded.
+		if originalRelease.Info.Status == release.StatusDeployed && originalRelease.Version == upgradedRelease.Version-1 {
+			opts.Unchanged = unchangedSince(originalRelease.Info.ResourceDigests, digests)
+		}
+	}
+
+	var toBePruned []release.ResourceIdentity
+	if u.Prune {
+		toBePruned = pruneCandidates(u.cfg.appliedResources(originalRelease), upgradedRelease.Info.Resources, current)
+	}
 
 	// Do a basic diff using gvk + name to figure out what new resources are being created so we can validate they don't already exist
 	existingResources := make(map[string]bool)
@@ -369,17 +532,24 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 		existingResources[objectKey(r)] = true
 	}
 
-	var toBeCreated kube.ResourceList
+	var toBeCreated, applied kube.ResourceList
 	for _, r := range target {
 		if !existingResources[objectKey(r)] {
 			toBeCreated = append(toBeCreated, r)
+		} else {
+			applied = append(applied, r)
 		}
 	}
 
-	toBeUpdated, err := existingResourceConflict(toBeCreated, upgradedRelease.Name, upgradedRelease.Namespace)
+	toBeUpdated, err := existingResourceConflict(toBeCreated, upgradedRelease.Name, upgradedRelease.Namespace, u.TakeOwnership)
 	if err != nil {
 		return nil, errors.Wrap(err, "rendered manifests contain a resource that already exists. Unable to continue with update")
 	}
+	if !u.TakeOwnership {
+		if err := checkClusterScopedOwnership(applied, upgradedRelease.Name, upgradedRelease.Namespace); err != nil {
+			return nil, errors.Wrap(err, "rendered manifests contain a resource owned by another release. Unable to continue with update")
+		}
+	}
 
 	toBeUpdated.Visit(func(r *resource.Info, err error) error {
 		if err != nil {
@@ -391,6 +561,16 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 
 	if u.DryRun {
 		u.cfg.Log("dry run for %s", upgradedRelease.Name)
+		// List the resources the upgrade would prune, leaving out the ones
+		// it would not delete.
+		targets, err := u.cfg.pruneTargets(upgradedRelease, toBePruned)
+		if err != nil {
+			u.cfg.releaseLogger(upgradedRelease).Warn("unable to list the resources to prune", "error", err)
+		}
+		u.Pruned = nil
+		for _, info := range targets {
+			u.Pruned = append(u.Pruned, resourceIdentity(info))
+		}
 		if len(u.Description) > 0 {
 			upgradedRelease.Info.Description = u.Description
 		} else {
@@ -407,7 +587,7 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 	ctxChan := make(chan resultMessage)
 	doneChan := make(chan interface{})
 	defer close(doneChan)
-	go u.releasingUpgrade(rChan, upgradedRelease, current, target, originalRelease)
+	go u.releasingUpgrade(ctx, rChan, upgradedRelease, current, target, originalRelease, toBePruned, opts)
 	go u.handleContext(ctx, doneChan, ctxChan, upgradedRelease)
 	select {
 	case result := <-rChan:
@@ -441,12 +621,14 @@ func (u *Upgrade) handleContext(ctx context.Context, done chan interface{}, c ch
 		return
 	}
 }
-func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *release.Release, current kube.ResourceList, target kube.ResourceList, originalRelease *release.Release) {
+func (u *Upgrade) releasingUpgrade(ctx context.Context, c chan<- resultMessage, upgradedRelease *release.Release, current kube.ResourceList, target kube.ResourceList, originalRelease *release.Release, toBePruned []release.ResourceIdentity, opts kube.ApplyOptions) {
 	// pre-upgrade hooks
 
 	glog.V(1).Info("================================================================execute webhook")
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPreUpgrade, u.Timeout, u.ImagePullSecret, u.Command, u.V1Command, u.AppServiceId, u.V1AppServiceId, u.Commit, u.ChartVersion, u.ReleaseName, u.ChartName, u.AgentVersion, "", originalRelease.Namespace, false); err != nil {
+		if err := u.cfg.traced(ctx, "helm.hooks.pre-upgrade", func(context.Context) error {
+			return u.cfg.execHook(upgradedRelease, release.HookPreUpgrade, u.Timeout, u.ImagePullSecret, u.Command, u.V1Command, u.AppServiceId, u.V1AppServiceId, u.Commit, u.ChartVersion, u.ReleaseName, u.ChartName, u.AgentVersion, "", originalRelease.Namespace, false)
+		}); err != nil {
 			u.reportToPerformUpgrade(c, upgradedRelease, kube.ResourceList{}, fmt.Errorf("pre-upgrade hooks failed: %s", err))
 			return
 		}
@@ -456,7 +638,12 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 	glog.V(1).Info("================================================================execute webhook done")
 
 	glog.V(1).Info("================================================================update resource")
-	results, err := u.cfg.KubeClient.Update(current, target, u.Force)
+	var results *kube.Result
+	err := u.cfg.traced(ctx, "helm.kube.update", func(context.Context) error {
+		var err error
+		results, err = u.cfg.updateResources(upgradedRelease, current, target, u.Force, u.Ordering, u.Waves, u.Timeout, opts)
+		return err
+	}, resourceCountKey.Int(len(target)))
 	glog.V(1).Info("================================================================update resource done")
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
@@ -466,57 +653,71 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 
 	if u.Recreate {
 		// NOTE: Because this is not critical for a release to succeed, we just
-		// log if an error occurs and continue onward. If we ever introduce log
-		// levels, we should make these error level logs so users are notified
-		// that they'll need to go do the cleanup on their own
+		// log if an error occurs and continue onward, at error level so users
+		// are notified that they'll need to go do the cleanup on their own
 		if err := recreate(u.cfg, results.Updated); err != nil {
-			u.cfg.Log(err.Error())
+			u.cfg.releaseLogger(upgradedRelease).Error("unable to recreate pods", "error", err)
 		}
 	}
 
 	if u.Wait {
 		u.cfg.Log(
-			"waiting for release %s resources (created: %d updated: %d  deleted: %d)",
-			upgradedRelease.Name, len(results.Created), len(results.Updated), len(results.Deleted))
-		if u.WaitForJobs {
-			if err := u.cfg.KubeClient.WaitWithJobs(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
-				return
-			}
-		} else {
-			if err := u.cfg.KubeClient.Wait(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
-				return
-			}
+			"waiting for release %s resources (created: %d updated: %d unchanged: %d deleted: %d)",
+			upgradedRelease.Name, len(results.Created), len(results.Updated), len(results.Unchanged), len(results.Deleted))
+		if err := u.cfg.traced(ctx, "helm.kube.wait", func(context.Context) error {
+			return u.cfg.waitForResources(upgradedRelease, target, u.Timeout, u.WaitForJobs, u.WaitStrategy)
+		}, resourceCountKey.Int(len(target))); err != nil {
+			u.cfg.recordRelease(originalRelease)
+			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+			return
 		}
 	}
 
 	// post-upgrade hooks
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPostUpgrade, u.Timeout, u.ImagePullSecret, u.Command, u.V1Command, u.AppServiceId, u.V1AppServiceId, u.Commit, u.ChartVersion, u.ReleaseName, u.ChartName, u.AgentVersion, "", originalRelease.Namespace, false); err != nil {
+		if err := u.cfg.traced(ctx, "helm.hooks.post-upgrade", func(context.Context) error {
+			return u.cfg.execHook(upgradedRelease, release.HookPostUpgrade, u.Timeout, u.ImagePullSecret, u.Command, u.V1Command, u.AppServiceId, u.V1AppServiceId, u.Commit, u.ChartVersion, u.ReleaseName, u.ChartName, u.AgentVersion, "", originalRelease.Namespace, false)
+		}); err != nil {
 			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, fmt.Errorf("post-upgrade hooks failed: %s", err))
 			return
 		}
 	}
 
+	if u.Prune {
+		// NOTE: Like recreating pods, pruning is not critical for the release
+		// to succeed, so errors are only logged.
+		pruned, err := u.cfg.prune(upgradedRelease, toBePruned)
+		if err != nil {
+			u.cfg.releaseLogger(upgradedRelease).Warn("unable to prune resources", "error", err)
+		}
+		u.Pruned = pruned
+	}
+
+	notes, err := postProcessNotes(upgradedRelease)
+	if err != nil {
+		u.cfg.releaseLogger(upgradedRelease).Warn("unable to post-process the notes", "error", err)
+	}
+	upgradedRelease.Info.Notes = notes
+
 	originalRelease.Info.Status = release.StatusSuperseded
 	u.cfg.recordRelease(originalRelease)
 
+	u.cfg.markCompleted(upgradedRelease)
 	upgradedRelease.Info.Status = release.StatusDeployed
 	if len(u.Description) > 0 {
 		upgradedRelease.Info.Description = u.Description
 	} else {
 		upgradedRelease.Info.Description = "Upgrade complete"
 	}
+	u.cfg.releaseLogger(upgradedRelease).Info("release upgraded")
 	u.reportToPerformUpgrade(c, upgradedRelease, nil, nil)
 }
 
 func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, err error) (*release.Release, error) {
 	msg := fmt.Sprintf("Upgrade %q failed: %s", rel.Name, err)
-	u.cfg.Log("warning: %s", msg)
+	u.cfg.releaseLogger(rel).Warn("upgrade failed", "error", err)
 
+	u.cfg.markCompleted(rel)
 	rel.Info.Status = release.StatusFailed
 	rel.Info.Description = msg
 	u.cfg.recordRelease(rel)
@@ -559,6 +760,8 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		rollin.Version = filteredHistory[0].Version
 		rollin.Wait = true
 		rollin.WaitForJobs = u.WaitForJobs
+		rollin.WaitStrategy = u.WaitStrategy
+		rollin.ApplyConcurrency = u.ApplyConcurrency
 		rollin.DisableHooks = u.DisableHooks
 		rollin.Recreate = u.Recreate
 		rollin.Force = u.Force
@@ -587,6 +790,24 @@ func (u *Upgrade) reuseValues(chart *chart.Chart, current *release.Release, newV
 		return newVals, nil
 	}
 
+	// With SmartReuseValues, the old values are merged with the new chart's
+	// defaults instead of freezing the old chart's defaults.
+	if u.ReuseValues && u.SmartReuseValues {
+		u.cfg.Log("merging the old release's values with the new chart's defaults")
+
+		var oldDefaults map[string]interface{}
+		if current.Chart != nil {
+			oldDefaults = current.Chart.Values
+		}
+		reused, warnings := chartutil.MergeReusedValues(current.Config, oldDefaults, chart.Values)
+		for _, w := range warnings {
+			u.cfg.Log("warning: %s", w)
+		}
+		u.ReuseValuesWarnings = warnings
+
+		return chartutil.CoalesceTables(newVals, reused), nil
+	}
+
 	// If the ReuseValues flag is set, we always copy the old values over the new config's values.
 	if u.ReuseValues {
 		u.cfg.Log("reusing the old release's values")
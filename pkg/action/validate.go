@@ -37,7 +37,11 @@ const (
 	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
 )
 
-func existingResourceConflict(resources kube.ResourceList, releaseName, releaseNamespace string) (kube.ResourceList, error) {
+// existingResourceConflict returns the resources that already exist, which
+// the release must update instead of creating them. It fails if they are not
+// owned by the release, with a ResourceOwnershipError if another release
+// owns them, unless takeOwnership is set.
+func existingResourceConflict(resources kube.ResourceList, releaseName, releaseNamespace string, takeOwnership bool) (kube.ResourceList, error) {
 	var requireUpdate kube.ResourceList
 
 	err := resources.Visit(func(info *resource.Info, err error) error {
@@ -54,6 +58,14 @@ func existingResourceConflict(resources kube.ResourceList, releaseName, releaseN
 			return errors.Wrapf(err, "could not get information about the resource %s", resourceString(info))
 		}
 
+		if takeOwnership {
+			requireUpdate.Append(info)
+			return nil
+		}
+		if err := ownedByOtherRelease(info, existing, releaseName, releaseNamespace); err != nil {
+			return errors.Wrapf(err, "%s exists and cannot be imported into the current release", resourceString(info))
+		}
+
 		// Allow adoption of the resource if it is managed by Helm and is annotated with correct release name and namespace.
 		if err := checkOwnership(existing, releaseName, releaseNamespace); err != nil {
 			return fmt.Errorf("%s exists and cannot be imported into the current release: %s", resourceString(info), err)
@@ -0,0 +1,316 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package bundle reads and writes offline chart bundles.
+
+A bundle is a gzipped tar archive holding everything needed to install a
+chart without network access, for air-gapped delivery: the chart archive with
+its resolved dependencies, its provenance file if any, the list of the
+container images it references, and a repository index of the chart, so that
+the bundle can also be served as a chart repository once unpacked.
+*/
+package bundle // import "github.com/open-hand/helm/pkg/bundle"
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/repo"
+	"github.com/open-hand/helm/pkg/sbom"
+)
+
+// APIVersion is the version of the bundle format written by Write.
+const APIVersion = "v1"
+
+const (
+	// ManifestFile is the name of the file describing the contents of a
+	// bundle.
+	ManifestFile = "bundle.json"
+	// ImagesFile is the name of the file listing the images referenced by
+	// the chart of a bundle, one per line, e.g. for mirroring them.
+	ImagesFile = "images.txt"
+	// IndexFile is the name of the repository index of the chart of a
+	// bundle.
+	IndexFile = "index.yaml"
+)
+
+// maxFileSize bounds the size of a single file read from a bundle.
+const maxFileSize = 512 * 1024 * 1024
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	APIVersion string    `json:"apiVersion"`
+	Created    time.Time `json:"created"`
+	// Chart is the chart of the bundle.
+	Chart Chart `json:"chart"`
+	// Dependencies are the dependency charts included in the chart archive,
+	// at any depth.
+	Dependencies []Chart `json:"dependencies,omitempty"`
+	// Images are the container images referenced by the values of the
+	// chart and of its dependencies, sorted.
+	Images []Image `json:"images,omitempty"`
+}
+
+// Chart is a chart of a bundle.
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Repository is the repository a dependency was resolved from.
+	Repository string `json:"repository,omitempty"`
+	// Path is the path of the chart archive in the bundle.
+	Path string `json:"path,omitempty"`
+	// Digest is the SHA-256 digest of the chart archive, hex encoded.
+	Digest string `json:"digest,omitempty"`
+	// Provenance is the path of the provenance file of the chart in the
+	// bundle, if it has one.
+	Provenance string `json:"provenance,omitempty"`
+}
+
+// Image is a container image referenced by the chart of a bundle.
+type Image struct {
+	Reference string `json:"reference"`
+	// Digest is the digest the image is pinned to by its reference, if any.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Bundle is an offline chart bundle.
+type Bundle struct {
+	Manifest Manifest
+	// Archive is the chart archive.
+	Archive []byte
+	// Provenance is the provenance file of the chart archive, if any.
+	Provenance []byte
+}
+
+// New returns the bundle of the chart archive and its provenance file,
+// which may be nil. The dependencies of the chart must be included in the
+// archive.
+func New(archive, prov []byte, created time.Time) (*Bundle, error) {
+	c, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the chart archive")
+	}
+	digest, err := provenance.Digest(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+
+	m := Manifest{
+		APIVersion: APIVersion,
+		Created:    created,
+		Chart: Chart{
+			Name:    c.Name(),
+			Version: c.Metadata.Version,
+			Path:    fmt.Sprintf("chart/%s-%s.tgz", c.Name(), c.Metadata.Version),
+			Digest:  digest,
+		},
+	}
+	if prov != nil {
+		m.Chart.Provenance = m.Chart.Path + ".prov"
+	}
+
+	images := map[string]bool{}
+	var walk func(c *chart.Chart)
+	walk = func(c *chart.Chart) {
+		for _, image := range sbom.Images(c.Values) {
+			images[image] = true
+		}
+		for _, dep := range c.Dependencies() {
+			d := Chart{Name: dep.Name(), Version: dep.Metadata.Version}
+			for _, req := range c.Metadata.Dependencies {
+				if req.Name == dep.Name() {
+					d.Repository = req.Repository
+				}
+			}
+			m.Dependencies = append(m.Dependencies, d)
+			walk(dep)
+		}
+	}
+	walk(c)
+	sort.Slice(m.Dependencies, func(i, j int) bool {
+		a, b := m.Dependencies[i], m.Dependencies[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	for image := range images {
+		img := Image{Reference: image}
+		if i := strings.Index(image, "@"); i >= 0 {
+			img.Digest = image[i+1:]
+		}
+		m.Images = append(m.Images, img)
+	}
+	sort.Slice(m.Images, func(i, j int) bool { return m.Images[i].Reference < m.Images[j].Reference })
+
+	return &Bundle{Manifest: m, Archive: archive, Provenance: prov}, nil
+}
+
+// LoadChart loads the chart of the bundle.
+func (b *Bundle) LoadChart() (*chart.Chart, error) {
+	return loader.LoadArchive(bytes.NewReader(b.Archive))
+}
+
+// Verify verifies the provenance file of the chart of the bundle with the
+// keys of the keyring file.
+func (b *Bundle) Verify(keyring string) (*provenance.Verification, error) {
+	if b.Provenance == nil {
+		return nil, errors.Errorf("the bundle of %s-%s has no provenance file", b.Manifest.Chart.Name, b.Manifest.Chart.Version)
+	}
+	sig, err := provenance.NewFromKeyring(keyring, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load keyring")
+	}
+	return sig.VerifyData(b.Archive, b.Manifest.Chart.Path[strings.LastIndex(b.Manifest.Chart.Path, "/")+1:], b.Provenance)
+}
+
+// Write writes the bundle to out as a gzipped tar archive, which can be read
+// back with Read.
+func Write(out io.Writer, b *Bundle) error {
+	mb, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	c, err := b.LoadChart()
+	if err != nil {
+		return err
+	}
+	index := repo.NewIndexFile()
+	if err := index.MustAdd(c.Metadata, b.Manifest.Chart.Path, "", b.Manifest.Chart.Digest); err != nil {
+		return err
+	}
+	index.Entries[c.Name()][0].Created = b.Manifest.Created
+	index.Generated = b.Manifest.Created
+	ib, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	var images bytes.Buffer
+	for _, image := range b.Manifest.Images {
+		fmt.Fprintln(&images, image.Reference)
+	}
+
+	zw := gzip.NewWriter(out)
+	tw := tar.NewWriter(zw)
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{ManifestFile, mb},
+		{b.Manifest.Chart.Path, b.Archive},
+		{b.Manifest.Chart.Provenance, b.Provenance},
+		{ImagesFile, images.Bytes()},
+		{IndexFile, ib},
+	}
+	for _, f := range files {
+		if f.name == "" {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    f.name,
+			Mode:    0644,
+			Size:    int64(len(f.body)),
+			ModTime: b.Manifest.Created,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.body); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read reads a bundle written by Write, checking the digest of its chart
+// archive.
+func Read(in io.Reader) (*Bundle, error) {
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "bundle is not a gzipped archive")
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read bundle")
+		}
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hd.Size > maxFileSize {
+			return nil, errors.Errorf("file %q in bundle is too large", hd.Name)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxFileSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %q from bundle", hd.Name)
+		}
+		files[hd.Name] = b
+	}
+
+	mb, ok := files[ManifestFile]
+	if !ok {
+		return nil, errors.Errorf("bundle has no %s", ManifestFile)
+	}
+	b := &Bundle{}
+	if err := json.Unmarshal(mb, &b.Manifest); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", ManifestFile)
+	}
+	if b.Manifest.APIVersion != APIVersion {
+		return nil, errors.Errorf("unsupported bundle version %q", b.Manifest.APIVersion)
+	}
+	if b.Archive, ok = files[b.Manifest.Chart.Path]; !ok {
+		return nil, errors.Errorf("bundle is missing %q", b.Manifest.Chart.Path)
+	}
+	digest, err := provenance.Digest(bytes.NewReader(b.Archive))
+	if err != nil {
+		return nil, err
+	}
+	if digest != b.Manifest.Chart.Digest {
+		return nil, errors.Errorf("digest of %q is sha256:%s, expected sha256:%s", b.Manifest.Chart.Path, digest, b.Manifest.Chart.Digest)
+	}
+	if b.Manifest.Chart.Provenance != "" {
+		if b.Provenance, ok = files[b.Manifest.Chart.Provenance]; !ok {
+			return nil, errors.Errorf("bundle is missing %q", b.Manifest.Chart.Provenance)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+func testArchive(t *testing.T) []byte {
+	t.Helper()
+	sub := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "redis", Version: "1.2.3"},
+		Templates: []*chart.File{{Name: "templates/statefulset.yaml", Data: []byte("kind: StatefulSet")}},
+		Raw:       []*chart.File{{Name: "values.yaml", Data: []byte("image:\n  repository: bitnami/redis\n  tag: \"6.2\"\n")}},
+	}
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   chart.APIVersionV2,
+			Name:         "hello",
+			Version:      "0.1.0",
+			Dependencies: []*chart.Dependency{{Name: "redis", Version: "1.2.3", Repository: "https://charts.example.com"}},
+		},
+		Templates: []*chart.File{{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment")}},
+		Raw:       []*chart.File{{Name: "values.yaml", Data: []byte("image: nginx@sha256:abcd\n")}},
+	}
+	ch.AddDependency(sub)
+
+	dir := t.TempDir()
+	name, err := chartutil.Save(ch, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestWriteRead(t *testing.T) {
+	archive := testArchive(t)
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	b, err := New(archive, []byte("signature"), created)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := b.Manifest
+	if m.Chart.Name != "hello" || m.Chart.Version != "0.1.0" || m.Chart.Path != "chart/hello-0.1.0.tgz" {
+		t.Errorf("unexpected chart %+v", m.Chart)
+	}
+	if m.Chart.Provenance != "chart/hello-0.1.0.tgz.prov" {
+		t.Errorf("unexpected provenance path %q", m.Chart.Provenance)
+	}
+	wantDeps := []Chart{{Name: "redis", Version: "1.2.3", Repository: "https://charts.example.com"}}
+	if !reflect.DeepEqual(m.Dependencies, wantDeps) {
+		t.Errorf("expected dependencies %+v, got %+v", wantDeps, m.Dependencies)
+	}
+	wantImages := []Image{{Reference: "bitnami/redis:6.2"}, {Reference: "nginx@sha256:abcd", Digest: "sha256:abcd"}}
+	if !reflect.DeepEqual(m.Images, wantImages) {
+		t.Errorf("expected images %+v, got %+v", wantImages, m.Images)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Manifest, b.Manifest) {
+		t.Errorf("expected manifest %+v, got %+v", b.Manifest, got.Manifest)
+	}
+	if !bytes.Equal(got.Archive, archive) || string(got.Provenance) != "signature" {
+		t.Error("unexpected chart archive or provenance file")
+	}
+	c, err := got.LoadChart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name() != "hello" || len(c.Dependencies()) != 1 {
+		t.Errorf("unexpected chart %s with %d dependencies", c.Name(), len(c.Dependencies()))
+	}
+}
+
+func TestReadDigestMismatch(t *testing.T) {
+	b, err := New(testArchive(t), nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Manifest.Chart.Digest = strings.Repeat("0", 64)
+	var buf bytes.Buffer
+	if err := Write(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buf); err == nil || !strings.Contains(err.Error(), "expected sha256:") {
+		t.Errorf("expected a digest mismatch, got %v", err)
+	}
+}
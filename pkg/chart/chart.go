@@ -18,6 +18,7 @@ package chart
 import (
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -143,6 +144,7 @@ func (ch *Chart) CRDs() []*File {
 			files = append(files, f)
 		}
 	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
 	// Get CRDs from dependencies, too.
 	for _, dep := range ch.Dependencies() {
 		files = append(files, dep.CRDs()...)
@@ -160,6 +162,7 @@ func (ch *Chart) CRDObjects() []CRD {
 			crds = append(crds, mycrd)
 		}
 	}
+	sort.Slice(crds, func(i, j int) bool { return crds[i].Name < crds[j].Name })
 	// Get CRDs from dependencies, too.
 	for _, dep := range ch.Dependencies() {
 		crds = append(crds, dep.CRDObjects()...)
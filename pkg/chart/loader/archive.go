@@ -100,8 +100,15 @@ func ensureArchive(name string, raw *os.File) error {
 
 // LoadArchiveFiles reads in files out of an archive into memory. This function
 // performs important path security checks and should always be used before
-// expanding a tarball
+// expanding a tarball. The archive must stay within DefaultLimits.
 func LoadArchiveFiles(in io.Reader) ([]*BufferedFile, error) {
+	return LoadArchiveFilesWithLimits(in, DefaultLimits)
+}
+
+// LoadArchiveFilesWithLimits is LoadArchiveFiles with the given limits. The
+// archives breaking the limits or the path security checks are rejected with
+// a *Violation.
+func LoadArchiveFilesWithLimits(in io.Reader, limits Limits) ([]*BufferedFile, error) {
 	unzipped, err := gzip.NewReader(in)
 	if err != nil {
 		return nil, err
@@ -109,6 +116,7 @@ func LoadArchiveFiles(in io.Reader) ([]*BufferedFile, error) {
 	defer unzipped.Close()
 
 	files := []*BufferedFile{}
+	var size int64
 	tr := tar.NewReader(unzipped)
 	for {
 		b := bytes.NewBuffer(nil)
@@ -131,6 +139,9 @@ func LoadArchiveFiles(in io.Reader) ([]*BufferedFile, error) {
 		case tar.TypeXGlobalHeader, tar.TypeXHeader:
 			continue
 		}
+		if !hd.FileInfo().Mode().IsRegular() {
+			return nil, violation(RuleFileType, hd.Name, "chart illegally contains special file %q", hd.Name)
+		}
 
 		// Archive could contain \ if generated on Windows
 		delimiter := "/"
@@ -145,16 +156,16 @@ func LoadArchiveFiles(in io.Reader) ([]*BufferedFile, error) {
 		n = strings.ReplaceAll(n, delimiter, "/")
 
 		if path.IsAbs(n) {
-			return nil, errors.New("chart illegally contains absolute paths")
+			return nil, violation(RuleAbsolutePath, hd.Name, "chart illegally contains absolute paths")
 		}
 
 		n = path.Clean(n)
 		if n == "." {
 			// In this case, the original path was relative when it should have been absolute.
-			return nil, errors.Errorf("chart illegally contains content outside the base directory: %q", hd.Name)
+			return nil, violation(RuleOutsideBase, hd.Name, "chart illegally contains content outside the base directory: %q", hd.Name)
 		}
 		if strings.HasPrefix(n, "..") {
-			return nil, errors.New("chart illegally references parent directory")
+			return nil, violation(RuleParentDirectory, hd.Name, "chart illegally references parent directory")
 		}
 
 		// In some particularly arcane acts of path creativity, it is possible to intermix
@@ -162,16 +173,32 @@ func LoadArchiveFiles(in io.Reader) ([]*BufferedFile, error) {
 		// c:/foo even after all the built-in absolute path checks. So we explicitly check
 		// for this condition.
 		if drivePathPattern.MatchString(n) {
-			return nil, errors.New("chart contains illegally named files")
+			return nil, violation(RuleIllegalName, hd.Name, "chart contains illegally named files")
 		}
 
 		if parts[0] == "Chart.yaml" {
-			return nil, errors.New("chart yaml not in base directory")
+			return nil, violation(RuleOutsideBase, hd.Name, "chart yaml not in base directory")
 		}
 
-		if _, err := io.Copy(b, tr); err != nil {
+		if limits.MaxDepth > 0 && strings.Count(n, "/") > limits.MaxDepth {
+			return nil, violation(RuleMaxDepth, hd.Name, "chart file %q is nested deeper than %d directories", n, limits.MaxDepth)
+		}
+		if limits.MaxFiles > 0 && len(files) >= limits.MaxFiles {
+			return nil, violation(RuleMaxFiles, hd.Name, "chart archive contains more than %d files", limits.MaxFiles)
+		}
+
+		r := io.Reader(tr)
+		if limits.MaxSize > 0 {
+			r = io.LimitReader(tr, limits.MaxSize-size+1)
+		}
+		copied, err := io.Copy(b, r)
+		if err != nil {
 			return nil, err
 		}
+		size += copied
+		if limits.MaxSize > 0 && size > limits.MaxSize {
+			return nil, violation(RuleMaxSize, hd.Name, "chart archive is larger than %d bytes uncompressed", limits.MaxSize)
+		}
 
 		data := bytes.TrimPrefix(b.Bytes(), utf8bom)
 
@@ -194,3 +221,78 @@ func LoadArchive(in io.Reader) (*chart.Chart, error) {
 
 	return LoadFiles(files)
 }
+
+// LoadArchiveWithLimits is LoadArchive with the given limits, see
+// LoadArchiveFilesWithLimits.
+func LoadArchiveWithLimits(in io.Reader, limits Limits) (*chart.Chart, error) {
+	files, err := LoadArchiveFilesWithLimits(in, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadFiles(files)
+}
+
+// ErrIncompleteArchive is returned by LoadPartialArchiveFiles when the files
+// may be further in the archive than the part read.
+var ErrIncompleteArchive = errors.New("incomplete chart archive")
+
+// LoadPartialArchiveFiles reads the files with the given names, relative to
+// the chart directory, e.g. Chart.yaml, from the beginning of a chart
+// archive. It is used to read the metadata of a chart without downloading
+// its whole archive.
+//
+// complete tells whether archive is the whole archive. The files missing
+// from a complete archive are left out, and ErrIncompleteArchive is returned
+// if some of them may be in the rest of an incomplete one.
+func LoadPartialArchiveFiles(archive []byte, complete bool, names ...string) ([]*BufferedFile, error) {
+	incomplete := func(err error) error {
+		if !complete && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return ErrIncompleteArchive
+		}
+		return err
+	}
+
+	unzipped, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, incomplete(err)
+	}
+	defer unzipped.Close()
+
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+	var files []*BufferedFile
+	tr := tar.NewReader(unzipped)
+	for len(wanted) > 0 {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			if !complete {
+				return nil, ErrIncompleteArchive
+			}
+			break
+		}
+		if err != nil {
+			return nil, incomplete(err)
+		}
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		n := strings.ReplaceAll(hd.Name, "\\", "/")
+		if i := strings.IndexByte(n, '/'); i >= 0 {
+			n = path.Clean(n[i+1:])
+		}
+		if !wanted[n] {
+			continue
+		}
+		b := bytes.NewBuffer(nil)
+		if _, err := io.Copy(b, tr); err != nil {
+			return nil, incomplete(err)
+		}
+		files = append(files, &BufferedFile{Name: n, Data: bytes.TrimPrefix(b.Bytes(), utf8bom)})
+		delete(wanted, n)
+	}
+	return files, nil
+}
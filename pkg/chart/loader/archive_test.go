@@ -20,6 +20,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"math/rand"
 	"testing"
 )
 
@@ -88,3 +89,111 @@ func TestLoadArchiveFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadPartialArchiveFiles(t *testing.T) {
+	assets := make([]byte, 256*1024)
+	rand.New(rand.NewSource(1)).Read(assets)
+
+	buf := &bytes.Buffer{}
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"mychart/Chart.yaml", []byte("apiVersion: v2\nname: mychart\nversion: 0.1.0\n")},
+		{"mychart/charts/sub/values.yaml", []byte("sub: true\n")},
+		{"mychart/values.yaml", []byte("replicas: 1\n")},
+		{"mychart/files/assets.bin", assets},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Mode: 0644, Size: int64(len(f.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tw.Close()
+	_ = gzw.Close()
+	archive := buf.Bytes()
+
+	files, err := LoadPartialArchiveFiles(archive[:4096], false, "Chart.yaml", "values.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Name != "Chart.yaml" || files[1].Name != "values.yaml" || string(files[1].Data) != "replicas: 1\n" {
+		t.Errorf("expected Chart.yaml and the values.yaml of the chart, got %v", files)
+	}
+
+	if _, err := LoadPartialArchiveFiles(archive[:10], false, "Chart.yaml"); err != ErrIncompleteArchive {
+		t.Errorf("expected ErrIncompleteArchive for a truncated archive, got %v", err)
+	}
+	if _, err := LoadPartialArchiveFiles(archive[:4096], false, "README.md"); err != ErrIncompleteArchive {
+		t.Errorf("expected ErrIncompleteArchive for a file that may be further in the archive, got %v", err)
+	}
+
+	files, err = LoadPartialArchiveFiles(archive, true, "Chart.yaml", "README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name != "Chart.yaml" {
+		t.Errorf("expected only Chart.yaml from the complete archive, got %v", files)
+	}
+}
+
+func TestLoadArchiveFilesWithLimits(t *testing.T) {
+	archive := func(headers ...*tar.Header) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		gzw := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gzw)
+		for _, hd := range headers {
+			if err := tw.WriteHeader(hd); err != nil {
+				t.Fatal(err)
+			}
+			if hd.Typeflag == tar.TypeReg {
+				if _, err := tw.Write(bytes.Repeat([]byte("a"), int(hd.Size))); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		_ = tw.Close()
+		_ = gzw.Close()
+		return buf
+	}
+	file := func(name string, size int64) *tar.Header {
+		return &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: size}
+	}
+	limits := Limits{MaxFiles: 2, MaxSize: 10, MaxDepth: 2}
+
+	for _, tt := range []struct {
+		name    string
+		headers []*tar.Header
+		rule    ViolationRule
+	}{
+		{"within limits", []*tar.Header{file("mychart/Chart.yaml", 5), file("mychart/a/b/c.txt", 5)}, ""},
+		{"too many files", []*tar.Header{file("mychart/a", 1), file("mychart/b", 1), file("mychart/c", 1)}, RuleMaxFiles},
+		{"too large", []*tar.Header{file("mychart/a", 6), file("mychart/b", 5)}, RuleMaxSize},
+		{"too deep", []*tar.Header{file("mychart/a/b/c/d.txt", 1)}, RuleMaxDepth},
+		{"parent directory", []*tar.Header{file("mychart/../../etc/passwd", 1)}, RuleParentDirectory},
+		{"absolute path", []*tar.Header{file("mychart//etc/passwd", 1)}, RuleAbsolutePath},
+		{"symlink", []*tar.Header{{Typeflag: tar.TypeSymlink, Name: "mychart/values.yaml", Linkname: "/etc/passwd"}}, RuleFileType},
+		{"device", []*tar.Header{{Typeflag: tar.TypeChar, Name: "mychart/null", Devmajor: 1, Devminor: 3}}, RuleFileType},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadArchiveFilesWithLimits(archive(tt.headers...), limits)
+			if tt.rule == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			v, ok := AsViolation(err)
+			if !ok {
+				t.Fatalf("expected a violation of %s, got %v", tt.rule, err)
+			}
+			if v.Rule != tt.rule || v.Path != tt.headers[len(tt.headers)-1].Name {
+				t.Errorf("expected a violation of %s by %q, got %+v", tt.rule, tt.headers[len(tt.headers)-1].Name, v)
+			}
+		})
+	}
+}
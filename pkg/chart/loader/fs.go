@@ -0,0 +1,115 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/ignore"
+	"github.com/open-hand/helm/pkg/chart"
+)
+
+// FSLoader loads a chart from the root of a file system.
+type FSLoader struct {
+	FS fs.FS
+}
+
+// Load loads the chart
+func (l FSLoader) Load() (*chart.Chart, error) {
+	return LoadFS(l.FS)
+}
+
+// LoadFS loads a chart from the root of fsys, such as an embed.FS, like
+// LoadDir loads it from a directory. Use fs.Sub to load a chart from a
+// subdirectory of fsys.
+//
+// The .helmignore file of the chart is evaluated, and irregular files are
+// rejected.
+func LoadFS(fsys fs.FS) (*chart.Chart, error) {
+	// Just used for errors.
+	c := &chart.Chart{}
+
+	rules := ignore.Empty()
+	if f, err := fsys.Open(ignore.HelmIgnore); err == nil {
+		r, err := ignore.Parse(f)
+		f.Close()
+		if err != nil {
+			return c, err
+		}
+		rules = r
+	}
+	rules.AddDefaults()
+
+	files := []*BufferedFile{}
+	walk := func(n string, d fs.DirEntry, err error) error {
+		if n == "." {
+			// No need to process top level. Avoid bug with helmignore .* matching
+			// empty names. See issue 1779.
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		fi, err := fs.Stat(fsys, n)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Directory-based ignore rules should involve skipping the entire
+			// contents of that directory.
+			if rules.Ignore(n, fi) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// If a .helmignore file matches, skip this file.
+		if rules.Ignore(n, fi) {
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() {
+			return fmt.Errorf("cannot load irregular file %s as it has file mode type bits set", n)
+		}
+
+		data, err := fs.ReadFile(fsys, n)
+		if err != nil {
+			return errors.Wrapf(err, "error reading %s", n)
+		}
+
+		data = bytes.TrimPrefix(data, utf8bom)
+
+		files = append(files, &BufferedFile{Name: n, Data: data})
+		return nil
+	}
+	if err := fs.WalkDir(fsys, ".", walk); err != nil {
+		return c, err
+	}
+
+	return LoadFiles(files)
+}
+
+// LoadArchiveBytes loads a chart from the bytes of a chart archive, e.g.
+// one stored in a database or received over the network, within
+// DefaultLimits.
+func LoadArchiveBytes(archive []byte) (*chart.Chart, error) {
+	return LoadArchive(bytes.NewReader(archive))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package loader
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Limits bounds what is extracted from a chart archive, to load untrusted
+// charts safely. A zero field is no limit.
+type Limits struct {
+	// MaxFiles is the maximum number of files in the archive.
+	MaxFiles int
+	// MaxSize is the maximum total uncompressed size of the files, in bytes.
+	MaxSize int64
+	// MaxDepth is the maximum number of directories a file is nested in,
+	// below the chart directory.
+	MaxDepth int
+}
+
+// DefaultLimits are the limits LoadArchiveFiles and LoadArchive enforce.
+var DefaultLimits = Limits{
+	MaxFiles: 10000,
+	MaxSize:  100 * 1024 * 1024,
+	MaxDepth: 64,
+}
+
+// ViolationRule is a rule a chart archive violates.
+type ViolationRule string
+
+const (
+	// RuleAbsolutePath rejects files with absolute paths.
+	RuleAbsolutePath ViolationRule = "absolute-path"
+	// RuleParentDirectory rejects files referencing a parent directory of
+	// the chart directory.
+	RuleParentDirectory ViolationRule = "parent-directory"
+	// RuleOutsideBase rejects files outside of the chart directory.
+	RuleOutsideBase ViolationRule = "outside-base"
+	// RuleIllegalName rejects files whose names can be read as Windows
+	// drive paths.
+	RuleIllegalName ViolationRule = "illegal-name"
+	// RuleFileType rejects links, devices and other special files.
+	RuleFileType ViolationRule = "file-type"
+	// RuleMaxFiles rejects archives with more than Limits.MaxFiles files.
+	RuleMaxFiles ViolationRule = "max-files"
+	// RuleMaxSize rejects archives larger than Limits.MaxSize uncompressed.
+	RuleMaxSize ViolationRule = "max-size"
+	// RuleMaxDepth rejects files nested deeper than Limits.MaxDepth.
+	RuleMaxDepth ViolationRule = "max-depth"
+)
+
+// Violation is returned by the loading of a chart archive that violates a
+// rule.
+type Violation struct {
+	Rule ViolationRule
+	// Path is the path of the offending file in the archive.
+	Path    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// AsViolation returns the violation err is, or wraps, if any.
+func AsViolation(err error) (*Violation, bool) {
+	var v *Violation
+	ok := errors.As(err, &v)
+	return v, ok
+}
+
+func violation(rule ViolationRule, path, format string, args ...interface{}) *Violation {
+	return &Violation{Rule: rule, Path: path, Message: fmt.Sprintf(format, args...)}
+}
@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -162,7 +163,16 @@ func LoadFiles(files []*BufferedFile) (*chart.Chart, error) {
 		return c, err
 	}
 
-	for n, files := range subcharts {
+	// Load the subcharts in the order of their names, so that the
+	// dependencies of a chart, and what is rendered from them, do not depend
+	// on the order of the files.
+	names := make([]string, 0, len(subcharts))
+	for n := range subcharts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		files := subcharts[n]
 		var sc *chart.Chart
 		var err error
 		switch {
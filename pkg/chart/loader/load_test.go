@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
@@ -28,6 +29,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/open-hand/helm/pkg/chart"
@@ -48,6 +50,59 @@ func TestLoadDir(t *testing.T) {
 	verifyDependenciesLock(t, c)
 }
 
+func TestLoadFS(t *testing.T) {
+	c, err := FSLoader{FS: os.DirFS("testdata/frobnitz")}.Load()
+	if err != nil {
+		t.Fatalf("Failed to load testdata: %s", err)
+	}
+	verifyFrobnitz(t, c)
+	verifyChart(t, c)
+	verifyDependencies(t, c)
+	verifyDependenciesLock(t, c)
+
+	fsys := fstest.MapFS{
+		"mychart/Chart.yaml":         {Data: []byte("apiVersion: v2\nname: mychart\nversion: 0.1.0\n")},
+		"mychart/.helmignore":        {Data: []byte("*.bak\n")},
+		"mychart/templates/cm.yaml":  {Data: []byte("kind: ConfigMap\n")},
+		"mychart/templates/cm.bak":   {Data: []byte("kind: ConfigMap\n")},
+		"mychart/templates/.ignored": {Data: []byte("kind: ConfigMap\n")},
+		"mychart/values.yaml":        {Data: []byte("replicas: 1\n")},
+		"mychart/README.md":          {Data: []byte("# mychart\n")},
+	}
+	sub, err := fs.Sub(fsys, "mychart")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = LoadFS(sub)
+	if err != nil {
+		t.Fatalf("Failed to load chart from an in-memory file system: %s", err)
+	}
+	if c.Name() != "mychart" || len(c.Templates) != 1 || c.Templates[0].Name != "templates/cm.yaml" {
+		t.Errorf("Expected mychart with only templates/cm.yaml, got %s with %v", c.Name(), c.Templates)
+	}
+	if c.Values["replicas"] != 1.0 {
+		t.Errorf("Expected replicas 1, got %v", c.Values["replicas"])
+	}
+
+	fsys["mychart/templates/pipe"] = &fstest.MapFile{Mode: fs.ModeNamedPipe}
+	if _, err := LoadFS(sub); err == nil {
+		t.Error("Expected an error loading an irregular file")
+	}
+}
+
+func TestLoadArchiveBytes(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/frobnitz-1.2.3.tgz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadArchiveBytes(data)
+	if err != nil {
+		t.Fatalf("Failed to load testdata: %s", err)
+	}
+	verifyFrobnitz(t, c)
+	verifyChart(t, c)
+}
+
 func TestLoadDirWithDevNull(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("test only works on unix systems with /dev/null present")
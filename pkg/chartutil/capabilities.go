@@ -16,14 +16,19 @@ limitations under the License.
 package chartutil
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"strconv"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
 	"k8s.io/client-go/kubernetes/scheme"
 
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"sigs.k8s.io/yaml"
 
 	helmversion "github.com/open-hand/helm/internal/version"
 )
@@ -57,6 +62,9 @@ type Capabilities struct {
 	APIVersions VersionSet
 	// HelmVersion is the build information for this helm version
 	HelmVersion helmversion.BuildInfo
+	// Features are the facts detected about the cluster, if the detection
+	// was enabled.
+	Features Features
 }
 
 func (capabilities *Capabilities) Copy() *Capabilities {
@@ -64,9 +72,35 @@ func (capabilities *Capabilities) Copy() *Capabilities {
 		KubeVersion: capabilities.KubeVersion,
 		APIVersions: capabilities.APIVersions,
 		HelmVersion: capabilities.HelmVersion,
+		Features:    capabilities.Features,
 	}
 }
 
+// Features are facts about a Kubernetes cluster that its version and APIs do
+// not tell, detected with extra requests to the cluster when enabled. The
+// facts that could not be detected are left empty.
+type Features struct {
+	// Detected reports whether the features were detected. The other fields
+	// are empty otherwise.
+	Detected bool `json:"detected"`
+	// CloudProvider is the cloud provider of the nodes, such as "aws",
+	// "azure" or "gce", as in their provider IDs.
+	CloudProvider string `json:"cloudProvider,omitempty"`
+	// CNI is the network plugin of the cluster, such as "calico" or
+	// "cilium", as recognized from the DaemonSets of kube-system.
+	CNI string `json:"cni,omitempty"`
+	// DefaultStorageClass is the name of the default StorageClass.
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+	// IPv6 reports whether the nodes are given IPv6 pod ranges, and
+	// DualStack whether they are given both IPv4 and IPv6 ranges.
+	IPv6      bool `json:"ipv6,omitempty"`
+	DualStack bool `json:"dualStack,omitempty"`
+	// PodSecurity is the level the Pod Security admission enforces in the
+	// namespace of the client as labeled on the namespace, "privileged",
+	// "baseline" or "restricted".
+	PodSecurity string `json:"podSecurity,omitempty"`
+}
+
 // KubeVersion is the Kubernetes version.
 type KubeVersion struct {
 	Version string // Kubernetes version
@@ -124,3 +158,66 @@ func allKnownVersions() VersionSet {
 	}
 	return vs
 }
+
+// capabilitiesFile is the format of the capabilities files, snapshots of the
+// capabilities of a cluster for rendering charts without access to it.
+type capabilitiesFile struct {
+	KubeVersion string     `json:"kubeVersion"`
+	APIVersions VersionSet `json:"apiVersions"`
+	Features    *Features  `json:"features,omitempty"`
+}
+
+// WriteCapabilities writes caps to w as a JSON capabilities file, read by
+// LoadCapabilities. The Helm version is not written.
+func WriteCapabilities(w io.Writer, caps *Capabilities) error {
+	f := capabilitiesFile{KubeVersion: caps.KubeVersion.Version, APIVersions: caps.APIVersions}
+	if caps.Features.Detected {
+		features := caps.Features
+		f.Features = &features
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// LoadCapabilities reads the capabilities file filename, in JSON or YAML, as
+// written by WriteCapabilities. The capabilities have the Helm version of
+// this build.
+func LoadCapabilities(filename string) (*Capabilities, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := ParseCapabilities(b)
+	return caps, errors.Wrapf(err, "invalid capabilities file %s", filename)
+}
+
+// ParseCapabilities parses the content of a capabilities file.
+func ParseCapabilities(data []byte) (*Capabilities, error) {
+	var f capabilitiesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	if f.KubeVersion == "" {
+		return nil, errors.New("kubeVersion is not set")
+	}
+	kubeVersion, err := ParseKubeVersion(f.KubeVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid kubeVersion")
+	}
+	if len(f.APIVersions) == 0 {
+		return nil, errors.New("apiVersions is empty")
+	}
+	caps := &Capabilities{
+		KubeVersion: *kubeVersion,
+		APIVersions: f.APIVersions,
+		HelmVersion: DefaultCapabilities.HelmVersion,
+	}
+	if f.Features != nil {
+		caps.Features = *f.Features
+	}
+	return caps, nil
+}
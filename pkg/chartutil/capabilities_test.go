@@ -16,6 +16,8 @@ limitations under the License.
 package chartutil
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -82,3 +84,34 @@ func TestParseKubeVersion(t *testing.T) {
 		t.Errorf("Expected parsed KubeVersion.Minor to be 16, got %q", kv.Minor)
 	}
 }
+
+func TestCapabilitiesFile(t *testing.T) {
+	caps := &Capabilities{
+		KubeVersion: KubeVersion{Version: "v1.27.3", Major: "1", Minor: "27"},
+		APIVersions: VersionSet{"v1", "apps/v1", "apps/v1/Deployment", "monitoring.coreos.com/v1"},
+		HelmVersion: DefaultCapabilities.HelmVersion,
+		Features:    Features{Detected: true, CloudProvider: "aws", CNI: "cilium"},
+	}
+	var buf bytes.Buffer
+	if err := WriteCapabilities(&buf, caps); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseCapabilities(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, caps) {
+		t.Errorf("expected %+v, got %+v", caps, got)
+	}
+
+	for _, data := range []string{
+		`{"apiVersions": ["v1"]}`,
+		`{"kubeVersion": "latest", "apiVersions": ["v1"]}`,
+		`{"kubeVersion": "v1.27.0"}`,
+		`{"kubeVersion": "v1.27.0", "apiVersions": ["v1"], "unknown": true}`,
+	} {
+		if _, err := ParseCapabilities([]byte(data)); err == nil {
+			t.Errorf("expected an error parsing %s", data)
+		}
+	}
+}
@@ -26,10 +26,8 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
-	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/pkg/chart"
-	"github.com/open-hand/helm/pkg/chart/loader"
 )
 
 // chartName is a regular expression for testing the supplied name of a chart.
@@ -511,43 +509,10 @@ spec:
 var Stderr io.Writer = os.Stderr
 
 // CreateFrom creates a new chart, but scaffolds it from the src chart.
+//
+// See CreateFromStarter to give values to the parameters of the starter.
 func CreateFrom(chartfile *chart.Metadata, dest, src string) error {
-	schart, err := loader.Load(src)
-	if err != nil {
-		return errors.Wrapf(err, "could not load %s", src)
-	}
-
-	schart.Metadata = chartfile
-
-	var updatedTemplates []*chart.File
-
-	for _, template := range schart.Templates {
-		newData := transform(string(template.Data), schart.Name())
-		updatedTemplates = append(updatedTemplates, &chart.File{Name: template.Name, Data: newData})
-	}
-
-	schart.Templates = updatedTemplates
-	b, err := yaml.Marshal(schart.Values)
-	if err != nil {
-		return errors.Wrap(err, "reading values file")
-	}
-
-	var m map[string]interface{}
-	if err := yaml.Unmarshal(transform(string(b), schart.Name()), &m); err != nil {
-		return errors.Wrap(err, "transforming values file")
-	}
-	schart.Values = m
-
-	// SaveDir looks for the file values.yaml when saving rather than the values
-	// key in order to preserve the comments in the YAML. The name placeholder
-	// needs to be replaced on that file.
-	for _, f := range schart.Raw {
-		if f.Name == ValuesfileName {
-			f.Data = transform(string(f.Data), schart.Name())
-		}
-	}
-
-	return SaveDir(schart, dest)
+	return CreateFromStarter(chartfile, dest, src, nil)
 }
 
 // Create creates a new chart in a directory.
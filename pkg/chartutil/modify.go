@@ -0,0 +1,229 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/provenance"
+)
+
+// ModifyFunc edits a chart in memory, see Modify.
+type ModifyFunc func(c *chart.Chart) error
+
+// Modify loads the chart at path, a chart directory or archive, edits it
+// with modify and saves it back, returning the path of the saved chart.
+//
+// modify may change the metadata, values, templates and files of the chart.
+// Changed values are written to values.yaml, losing its comments, and the
+// digest of Chart.lock is updated when the dependencies of the metadata or
+// of the lock change.
+//
+// A chart directory is saved in place: the files modify removed are deleted,
+// and the charts directory is left untouched. An archive is replaced with
+// the archive of the new name and version of the chart, and its provenance
+// file, which no longer matches, is removed.
+func Modify(path string, modify ModifyFunc) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c, err := loader.Load(path)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := copystructure.Copy(c.Values)
+	if err != nil {
+		return "", err
+	}
+	digest, err := dependenciesDigest(c)
+	if err != nil {
+		return "", err
+	}
+	before := chartFileNames(c)
+
+	if err := modify(c); err != nil {
+		return "", err
+	}
+
+	if !reflect.DeepEqual(values, c.Values) {
+		if err := SetValues(c, c.Values); err != nil {
+			return "", err
+		}
+	}
+	newDigest, err := dependenciesDigest(c)
+	if err != nil {
+		return "", err
+	}
+	if c.Lock != nil && newDigest != digest {
+		c.Lock.Digest = newDigest
+	}
+	if err := c.Validate(); err != nil {
+		return "", errors.Wrap(err, "chart validation")
+	}
+
+	if fi.IsDir() {
+		return path, saveInPlace(c, path, before)
+	}
+	return replaceArchive(c, path)
+}
+
+// SetValues sets the values of c, and its values.yaml file to them.
+func SetValues(c *chart.Chart, values map[string]interface{}) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal values")
+	}
+	c.Values = values
+	for _, f := range c.Raw {
+		if f.Name == ValuesfileName {
+			f.Data = data
+			return nil
+		}
+	}
+	c.Raw = append(c.Raw, &chart.File{Name: ValuesfileName, Data: data})
+	return nil
+}
+
+// lockDigest returns the digest of Chart.lock for the dependencies, like the
+// dependency resolver computes it.
+func lockDigest(req, lock []*chart.Dependency) (string, error) {
+	data, err := json.Marshal([2][]*chart.Dependency{req, lock})
+	if err != nil {
+		return "", err
+	}
+	s, err := provenance.Digest(bytes.NewBuffer(data))
+	return "sha256:" + s, err
+}
+
+// dependenciesDigest returns the digest of Chart.lock for the current
+// dependencies of c, or "" if c has no Chart.lock.
+func dependenciesDigest(c *chart.Chart) (string, error) {
+	if c.Lock == nil {
+		return "", nil
+	}
+	return lockDigest(c.Metadata.Dependencies, c.Lock.Dependencies)
+}
+
+// chartFileNames returns the names of the templates and files of c.
+func chartFileNames(c *chart.Chart) map[string]bool {
+	names := make(map[string]bool)
+	for _, o := range [][]*chart.File{c.Templates, c.Files} {
+		for _, f := range o {
+			names[f.Name] = true
+		}
+	}
+	return names
+}
+
+// saveInPlace writes the chart c to its directory dir, deleting the
+// templates and files it had before that it no longer has.
+func saveInPlace(c *chart.Chart, dir string, before map[string]bool) error {
+	if err := SaveChartfile(filepath.Join(dir, ChartfileName), c.Metadata); err != nil {
+		return err
+	}
+	if c.Lock != nil {
+		data, err := yaml.Marshal(c.Lock)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, "Chart.lock"), data); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.Raw {
+		if f.Name == ValuesfileName {
+			if err := writeFile(filepath.Join(dir, ValuesfileName), f.Data); err != nil {
+				return err
+			}
+		}
+	}
+	if c.Schema != nil {
+		if err := writeFile(filepath.Join(dir, SchemafileName), c.Schema); err != nil {
+			return err
+		}
+	}
+
+	after := chartFileNames(c)
+	for _, o := range [][]*chart.File{c.Templates, c.Files} {
+		for _, f := range o {
+			if err := writeFile(filepath.Join(dir, filepath.FromSlash(f.Name)), f.Data); err != nil {
+				return err
+			}
+		}
+	}
+	for name := range before {
+		if after[name] || strings.HasPrefix(name, ChartsDir+"/") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceArchive saves the chart c next to the archive at path, then
+// removes the archive if its name changed, and its provenance file.
+func replaceArchive(c *chart.Chart, path string) (string, error) {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempDir(dir, ".modify-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	saved, err := Save(c, tmp)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, filepath.Base(saved))
+	if err := os.Rename(saved, name); err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return name, err
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return name, err
+	}
+	if abs != absName {
+		if err := os.Remove(path); err != nil {
+			return name, err
+		}
+	}
+	if err := os.Remove(path + ".prov"); err != nil && !os.IsNotExist(err) {
+		return name, err
+	}
+	return name, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+)
+
+func TestModifyDir(t *testing.T) {
+	tmp := ensure.TempDir(t)
+	dir, err := Create("ahab", tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := Modify(dir, func(c *chart.Chart) error {
+		c.Metadata.Version = "1.2.3"
+		c.Metadata.AppVersion = "2.0"
+		c.Values["replicaCount"] = 3
+		var templates []*chart.File
+		for _, f := range c.Templates {
+			if f.Name != "templates/hpa.yaml" {
+				templates = append(templates, f)
+			}
+		}
+		c.Templates = append(templates, &chart.File{Name: "templates/cm.yaml", Data: []byte("kind: ConfigMap\n")})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != dir {
+		t.Errorf("expected the chart to be saved in place, got %s", path)
+	}
+
+	c, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Metadata.Version != "1.2.3" || c.Metadata.AppVersion != "2.0" {
+		t.Errorf("unexpected version %s and app version %s", c.Metadata.Version, c.Metadata.AppVersion)
+	}
+	if c.Values["replicaCount"] != 3.0 {
+		t.Errorf("expected replicaCount 3, got %v", c.Values["replicaCount"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "templates", "hpa.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected templates/hpa.yaml to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "templates", "cm.yaml")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestModifyArchive(t *testing.T) {
+	tmp := ensure.TempDir(t)
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   chart.APIVersionV2,
+			Name:         "ahab",
+			Version:      "1.2.3",
+			Dependencies: []*chart.Dependency{{Name: "whale", Version: "1.0.0", Repository: "https://example.com"}},
+		},
+		Lock: &chart.Lock{
+			Digest:       "sha256:stale",
+			Dependencies: []*chart.Dependency{{Name: "whale", Version: "1.0.0", Repository: "https://example.com"}},
+		},
+		Raw:       []*chart.File{{Name: ValuesfileName, Data: []byte("# keep me\nimage: whale\n")}},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte("kind: ConfigMap\n")}},
+	}
+	archive, err := Save(c, tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(archive+".prov", []byte("signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unchanged lock keeps its digest and comments are kept in unchanged values
+	path, err := Modify(archive, func(c *chart.Chart) error {
+		c.Metadata.AppVersion = "2.0"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != archive {
+		t.Errorf("expected %s, got %s", archive, path)
+	}
+	if _, err := os.Stat(archive + ".prov"); !os.IsNotExist(err) {
+		t.Errorf("expected the provenance file to be removed, got %v", err)
+	}
+	c, err = loader.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Metadata.AppVersion != "2.0" || c.Lock.Digest != "sha256:stale" {
+		t.Errorf("unexpected app version %s and lock digest %s", c.Metadata.AppVersion, c.Lock.Digest)
+	}
+	for _, f := range c.Raw {
+		if f.Name == ValuesfileName && !strings.HasPrefix(string(f.Data), "# keep me") {
+			t.Errorf("expected unchanged values.yaml, got %q", f.Data)
+		}
+	}
+
+	path, err = Modify(archive, func(c *chart.Chart) error {
+		c.Metadata.Version = "1.3.0"
+		c.Metadata.Dependencies[0].Version = "1.1.0"
+		c.Lock.Dependencies[0].Version = "1.1.0"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tmp, "ahab-1.3.0.tgz"); path != want {
+		t.Errorf("expected %s, got %s", want, path)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Errorf("expected the old archive to be removed, got %v", err)
+	}
+	c, err = loader.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := lockDigest(c.Metadata.Dependencies, c.Lock.Dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Lock.Digest != digest {
+		t.Errorf("expected lock digest %s, got %s", digest, c.Lock.Digest)
+	}
+
+	if _, err := Modify(path, func(c *chart.Chart) error {
+		c.Metadata.Version = "not a version"
+		return nil
+	}); err == nil {
+		t.Error("expected an error saving an invalid chart")
+	}
+}
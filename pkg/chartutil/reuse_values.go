@@ -0,0 +1,119 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+)
+
+// MergeReusedValues merges the values a user supplied to a release, user,
+// with the default values of the chart the release is upgraded to,
+// newDefaults, given the defaults of the chart of the release, oldDefaults.
+// It returns the user values to reuse, and warnings about the values whose
+// defaults changed.
+//
+// The user values are all kept, as a value the user set explicitly may be
+// meant to pin the old default. Warnings are returned for the user values
+// equal to an old default that changed, and for the user values whose
+// default was removed from the chart, suggesting the key they were likely
+// renamed to when a new default has the old default value.
+func MergeReusedValues(user, oldDefaults, newDefaults map[string]interface{}) (map[string]interface{}, []string) {
+	c, err := copystructure.Copy(user)
+	if err != nil {
+		return user, nil
+	}
+	merged, _ := c.(map[string]interface{})
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+
+	added := map[string]interface{}{}
+	for _, path := range leafPaths(newDefaults, nil) {
+		if _, ok := lookupPath(oldDefaults, path); !ok {
+			added[strings.Join(path, ".")] = leafValue(newDefaults, path)
+		}
+	}
+
+	var warnings []string
+	paths := leafPaths(user, nil)
+	sort.Slice(paths, func(i, j int) bool { return strings.Join(paths[i], ".") < strings.Join(paths[j], ".") })
+	for _, path := range paths {
+		key := strings.Join(path, ".")
+		oldDefault, inOld := lookupPath(oldDefaults, path)
+		if !inOld {
+			continue
+		}
+		newDefault, inNew := lookupPath(newDefaults, path)
+		switch {
+		case !inNew:
+			w := fmt.Sprintf("the value %q is no longer a default of the chart, it may have been removed or renamed", key)
+			if renamed := renamedKey(path, oldDefault, added); renamed != "" {
+				w = fmt.Sprintf("the value %q is no longer a default of the chart, it may have been renamed to %q", key, renamed)
+			}
+			warnings = append(warnings, w)
+		case reflect.DeepEqual(leafValue(user, path), oldDefault) && !reflect.DeepEqual(oldDefault, newDefault):
+			warnings = append(warnings, fmt.Sprintf("the default of %q changed from %v to %v, keeping the value %v set for the release", key, oldDefault, newDefault, oldDefault))
+		}
+	}
+	return merged, warnings
+}
+
+// renamedKey returns the key of the added defaults most likely to be the new
+// name of the value at path, whose default was oldDefault: an added key with
+// the same default, preferably with the same last element as path.
+func renamedKey(path []string, oldDefault interface{}, added map[string]interface{}) string {
+	var candidates []string
+	for key, v := range added {
+		if reflect.DeepEqual(v, oldDefault) {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+	last := path[len(path)-1]
+	for _, key := range candidates {
+		if key == last || strings.HasSuffix(key, "."+last) {
+			return key
+		}
+	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// lookupPath returns the value at path in vals, and whether it exists.
+func lookupPath(vals map[string]interface{}, path []string) (interface{}, bool) {
+	m := vals
+	for i, k := range path {
+		v, ok := m[k]
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return v, true
+		}
+		if m, ok = v.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
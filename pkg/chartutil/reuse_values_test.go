@@ -0,0 +1,71 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeReusedValues(t *testing.T) {
+	oldDefaults := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "1.0", "pullPolicy": "IfNotPresent"},
+		"replicas": 1,
+		"port":     8080,
+		"debug":    false,
+	}
+	newDefaults := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "2.0", "pullPolicy": "IfNotPresent"},
+		"replicas": 1,
+		"service":  map[string]interface{}{"port": 8080},
+	}
+	user := map[string]interface{}{
+		// set to the old default, which changed: kept
+		"image": map[string]interface{}{"tag": "1.0"},
+		// customized: kept
+		"replicas": 3,
+		// renamed to service.port
+		"port": 9090,
+		// removed
+		"debug": true,
+		// not a default
+		"extra": "x",
+	}
+
+	merged, warnings := MergeReusedValues(user, oldDefaults, newDefaults)
+	expect := map[string]interface{}{
+		"image":    map[string]interface{}{"tag": "1.0"},
+		"replicas": 3,
+		"port":     9090,
+		"debug":    true,
+		"extra":    "x",
+	}
+	if !reflect.DeepEqual(merged, expect) {
+		t.Errorf("expected values %v, got %v", expect, merged)
+	}
+	expectWarnings := []string{
+		`the value "debug" is no longer a default of the chart, it may have been removed or renamed`,
+		`the default of "image.tag" changed from 1.0 to 2.0, keeping the value 1.0 set for the release`,
+		`the value "port" is no longer a default of the chart, it may have been renamed to "service.port"`,
+	}
+	if !reflect.DeepEqual(warnings, expectWarnings) {
+		t.Errorf("expected warnings %q, got %q", expectWarnings, warnings)
+	}
+	if user["image"].(map[string]interface{})["tag"] != "1.0" {
+		t.Error("expected the user values to be left unchanged")
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+)
+
+// StarterfileName is the name of the file describing the parameters and
+// hooks of a starter. It is not copied to the charts created from the
+// starter.
+const StarterfileName = "starter.yaml"
+
+// StarterParameter is a parameter of a starter. The placeholder
+// "<PARAM:name>" is replaced with its value in the templates and values of
+// the charts created from the starter.
+type StarterParameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	// Required parameters without a default must be given a value.
+	Required bool `json:"required,omitempty"`
+}
+
+// StarterHook is a command run in the directory of a chart once it is
+// created from a starter.
+type StarterHook struct {
+	Command []string `json:"command"`
+}
+
+// Starter describes the parameters and hooks of a starter.
+type Starter struct {
+	Parameters []StarterParameter `json:"parameters,omitempty"`
+	Hooks      []StarterHook      `json:"hooks,omitempty"`
+}
+
+// LoadStarterfile loads the starter file of the starter in dir. A starter
+// without a starter file has neither parameters nor hooks.
+func LoadStarterfile(dir string) (*Starter, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, StarterfileName))
+	if os.IsNotExist(err) {
+		return &Starter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Starter{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, errors.Wrapf(err, "cannot load %s", StarterfileName)
+	}
+	for _, p := range s.Parameters {
+		if p.Name == "" {
+			return nil, errors.Errorf("%s: parameters must have a name", StarterfileName)
+		}
+	}
+	for _, h := range s.Hooks {
+		if len(h.Command) == 0 {
+			return nil, errors.Errorf("%s: hooks must have a command", StarterfileName)
+		}
+	}
+	return s, nil
+}
+
+// Resolve returns the values of the parameters of the starter. The values
+// are taken from params, then from prompt if it is not nil, then from the
+// defaults of the parameters. An error is returned for the parameters
+// given in params that the starter does not declare, and for the required
+// parameters left without a value.
+func (s *Starter) Resolve(params map[string]string, prompt func(StarterParameter) (string, error)) (map[string]string, error) {
+	declared := make(map[string]bool, len(s.Parameters))
+	resolved := make(map[string]string, len(s.Parameters))
+	for _, p := range s.Parameters {
+		declared[p.Name] = true
+		if v, ok := params[p.Name]; ok {
+			resolved[p.Name] = v
+			continue
+		}
+		v := ""
+		if prompt != nil {
+			var err error
+			if v, err = prompt(p); err != nil {
+				return nil, err
+			}
+		}
+		if v == "" {
+			v = p.Default
+		}
+		if v == "" && p.Required {
+			return nil, errors.Errorf("missing value for the required starter parameter %q", p.Name)
+		}
+		resolved[p.Name] = v
+	}
+	for name := range params {
+		if !declared[name] {
+			return nil, errors.Errorf("unknown starter parameter %q", name)
+		}
+	}
+	return resolved, nil
+}
+
+// RunHooks runs the hooks of the starter in dir, the directory of a chart
+// created from it, writing their output to out. The hooks are given the
+// name of the chart and the values of the parameters in the environment as
+// HELM_CHART_NAME and HELM_PARAM_<NAME>.
+func (s *Starter) RunHooks(dir, name string, params map[string]string, out io.Writer) error {
+	env := append(os.Environ(), "HELM_CHART_NAME="+name)
+	for k, v := range params {
+		env = append(env, "HELM_PARAM_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_"))+"="+v)
+	}
+	for _, h := range s.Hooks {
+		fmt.Fprintf(out, "Running starter hook %s\n", strings.Join(h.Command, " "))
+		cmd := exec.Command(h.Command[0], h.Command[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		cmd.Stdout = out
+		cmd.Stderr = out
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(err, "starter hook %q failed", strings.Join(h.Command, " "))
+		}
+	}
+	return nil
+}
+
+// CreateFromStarter creates a new chart in dest scaffolded from the starter
+// in src, replacing "<CHARTNAME>" with the name of the chart and
+// "<PARAM:name>" with the value of the parameter name of params in its
+// templates and values. The hooks of the starter are not run.
+func CreateFromStarter(chartfile *chart.Metadata, dest, src string, params map[string]string) error {
+	schart, err := loader.Load(src)
+	if err != nil {
+		return errors.Wrapf(err, "could not load %s", src)
+	}
+
+	schart.Metadata = chartfile
+
+	replacements := []string{"<CHARTNAME>", schart.Name()}
+	for k, v := range params {
+		replacements = append(replacements, "<PARAM:"+k+">", v)
+	}
+	r := strings.NewReplacer(replacements...)
+
+	var updatedTemplates []*chart.File
+
+	for _, template := range schart.Templates {
+		newData := []byte(r.Replace(string(template.Data)))
+		updatedTemplates = append(updatedTemplates, &chart.File{Name: template.Name, Data: newData})
+	}
+
+	schart.Templates = updatedTemplates
+	b, err := yaml.Marshal(schart.Values)
+	if err != nil {
+		return errors.Wrap(err, "reading values file")
+	}
+
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(r.Replace(string(b))), &m); err != nil {
+		return errors.Wrap(err, "transforming values file")
+	}
+	schart.Values = m
+
+	// SaveDir looks for the file values.yaml when saving rather than the values
+	// key in order to preserve the comments in the YAML. The name placeholder
+	// needs to be replaced on that file.
+	for _, f := range schart.Raw {
+		if f.Name == ValuesfileName {
+			f.Data = []byte(r.Replace(string(f.Data)))
+		}
+	}
+
+	var files []*chart.File
+	for _, f := range schart.Files {
+		if f.Name != StarterfileName {
+			files = append(files, f)
+		}
+	}
+	schart.Files = files
+
+	return SaveDir(schart, dest)
+}
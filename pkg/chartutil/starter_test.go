@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStarterResolve(t *testing.T) {
+	s := &Starter{Parameters: []StarterParameter{
+		{Name: "port", Default: "8080"},
+		{Name: "team", Required: true},
+		{Name: "color"},
+	}}
+	prompted := func(p StarterParameter) (string, error) {
+		if p.Name == "team" {
+			return "ops", nil
+		}
+		return "", nil
+	}
+
+	tests := []struct {
+		name    string
+		params  map[string]string
+		prompt  func(StarterParameter) (string, error)
+		expect  map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "given",
+			params: map[string]string{"team": "web", "port": "80"},
+			expect: map[string]string{"team": "web", "port": "80", "color": ""},
+		},
+		{
+			name:   "prompted",
+			prompt: prompted,
+			expect: map[string]string{"team": "ops", "port": "8080", "color": ""},
+		},
+		{
+			name:    "missing required",
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			params:  map[string]string{"team": "web", "size": "xl"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Resolve(tt.params, tt.prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestLoadStarterfile(t *testing.T) {
+	dir := t.TempDir()
+	s, err := LoadStarterfile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Parameters) != 0 || len(s.Hooks) != 0 {
+		t.Errorf("expected an empty starter, got %+v", s)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, StarterfileName), []byte("hooks:\n- command: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadStarterfile(dir); err == nil {
+		t.Error("expected an error for a hook without a command")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+	"github.com/pkg/errors"
+)
+
+// RenderFunc renders a chart with the values supplied by a user, returning
+// the rendered files by name.
+type RenderFunc func(vals map[string]interface{}) (map[string]string, error)
+
+// unusedProbe is the value given to the values probed by UnusedValues.
+const unusedProbe = "unused-value-probe"
+
+// UnusedValues returns the values of candidates that the chart rendered by
+// render does not use when given the user values vals, as the paths of their
+// keys joined with ".", e.g. "image.tag", in order.
+//
+// Each leaf of candidates is probed by overriding it in a copy of vals,
+// first with a different value of the same type, then with null, which
+// removes it. The value is used if either probe changes the rendered files
+// or fails the rendering. candidates are usually vals themselves, to find
+// the values a user supplied by mistake, or the default values of the chart.
+//
+// Since only the given values are rendered, a value only used in a branch
+// the values do not take, such as the port of a disabled service, is
+// reported as unused. Charts whose rendering is not deterministic, e.g. by
+// generating random passwords, have all their values reported as used.
+func UnusedValues(vals, candidates map[string]interface{}, render RenderFunc) ([]string, error) {
+	base, err := renderCopy(vals, nil, nil, render)
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering the chart")
+	}
+
+	var unused []string
+	for _, path := range leafPaths(candidates, nil) {
+		used := false
+		for _, probe := range []interface{}{probeValue(leafValue(candidates, path)), nil} {
+			out, err := renderCopy(vals, path, probe, render)
+			if err != nil || !reflect.DeepEqual(out, base) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, strings.Join(path, "."))
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// renderCopy renders the chart with a copy of vals in which the value at
+// path is set to value. Nothing is set if path is empty.
+func renderCopy(vals map[string]interface{}, path []string, value interface{}, render RenderFunc) (map[string]string, error) {
+	c, err := copystructure.Copy(vals)
+	if err != nil {
+		return nil, err
+	}
+	probed, _ := c.(map[string]interface{})
+	if probed == nil {
+		probed = map[string]interface{}{}
+	}
+	if len(path) > 0 {
+		m := probed
+		for _, k := range path[:len(path)-1] {
+			next, ok := m[k].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[k] = next
+			}
+			m = next
+		}
+		m[path[len(path)-1]] = value
+	}
+	return render(probed)
+}
+
+// leafPaths returns the paths of the leaves of vals: the values that are
+// not maps, and the empty maps.
+func leafPaths(vals map[string]interface{}, prefix []string) [][]string {
+	var paths [][]string
+	for k, v := range vals {
+		path := append(append([]string{}, prefix...), k)
+		if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+			paths = append(paths, leafPaths(m, path)...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func leafValue(vals map[string]interface{}, path []string) interface{} {
+	var v interface{} = vals
+	for _, k := range path {
+		m, _ := v.(map[string]interface{})
+		v = m[k]
+	}
+	return v
+}
+
+// probeValue returns a value of the type of v that differs from v.
+func probeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return t + "-" + unusedProbe
+	case bool:
+		return !t
+	case int:
+		return t + 1
+	case int64:
+		return t + 1
+	case float64:
+		return t + 1
+	case []interface{}:
+		return append(append([]interface{}{}, t...), unusedProbe)
+	case map[string]interface{}:
+		return map[string]interface{}{unusedProbe: true}
+	}
+	return unusedProbe
+}
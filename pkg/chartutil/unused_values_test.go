@@ -0,0 +1,50 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestUnusedValues(t *testing.T) {
+	// render uses image.tag, and enabled unless it is missing.
+	render := func(vals map[string]interface{}) (map[string]string, error) {
+		image, _ := vals["image"].(map[string]interface{})
+		out := fmt.Sprint(image["tag"])
+		if enabled, ok := vals["enabled"]; ok && enabled == nil {
+			out += " disabled"
+		}
+		return map[string]string{"out": out}, nil
+	}
+	vals := map[string]interface{}{
+		"image":   map[string]interface{}{"tag": "1.0", "pullPolicy": "Always"},
+		"enabled": true,
+		"extra":   map[string]interface{}{},
+		"ports":   []interface{}{80},
+	}
+
+	unused, err := UnusedValues(vals, vals, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"extra", "image.pullPolicy", "ports"}
+	if !reflect.DeepEqual(unused, expect) {
+		t.Errorf("expected %v, got %v", expect, unused)
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"github.com/open-hand/helm/pkg/chart"
+)
+
+// ValuesDocsFileName is the default name of the file documenting the values
+// of a chart.
+const ValuesDocsFileName = "VALUES.md"
+
+// ValueDoc documents a value of a chart.
+type ValueDoc struct {
+	// Key is the dotted path of the value, such as "ingress.enabled".
+	Key string `json:"key"`
+	// Type is the type of the value: string, int, float, bool, list, object
+	// or the one declared by the schema of the values.
+	Type string `json:"type"`
+	// Default is the default value.
+	Default interface{} `json:"default"`
+	// Description is the description of the value, from the comment above
+	// its key or from the schema of the values.
+	Description string `json:"description,omitempty"`
+}
+
+// ChartValuesDocs documents the values of the chart, see ValuesDocs.
+func ChartValuesDocs(ch *chart.Chart) ([]ValueDoc, error) {
+	var values []byte
+	for _, f := range ch.Raw {
+		if f.Name == ValuesfileName {
+			values = f.Data
+		}
+	}
+	return ValuesDocs(values, ch.Schema)
+}
+
+// ValuesDocs documents the leaf values of a values.yaml file, in the order
+// of the file, followed by the ones only declared by the JSON schema of the
+// values, sorted by key.
+//
+// A value is described by the comment above its key, without the leading
+// "--" helm-docs uses to mark descriptions, or else by the description of
+// its property in the schema. Its type is the one the schema declares, or
+// else the type of its default value.
+func ValuesDocs(values, schema []byte) ([]ValueDoc, error) {
+	properties := map[string]map[string]interface{}{}
+	if len(schema) > 0 {
+		var s map[string]interface{}
+		if err := json.Unmarshal(schema, &s); err != nil {
+			return nil, errors.Wrap(err, "unable to parse the values schema")
+		}
+		schemaProperties("", s, properties)
+	}
+
+	var docs []ValueDoc
+	if len(values) > 0 {
+		var doc yamlv3.Node
+		if err := yamlv3.Unmarshal(values, &doc); err != nil {
+			return nil, errors.Wrap(err, "unable to parse the values")
+		}
+		if len(doc.Content) > 0 {
+			var err error
+			if docs, err = valuesDocs("", doc.Content[0], docs); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	documented := map[string]bool{}
+	for i := range docs {
+		documented[docs[i].Key] = true
+		property, ok := properties[docs[i].Key]
+		if !ok {
+			continue
+		}
+		if t, ok := property["type"].(string); ok {
+			docs[i].Type = t
+		}
+		if docs[i].Description == "" {
+			docs[i].Description, _ = property["description"].(string)
+		}
+	}
+	var keys []string
+	for key := range properties {
+		if !documented[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		property := properties[key]
+		d := ValueDoc{Key: key, Default: property["default"]}
+		d.Type, _ = property["type"].(string)
+		d.Description, _ = property["description"].(string)
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// valuesDocs appends the documentation of the leaf values of the mapping
+// node to docs.
+func valuesDocs(prefix string, node *yamlv3.Node, docs []ValueDoc) ([]ValueDoc, error) {
+	if node.Kind != yamlv3.MappingNode {
+		return docs, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := prefix + key.Value
+		if value.Kind == yamlv3.MappingNode && len(value.Content) > 0 {
+			var err error
+			if docs, err = valuesDocs(path+".", value, docs); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var def interface{}
+		if err := value.Decode(&def); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the value of %s", path)
+		}
+		docs = append(docs, ValueDoc{
+			Key:         path,
+			Type:        nodeType(value),
+			Default:     def,
+			Description: commentText(key.HeadComment),
+		})
+	}
+	return docs, nil
+}
+
+// nodeType returns the type of the value of the node.
+func nodeType(node *yamlv3.Node) string {
+	for node.Kind == yamlv3.AliasNode {
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		return "object"
+	case yamlv3.SequenceNode:
+		return "list"
+	}
+	switch node.ShortTag() {
+	case "!!str":
+		return "string"
+	case "!!int":
+		return "int"
+	case "!!float":
+		return "float"
+	case "!!bool":
+		return "bool"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// commentText returns the text of a YAML comment. When some of its lines
+// start with "--", only the lines from the last of them are kept, the other
+// ones commenting out values or describing a section.
+func commentText(comment string) string {
+	lines := strings.Split(comment, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "#")), "--") {
+			lines = lines[i:]
+			break
+		}
+	}
+	var text []string
+	for _, line := range lines {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+		line = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if line != "" {
+			text = append(text, line)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// schemaProperties adds the leaf properties of the JSON schema, by dotted
+// path, to properties.
+func schemaProperties(prefix string, schema map[string]interface{}, properties map[string]map[string]interface{}) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, property := range props {
+		property, ok := property.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sub, ok := property["properties"].(map[string]interface{}); ok && len(sub) > 0 {
+			schemaProperties(prefix+name+".", property, properties)
+			continue
+		}
+		properties[prefix+name] = property
+	}
+}
+
+// WriteValuesDocsMarkdown writes the documentation of the values as a
+// markdown table.
+func WriteValuesDocsMarkdown(w io.Writer, docs []ValueDoc) error {
+	if _, err := fmt.Fprint(w, "## Values\n\n| Key | Type | Default | Description |\n|-----|------|---------|-------------|\n"); err != nil {
+		return err
+	}
+	for _, d := range docs {
+		def, err := json.Marshal(d.Default)
+		if err != nil {
+			return errors.Wrapf(err, "unable to encode the default value of %s", d.Key)
+		}
+		if _, err := fmt.Fprintf(w, "| %s | %s | %s | %s |\n", markdownCode(d.Key), d.Type, markdownCode(string(def)), markdownCell(d.Description)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownCode returns s as inline code of a markdown table cell.
+func markdownCode(s string) string {
+	return "`" + markdownCell(s) + "`"
+}
+
+// markdownCell escapes s for a markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
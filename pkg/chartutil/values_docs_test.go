@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+const docsValues = `# Number of replicas.
+replicaCount: 1
+
+# Image settings.
+image:
+  # -- Repository of the image,
+  # pulled from Docker Hub.
+  repository: nginx
+  tag: ""
+# Some commented out value
+# debug: true
+# -- Annotations of the pods.
+podAnnotations: {}
+`
+
+const docsSchema = `{
+  "properties": {
+    "image": {
+      "properties": {
+        "tag": {"type": "string", "description": "Tag of the image"},
+        "pullPolicy": {"type": "string", "description": "Pull policy of the image", "default": "IfNotPresent"}
+      }
+    },
+    "replicaCount": {"type": "integer", "description": "Replicas"}
+  }
+}`
+
+func TestValuesDocs(t *testing.T) {
+	docs, err := ValuesDocs([]byte(docsValues), []byte(docsSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ValueDoc{
+		{Key: "replicaCount", Type: "integer", Default: 1, Description: "Number of replicas."},
+		{Key: "image.repository", Type: "string", Default: "nginx", Description: "Repository of the image, pulled from Docker Hub."},
+		{Key: "image.tag", Type: "string", Default: "", Description: "Tag of the image"},
+		{Key: "podAnnotations", Type: "object", Default: map[string]interface{}{}, Description: "Annotations of the pods."},
+		{Key: "image.pullPolicy", Type: "string", Default: "IfNotPresent", Description: "Pull policy of the image"},
+	}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %#v, got %#v", expected, docs)
+	}
+}
+
+func TestValuesDocsInvalid(t *testing.T) {
+	if _, err := ValuesDocs([]byte("a: [b"), nil); err == nil {
+		t.Error("expected invalid values to fail")
+	}
+	if _, err := ValuesDocs(nil, []byte("{")); err == nil {
+		t.Error("expected an invalid schema to fail")
+	}
+}
+
+func TestWriteValuesDocsMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteValuesDocsMarkdown(&buf, []ValueDoc{
+		{Key: "a", Type: "string", Default: "x|y", Description: "first | second"},
+		{Key: "b.c", Type: "list", Default: []interface{}{1, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "## Values\n\n" +
+		"| Key | Type | Default | Description |\n" +
+		"|-----|------|---------|-------------|\n" +
+		"| `a` | string | `\"x\\|y\"` | first \\| second |\n" +
+		"| `b.c` | list | `[1,2]` |  |\n"
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
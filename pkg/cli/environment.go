@@ -14,7 +14,8 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-/*Package cli describes the operating environment for the Helm CLI.
+/*
+Package cli describes the operating environment for the Helm CLI.
 
 Helm's environment encapsulates all of the service dependencies Helm has.
 These dependencies are expressed as interfaces so that alternate implementations
@@ -24,13 +25,20 @@ package cli
 
 import (
 	"fmt"
-	"github.com/open-hand/helm/pkg/helmpath"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/open-hand/helm/pkg/helmpath"
+	"github.com/open-hand/helm/pkg/helmpath/xdg"
+	"github.com/open-hand/helm/pkg/kube"
+	"github.com/open-hand/helm/pkg/logging"
 )
 
 // defaultMaxHistory sets the maximum number of releases to 0: unlimited
@@ -40,6 +48,11 @@ const defaultMaxHistory = 10
 type EnvSettings struct {
 	namespace string
 	config    *genericclioptions.ConfigFlags
+	flags     *pflag.FlagSet
+
+
+	rateLimitOnce sync.Once
+	rateLimit     func(*rest.Config) *rest.Config
 
 	// KubeConfig is the path to the kubeconfig file
 	KubeConfig string
@@ -51,40 +64,77 @@ type EnvSettings struct {
 	KubeAsUser string
 	// Groups to impersonate for the operation, multiple groups parsed from a comma delimited list
 	KubeAsGroups []string
+	// KubeExecCommand is a credential plugin run to get the credentials of
+	// the operation, replacing the ones of the kubeconfig
+	KubeExecCommand string
+	// KubeExecArgs are the arguments of KubeExecCommand
+	KubeExecArgs []string
+	// KubeQPS is the maximum number of queries per second to the Kubernetes
+	// API, negative to disable the client-side rate limiting
+	KubeQPS float32
+	// KubeBurst is the maximum number of queries sent at once to the Kubernetes API
+	KubeBurst int
+	// KubeAdaptiveRateLimit lowers the rate of the queries when the Kubernetes API throttles them
+	KubeAdaptiveRateLimit bool
 	// Kubernetes API Server Endpoint for authentication
 	KubeAPIServer string
 	// Custom certificate authority file.
 	KubeCaFile string
 	// Debug indicates whether or not Helm is running in Debug mode.
 	Debug bool
+	// LogLevel is the minimum level of the logged messages: debug, info,
+	// warn or error. It defaults to debug in Debug mode, and warn otherwise.
+	LogLevel string
+	// LogFormat is the format of the logged messages: text or json.
+	LogFormat string
 	// RegistryConfig is the path to the registry config file.
 	RegistryConfig string
+	// RegistryMirrorsConfig is the path to the registry mirrors configuration.
+	RegistryMirrorsConfig string
 	// RepositoryConfig is the path to the repositories file.
 	RepositoryConfig string
 	// RepositoryCache is the path to the repository cache directory.
 	RepositoryCache string
+	// StrictCredentials only sends the credentials of a repository with the
+	// requests of URLs under the URL of the repository, never on redirects
+	// leaving it.
+	StrictCredentials bool
+	// CredentialAuditLog is the path to the file the requests credentials
+	// were attached to are logged to.
+	CredentialAuditLog string
 	// PluginsDirectory is the path to the plugins directory.
 	PluginsDirectory string
 	// MaxHistory is the max release history maintained.
 	MaxHistory int
+	// Project is the project configuration the defaults of the settings
+	// come from, nil when there is none. See LoadProjectConfig.
+	Project *ProjectConfig
 }
 
 func New() *EnvSettings {
 	env := &EnvSettings{
-		namespace:        os.Getenv("HELM_NAMESPACE"),
-		MaxHistory:       envIntOr("HELM_MAX_HISTORY", defaultMaxHistory),
-		KubeContext:      os.Getenv("HELM_KUBECONTEXT"),
-		KubeToken:        os.Getenv("HELM_KUBETOKEN"),
-		KubeAsUser:       os.Getenv("HELM_KUBEASUSER"),
-		KubeAsGroups:     envCSV("HELM_KUBEASGROUPS"),
-		KubeAPIServer:    os.Getenv("HELM_KUBEAPISERVER"),
-		KubeCaFile:       os.Getenv("HELM_KUBECAFILE"),
-		PluginsDirectory: envOr("HELM_PLUGINS", helmpath.DataPath("plugins")),
-		RegistryConfig:   envOr("HELM_REGISTRY_CONFIG", helmpath.ConfigPath("registry/config.json")),
-		RepositoryConfig: envOr("HELM_REPOSITORY_CONFIG", helmpath.ConfigPath("repositories.yaml")),
-		RepositoryCache:  envOr("HELM_REPOSITORY_CACHE", helmpath.CachePath("repository")),
+		namespace:             os.Getenv("HELM_NAMESPACE"),
+		MaxHistory:            envIntOr("HELM_MAX_HISTORY", defaultMaxHistory),
+		KubeContext:           os.Getenv("HELM_KUBECONTEXT"),
+		KubeToken:             os.Getenv("HELM_KUBETOKEN"),
+		KubeAsUser:            os.Getenv("HELM_KUBEASUSER"),
+		KubeAsGroups:          envCSV("HELM_KUBEASGROUPS"),
+		KubeQPS:               envFloat32Or("HELM_KUBEQPS", 0),
+		KubeBurst:             envIntOr("HELM_KUBEBURST", 0),
+		KubeAPIServer:         os.Getenv("HELM_KUBEAPISERVER"),
+		KubeCaFile:            os.Getenv("HELM_KUBECAFILE"),
+		LogLevel:              os.Getenv("HELM_LOG_LEVEL"),
+		LogFormat:             envOr("HELM_LOG_FORMAT", string(logging.TextFormat)),
+		PluginsDirectory:      envOr("HELM_PLUGINS", helmpath.DataPath("plugins")),
+		RegistryConfig:        envOr("HELM_REGISTRY_CONFIG", helmpath.ConfigPath("registry/config.json")),
+		RegistryMirrorsConfig: envOr("HELM_REGISTRY_MIRRORS_CONFIG", helmpath.ConfigPath("registry/registries.yaml")),
+		RepositoryConfig:      envOr("HELM_REPOSITORY_CONFIG", helmpath.ConfigPath("repositories.yaml")),
+		RepositoryCache:       envOr("HELM_REPOSITORY_CACHE", helmpath.CachePath("repository")),
+		CredentialAuditLog:    os.Getenv("HELM_CREDENTIAL_AUDIT_LOG"),
 	}
 	env.Debug, _ = strconv.ParseBool(os.Getenv("HELM_DEBUG"))
+	env.KubeAdaptiveRateLimit, _ = strconv.ParseBool(os.Getenv("HELM_KUBEADAPTIVERATELIMIT"))
+	env.StrictCredentials, _ = strconv.ParseBool(os.Getenv("HELM_STRICT_CREDENTIALS"))
 
 	// bind to kubernetes config flags
 	env.config = &genericclioptions.ConfigFlags{
@@ -97,23 +147,153 @@ func New() *EnvSettings {
 		Impersonate:      &env.KubeAsUser,
 		ImpersonateGroup: &env.KubeAsGroups,
 	}
+	env.config.WrapConfigFn = env.wrapConfig
 	return env
 }
 
 // AddFlags binds flags to the given flagset.
 func (s *EnvSettings) AddFlags(fs *pflag.FlagSet) {
+	s.flags = fs
 	fs.StringVarP(&s.namespace, "namespace", "n", s.namespace, "namespace scope for this request")
 	fs.StringVar(&s.KubeConfig, "kubeconfig", "", "path to the kubeconfig file")
 	fs.StringVar(&s.KubeContext, "kube-context", s.KubeContext, "name of the kubeconfig context to use")
 	fs.StringVar(&s.KubeToken, "kube-token", s.KubeToken, "bearer token used for authentication")
 	fs.StringVar(&s.KubeAsUser, "kube-as-user", s.KubeAsUser, "username to impersonate for the operation")
 	fs.StringArrayVar(&s.KubeAsGroups, "kube-as-group", s.KubeAsGroups, "group to impersonate for the operation, this flag can be repeated to specify multiple groups.")
+	fs.StringVar(&s.KubeAsUser, "as", s.KubeAsUser, "username to impersonate for the operation, same as --kube-as-user")
+	fs.StringArrayVar(&s.KubeAsGroups, "as-group", s.KubeAsGroups, "group to impersonate for the operation, same as --kube-as-group")
+	fs.StringVar(&s.KubeExecCommand, "kube-exec-command", s.KubeExecCommand, "credential plugin run to get the credentials of the operation, replacing the ones of the kubeconfig")
+	fs.StringArrayVar(&s.KubeExecArgs, "kube-exec-arg", s.KubeExecArgs, "argument of the credential plugin, this flag can be repeated to specify multiple arguments")
+	fs.Float32Var(&s.KubeQPS, "kube-qps", s.KubeQPS, "maximum number of queries per second to the Kubernetes API, a negative value disables the client-side rate limiting")
+	fs.IntVar(&s.KubeBurst, "kube-burst", s.KubeBurst, "maximum number of queries sent at once to the Kubernetes API")
+	fs.BoolVar(&s.KubeAdaptiveRateLimit, "kube-adaptive-rate-limit", s.KubeAdaptiveRateLimit, "lower the rate of the queries when the Kubernetes API throttles them, and raise it back as they succeed")
 	fs.StringVar(&s.KubeAPIServer, "kube-apiserver", s.KubeAPIServer, "the address and the port for the Kubernetes API server")
 	fs.StringVar(&s.KubeCaFile, "kube-ca-file", s.KubeCaFile, "the certificate authority file for the Kubernetes API server connection")
 	fs.BoolVar(&s.Debug, "debug", s.Debug, "enable verbose output")
+	fs.Var(&logLevelValue{&s.LogLevel}, "log-level", "minimum level of the logged messages: debug, info, warn or error. Defaults to debug with --debug, and warn otherwise")
+	fs.Var(&logFormatValue{&s.LogFormat}, "log-format", "format of the logged messages: text or json")
 	fs.StringVar(&s.RegistryConfig, "registry-config", s.RegistryConfig, "path to the registry config file")
+	fs.StringVar(&s.RegistryMirrorsConfig, "registry-mirrors-config", s.RegistryMirrorsConfig, "path to the file mapping the registries to their mirrors")
 	fs.StringVar(&s.RepositoryConfig, "repository-config", s.RepositoryConfig, "path to the file containing repository names and URLs")
 	fs.StringVar(&s.RepositoryCache, "repository-cache", s.RepositoryCache, "path to the file containing cached repository indexes")
+	fs.BoolVar(&s.StrictCredentials, "strict-credentials", s.StrictCredentials, "only send the credentials of a repository to URLs under the repository URL, never on redirects leaving it")
+	fs.StringVar(&s.CredentialAuditLog, "credential-audit-log", s.CredentialAuditLog, "path to the file logging the requests credentials are sent with")
+}
+
+// LoadProjectConfig loads the project configuration named by
+// ProjectConfigEnvVar or, when unset, the one of the working directory, and
+// takes the namespace and kube context the environment does not set from it.
+// New does not load it, so that the programs using the settings are not
+// configured by the directory they run in; the helm CLI loads it before
+// parsing its flags. On error, the settings keep their defaults.
+func (s *EnvSettings) LoadProjectConfig() error {
+	project, err := loadProjectConfig()
+	if err != nil || project == nil {
+		return err
+	}
+	s.Project = project
+	if s.namespace == "" {
+		s.namespace = project.Namespace
+	}
+	if s.KubeContext == "" {
+		s.KubeContext = project.KubeContext
+	}
+	return nil
+}
+
+// ValuesFiles returns the values files merged before the ones given on the
+// command line, from the project configuration.
+func (s *EnvSettings) ValuesFiles() []string {
+	if s.Project == nil {
+		return nil
+	}
+	return s.Project.ValuesFiles
+}
+
+// wrapConfig applies the credential plugin and the rate limiting settings to
+// the client configuration.
+func (s *EnvSettings) wrapConfig(c *rest.Config) *rest.Config {
+	if s.KubeExecCommand != "" {
+		c = kube.AuthOptions{
+			ExecProvider: &clientcmdapi.ExecConfig{
+				Command: s.KubeExecCommand,
+				Args:    s.KubeExecArgs,
+			},
+		}.WrapConfig(c)
+	}
+	// The wrapper is built once, after the flags are parsed, so that all the
+	// clients share the adaptive rate limiter.
+	s.rateLimitOnce.Do(func() {
+		s.rateLimit = kube.RateLimitOptions{
+			QPS:      s.KubeQPS,
+			Burst:    s.KubeBurst,
+			Adaptive: s.KubeAdaptiveRateLimit,
+		}.ConfigWrapper()
+	})
+	return s.rateLimit(c)
+}
+
+// Logger returns the logger writing the messages of the level and format
+// settings to the standard error. Invalid settings fall back to the defaults.
+func (s *EnvSettings) Logger() logging.Logger {
+	level := logging.WarnLevel
+	if s.Debug {
+		level = logging.DebugLevel
+	}
+	if l, err := logging.ParseLevel(s.LogLevel); err == nil {
+		level = l
+	}
+	format, err := logging.ParseFormat(s.LogFormat)
+	if err != nil {
+		format = logging.TextFormat
+	}
+	return logging.New(os.Stderr, level, format)
+}
+
+type logLevelValue struct {
+	level *string
+}
+
+func (v *logLevelValue) String() string {
+	if v.level == nil {
+		return ""
+	}
+	return *v.level
+}
+
+func (v *logLevelValue) Set(s string) error {
+	if _, err := logging.ParseLevel(s); err != nil {
+		return err
+	}
+	*v.level = s
+	return nil
+}
+
+func (v *logLevelValue) Type() string {
+	return "level"
+}
+
+type logFormatValue struct {
+	format *string
+}
+
+func (v *logFormatValue) String() string {
+	if v.format == nil {
+		return ""
+	}
+	return *v.format
+}
+
+func (v *logFormatValue) Set(s string) error {
+	if _, err := logging.ParseFormat(s); err != nil {
+		return err
+	}
+	*v.format = s
+	return nil
+}
+
+func (v *logFormatValue) Type() string {
+	return "format"
 }
 
 func envOr(name, def string) string {
@@ -135,6 +315,18 @@ func envIntOr(name string, def int) int {
 	return ret
 }
 
+func envFloat32Or(name string, def float32) float32 {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	ret, err := strconv.ParseFloat(v, 32)
+	if err != nil {
+		return def
+	}
+	return float32(ret)
+}
+
 func envCSV(name string) (ls []string) {
 	trimmed := strings.Trim(os.Getenv(name), ", ")
 	if trimmed != "" {
@@ -145,25 +337,34 @@ func envCSV(name string) (ls []string) {
 
 func (s *EnvSettings) EnvVars() map[string]string {
 	envvars := map[string]string{
-		"HELM_BIN":               os.Args[0],
-		"HELM_CACHE_HOME":        helmpath.CachePath(""),
-		"HELM_CONFIG_HOME":       helmpath.ConfigPath(""),
-		"HELM_DATA_HOME":         helmpath.DataPath(""),
-		"HELM_DEBUG":             fmt.Sprint(s.Debug),
-		"HELM_PLUGINS":           s.PluginsDirectory,
-		"HELM_REGISTRY_CONFIG":   s.RegistryConfig,
-		"HELM_REPOSITORY_CACHE":  s.RepositoryCache,
-		"HELM_REPOSITORY_CONFIG": s.RepositoryConfig,
-		"HELM_NAMESPACE":         s.Namespace(),
-		"HELM_MAX_HISTORY":       strconv.Itoa(s.MaxHistory),
+		"HELM_BIN":                     os.Args[0],
+		"HELM_CACHE_HOME":              helmpath.CachePath(""),
+		"HELM_CONFIG_HOME":             helmpath.ConfigPath(""),
+		"HELM_DATA_HOME":               helmpath.DataPath(""),
+		"HELM_DEBUG":                   fmt.Sprint(s.Debug),
+		"HELM_LOG_LEVEL":               s.LogLevel,
+		"HELM_LOG_FORMAT":              s.LogFormat,
+		"HELM_PLUGINS":                 s.PluginsDirectory,
+		"HELM_REGISTRY_CONFIG":         s.RegistryConfig,
+		"HELM_REGISTRY_MIRRORS_CONFIG": s.RegistryMirrorsConfig,
+		"HELM_REPOSITORY_CACHE":        s.RepositoryCache,
+		"HELM_REPOSITORY_CONFIG":       s.RepositoryConfig,
+		"HELM_STRICT_CREDENTIALS":      strconv.FormatBool(s.StrictCredentials),
+		"HELM_CREDENTIAL_AUDIT_LOG":    s.CredentialAuditLog,
+		"HELM_NAMESPACE":               s.Namespace(),
+		"HELM_MAX_HISTORY":             strconv.Itoa(s.MaxHistory),
+		"HELM_PROJECT_CONFIG":          s.projectConfigPath(),
 
 		// broken, these are populated from helm flags and not kubeconfig.
-		"HELM_KUBECONTEXT":   s.KubeContext,
-		"HELM_KUBETOKEN":     s.KubeToken,
-		"HELM_KUBEASUSER":    s.KubeAsUser,
-		"HELM_KUBEASGROUPS":  strings.Join(s.KubeAsGroups, ","),
-		"HELM_KUBEAPISERVER": s.KubeAPIServer,
-		"HELM_KUBECAFILE":    s.KubeCaFile,
+		"HELM_KUBECONTEXT":           s.KubeContext,
+		"HELM_KUBETOKEN":             s.KubeToken,
+		"HELM_KUBEASUSER":            s.KubeAsUser,
+		"HELM_KUBEASGROUPS":          strings.Join(s.KubeAsGroups, ","),
+		"HELM_KUBEAPISERVER":         s.KubeAPIServer,
+		"HELM_KUBECAFILE":            s.KubeCaFile,
+		"HELM_KUBEQPS":               strconv.FormatFloat(float64(s.KubeQPS), 'f', -1, 32),
+		"HELM_KUBEBURST":             strconv.Itoa(s.KubeBurst),
+		"HELM_KUBEADAPTIVERATELIMIT": strconv.FormatBool(s.KubeAdaptiveRateLimit),
 	}
 	if s.KubeConfig != "" {
 		envvars["KUBECONFIG"] = s.KubeConfig
@@ -171,6 +372,121 @@ func (s *EnvSettings) EnvVars() map[string]string {
 	return envvars
 }
 
+// SettingSource tells where the value of a setting comes from.
+type SettingSource string
+
+const (
+	// SourceDefault is the source of the settings left to their default value.
+	SourceDefault SettingSource = "default"
+	// SourceEnv is the source of the settings read from an environment variable.
+	SourceEnv SettingSource = "env"
+	// SourceFlag is the source of the settings set by a command line flag.
+	SourceFlag SettingSource = "flag"
+	// SourceProject is the source of the settings read from the project
+	// configuration.
+	SourceProject SettingSource = "project"
+)
+
+// Setting describes the value of an environment setting and its origin.
+type Setting struct {
+	// Value is the value of the setting, as printed by 'helm env'.
+	Value string `json:"value"`
+	// Source tells where Value comes from.
+	Source SettingSource `json:"source"`
+	// EnvVar is the environment variable Value was read from, when Source is env.
+	EnvVar string `json:"env_var,omitempty"`
+	// Flags are the command line flags setting the value.
+	Flags []string `json:"flags,omitempty"`
+}
+
+// settingFlags maps the settings to the flags setting them.
+var settingFlags = map[string][]string{
+	"KUBECONFIG":                   {"kubeconfig"},
+	"HELM_DEBUG":                   {"debug"},
+	"HELM_LOG_LEVEL":               {"log-level"},
+	"HELM_LOG_FORMAT":              {"log-format"},
+	"HELM_REGISTRY_CONFIG":         {"registry-config"},
+	"HELM_REGISTRY_MIRRORS_CONFIG": {"registry-mirrors-config"},
+	"HELM_REPOSITORY_CACHE":        {"repository-cache"},
+	"HELM_REPOSITORY_CONFIG":       {"repository-config"},
+	"HELM_STRICT_CREDENTIALS":      {"strict-credentials"},
+	"HELM_CREDENTIAL_AUDIT_LOG":    {"credential-audit-log"},
+	"HELM_NAMESPACE":               {"namespace"},
+	"HELM_KUBECONTEXT":             {"kube-context"},
+	"HELM_KUBETOKEN":               {"kube-token"},
+	"HELM_KUBEASUSER":              {"kube-as-user", "as"},
+	"HELM_KUBEASGROUPS":            {"kube-as-group", "as-group"},
+	"HELM_KUBEAPISERVER":           {"kube-apiserver"},
+	"HELM_KUBECAFILE":              {"kube-ca-file"},
+	"HELM_KUBEQPS":                 {"kube-qps"},
+	"HELM_KUBEBURST":               {"kube-burst"},
+	"HELM_KUBEADAPTIVERATELIMIT":   {"kube-adaptive-rate-limit"},
+}
+
+// settingEnvVars maps the settings read from other environment variables than
+// their own name to those variables, in order of precedence.
+var settingEnvVars = map[string][]string{
+	"HELM_CACHE_HOME":  {helmpath.CacheHomeEnvVar, xdg.CacheHomeEnvVar},
+	"HELM_CONFIG_HOME": {helmpath.ConfigHomeEnvVar, xdg.ConfigHomeEnvVar},
+	"HELM_DATA_HOME":   {helmpath.DataHomeEnvVar, xdg.DataHomeEnvVar},
+	"HELM_BIN":         {},
+}
+
+// Settings returns the settings of EnvVars along with where their values come
+// from: a flag bound by AddFlags, an environment variable, or the defaults.
+func (s *EnvSettings) Settings() map[string]Setting {
+	settings := map[string]Setting{}
+	for name, value := range s.EnvVars() {
+		setting := Setting{Value: value, Source: SourceDefault, Flags: settingFlags[name]}
+		if s.fromProject(name) {
+			setting.Source = SourceProject
+		}
+		envVars, ok := settingEnvVars[name]
+		if !ok {
+			envVars = []string{name}
+		}
+		for _, env := range envVars {
+			if v, ok := os.LookupEnv(env); ok && v != "" {
+				setting.Source = SourceEnv
+				setting.EnvVar = env
+				break
+			}
+		}
+		if s.flags != nil {
+			for _, flag := range setting.Flags {
+				if f := s.flags.Lookup(flag); f != nil && f.Changed {
+					setting.Source = SourceFlag
+					setting.EnvVar = ""
+					break
+				}
+			}
+		}
+		settings[name] = setting
+	}
+	return settings
+}
+
+// fromProject tells whether the project configuration sets the setting.
+func (s *EnvSettings) fromProject(name string) bool {
+	if s.Project == nil {
+		return false
+	}
+	switch name {
+	case "HELM_NAMESPACE":
+		return s.Project.Namespace != ""
+	case "HELM_KUBECONTEXT":
+		return s.Project.KubeContext != ""
+	}
+	return false
+}
+
+func (s *EnvSettings) projectConfigPath() string {
+	if s.Project == nil {
+		return ""
+	}
+	return s.Project.Path
+}
+
 // Namespace gets the namespace from the configuration
 func (s *EnvSettings) Namespace() string {
 	if ns, _, err := s.config.ToRawKubeConfigLoader().Namespace(); err == nil {
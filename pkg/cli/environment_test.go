@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
 )
 
 func TestSetNamespace(t *testing.T) {
@@ -70,6 +71,14 @@ func TestEnvSettings(t *testing.T) {
 			kubeAsGroups: []string{"admins", "teatime", "snackeaters"},
 			kubeCaFile:   "/tmp/ca.crt",
 		},
+		{
+			name:         "with impersonation shorthand flags set",
+			args:         "--as=poro --as-group=admins --as-group=teatime",
+			ns:           "default",
+			maxhistory:   defaultMaxHistory,
+			kubeAsUser:   "poro",
+			kubeAsGroups: []string{"admins", "teatime"},
+		},
 		{
 			name:         "with envvars set",
 			envvars:      map[string]string{"HELM_DEBUG": "1", "HELM_NAMESPACE": "yourns", "HELM_KUBEASUSER": "pikachu", "HELM_KUBEASGROUPS": ",,,operators,snackeaters,partyanimals", "HELM_MAX_HISTORY": "5", "HELM_KUBECAFILE": "/tmp/ca.crt"},
@@ -132,6 +141,104 @@ func TestEnvSettings(t *testing.T) {
 	}
 }
 
+func TestExecCredentialFlags(t *testing.T) {
+	defer resetEnv()()
+
+	flags := pflag.NewFlagSet("testing", pflag.ContinueOnError)
+	settings := New()
+	settings.AddFlags(flags)
+	if err := flags.Parse([]string{"--kube-token=secret", "--kube-exec-command=get-token", "--kube-exec-arg=--user", "--kube-exec-arg=poro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := settings.config.WrapConfigFn(&rest.Config{BearerToken: "secret"})
+	if config.BearerToken != "" {
+		t.Errorf("expected the bearer token to be replaced, got %q", config.BearerToken)
+	}
+	if config.ExecProvider == nil {
+		t.Fatal("expected an exec provider")
+	}
+	if config.ExecProvider.Command != "get-token" {
+		t.Errorf("expected command %q, got %q", "get-token", config.ExecProvider.Command)
+	}
+	if !reflect.DeepEqual(config.ExecProvider.Args, []string{"--user", "poro"}) {
+		t.Errorf("unexpected args %v", config.ExecProvider.Args)
+	}
+}
+
+func TestRateLimitFlags(t *testing.T) {
+	defer resetEnv()()
+	os.Setenv("HELM_KUBEBURST", "200")
+
+	flags := pflag.NewFlagSet("testing", pflag.ContinueOnError)
+	settings := New()
+	settings.AddFlags(flags)
+	if err := flags.Parse([]string{"--kube-qps=100", "--kube-adaptive-rate-limit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := settings.config.WrapConfigFn(&rest.Config{})
+	if config.QPS != 100 || config.Burst != 200 {
+		t.Errorf("expected QPS 100 and burst 200, got %v and %d", config.QPS, config.Burst)
+	}
+	if config.RateLimiter == nil {
+		t.Error("expected an adaptive rate limiter")
+	}
+}
+
+func TestLogFlags(t *testing.T) {
+	defer resetEnv()()
+	os.Setenv("HELM_LOG_FORMAT", "json")
+
+	flags := pflag.NewFlagSet("testing", pflag.ContinueOnError)
+	settings := New()
+	settings.AddFlags(flags)
+	if err := flags.Parse([]string{"--log-level=info"}); err != nil {
+		t.Fatal(err)
+	}
+	if settings.LogLevel != "info" || settings.LogFormat != "json" {
+		t.Errorf("expected level info and format json, got %q and %q", settings.LogLevel, settings.LogFormat)
+	}
+
+	if err := flags.Parse([]string{"--log-level=verbose"}); err == nil {
+		t.Error("expected an error for an invalid log level")
+	}
+	if err := flags.Parse([]string{"--log-format=xml"}); err == nil {
+		t.Error("expected an error for an invalid log format")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	defer resetEnv()()
+	os.Setenv("HELM_KUBEBURST", "200")
+	os.Setenv("HELM_NAMESPACE", "yourns")
+	t.Setenv("XDG_CACHE_HOME", "/tmp/cache")
+
+	flags := pflag.NewFlagSet("testing", pflag.ContinueOnError)
+	settings := New()
+	settings.AddFlags(flags)
+	if err := flags.Parse([]string{"--as=poro", "--namespace=myns"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]Setting{
+		"HELM_KUBEBURST":  {Value: "200", Source: SourceEnv, EnvVar: "HELM_KUBEBURST", Flags: []string{"kube-burst"}},
+		"HELM_NAMESPACE":  {Value: "myns", Source: SourceFlag, Flags: []string{"namespace"}},
+		"HELM_KUBEASUSER": {Value: "poro", Source: SourceFlag, Flags: []string{"kube-as-user", "as"}},
+		"HELM_CACHE_HOME": {Value: "/tmp/cache/helm", Source: SourceEnv, EnvVar: "XDG_CACHE_HOME"},
+		"HELM_KUBEQPS":    {Value: "0", Source: SourceDefault, Flags: []string{"kube-qps"}},
+	}
+	got := settings.Settings()
+	for name, want := range expect {
+		if !reflect.DeepEqual(got[name], want) {
+			t.Errorf("expected %s to be %+v, got %+v", name, want, got[name])
+		}
+	}
+	if len(got) != len(settings.EnvVars()) {
+		t.Errorf("expected a setting for each of the %d environment variables, got %d", len(settings.EnvVars()), len(got))
+	}
+}
+
 func resetEnv() func() {
 	origEnv := os.Environ()
 
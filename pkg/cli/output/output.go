@@ -17,6 +17,7 @@ limitations under the License.
 package output
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -72,6 +73,39 @@ func (o Format) Write(out io.Writer, w Writer) error {
 	return ErrInvalidFormatType
 }
 
+// Error is the output of a command that failed, so that scripts parsing the
+// JSON or YAML output of a command get a result either way.
+type Error struct {
+	Error string `json:"error"`
+	// Result is the output the command produced before failing, if any.
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// WriteError writes the error in the given format to the io.Writer, along
+// with the result w the command produced before failing if w is not nil, in
+// a single document. Nothing is written in the table format, where errors
+// are only reported on the standard error.
+func (o Format) WriteError(out io.Writer, err error, w Writer) error {
+	if o == Table {
+		return nil
+	}
+	e := Error{Error: err.Error()}
+	if w != nil {
+		var result bytes.Buffer
+		if err := w.WriteJSON(&result); err != nil {
+			return err
+		}
+		e.Result = bytes.TrimSpace(result.Bytes())
+	}
+	switch o {
+	case JSON:
+		return EncodeJSON(out, e)
+	case YAML:
+		return EncodeYAML(out, e)
+	}
+	return ErrInvalidFormatType
+}
+
 // ParseFormat takes a raw string and returns the matching Format.
 // If the format does not exists, ErrInvalidFormatType is returned
 func ParseFormat(s string) (out Format, err error) {
@@ -0,0 +1,132 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ProjectConfigEnvVar is the environment variable naming the project
+// configuration file. Setting it to an empty value disables the discovery of
+// the project configuration.
+const ProjectConfigEnvVar = "HELM_PROJECT_CONFIG"
+
+// ProjectConfigFiles are the names of the project configuration files, in
+// order of precedence within a directory.
+var ProjectConfigFiles = []string{".helmrc", "helm.yaml"}
+
+// ProjectConfig is a project-local configuration, shared by the team working
+// on a repository to standardize the settings of the helm commands run in it.
+//
+// Its settings only apply when neither an environment variable nor a flag
+// sets them.
+type ProjectConfig struct {
+	// Namespace is the default namespace of the commands.
+	Namespace string `json:"namespace,omitempty"`
+	// KubeContext is the default kubeconfig context of the commands.
+	KubeContext string `json:"kubeContext,omitempty"`
+	// RepoAliases maps names usable with --repo to repository URLs.
+	RepoAliases map[string]string `json:"repoAliases,omitempty"`
+	// ValuesFiles are values files merged before the ones given with
+	// -f/--values by install, upgrade and template. Relative paths are
+	// relative to the directory of the configuration file.
+	ValuesFiles []string `json:"valuesFiles,omitempty"`
+
+	// Path is the path of the file the configuration was loaded from.
+	Path string `json:"-"`
+}
+
+// LoadProjectConfig loads a project configuration file.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &ProjectConfig{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, errors.Wrapf(err, "cannot load project configuration %s", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	c.Path = abs
+	for i, f := range c.ValuesFiles {
+		if !filepath.IsAbs(f) && !strings.Contains(f, "://") {
+			c.ValuesFiles[i] = filepath.Join(filepath.Dir(abs), f)
+		}
+	}
+	return c, nil
+}
+
+// FindProjectConfig returns the path of the project configuration file of
+// dir: the first of ProjectConfigFiles found in dir or its parents. It returns
+// an empty path when there is none.
+func FindProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range ProjectConfigFiles {
+			path := filepath.Join(dir, name)
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadProjectConfig loads the project configuration named by
+// ProjectConfigEnvVar or, when unset, the one of the working directory.
+func loadProjectConfig() (*ProjectConfig, error) {
+	path, ok := os.LookupEnv(ProjectConfigEnvVar)
+	if !ok {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		if path, err = FindProjectConfig(wd); err != nil {
+			return nil, err
+		}
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return LoadProjectConfig(path)
+}
+
+// ResolveRepo returns the URL of the repository alias of the project
+// configuration, or repo itself when it is not an alias.
+func (s *EnvSettings) ResolveRepo(repo string) string {
+	if s.Project != nil {
+		if u, ok := s.Project.RepoAliases[repo]; ok {
+			return u
+		}
+	}
+	return repo
+}
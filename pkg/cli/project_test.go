@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writeProjectConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeProjectConfig(t, filepath.Join(root, "helm.yaml"), "namespace: root\n")
+	path, err := FindProjectConfig(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(root, "helm.yaml") {
+		t.Errorf("expected the configuration of the parent directory, got %q", path)
+	}
+
+	writeProjectConfig(t, filepath.Join(root, "a", ".helmrc"), "namespace: a\n")
+	writeProjectConfig(t, filepath.Join(root, "a", "helm.yaml"), "namespace: other\n")
+	if path, _ = FindProjectConfig(nested); path != filepath.Join(root, "a", ".helmrc") {
+		t.Errorf("expected the closest .helmrc, got %q", path)
+	}
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".helmrc")
+	writeProjectConfig(t, path, `namespace: team-a
+kubeContext: staging
+repoAliases:
+  internal: https://charts.example.com/internal/
+valuesFiles:
+- values/common.yaml
+- /etc/helm/values.yaml
+- https://example.com/values.yaml
+`)
+	c, err := LoadProjectConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{filepath.Join(dir, "values", "common.yaml"), "/etc/helm/values.yaml", "https://example.com/values.yaml"}
+	if !reflect.DeepEqual(c.ValuesFiles, expect) {
+		t.Errorf("expected values files %v, got %v", expect, c.ValuesFiles)
+	}
+	if c.Path != path {
+		t.Errorf("expected path %q, got %q", path, c.Path)
+	}
+
+	writeProjectConfig(t, path, "namespaces: team-a\n")
+	if _, err := LoadProjectConfig(path); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestProjectConfigSettings(t *testing.T) {
+	defer resetEnv()()
+	path := filepath.Join(t.TempDir(), "helm.yaml")
+	writeProjectConfig(t, path, `namespace: team-a
+kubeContext: staging
+repoAliases:
+  internal: https://charts.example.com/internal/
+`)
+	os.Setenv(ProjectConfigEnvVar, path)
+	os.Setenv("HELM_KUBECONTEXT", "production")
+
+	flags := pflag.NewFlagSet("testing", pflag.ContinueOnError)
+	settings := New()
+	if settings.Project != nil {
+		t.Fatal("expected New not to load the project configuration")
+	}
+	if err := settings.LoadProjectConfig(); err != nil {
+		t.Fatal(err)
+	}
+	settings.AddFlags(flags)
+	if err := flags.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if ns := settings.Namespace(); ns != "team-a" {
+		t.Errorf("expected the namespace of the project, got %q", ns)
+	}
+	if settings.KubeContext != "production" {
+		t.Errorf("expected the environment to take precedence over the project, got %q", settings.KubeContext)
+	}
+	if s := settings.Settings()["HELM_NAMESPACE"]; s.Source != SourceProject {
+		t.Errorf("expected the namespace to come from the project, got %+v", s)
+	}
+	if u := settings.ResolveRepo("internal"); u != "https://charts.example.com/internal/" {
+		t.Errorf("expected the alias to be resolved, got %q", u)
+	}
+	if u := settings.ResolveRepo("https://example.com/"); u != "https://example.com/" {
+		t.Errorf("expected URLs to be left unchanged, got %q", u)
+	}
+
+	if err := flags.Parse([]string{"--namespace=mine"}); err != nil {
+		t.Fatal(err)
+	}
+	if ns := settings.Namespace(); ns != "mine" {
+		t.Errorf("expected the flag to take precedence over the project, got %q", ns)
+	}
+}
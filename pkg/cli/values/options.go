@@ -30,6 +30,9 @@ import (
 )
 
 type Options struct {
+	// DefaultValueFiles are merged before ValueFiles, such as the values
+	// files of the project configuration.
+	DefaultValueFiles []string
 	ValueFiles        []string
 	StringValues      []string
 	Values            []string
@@ -43,7 +46,8 @@ func (opts *Options) MergeValues(p getter.Providers) (map[string]interface{}, er
 	base := map[string]interface{}{}
 
 	// User specified a values files via -f/--values
-	for _, filePath := range opts.ValueFiles {
+	valueFiles := append(append([]string{}, opts.DefaultValueFiles...), opts.ValueFiles...)
+	for _, filePath := range valueFiles {
 		currentMap := map[string]interface{}{}
 
 		bytes, err := readFile(filePath, p)
@@ -17,6 +17,8 @@ limitations under the License.
 package values
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -75,3 +77,25 @@ func TestMergeValues(t *testing.T) {
 		t.Errorf("Expected a map with different keys to merge properly with another map. Expected: %v, got %v", expectedMap, testMap)
 	}
 }
+
+func TestMergeValuesDefaultValueFiles(t *testing.T) {
+	dir := t.TempDir()
+	defaults := filepath.Join(dir, "defaults.yaml")
+	if err := os.WriteFile(defaults, []byte("replicas: 1\nimage: nginx\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	overrides := filepath.Join(dir, "overrides.yaml")
+	if err := os.WriteFile(overrides, []byte("replicas: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{DefaultValueFiles: []string{defaults}, ValueFiles: []string{overrides}}
+	vals, err := opts.MergeValues(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{"replicas": float64(3), "image": "nginx"}
+	if !reflect.DeepEqual(vals, expect) {
+		t.Errorf("expected %v, got %v", expect, vals)
+	}
+}
@@ -0,0 +1,325 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package controller reconciles releases with their desired state.
+
+A Source provides the desired releases, read from a file or from HelmRelease
+custom resources. The Controller installs the missing releases and upgrades
+the ones whose chart or values differ, rolling them back if the upgrade
+fails. It periodically reconciles all releases again, repairing the ones
+whose resources drifted from their manifest.
+*/
+package controller // import "github.com/open-hand/helm/pkg/controller"
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/logging"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// DefaultInterval is the default interval between the periodic
+// reconciliations.
+const DefaultInterval = 5 * time.Minute
+
+// defaultTimeout is the timeout of the operations of the releases that do
+// not set one, the default of the CLI.
+const defaultTimeout = 300 * time.Second
+
+// ReleaseSpec is the desired state of a release.
+type ReleaseSpec struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is the name of the chart, downloaded with its Version from the
+	// repository at RepoURL, as by 'helm install --repo'.
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	RepoURL string `json:"repoURL,omitempty"`
+	// Values are the values overriding the ones of the chart.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// Wait waits for the resources of the release to be ready.
+	Wait bool `json:"wait,omitempty"`
+	// Timeout defaults to 5 minutes.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+	// Suspend stops the reconciliation of the release.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+func (s *ReleaseSpec) validate() error {
+	if s.Name == "" {
+		return errors.New("release without name")
+	}
+	if s.Chart == "" {
+		return errors.Errorf("release %s has no chart", s.Name)
+	}
+	return nil
+}
+
+// desired returns the chart and values of the spec.
+func (s *ReleaseSpec) desired() action.DesiredChart {
+	return action.DesiredChart{Chart: s.Chart, Version: s.Version, RepoURL: s.RepoURL, Values: s.Values}
+}
+
+func (s *ReleaseSpec) timeout() time.Duration {
+	if s.Timeout.Duration <= 0 {
+		return defaultTimeout
+	}
+	return s.Timeout.Duration
+}
+
+// Operation is the operation a reconciliation ran on a release.
+type Operation string
+
+const (
+	// OperationNone is reported when the release is up to date.
+	OperationNone Operation = "none"
+	// OperationInstall is reported when the release was installed.
+	OperationInstall Operation = "install"
+	// OperationUpgrade is reported when the release was upgraded to its
+	// spec.
+	OperationUpgrade Operation = "upgrade"
+	// OperationRepair is reported when the release was upgraded to repair
+	// its drifted resources.
+	OperationRepair Operation = "repair"
+	// OperationRollback is reported when the upgrade failed and the release
+	// was rolled back.
+	OperationRollback Operation = "rollback"
+	// OperationSkip is reported when the release is suspended, or another
+	// operation on it is pending.
+	OperationSkip Operation = "skip"
+)
+
+// Result is the result of the reconciliation of a release.
+type Result struct {
+	Operation Operation
+	// Release is the last release after the reconciliation, if any.
+	Release *release.Release
+}
+
+// Source provides the desired releases.
+type Source interface {
+	// Run sends the desired releases on updates when they change, until ctx
+	// is done.
+	Run(ctx context.Context, updates chan<- []ReleaseSpec) error
+}
+
+// StatusReporter is implemented by the sources recording the result of the
+// reconciliations, e.g. in the status of custom resources.
+type StatusReporter interface {
+	ReportStatus(ctx context.Context, spec ReleaseSpec, result *Result, err error)
+}
+
+// ConfigFunc returns the action configuration of the operations on the
+// releases of a namespace, closed once they are done.
+type ConfigFunc func(namespace string) (*action.Configuration, error)
+
+// Controller reconciles the releases of a Source.
+type Controller struct {
+	// Source provides the desired releases.
+	Source Source
+	// Config returns the action configuration of a namespace.
+	Config ConfigFunc
+	// Settings locate the charts.
+	Settings *cli.EnvSettings
+	// Interval is the interval between the periodic reconciliations,
+	// DefaultInterval if 0.
+	Interval time.Duration
+	// DetectDrift compares the deployed releases with the cluster on the
+	// periodic reconciliations, and upgrades the ones that drifted.
+	DetectDrift bool
+	// RollbackOnFailure rolls the failed upgrades back to the last deployed
+	// revision.
+	RollbackOnFailure bool
+	// Logger receives the result of the reconciliations.
+	Logger logging.Logger
+}
+
+// Run reconciles the releases when the source updates them and every
+// interval, until ctx is done.
+func (c *Controller) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	updates := make(chan []ReleaseSpec)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Source.Run(ctx, updates)
+	}()
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var specs []ReleaseSpec
+	for {
+		select {
+		case specs = <-updates:
+			c.reconcileAll(ctx, specs, false)
+		case <-ticker.C:
+			c.reconcileAll(ctx, specs, c.DetectDrift)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrap(err, "release source failed")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (c *Controller) reconcileAll(ctx context.Context, specs []ReleaseSpec, detectDrift bool) {
+	for _, spec := range specs {
+		if ctx.Err() != nil {
+			return
+		}
+		log := c.logger().With(logging.ReleaseField, spec.Name, logging.NamespaceField, spec.Namespace)
+		result, err := c.reconcile(ctx, spec, detectDrift)
+		if err != nil {
+			log.Error("reconciliation failed", "error", err)
+		} else if result.Operation != OperationNone {
+			log.Info("release reconciled", "operation", string(result.Operation))
+		}
+		if r, ok := c.Source.(StatusReporter); ok {
+			r.ReportStatus(ctx, spec, result, err)
+		}
+	}
+}
+
+// Reconcile brings the release to its spec, installing it or upgrading it.
+func (c *Controller) Reconcile(ctx context.Context, spec ReleaseSpec) (*Result, error) {
+	return c.reconcile(ctx, spec, false)
+}
+
+func (c *Controller) reconcile(ctx context.Context, spec ReleaseSpec, detectDrift bool) (*Result, error) {
+	if err := spec.validate(); err != nil {
+		return &Result{Operation: OperationSkip}, err
+	}
+	if spec.Suspend {
+		return &Result{Operation: OperationSkip}, nil
+	}
+	cfg, err := c.Config(spec.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+
+	last, err := cfg.Releases.Last(spec.Name)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		rel, err := c.install(ctx, cfg, spec, false)
+		return &Result{Operation: OperationInstall, Release: rel}, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if last.Info.Status.IsPending() {
+		return &Result{Operation: OperationSkip, Release: last}, nil
+	}
+	if last.Info.Status == release.StatusUninstalled {
+		rel, err := c.install(ctx, cfg, spec, true)
+		return &Result{Operation: OperationInstall, Release: rel}, err
+	}
+
+	upToDate, err := spec.desired().Matches(last)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid values")
+	}
+	op := OperationUpgrade
+	if upToDate && last.Info.Status == release.StatusDeployed {
+		if !detectDrift {
+			return &Result{Operation: OperationNone, Release: last}, nil
+		}
+		report, err := action.NewReleaseAudit(cfg).Run(spec.Name)
+		if err != nil {
+			return &Result{Operation: OperationNone, Release: last}, errors.Wrap(err, "unable to detect drift")
+		}
+		if !report.Drifted() {
+			return &Result{Operation: OperationNone, Release: last}, nil
+		}
+		op = OperationRepair
+	}
+
+	rel, err := c.upgrade(ctx, cfg, spec)
+	if err == nil || !c.RollbackOnFailure {
+		return &Result{Operation: op, Release: rel}, err
+	}
+	return c.rollback(cfg, spec, err)
+}
+
+// install installs the release, replacing the uninstalled one whose history
+// was kept if replace is true.
+func (c *Controller) install(ctx context.Context, cfg *action.Configuration, spec ReleaseSpec, replace bool) (*release.Release, error) {
+	client := action.NewInstall(cfg, action.ChartPathOptions{Version: spec.Version, RepoURL: spec.RepoURL},
+		"", 0, "", nil, spec.Namespace, spec.Name, "", "", 0, "", "", "", false)
+	client.Replace = replace
+	client.Wait = spec.Wait
+	client.Timeout = spec.timeout()
+	client.Description = "Install by the controller"
+	chrt, err := client.ChartPathOptions.LoadChart(spec.Chart, c.Settings)
+	if err != nil {
+		return nil, err
+	}
+	return client.RunWithContext(ctx, chrt, spec.desired().OverrideValues(), "")
+}
+
+func (c *Controller) upgrade(ctx context.Context, cfg *action.Configuration, spec ReleaseSpec) (*release.Release, error) {
+	client := action.NewUpgrade(cfg, action.ChartPathOptions{Version: spec.Version, RepoURL: spec.RepoURL},
+		"", 0, "", nil, spec.Name, "", "", 0, "", "", false, "")
+	client.Namespace = spec.Namespace
+	client.Wait = spec.Wait
+	client.Timeout = spec.timeout()
+	client.Description = "Upgrade by the controller"
+	chrt, err := client.ChartPathOptions.LoadChart(spec.Chart, c.Settings)
+	if err != nil {
+		return nil, err
+	}
+	return client.RunWithContext(ctx, spec.Name, chrt, spec.desired().OverrideValues(), "")
+}
+
+// rollback rolls the release back to its last deployed revision after the
+// upgrade failed with upgradeErr.
+func (c *Controller) rollback(cfg *action.Configuration, spec ReleaseSpec, upgradeErr error) (*Result, error) {
+	deployed, err := cfg.Releases.Deployed(spec.Name)
+	if err != nil {
+		return &Result{Operation: OperationUpgrade}, upgradeErr
+	}
+	client := action.NewRollback(cfg)
+	client.Version = deployed.Version
+	client.Wait = spec.Wait
+	client.Timeout = spec.timeout()
+	if err := client.Run(spec.Name); err != nil {
+		return &Result{Operation: OperationRollback}, errors.Wrapf(err, "rollback after failed upgrade (%s) failed", upgradeErr)
+	}
+	rel, _ := cfg.Releases.Last(spec.Name)
+	return &Result{Operation: OperationRollback, Release: rel}, errors.Wrap(upgradeErr, "upgrade failed and was rolled back")
+}
+
+func (c *Controller) logger() logging.Logger {
+	if c.Logger == nil {
+		return logging.Nop()
+	}
+	return c.Logger
+}
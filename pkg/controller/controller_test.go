@@ -0,0 +1,320 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/kube"
+	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// failingUpdates fails the given number of updates.
+type failingUpdates struct {
+	kubefake.PrintingKubeClient
+	failures int
+}
+
+func (f *failingUpdates) Update(original, target kube.ResourceList, force bool) (*kube.Result, error) {
+	if f.failures > 0 {
+		f.failures--
+		return &kube.Result{}, errors.New("update failed")
+	}
+	return f.PrintingKubeClient.Update(original, target, force)
+}
+
+// newTestController returns a controller, its kube client and the URL of a
+// repository serving the charts of testdata.
+func newTestController(t *testing.T) (*Controller, *failingUpdates, string) {
+	t.Helper()
+	charts := filepath.Join(t.TempDir(), "charts")
+	if err := os.Mkdir(charts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chrt, err := loader.Load("testdata/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chartutil.Save(chrt, charts); err != nil {
+		t.Fatal(err)
+	}
+	repo := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(charts))))
+	t.Cleanup(repo.Close)
+
+	settings := cli.New()
+	settings.RepositoryCache = t.TempDir()
+	settings.RepositoryConfig = filepath.Join(t.TempDir(), "repositories.yaml")
+
+	kc := &failingUpdates{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+	store := storage.Init(driver.NewMemory())
+	c := &Controller{
+		Settings: settings,
+		Config: func(namespace string) (*action.Configuration, error) {
+			store.Driver.(*driver.Memory).SetNamespace(namespace)
+			return &action.Configuration{
+				Releases:     store,
+				KubeClient:   kc,
+				Capabilities: chartutil.DefaultCapabilities,
+				Log:          func(string, ...interface{}) {},
+			}, nil
+		},
+	}
+	return c, kc, repo.URL + "/"
+}
+
+func TestReconcile(t *testing.T) {
+	c, kc, repoURL := newTestController(t)
+	c.RollbackOnFailure = true
+	ctx := context.Background()
+	spec := ReleaseSpec{Name: "hello", Namespace: "apps", Chart: "hello", Version: "0.1.0", RepoURL: repoURL}
+
+	tests := []struct {
+		name      string
+		values    map[string]interface{}
+		suspend   bool
+		failures  int
+		operation Operation
+		revision  int
+		status    release.Status
+		wantErr   bool
+	}{
+		{name: "install", operation: OperationInstall, revision: 1, status: release.StatusDeployed},
+		{name: "up to date", operation: OperationNone, revision: 1, status: release.StatusDeployed},
+		{name: "values changed", values: map[string]interface{}{"greeting": "hi"}, operation: OperationUpgrade, revision: 2, status: release.StatusDeployed},
+		{name: "same values", values: map[string]interface{}{"greeting": "hi"}, operation: OperationNone, revision: 2, status: release.StatusDeployed},
+		{name: "suspended", suspend: true, operation: OperationSkip},
+		{name: "failed upgrade", values: map[string]interface{}{"greeting": "hey"}, failures: 1, operation: OperationRollback, revision: 4, status: release.StatusDeployed, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := spec
+			spec.Values = tt.values
+			spec.Suspend = tt.suspend
+			kc.failures = tt.failures
+
+			result, err := c.Reconcile(ctx, spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got %v", tt.wantErr, err)
+			}
+			if result.Operation != tt.operation {
+				t.Errorf("expected operation %s, got %s", tt.operation, result.Operation)
+			}
+			if tt.revision == 0 {
+				return
+			}
+			if result.Release == nil {
+				t.Fatal("expected a release")
+			}
+			if result.Release.Version != tt.revision || result.Release.Info.Status != tt.status {
+				t.Errorf("expected revision %d %s, got %d %s", tt.revision, tt.status, result.Release.Version, result.Release.Info.Status)
+			}
+		})
+	}
+}
+
+func TestParseReleases(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr string
+	}{{
+		name: "releases",
+		data: "releases:\n- name: web\n  namespace: apps\n  chart: web\n  values:\n    replicas: 2\n- name: web\n  namespace: staging\n  chart: web\n",
+		want: 2,
+	}, {
+		name:    "duplicate release",
+		data:    "releases:\n- name: web\n  chart: web\n- name: web\n  chart: api\n",
+		wantErr: "defined more than once",
+	}, {
+		name:    "missing chart",
+		data:    "releases:\n- name: web\n",
+		wantErr: "has no chart",
+	}, {
+		name:    "unknown field",
+		data:    "releases:\n- name: web\n  chart: web\n  charts: api\n",
+		wantErr: "unknown field",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := ParseReleases([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(specs) != tt.want {
+				t.Errorf("expected %d releases, got %d", tt.want, len(specs))
+			}
+		})
+	}
+}
+
+func receive(t *testing.T, updates <-chan []ReleaseSpec) []ReleaseSpec {
+	t.Helper()
+	select {
+	case specs := <-updates:
+		return specs
+	case <-time.After(5 * time.Second):
+		t.Fatal("no releases received")
+		return nil
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "releases.yaml")
+	if err := ioutil.WriteFile(path, []byte("releases:\n- name: web\n  chart: web\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []ReleaseSpec)
+	s := &FileSource{Path: path, Interval: 10 * time.Millisecond}
+	go s.Run(ctx, updates)
+
+	if specs := receive(t, updates); len(specs) != 1 || specs[0].Name != "web" {
+		t.Fatalf("unexpected releases %v", specs)
+	}
+	if err := ioutil.WriteFile(path, []byte("releases:\n- name: web\n  chart: web\n- name: api\n  chart: api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if specs := receive(t, updates); len(specs) != 2 || specs[1].Name != "api" {
+		t.Fatalf("unexpected releases %v", specs)
+	}
+}
+
+func TestCustomResourceSource(t *testing.T) {
+	hr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "helm.sh/v1alpha1",
+		"kind":       "HelmRelease",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "apps"},
+		"spec": map[string]interface{}{
+			"chart":   "web",
+			"version": "1.0.0",
+			"values":  map[string]interface{}{"replicas": int64(2)},
+		},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{HelmReleaseResource: "HelmReleaseList"}, hr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan []ReleaseSpec)
+	s := &CustomResourceSource{Client: client}
+	go s.Run(ctx, updates)
+
+	specs := receive(t, updates)
+	if len(specs) != 1 {
+		t.Fatalf("expected 1 release, got %d", len(specs))
+	}
+	spec := specs[0]
+	if spec.Name != "web" || spec.Namespace != "apps" || spec.Chart != "web" || spec.Values["replicas"] != float64(2) {
+		t.Errorf("unexpected release %+v", spec)
+	}
+
+	s.ReportStatus(ctx, spec, &Result{
+		Operation: OperationInstall,
+		Release:   &release.Release{Version: 1, Info: &release.Info{Status: release.StatusDeployed}},
+	}, nil)
+	u, err := client.Resource(HelmReleaseResource).Namespace("apps").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, _, _ := unstructured.NestedMap(u.Object, "status")
+	if status["operation"] != "install" || status["releaseStatus"] != "deployed" || status["revision"] != int64(1) {
+		t.Errorf("unexpected status %v", status)
+	}
+
+	// The status update does not change the generation, and must not
+	// trigger another reconciliation.
+	select {
+	case specs := <-updates:
+		t.Fatalf("unexpected releases after a status update %v", specs)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unstructured.SetNestedField(u.Object, "2.0.0", "spec", "version")
+	u.SetGeneration(u.GetGeneration() + 1)
+	if _, err := client.Resource(HelmReleaseResource).Namespace("apps").Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if specs := receive(t, updates); len(specs) != 1 || specs[0].Version != "2.0.0" {
+		t.Errorf("unexpected releases %v", specs)
+	}
+}
+
+func TestReleaseSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    map[string]interface{}
+		wantErr string
+	}{
+		{name: "defaults", spec: map[string]interface{}{"chart": "repo/web"}},
+		{name: "same namespace", spec: map[string]interface{}{"chart": "web", "namespace": "apps", "repoURL": "https://charts.example.com"}},
+		{name: "other namespace", spec: map[string]interface{}{"chart": "web", "namespace": "kube-system"}, wantErr: `targets namespace "kube-system"`},
+		{name: "absolute path", spec: map[string]interface{}{"chart": "/charts/web"}, wantErr: "local chart"},
+		{name: "relative path", spec: map[string]interface{}{"chart": "./web"}, wantErr: "local chart"},
+		{name: "existing path", spec: map[string]interface{}{"chart": "testdata/hello"}, wantErr: "local chart"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "web", "namespace": "apps"},
+				"spec":     tt.spec,
+			}}
+			spec, err := releaseSpec(u)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.Name != "web" || spec.Namespace != "apps" {
+				t.Errorf("unexpected release %s/%s", spec.Namespace, spec.Name)
+			}
+		})
+	}
+}
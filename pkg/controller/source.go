@@ -0,0 +1,312 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/logging"
+)
+
+// ReleasesFile is the format of the files of a FileSource.
+type ReleasesFile struct {
+	Releases []ReleaseSpec `json:"releases"`
+}
+
+// ParseReleases parses the releases of a ReleasesFile.
+func ParseReleases(data []byte) ([]ReleaseSpec, error) {
+	var f ReleasesFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(f.Releases))
+	for i := range f.Releases {
+		spec := &f.Releases[i]
+		if err := spec.validate(); err != nil {
+			return nil, err
+		}
+		key := spec.Namespace + "/" + spec.Name
+		if seen[key] {
+			return nil, errors.Errorf("release %s is defined more than once", key)
+		}
+		seen[key] = true
+	}
+	return f.Releases, nil
+}
+
+// FileSource reads the desired releases from a ReleasesFile, checked for
+// changes every interval.
+type FileSource struct {
+	Path string
+	// Interval defaults to 10 seconds.
+	Interval time.Duration
+	// Logger receives the errors reading the file after it was first read.
+	Logger logging.Logger
+}
+
+// Run sends the releases of the file when it changes. It fails if the file
+// cannot be read at first, and keeps the previous releases if it cannot be
+// read later on.
+func (s *FileSource) Run(ctx context.Context, updates chan<- []ReleaseSpec) error {
+	var last []byte
+	read := func() ([]ReleaseSpec, bool, error) {
+		data, err := ioutil.ReadFile(s.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		if last != nil && bytes.Equal(data, last) {
+			return nil, false, nil
+		}
+		specs, err := ParseReleases(data)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "invalid releases file %s", s.Path)
+		}
+		last = data
+		return specs, true, nil
+	}
+
+	specs, _, err := read()
+	if err != nil {
+		return err
+	}
+	if !send(ctx, updates, specs) {
+		return nil
+	}
+
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			specs, changed, err := read()
+			if err != nil {
+				if s.Logger != nil {
+					s.Logger.Warn("unable to read the releases", "error", err)
+				}
+				continue
+			}
+			if changed && !send(ctx, updates, specs) {
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func send(ctx context.Context, updates chan<- []ReleaseSpec, specs []ReleaseSpec) bool {
+	select {
+	case updates <- specs:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// HelmReleaseResource is the resource of the HelmRelease custom resources.
+// Their spec is a ReleaseSpec, whose name and namespace default to the ones
+// of the resource.
+var HelmReleaseResource = schema.GroupVersionResource{Group: "helm.sh", Version: "v1alpha1", Resource: "helmreleases"}
+
+// CustomResourceSource watches the HelmRelease custom resources and records
+// the result of their reconciliation in their status.
+type CustomResourceSource struct {
+	Client dynamic.Interface
+	// Namespace restricts the resources watched to a namespace.
+	Namespace string
+	// Resync is the interval between the full lists of the resources, never
+	// if 0.
+	Resync time.Duration
+	// Logger receives the errors of the resources.
+	Logger logging.Logger
+
+	mu        sync.Mutex
+	store     cache.Store
+	resources map[string]string
+}
+
+// Run sends the releases of the resources when they change.
+func (s *CustomResourceSource) Run(ctx context.Context, updates chan<- []ReleaseSpec) error {
+	informer := dynamicinformer.NewFilteredDynamicInformer(s.Client, HelmReleaseResource, s.Namespace, s.Resync, cache.Indexers{}, nil).Informer()
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { notify() },
+		// The generation only changes with the spec, not with the status
+		// written by ReportStatus, which would otherwise trigger another
+		// reconciliation endlessly.
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			o, ok1 := oldObj.(*unstructured.Unstructured)
+			n, ok2 := newObj.(*unstructured.Unstructured)
+			if !ok1 || !ok2 || o.GetGeneration() != n.GetGeneration() {
+				notify()
+			}
+		},
+		DeleteFunc: func(interface{}) { notify() },
+	})
+	s.mu.Lock()
+	s.store = informer.GetStore()
+	s.mu.Unlock()
+
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return ctx.Err()
+	}
+	for {
+		select {
+		case <-changed:
+			if !send(ctx, updates, s.specs()) {
+				return nil
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// specs returns the releases of the resources, sorted by resource.
+func (s *CustomResourceSource) specs() []ReleaseSpec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objs := s.store.List()
+	sort.Slice(objs, func(i, j int) bool {
+		a, b := objs[i].(*unstructured.Unstructured), objs[j].(*unstructured.Unstructured)
+		return a.GetNamespace()+"/"+a.GetName() < b.GetNamespace()+"/"+b.GetName()
+	})
+
+	s.resources = make(map[string]string, len(objs))
+	specs := make([]ReleaseSpec, 0, len(objs))
+	for _, obj := range objs {
+		u := obj.(*unstructured.Unstructured)
+		spec, err := releaseSpec(u)
+		if err != nil {
+			if s.Logger != nil {
+				s.Logger.Warn("invalid HelmRelease", "resource", u.GetNamespace()+"/"+u.GetName(), "error", err)
+			}
+			continue
+		}
+		s.resources[spec.Namespace+"/"+spec.Name] = u.GetNamespace() + "/" + u.GetName()
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func releaseSpec(u *unstructured.Unstructured) (ReleaseSpec, error) {
+	var spec ReleaseSpec
+	b, err := json.Marshal(u.Object["spec"])
+	if err != nil {
+		return spec, err
+	}
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return spec, err
+	}
+	if spec.Name == "" {
+		spec.Name = u.GetName()
+	}
+	// The resources may only manage the releases of their namespace, with
+	// charts downloaded from repositories, as anyone allowed to create them
+	// could otherwise deploy to other namespaces or read the filesystem of
+	// the controller.
+	switch spec.Namespace {
+	case "":
+		spec.Namespace = u.GetNamespace()
+	case u.GetNamespace():
+	default:
+		return spec, errors.Errorf("release %s targets namespace %q instead of the namespace of the resource", spec.Name, spec.Namespace)
+	}
+	if isLocalChart(spec) {
+		return spec, errors.Errorf("release %s has a local chart %q", spec.Name, spec.Chart)
+	}
+	return spec, spec.validate()
+}
+
+// isLocalChart reports whether the chart of the spec is resolved as a path
+// by 'helm install'.
+func isLocalChart(spec ReleaseSpec) bool {
+	if spec.RepoURL != "" || strings.Contains(spec.Chart, "://") {
+		return false
+	}
+	if strings.HasPrefix(spec.Chart, "/") || strings.HasPrefix(spec.Chart, ".") {
+		return true
+	}
+	_, err := os.Stat(spec.Chart)
+	return err == nil
+}
+
+// ReportStatus records the result of the reconciliation of a release in the
+// status of its resource.
+func (s *CustomResourceSource) ReportStatus(ctx context.Context, spec ReleaseSpec, result *Result, err error) {
+	s.mu.Lock()
+	key, ok := s.resources[spec.Namespace+"/"+spec.Name]
+	var obj interface{}
+	if ok {
+		obj, ok, _ = s.store.GetByKey(key)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	u := obj.(*unstructured.Unstructured).DeepCopy()
+	status := map[string]interface{}{
+		"observedGeneration": u.GetGeneration(),
+		"lastReconcileTime":  time.Now().UTC().Format(time.RFC3339),
+	}
+	if result != nil {
+		status["operation"] = string(result.Operation)
+		if rel := result.Release; rel != nil {
+			status["revision"] = int64(rel.Version)
+			if rel.Info != nil {
+				status["releaseStatus"] = rel.Info.Status.String()
+			}
+		}
+	}
+	if err != nil {
+		status["message"] = err.Error()
+	}
+	u.Object["status"] = status
+
+	if _, err := s.Client.Resource(HelmReleaseResource).Namespace(u.GetNamespace()).UpdateStatus(ctx, u, metav1.UpdateOptions{}); err != nil && s.Logger != nil {
+		s.Logger.Debug("unable to update the status of the HelmRelease", "resource", key, "error", err)
+	}
+}
@@ -0,0 +1,249 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package deprecations detects the use of deprecated and removed Kubernetes APIs
+in manifests.
+
+A database of the deprecated APIs, with the versions of Kubernetes deprecating
+and removing them and the APIs replacing them, is bundled with Helm.
+*/
+package deprecations // import "github.com/open-hand/helm/pkg/deprecations"
+
+import (
+	_ "embed" // for the bundled database
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// Status is the status of an API in a version of Kubernetes.
+type Status string
+
+const (
+	// Deprecated APIs are still served, but will be removed.
+	Deprecated Status = "deprecated"
+	// Removed APIs are no longer served.
+	Removed Status = "removed"
+)
+
+// API is a deprecated Kubernetes API.
+type API struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// DeprecatedIn and RemovedIn are the versions of Kubernetes deprecating
+	// and removing the API, e.g. "v1.16". RemovedIn is empty if the removal
+	// of the API is not planned.
+	DeprecatedIn string `json:"deprecatedIn"`
+	RemovedIn    string `json:"removedIn,omitempty"`
+	// Replacement is the API version replacing the API, if any.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Status returns the status of the API in the version of Kubernetes
+// kubeVersion, or "" if the API is not deprecated yet.
+func (a API) Status(kubeVersion string) (Status, error) {
+	v, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid Kubernetes version %q", kubeVersion)
+	}
+	// Pre-releases of a version of Kubernetes already serve its APIs.
+	if pre, err := v.SetPrerelease(""); err == nil {
+		v = &pre
+	}
+	for _, s := range []struct {
+		version string
+		status  Status
+	}{{a.RemovedIn, Removed}, {a.DeprecatedIn, Deprecated}} {
+		if s.version == "" {
+			continue
+		}
+		since, err := semver.NewVersion(s.version)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid Kubernetes version %q of %s %s", s.version, a.APIVersion, a.Kind)
+		}
+		if !v.LessThan(since) {
+			return s.status, nil
+		}
+	}
+	return "", nil
+}
+
+// Database is a database of deprecated APIs.
+type Database struct {
+	apis map[string]API
+}
+
+//go:embed deprecations.yaml
+var bundled []byte
+
+var (
+	defaultOnce sync.Once
+	defaultDB   *Database
+)
+
+// Default returns the database bundled with Helm.
+func Default() *Database {
+	defaultOnce.Do(func() {
+		db, err := Load(bundled)
+		if err != nil {
+			panic(errors.Wrap(err, "loading the bundled deprecations"))
+		}
+		defaultDB = db
+	})
+	return defaultDB
+}
+
+// Load loads a database from YAML, a list of APIs under the key "apis".
+func Load(data []byte) (*Database, error) {
+	var f struct {
+		APIs []API `json:"apis"`
+	}
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, errors.Wrap(err, "parsing the deprecated APIs")
+	}
+	db := &Database{apis: make(map[string]API, len(f.APIs))}
+	for _, api := range f.APIs {
+		if api.APIVersion == "" || api.Kind == "" || api.DeprecatedIn == "" {
+			return nil, errors.Errorf("deprecated API %s %s: apiVersion, kind and deprecatedIn are required", api.APIVersion, api.Kind)
+		}
+		if _, err := api.Status("v1.0.0"); err != nil {
+			return nil, err
+		}
+		db.apis[api.APIVersion+"/"+api.Kind] = api
+	}
+	return db, nil
+}
+
+// Lookup returns the deprecated API of the kind in the API version, if it
+// is deprecated.
+func (db *Database) Lookup(apiVersion, kind string) (API, bool) {
+	api, ok := db.apis[apiVersion+"/"+kind]
+	return api, ok
+}
+
+// Finding is a resource using a deprecated API.
+type Finding struct {
+	API
+	Status    Status `json:"status"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Source is the template the resource was rendered from, if known.
+	Source string `json:"source,omitempty"`
+}
+
+var sourceComment = regexp.MustCompile(`(?m)^# Source: (.+)$`)
+
+// Scan returns the resources of manifest using APIs deprecated or removed in
+// the version of Kubernetes kubeVersion, in the order of the manifest. The
+// resources are attributed to the templates they were rendered from by the
+// "# Source:" comments of the manifest.
+func (db *Database) Scan(manifest, kubeVersion string) ([]Finding, error) {
+	resources, err := releaseutil.ParseManifest(manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing the manifest")
+	}
+
+	var findings []Finding
+	for _, res := range resources {
+		api, ok := db.Lookup(res.APIVersion(), res.Kind)
+		if !ok {
+			continue
+		}
+		status, err := api.Status(kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		if status == "" {
+			continue
+		}
+		findings = append(findings, Finding{API: api, Status: status, Name: res.Name, Namespace: res.Namespace, Source: res.Source})
+	}
+	return findings, nil
+}
+
+var (
+	documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+	apiVersionLine    = regexp.MustCompile(`(?m)^apiVersion:[ \t]*["']?([^"'\s]+)["']?[ \t]*$`)
+)
+
+// Migrate rewrites the API versions of the resources of manifest using APIs
+// removed from the version of Kubernetes kubeVersion to the APIs replacing
+// them, leaving the rest of the manifest as it is. It returns the rewritten
+// manifest and the resources it rewrote, with the APIs they used. The
+// resources whose APIs have no replacement are left as they are.
+//
+// Only the API versions are rewritten: the resources whose fields changed
+// between the versions of their APIs have to be fixed in their charts.
+func (db *Database) Migrate(manifest, kubeVersion string) (string, []Finding, error) {
+	var b strings.Builder
+	var migrated []Finding
+	start := 0
+	bounds := append(documentSeparator.FindAllStringIndex(manifest, -1), []int{len(manifest), len(manifest)})
+	for _, bound := range bounds {
+		doc := manifest[start:bound[0]]
+		rewritten, finding, err := db.migrateDocument(doc, kubeVersion)
+		if err != nil {
+			return manifest, nil, err
+		}
+		if finding != nil {
+			migrated = append(migrated, *finding)
+		}
+		b.WriteString(rewritten)
+		b.WriteString(manifest[bound[0]:bound[1]])
+		start = bound[1]
+	}
+	return b.String(), migrated, nil
+}
+
+// migrateDocument rewrites the API version of a document of a manifest, if
+// its API is removed.
+func (db *Database) migrateDocument(doc, kubeVersion string) (string, *Finding, error) {
+	var head struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(doc), &head); err != nil {
+		return doc, nil, errors.Wrap(err, "parsing the manifest")
+	}
+	api, ok := db.Lookup(head.APIVersion, head.Kind)
+	if !ok || api.Replacement == "" {
+		return doc, nil, nil
+	}
+	status, err := api.Status(kubeVersion)
+	if err != nil || status != Removed {
+		return doc, nil, err
+	}
+	loc := apiVersionLine.FindStringSubmatchIndex(doc)
+	if loc == nil || doc[loc[2]:loc[3]] != api.APIVersion {
+		return doc, nil, nil
+	}
+	f := &Finding{API: api, Status: status, Name: head.Metadata.Name, Namespace: head.Metadata.Namespace}
+	if m := sourceComment.FindStringSubmatch(doc); m != nil {
+		f.Source = m[1]
+	}
+	return doc[:loc[0]] + "apiVersion: " + api.Replacement + doc[loc[1]:], f, nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deprecations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAPIStatus(t *testing.T) {
+	api, ok := Default().Lookup("extensions/v1beta1", "Ingress")
+	if !ok {
+		t.Fatal("expected extensions/v1beta1 Ingress to be deprecated")
+	}
+	for version, expect := range map[string]Status{
+		"v1.13.0":      "",
+		"1.14":         Deprecated,
+		"v1.21.9":      Deprecated,
+		"v1.22.0-rc.1": Removed,
+		"v1.28.2+k3s1": Removed,
+	} {
+		status, err := api.Status(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != expect {
+			t.Errorf("%s: expected status %q, got %q", version, expect, status)
+		}
+	}
+	if _, ok := Default().Lookup("networking.k8s.io/v1", "Ingress"); ok {
+		t.Error("expected networking.k8s.io/v1 Ingress not to be deprecated")
+	}
+}
+
+func TestScan(t *testing.T) {
+	manifest := `---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: cleanup
+  namespace: jobs
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+# Source: web/templates/pdb.yaml
+apiVersion: policy/v1beta1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+`
+	findings, err := Default().Scan(manifest, "v1.22.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+	f := findings[0]
+	if f.Kind != "CronJob" || f.Name != "cleanup" || f.Namespace != "jobs" || f.Status != Deprecated ||
+		f.Source != "web/templates/cronjob.yaml" || f.Replacement != "batch/v1" {
+		t.Errorf("unexpected finding %+v", f)
+	}
+	if findings[1].Kind != "PodDisruptionBudget" {
+		t.Errorf("expected the PodDisruptionBudget second, got %+v", findings[1])
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load([]byte("apis:\n- {apiVersion: v1beta1, kind: Thing}\n")); err == nil {
+		t.Error("expected an error for an API without deprecatedIn")
+	}
+	if _, err := Load([]byte("apis:\n- {apiVersion: v1beta1, kind: Thing, deprecatedIn: soon}\n")); err == nil {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	manifest := `---
+# Source: web/templates/ingress.yaml
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+data:
+  nested: |
+    apiVersion: extensions/v1beta1
+---
+# Source: web/templates/psp.yaml
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: web
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: "batch/v1beta1"
+kind: CronJob
+metadata:
+  name: cleanup
+`
+	expect := `---
+# Source: web/templates/ingress.yaml
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+data:
+  nested: |
+    apiVersion: extensions/v1beta1
+---
+# Source: web/templates/psp.yaml
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: web
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+`
+	migrated, findings, err := Default().Migrate(manifest, "v1.25.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != expect {
+		t.Errorf("expected\n%s\ngot\n%s", expect, migrated)
+	}
+	if len(findings) != 2 || findings[0].Kind != "Ingress" || findings[1].Source != "web/templates/cronjob.yaml" {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+
+	// batch/v1beta1 CronJobs are still served by 1.24
+	migrated, findings, err = Default().Migrate(manifest, "v1.24.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || !strings.Contains(migrated, `apiVersion: "batch/v1beta1"`) {
+		t.Errorf("expected only the Ingress to be migrated, got %+v", findings)
+	}
+}
@@ -16,8 +16,12 @@ limitations under the License.
 package downloader
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -25,9 +29,12 @@ import (
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
 
 	"github.com/open-hand/helm/internal/fileutil"
 	"github.com/open-hand/helm/internal/urlutil"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
 	"github.com/open-hand/helm/pkg/getter"
 	"github.com/open-hand/helm/pkg/helmpath"
 	"github.com/open-hand/helm/pkg/provenance"
@@ -66,6 +73,15 @@ type ChartDownloader struct {
 	Verify VerificationStrategy
 	// Keyring is the keyring file used for verification.
 	Keyring string
+	// SignatureVerifier, if set, verifies the cosign signatures of charts:
+	// the signatures attached to charts stored in OCI registries, or the
+	// detached signature published at the URL of a chart archive followed
+	// by ".sig". The download fails if no signature can be verified.
+	SignatureVerifier provenance.SignatureVerifier
+	// RequireAttestations fails the download unless an attestation of the
+	// chart is verified by SignatureVerifier. Only charts stored in OCI
+	// registries can have attestations.
+	RequireAttestations bool
 	// Getter collection for the operation
 	Getters getter.Providers
 	// Options provide parameters to be passed along to the Getter being initialized.
@@ -73,6 +89,36 @@ type ChartDownloader struct {
 	RegistryClient   *registry.Client
 	RepositoryConfig string
 	RepositoryCache  string
+	// VerifyDigest fails the download of a chart of a repository whose
+	// archive does not match the digest of the chart in the repository
+	// index. Charts without a digest in the index are not verified.
+	VerifyDigest bool
+	// Channel restricts the versions of the charts of repositories to the
+	// ones available in the release channel, see repo.ChartVersion.Channel.
+	// All the versions are considered when empty, except pre-releases for
+	// unversioned references. Only the edge channel gets the pre-releases
+	// of the charts of OCI registries.
+	Channel string
+
+	// indexDigest is the digest of the chart resolved by the last call to
+	// ResolveChartVersion in the repository index, if any.
+	indexDigest string
+}
+
+// Download is a chart downloaded by ChartDownloader.Download.
+type Download struct {
+	// Path is the location the chart archive was written to.
+	Path string
+	// URL is the URL the chart was resolved to and downloaded from.
+	URL string
+	// Digest is the SHA-256 digest of the chart archive, hex encoded.
+	Digest string
+	// DigestVerified reports whether the digest matched the one of the
+	// repository index.
+	DigestVerified bool
+	// Verification is the verification of the provenance of the chart, as
+	// returned by DownloadTo.
+	Verification *provenance.Verification
 }
 
 // DownloadTo retrieves a chart. Depending on the settings, it may also download a provenance file.
@@ -87,30 +133,107 @@ type ChartDownloader struct {
 // Returns a string path to the location where the file was downloaded and a verification
 // (if provenance was verified), or an error if something bad happened.
 func (c *ChartDownloader) DownloadTo(ref, version, dest string) (string, *provenance.Verification, error) {
-	u, err := c.ResolveChartVersion(ref, version)
-	if err != nil {
+	d, err := c.Download(ref, version, dest)
+	if d == nil {
 		return "", nil, err
 	}
+	return d.Path, d.Verification, err
+}
 
-	g, err := c.Getters.ByScheme(u.Scheme)
+// Download retrieves a chart like DownloadTo, and also returns the URL it
+// was downloaded from and its digest. The returned download is not nil
+// when the chart was written to dest, even if its verification fails.
+func (c *ChartDownloader) Download(ref, version, dest string) (*Download, error) {
+	u, err := c.ResolveChartVersion(ref, version)
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+	path, ver, err := c.downloadTo(u, ref, dest)
+	if path == "" {
+		return nil, err
+	}
+	d := &Download{Path: path, URL: u.String(), Verification: ver}
+	if err != nil {
+		return d, err
 	}
 
-	data, err := g.Get(u.String(), c.Options...)
+	if d.Digest, err = provenance.DigestFile(path); err != nil {
+		return d, err
+	}
+	if repo.IsDigest(version) && u.Scheme != registry.OCIScheme {
+		if err := verifyPinnedDigest(u, d.Digest, version); err != nil {
+			return d, err
+		}
+		d.DigestVerified = true
+	} else if c.VerifyDigest && c.indexDigest != "" {
+		if d.Digest != c.indexDigest {
+			return d, errors.Errorf("digest of %s is sha256:%s, expected sha256:%s from the repository index", u, d.Digest, c.indexDigest)
+		}
+		d.DigestVerified = true
+	}
+	return d, nil
+}
+
+// verifyPinnedDigest checks that the hex encoded digest of the archive
+// downloaded from u is the digest the chart version was pinned to.
+func verifyPinnedDigest(u *url.URL, digest, pinned string) error {
+	if "sha256:"+digest != pinned {
+		return errors.Errorf("digest of %s is sha256:%s, expected %s", u, digest, pinned)
+	}
+	return nil
+}
+
+// downloadTo downloads the chart ref resolved to u to dest.
+func (c *ChartDownloader) downloadTo(u *url.URL, ref, dest string) (string, *provenance.Verification, error) {
+	g, err := c.Getters.ByScheme(u.Scheme)
 	if err != nil {
 		return "", nil, err
 	}
 
 	name := filepath.Base(u.Path)
 	if u.Scheme == registry.OCIScheme {
-		idx := strings.LastIndexByte(name, ':')
-		name = fmt.Sprintf("%s-%s.tgz", name[:idx], name[idx+1:])
+		if idx := strings.IndexByte(name, '@'); idx >= 0 {
+			name = fmt.Sprintf("%s-%s.tgz", name[:idx], strings.TrimPrefix(name[idx+1:], "sha256:"))
+		} else {
+			idx := strings.LastIndexByte(name, ':')
+			name = fmt.Sprintf("%s-%s.tgz", name[:idx], name[idx+1:])
+		}
 	}
-
 	destfile := filepath.Join(dest, name)
-	if err := fileutil.AtomicWriteFile(destfile, data, 0644); err != nil {
-		return destfile, nil, err
+
+	// Getters that can stream, like downloader plugins, write the chart to
+	// the file as it is downloaded instead of buffering it.
+	var archive []byte
+	if sg, ok := g.(getter.StreamGetter); ok {
+		stream, err := sg.GetStream(u.String(), c.Options...)
+		if err != nil {
+			return "", nil, err
+		}
+		err = fileutil.AtomicWriteFile(destfile, stream, 0644)
+		stream.Close()
+		if err != nil {
+			return destfile, nil, err
+		}
+	} else {
+		data, err := g.Get(u.String(), c.Options...)
+		if err != nil {
+			return "", nil, err
+		}
+		archive = data.Bytes()
+		if err := fileutil.AtomicWriteFile(destfile, data, 0644); err != nil {
+			return destfile, nil, err
+		}
+	}
+
+	if c.SignatureVerifier != nil {
+		if archive == nil {
+			if archive, err = ioutil.ReadFile(destfile); err != nil {
+				return destfile, nil, err
+			}
+		}
+		if err := c.verifySignature(u, g, archive); err != nil {
+			return destfile, nil, err
+		}
 	}
 
 	// If provenance is requested, verify it.
@@ -141,10 +264,175 @@ func (c *ChartDownloader) DownloadTo(ref, version, dest string) (string, *proven
 	return destfile, ver, nil
 }
 
+// partialReadSize is the size of the first read of the chart archives whose
+// files are read by ChartFiles, doubled until the files are found.
+const partialReadSize = 64 * 1024
+
+// ChartFiles returns the files of the chart ref with the given names,
+// relative to the chart directory, e.g. Chart.yaml and values.yaml, without
+// downloading the whole chart archive when possible. It is meant for quick
+// queries of the metadata of charts bundling large files.
+//
+// The archive is read from its beginning with range requests, doubling the
+// size read until the files are found, if the getter of the chart is a
+// getter.RangeGetter. For a chart in an OCI registry, only its metadata is
+// pulled when Chart.yaml is the only file asked for. The whole archive is
+// downloaded otherwise. The files missing from the chart are left out.
+//
+// Neither the chart nor its digest are verified, since that takes the whole
+// archive, unless the version is a digest: the whole archive is then
+// downloaded and its digest verified.
+func (c *ChartDownloader) ChartFiles(ref, version string, names ...string) ([]*loader.BufferedFile, error) {
+	u, err := c.ResolveChartVersion(ref, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == registry.OCIScheme && c.RegistryClient != nil && len(names) == 1 && names[0] == chartutil.ChartfileName {
+		result, err := c.RegistryClient.Pull(strings.TrimPrefix(u.String(), registry.OCIScheme+"://"),
+			registry.PullOptWithChart(false),
+			registry.PullOptWithProv(true),
+			registry.PullOptIgnoreMissingProv(true))
+		if err != nil {
+			return nil, err
+		}
+		data, err := yaml.Marshal(result.Chart.Meta)
+		if err != nil {
+			return nil, err
+		}
+		return []*loader.BufferedFile{{Name: chartutil.ChartfileName, Data: data}}, nil
+	}
+
+	g, err := c.Getters.ByScheme(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	rg, ok := g.(getter.RangeGetter)
+	if pinned := repo.IsDigest(version); !ok || pinned {
+		data, err := g.Get(u.String(), c.Options...)
+		if err != nil {
+			return nil, err
+		}
+		if pinned {
+			digest, err := provenance.Digest(bytes.NewReader(data.Bytes()))
+			if err != nil {
+				return nil, err
+			}
+			if err := verifyPinnedDigest(u, digest, version); err != nil {
+				return nil, err
+			}
+		}
+		return loader.LoadPartialArchiveFiles(data.Bytes(), true, names...)
+	}
+	for length := int64(partialReadSize); ; length *= 2 {
+		data, err := rg.GetRange(u.String(), 0, length, c.Options...)
+		if err != nil {
+			return nil, err
+		}
+		files, err := loader.LoadPartialArchiveFiles(data.Bytes(), int64(data.Len()) < length, names...)
+		if err != loader.ErrIncompleteArchive {
+			return files, err
+		}
+	}
+}
+
+// verifySignature verifies the cosign signature of the chart archive
+// downloaded from u.
+func (c *ChartDownloader) verifySignature(u *url.URL, g getter.Getter, archive []byte) error {
+	var ver *provenance.SignatureVerification
+	if u.Scheme == registry.OCIScheme {
+		if c.RegistryClient == nil {
+			return errors.New("a registry client is required to verify the signatures of OCI charts")
+		}
+		res, err := c.RegistryClient.Signatures(strings.TrimPrefix(u.String(), fmt.Sprintf("%s://", registry.OCIScheme)))
+		if err != nil {
+			return err
+		}
+		sum, err := provenance.Digest(bytes.NewReader(archive))
+		if err != nil {
+			return err
+		}
+		if res.ChartDigest != "sha256:"+sum {
+			return errors.Errorf("downloaded chart does not match the chart of manifest %s", res.Digest)
+		}
+		if ver, err = c.SignatureVerifier.VerifyManifest(res.Digest, res.Signatures); err != nil {
+			return err
+		}
+		statements, err := c.SignatureVerifier.VerifyAttestations(res.Digest, res.Attestations)
+		if err != nil {
+			return err
+		}
+		if c.RequireAttestations && len(statements) == 0 {
+			return errors.Errorf("no verified attestations found for %s", res.Ref)
+		}
+		for _, st := range statements {
+			fmt.Fprintf(c.Out, "Verified attestation: %s\n", st.PredicateType)
+		}
+	} else {
+		if c.RequireAttestations {
+			return errors.New("attestations can only be verified for charts stored in OCI registries")
+		}
+		sig, err := c.fetchDetachedSignature(u, g)
+		if err != nil {
+			return err
+		}
+		if ver, err = c.SignatureVerifier.VerifyBlob(archive, sig); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.Out, "Verified signature of %s by %s\n", ver.Digest, ver.Signer)
+	return nil
+}
+
+// fetchDetachedSignature fetches the signature published next to a chart
+// archive by 'cosign sign-blob': a bundle at the URL of the archive followed
+// by ".bundle", or otherwise a signature and an optional certificate followed
+// by ".sig" and ".pem".
+func (c *ChartDownloader) fetchDetachedSignature(u *url.URL, g getter.Getter) (*provenance.Signature, error) {
+	if body, err := g.Get(u.String() + ".bundle"); err == nil {
+		var bundle struct {
+			Base64Signature string          `json:"base64Signature"`
+			Cert            string          `json:"cert"`
+			RekorBundle     json.RawMessage `json:"rekorBundle"`
+		}
+		if err := json.Unmarshal(body.Bytes(), &bundle); err != nil {
+			return nil, errors.Wrapf(err, "invalid signature bundle %q", u.String()+".bundle")
+		}
+		sig, err := base64.StdEncoding.DecodeString(bundle.Base64Signature)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid signature bundle %q", u.String()+".bundle")
+		}
+		return &provenance.Signature{
+			Signature:   sig,
+			Certificate: []byte(bundle.Cert),
+			Bundle:      bundle.RekorBundle,
+		}, nil
+	}
+
+	body, err := g.Get(u.String() + ".sig")
+	if err != nil {
+		return nil, errors.Errorf("failed to fetch signature %q", u.String()+".sig")
+	}
+	sig := &provenance.Signature{Signature: body.Bytes()}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(body.String())); err == nil {
+		sig.Signature = decoded
+	}
+	if cert, err := g.Get(u.String() + ".pem"); err == nil {
+		sig.Certificate = cert.Bytes()
+	}
+	return sig, nil
+}
+
 func (c *ChartDownloader) getOciURI(ref, version string, u *url.URL) (*url.URL, error) {
 	var tag string
 	var err error
 
+	// A digest pins the manifest of the chart, whatever its tags.
+	if repo.IsDigest(version) {
+		u.Path = fmt.Sprintf("%s@%s", u.Path, version)
+		return u, nil
+	}
+
 	// Evaluate whether an explicit version has been provided. Otherwise, determine version to use
 	_, errSemVer := semver.NewVersion(version)
 	if errSemVer == nil {
@@ -159,6 +447,12 @@ func (c *ChartDownloader) getOciURI(ref, version string, u *url.URL) (*url.URL,
 			return nil, errors.Errorf("Unable to locate any tags in provided repository: %s", ref)
 		}
 
+		// Registries have no index declaring the channels of the charts,
+		// only the edge channel gets their pre-releases.
+		if version == "" && c.Channel == repo.ChannelEdge {
+			version = ">0.0.0-0"
+		}
+
 		// Determine if version provided
 		// If empty, try to get the highest available tag
 		// If exact version, try to find it
@@ -182,14 +476,22 @@ func (c *ChartDownloader) getOciURI(ref, version string, u *url.URL) (*url.URL,
 // A reference may be an HTTP URL, an oci reference URL, a 'reponame/chartname'
 // reference, or a local path.
 //
-// A version is a SemVer string (1.2.3-beta.1+f334a6789).
+// A version is a SemVer string (1.2.3-beta.1+f334a6789), or a digest
+// (sha256:...) pinning a chart version, see repo.IsDigest.
 //
 //	- For fully qualified URLs, the version will be ignored (since URLs aren't versioned)
 //	- For a chart reference
 //		* If version is non-empty, this will return the URL for that version
 //		* If version is empty, this will return the URL for the latest version
+//		* If version is a digest, this will return the URL for the version with that digest
+//		* If Channel is set, only the versions available in the channel are considered
 //		* If no version can be found, an error is returned
+//	- For an oci reference, a digest pins the manifest of the chart
+//
+// Download verifies that the archive of a chart pinned by a digest, other
+// than in an OCI registry, has that digest.
 func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, error) {
+	c.indexDigest = ""
 	u, err := url.Parse(ref)
 	if err != nil {
 		return nil, errors.Errorf("invalid chart URL format: %s", ref)
@@ -232,10 +534,14 @@ func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, er
 		if rc.CertFile != "" || rc.KeyFile != "" || rc.CAFile != "" {
 			c.Options = append(c.Options, getter.WithTLSClientConfig(rc.CertFile, rc.KeyFile, rc.CAFile))
 		}
-		if rc.Username != "" && rc.Password != "" {
+		username, password, err := rc.Credentials()
+		if err != nil {
+			return u, err
+		}
+		if username != "" && password != "" {
 			c.Options = append(
 				c.Options,
-				getter.WithBasicAuth(rc.Username, rc.Password),
+				getter.WithBasicAuth(username, password),
 				getter.WithPassCredentialsAll(rc.PassCredentialsAll),
 			)
 		}
@@ -248,7 +554,9 @@ func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, er
 		return u, errors.Errorf("non-absolute URLs should be in form of repo_name/path_to_chart, got: %s", u)
 	}
 
-	repoName := p[0]
+	// The repository may be referred to by its name or one of its aliases,
+	// optionally prefixed with "@" like in the dependencies of a chart.
+	repoName := strings.TrimPrefix(p[0], "@")
 	chartName := p[1]
 	rc, err := pickChartRepositoryConfigByName(repoName, rf.Repositories)
 
@@ -269,9 +577,13 @@ func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, er
 		if r.Config.CertFile != "" || r.Config.KeyFile != "" || r.Config.CAFile != "" {
 			c.Options = append(c.Options, getter.WithTLSClientConfig(r.Config.CertFile, r.Config.KeyFile, r.Config.CAFile))
 		}
-		if r.Config.Username != "" && r.Config.Password != "" {
+		username, password, err := r.Config.Credentials()
+		if err != nil {
+			return u, err
+		}
+		if username != "" && password != "" {
 			c.Options = append(c.Options,
-				getter.WithBasicAuth(r.Config.Username, r.Config.Password),
+				getter.WithBasicAuth(username, password),
 				getter.WithPassCredentialsAll(r.Config.PassCredentialsAll),
 			)
 		}
@@ -284,7 +596,7 @@ func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, er
 		return u, errors.Wrap(err, "no cached repo found. (try 'helm repo update')")
 	}
 
-	cv, err := i.Get(chartName, version)
+	cv, err := i.GetInChannel(chartName, version, c.Channel)
 	if err != nil {
 		return u, errors.Wrapf(err, "chart %q matching %s not found in %s index. (try 'helm repo update')", chartName, version, r.Config.Name)
 	}
@@ -292,6 +604,7 @@ func (c *ChartDownloader) ResolveChartVersion(ref, version string) (*url.URL, er
 	if len(cv.URLs) == 0 {
 		return u, errors.Errorf("chart %q has no downloadable URLs", ref)
 	}
+	c.indexDigest = strings.TrimPrefix(cv.Digest, "sha256:")
 
 	// TODO: Seems that picking first URL is not fully correct
 	u, err = url.Parse(cv.URLs[0])
@@ -356,16 +669,18 @@ func isTar(filename string) bool {
 	return strings.EqualFold(filepath.Ext(filename), ".tgz")
 }
 
+// pickChartRepositoryConfigByName returns the repository named name, or
+// having it as an alias.
 func pickChartRepositoryConfigByName(name string, cfgs []*repo.Entry) (*repo.Entry, error) {
-	for _, rc := range cfgs {
-		if rc.Name == name {
-			if rc.URL == "" {
-				return nil, errors.Errorf("no URL found for repository %s", name)
-			}
-			return rc, nil
-		}
+	rf := &repo.File{Repositories: cfgs}
+	rc := rf.Get(name)
+	if rc == nil {
+		return nil, errors.Errorf("repo %s not found", name)
+	}
+	if rc.URL == "" {
+		return nil, errors.Errorf("no URL found for repository %s", name)
 	}
-	return nil, errors.Errorf("repo %s not found", name)
+	return rc, nil
 }
 
 // scanReposForURL scans all repos to find which repo contains the given URL.
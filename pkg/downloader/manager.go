@@ -598,8 +598,8 @@ func (m *Manager) resolveRepoNames(deps []*chart.Dependency) (map[string]string,
 		found := false
 
 		for _, repo := range repos {
-			if (strings.HasPrefix(dd.Repository, "@") && strings.TrimPrefix(dd.Repository, "@") == repo.Name) ||
-				(strings.HasPrefix(dd.Repository, "alias:") && strings.TrimPrefix(dd.Repository, "alias:") == repo.Name) {
+			if (strings.HasPrefix(dd.Repository, "@") && repo.HasName(strings.TrimPrefix(dd.Repository, "@"))) ||
+				(strings.HasPrefix(dd.Repository, "alias:") && repo.HasName(strings.TrimPrefix(dd.Repository, "alias:"))) {
 				found = true
 				dd.Repository = repo.URL
 				reposMap[dd.Name] = repo.Name
@@ -726,8 +726,10 @@ func (m *Manager) findChartURL(name, version, repoURL string, repos map[string]*
 			if err != nil {
 				return
 			}
-			username = cr.Config.Username
-			password = cr.Config.Password
+			username, password, err = cr.Config.Credentials()
+			if err != nil {
+				return
+			}
 			passcredentialsall = cr.Config.PassCredentialsAll
 			insecureskiptlsverify = cr.Config.InsecureSkipTLSverify
 			caFile = cr.Config.CAFile
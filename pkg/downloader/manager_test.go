@@ -186,6 +186,13 @@ func TestGetRepoNames(t *testing.T) {
 			},
 			expect: map[string]string{"oedipus-rex": "testing"},
 		},
+		{
+			name: "repo alias of the repository (@)",
+			req: []*chart.Dependency{
+				{Name: "oedipus-rex", Repository: "@former-testing"},
+			},
+			expect: map[string]string{"oedipus-rex": "testing"},
+		},
 		{
 			name: "repo from local chart under charts path",
 			req: []*chart.Dependency{
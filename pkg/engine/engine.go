@@ -40,10 +40,18 @@ type Engine struct {
 	Strict bool
 	// In LintMode, some 'required' template values may be missing, so don't fail
 	LintMode bool
+	// Limits bound the time and the memory used to render the templates.
+	Limits Limits
 	// the rest config to connect to the kubernetes api
 	config *rest.Config
 }
 
+// New creates an Engine whose lookup function uses config to connect to the
+// Kubernetes API. A nil config renders without a connection to the cluster.
+func New(config *rest.Config) Engine {
+	return Engine{config: config}
+}
+
 // Render takes a chart, optional values, and value overrides, and attempts to render the Go templates.
 //
 // Render can be called repeatedly on the same engine.
@@ -104,13 +112,18 @@ func warnWrap(warn string) string {
 }
 
 // initFunMap creates the Engine's FuncMap and adds context-specific functions.
-func (e Engine) initFunMap(t *template.Template, referenceTpls map[string]renderable) {
+func (e Engine) initFunMap(t *template.Template, referenceTpls map[string]renderable, g *renderGuard) {
 	funcMap := funcMap()
+	guardFuncs(funcMap, g)
 	includedNames := make(map[string]int)
 
 	// Add the 'include' function here so we can close over t.
 	funcMap["include"] = func(name string, data interface{}) (string, error) {
 		var buf strings.Builder
+		if err := g.enter(); err != nil {
+			return "", err
+		}
+		defer g.leave()
 		if v, ok := includedNames[name]; ok {
 			if v > recursionMaxNums {
 				return "", errors.Wrapf(fmt.Errorf("unable to execute template"), "rendering template has a nested reference name: %s", name)
@@ -119,13 +132,18 @@ func (e Engine) initFunMap(t *template.Template, referenceTpls map[string]render
 		} else {
 			includedNames[name] = 1
 		}
-		err := t.ExecuteTemplate(&buf, name, data)
+		err := t.ExecuteTemplate(g.writer(&buf), name, data)
 		includedNames[name]--
 		return buf.String(), err
 	}
 
 	// Add the 'tpl' function here
 	funcMap["tpl"] = func(tpl string, vals chartutil.Values) (string, error) {
+		if err := g.enter(); err != nil {
+			return "", err
+		}
+		defer g.leave()
+
 		basePath, err := vals.PathValue("Template.BasePath")
 		if err != nil {
 			return "", errors.Wrapf(err, "cannot retrieve Template.Basepath from values inside tpl function: %s", tpl)
@@ -144,7 +162,7 @@ func (e Engine) initFunMap(t *template.Template, referenceTpls map[string]render
 			},
 		}
 
-		result, err := e.renderWithReferences(templates, referenceTpls)
+		result, err := e.renderWithReferences(templates, referenceTpls, g)
 		if err != nil {
 			return "", errors.Wrapf(err, "error during tpl function execution for %q", tpl)
 		}
@@ -194,12 +212,13 @@ func (e Engine) initFunMap(t *template.Template, referenceTpls map[string]render
 
 // render takes a map of templates/values and renders them.
 func (e Engine) render(tpls map[string]renderable) (map[string]string, error) {
-	return e.renderWithReferences(tpls, tpls)
+	return e.renderWithReferences(tpls, tpls, newRenderGuard(e.Limits))
 }
 
 // renderWithReferences takes a map of templates/values to render, and a map of
-// templates which can be referenced within them.
-func (e Engine) renderWithReferences(tpls, referenceTpls map[string]renderable) (rendered map[string]string, err error) {
+// templates which can be referenced within them. g enforces the limits of the
+// engine across the nested renders of the tpl function.
+func (e Engine) renderWithReferences(tpls, referenceTpls map[string]renderable, g *renderGuard) (rendered map[string]string, err error) {
 	// Basically, what we do here is start with an empty parent template and then
 	// build up a list of templates -- one for each file. Once all of the templates
 	// have been parsed, we loop through again and execute every template.
@@ -221,7 +240,7 @@ func (e Engine) renderWithReferences(tpls, referenceTpls map[string]renderable)
 		t.Option("missingkey=zero")
 	}
 
-	e.initFunMap(t, referenceTpls)
+	e.initFunMap(t, referenceTpls, g)
 
 	// We want to parse the templates in a predictable order. The order favors
 	// higher-level (in file system) templates over deeply nested templates.
@@ -246,6 +265,8 @@ func (e Engine) renderWithReferences(tpls, referenceTpls map[string]renderable)
 		}
 	}
 
+	g.guardRanges(t)
+
 	rendered = make(map[string]string, len(keys))
 	for _, filename := range keys {
 		// Don't render partials. We don't care out the direct output of partials.
@@ -256,8 +277,14 @@ func (e Engine) renderWithReferences(tpls, referenceTpls map[string]renderable)
 		// At render time, add information about the template that is being rendered.
 		vals := tpls[filename].vals
 		vals["Template"] = chartutil.Values{"Name": filename, "BasePath": tpls[filename].basePath}
+		if g.depth == 0 {
+			g.start(filename)
+		}
 		var buf strings.Builder
-		if err := t.ExecuteTemplate(&buf, filename, vals); err != nil {
+		if err := t.ExecuteTemplate(g.writer(&buf), filename, vals); err != nil {
+			if g.err != nil {
+				return map[string]string{}, g.err
+			}
 			return map[string]string{}, cleanupExecError(filename, err)
 		}
 
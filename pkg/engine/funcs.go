@@ -76,6 +76,17 @@ func funcMap() template.FuncMap {
 	return f
 }
 
+// FuncMap returns the functions of the templates rendered outside of a
+// chart, such as post-processed notes: the functions of the chart templates,
+// without the late-bound ones (include, tpl, required and lookup).
+func FuncMap() template.FuncMap {
+	f := funcMap()
+	for _, name := range []string{"include", "tpl", "required", "lookup"} {
+		delete(f, name)
+	}
+	return f
+}
+
 // toYAML takes an interface, marshals it to yaml, and returns a string. It will
 // always return a string, even on marshal error (empty string).
 //
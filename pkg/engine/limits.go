@@ -0,0 +1,347 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits bound the resources a render can use, so that a buggy or malicious
+// chart cannot exhaust the memory or the time of the process rendering it.
+// The zero value of a field disables the corresponding limit.
+//
+// Limits are enforced while the templates execute: the output is checked as
+// it is written, and the lengths when the functions producing lists and
+// strings (until, untilStep, seq, repeat and the rand functions), include
+// and tpl are called. The time is checked on each of these and at each
+// iteration of a range action, so that a loop producing no output is
+// interrupted too.
+type Limits struct {
+	// Timeout is the maximum time to render each template.
+	Timeout time.Duration
+	// MaxOutputSize is the maximum size in bytes of the output of each
+	// template, and of each string returned by include, tpl, repeat and the
+	// rand functions.
+	MaxOutputSize int
+	// MaxIncludeDepth is the maximum depth of nested include and tpl calls.
+	MaxIncludeDepth int
+	// MaxListLength is the maximum length of the lists returned by until and
+	// untilStep, and of the sequences returned by seq.
+	MaxListLength int
+}
+
+// DefaultLimits are the limits of the services rendering the charts of
+// their clients, helm serve and helm daemon, unless configured otherwise.
+var DefaultLimits = Limits{
+	Timeout:         time.Minute,
+	MaxOutputSize:   10 << 20,
+	MaxIncludeDepth: recursionMaxNums,
+	MaxListLength:   1000000,
+}
+
+// The limits a render can exceed, as reported by LimitError.Limit.
+const (
+	LimitTimeout      = "timeout"
+	LimitOutputSize   = "output size"
+	LimitIncludeDepth = "include depth"
+	LimitListLength   = "list length"
+)
+
+// LimitError is returned by Render when a template exceeds one of the
+// Limits of the engine.
+type LimitError struct {
+	// Template is the template being rendered.
+	Template string
+	// Limit is the exceeded limit, one of the Limit constants.
+	Limit string
+	// Max is the value of the exceeded limit.
+	Max string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rendering template %s exceeded the %s limit of %s", e.Template, e.Limit, e.Max)
+}
+
+// IsLimitExceeded reports whether err is, or wraps, a LimitError.
+func IsLimitExceeded(err error) bool {
+	var limit *LimitError
+	return errors.As(err, &limit)
+}
+
+// renderGuard enforces the Limits of an engine during a render.
+type renderGuard struct {
+	limits Limits
+	// template is the top-level template being executed, and deadline the
+	// time its execution must end by.
+	template string
+	deadline time.Time
+	// depth is the number of nested include and tpl calls.
+	depth int
+	// err is the first limit exceeded. It is returned by the render in place
+	// of the execution error wrapping it.
+	err *LimitError
+}
+
+func newRenderGuard(limits Limits) *renderGuard {
+	return &renderGuard{limits: limits}
+}
+
+// start records the execution of the top-level template name.
+func (g *renderGuard) start(name string) {
+	g.template = name
+	g.deadline = time.Time{}
+	if g.limits.Timeout > 0 {
+		g.deadline = time.Now().Add(g.limits.Timeout)
+	}
+}
+
+func (g *renderGuard) exceeded(limit, max string) error {
+	if g.err == nil {
+		g.err = &LimitError{Template: g.template, Limit: limit, Max: max}
+	}
+	return g.err
+}
+
+func (g *renderGuard) checkTime() error {
+	if !g.deadline.IsZero() && time.Now().After(g.deadline) {
+		return g.exceeded(LimitTimeout, g.limits.Timeout.String())
+	}
+	return nil
+}
+
+func (g *renderGuard) checkSize(size int) error {
+	if g.limits.MaxOutputSize > 0 && size > g.limits.MaxOutputSize {
+		return g.exceeded(LimitOutputSize, fmt.Sprintf("%d bytes", g.limits.MaxOutputSize))
+	}
+	return nil
+}
+
+func (g *renderGuard) checkLength(length int) error {
+	if err := g.checkTime(); err != nil {
+		return err
+	}
+	if g.limits.MaxListLength > 0 && length > g.limits.MaxListLength {
+		return g.exceeded(LimitListLength, fmt.Sprint(g.limits.MaxListLength))
+	}
+	return nil
+}
+
+// enter records a nested include or tpl call, to be ended by calling leave.
+func (g *renderGuard) enter() error {
+	if err := g.checkTime(); err != nil {
+		return err
+	}
+	if g.limits.MaxIncludeDepth > 0 && g.depth >= g.limits.MaxIncludeDepth {
+		return g.exceeded(LimitIncludeDepth, fmt.Sprint(g.limits.MaxIncludeDepth))
+	}
+	g.depth++
+	return nil
+}
+
+func (g *renderGuard) leave() {
+	g.depth--
+}
+
+// rangeGuardFunc is the function called by guardRanges at each iteration of
+// the range actions.
+const rangeGuardFunc = "_helmRangeGuard"
+
+// guardRanges makes the range actions of the templates of t check the time
+// at each iteration, if the render has a timeout. The templates must not be
+// executing.
+func (g *renderGuard) guardRanges(t *template.Template) {
+	if g.limits.Timeout <= 0 {
+		return
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			guardRangeNodes(tmpl.Tree, tmpl.Tree.Root)
+		}
+	}
+}
+
+// guardRangeNodes adds a call to rangeGuardFunc at the start of the range
+// actions of node, a node of tree.
+func guardRangeNodes(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			guardRangeNodes(tree, c)
+		}
+	case *parse.IfNode:
+		guardRangeNodes(tree, n.List)
+		guardRangeNodes(tree, n.ElseList)
+	case *parse.WithNode:
+		guardRangeNodes(tree, n.List)
+		guardRangeNodes(tree, n.ElseList)
+	case *parse.RangeNode:
+		guardRangeNodes(tree, n.List)
+		guardRangeNodes(tree, n.ElseList)
+		// The call prints an empty string.
+		call := &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(rangeGuardFunc).SetTree(tree).SetPos(n.Pos)},
+		}
+		action := &parse.ActionNode{
+			NodeType: parse.NodeAction,
+			Pos:      n.Pos,
+			Line:     n.Line,
+			Pipe:     &parse.PipeNode{NodeType: parse.NodePipe, Pos: n.Pos, Line: n.Line, Cmds: []*parse.CommandNode{call}},
+		}
+		n.List.Nodes = append([]parse.Node{action}, n.List.Nodes...)
+	}
+}
+
+// writer returns a writer to b enforcing the limits of the guard.
+func (g *renderGuard) writer(b *strings.Builder) *guardedWriter {
+	return &guardedWriter{guard: g, b: b}
+}
+
+type guardedWriter struct {
+	guard *renderGuard
+	b     *strings.Builder
+}
+
+func (w *guardedWriter) Write(p []byte) (int, error) {
+	if err := w.guard.checkTime(); err != nil {
+		return 0, err
+	}
+	if err := w.guard.checkSize(w.b.Len() + len(p)); err != nil {
+		return 0, err
+	}
+	return w.b.Write(p)
+}
+
+// guardFuncs overrides the functions of funcMap producing lists and strings
+// of arbitrary lengths with versions enforcing the limits of g.
+func guardFuncs(funcMap map[string]interface{}, g *renderGuard) {
+	funcMap[rangeGuardFunc] = func() (string, error) {
+		return "", g.checkTime()
+	}
+
+	until := funcMap["until"].(func(int) []int)
+	funcMap["until"] = func(count int) ([]int, error) {
+		if err := g.checkLength(abs(count)); err != nil {
+			return nil, err
+		}
+		return until(count), nil
+	}
+
+	untilStep := funcMap["untilStep"].(func(int, int, int) []int)
+	funcMap["untilStep"] = func(start, stop, step int) ([]int, error) {
+		if err := g.checkLength(stepCount(start, stop, step)); err != nil {
+			return nil, err
+		}
+		return untilStep(start, stop, step), nil
+	}
+
+	seq := funcMap["seq"].(func(...int) string)
+	funcMap["seq"] = func(params ...int) (string, error) {
+		// Bound the length with the largest range of the parameters, whatever
+		// their meaning.
+		length := 0
+		for _, p := range params {
+			for _, q := range params {
+				if n := abs(p-q) + 1; n > length {
+					length = n
+				}
+			}
+		}
+		if err := g.checkLength(length); err != nil {
+			return "", err
+		}
+		return seq(params...), nil
+	}
+
+	repeat := funcMap["repeat"].(func(int, string) string)
+	funcMap["repeat"] = func(count int, str string) (string, error) {
+		if err := g.checkTime(); err != nil {
+			return "", err
+		}
+		if count > 0 && len(str) > 0 {
+			// Cap the count to compute the size without overflowing.
+			if err := g.checkSize(len(str) * min(count, g.limits.MaxOutputSize/len(str)+1)); err != nil {
+				return "", err
+			}
+		}
+		return repeat(count, str), nil
+	}
+
+	for _, name := range []string{"randAlphaNum", "randAlpha", "randAscii", "randNumeric"} {
+		random := funcMap[name].(func(int) string)
+		funcMap[name] = func(count int) (string, error) {
+			if err := g.checkTime(); err != nil {
+				return "", err
+			}
+			if err := g.checkSize(count); err != nil {
+				return "", err
+			}
+			return random(count), nil
+		}
+	}
+
+	randBytes := funcMap["randBytes"].(func(int) (string, error))
+	funcMap["randBytes"] = func(count int) (string, error) {
+		if err := g.checkTime(); err != nil {
+			return "", err
+		}
+		// The bytes are base64 encoded. Check the count first to compute the
+		// size of the encoding without overflowing.
+		if err := g.checkSize(count); err != nil {
+			return "", err
+		}
+		if err := g.checkSize((count + 2) / 3 * 4); err != nil {
+			return "", err
+		}
+		return randBytes(count)
+	}
+}
+
+// stepCount returns the length of the list returned by untilStep.
+func stepCount(start, stop, step int) int {
+	switch {
+	case step > 0 && stop > start:
+		return (stop-start-1)/step + 1
+	case step < 0 && stop < start:
+		return (start-stop-1)/-step + 1
+	}
+	return 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
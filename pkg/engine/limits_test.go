@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chartutil"
+)
+
+func TestRenderLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    Limits
+		templates []*chart.File
+		limit     string
+	}{
+		{
+			name:   "list length",
+			limits: Limits{MaxListLength: 1000},
+			templates: []*chart.File{
+				{Name: "templates/loop", Data: []byte(`{{ range until 1000000000 }}x{{ end }}`)},
+			},
+			limit: LimitListLength,
+		},
+		{
+			name:   "untilStep length",
+			limits: Limits{MaxListLength: 1000},
+			templates: []*chart.File{
+				{Name: "templates/loop", Data: []byte(`{{ range untilStep 0 1000000000 2 }}x{{ end }}`)},
+			},
+			limit: LimitListLength,
+		},
+		{
+			name:   "seq length",
+			limits: Limits{MaxListLength: 1000},
+			templates: []*chart.File{
+				{Name: "templates/seq", Data: []byte(`{{ seq 1 1000000000 }}`)},
+			},
+			limit: LimitListLength,
+		},
+		{
+			name:   "output size",
+			limits: Limits{MaxOutputSize: 1024},
+			templates: []*chart.File{
+				{Name: "templates/loop", Data: []byte(`{{ range until 2000 }}x{{ end }}`)},
+			},
+			limit: LimitOutputSize,
+		},
+		{
+			name:   "repeat size",
+			limits: Limits{MaxOutputSize: 1024},
+			templates: []*chart.File{
+				{Name: "templates/repeat", Data: []byte(`{{ repeat 1000000000 "x" | len }}`)},
+			},
+			limit: LimitOutputSize,
+		},
+		{
+			name:   "include size",
+			limits: Limits{MaxOutputSize: 1024},
+			templates: []*chart.File{
+				{Name: "templates/base", Data: []byte(`{{ include "big" . | len }}`)},
+				{Name: "templates/_big", Data: []byte(`{{ define "big" }}{{ range until 2000 }}x{{ end }}{{ end }}`)},
+			},
+			limit: LimitOutputSize,
+		},
+		{
+			name:   "randAlphaNum size",
+			limits: Limits{MaxOutputSize: 1024},
+			templates: []*chart.File{
+				{Name: "templates/rand", Data: []byte(`{{ randAlphaNum 1000000000 | len }}`)},
+			},
+			limit: LimitOutputSize,
+		},
+		{
+			name:   "randBytes size",
+			limits: Limits{MaxOutputSize: 1024},
+			templates: []*chart.File{
+				{Name: "templates/rand", Data: []byte(`{{ randBytes 1000 | len }}`)},
+			},
+			limit: LimitOutputSize,
+		},
+		{
+			name:   "include depth",
+			limits: Limits{MaxIncludeDepth: 10},
+			templates: []*chart.File{
+				{Name: "templates/base", Data: []byte(`{{ include "recursion" . }}`)},
+				{Name: "templates/_recursion", Data: []byte(`{{ define "recursion" }}{{ include "recursion" . }}{{ end }}`)},
+			},
+			limit: LimitIncludeDepth,
+		},
+		{
+			name:   "tpl depth",
+			limits: Limits{MaxIncludeDepth: 10},
+			templates: []*chart.File{
+				{Name: "templates/base", Data: []byte(`{{ tpl "{{ include \"recursion\" . }}" . }}`)},
+				{Name: "templates/_recursion", Data: []byte(`{{ define "recursion" }}{{ tpl "{{ include \"recursion\" . }}" . }}{{ end }}`)},
+			},
+			limit: LimitIncludeDepth,
+		},
+		{
+			name:   "timeout",
+			limits: Limits{Timeout: 10 * time.Millisecond},
+			templates: []*chart.File{
+				{Name: "templates/loop", Data: []byte(`{{ range until 100000 }}{{ range until 100000 }}x{{ end }}{{ end }}`)},
+			},
+			limit: LimitTimeout,
+		},
+		{
+			name:   "timeout of loops without output",
+			limits: Limits{Timeout: 10 * time.Millisecond},
+			templates: []*chart.File{
+				{Name: "templates/loop", Data: []byte(`{{ $l := until 100000 }}{{ $x := 0 }}{{ range $l }}{{ range $l }}{{ $x = . }}{{ end }}{{ end }}`)},
+			},
+			limit: LimitTimeout,
+		},
+		{
+			name:   "timeout of loops in a defined template",
+			limits: Limits{Timeout: 10 * time.Millisecond},
+			templates: []*chart.File{
+				{Name: "templates/base", Data: []byte(`{{ include "loop" . }}`)},
+				{Name: "templates/_loop", Data: []byte(`{{ define "loop" }}{{ $l := until 100000 }}{{ range $l }}{{ if true }}{{ range $l }}{{ end }}{{ end }}{{ end }}{{ end }}`)},
+			},
+			limit: LimitTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &chart.Chart{
+				Metadata:  &chart.Metadata{Name: "limits"},
+				Templates: tt.templates,
+			}
+			v := chartutil.Values{"Values": map[string]interface{}{}, "Chart": c.Metadata}
+
+			_, err := Engine{Limits: tt.limits}.Render(c, v)
+			if !IsLimitExceeded(err) {
+				t.Fatalf("expected a LimitError, got %v", err)
+			}
+			limitErr := err.(*LimitError)
+			if limitErr.Limit != tt.limit {
+				t.Errorf("expected the %s limit to be exceeded, got %s", tt.limit, limitErr.Limit)
+			}
+			if !strings.HasPrefix(limitErr.Template, "limits/templates/") {
+				t.Errorf("unexpected template %q", limitErr.Template)
+			}
+		})
+	}
+}
+
+func TestRenderWithinLimits(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "limits"},
+		Templates: []*chart.File{
+			{Name: "templates/base", Data: []byte(`{{ range until 3 }}{{ include "item" . }}{{ end }}{{ seq 3 }} {{ repeat 2 "ab" }} {{ untilStep 0 6 2 }} {{ tpl "{{ 1 }}" $ }}`)},
+			{Name: "templates/_item", Data: []byte(`{{ define "item" }}{{ . }}{{ end }}`)},
+		},
+	}
+	v := chartutil.Values{"Values": map[string]interface{}{}, "Chart": c.Metadata}
+	limits := Limits{
+		Timeout:         time.Minute,
+		MaxOutputSize:   64,
+		MaxIncludeDepth: 2,
+		MaxListLength:   3,
+	}
+
+	out, err := Engine{Limits: limits}.Render(c, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "0121 2 3 abab [0 2 4] 1"
+	if got := out["limits/templates/base"]; got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+}
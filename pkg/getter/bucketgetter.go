@@ -0,0 +1,472 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package getter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/version"
+)
+
+// BucketObject is an object of an object storage bucket.
+type BucketObject struct {
+	// Key is the name of the object in the bucket.
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// BucketLister is a Getter that can list the objects of the object storage
+// buckets it downloads from.
+type BucketLister interface {
+	Getter
+	// List returns the objects of the bucket of url whose key starts with
+	// the path of url, e.g. "charts/" for s3://bucket/charts/.
+	List(url string, options ...Option) ([]BucketObject, error)
+}
+
+// RangeGetter is a Getter that can download a part of the content.
+type RangeGetter interface {
+	Getter
+	// GetRange returns at most length bytes of the content at url, starting
+	// at offset.
+	GetRange(url string, offset, length int64, options ...Option) (*bytes.Buffer, error)
+}
+
+// BucketGetter downloads from Amazon S3 (s3://bucket/key) and Google Cloud
+// Storage (gs://bucket/key) buckets through their HTTP APIs.
+//
+// S3 requests are signed with the credentials of the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, in the
+// region of AWS_REGION or AWS_DEFAULT_REGION, and sent to AWS_ENDPOINT_URL
+// if set, e.g. for MinIO. GCS requests are authorized with the access token
+// of GOOGLE_OAUTH_ACCESS_TOKEN, and sent to STORAGE_EMULATOR_HOST if set.
+// Requests without credentials are anonymous, which is enough for public
+// buckets.
+type BucketGetter struct {
+	opts options
+}
+
+// NewBucketGetter constructs a valid S3 and GCS client as a Getter
+func NewBucketGetter(options ...Option) (Getter, error) {
+	var client BucketGetter
+
+	for _, opt := range options {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
+
+// Get performs a Get from repo.Getter and returns the body.
+func (g *BucketGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	return g.GetRange(href, 0, -1, options...)
+}
+
+// GetRange downloads length bytes of the object at href from offset, or the
+// rest of the object if length is negative.
+func (g *BucketGetter) GetRange(href string, offset, length int64, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+	ctx, span := g.opts.startSpan(href)
+	buf, err := g.getRange(ctx, href, offset, length)
+	endSpan(span, err)
+	return buf, err
+}
+
+func (g *BucketGetter) getRange(ctx context.Context, href string, offset, length int64) (*bytes.Buffer, error) {
+	b, err := parseBucketURL(href)
+	if err != nil {
+		return nil, err
+	}
+	req, err := b.objectRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 || length >= 0 {
+		rng := fmt.Sprintf("bytes=%d-", offset)
+		if length >= 0 {
+			rng += strconv.FormatInt(offset+length-1, 10)
+		}
+		req.Header.Set("Range", rng)
+	}
+	resp, err := g.do(b, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch %s", href)
+	}
+	defer resp.Body.Close()
+
+	buf := bytes.NewBuffer(nil)
+	body := io.Reader(resp.Body)
+	// Servers ignoring the range send the whole object.
+	if resp.StatusCode == http.StatusOK && offset > 0 {
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			return buf, err
+		}
+	}
+	if length >= 0 {
+		body = io.LimitReader(body, length)
+	}
+	_, err = io.Copy(buf, body)
+	return buf, err
+}
+
+// List returns the objects of the bucket of href whose key starts with the
+// path of href.
+func (g *BucketGetter) List(href string, options ...Option) ([]BucketObject, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+	ctx := g.opts.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b, err := parseBucketURL(href)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []BucketObject
+	token := ""
+	for {
+		req, err := b.listRequest(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := g.do(b, req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", href)
+		}
+		page, next, err := b.parseList(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", href)
+		}
+		objects = append(objects, page...)
+		if next == "" {
+			return objects, nil
+		}
+		token = next
+	}
+}
+
+// do sends the request to the bucket, returning an error for the failed
+// requests.
+func (g *BucketGetter) do(b *bucketURL, req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", version.GetUserAgent())
+	if g.opts.userAgent != "" {
+		req.Header.Set("User-Agent", g.opts.userAgent)
+	}
+	b.authorize(req, time.Now())
+
+	client := &http.Client{Timeout: g.opts.timeout}
+	if g.opts.transport != nil {
+		client.Transport = g.opts.transport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, errors.New(resp.Status)
+	}
+	return resp, nil
+}
+
+// bucketURL is an s3:// or gs:// URL.
+type bucketURL struct {
+	scheme string
+	bucket string
+	key    string
+}
+
+func parseBucketURL(href string) (*bucketURL, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid bucket URL %s", href)
+	}
+	if (u.Scheme != "s3" && u.Scheme != "gs") || u.Host == "" {
+		return nil, errors.Errorf("invalid bucket URL %s: expected s3://bucket/key or gs://bucket/key", href)
+	}
+	return &bucketURL{scheme: u.Scheme, bucket: u.Host, key: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+// s3Endpoint returns the URL of the bucket in the S3 API, and whether the
+// bucket is given in the path rather than in the host.
+func (b *bucketURL) s3Endpoint() (*url.URL, bool, error) {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+		return u, true, err
+	}
+	return &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", b.bucket, s3Region())}, false, nil
+}
+
+// gcsEndpoint returns the URL of the GCS API.
+func gcsEndpoint() (*url.URL, error) {
+	endpoint := os.Getenv("STORAGE_EMULATOR_HOST")
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return url.Parse(strings.TrimSuffix(endpoint, "/"))
+}
+
+func s3Region() string {
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if r := os.Getenv(env); r != "" {
+			return r
+		}
+	}
+	return "us-east-1"
+}
+
+// objectRequest returns the request downloading the object.
+func (b *bucketURL) objectRequest(ctx context.Context) (*http.Request, error) {
+	if b.key == "" {
+		return nil, errors.Errorf("missing object key in %s://%s", b.scheme, b.bucket)
+	}
+	var u *url.URL
+	if b.scheme == "s3" {
+		endpoint, pathStyle, err := b.s3Endpoint()
+		if err != nil {
+			return nil, err
+		}
+		u = endpoint
+		p := "/" + b.key
+		if pathStyle {
+			p = "/" + b.bucket + p
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/") + p
+		u.RawPath = awsEscape(u.Path, false)
+	} else {
+		endpoint, err := gcsEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		// The key is a single path segment of the API, its slashes escaped.
+		u = endpoint
+		u.RawPath = u.EscapedPath() + "/storage/v1/b/" + url.PathEscape(b.bucket) + "/o/" + url.PathEscape(b.key)
+		u.Path += "/storage/v1/b/" + b.bucket + "/o/" + b.key
+		u.RawQuery = "alt=media"
+	}
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// listRequest returns the request listing the page of objects of token.
+func (b *bucketURL) listRequest(ctx context.Context, token string) (*http.Request, error) {
+	q := url.Values{}
+	if b.key != "" {
+		q.Set("prefix", b.key)
+	}
+	var u *url.URL
+	if b.scheme == "s3" {
+		endpoint, pathStyle, err := b.s3Endpoint()
+		if err != nil {
+			return nil, err
+		}
+		u = endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+		if pathStyle {
+			u.Path += b.bucket
+		}
+		q.Set("list-type", "2")
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+	} else {
+		endpoint, err := gcsEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		u = endpoint
+		u.Path += "/storage/v1/b/" + b.bucket + "/o"
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
+
+// parseList parses a page of objects, returning the token of the next page
+// if any.
+func (b *bucketURL) parseList(r io.Reader) ([]BucketObject, string, error) {
+	var objects []BucketObject
+	if b.scheme == "s3" {
+		var page struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.NewDecoder(r).Decode(&page); err != nil {
+			return nil, "", err
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, BucketObject{Key: c.Key, Size: c.Size, LastModified: c.LastModified})
+		}
+		if !page.IsTruncated {
+			return objects, "", nil
+		}
+		return objects, page.NextContinuationToken, nil
+	}
+
+	var page struct {
+		Items []struct {
+			Name    string    `json:"name"`
+			Size    string    `json:"size"`
+			Updated time.Time `json:"updated"`
+		} `json:"items"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	if err := json.NewDecoder(r).Decode(&page); err != nil {
+		return nil, "", err
+	}
+	for _, item := range page.Items {
+		size, _ := strconv.ParseInt(item.Size, 10, 64)
+		objects = append(objects, BucketObject{Key: item.Name, Size: size, LastModified: item.Updated})
+	}
+	return objects, page.NextPageToken, nil
+}
+
+// authorize sets the credentials of the environment on the request.
+func (b *bucketURL) authorize(req *http.Request, now time.Time) {
+	if b.scheme == "gs" {
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return
+	}
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return
+	}
+	signS3Request(req, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), s3Region(), now)
+}
+
+// emptyPayloadHash is the SHA-256 digest of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signS3Request signs the GET request with the AWS signature version 4.
+func signS3Request(req *http.Request, accessKey, secretKey, sessionToken, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": emptyPayloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if sessionToken != "" {
+		headers["x-amz-security-token"] = sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.Path, false),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape escapes s as required by the AWS signature: all the bytes but
+// the unreserved characters, and "/" unless escapeSlash is set.
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package getter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sources of the credentials recorded in a CredentialUse.
+const (
+	// CredentialSourceBasicAuth are the credentials given by WithBasicAuth,
+	// such as the ones of a repository of the repositories file.
+	CredentialSourceBasicAuth = "basic-auth"
+	// CredentialSourceProvider are the credentials of the host returned by
+	// the provider given by WithCredentialProvider.
+	CredentialSourceProvider = "credential-provider"
+)
+
+// CredentialUse records that credentials were attached to a request.
+type CredentialUse struct {
+	Time time.Time `json:"time"`
+	// URL is the URL of the request, without its user info and query.
+	URL string `json:"url"`
+	// Scope is the URL the credentials are configured for: the URL of the
+	// repository for basic auth, or the host for a credential provider.
+	Scope string `json:"scope"`
+	// Source is CredentialSourceBasicAuth or CredentialSourceProvider.
+	Source string `json:"source"`
+	// Redirect reports whether the request follows a redirect.
+	Redirect bool `json:"redirect,omitempty"`
+}
+
+// CredentialAuditor is called with every request credentials are attached
+// to.
+type CredentialAuditor interface {
+	// AuditCredentials records the use of credentials. The request fails if
+	// it returns an error.
+	AuditCredentials(use CredentialUse) error
+}
+
+// CredentialAuditorFunc adapts a function to a CredentialAuditor.
+type CredentialAuditorFunc func(use CredentialUse) error
+
+// AuditCredentials calls f(use).
+func (f CredentialAuditorFunc) AuditCredentials(use CredentialUse) error {
+	return f(use)
+}
+
+// credentialAuditLog appends the uses of credentials to a file.
+type credentialAuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewCredentialAuditLog returns a CredentialAuditor appending the uses of
+// credentials to the file path, one JSON object per line.
+func NewCredentialAuditLog(path string) CredentialAuditor {
+	return &credentialAuditLog{path: path}
+}
+
+func (l *credentialAuditLog) AuditCredentials(use CredentialUse) error {
+	data, err := json.Marshal(use)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "unable to write the credential audit log")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "unable to write the credential audit log")
+	}
+	return f.Close()
+}
+
+// WithStrictCredentialScope only sends the credentials given by
+// WithBasicAuth with the requests whose URL has the scheme and host of the
+// URL given by WithURL, and a path under its path, regardless of
+// WithPassCredentialsAll. Credentials are also removed from the redirects
+// leaving their scope, including the ones of a credential provider on
+// redirects to other hosts.
+func WithStrictCredentialScope(strict bool) Option {
+	return func(opts *options) {
+		opts.strictCredentials = strict
+	}
+}
+
+// WithCredentialAuditor sets the auditor called with every request
+// credentials are attached to.
+func WithCredentialAuditor(auditor CredentialAuditor) Option {
+	return func(opts *options) {
+		opts.credentialAuditor = auditor
+	}
+}
+
+// inCredentialScope reports whether u is in the scope of the credentials of
+// the repository URL scope: it has the same scheme and host, and a path
+// under the path of scope once its dot segments, encoded or not, are
+// resolved as the server would.
+func inCredentialScope(scope, u *url.URL) bool {
+	if scope.Scheme != u.Scheme || scope.Host != u.Host {
+		return false
+	}
+	prefix := strings.TrimSuffix(scope.Path, "/")
+	p := path.Clean("/" + u.Path)
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// auditCredentials records that the credentials of source, configured for
+// scope, were attached to a request of u.
+func (o *options) auditCredentials(u *url.URL, scope, source string, redirect bool) error {
+	if o.credentialAuditor == nil {
+		return nil
+	}
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.Fragment = ""
+	return o.credentialAuditor.AuditCredentials(CredentialUse{
+		Time:     time.Now(),
+		URL:      redacted.String(),
+		Scope:    scope,
+		Source:   source,
+		Redirect: redirect,
+	})
+}
+
+// checkRedirect returns the redirect policy of a request that credentials
+// of source, configured for scope, were attached to. In strict mode, the
+// credentials are removed from the redirects leaving their scope. The
+// redirects keeping the credentials are audited.
+func (o *options) checkRedirect(scope *url.URL, source string) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.Header.Get("Authorization") == "" {
+			return nil
+		}
+		if o.strictCredentials {
+			inScope := inCredentialScope(scope, req.URL)
+			if source == CredentialSourceProvider {
+				inScope = req.URL.Scheme == scope.Scheme && req.URL.Host == scope.Host
+			}
+			if !inScope {
+				req.Header.Del("Authorization")
+				return nil
+			}
+		}
+		return o.auditCredentials(req.URL, scope.String(), source, true)
+	}
+}
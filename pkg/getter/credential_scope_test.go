@@ -0,0 +1,135 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package getter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStrictCredentialScope(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		auth = map[string]bool{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		_, _, ok := r.BasicAuth()
+		// The server resolves the dot segments of the path.
+		auth[path.Clean(r.URL.Path)] = ok
+		mu.Unlock()
+		switch r.URL.EscapedPath() {
+		case "/charts/redirect":
+			http.Redirect(w, r, "/other/redirected.tgz", http.StatusFound)
+		case "/charts/redirect-dots":
+			w.Header().Set("Location", "http://"+r.Host+"/charts/../other/redirected.tgz")
+			w.WriteHeader(http.StatusFound)
+		case "/charts/redirect-encoded-dots":
+			w.Header().Set("Location", "http://"+r.Host+"/charts/%2e%2e/other/redirected.tgz")
+			w.WriteHeader(http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	var uses []CredentialUse
+	auditor := CredentialAuditorFunc(func(use CredentialUse) error {
+		uses = append(uses, use)
+		return nil
+	})
+
+	tests := []struct {
+		name   string
+		strict bool
+		path   string
+		auth   map[string]bool
+		audits int
+	}{
+		{name: "under the repository", strict: true, path: "/charts/index.yaml", auth: map[string]bool{"/charts/index.yaml": true}, audits: 1},
+		{name: "path sharing a prefix", strict: true, path: "/chartsfoo/a.tgz", auth: map[string]bool{"/chartsfoo/a.tgz": false}},
+		{name: "outside of the repository", strict: true, path: "/other/a.tgz", auth: map[string]bool{"/other/a.tgz": false}},
+		{name: "outside of the repository without strict scope", path: "/other/a.tgz", auth: map[string]bool{"/other/a.tgz": true}, audits: 1},
+		{name: "redirect leaving the repository", strict: true, path: "/charts/redirect", auth: map[string]bool{"/charts/redirect": true, "/other/redirected.tgz": false}, audits: 1},
+		{name: "dot segments leaving the repository", strict: true, path: "/charts/../other/a.tgz", auth: map[string]bool{"/other/a.tgz": false}},
+		{name: "encoded dot segments leaving the repository", strict: true, path: "/charts/%2e%2e/other/a.tgz", auth: map[string]bool{"/other/a.tgz": false}},
+		{name: "dot segments under the repository", strict: true, path: "/charts/sub/../a.tgz", auth: map[string]bool{"/charts/a.tgz": true}, audits: 1},
+		{name: "redirect with dot segments leaving the repository", strict: true, path: "/charts/redirect-dots", auth: map[string]bool{"/charts/redirect-dots": true, "/other/redirected.tgz": false}, audits: 1},
+		{name: "redirect with encoded dot segments leaving the repository", strict: true, path: "/charts/redirect-encoded-dots", auth: map[string]bool{"/charts/redirect-encoded-dots": true, "/other/redirected.tgz": false}, audits: 1},
+		{name: "redirect without strict scope", path: "/charts/redirect", auth: map[string]bool{"/charts/redirect": true, "/other/redirected.tgz": true}, audits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, uses = map[string]bool{}, nil
+			g, err := NewHTTPGetter(
+				WithURL(srv.URL+"/charts/"),
+				WithBasicAuth("user", "pass"),
+				WithStrictCredentialScope(tt.strict),
+				WithCredentialAuditor(auditor),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := g.Get(srv.URL + tt.path + "?token=secret"); err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range tt.auth {
+				if auth[path] != want {
+					t.Errorf("expected the credentials to be sent to %s: %t, got %t", path, want, auth[path])
+				}
+			}
+			if len(uses) != tt.audits {
+				t.Fatalf("expected %d audited uses of the credentials, got %+v", tt.audits, uses)
+			}
+			for _, use := range uses {
+				if strings.Contains(use.URL, "secret") || use.Source != CredentialSourceBasicAuth || use.Scope != srv.URL+"/charts/" {
+					t.Errorf("unexpected audited use %+v", use)
+				}
+			}
+		})
+	}
+}
+
+func TestCredentialAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit := NewCredentialAuditLog(path)
+	for _, u := range []string{"https://example.com/charts/index.yaml", "https://example.com/charts/a.tgz"} {
+		if err := audit.AuditCredentials(CredentialUse{URL: u, Scope: "https://example.com/charts", Source: CredentialSourceBasicAuth}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", data)
+	}
+	var use CredentialUse
+	if err := json.Unmarshal([]byte(lines[1]), &use); err != nil {
+		t.Fatal(err)
+	}
+	if use.URL != "https://example.com/charts/a.tgz" || use.Source != CredentialSourceBasicAuth {
+		t.Errorf("unexpected logged use %+v", use)
+	}
+}
@@ -18,12 +18,19 @@ package getter
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/plugin"
 	"github.com/open-hand/helm/pkg/registry"
 )
 
@@ -43,8 +50,15 @@ type options struct {
 	userAgent             string
 	version               string
 	registryClient        *registry.Client
+	credentialProvider    registry.CredentialProvider
 	timeout               time.Duration
 	transport             *http.Transport
+	ctx                   context.Context
+	tracerProvider        trace.TracerProvider
+	maxSize               int64
+	maxSizeSet            bool
+	strictCredentials     bool
+	credentialAuditor     CredentialAuditor
 }
 
 // Option allows specifying various settings configurable by the user for overriding the defaults
@@ -115,12 +129,48 @@ func WithRegistryClient(client *registry.Client) Option {
 	}
 }
 
+// WithCredentialProvider sets a provider of credentials for the hosts charts
+// are fetched from. It is only consulted when no basic auth credentials are
+// given.
+func WithCredentialProvider(provider registry.CredentialProvider) Option {
+	return func(opts *options) {
+		opts.credentialProvider = provider
+	}
+}
+
 func WithUntar() Option {
 	return func(opts *options) {
 		opts.unTar = true
 	}
 }
 
+// DefaultMaxSize is the size limit of the content downloaded by plugins,
+// unless WithMaxSize sets another one.
+const DefaultMaxSize int64 = 100 << 20
+
+// WithMaxSize limits the size of the content downloaded by plugins,
+// DefaultMaxSize by default. The plugins can lower the limit, not raise
+// it. A size of zero or less means no limit.
+func WithMaxSize(size int64) Option {
+	return func(opts *options) {
+		opts.maxSize = size
+		opts.maxSizeSet = true
+	}
+}
+
+// sizeLimit returns the size limit of the content downloaded by a plugin
+// declaring the given limit, capped by the limit of the options.
+func (o *options) sizeLimit(pluginLimit int64) int64 {
+	limit := DefaultMaxSize
+	if o.maxSizeSet {
+		limit = o.maxSize
+	}
+	if pluginLimit > 0 && (limit <= 0 || pluginLimit < limit) {
+		return pluginLimit
+	}
+	return limit
+}
+
 // WithTransport sets the http.Transport to allow overwriting the HTTPGetter default.
 func WithTransport(transport *http.Transport) Option {
 	return func(opts *options) {
@@ -128,12 +178,66 @@ func WithTransport(transport *http.Transport) Option {
 	}
 }
 
+// WithContext sets the context of the requests. It cancels them, and the
+// spans of the requests are children of its span.
+func WithContext(ctx context.Context) Option {
+	return func(opts *options) {
+		opts.ctx = ctx
+	}
+}
+
+// WithTracerProvider sets the provider of the tracer recording a span for
+// each Get.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(opts *options) {
+		opts.tracerProvider = tp
+	}
+}
+
+// startSpan starts the span of a Get of href. It records nothing if no
+// tracer provider is set.
+func (o *options) startSpan(href string) (context.Context, trace.Span) {
+	ctx := o.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tp := o.tracerProvider
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	// Credentials in the URL are not recorded.
+	if u, err := url.Parse(href); err == nil {
+		u.User = nil
+		href = u.String()
+	}
+	return tp.Tracer("github.com/open-hand/helm/pkg/getter").Start(ctx, "helm.getter.get",
+		trace.WithAttributes(attribute.String("helm.getter.url", href)))
+}
+
+// endSpan ends the span of a Get, recording its error if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 // Getter is an interface to support GET to the specified URL.
 type Getter interface {
 	// Get file content by url string
 	Get(url string, options ...Option) (*bytes.Buffer, error)
 }
 
+// StreamGetter is a Getter that can stream the content it downloads instead
+// of buffering it.
+type StreamGetter interface {
+	Getter
+	// GetStream returns the content at url. Reading it returns an error if
+	// the download fails; it must be closed.
+	GetStream(url string, options ...Option) (io.ReadCloser, error)
+}
+
 // Constructor is the function for every getter which creates a specific instance
 // according to the configuration
 type Constructor func(options ...Option) (Getter, error)
@@ -172,6 +276,17 @@ func (p Providers) ByScheme(scheme string) (Getter, error) {
 	return nil, errors.Errorf("scheme %q not supported", scheme)
 }
 
+// withOptions returns a copy of p whose getters are constructed with opts,
+// before any options given by the caller.
+func withOptions(p Provider, opts ...Option) Provider {
+	return Provider{
+		Schemes: p.Schemes,
+		New: func(options ...Option) (Getter, error) {
+			return p.New(append(append([]Option{}, opts...), options...)...)
+		},
+	}
+}
+
 var httpProvider = Provider{
 	Schemes: []string{"http", "https"},
 	New:     NewHTTPGetter,
@@ -182,12 +297,51 @@ var ociProvider = Provider{
 	New:     NewOCIGetter,
 }
 
+var bucketProvider = Provider{
+	Schemes: []string{"s3", "gs"},
+	New:     NewBucketGetter,
+}
+
 // All finds all of the registered getters as a list of Provider instances.
 // Currently, the built-in getters and the discovered plugins with downloader
 // notations are collected.
+//
+// The HTTP and OCI getters look up credentials in the registry config file and
+// the Docker config, including its credential helpers, then with the plugins
+// providing the credentials capability, for hosts that no credentials are
+// given for. The HTTP getter scopes the credentials strictly and audits
+// their uses as configured by the settings.
 func All(settings *cli.EnvSettings) Providers {
 	result := Providers{httpProvider, ociProvider}
+	var creds []registry.CredentialProvider
+	if docker, err := registry.NewDockerCredentialProvider(settings.RegistryConfig); err == nil {
+		creds = append(creds, docker)
+	}
+	if plugins, err := plugin.FindProviders(settings.PluginsDirectory, plugin.CapabilityCredentials); err == nil {
+		for _, p := range plugins {
+			creds = append(creds, plugin.NewCredentialProvider(p, settings))
+		}
+	}
+	var httpOpts []Option
+	if settings.StrictCredentials {
+		httpOpts = append(httpOpts, WithStrictCredentialScope(true))
+	}
+	if settings.CredentialAuditLog != "" {
+		httpOpts = append(httpOpts, WithCredentialAuditor(NewCredentialAuditLog(settings.CredentialAuditLog)))
+	}
+	if len(creds) > 0 {
+		provider := registry.ChainCredentialProviders(creds...)
+		result = Providers{
+			withOptions(httpProvider, append(httpOpts, WithCredentialProvider(provider))...),
+			withOptions(ociProvider, WithCredentialProvider(provider)),
+		}
+	} else if len(httpOpts) > 0 {
+		result[0] = withOptions(httpProvider, httpOpts...)
+	}
 	pluginDownloaders, _ := collectPlugins(settings)
 	result = append(result, pluginDownloaders...)
+	// The plugins downloading from buckets, such as helm-s3, take precedence
+	// over the built-in bucket getter.
+	result = append(result, bucketProvider)
 	return result
 }
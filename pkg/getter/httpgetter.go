@@ -17,7 +17,9 @@ package getter
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -42,13 +44,31 @@ func (g *HTTPGetter) Get(href string, options ...Option) (*bytes.Buffer, error)
 	for _, opt := range options {
 		opt(&g.opts)
 	}
-	return g.get(href)
+	ctx, span := g.opts.startSpan(href)
+	buf, err := g.get(ctx, href, 0, -1)
+	endSpan(span, err)
+	return buf, err
 }
 
-func (g *HTTPGetter) get(href string) (*bytes.Buffer, error) {
+// GetRange downloads length bytes of the content at href from offset with
+// a range request. The bytes are skipped or cut from the response of
+// servers ignoring the range.
+func (g *HTTPGetter) GetRange(href string, offset, length int64, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+	ctx, span := g.opts.startSpan(href)
+	buf, err := g.get(ctx, href, offset, length)
+	endSpan(span, err)
+	return buf, err
+}
+
+// get downloads the content at href, or only length bytes of it from offset
+// if length is not negative.
+func (g *HTTPGetter) get(ctx context.Context, href string, offset, length int64) (*bytes.Buffer, error) {
 	// Set a helm specific user agent so that a repo server and metrics can
 	// separate helm calls from other tools interacting with repos.
-	req, err := http.NewRequest(http.MethodGet, href, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, href, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +77,9 @@ func (g *HTTPGetter) get(href string) (*bytes.Buffer, error) {
 	if g.opts.userAgent != "" {
 		req.Header.Set("User-Agent", g.opts.userAgent)
 	}
+	if length >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
 
 	// Before setting the basic auth credentials, make sure the URL associated
 	// with the basic auth is the one being fetched.
@@ -71,10 +94,31 @@ func (g *HTTPGetter) get(href string) (*bytes.Buffer, error) {
 
 	// Host on URL (returned from url.Parse) contains the port if present.
 	// This check ensures credentials are not passed between different
-	// services on different ports.
-	if g.opts.passCredentialsAll || (u1.Scheme == u2.Scheme && u1.Host == u2.Host) {
-		if g.opts.username != "" && g.opts.password != "" {
-			req.SetBasicAuth(g.opts.username, g.opts.password)
+	// services on different ports. In strict mode, the path must also be
+	// under the path of the repository.
+	inScope := g.opts.passCredentialsAll || (u1.Scheme == u2.Scheme && u1.Host == u2.Host)
+	if g.opts.strictCredentials {
+		inScope = inCredentialScope(u1, u2)
+	}
+	scope, source := u1, CredentialSourceBasicAuth
+	if inScope && g.opts.username != "" && g.opts.password != "" {
+		req.SetBasicAuth(g.opts.username, g.opts.password)
+	}
+	// Credentials from the provider are looked up for the host being
+	// fetched, so they are never passed on to other hosts. If they cannot be
+	// retrieved the request is made anonymously, since most repositories do
+	// not need them.
+	var credErr error
+	if req.Header.Get("Authorization") == "" && g.opts.credentialProvider != nil {
+		scope, source = &url.URL{Scheme: u2.Scheme, Host: u2.Host}, CredentialSourceProvider
+		var username, password string
+		username, password, credErr = g.opts.credentialProvider.Credential(u2.Host)
+		switch {
+		case credErr != nil:
+		case username != "":
+			req.SetBasicAuth(username, password)
+		case password != "":
+			req.Header.Set("Authorization", "Bearer "+password)
 		}
 	}
 
@@ -82,18 +126,37 @@ func (g *HTTPGetter) get(href string) (*bytes.Buffer, error) {
 	if err != nil {
 		return nil, err
 	}
+	if req.Header.Get("Authorization") != "" {
+		if err := g.opts.auditCredentials(u2, scope.String(), source, false); err != nil {
+			return nil, err
+		}
+		client.CheckRedirect = g.opts.checkRedirect(scope, source)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode != http.StatusOK && (length < 0 || resp.StatusCode != http.StatusPartialContent) {
+		if credErr != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, errors.Errorf("failed to fetch %s : %s (unable to retrieve credentials: %s)", href, resp.Status, credErr)
+		}
 		return nil, errors.Errorf("failed to fetch %s : %s", href, resp.Status)
 	}
 
 	buf := bytes.NewBuffer(nil)
-	_, err = io.Copy(buf, resp.Body)
+	body := io.Reader(resp.Body)
+	if length >= 0 {
+		// Servers ignoring the range send the whole content.
+		if resp.StatusCode == http.StatusOK && offset > 0 {
+			if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+				return buf, err
+			}
+		}
+		body = io.LimitReader(body, length)
+	}
+	_, err = io.Copy(buf, body)
 	return buf, err
 }
 
@@ -16,8 +16,11 @@ limitations under the License.
 package getter
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -29,10 +32,15 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/open-hand/helm/internal/test/ensure"
 	"github.com/open-hand/helm/internal/tlsutil"
 	"github.com/open-hand/helm/internal/version"
 	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/registry"
 )
 
 func TestHTTPGetter(t *testing.T) {
@@ -282,6 +290,81 @@ func TestDownload(t *testing.T) {
 	}
 }
 
+func TestDownloadWithCredentialProvider(t *testing.T) {
+	expect := "Call me Ishmael"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "username" || password != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		fmt.Fprint(w, expect)
+	}))
+	defer srv.Close()
+	u, _ := url.ParseRequestURI(srv.URL)
+
+	// credentials from a Docker config file
+	dir := ensure.TempDir(t)
+	config := fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, u.Host, base64.StdEncoding.EncodeToString([]byte("username:password")))
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	creds, err := registry.NewDockerCredentialProvider(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := NewHTTPGetter(WithURL(srv.URL), WithCredentialProvider(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != expect {
+		t.Errorf("Expected %q, got %q", expect, got.String())
+	}
+
+	// identity tokens are sent as bearer tokens
+	token := registry.CredentialProviderFunc(func(host string) (string, string, error) {
+		if host != u.Host {
+			t.Errorf("Expected credentials to be requested for %q, got %q", u.Host, host)
+		}
+		return "", "token", nil
+	})
+	g, err = NewHTTPGetter(WithURL(srv.URL), WithCredentialProvider(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	// basic auth given explicitly takes precedence
+	g, err = NewHTTPGetter(WithURL(srv.URL), WithBasicAuth("username", "wrong"), WithCredentialProvider(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get(srv.URL); err == nil {
+		t.Error("Expected explicit basic auth to be used")
+	}
+
+	// a failing provider falls back to anonymous access and is reported
+	failing := registry.CredentialProviderFunc(func(string) (string, string, error) {
+		return "", "", errors.New("helper not found")
+	})
+	g, err = NewHTTPGetter(WithURL(srv.URL), WithCredentialProvider(failing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "helper not found") {
+		t.Errorf("Expected the credential error to be reported, got %v", err)
+	}
+}
+
 func TestDownloadTLS(t *testing.T) {
 	cd := "../../testdata"
 	ca, pub, priv := filepath.Join(cd, "rootca.crt"), filepath.Join(cd, "crt.pem"), filepath.Join(cd, "key.pem")
@@ -399,6 +482,58 @@ func TestHTTPGetterTarDownload(t *testing.T) {
 	}
 }
 
+func TestHTTPGetterGetRange(t *testing.T) {
+	content := "0123456789abcdefghij"
+	tests := []struct {
+		name         string
+		ignoreRanges bool
+	}{
+		{name: "range request"},
+		{name: "server ignoring ranges", ignoreRanges: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if tt.ignoreRanges {
+					io.WriteString(w, content)
+					return
+				}
+				http.ServeContent(w, r, "chart.tgz", time.Time{}, strings.NewReader(content))
+			}))
+			defer srv.Close()
+
+			g, err := NewHTTPGetter(WithURL(srv.URL))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rg, ok := g.(RangeGetter)
+			if !ok {
+				t.Fatal("expected the HTTP getter to be a RangeGetter")
+			}
+			data, err := rg.GetRange(srv.URL, 5, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.String() != "56789abc" {
+				t.Errorf("expected %q, got %q", "56789abc", data.String())
+			}
+			if len(ranges) != 1 || ranges[0] != "bytes=5-12" {
+				t.Errorf("expected a request of the range bytes=5-12, got %q", ranges)
+			}
+
+			data, err = rg.GetRange(srv.URL, 15, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.String() != "fghij" {
+				t.Errorf("expected the end of the content %q, got %q", "fghij", data.String())
+			}
+		})
+	}
+}
+
 func TestHttpClientInsecureSkipVerify(t *testing.T) {
 	g := HTTPGetter{}
 	g.opts.url = "https://localhost"
@@ -530,3 +665,72 @@ func TestHTTPTransportOption(t *testing.T) {
 		t.Fatal("transport.TLSClientConfig should not be set")
 	}
 }
+
+// recordingTracer records the spans it starts.
+type recordingTracer struct {
+	spans []*recordingSpan
+}
+
+func (r *recordingTracer) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return r
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	config := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{
+		Span:  trace.SpanFromContext(ctx),
+		name:  name,
+		attrs: config.Attributes(),
+	}
+	r.spans = append(r.spans, span)
+	return ctx, span
+}
+
+type recordingSpan struct {
+	trace.Span
+	name   string
+	attrs  []attribute.KeyValue
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+func (s *recordingSpan) End(...trace.SpanEndOption)          { s.ended = true }
+
+func TestHTTPGetterTracing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "chart")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("user", "secret")
+
+	tracer := &recordingTracer{}
+	g, err := NewHTTPGetter(WithTracerProvider(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get(u.String() + "/chart.tgz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Get(u.String() + "/missing"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(tracer.spans))
+	}
+	for i, want := range []codes.Code{codes.Unset, codes.Error} {
+		span := tracer.spans[i]
+		if span.name != "helm.getter.get" || !span.ended || span.status != want {
+			t.Errorf("span %d: unexpected name %q, ended %t or status %v", i, span.name, span.ended, span.status)
+		}
+		if len(span.attrs) != 1 || strings.Contains(span.attrs[0].Value.AsString(), "secret") {
+			t.Errorf("span %d: unexpected attributes %v", i, span.attrs)
+		}
+	}
+}
@@ -33,7 +33,10 @@ func (g *OCIGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
 	for _, opt := range options {
 		opt(&g.opts)
 	}
-	return g.get(href)
+	_, span := g.opts.startSpan(href)
+	buf, err := g.get(href)
+	endSpan(span, err)
+	return buf, err
 }
 
 func (g *OCIGetter) get(href string) (*bytes.Buffer, error) {
@@ -80,5 +83,17 @@ func NewOCIGetter(ops ...Option) (Getter, error) {
 		opt(&client.opts)
 	}
 
+	// The default client is replaced so that it uses the credential
+	// provider; a client given by the caller is kept as it is.
+	if client.opts.registryClient == registryClient && client.opts.credentialProvider != nil {
+		client.opts.registryClient, err = registry.NewClient(
+			registry.ClientOptEnableCache(true),
+			registry.ClientOptCredentialProvider(client.opts.credentialProvider),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &client, nil
 }
@@ -17,6 +17,9 @@ package getter
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -36,18 +39,24 @@ func collectPlugins(settings *cli.EnvSettings) (Providers, error) {
 		return nil, err
 	}
 	var result Providers
-	for _, plugin := range plugins {
-		for _, downloader := range plugin.Metadata.Downloaders {
+	for _, plug := range plugins {
+		for _, downloader := range plug.Metadata.Downloaders {
 			result = append(result, Provider{
 				Schemes: downloader.Protocols,
-				New: NewPluginGetter(
-					downloader.Command,
+				New: NewDownloaderPluginGetter(
+					downloader,
 					settings,
-					plugin.Metadata.Name,
-					plugin.Dir,
+					plug.Metadata.Name,
+					plug.Dir,
 				),
 			})
 		}
+		if plug.Provides(plugin.CapabilityGetter) {
+			result = append(result, Provider{
+				Schemes: plug.Metadata.Protocol.Schemes,
+				New:     NewProtocolPluginGetter(plug, settings),
+			})
+		}
 	}
 	return result, nil
 }
@@ -55,45 +64,203 @@ func collectPlugins(settings *cli.EnvSettings) (Providers, error) {
 // pluginGetter is a generic type to invoke custom downloaders,
 // implemented in plugins.
 type pluginGetter struct {
-	command  string
-	settings *cli.EnvSettings
-	name     string
-	base     string
-	opts     options
+	downloader plugin.Downloaders
+	settings   *cli.EnvSettings
+	name       string
+	base       string
+	opts       options
 }
 
 // Get runs downloader plugin command
 func (p *pluginGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	stream, err := p.GetStream(href, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	buf := bytes.NewBuffer(nil)
+	if _, err := buf.ReadFrom(stream); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// GetStream runs the downloader plugin command, streaming its output.
+// Reading the stream fails if the command exits with an error or writes
+// more than the size limit of the plugin or of the options.
+func (p *pluginGetter) GetStream(href string, options ...Option) (io.ReadCloser, error) {
 	for _, opt := range options {
 		opt(&p.opts)
 	}
-	commands := strings.Split(p.command, " ")
+	commands := strings.Split(p.downloader.Command, " ")
 	argv := append(commands[1:], p.opts.certFile, p.opts.keyFile, p.opts.caFile, href)
 	prog := exec.Command(filepath.Join(p.base, commands[0]), argv...)
 	plugin.SetupPluginEnv(p.settings, p.name, p.base)
 	prog.Env = os.Environ()
-	buf := bytes.NewBuffer(nil)
-	prog.Stdout = buf
 	prog.Stderr = os.Stderr
-	if err := prog.Run(); err != nil {
-		if eerr, ok := err.(*exec.ExitError); ok {
-			os.Stderr.Write(eerr.Stderr)
-			return nil, errors.Errorf("plugin %q exited with error", p.command)
+
+	var creds *os.File
+	if p.downloader.CredentialsFD {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
 		}
+		defer r.Close()
+		creds = w
+		prog.ExtraFiles = []*os.File{r}
+		prog.Env = append(prog.Env, "HELM_PLUGIN_CREDENTIALS_FD=3")
+	}
+	stdout, err := prog.StdoutPipe()
+	if err != nil {
 		return nil, err
 	}
-	return buf, nil
+	if err := prog.Start(); err != nil {
+		if creds != nil {
+			creds.Close()
+		}
+		return nil, err
+	}
+	if creds != nil {
+		go func() {
+			json.NewEncoder(creds).Encode(p.credentials(href))
+			creds.Close()
+		}()
+	}
+
+	limit := p.opts.sizeLimit(p.downloader.MaxSize)
+	return &pluginStream{prog: prog, stdout: stdout, command: p.downloader.Command, limit: limit}, nil
+}
+
+// pluginCredentials are the credentials passed to a downloader plugin.
+type pluginCredentials struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// credentials returns the credentials of the repository, unless href is on
+// another host and the credentials are not passed to all domains.
+func (p *pluginGetter) credentials(href string) *pluginCredentials {
+	u1, err1 := url.Parse(p.opts.url)
+	u2, err2 := url.Parse(href)
+	if !p.opts.passCredentialsAll && (err1 != nil || err2 != nil || u1.Scheme != u2.Scheme || u1.Host != u2.Host) {
+		return &pluginCredentials{}
+	}
+	return &pluginCredentials{Username: p.opts.username, Password: p.opts.password}
+}
+
+// pluginStream is the output of a downloader plugin command.
+type pluginStream struct {
+	prog    *exec.Cmd
+	stdout  io.ReadCloser
+	command string
+	limit   int64
+	read    int64
+	waited  bool
+}
+
+func (s *pluginStream) Read(b []byte) (int, error) {
+	n, err := s.stdout.Read(b)
+	s.read += int64(n)
+	if s.limit > 0 && s.read > s.limit {
+		s.Close()
+		return n, errors.Errorf("plugin %q exceeded the download size limit of %d bytes", s.command, s.limit)
+	}
+	if err == io.EOF {
+		if werr := s.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (s *pluginStream) wait() error {
+	if s.waited {
+		return nil
+	}
+	s.waited = true
+	if err := s.prog.Wait(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return errors.Errorf("plugin %q exited with error", s.command)
+		}
+		return err
+	}
+	return nil
+}
+
+// Close stops the command if it is still running.
+func (s *pluginStream) Close() error {
+	if s.waited {
+		return nil
+	}
+	s.prog.Process.Kill()
+	s.waited = true
+	s.prog.Wait()
+	return nil
 }
 
 // NewPluginGetter constructs a valid plugin getter
 func NewPluginGetter(command string, settings *cli.EnvSettings, name, base string) Constructor {
+	return NewDownloaderPluginGetter(plugin.Downloaders{Command: command}, settings, name, base)
+}
+
+// NewDownloaderPluginGetter constructs a plugin getter running the command
+// of downloader.
+func NewDownloaderPluginGetter(downloader plugin.Downloaders, settings *cli.EnvSettings, name, base string) Constructor {
 	return func(options ...Option) (Getter, error) {
 		result := &pluginGetter{
-			command:  command,
-			settings: settings,
-			name:     name,
-			base:     base,
+			downloader: downloader,
+			settings:   settings,
+			name:       name,
+			base:       base,
+		}
+		for _, opt := range options {
+			opt(&result.opts)
 		}
+		return result, nil
+	}
+}
+
+// protocolPluginGetter downloads charts with a protocol plugin providing
+// the getter capability.
+type protocolPluginGetter struct {
+	plugin   *plugin.Plugin
+	settings *cli.EnvSettings
+	opts     options
+}
+
+// Get calls the getter.get method of the plugin.
+func (p *protocolPluginGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&p.opts)
+	}
+	c, err := plugin.Start(p.plugin, p.settings)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	var resp plugin.GetResponse
+	err = c.Call("getter.get", &plugin.GetRequest{
+		URL:                   href,
+		Username:              p.opts.username,
+		Password:              p.opts.password,
+		CertFile:              p.opts.certFile,
+		KeyFile:               p.opts.keyFile,
+		CAFile:                p.opts.caFile,
+		InsecureSkipTLSverify: p.opts.insecureSkipVerifyTLS,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if limit := p.opts.sizeLimit(0); limit > 0 && int64(len(resp.Data)) > limit {
+		return nil, errors.Errorf("plugin %q exceeded the download size limit of %d bytes", p.plugin.Metadata.Name, limit)
+	}
+	return bytes.NewBuffer(resp.Data), nil
+}
+
+// NewProtocolPluginGetter constructs a getter calling a protocol plugin.
+func NewProtocolPluginGetter(p *plugin.Plugin, settings *cli.EnvSettings) Constructor {
+	return func(options ...Option) (Getter, error) {
+		result := &protocolPluginGetter{plugin: p, settings: settings}
 		for _, opt := range options {
 			opt(&result.opts)
 		}
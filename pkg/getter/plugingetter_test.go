@@ -16,11 +16,14 @@ limitations under the License.
 package getter
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/plugin"
 )
 
 func TestCollectPlugins(t *testing.T) {
@@ -99,3 +102,116 @@ func TestPluginSubCommands(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expect, got)
 	}
 }
+
+func TestPluginGetterMaxSize(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TODO: refactor this test to work on windows")
+	}
+
+	env := cli.New()
+	env.PluginsDirectory = pluginDir
+	pg := NewDownloaderPluginGetter(plugin.Downloaders{Command: "echo", MaxSize: 8}, env, "test", ".")
+	g, err := pg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Get("test://foo/bar"); err == nil || !strings.Contains(err.Error(), "size limit of 8 bytes") {
+		t.Errorf("expected a size limit error, got %v", err)
+	}
+	data, err := g.Get("test://foo/bar", WithMaxSize(4))
+	if err == nil || !strings.Contains(err.Error(), "size limit of 4 bytes") {
+		t.Errorf("expected a size limit error, got %v and %q", err, data)
+	}
+	if _, err := g.Get("test://foo/bar", WithMaxSize(100)); err == nil || !strings.Contains(err.Error(), "size limit of 8 bytes") {
+		t.Errorf("expected the plugin not to raise its size limit, got %v", err)
+	}
+}
+
+func TestSizeLimit(t *testing.T) {
+	for _, tt := range []struct {
+		opts        []Option
+		pluginLimit int64
+		expect      int64
+	}{
+		{nil, 0, DefaultMaxSize},
+		{nil, 8, 8},
+		{nil, DefaultMaxSize * 2, DefaultMaxSize},
+		{[]Option{WithMaxSize(4)}, 8, 4},
+		{[]Option{WithMaxSize(16)}, 8, 8},
+		{[]Option{WithMaxSize(0)}, 0, 0},
+		{[]Option{WithMaxSize(0)}, 8, 8},
+	} {
+		var o options
+		for _, opt := range tt.opts {
+			opt(&o)
+		}
+		if got := o.sizeLimit(tt.pluginLimit); got != tt.expect {
+			t.Errorf("%+v with plugin limit %d: expected %d, got %d", o, tt.pluginLimit, tt.expect, got)
+		}
+	}
+}
+
+func TestPluginGetterStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TODO: refactor this test to work on windows")
+	}
+
+	env := cli.New()
+	env.PluginsDirectory = pluginDir
+	g, err := NewPluginGetter("false", env, "test", ".")()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := g.(StreamGetter).GetStream("test://foo/bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if _, err := ioutil.ReadAll(stream); err == nil || !strings.Contains(err.Error(), "exited with error") {
+		t.Errorf("expected the exit error of the plugin, got %v", err)
+	}
+}
+
+func TestPluginGetterCredentialsFD(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TODO: refactor this test to work on windows")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\"\ncat <&$HELM_PLUGIN_CREDENTIALS_FD\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "get.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := cli.New()
+	env.PluginsDirectory = pluginDir
+	pg := NewDownloaderPluginGetter(plugin.Downloaders{Command: "get.sh", CredentialsFD: true}, env, "test", dir)
+
+	tests := []struct {
+		name string
+		href string
+		want string
+	}{
+		{"same host", "test://repo/foo.tgz", `{"username":"user","password":"secret"}`},
+		{"other host", "test://other/foo.tgz", `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := pg(WithURL("test://repo/"), WithBasicAuth("user", "secret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := g.Get(tt.href)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(data.String()), "\n")
+			if len(lines) != 2 || lines[1] != tt.want {
+				t.Errorf("expected credentials %s, got %q", tt.want, data)
+			}
+			if strings.Contains(lines[0], "secret") {
+				t.Errorf("expected no credentials in the arguments, got %q", lines[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmdtest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	shellwords "github.com/mattn/go-shellwords"
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/internal/test"
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/kube"
+	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// RootCmdFunc creates the root command of a CLI running its actions with
+// the given configuration and writing to out. args are the arguments of the
+// command line, without the name of the program.
+type RootCmdFunc func(cfg *action.Configuration, out io.Writer, args []string) (*cobra.Command, error)
+
+// Case describes a command line run against releases.
+type Case struct {
+	// Name is the name of the subtest of the case.
+	Name string
+	// Cmd is the command line, without the name of the program. It is split
+	// like a shell would.
+	Cmd string
+	// Golden is the golden file the output of the command must match,
+	// relative to the testdata directory. The output is not checked if empty.
+	Golden string
+	// WantError is whether the command must fail.
+	WantError bool
+	// Releases are the available releases at the start of the case.
+	Releases []*release.Release
+	// Repeat is how many more times the case is run, to check that a
+	// command produces the same output each time.
+	Repeat int
+}
+
+// Runner runs command lines of a CLI.
+type Runner struct {
+	// NewRootCmd creates the root command of the CLI.
+	NewRootCmd RootCmdFunc
+	// NewKubeClient returns the Kubernetes client of a command. The client
+	// defaults to a fake one printing the resources to nowhere.
+	NewKubeClient func() kube.Interface
+	// Configure, if not nil, is called with the configuration of the actions
+	// of a command before it runs, e.g. to set their policies or metrics.
+	Configure func(cfg *action.Configuration)
+	// Namespace returns the namespace of the releases of a command once its
+	// root command is created, "default" if nil.
+	Namespace func() string
+	// Reset is called before each case, and the function it returns after
+	// it, to restore the state the commands change such as the environment.
+	Reset func() func()
+	// WriteError, if not nil, writes the error of a command that failed to
+	// its output, as the CLI does once the command returns.
+	WriteError func(cmd *cobra.Command, out io.Writer, err error)
+}
+
+// Run runs the cases as subtests of t, failing them when the commands do not
+// succeed or fail as expected, or do not output their golden files.
+func (r *Runner) Run(t *testing.T, cases []Case) {
+	t.Helper()
+	for _, tt := range cases {
+		for i := 0; i <= tt.Repeat; i++ {
+			t.Run(tt.Name, func(t *testing.T) {
+				if r.Reset != nil {
+					defer r.Reset()()
+				}
+
+				store := helmtesting.NewStorage()
+				for _, rel := range tt.Releases {
+					if err := store.Create(rel); err != nil {
+						t.Fatal(err)
+					}
+				}
+				t.Logf("running cmd (attempt %d): %s", i+1, tt.Cmd)
+				_, out, err := r.Execute(store, nil, tt.Cmd)
+				if tt.WantError && err == nil {
+					t.Errorf("expected error, got success with the following output:\n%s", out)
+				}
+				if !tt.WantError && err != nil {
+					t.Errorf("expected no error, got: '%v'", err)
+				}
+				if tt.Golden != "" {
+					AssertGolden(t, out, tt.Golden)
+				}
+			})
+		}
+	}
+}
+
+// Execute runs the command line against the releases of store, reading from
+// in if not nil, and returns the command that ran with its output.
+func (r *Runner) Execute(store *storage.Storage, in *os.File, cmd string) (*cobra.Command, string, error) {
+	args, err := shellwords.Parse(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	var kubeClient kube.Interface = &kubefake.PrintingKubeClient{Out: ioutil.Discard}
+	if r.NewKubeClient != nil {
+		kubeClient = r.NewKubeClient()
+	}
+	actionConfig := &action.Configuration{
+		Releases:     store,
+		KubeClient:   kubeClient,
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          func(format string, v ...interface{}) {},
+	}
+	if r.Configure != nil {
+		r.Configure(actionConfig)
+	}
+
+	root, err := r.NewRootCmd(actionConfig, buf, args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	root.SetOut(buf)
+	root.SetErr(buf)
+	root.SetArgs(args)
+
+	oldStdin := os.Stdin
+	if in != nil {
+		root.SetIn(in)
+		os.Stdin = in
+	}
+
+	if mem, ok := store.Driver.(*driver.Memory); ok {
+		namespace := "default"
+		if r.Namespace != nil {
+			namespace = r.Namespace()
+		}
+		mem.SetNamespace(namespace)
+	}
+	c, err := root.ExecuteC()
+	if err != nil && c != nil && r.WriteError != nil {
+		r.WriteError(c, buf, err)
+	}
+
+	result := buf.String()
+
+	os.Stdin = oldStdin
+
+	return c, result, err
+}
+
+// AssertGolden fails t if actual does not match the content of the golden
+// file, relative to the testdata directory unless absolute. The file is
+// rewritten with actual instead when the tests run with the -update flag.
+func AssertGolden(t testing.TB, actual, filename string) {
+	t.Helper()
+	test.AssertGoldenString(t, actual, filename)
+}
@@ -0,0 +1,128 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmdtest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/helmtesting"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// newRootCmd creates a CLI listing the releases.
+func newRootCmd(cfg *action.Configuration, out io.Writer, _ []string) (*cobra.Command, error) {
+	root := &cobra.Command{Use: "cli", SilenceUsage: true}
+	root.AddCommand(&cobra.Command{
+		Use:  "list",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			rels, err := action.NewList(cfg).Run()
+			if err != nil {
+				return err
+			}
+			for _, rel := range rels {
+				fmt.Fprintf(out, "%s\t%d\t%s\n", rel.Name, rel.Version, rel.Info.Status)
+			}
+			return nil
+		},
+	})
+	return root, nil
+}
+
+func testRelease(name string, version int) *release.Release {
+	return &release.Release{
+		Name:      name,
+		Namespace: "default",
+		Version:   version,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "test", Version: "0.1.0"}},
+	}
+}
+
+func TestRunner(t *testing.T) {
+	runner := &Runner{NewRootCmd: newRootCmd}
+	runner.Run(t, []Case{{
+		Name:     "list releases",
+		Cmd:      "list",
+		Golden:   "list.txt",
+		Releases: []*release.Release{testRelease("alpha", 1), testRelease("beta", 3)},
+		Repeat:   1,
+	}, {
+		Name:   "list no release",
+		Cmd:    "list",
+		Golden: "list-empty.txt",
+	}, {
+		Name:      "unknown command",
+		Cmd:       "install",
+		WantError: true,
+	}})
+}
+
+func TestRunnerReset(t *testing.T) {
+	var resets, restores int
+	runner := &Runner{
+		NewRootCmd: newRootCmd,
+		Reset: func() func() {
+			resets++
+			return func() { restores++ }
+		},
+	}
+	runner.Run(t, []Case{{Name: "list", Cmd: "list", Repeat: 2}})
+	if resets != 3 || restores != 3 {
+		t.Errorf("expected 3 resets and restores, got %d and %d", resets, restores)
+	}
+}
+
+func TestRunnerWriteError(t *testing.T) {
+	runner := &Runner{
+		NewRootCmd: newRootCmd,
+		WriteError: func(cmd *cobra.Command, out io.Writer, err error) {
+			fmt.Fprintf(out, "%s failed\n", cmd.Name())
+		},
+	}
+	_, out, err := runner.Execute(helmtesting.NewStorage(), nil, "list extra")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.HasSuffix(out, "list failed\n") {
+		t.Errorf("expected the error to be written, got %q", out)
+	}
+}
+
+func TestRunnerConfigure(t *testing.T) {
+	runner := &Runner{
+		NewRootCmd: newRootCmd,
+		Configure: func(cfg *action.Configuration) {
+			cfg.Releases = helmtesting.NewStorage()
+			cfg.Releases.Create(testRelease("gamma", 2))
+		},
+	}
+	_, out, err := runner.Execute(helmtesting.NewStorage(), nil, "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "gamma\t2\t") {
+		t.Errorf("expected the configured releases to be listed, got %q", out)
+	}
+}
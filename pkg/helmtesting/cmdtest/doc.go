@@ -0,0 +1,36 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package cmdtest runs command lines of helm, or of the CLIs built on top of
+the actions, against releases kept in memory, and compares their output with
+golden files.
+
+A test builds a Runner with the function creating the root command of the
+CLI, then runs its cases:
+
+	runner := &cmdtest.Runner{NewRootCmd: newRootCmd}
+	runner.Run(t, []cmdtest.Case{{
+		Name:     "list releases",
+		Cmd:      "list",
+		Golden:   "output/list.txt",
+		Releases: []*release.Release{rel},
+	}})
+
+The golden files are read from the testdata directory, and rewritten with
+the output of the commands when the tests run with the -update flag.
+*/
+package cmdtest // import "github.com/open-hand/helm/pkg/helmtesting/cmdtest"
@@ -0,0 +1,30 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package helmtesting provides an in-process fake cluster to unit test the code
+// embedding the actions: a release storage kept in memory, a Kubernetes client
+// keeping the state of the resources the actions apply, and capability stubs.
+//
+//	cfg := helmtesting.NewConfiguration(t)
+//	install := action.NewInstall(cfg, ...)
+//	if _, err := install.Run(chart, values, ""); err != nil {
+//		t.Fatal(err)
+//	}
+//	kc := cfg.KubeClient.(*helmtesting.KubeClient)
+//	if _, ok := kc.Get("v1", "ConfigMap", "default", "config"); !ok {
+//		t.Error("expected the config map to be created")
+//	}
+package helmtesting // import "github.com/open-hand/helm/pkg/helmtesting"
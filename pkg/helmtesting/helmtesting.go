@@ -0,0 +1,73 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmtesting
+
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// NewStorage returns a release storage keeping the releases in memory, in
+// the "default" namespace.
+func NewStorage() *storage.Storage {
+	return storage.Init(driver.NewMemory())
+}
+
+// NewConfiguration returns an action configuration using a new release
+// storage kept in memory, a new KubeClient and the default capabilities,
+// logging to t.
+func NewConfiguration(t testing.TB) *action.Configuration {
+	return &action.Configuration{
+		Releases:     NewStorage(),
+		KubeClient:   NewKubeClient(),
+		Capabilities: chartutil.DefaultCapabilities,
+		Log:          t.Logf,
+	}
+}
+
+// SeedReleases stores the releases in the storage of the configuration,
+// failing t if one cannot be stored.
+func SeedReleases(t testing.TB, cfg *action.Configuration, rels ...*release.Release) {
+	t.Helper()
+	for _, rel := range rels {
+		if err := cfg.Releases.Create(rel); err != nil {
+			t.Fatalf("unable to store release %s revision %d: %s", rel.Name, rel.Version, err)
+		}
+	}
+}
+
+// Capabilities returns the capabilities of a cluster running the given
+// Kubernetes version, such as "v1.24.0", and supporting the default API
+// versions and the given ones. The default Kubernetes version is used if
+// kubeVersion is empty.
+func Capabilities(kubeVersion string, apiVersions ...string) (*chartutil.Capabilities, error) {
+	caps := chartutil.DefaultCapabilities.Copy()
+	if kubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		caps.KubeVersion = *kv
+	}
+	caps.APIVersions = append(append(chartutil.VersionSet{}, chartutil.DefaultVersionSet...), apiVersions...)
+	return caps, nil
+}
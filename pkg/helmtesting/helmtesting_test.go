@@ -0,0 +1,133 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmtesting
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+const configMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+data:
+  revision: "{{ .Release.Revision }}"
+`
+
+func testChart(templates ...*chart.File) *chart.Chart {
+	return &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: "v2", Name: "test", Version: "0.1.0"},
+		Templates: templates,
+	}
+}
+
+func newInstall(cfg *action.Configuration, name string) *action.Install {
+	install := action.NewInstall(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", "", 0, "", "", "", false)
+	install.ReleaseName = name
+	install.Namespace = "default"
+	return install
+}
+
+func TestInstallUpgradeUninstall(t *testing.T) {
+	cfg := NewConfiguration(t)
+	kc := cfg.KubeClient.(*KubeClient)
+	chrt := testChart(&chart.File{Name: "templates/configmap.yaml", Data: []byte(configMapTemplate)})
+
+	if _, err := newInstall(cfg, "demo").Run(chrt, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	cm, ok := kc.Get("v1", "ConfigMap", "default", "demo-config")
+	if !ok {
+		t.Fatal("expected the config map to be created")
+	}
+	if got := cm.GetAnnotations()["meta.helm.sh/release-name"]; got != "demo" {
+		t.Errorf("expected the config map to be owned by release demo, got %q", got)
+	}
+
+	upgrade := action.NewUpgrade(cfg, action.ChartPathOptions{}, "", 0, "", nil, "", "", "", 0, "", "", false, "")
+	upgrade.Namespace = "default"
+	if _, err := upgrade.Run("demo", chrt, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	cm, _ = kc.Get("v1", "ConfigMap", "default", "demo-config")
+	if got, _, _ := unstructured.NestedString(cm.Object, "data", "revision"); got != "2" {
+		t.Errorf("expected revision 2 in the config map, got %q", got)
+	}
+
+	if _, err := action.NewUninstall(cfg).Run("demo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kc.Get("v1", "ConfigMap", "default", "demo-config"); ok {
+		t.Error("expected the config map to be deleted")
+	}
+}
+
+func TestInstallExistingResource(t *testing.T) {
+	cfg := NewConfiguration(t)
+	kc := cfg.KubeClient.(*KubeClient)
+	chrt := testChart(&chart.File{Name: "templates/configmap.yaml", Data: []byte(configMapTemplate)})
+	resources, err := kc.Build(strings.NewReader(strings.Replace(configMapTemplate, "{{ .Release.Name }}", "demo", 1)), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kc.Add(resources[0].Object.(*unstructured.Unstructured))
+
+	if _, err := newInstall(cfg, "demo").Run(chrt, nil, ""); err == nil {
+		t.Error("expected the install to fail on the existing config map")
+	}
+}
+
+func TestSeedReleases(t *testing.T) {
+	cfg := NewConfiguration(t)
+	SeedReleases(t, cfg, &release.Release{
+		Name:      "demo",
+		Namespace: "default",
+		Version:   1,
+		Info:      &release.Info{Status: release.StatusDeployed},
+		Chart:     testChart(),
+	})
+	rel, err := action.NewGet(cfg).Run("demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version != 1 {
+		t.Errorf("expected revision 1, got %d", rel.Version)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	caps, err := Capabilities("v1.22.3", "example.com/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.KubeVersion.Version != "v1.22.3" {
+		t.Errorf("expected Kubernetes v1.22.3, got %s", caps.KubeVersion.Version)
+	}
+	if !caps.APIVersions.Has("example.com/v1") || !caps.APIVersions.Has("v1") {
+		t.Errorf("expected the default and given API versions, got %v", caps.APIVersions)
+	}
+	if _, err := Capabilities("not a version"); err == nil {
+		t.Error("expected an invalid version to fail")
+	}
+}
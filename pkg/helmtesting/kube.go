@@ -0,0 +1,450 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helmtesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
+
+	"github.com/open-hand/helm/pkg/kube"
+)
+
+// clusterScopedKinds are the kinds of the cluster-scoped resources.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// objectKey identifies an object regardless of the version of its API.
+type objectKey struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// KubeClient is a fake kube.Interface keeping the resources it applies in
+// memory, so that tests can check the state of the cluster after running
+// actions. The resources it builds get and change this state through their
+// REST clients too, as the actions checking the existing resources do.
+//
+// Its resources are ready as soon as they are applied, and its pods
+// succeed.
+type KubeClient struct {
+	// Namespace is the namespace of the namespaced resources built without
+	// one, "default" if empty.
+	Namespace string
+
+	mu              sync.Mutex
+	objects         map[objectKey]*unstructured.Unstructured
+	resourceVersion int
+}
+
+var _ kube.Interface = (*KubeClient)(nil)
+var _ kube.InterfaceExt = (*KubeClient)(nil)
+
+// NewKubeClient returns a KubeClient of an empty cluster.
+func NewKubeClient() *KubeClient {
+	return &KubeClient{objects: map[objectKey]*unstructured.Unstructured{}}
+}
+
+// Add adds objects to the cluster, such as the resources existing before a
+// release is installed.
+func (c *KubeClient) Add(objs ...*unstructured.Unstructured) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, obj := range objs {
+		c.store(obj.DeepCopy())
+	}
+}
+
+// Get returns a copy of the object of the cluster with the given API
+// version, kind, namespace and name. The namespace of cluster-scoped
+// objects is empty.
+func (c *KubeClient) Get(apiVersion, kind, namespace, name string) (*unstructured.Unstructured, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	obj, ok := c.objects[objectKey{gv.Group, kind, namespace, name}]
+	if !ok {
+		return nil, false
+	}
+	return obj.DeepCopy(), true
+}
+
+// Objects returns copies of the objects of the cluster, sorted by kind,
+// namespace and name.
+func (c *KubeClient) Objects() []*unstructured.Unstructured {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]objectKey, 0, len(c.objects))
+	for k := range c.objects {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		return a.name < b.name
+	})
+	objs := make([]*unstructured.Unstructured, len(keys))
+	for i, k := range keys {
+		objs[i] = c.objects[k].DeepCopy()
+	}
+	return objs
+}
+
+// Build builds the resources of the YAML documents of reader. It does not
+// validate them.
+func (c *KubeClient) Build(reader io.Reader, _ bool) (kube.ResourceList, error) {
+	var resources kube.ResourceList
+	decoder := yaml.NewYAMLOrJSONDecoder(reader, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				return resources, nil
+			}
+			return nil, errors.Wrap(err, "unable to decode the resources")
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if gvk.Kind == "" || gvk.Version == "" {
+			return nil, errors.Errorf("resource %q has no apiVersion or kind", obj.GetName())
+		}
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		mapping := &meta.RESTMapping{Resource: plural, GroupVersionKind: gvk, Scope: meta.RESTScopeNamespace}
+		if clusterScopedKinds[gvk.Kind] {
+			mapping.Scope = meta.RESTScopeRoot
+			obj.SetNamespace("")
+		} else if obj.GetNamespace() == "" {
+			obj.SetNamespace(c.namespace())
+		}
+		resources = append(resources, &resource.Info{
+			Client:    c.restClient(mapping),
+			Mapping:   mapping,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Object:    obj,
+		})
+	}
+}
+
+// Create creates the resources, failing if one already exists.
+func (c *KubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, info := range resources {
+		key := infoKey(info)
+		if _, ok := c.objects[key]; ok {
+			return nil, apierrors.NewAlreadyExists(info.Mapping.Resource.GroupResource(), info.Name)
+		}
+		c.store(toUnstructured(info.Object))
+	}
+	return &kube.Result{Created: resources}, nil
+}
+
+// Update replaces the resources of target, creating the missing ones, and
+// deletes the resources of original missing from target, unless their
+// resource policy keeps them.
+func (c *KubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &kube.Result{}
+	for _, info := range target {
+		if _, ok := c.objects[infoKey(info)]; ok {
+			res.Updated = append(res.Updated, info)
+		} else {
+			res.Created = append(res.Created, info)
+		}
+		c.store(toUnstructured(info.Object))
+	}
+	for _, info := range original.Difference(target) {
+		key := infoKey(info)
+		obj, ok := c.objects[key]
+		if !ok {
+			continue
+		}
+		if obj.GetAnnotations()[kube.ResourcePolicyAnno] == kube.KeepPolicy {
+			continue
+		}
+		delete(c.objects, key)
+		res.Deleted = append(res.Deleted, info)
+	}
+	return res, nil
+}
+
+// Delete deletes the resources, skipping the missing ones.
+func (c *KubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := &kube.Result{}
+	for _, info := range resources {
+		key := infoKey(info)
+		if _, ok := c.objects[key]; ok {
+			delete(c.objects, key)
+			res.Deleted = append(res.Deleted, info)
+		}
+	}
+	return res, nil
+}
+
+// Wait returns immediately, the resources are ready.
+func (c *KubeClient) Wait(kube.ResourceList, time.Duration) error {
+	return nil
+}
+
+// WaitWithJobs returns immediately, the resources are ready.
+func (c *KubeClient) WaitWithJobs(kube.ResourceList, time.Duration) error {
+	return nil
+}
+
+// WaitForDelete returns immediately, the resources are deleted at once.
+func (c *KubeClient) WaitForDelete(kube.ResourceList, time.Duration) error {
+	return nil
+}
+
+// WatchUntilReady returns immediately, the resources are ready.
+func (c *KubeClient) WatchUntilReady(kube.ResourceList, time.Duration) error {
+	return nil
+}
+
+// WaitAndGetCompletedPodPhase returns that the pod succeeded.
+func (c *KubeClient) WaitAndGetCompletedPodPhase(string, time.Duration) (v1.PodPhase, error) {
+	return v1.PodSucceeded, nil
+}
+
+// IsReachable returns nil, the cluster is always reachable.
+func (c *KubeClient) IsReachable() error {
+	return nil
+}
+
+func (c *KubeClient) namespace() string {
+	if c.Namespace == "" {
+		return "default"
+	}
+	return c.Namespace
+}
+
+// store stores obj, which must be owned by the client. It must be called
+// with the lock held.
+func (c *KubeClient) store(obj *unstructured.Unstructured) {
+	c.resourceVersion++
+	obj.SetResourceVersion(fmt.Sprint(c.resourceVersion))
+	c.objects[objectKey{obj.GroupVersionKind().Group, obj.GetKind(), obj.GetNamespace(), obj.GetName()}] = obj
+}
+
+func infoKey(info *resource.Info) objectKey {
+	gvk := info.Mapping.GroupVersionKind
+	return objectKey{gvk.Group, gvk.Kind, info.Namespace, info.Name}
+}
+
+// toUnstructured returns an unstructured copy of obj.
+func toUnstructured(obj runtime.Object) *unstructured.Unstructured {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.DeepCopy()
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		panic(err)
+	}
+	return &unstructured.Unstructured{Object: data}
+}
+
+// restClient returns a REST client serving the resources of the mapping
+// from the state of the cluster.
+func (c *KubeClient) restClient(mapping *meta.RESTMapping) resource.RESTClient {
+	return &fake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		GroupVersion:         mapping.GroupVersionKind.GroupVersion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return c.serve(mapping, req), nil
+		}),
+	}
+}
+
+// serve serves the request of a REST client of the mapping.
+func (c *KubeClient) serve(mapping *meta.RESTMapping, req *http.Request) *http.Response {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	namespace := ""
+	if len(parts) >= 2 && parts[0] == "namespaces" {
+		namespace, parts = parts[1], parts[2:]
+	}
+	if len(parts) < 2 || parts[0] != mapping.Resource.Resource {
+		return statusResponse(apierrors.NewBadRequest(fmt.Sprintf("unsupported request %s %s", req.Method, req.URL.Path)))
+	}
+	name := parts[1]
+	gr := mapping.Resource.GroupResource()
+	key := objectKey{mapping.GroupVersionKind.Group, mapping.GroupVersionKind.Kind, namespace, name}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	existing, exists := c.objects[key]
+	switch req.Method {
+	case http.MethodGet:
+		if !exists {
+			return statusResponse(apierrors.NewNotFound(gr, name))
+		}
+		return objectResponse(http.StatusOK, existing)
+	case http.MethodDelete:
+		if !exists {
+			return statusResponse(apierrors.NewNotFound(gr, name))
+		}
+		delete(c.objects, key)
+		return objectResponse(http.StatusOK, existing)
+	case http.MethodPost, http.MethodPut:
+		if req.Method == http.MethodPost && exists {
+			return statusResponse(apierrors.NewAlreadyExists(gr, name))
+		}
+		if req.Method == http.MethodPut && !exists {
+			return statusResponse(apierrors.NewNotFound(gr, name))
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(body); err != nil {
+			return statusResponse(apierrors.NewBadRequest(err.Error()))
+		}
+		obj.SetNamespace(namespace)
+		c.store(obj)
+		return objectResponse(http.StatusOK, obj)
+	case http.MethodPatch:
+		patched, err := c.patch(mapping, existing, types.PatchType(req.Header.Get("Content-Type")), body)
+		if err != nil {
+			return statusResponse(err)
+		}
+		patched.SetNamespace(namespace)
+		patched.SetName(name)
+		c.store(patched)
+		return objectResponse(http.StatusOK, patched)
+	}
+	return statusResponse(apierrors.NewMethodNotSupported(gr, req.Method))
+}
+
+// patch returns the existing object patched. Server-side applies create the
+// missing objects and replace the fields they set.
+func (c *KubeClient) patch(mapping *meta.RESTMapping, existing *unstructured.Unstructured, pt types.PatchType, patch []byte) (*unstructured.Unstructured, *apierrors.StatusError) {
+	current := []byte("{}")
+	if existing != nil {
+		var err error
+		if current, err = existing.MarshalJSON(); err != nil {
+			return nil, apierrors.NewInternalError(err)
+		}
+	} else if pt != types.ApplyPatchType {
+		return nil, apierrors.NewNotFound(mapping.Resource.GroupResource(), "")
+	}
+
+	var patched []byte
+	var err error
+	switch pt {
+	case types.JSONPatchType:
+		var p jsonpatch.Patch
+		if p, err = jsonpatch.DecodePatch(patch); err == nil {
+			patched, err = p.Apply(current)
+		}
+	case types.StrategicMergePatchType:
+		var versioned runtime.Object
+		if versioned, err = scheme.Scheme.New(mapping.GroupVersionKind); err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(current, patch, versioned)
+		}
+	case types.MergePatchType:
+		patched, err = jsonpatch.MergePatch(current, patch)
+	case types.ApplyPatchType:
+		if patch, err = yaml.ToJSON(patch); err == nil {
+			patched, err = jsonpatch.MergePatch(current, patch)
+		}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported patch type %q", pt))
+	}
+	if err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(patched); err != nil {
+		return nil, apierrors.NewBadRequest(err.Error())
+	}
+	return obj, nil
+}
+
+func objectResponse(code int, obj *unstructured.Unstructured) *http.Response {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return statusResponse(apierrors.NewInternalError(err))
+	}
+	return jsonResponse(code, data)
+}
+
+func statusResponse(err *apierrors.StatusError) *http.Response {
+	status := err.Status()
+	status.Kind, status.APIVersion = "Status", "v1"
+	data, _ := json.Marshal(&status)
+	return jsonResponse(int(status.Code), data)
+}
+
+func jsonResponse(code int, data []byte) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", runtime.ContentTypeJSON)
+	return &http.Response{StatusCode: code, Header: header, Body: ioutil.NopCloser(bytes.NewReader(data))}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Permission is a verb on a type of resource, in a namespace or, if the
+// namespace is empty, cluster-wide.
+type Permission struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group,omitempty"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GroupResource returns the resource qualified by its group, e.g.
+// "deployments.apps", or "secrets" for the core group.
+func (p Permission) GroupResource() string {
+	if p.Group == "" {
+		return p.Resource
+	}
+	return p.Resource + "." + p.Group
+}
+
+func (p Permission) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s on %s cluster-wide", p.Verb, p.GroupResource())
+	}
+	return fmt.Sprintf("%s on %s in namespace %q", p.Verb, p.GroupResource(), p.Namespace)
+}
+
+// ReviewAccess asks the API server, with SelfSubjectAccessReviews, whether
+// the identity of the client is granted each of perms, and returns the ones
+// it is denied.
+func (c *Client) ReviewAccess(perms []Permission) ([]Permission, error) {
+	client, err := c.Factory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	return reviewAccess(context.Background(), client, perms)
+}
+
+func reviewAccess(ctx context.Context, client kubernetes.Interface, perms []Permission) ([]Permission, error) {
+	var denied []Permission
+	for _, p := range perms {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: p.Namespace,
+					Verb:      p.Verb,
+					Group:     p.Group,
+					Resource:  p.Resource,
+				},
+			},
+		}
+		review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not review the access to %s", p)
+		}
+		if !review.Status.Allowed {
+			denied = append(denied, p)
+		}
+	}
+	return denied, nil
+}
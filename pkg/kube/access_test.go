@@ -0,0 +1,68 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReviewAccess(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	cs.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		// Only the namespace "apps" is writable.
+		review.Status.Allowed = attrs.Namespace == "apps" || attrs.Verb == "get"
+		return true, review, nil
+	})
+
+	perms := []Permission{
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "apps"},
+		{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "web"},
+		{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "web"},
+		{Verb: "create", Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"},
+	}
+	denied, err := reviewAccess(context.Background(), cs, perms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Permission{perms[2], perms[3]}; !reflect.DeepEqual(denied, want) {
+		t.Errorf("expected denied %v, got %v", want, denied)
+	}
+}
+
+func TestPermissionString(t *testing.T) {
+	for _, tt := range []struct {
+		perm Permission
+		want string
+	}{
+		{Permission{Verb: "create", Group: "apps", Resource: "deployments", Namespace: "web"}, `create on deployments.apps in namespace "web"`},
+		{Permission{Verb: "update", Resource: "secrets", Namespace: "web"}, `update on secrets in namespace "web"`},
+		{Permission{Verb: "create", Resource: "namespaces"}, "create on namespaces cluster-wide"},
+	} {
+		if got := tt.perm.String(); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ConflictResolution is how a conflict of a server-side apply with the
+// fields of other managers is resolved.
+type ConflictResolution string
+
+const (
+	// ConflictFail fails the apply of the resource.
+	ConflictFail ConflictResolution = "fail"
+	// ConflictForce applies the resource again, taking the ownership of the
+	// conflicting fields.
+	ConflictForce ConflictResolution = "force"
+	// ConflictSkip leaves the resource as is.
+	ConflictSkip ConflictResolution = "skip"
+)
+
+// ApplyOptions configure how resources are created and updated.
+type ApplyOptions struct {
+	// Concurrency is the maximum number of resources of a kind created or
+	// updated at the same time. When it is not set, the resources of a kind
+	// are all created at once, as Create does, and updated one at a time, as
+	// Update does.
+	Concurrency int
+	// Unchanged reports whether an existing resource is unchanged, in which
+	// case updates leave it as is instead of patching it. It is not used
+	// when updates are forced.
+	Unchanged func(info *resource.Info) bool
+	// ServerSide creates and updates the resources with server-side applies
+	// instead of creations and three-way merge patches. The resources are
+	// created even if they already exist. Forced updates still replace the
+	// resources.
+	ServerSide bool
+	// FieldManager is the name of the manager of the fields set by the
+	// creations and updates, ManagedFieldsManager or the name of the
+	// program if not set.
+	FieldManager string
+	// ForceConflicts takes the ownership of the fields of other managers
+	// conflicting with server-side applies.
+	ForceConflicts bool
+	// OnConflict is called when the server-side apply of a resource conflicts
+	// with the fields of other managers, unless ForceConflicts is set, and
+	// returns how the conflict is resolved. Conflicts fail the applies if it
+	// is not set.
+	OnConflict func(info *resource.Info, err error) ConflictResolution
+}
+
+// fieldManager returns the name of the manager of the fields set with the
+// options.
+func (o ApplyOptions) fieldManager() string {
+	if o.FieldManager != "" {
+		return o.FieldManager
+	}
+	return getManagedFieldsManager()
+}
+
+// applyResource applies the resource with a server-side apply, resolving the
+// conflicts as the options tell. It returns false if the resource was left
+// as is because of a conflict.
+func applyResource(c *Client, info *resource.Info, opts ApplyOptions) (bool, error) {
+	kind := info.Mapping.GroupVersionKind.Kind
+	data, err := json.Marshal(info.Object)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to encode %q with kind %s", info.Name, kind)
+	}
+	helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(opts.fieldManager())
+	force := opts.ForceConflicts
+	for {
+		obj, err := helper.Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{Force: &force})
+		if err == nil {
+			return true, info.Refresh(obj, true)
+		}
+		resolution := ConflictFail
+		if apierrors.IsConflict(err) && !force && opts.OnConflict != nil {
+			resolution = opts.OnConflict(info, err)
+		}
+		switch resolution {
+		case ConflictForce:
+			c.Log("Forcing the conflicting fields of %s %q", kind, info.Name)
+			force = true
+		case ConflictSkip:
+			c.Log("Skipping %s %q conflicting with other field managers: %s", kind, info.Name, err)
+			return false, nil
+		default:
+			return false, errors.Wrapf(err, "cannot apply %q with kind %s", info.Name, kind)
+		}
+	}
+}
+
+// applyBatches calls fn on every resource, batching the consecutive
+// resources of a kind: the resources of a batch are handled at most
+// concurrency at a time, all at once if concurrency is not set, and the next
+// batch starts once they are all done, so that the order of the kinds is
+// kept. Once a resource fails, no other resource is started: the resources
+// being handled are waited for, and the errors of all the failed ones are
+// returned.
+func applyBatches(infos ResourceList, concurrency int, fn func(int, *resource.Info) error) error {
+	for start := 0; start < len(infos); {
+		kind := infos[start].Object.GetObjectKind().GroupVersionKind().Kind
+		end := start + 1
+		for end < len(infos) && infos[end].Object.GetObjectKind().GroupVersionKind().Kind == kind {
+			end++
+		}
+		limit := concurrency
+		if limit < 1 || limit > end-start {
+			limit = end - start
+		}
+
+		errs := make([]error, end-start)
+		slots := make(chan struct{}, limit)
+		var mu sync.Mutex
+		failed := false
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			slots <- struct{}{}
+			mu.Lock()
+			stop := failed
+			mu.Unlock()
+			if stop {
+				break
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				if err := fn(i, infos[i]); err != nil {
+					mu.Lock()
+					errs[i-start], failed = err, true
+					mu.Unlock()
+				}
+			}(i)
+		}
+		wg.Wait()
+		if err := joinErrors(errs); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// joinErrors returns an error reporting all the errors, the error itself if
+// there is only one, or nil if there are none.
+func joinErrors(errs []error) error {
+	var first error
+	var msgs []string
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		msgs = append(msgs, err.Error())
+	}
+	switch len(msgs) {
+	case 0:
+		return nil
+	case 1:
+		return first
+	}
+	return errors.Errorf("%d resources failed: %s", len(msgs), strings.Join(msgs, " && "))
+}
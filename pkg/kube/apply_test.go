@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func kindInfos(kinds ...string) ResourceList {
+	var infos ResourceList
+	for i, kind := range kinds {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(fmt.Sprintf("%s-%d", strings.ToLower(kind), i))
+		infos = append(infos, &resource.Info{Name: obj.GetName(), Object: obj})
+	}
+	return infos
+}
+
+func TestApplyBatches(t *testing.T) {
+	infos := kindInfos("ConfigMap", "ConfigMap", "ConfigMap", "ConfigMap", "Service", "ConfigMap")
+
+	var mu sync.Mutex
+	var done []string
+	running, maxRunning := map[string]int{}, map[string]int{}
+	err := applyBatches(infos, 2, func(i int, info *resource.Info) error {
+		kind := info.Object.GetObjectKind().GroupVersionKind().Kind
+		mu.Lock()
+		for k, n := range running {
+			if k != kind && n > 0 {
+				t.Errorf("%s applied while %d %s are applied", info.Name, n, k)
+			}
+		}
+		running[kind]++
+		if running[kind] > maxRunning[kind] {
+			maxRunning[kind] = running[kind]
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running[kind]--
+		done = append(done, info.Name)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(infos) {
+		t.Fatalf("expected %d resources applied, got %v", len(infos), done)
+	}
+	if maxRunning["ConfigMap"] != 2 {
+		t.Errorf("expected 2 config maps applied at the same time, got %d", maxRunning["ConfigMap"])
+	}
+	if done[4] != "service-4" || done[5] != "configmap-5" {
+		t.Errorf("expected the kinds to be applied in order, got %v", done)
+	}
+}
+
+func TestApplyBatchesErrors(t *testing.T) {
+	infos := kindInfos("ConfigMap", "ConfigMap", "ConfigMap", "Service")
+
+	var mu sync.Mutex
+	var applied []string
+	err := applyBatches(infos, 0, func(i int, info *resource.Info) error {
+		mu.Lock()
+		applied = append(applied, info.Name)
+		mu.Unlock()
+		if i == 1 || i == 2 {
+			return errors.Errorf("%s failed", info.Name)
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	const expected = "2 resources failed: configmap-1 failed && configmap-2 failed"
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err)
+	}
+	for _, name := range applied {
+		if name == "service-3" {
+			t.Error("expected the next kinds not to be applied after a failure")
+		}
+	}
+}
+
+func TestApplyBatchesStopsAfterFailure(t *testing.T) {
+	infos := kindInfos("ConfigMap", "ConfigMap", "ConfigMap")
+
+	var applied []string
+	err := applyBatches(infos, 1, func(i int, info *resource.Info) error {
+		applied = append(applied, info.Name)
+		if i == 1 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected the error of the failed resource, got %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("expected no resource to be started after the failure, got %v", applied)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// execAPIVersion is the API version of the credential plugins run for
+// AuthOptions.ExecProvider if it sets none.
+const execAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+// AuthOptions are the authentication and impersonation settings of the
+// requests to the Kubernetes API, overriding the ones of the kubeconfig. They
+// let a central deploy service act on behalf of the user requesting an
+// operation, and rely on Kubernetes RBAC for authorization.
+type AuthOptions struct {
+	// Impersonate is the user to impersonate.
+	Impersonate string
+	// ImpersonateGroups are the groups to impersonate.
+	ImpersonateGroups []string
+	// BearerToken replaces the credentials of the kubeconfig.
+	BearerToken string
+	// ExecProvider is a credential plugin that replaces the credentials of
+	// the kubeconfig, e.g. to get a token for the requesting user.
+	ExecProvider *clientcmdapi.ExecConfig
+}
+
+// IsZero returns true if the options change nothing.
+func (o AuthOptions) IsZero() bool {
+	return o.Impersonate == "" && len(o.ImpersonateGroups) == 0 && o.BearerToken == "" && o.ExecProvider == nil
+}
+
+// Override returns the options with the non-empty settings of other.
+func (o AuthOptions) Override(other AuthOptions) AuthOptions {
+	if other.Impersonate != "" {
+		o.Impersonate = other.Impersonate
+	}
+	if len(other.ImpersonateGroups) > 0 {
+		o.ImpersonateGroups = other.ImpersonateGroups
+	}
+	if other.BearerToken != "" {
+		o.BearerToken = other.BearerToken
+	}
+	if other.ExecProvider != nil {
+		o.ExecProvider = other.ExecProvider
+	}
+	return o
+}
+
+// WrapConfig returns a copy of the client configuration c applying the
+// options.
+func (o AuthOptions) WrapConfig(c *rest.Config) *rest.Config {
+	c = rest.CopyConfig(c)
+	if o.BearerToken != "" || o.ExecProvider != nil {
+		// Drop the credentials of the kubeconfig
+		c.Username, c.Password = "", ""
+		c.BearerToken, c.BearerTokenFile = "", ""
+		c.CertFile, c.CertData = "", nil
+		c.KeyFile, c.KeyData = "", nil
+		c.AuthProvider, c.AuthConfigPersister = nil, nil
+		c.ExecProvider = nil
+	}
+	if o.BearerToken != "" {
+		c.BearerToken = o.BearerToken
+	}
+	if o.ExecProvider != nil {
+		exec := *o.ExecProvider
+		if exec.APIVersion == "" {
+			exec.APIVersion = execAPIVersion
+		}
+		if exec.InteractiveMode == "" {
+			exec.InteractiveMode = clientcmdapi.NeverExecInteractiveMode
+		}
+		c.ExecProvider = &exec
+	}
+	if o.Impersonate != "" || len(o.ImpersonateGroups) > 0 {
+		c.Impersonate = rest.ImpersonationConfig{
+			UserName: o.Impersonate,
+			Groups:   o.ImpersonateGroups,
+		}
+	}
+	return c
+}
+
+// WithAuth returns a RESTClientGetter applying the options to the clients of
+// getter. It has its own discovery cache, since the resources a user can
+// discover depend on its permissions.
+func WithAuth(getter genericclioptions.RESTClientGetter, opts AuthOptions) genericclioptions.RESTClientGetter {
+	if opts.IsZero() {
+		return getter
+	}
+	return &wrapGetter{RESTClientGetter: getter, wrap: opts.WrapConfig}
+}
+
+// wrapGetter is a RESTClientGetter changing the client configurations of
+// another one. The discovery client and the RESTMapper are built from the
+// changed configuration.
+type wrapGetter struct {
+	genericclioptions.RESTClientGetter
+	wrap func(*rest.Config) *rest.Config
+
+	mu        sync.Mutex
+	discovery discovery.CachedDiscoveryInterface
+}
+
+func (g *wrapGetter) ToRESTConfig() (*rest.Config, error) {
+	c, err := g.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	return g.wrap(c), nil
+}
+
+func (g *wrapGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.discovery != nil {
+		return g.discovery, nil
+	}
+	c, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	g.discovery = memory.NewMemCacheClient(dc)
+	return g.discovery, nil
+}
+
+func (g *wrapGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(dc)
+	return restmapper.NewShortcutExpander(mapper, dc), nil
+}
+
+// WithAuth returns a copy of the client applying the options to its
+// requests, e.g. to impersonate the user requesting an operation.
+func (c *Client) WithAuth(opts AuthOptions) (*Client, error) {
+	getter, ok := c.Factory.(genericclioptions.RESTClientGetter)
+	if !ok {
+		return nil, errors.New("the client factory does not support authentication options")
+	}
+	client := New(WithAuth(getter, opts))
+	client.Log = c.Log
+	client.Namespace = c.Namespace
+	return client, nil
+}
+
+// UserName returns the name of the user the clients of getter act as: the
+// impersonated user, or else the user of the kubeconfig context, taking the
+// --kube-context and --user overrides of the getter into account. It returns
+// an empty string if the user cannot be determined.
+func UserName(getter genericclioptions.RESTClientGetter) string {
+	if c, err := getter.ToRESTConfig(); err == nil {
+		if c.Impersonate.UserName != "" {
+			return c.Impersonate.UserName
+		}
+		if c.Username != "" {
+			return c.Username
+		}
+	}
+	raw, err := getter.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	contextName := raw.CurrentContext
+	if flags := configFlags(getter); flags != nil {
+		if flags.AuthInfoName != nil && *flags.AuthInfoName != "" {
+			return *flags.AuthInfoName
+		}
+		if flags.Context != nil && *flags.Context != "" {
+			contextName = *flags.Context
+		}
+	}
+	if ctx, ok := raw.Contexts[contextName]; ok {
+		return ctx.AuthInfo
+	}
+	return ""
+}
+
+// configFlags returns the kubeconfig flags getter wraps, or nil if it does
+// not wrap any.
+func configFlags(getter genericclioptions.RESTClientGetter) *genericclioptions.ConfigFlags {
+	for {
+		switch g := getter.(type) {
+		case *genericclioptions.ConfigFlags:
+			return g
+		case *wrapGetter:
+			getter = g.RESTClientGetter
+		case *discoveryCacheGetter:
+			getter = g.RESTClientGetter
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestAuthOptionsWrapConfig(t *testing.T) {
+	base := &rest.Config{
+		Host:     "https://example.com",
+		Username: "admin",
+		Password: "secret",
+		TLSClientConfig: rest.TLSClientConfig{
+			CertData: []byte("cert"),
+			KeyData:  []byte("key"),
+			CAData:   []byte("ca"),
+		},
+	}
+
+	t.Run("bearer token", func(t *testing.T) {
+		c := AuthOptions{BearerToken: "token"}.WrapConfig(base)
+		if c.BearerToken != "token" {
+			t.Errorf("expected bearer token %q, got %q", "token", c.BearerToken)
+		}
+		if c.Username != "" || c.Password != "" || c.CertData != nil || c.KeyData != nil {
+			t.Errorf("expected the kubeconfig credentials to be dropped, got %+v", c)
+		}
+		if string(c.CAData) != "ca" || c.Host != base.Host {
+			t.Errorf("expected the connection settings to be kept, got %+v", c)
+		}
+		if base.Username != "admin" || base.BearerToken != "" {
+			t.Error("expected the base configuration to be unchanged")
+		}
+	})
+
+	t.Run("exec provider", func(t *testing.T) {
+		c := AuthOptions{ExecProvider: &clientcmdapi.ExecConfig{Command: "get-token"}}.WrapConfig(base)
+		if c.ExecProvider == nil {
+			t.Fatal("expected an exec provider")
+		}
+		if c.ExecProvider.APIVersion != execAPIVersion {
+			t.Errorf("expected API version %q, got %q", execAPIVersion, c.ExecProvider.APIVersion)
+		}
+		if c.ExecProvider.InteractiveMode != clientcmdapi.NeverExecInteractiveMode {
+			t.Errorf("expected interactive mode %q, got %q", clientcmdapi.NeverExecInteractiveMode, c.ExecProvider.InteractiveMode)
+		}
+		if c.Username != "" || c.CertData != nil {
+			t.Errorf("expected the kubeconfig credentials to be dropped, got %+v", c)
+		}
+	})
+
+	t.Run("impersonation", func(t *testing.T) {
+		c := AuthOptions{Impersonate: "jane", ImpersonateGroups: []string{"devs"}}.WrapConfig(base)
+		want := rest.ImpersonationConfig{UserName: "jane", Groups: []string{"devs"}}
+		if !reflect.DeepEqual(c.Impersonate, want) {
+			t.Errorf("expected impersonation %+v, got %+v", want, c.Impersonate)
+		}
+		if c.Username != "admin" {
+			t.Error("expected the kubeconfig credentials to be kept")
+		}
+	})
+}
+
+func TestAuthOptionsOverride(t *testing.T) {
+	o := AuthOptions{Impersonate: "jane", BearerToken: "token"}
+	got := o.Override(AuthOptions{Impersonate: "joe", ImpersonateGroups: []string{"devs"}})
+	want := AuthOptions{Impersonate: "joe", ImpersonateGroups: []string{"devs"}, BearerToken: "token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if !(AuthOptions{}).IsZero() || want.IsZero() {
+		t.Error("unexpected IsZero result")
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	host := "https://example.com"
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.APIServer = &host
+
+	if got := WithAuth(flags, AuthOptions{}); got != genericclioptions.RESTClientGetter(flags) {
+		t.Error("expected the getter to be returned as is without options")
+	}
+
+	getter := WithAuth(flags, AuthOptions{Impersonate: "jane"})
+	c, err := getter.ToRESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Impersonate.UserName != "jane" {
+		t.Errorf("expected to impersonate %q, got %q", "jane", c.Impersonate.UserName)
+	}
+	if c.Host != host {
+		t.Errorf("expected host %q, got %q", host, c.Host)
+	}
+}
+
+func TestUserName(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: cluster
+  cluster:
+    server: https://example.com
+contexts:
+- name: dev
+  context:
+    cluster: cluster
+    user: dev-user
+- name: prod
+  context:
+    cluster: cluster
+    user: prod-user
+users:
+- name: dev-user
+  user:
+    token: dev
+- name: prod-user
+  user:
+    token: prod
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		context, user, impersonate string
+		expect                     string
+	}{
+		{"", "", "", "dev-user"},
+		{"prod", "", "", "prod-user"},
+		{"prod", "dev-user", "", "dev-user"},
+		{"prod", "", "jane", "jane"},
+	} {
+		flags := genericclioptions.NewConfigFlags(false)
+		flags.KubeConfig = &kubeconfig
+		flags.Context = &tt.context
+		flags.AuthInfoName = &tt.user
+		flags.Impersonate = &tt.impersonate
+		if got := UserName(WithReadOnly(flags)); got != tt.expect {
+			t.Errorf("context %q, user %q, impersonate %q: expected %q, got %q", tt.context, tt.user, tt.impersonate, tt.expect, got)
+		}
+	}
+}
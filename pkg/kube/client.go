@@ -66,6 +66,12 @@ type Client struct {
 	Log     func(string, ...interface{})
 	// Namespace allows to bypass the kubeconfig file for the choice of the namespace
 	Namespace string
+	// WaitIgnoreKinds are the kinds of the custom resources considered ready
+	// regardless of their status when waiting, as "Kind" or "Kind.group".
+	WaitIgnoreKinds []string
+	// WaitStrategy is how the resources are waited for, WaitStrategyWatch
+	// when empty.
+	WaitStrategy WaitStrategy
 
 	kubeClient *kubernetes.Clientset
 }
@@ -124,8 +130,24 @@ func (c *Client) IsReachable() error {
 
 // Create creates Kubernetes resources specified in the resource list.
 func (c *Client) Create(resources ResourceList) (*Result, error) {
+	return c.CreateWithOptions(resources, ApplyOptions{})
+}
+
+// CreateWithOptions creates the resources like Create, with the given
+// options. The resources of a kind are created concurrently, and the kinds
+// one after the other.
+func (c *Client) CreateWithOptions(resources ResourceList, opts ApplyOptions) (*Result, error) {
 	c.Log("creating %d resource(s)", len(resources))
-	if err := perform(resources, createResource); err != nil {
+	if len(resources) == 0 {
+		return nil, ErrNoObjectsVisited
+	}
+	if err := applyBatches(resources, opts.Concurrency, func(_ int, info *resource.Info) error {
+		if opts.ServerSide {
+			_, err := applyResource(c, info, opts)
+			return err
+		}
+		return createResource(info, opts.fieldManager())
+	}); err != nil {
 		return nil, err
 	}
 	return &Result{Created: resources}, nil
@@ -133,32 +155,45 @@ func (c *Client) Create(resources ResourceList) (*Result, error) {
 
 // Wait waits up to the given timeout for the specified resources to be ready.
 func (c *Client) Wait(resources ResourceList, timeout time.Duration) error {
-	cs, err := c.getKubeClient()
-	if err != nil {
-		return err
-	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true))
-	w := waiter{
-		c:       checker,
-		log:     c.Log,
-		timeout: timeout,
-	}
-	return w.waitForResources(resources)
+	_, err := c.WaitWithStrategy(resources, timeout, "", false)
+	return err
 }
 
 // WaitWithJobs wait up to the given timeout for the specified resources to be ready, including jobs.
 func (c *Client) WaitWithJobs(resources ResourceList, timeout time.Duration) error {
+	_, err := c.WaitWithStrategy(resources, timeout, "", true)
+	return err
+}
+
+// WaitWithStrategy waits up to the given timeout for the specified resources
+// to be ready, including jobs if jobs is set, with the given strategy or
+// otherwise the WaitStrategy of the client. It returns how long each
+// resource took to be ready.
+func (c *Client) WaitWithStrategy(resources ResourceList, timeout time.Duration, strategy WaitStrategy, jobs bool) ([]WaitTiming, error) {
 	cs, err := c.getKubeClient()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	opts := []ReadyCheckerOption{PausedAsReady(true), IgnoreCustomResourceStatus(c.WaitIgnoreKinds...)}
+	if jobs {
+		opts = append(opts, CheckJobs(true))
 	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true), CheckJobs(true))
 	w := waiter{
-		c:       checker,
+		c:       NewReadyChecker(cs, c.Log, opts...),
 		log:     c.Log,
 		timeout: timeout,
 	}
-	return w.waitForResources(resources)
+	if strategy == "" {
+		strategy = c.WaitStrategy
+	}
+	if strategy == WaitStrategyPoll {
+		return w.waitForResources(resources)
+	}
+	if w.dynamic, err = c.Factory.DynamicClient(); err != nil {
+		c.Log("unable to watch the resources: %v, falling back to polling", err)
+		return w.waitForResources(resources)
+	}
+	return w.watchForResources(resources)
 }
 
 // WaitForDelete wait up to the given timeout for the specified resources to be deleted.
@@ -222,15 +257,28 @@ func (c *Client) Build(reader io.Reader, validate bool) (ResourceList, error) {
 // resource updates, creations, and deletions that were attempted. These can be
 // used for cleanup or other logging purposes.
 func (c *Client) Update(original, target ResourceList, force bool) (*Result, error) {
-	updateErrors := []string{}
-	res := &Result{}
+	return c.UpdateWithOptions(original, target, force, ApplyOptions{})
+}
 
-	c.Log("checking %d resources for changes", len(target))
-	err := target.Visit(func(info *resource.Info, err error) error {
-		if err != nil {
-			return err
-		}
+// UpdateWithOptions updates the resources like Update, with the given
+// options. The resources of a kind are updated concurrently, and the kinds
+// one after the other.
+func (c *Client) UpdateWithOptions(original, target ResourceList, force bool, opts ApplyOptions) (*Result, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	const (
+		skipped = iota
+		created
+		updated
+		unchanged
+	)
+	outcomes := make([]int, len(target))
+	updateErrors := make([]error, len(target))
 
+	c.Log("checking %d resources for changes", len(target))
+	err := applyBatches(target, concurrency, func(i int, info *resource.Info) error {
 		helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager())
 		if _, err := helper.Get(info.Namespace, info.Name); err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -238,10 +286,15 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			}
 
 			// Append the created resource to the results, even if something fails
-			res.Created = append(res.Created, info)
+			outcomes[i] = created
 
 			// Since the resource does not exist, create it.
-			if err := createResource(info); err != nil {
+			if opts.ServerSide {
+				_, err = applyResource(c, info, opts)
+			} else {
+				err = createResource(info, opts.fieldManager())
+			}
+			if err != nil {
 				return errors.Wrap(err, "failed to create resource")
 			}
 
@@ -256,21 +309,52 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			return errors.Errorf("no %s with the name %q found", kind, info.Name)
 		}
 
-		if err := updateResource(c, info, originalInfo.Object, force); err != nil {
+		if !force && opts.Unchanged != nil && opts.Unchanged(info) {
+			c.Log("Skipping the unchanged resource %q", info.Name)
+			outcomes[i] = unchanged
+			return nil
+		}
+
+		if opts.ServerSide && !force {
+			applied, err := applyResource(c, info, opts)
+			switch {
+			case err != nil:
+				c.Log("error updating the resource %q:\n\t %v", info.Name, err)
+				updateErrors[i] = err
+			case !applied:
+				outcomes[i] = unchanged
+				return nil
+			}
+		} else if err := updateResource(c, info, originalInfo.Object, force, opts.fieldManager()); err != nil {
 			c.Log("error updating the resource %q:\n\t %v", info.Name, err)
-			updateErrors = append(updateErrors, err.Error())
+			updateErrors[i] = err
 		}
 		// Because we check for errors later, append the info regardless
-		res.Updated = append(res.Updated, info)
+		outcomes[i] = updated
 
 		return nil
 	})
 
+	res := &Result{}
+	var msgs []string
+	for i, info := range target {
+		switch outcomes[i] {
+		case created:
+			res.Created = append(res.Created, info)
+		case updated:
+			res.Updated = append(res.Updated, info)
+		case unchanged:
+			res.Unchanged = append(res.Unchanged, info)
+		}
+		if updateErrors[i] != nil {
+			msgs = append(msgs, updateErrors[i].Error())
+		}
+	}
 	switch {
 	case err != nil:
 		return res, err
-	case len(updateErrors) != 0:
-		return res, errors.Errorf(strings.Join(updateErrors, " && "))
+	case len(msgs) != 0:
+		return res, errors.Errorf(strings.Join(msgs, " && "))
 	}
 
 	for _, info := range original.Difference(target) {
@@ -372,7 +456,7 @@ func perform(infos ResourceList, fn func(*resource.Info) error) error {
 		return ErrNoObjectsVisited
 	}
 
-	errs := make(chan error)
+	errs := make(chan error, len(infos))
 	go batchPerform(infos, fn, errs)
 
 	for range infos {
@@ -421,8 +505,8 @@ func batchPerform(infos ResourceList, fn func(*resource.Info) error, errs chan<-
 	}
 }
 
-func createResource(info *resource.Info) error {
-	obj, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()).Create(info.Namespace, true, info.Object)
+func createResource(info *resource.Info, fieldManager string) error {
+	obj, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(fieldManager).Create(info.Namespace, true, info.Object)
 	if err != nil {
 		return err
 	}
@@ -486,10 +570,10 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 	return patch, types.StrategicMergePatchType, err
 }
 
-func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool) error {
+func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool, fieldManager string) error {
 	var (
 		obj    runtime.Object
-		helper = resource.NewHelper(target.Client, target.Mapping).WithFieldManager(getManagedFieldsManager())
+		helper = resource.NewHelper(target.Client, target.Mapping).WithFieldManager(fieldManager)
 		kind   = target.Mapping.GroupVersionKind.Kind
 	)
 
@@ -27,6 +27,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest/fake"
@@ -214,6 +215,160 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateUnchanged(t *testing.T) {
+	listA := newPodList("starfish", "otter")
+	listB := newPodList("starfish", "otter")
+	listB.Items[0].Spec.Containers[0].Ports = []v1.ContainerPort{{Name: "https", ContainerPort: 443}}
+
+	var actions []string
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			actions = append(actions, p+":"+m)
+			switch {
+			case p == "/namespaces/default/pods/starfish" && (m == "GET" || m == "PATCH"):
+				return newResponse(200, &listB.Items[0])
+			case p == "/namespaces/default/pods/otter" && m == "GET":
+				return newResponse(200, &listA.Items[1])
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UpdateWithOptions(first, second, false, ApplyOptions{
+		Unchanged: func(info *resource.Info) bool { return info.Name == "otter" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Updated) != 1 || result.Updated[0].Name != "starfish" {
+		t.Errorf("expected starfish to be updated, got %v", result.Updated)
+	}
+	if len(result.Unchanged) != 1 || result.Unchanged[0].Name != "otter" {
+		t.Errorf("expected otter to be unchanged, got %v", result.Unchanged)
+	}
+
+	expectedActions := []string{
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:PATCH",
+		"/namespaces/default/pods/otter:GET",
+	}
+	if strings.Join(actions, " ") != strings.Join(expectedActions, " ") {
+		t.Errorf("expected requests %v, got %v", expectedActions, actions)
+	}
+}
+
+func TestUpdateServerSide(t *testing.T) {
+	listA := newPodList("starfish", "otter")
+	listB := newPodList("starfish", "otter", "dolphin")
+
+	var actions []string
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			if m == "PATCH" {
+				if ct := req.Header.Get("Content-Type"); ct != string(types.ApplyPatchType) {
+					t.Errorf("expected an apply patch, got %s", ct)
+				}
+				if fm := req.URL.Query().Get("fieldManager"); fm != "controller" {
+					t.Errorf("expected the field manager controller, got %q", fm)
+				}
+				m += "?force=" + req.URL.Query().Get("force")
+			}
+			actions = append(actions, p+":"+m)
+			conflict := &metav1.Status{
+				Code:   http.StatusConflict,
+				Status: metav1.StatusFailure,
+				Reason: metav1.StatusReasonConflict,
+			}
+			switch {
+			case p == "/namespaces/default/pods/starfish" && m == "GET":
+				return newResponse(200, &listA.Items[0])
+			case p == "/namespaces/default/pods/starfish" && m == "PATCH?force=false":
+				return newResponse(409, conflict)
+			case p == "/namespaces/default/pods/starfish" && m == "PATCH?force=true":
+				return newResponse(200, &listB.Items[0])
+			case p == "/namespaces/default/pods/otter" && m == "GET":
+				return newResponse(200, &listA.Items[1])
+			case p == "/namespaces/default/pods/otter" && m == "PATCH?force=false":
+				return newResponse(409, conflict)
+			case p == "/namespaces/default/pods/dolphin" && m == "GET":
+				return newResponse(404, notFoundBody())
+			case p == "/namespaces/default/pods/dolphin" && m == "PATCH?force=false":
+				return newResponse(200, &listB.Items[2])
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UpdateWithOptions(first, second, false, ApplyOptions{
+		ServerSide:   true,
+		FieldManager: "controller",
+		OnConflict: func(info *resource.Info, err error) ConflictResolution {
+			if info.Name == "starfish" {
+				return ConflictForce
+			}
+			return ConflictSkip
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Created) != 1 || result.Created[0].Name != "dolphin" {
+		t.Errorf("expected dolphin to be created, got %v", result.Created)
+	}
+	if len(result.Updated) != 1 || result.Updated[0].Name != "starfish" {
+		t.Errorf("expected starfish to be updated, got %v", result.Updated)
+	}
+	if len(result.Unchanged) != 1 || result.Unchanged[0].Name != "otter" {
+		t.Errorf("expected otter to be left as is, got %v", result.Unchanged)
+	}
+
+	expectedActions := []string{
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:PATCH?force=false",
+		"/namespaces/default/pods/starfish:PATCH?force=true",
+		"/namespaces/default/pods/otter:GET",
+		"/namespaces/default/pods/otter:PATCH?force=false",
+		"/namespaces/default/pods/dolphin:GET",
+		"/namespaces/default/pods/dolphin:PATCH?force=false",
+	}
+	if strings.Join(actions, " ") != strings.Join(expectedActions, " ") {
+		t.Errorf("expected requests %v, got %v", expectedActions, actions)
+	}
+
+	if _, err := c.UpdateWithOptions(first, first, false, ApplyOptions{ServerSide: true, FieldManager: "controller"}); err == nil {
+		t.Error("expected the conflicts to fail the update without OnConflict")
+	}
+}
+
 func TestBuild(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,271 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// DefaultDiscoveryCacheTTL is how long a DiscoveryCache keeps the discovery
+// information of a cluster if its TTL is not set.
+const DefaultDiscoveryCacheTTL = 10 * time.Minute
+
+// DiscoveryCache shares the discovery information and the OpenAPI schema of
+// clusters among the clients of a process, so that the actions run against a
+// cluster do not each repeat the discovery of its APIs, which is slow on
+// clusters with many CRDs.
+//
+// The information is cached per cluster and identity, since the resources a
+// user can discover depend on its permissions. It expires after the TTL, and
+// is invalidated when an action installs CRDs.
+type DiscoveryCache struct {
+	// TTL is how long the information of a cluster is kept.
+	// DefaultDiscoveryCacheTTL applies if it is zero.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*cachedDiscovery
+}
+
+// NewDiscoveryCache returns a cache keeping the discovery information for
+// ttl.
+func NewDiscoveryCache(ttl time.Duration) *DiscoveryCache {
+	return &DiscoveryCache{TTL: ttl}
+}
+
+// Client returns the discovery client of the cluster the configuration
+// connects to. The clients of the same cluster and identity share their
+// cached information.
+func (d *DiscoveryCache) Client(c *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := clusterIdentity(c)
+	if client, ok := d.clients[key]; ok {
+		return client, nil
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	ttl := d.TTL
+	if ttl == 0 {
+		ttl = DefaultDiscoveryCacheTTL
+	}
+	client := newCachedDiscovery(memory.NewMemCacheClient(dc), ttl)
+	if d.clients == nil {
+		d.clients = make(map[string]*cachedDiscovery)
+	}
+	d.clients[key] = client
+	return client, nil
+}
+
+// Invalidate drops the information cached for the cluster the configuration
+// connects to.
+func (d *DiscoveryCache) Invalidate(c *rest.Config) {
+	d.mu.Lock()
+	client, ok := d.clients[clusterIdentity(c)]
+	d.mu.Unlock()
+	if ok {
+		client.Invalidate()
+	}
+}
+
+// InvalidateAll drops the information cached for all clusters.
+func (d *DiscoveryCache) InvalidateAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clients = nil
+}
+
+// clusterIdentity identifies the cluster a configuration connects to and the
+// identity it connects with.
+func clusterIdentity(c *rest.Config) string {
+	id := struct {
+		Host, APIPath                    string
+		Username, BearerToken, TokenFile string
+		CertFile                         string
+		CertData                         []byte
+		Impersonate                      rest.ImpersonationConfig
+		Exec                             interface{}
+		AuthProvider                     interface{}
+	}{
+		Host:         c.Host,
+		APIPath:      c.APIPath,
+		Username:     c.Username,
+		BearerToken:  c.BearerToken,
+		TokenFile:    c.BearerTokenFile,
+		CertFile:     c.CertFile,
+		CertData:     c.CertData,
+		Impersonate:  c.Impersonate,
+		Exec:         c.ExecProvider,
+		AuthProvider: c.AuthProvider,
+	}
+	b, _ := json.Marshal(id)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedDiscovery expires the information of a cached discovery client after
+// a TTL, and caches the server version and the OpenAPI schema along with it.
+type cachedDiscovery struct {
+	discovery.CachedDiscoveryInterface
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	expires time.Time
+	version *version.Info
+	openAPI *openapi_v2.Document
+}
+
+func newCachedDiscovery(dc discovery.CachedDiscoveryInterface, ttl time.Duration) *cachedDiscovery {
+	return &cachedDiscovery{CachedDiscoveryInterface: dc, ttl: ttl, now: time.Now}
+}
+
+// expire invalidates the information once the TTL has elapsed.
+func (d *cachedDiscovery) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	if d.expires.IsZero() {
+		d.expires = now.Add(d.ttl)
+		return
+	}
+	if now.Before(d.expires) {
+		return
+	}
+	d.invalidateLocked(now)
+}
+
+func (d *cachedDiscovery) invalidateLocked(now time.Time) {
+	d.CachedDiscoveryInterface.Invalidate()
+	d.version = nil
+	d.openAPI = nil
+	d.expires = now.Add(d.ttl)
+}
+
+func (d *cachedDiscovery) Invalidate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.invalidateLocked(d.now())
+}
+
+func (d *cachedDiscovery) Fresh() bool {
+	d.expire()
+	return d.CachedDiscoveryInterface.Fresh()
+}
+
+func (d *cachedDiscovery) ServerGroups() (*metav1.APIGroupList, error) {
+	d.expire()
+	return d.CachedDiscoveryInterface.ServerGroups()
+}
+
+func (d *cachedDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	d.expire()
+	return d.CachedDiscoveryInterface.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (d *cachedDiscovery) ServerGroupsAndResources() ([]*metav1.APIGroup, []*metav1.APIResourceList, error) {
+	d.expire()
+	return d.CachedDiscoveryInterface.ServerGroupsAndResources()
+}
+
+func (d *cachedDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	d.expire()
+	return d.CachedDiscoveryInterface.ServerPreferredResources()
+}
+
+func (d *cachedDiscovery) ServerPreferredNamespacedResources() ([]*metav1.APIResourceList, error) {
+	d.expire()
+	return d.CachedDiscoveryInterface.ServerPreferredNamespacedResources()
+}
+
+func (d *cachedDiscovery) ServerVersion() (*version.Info, error) {
+	d.expire()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.version != nil {
+		return d.version, nil
+	}
+	v, err := d.CachedDiscoveryInterface.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	d.version = v
+	return v, nil
+}
+
+func (d *cachedDiscovery) OpenAPISchema() (*openapi_v2.Document, error) {
+	d.expire()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.openAPI != nil {
+		return d.openAPI, nil
+	}
+	doc, err := d.CachedDiscoveryInterface.OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+	d.openAPI = doc
+	return doc, nil
+}
+
+// WithDiscoveryCache returns a RESTClientGetter whose discovery clients and
+// RESTMappers use the information of the cache.
+func WithDiscoveryCache(getter genericclioptions.RESTClientGetter, cache *DiscoveryCache) genericclioptions.RESTClientGetter {
+	if cache == nil {
+		return getter
+	}
+	return &discoveryCacheGetter{RESTClientGetter: getter, cache: cache}
+}
+
+type discoveryCacheGetter struct {
+	genericclioptions.RESTClientGetter
+	cache *DiscoveryCache
+}
+
+func (g *discoveryCacheGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	c, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	return g.cache.Client(c)
+}
+
+func (g *discoveryCacheGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	dc, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(dc)
+	return restmapper.NewShortcutExpander(mapper, dc), nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"testing"
+	"time"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// countingDiscovery counts the server version and OpenAPI schema requests.
+type countingDiscovery struct {
+	*fake.FakeDiscovery
+	versions, schemas int
+}
+
+func (d *countingDiscovery) ServerVersion() (*version.Info, error) {
+	d.versions++
+	return &version.Info{GitVersion: "v1.24.0"}, nil
+}
+
+func (d *countingDiscovery) OpenAPISchema() (*openapi_v2.Document, error) {
+	d.schemas++
+	return &openapi_v2.Document{}, nil
+}
+
+func newTestCachedDiscovery(ttl time.Duration) (*cachedDiscovery, *countingDiscovery) {
+	delegate := &countingDiscovery{FakeDiscovery: &fake.FakeDiscovery{Fake: &k8stesting.Fake{}}}
+	var dc discovery.DiscoveryInterface = delegate
+	return newCachedDiscovery(memory.NewMemCacheClient(dc), ttl), delegate
+}
+
+func TestCachedDiscovery(t *testing.T) {
+	now := time.Now()
+	dc, delegate := newTestCachedDiscovery(time.Minute)
+	dc.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := dc.ServerVersion(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dc.OpenAPISchema(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if delegate.versions != 1 || delegate.schemas != 1 {
+		t.Errorf("expected 1 request each, got %d version and %d schema requests", delegate.versions, delegate.schemas)
+	}
+
+	dc.Invalidate()
+	dc.ServerVersion()
+	if delegate.versions != 2 {
+		t.Errorf("expected the version to be requested again after an invalidation, got %d requests", delegate.versions)
+	}
+
+	now = now.Add(2 * time.Minute)
+	dc.ServerVersion()
+	dc.OpenAPISchema()
+	if delegate.versions != 3 || delegate.schemas != 2 {
+		t.Errorf("expected the information to expire, got %d version and %d schema requests", delegate.versions, delegate.schemas)
+	}
+}
+
+func TestDiscoveryCacheClient(t *testing.T) {
+	cache := NewDiscoveryCache(time.Minute)
+
+	a, err := cache.Client(&rest.Config{Host: "https://a.example.com", BearerToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := cache.Client(&rest.Config{Host: "https://a.example.com", BearerToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != again {
+		t.Error("expected the clients of the same cluster and identity to be shared")
+	}
+
+	others := []*rest.Config{
+		{Host: "https://b.example.com", BearerToken: "token"},
+		{Host: "https://a.example.com", BearerToken: "other"},
+		{Host: "https://a.example.com", BearerToken: "token", Impersonate: rest.ImpersonationConfig{UserName: "jane"}},
+	}
+	for _, c := range others {
+		other, err := cache.Client(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other == a {
+			t.Errorf("expected a separate client for %+v", c)
+		}
+	}
+
+	cache.InvalidateAll()
+	fresh, err := cache.Client(&rest.Config{Host: "https://a.example.com", BearerToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == a {
+		t.Error("expected a new client after invalidating the cache")
+	}
+}
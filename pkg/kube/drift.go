@@ -0,0 +1,108 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldDrift is a field of a resource whose live value differs from the
+// value it was given in a manifest.
+type FieldDrift struct {
+	// Path locates the field, e.g. "spec.template.spec.containers[0].image".
+	Path string `json:"path"`
+	// Desired is the value in the manifest.
+	Desired interface{} `json:"desired"`
+	// Live is the value in the cluster, or nil if the field is not set.
+	Live interface{} `json:"live"`
+}
+
+// Drift compares the unstructured content of a resource as rendered in a
+// manifest with its live state and returns the fields that differ, ordered
+// by path.
+//
+// Only fields set in desired are compared. Fields that exist only in live,
+// such as defaults applied by the API server or fields managed by
+// controllers, are not reported, and neither is the status of the resource.
+// Lists whose length differs are reported as a whole.
+func Drift(desired, live map[string]interface{}) []FieldDrift {
+	var drift []FieldDrift
+	for k, v := range desired {
+		if k == "status" {
+			continue
+		}
+		drift = appendDrift(drift, k, v, live[k])
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Path < drift[j].Path })
+	return drift
+}
+
+func appendDrift(drift []FieldDrift, path string, desired, live interface{}) []FieldDrift {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return append(drift, FieldDrift{Path: path, Desired: desired, Live: live})
+		}
+		for k, v := range d {
+			drift = appendDrift(drift, path+"."+k, v, l[k])
+		}
+		return drift
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return append(drift, FieldDrift{Path: path, Desired: desired, Live: live})
+		}
+		for i := range d {
+			drift = appendDrift(drift, fmt.Sprintf("%s[%d]", path, i), d[i], l[i])
+		}
+		return drift
+	}
+	if !equalScalar(desired, live) {
+		return append(drift, FieldDrift{Path: path, Desired: desired, Live: live})
+	}
+	return drift
+}
+
+// equalScalar compares scalar values, treating numbers of different types
+// as equal if their values are, since manifests and API responses decode
+// numbers differently.
+func equalScalar(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,75 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	desired := map[string]interface{}{
+		"kind": "Deployment",
+		"metadata": map[string]interface{}{
+			"name":   "web",
+			"labels": map[string]interface{}{"app": "web"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.21"},
+					},
+				},
+			},
+		},
+	}
+	live := map[string]interface{}{
+		"kind": "Deployment",
+		"metadata": map[string]interface{}{
+			"name":            "web",
+			"labels":          map[string]interface{}{"app": "web"},
+			"resourceVersion": "42",
+		},
+		"spec": map[string]interface{}{
+			"replicas":             float64(5),
+			"revisionHistoryLimit": float64(10),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.23", "imagePullPolicy": "IfNotPresent"},
+					},
+				},
+			},
+		},
+		"status": map[string]interface{}{"replicas": float64(5)},
+	}
+
+	got := Drift(desired, live)
+	want := []FieldDrift{
+		{Path: "spec.replicas", Desired: int64(3), Live: float64(5)},
+		{Path: "spec.template.spec.containers[0].image", Desired: "nginx:1.21", Live: "nginx:1.23"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if d := Drift(desired, desired); len(d) != 0 {
+		t.Errorf("Expected no drift comparing a resource with itself, got %v", d)
+	}
+}
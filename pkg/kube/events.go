@@ -0,0 +1,70 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WarningEvents returns the most recent warning events of the object of the
+// kind and name in namespace, at most limit of them if limit is positive,
+// the most recent first.
+func WarningEvents(ctx context.Context, cs kubernetes.Interface, namespace, kind, name string, limit int) ([]corev1.Event, error) {
+	selector := fields.Set{
+		"involvedObject.kind": kind,
+		"involvedObject.name": name,
+		"type":                corev1.EventTypeWarning,
+	}.AsSelector().String()
+	list, err := cs.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	// Not every client honors field selectors, so the events are filtered
+	// again.
+	var events []corev1.Event
+	for _, e := range list.Items {
+		if e.Type == corev1.EventTypeWarning && e.InvolvedObject.Kind == kind && e.InvolvedObject.Name == name {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[j]).Before(eventTime(events[i]))
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// eventTime returns the time an event was last seen.
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
@@ -0,0 +1,60 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWarningEvents(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(name, kind, object, typ string, age time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object, Namespace: "default"},
+			Type:           typ,
+			Reason:         name,
+			LastTimestamp:  metav1.NewTime(now.Add(-age)),
+		}
+	}
+	cs := fake.NewSimpleClientset(
+		event("old", "Pod", "web", corev1.EventTypeWarning, time.Hour),
+		event("recent", "Pod", "web", corev1.EventTypeWarning, time.Minute),
+		event("middle", "Pod", "web", corev1.EventTypeWarning, 10*time.Minute),
+		event("normal", "Pod", "web", corev1.EventTypeNormal, 0),
+		event("other-pod", "Pod", "db", corev1.EventTypeWarning, 0),
+		event("other-kind", "Service", "web", corev1.EventTypeWarning, 0),
+	)
+
+	events, err := WarningEvents(context.Background(), cs, "default", "Pod", "web", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, e := range events {
+		reasons = append(reasons, e.Reason)
+	}
+	if len(reasons) != 2 || reasons[0] != "recent" || reasons[1] != "middle" {
+		t.Errorf("expected the two most recent warnings of the pod, got %v", reasons)
+	}
+}
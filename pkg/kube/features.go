@@ -0,0 +1,168 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// featureNodesLimit is the number of nodes read to detect the features of a
+// cluster, enough to find their provider and pod ranges without listing all
+// the nodes of a large cluster.
+const featureNodesLimit = 100
+
+// cniDaemonSets are the network plugins recognized from the prefixes of the
+// names of their DaemonSets in kube-system.
+var cniDaemonSets = []struct {
+	prefix string
+	cni    string
+}{
+	{"calico-node", "calico"},
+	{"canal", "canal"},
+	{"cilium", "cilium"},
+	{"kube-flannel", "flannel"},
+	{"weave-net", "weave"},
+	{"antrea-agent", "antrea"},
+	{"aws-node", "aws-vpc-cni"},
+	{"azure-cns", "azure"},
+	{"kindnet", "kindnet"},
+}
+
+// Features are facts about a cluster that its version and APIs do not tell.
+// The facts that could not be detected are left empty.
+type Features struct {
+	// CloudProvider is the cloud provider of the nodes, such as "aws",
+	// "azure" or "gce", as in their provider IDs.
+	CloudProvider string
+	// CNI is the network plugin of the cluster, such as "calico" or
+	// "cilium", as recognized from the DaemonSets of kube-system.
+	CNI string
+	// DefaultStorageClass is the name of the default StorageClass.
+	DefaultStorageClass string
+	// IPv6 reports whether the nodes are given IPv6 pod ranges, and
+	// DualStack whether they are given both IPv4 and IPv6 ranges.
+	IPv6      bool
+	DualStack bool
+	// PodSecurity is the level the Pod Security admission enforces in the
+	// namespace of the client as labeled on the namespace.
+	PodSecurity string
+}
+
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+	podSecurityEnforceLabel           = "pod-security.kubernetes.io/enforce"
+)
+
+// DetectFeatures detects the features of the cluster from its nodes, the
+// DaemonSets of kube-system, its StorageClasses and the namespace of the
+// client. The facts the client is not allowed to read are left empty.
+func (c *Client) DetectFeatures() (Features, error) {
+	client, err := c.Factory.KubernetesClientSet()
+	if err != nil {
+		return Features{}, err
+	}
+	return detectFeatures(context.Background(), client, c.namespace())
+}
+
+func detectFeatures(ctx context.Context, client kubernetes.Interface, namespace string) (Features, error) {
+	features := Features{}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: featureNodesLimit})
+	if err != nil && !isDenied(err) {
+		return features, errors.Wrap(err, "could not list the nodes")
+	}
+	if err == nil {
+		var ipv4, ipv6 bool
+		for _, n := range nodes.Items {
+			if features.CloudProvider == "" {
+				if i := strings.Index(n.Spec.ProviderID, "://"); i > 0 {
+					features.CloudProvider = n.Spec.ProviderID[:i]
+				}
+			}
+			cidrs := n.Spec.PodCIDRs
+			if len(cidrs) == 0 && n.Spec.PodCIDR != "" {
+				cidrs = []string{n.Spec.PodCIDR}
+			}
+			for _, cidr := range cidrs {
+				ip, _, err := net.ParseCIDR(cidr)
+				if err != nil {
+					continue
+				}
+				if ip.To4() == nil {
+					ipv6 = true
+				} else {
+					ipv4 = true
+				}
+			}
+		}
+		features.IPv6 = ipv6
+		features.DualStack = ipv4 && ipv6
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{})
+	if err != nil && !isDenied(err) {
+		return features, errors.Wrap(err, "could not list the DaemonSets of kube-system")
+	}
+	if err == nil {
+	cni:
+		for _, ds := range daemonSets.Items {
+			for _, d := range cniDaemonSets {
+				if strings.HasPrefix(ds.Name, d.prefix) {
+					features.CNI = d.cni
+					break cni
+				}
+			}
+		}
+	}
+
+	classes, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil && !isDenied(err) {
+		return features, errors.Wrap(err, "could not list the StorageClasses")
+	}
+	if err == nil {
+		for _, sc := range classes.Items {
+			if sc.Annotations[defaultStorageClassAnnotation] == "true" || sc.Annotations[betaDefaultStorageClassAnnotation] == "true" {
+				features.DefaultStorageClass = sc.Name
+				break
+			}
+		}
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil && !isDenied(err) && !apierrors.IsNotFound(err) {
+		return features, errors.Wrapf(err, "could not get the namespace %q", namespace)
+	}
+	if err == nil {
+		features.PodSecurity = ns.Labels[podSecurityEnforceLabel]
+	}
+
+	return features, nil
+}
+
+// isDenied reports whether err is a refusal of the cluster to let the client
+// read a resource.
+func isDenied(err error) bool {
+	return apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err)
+}
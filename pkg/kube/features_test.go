@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDetectFeatures(t *testing.T) {
+	node := func(name, providerID string, cidrs ...string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{ProviderID: providerID, PodCIDRs: cidrs},
+		}
+	}
+	cs := fake.NewSimpleClientset(
+		node("a", "aws:///us-east-1a/i-0123", "10.0.0.0/24", "fd00::/64"),
+		node("b", "aws:///us-east-1b/i-4567", "10.0.1.0/24", "fd00:1::/64"),
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "kube-proxy", Namespace: "kube-system"}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "cilium", Namespace: "kube-system"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{
+			Name:        "gp3",
+			Annotations: map[string]string{defaultStorageClassAnnotation: "true"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "apps",
+			Labels: map[string]string{podSecurityEnforceLabel: "restricted"},
+		}},
+	)
+
+	features, err := detectFeatures(context.Background(), cs, "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Features{
+		CloudProvider:       "aws",
+		CNI:                 "cilium",
+		DefaultStorageClass: "gp3",
+		IPv6:                true,
+		DualStack:           true,
+		PodSecurity:         "restricted",
+	}
+	if features != expect {
+		t.Errorf("expected %+v, got %+v", expect, features)
+	}
+}
+
+func TestDetectFeaturesDenied(t *testing.T) {
+	cs := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "a"},
+		Spec:       corev1.NodeSpec{PodCIDR: "10.0.0.0/24"},
+	})
+	cs.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetResource().Resource == "nodes" {
+			return false, nil, nil
+		}
+		gr := schema.GroupResource{Group: action.GetResource().Group, Resource: action.GetResource().Resource}
+		return true, nil, apierrors.NewForbidden(gr, "", nil)
+	})
+
+	features, err := detectFeatures(context.Background(), cs, "apps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Features{}
+	if features != expect {
+		t.Errorf("expected %+v, got %+v", expect, features)
+	}
+}
@@ -78,5 +78,53 @@ type InterfaceExt interface {
 	WaitForDelete(resources ResourceList, timeout time.Duration) error
 }
 
+// InterfaceWaitStrategy is implemented by the clients supporting several
+// strategies to wait for resources to be ready, see WaitStrategy.
+type InterfaceWaitStrategy interface {
+	// WaitWithStrategy waits like Wait, or like WaitWithJobs if jobs is set,
+	// with the given strategy or the default one if empty, and returns how
+	// long each resource took to be ready.
+	WaitWithStrategy(resources ResourceList, timeout time.Duration, strategy WaitStrategy, jobs bool) ([]WaitTiming, error)
+}
+
+// InterfaceApply is implemented by the clients able to create and update
+// resources with options, see ApplyOptions.
+type InterfaceApply interface {
+	// CreateWithOptions creates the resources like Create, with the given
+	// options.
+	CreateWithOptions(resources ResourceList, opts ApplyOptions) (*Result, error)
+	// UpdateWithOptions updates the resources like Update, with the given
+	// options.
+	UpdateWithOptions(original, target ResourceList, force bool, opts ApplyOptions) (*Result, error)
+}
+
+// InterfaceFeatures is implemented by the clients able to detect the
+// features of the cluster.
+type InterfaceFeatures interface {
+	// DetectFeatures detects the features of the cluster.
+	DetectFeatures() (Features, error)
+}
+
+// InterfaceAccess is implemented by the clients able to review the
+// permissions of their identity.
+type InterfaceAccess interface {
+	// ReviewAccess returns the permissions of perms the identity of the
+	// client is denied.
+	ReviewAccess(perms []Permission) ([]Permission, error)
+}
+
+// InterfaceNamespaces is implemented by the clients able to tell what is left
+// in a namespace before it is deleted.
+type InterfaceNamespaces interface {
+	// RemainingResources returns the resources left in namespace that
+	// deleting it would also delete, as "kind/name".
+	RemainingResources(namespace string) ([]string, error)
+}
+
 var _ Interface = (*Client)(nil)
 var _ InterfaceExt = (*Client)(nil)
+var _ InterfaceWaitStrategy = (*Client)(nil)
+var _ InterfaceApply = (*Client)(nil)
+var _ InterfaceFeatures = (*Client)(nil)
+var _ InterfaceAccess = (*Client)(nil)
+var _ InterfaceNamespaces = (*Client)(nil)
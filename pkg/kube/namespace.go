@@ -0,0 +1,106 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespaceDefaults are the objects Kubernetes creates in every namespace.
+var namespaceDefaults = map[string]bool{
+	"configmap/kube-root-ca.crt": true,
+}
+
+// RemainingResources returns the resources left in namespace that deleting
+// it would also delete, as "kind/name" in lexical order. Resources being
+// deleted, resources owned by other objects, the release records of Helm and
+// the objects Kubernetes creates in every namespace are left out.
+//
+// Only the common kinds of workloads, services, configuration and storage
+// are looked for, so an empty result does not guarantee that the namespace
+// is empty.
+func (c *Client) RemainingResources(namespace string) ([]string, error) {
+	client, err := c.Factory.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	return remainingResources(context.Background(), client, namespace)
+}
+
+func remainingResources(ctx context.Context, client kubernetes.Interface, namespace string) ([]string, error) {
+	opts := metav1.ListOptions{}
+	lists := []struct {
+		kind string
+		list func() (runtime.Object, error)
+	}{
+		{"pod", func() (runtime.Object, error) { return client.CoreV1().Pods(namespace).List(ctx, opts) }},
+		{"service", func() (runtime.Object, error) { return client.CoreV1().Services(namespace).List(ctx, opts) }},
+		{"configmap", func() (runtime.Object, error) { return client.CoreV1().ConfigMaps(namespace).List(ctx, opts) }},
+		{"secret", func() (runtime.Object, error) { return client.CoreV1().Secrets(namespace).List(ctx, opts) }},
+		{"persistentvolumeclaim", func() (runtime.Object, error) {
+			return client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+		}},
+		{"deployment", func() (runtime.Object, error) { return client.AppsV1().Deployments(namespace).List(ctx, opts) }},
+		{"statefulset", func() (runtime.Object, error) { return client.AppsV1().StatefulSets(namespace).List(ctx, opts) }},
+		{"daemonset", func() (runtime.Object, error) { return client.AppsV1().DaemonSets(namespace).List(ctx, opts) }},
+		{"job", func() (runtime.Object, error) { return client.BatchV1().Jobs(namespace).List(ctx, opts) }},
+	}
+
+	var remaining []string
+	for _, l := range lists {
+		list, err := l.list()
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list the %ss of namespace %q", l.kind, namespace)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if secret, ok := item.(*v1.Secret); ok && secret.Type == v1.SecretTypeServiceAccountToken {
+				continue
+			}
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			id := l.kind + "/" + obj.GetName()
+			switch {
+			case obj.GetDeletionTimestamp() != nil,
+				len(obj.GetOwnerReferences()) > 0,
+				obj.GetLabels()["owner"] == "helm",
+				namespaceDefaults[id]:
+				continue
+			}
+			remaining = append(remaining, id)
+		}
+	}
+	sort.Strings(remaining)
+	return remaining, nil
+}
@@ -0,0 +1,67 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRemainingResources(t *testing.T) {
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "web"}
+	}
+	now := metav1.Now()
+	objs := []runtime.Object{
+		// left out
+		&v1.ConfigMap{ObjectMeta: meta("kube-root-ca.crt")},
+		&v1.Secret{ObjectMeta: meta("default-token-abcde"), Type: v1.SecretTypeServiceAccountToken},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.web.v1", Namespace: "web", Labels: map[string]string{"owner": "helm"}}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-abcde", Namespace: "web", OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web"}}}},
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "terminating", Namespace: "web", DeletionTimestamp: &now}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "api"}},
+		// left in the namespace
+		&appsv1.Deployment{ObjectMeta: meta("api")},
+		&v1.PersistentVolumeClaim{ObjectMeta: meta("data")},
+		&v1.Secret{ObjectMeta: meta("tls")},
+	}
+	cs := fake.NewSimpleClientset(objs...)
+
+	remaining, err := remainingResources(context.Background(), cs, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"deployment/api", "persistentvolumeclaim/data", "secret/tls"}
+	if !reflect.DeepEqual(remaining, want) {
+		t.Errorf("expected %v, got %v", want, remaining)
+	}
+
+	remaining, err = remainingResources(context.Background(), cs, "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("expected nothing left in an empty namespace, got %v", remaining)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	// ApplyOrderAnnotation sets the apply order of a resource, an integer
+	// defaulting to 0. The resources with a lower order are applied before
+	// the others, and the resources with the same order in kind order.
+	ApplyOrderAnnotation = "helm.sh/apply-order"
+	// DependsOnAnnotation lists the resources of the release that must be
+	// applied and ready before the annotated resource is applied, separated
+	// by commas, as "Kind/name" or "Kind.group/name", e.g.
+	// "Namespace/team-a, CustomResourceDefinition.apiextensions.k8s.io/widgets.example.com".
+	DependsOnAnnotation = "helm.sh/depends-on"
+	// WaveAnnotation sets the wave of a resource, an integer defaulting to
+	// 0, when a release is applied in waves. The waves are applied in
+	// increasing order, each once the resources of the previous one are
+	// ready.
+	WaveAnnotation = "helm.sh/wave"
+)
+
+// ResourceRef identifies the resources of a release a resource depends on.
+type ResourceRef struct {
+	Kind string
+	// Group is the API group of the resources. Resources of any group match
+	// if it is empty.
+	Group string
+	Name  string
+}
+
+// ParseResourceRef parses a reference of the form "Kind/name" or
+// "Kind.group/name".
+func ParseResourceRef(s string) (ResourceRef, error) {
+	s = strings.TrimSpace(s)
+	i := strings.Index(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return ResourceRef{}, errors.Errorf("invalid resource reference %q, must be Kind/name or Kind.group/name", s)
+	}
+	ref := ResourceRef{Kind: s[:i], Name: s[i+1:]}
+	if j := strings.Index(ref.Kind, "."); j >= 0 {
+		ref.Kind, ref.Group = ref.Kind[:j], ref.Kind[j+1:]
+	}
+	return ref, nil
+}
+
+func (r ResourceRef) String() string {
+	if r.Group == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "." + r.Group + "/" + r.Name
+}
+
+// matches reports whether the resource is referenced.
+func (r ResourceRef) matches(info *resource.Info) bool {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	return gvk.Kind == r.Kind && info.Name == r.Name && (r.Group == "" || gvk.Group == r.Group)
+}
+
+// Ordering customizes the order in which the resources of a release are
+// applied, beyond the order of their kinds. The zero value orders the
+// resources by their ApplyOrderAnnotation and DependsOnAnnotation.
+type Ordering struct {
+	// Order returns the apply order of a resource, overriding its
+	// ApplyOrderAnnotation. The annotation applies if it returns false.
+	Order func(info *resource.Info) (int, bool)
+	// DependsOn returns the resources a resource depends on, in addition to
+	// the ones of its DependsOnAnnotation.
+	DependsOn func(info *resource.Info) []ResourceRef
+}
+
+// Stage is a set of resources applied together.
+type Stage struct {
+	Resources ResourceList
+	// Wait are the resources of the stage other resources depend on. They
+	// must be ready before the next stage is applied.
+	Wait ResourceList
+}
+
+// Stages splits the resources into the stages they are applied in, one
+// after the other. The resources keep their order within a stage. It
+// returns a single stage if the resources have no order nor dependencies.
+func (o Ordering) Stages(resources ResourceList) ([]Stage, error) {
+	return o.stages(resources, resources, resources)
+}
+
+// Wave is a set of resources applied in stages, and ready before the next
+// wave is applied.
+type Wave struct {
+	Number int
+	Stages []Stage
+}
+
+// Resources returns the resources of the wave.
+func (w Wave) Resources() ResourceList {
+	var resources ResourceList
+	for _, stage := range w.Stages {
+		resources = append(resources, stage.Resources...)
+	}
+	return resources
+}
+
+// Waves splits the resources into the waves of their WaveAnnotation, in
+// apply order. A resource can depend on the resources of its wave and of the
+// previous ones.
+func (o Ordering) Waves(resources ResourceList) ([]Wave, error) {
+	byWave := make(map[int]ResourceList)
+	var numbers []int
+	for _, info := range resources {
+		number := 0
+		if v, ok := annotation(info, WaveAnnotation); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, errors.Errorf("%s: invalid %s annotation %q, must be an integer", describe(info), WaveAnnotation, v)
+			}
+			number = n
+		}
+		if _, ok := byWave[number]; !ok {
+			numbers = append(numbers, number)
+		}
+		byWave[number] = append(byWave[number], info)
+	}
+	sort.Ints(numbers)
+
+	var waves []Wave
+	var applied ResourceList
+	for _, number := range numbers {
+		applied = append(applied, byWave[number]...)
+		stages, err := o.stages(byWave[number], applied, resources)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wave %d", number)
+		}
+		waves = append(waves, Wave{Number: number, Stages: stages})
+	}
+	return waves, nil
+}
+
+// stages orders the resources, whose dependencies are looked up in all the
+// resources of the release. The dependencies in available but not in
+// resources are already applied.
+func (o Ordering) stages(resources, available, all ResourceList) ([]Stage, error) {
+	orders := make([]int, len(resources))
+	deps := make([][]int, len(resources))
+	for i, info := range resources {
+		order, err := o.order(info)
+		if err != nil {
+			return nil, err
+		}
+		orders[i] = order
+
+		refs, err := o.dependsOn(info)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if err := checkDependency(info, ref, available, all); err != nil {
+				return nil, err
+			}
+			for j, dep := range resources {
+				if j != i && ref.matches(dep) {
+					deps[i] = append(deps[i], j)
+				}
+			}
+		}
+	}
+
+	levels, err := stageLevels(resources, orders, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	max := 0
+	for _, l := range levels {
+		if l > max {
+			max = l
+		}
+	}
+	stages := make([]Stage, max+1)
+	waited := make(map[int]bool)
+	for i := range resources {
+		for _, j := range deps[i] {
+			waited[j] = true
+		}
+	}
+	for i, info := range resources {
+		stage := &stages[levels[i]]
+		stage.Resources = append(stage.Resources, info)
+		if waited[i] {
+			stage.Wait = append(stage.Wait, info)
+		}
+	}
+	return stages, nil
+}
+
+// checkDependency checks that the dependency of info is applied before it.
+func checkDependency(info *resource.Info, ref ResourceRef, available, all ResourceList) error {
+	for _, dep := range available {
+		if ref.matches(dep) {
+			return nil
+		}
+	}
+	for _, dep := range all {
+		if ref.matches(dep) {
+			return errors.Errorf("%s depends on %s, which is in a later wave", describe(info), ref)
+		}
+	}
+	return errors.Errorf("%s depends on %s, which is not a resource of the release", describe(info), ref)
+}
+
+// stageLevels returns the stage of each resource. The resources are grouped
+// by order, and the resources of a group are layered by their dependencies
+// within the group, after the stages of the previous groups.
+func stageLevels(resources ResourceList, orders []int, deps [][]int) ([]int, error) {
+	var groups []int
+	seen := make(map[int]bool)
+	for _, order := range orders {
+		if !seen[order] {
+			seen[order] = true
+			groups = append(groups, order)
+		}
+	}
+	sort.Ints(groups)
+
+	levels := make([]int, len(resources))
+	done := make([]bool, len(resources))
+	base := 0
+	for _, group := range groups {
+		var members []int
+		for i, order := range orders {
+			if order == group {
+				members = append(members, i)
+			}
+		}
+		for _, i := range members {
+			for _, j := range deps[i] {
+				if orders[j] > group {
+					return nil, errors.Errorf("%s depends on %s, which has a higher apply order", describe(resources[i]), describe(resources[j]))
+				}
+			}
+		}
+
+		// Layer the members, each after its dependencies in the group.
+		top := base
+		for remaining := len(members); remaining > 0; {
+			progress := false
+			for _, i := range members {
+				if done[i] {
+					continue
+				}
+				level, ready := base, true
+				for _, j := range deps[i] {
+					if orders[j] < group {
+						continue
+					}
+					if !done[j] {
+						ready = false
+						break
+					}
+					if levels[j]+1 > level {
+						level = levels[j] + 1
+					}
+				}
+				if !ready {
+					continue
+				}
+				levels[i], done[i] = level, true
+				if level > top {
+					top = level
+				}
+				remaining--
+				progress = true
+			}
+			if !progress {
+				var cycle []string
+				for _, i := range members {
+					if !done[i] {
+						cycle = append(cycle, describe(resources[i]))
+					}
+				}
+				return nil, errors.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+			}
+		}
+		base = top + 1
+	}
+	return levels, nil
+}
+
+func (o Ordering) order(info *resource.Info) (int, error) {
+	if o.Order != nil {
+		if order, ok := o.Order(info); ok {
+			return order, nil
+		}
+	}
+	v, ok := annotation(info, ApplyOrderAnnotation)
+	if !ok {
+		return 0, nil
+	}
+	order, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, errors.Errorf("%s: invalid %s annotation %q, must be an integer", describe(info), ApplyOrderAnnotation, v)
+	}
+	return order, nil
+}
+
+func (o Ordering) dependsOn(info *resource.Info) ([]ResourceRef, error) {
+	var refs []ResourceRef
+	if v, ok := annotation(info, DependsOnAnnotation); ok {
+		for _, s := range strings.Split(v, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			ref, err := ParseResourceRef(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: invalid %s annotation", describe(info), DependsOnAnnotation)
+			}
+			refs = append(refs, ref)
+		}
+	}
+	if o.DependsOn != nil {
+		refs = append(refs, o.DependsOn(info)...)
+	}
+	return refs, nil
+}
+
+func annotation(info *resource.Info, key string) (string, bool) {
+	annotations, err := metadataAccessor.Annotations(info.Object)
+	if err != nil || annotations == nil {
+		return "", false
+	}
+	v, ok := annotations[key]
+	return v, ok
+}
+
+func describe(info *resource.Info) string {
+	return info.Object.GetObjectKind().GroupVersionKind().Kind + "/" + info.Name
+}
@@ -0,0 +1,214 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func orderInfo(apiVersion, kind, name string, annotations map[string]string) *resource.Info {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetName(name)
+	obj.SetAnnotations(annotations)
+	return &resource.Info{Name: name, Object: obj}
+}
+
+func stageNames(stages []Stage) (resources, wait [][]string) {
+	for _, stage := range stages {
+		var r, w []string
+		for _, info := range stage.Resources {
+			r = append(r, info.Name)
+		}
+		for _, info := range stage.Wait {
+			w = append(w, info.Name)
+		}
+		resources = append(resources, r)
+		wait = append(wait, w)
+	}
+	return resources, wait
+}
+
+func TestOrderingStages(t *testing.T) {
+	crd := orderInfo("apiextensions.k8s.io/v1", "CustomResourceDefinition", "widgets.example.com", nil)
+	ns := orderInfo("v1", "Namespace", "team-a", nil)
+	cm := orderInfo("v1", "ConfigMap", "config", map[string]string{DependsOnAnnotation: "Namespace/team-a"})
+	deploy := orderInfo("apps/v1", "Deployment", "web", map[string]string{
+		DependsOnAnnotation: "ConfigMap/config, CustomResourceDefinition.apiextensions.k8s.io/widgets.example.com",
+	})
+	job := orderInfo("batch/v1", "Job", "migrate", map[string]string{ApplyOrderAnnotation: "-1"})
+	svc := orderInfo("v1", "Service", "web", nil)
+
+	stages, err := Ordering{}.Stages(ResourceList{crd, ns, cm, svc, deploy, job})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, wait := stageNames(stages)
+	wantResources := [][]string{{"migrate"}, {"widgets.example.com", "team-a", "web"}, {"config"}, {"web"}}
+	wantWait := [][]string{nil, {"widgets.example.com", "team-a"}, {"config"}, nil}
+	if !reflect.DeepEqual(resources, wantResources) {
+		t.Errorf("expected stages %v, got %v", wantResources, resources)
+	}
+	if !reflect.DeepEqual(wait, wantWait) {
+		t.Errorf("expected waits %v, got %v", wantWait, wait)
+	}
+}
+
+func TestOrderingStagesDefault(t *testing.T) {
+	list := ResourceList{
+		orderInfo("v1", "Namespace", "a", nil),
+		orderInfo("v1", "ConfigMap", "b", nil),
+	}
+	stages, err := Ordering{}.Stages(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stages) != 1 || len(stages[0].Resources) != 2 || len(stages[0].Wait) != 0 {
+		t.Errorf("expected a single stage, got %+v", stages)
+	}
+}
+
+func TestOrderingStagesOptions(t *testing.T) {
+	a := orderInfo("v1", "ConfigMap", "a", map[string]string{ApplyOrderAnnotation: "5"})
+	b := orderInfo("v1", "ConfigMap", "b", nil)
+	ordering := Ordering{
+		Order: func(info *resource.Info) (int, bool) {
+			return 0, info.Name == "a"
+		},
+		DependsOn: func(info *resource.Info) []ResourceRef {
+			if info.Name == "a" {
+				return []ResourceRef{{Kind: "ConfigMap", Name: "b"}}
+			}
+			return nil
+		},
+	}
+	stages, err := ordering.Stages(ResourceList{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, _ := stageNames(stages)
+	if want := [][]string{{"b"}, {"a"}}; !reflect.DeepEqual(resources, want) {
+		t.Errorf("expected stages %v, got %v", want, resources)
+	}
+}
+
+func TestOrderingStagesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources ResourceList
+		want      string
+	}{
+		{
+			name: "cycle",
+			resources: ResourceList{
+				orderInfo("v1", "ConfigMap", "a", map[string]string{DependsOnAnnotation: "ConfigMap/b"}),
+				orderInfo("v1", "ConfigMap", "b", map[string]string{DependsOnAnnotation: "ConfigMap/a"}),
+			},
+			want: "dependency cycle between ConfigMap/a, ConfigMap/b",
+		},
+		{
+			name: "missing dependency",
+			resources: ResourceList{
+				orderInfo("v1", "ConfigMap", "a", map[string]string{DependsOnAnnotation: "Secret/b"}),
+			},
+			want: "ConfigMap/a depends on Secret/b, which is not a resource of the release",
+		},
+		{
+			name: "dependency applied later",
+			resources: ResourceList{
+				orderInfo("v1", "ConfigMap", "a", map[string]string{DependsOnAnnotation: "ConfigMap/b"}),
+				orderInfo("v1", "ConfigMap", "b", map[string]string{ApplyOrderAnnotation: "1"}),
+			},
+			want: "ConfigMap/a depends on ConfigMap/b, which has a higher apply order",
+		},
+		{
+			name: "invalid order",
+			resources: ResourceList{
+				orderInfo("v1", "ConfigMap", "a", map[string]string{ApplyOrderAnnotation: "first"}),
+			},
+			want: `invalid helm.sh/apply-order annotation "first"`,
+		},
+		{
+			name: "invalid reference",
+			resources: ResourceList{
+				orderInfo("v1", "ConfigMap", "a", map[string]string{DependsOnAnnotation: "b"}),
+			},
+			want: `invalid resource reference "b"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Ordering{}.Stages(tt.resources)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestOrderingWaves(t *testing.T) {
+	ns := orderInfo("v1", "Namespace", "team-a", map[string]string{WaveAnnotation: "-1"})
+	cm := orderInfo("v1", "ConfigMap", "config", map[string]string{DependsOnAnnotation: "Namespace/team-a"})
+	job := orderInfo("batch/v1", "Job", "migrate", map[string]string{WaveAnnotation: "1"})
+	deploy := orderInfo("apps/v1", "Deployment", "web", map[string]string{
+		WaveAnnotation:      "1",
+		DependsOnAnnotation: "Job/migrate",
+	})
+
+	waves, err := Ordering{}.Waves(ResourceList{ns, cm, job, deploy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []int
+	var names [][][]string
+	for _, wave := range waves {
+		numbers = append(numbers, wave.Number)
+		resources, _ := stageNames(wave.Stages)
+		names = append(names, resources)
+	}
+	if want := []int{-1, 0, 1}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("expected waves %v, got %v", want, numbers)
+	}
+	want := [][][]string{{{"team-a"}}, {{"config"}}, {{"migrate"}, {"web"}}}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("expected stages %v, got %v", want, names)
+	}
+	if len(waves[2].Resources()) != 2 {
+		t.Errorf("expected 2 resources in wave 1, got %d", len(waves[2].Resources()))
+	}
+
+	_, err = Ordering{}.Waves(ResourceList{
+		orderInfo("v1", "ConfigMap", "a", map[string]string{DependsOnAnnotation: "ConfigMap/b"}),
+		orderInfo("v1", "ConfigMap", "b", map[string]string{WaveAnnotation: "2"}),
+	})
+	if want := "wave 0: ConfigMap/a depends on ConfigMap/b, which is in a later wave"; err == nil || err.Error() != want {
+		t.Errorf("expected error %q, got %v", want, err)
+	}
+
+	_, err = Ordering{}.Waves(ResourceList{
+		orderInfo("v1", "ConfigMap", "a", map[string]string{WaveAnnotation: "last"}),
+	})
+	if err == nil || !strings.Contains(err.Error(), `invalid helm.sh/wave annotation "last"`) {
+		t.Errorf("expected an invalid wave error, got %v", err)
+	}
+}
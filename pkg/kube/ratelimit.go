@@ -0,0 +1,198 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// adaptiveMinQPS is the lowest rate an adaptive rate limiter slows down to.
+const adaptiveMinQPS = 1
+
+// RateLimitOptions configure the client-side rate limiting of the requests to
+// the Kubernetes API. Large releases may need a higher rate than the client-go
+// defaults to avoid being throttled by the client itself.
+type RateLimitOptions struct {
+	// QPS is the maximum number of queries per second. The client-go default
+	// applies if it is zero, and a negative value disables the rate limiting.
+	QPS float32
+	// Burst is the maximum number of queries sent at once. The client-go
+	// default applies if it is zero.
+	Burst int
+	// Adaptive halves the rate when the API server throttles the requests
+	// with 429 Too Many Requests responses, and raises it back up to QPS as
+	// requests succeed. It has no effect if the rate limiting is disabled.
+	Adaptive bool
+}
+
+// IsZero returns true if the options change nothing.
+func (o RateLimitOptions) IsZero() bool {
+	return o.QPS == 0 && o.Burst == 0 && !o.Adaptive
+}
+
+// ConfigWrapper returns a function applying the options to client
+// configurations. The clients built from the configurations it returns share
+// one adaptive rate limiter.
+func (o RateLimitOptions) ConfigWrapper() func(*rest.Config) *rest.Config {
+	var limiter *adaptiveRateLimiter
+	if o.Adaptive && o.QPS >= 0 {
+		qps, burst := o.QPS, o.Burst
+		if qps == 0 {
+			qps = rest.DefaultQPS
+		}
+		if burst == 0 {
+			burst = rest.DefaultBurst
+		}
+		limiter = newAdaptiveRateLimiter(qps, burst)
+	}
+	return func(c *rest.Config) *rest.Config {
+		if o.IsZero() {
+			return c
+		}
+		c = rest.CopyConfig(c)
+		if o.QPS != 0 {
+			c.QPS = o.QPS
+		}
+		if o.Burst != 0 {
+			c.Burst = o.Burst
+		}
+		if limiter != nil {
+			c.RateLimiter = limiter
+			c.WrapTransport = transport.Wrappers(c.WrapTransport, limiter.wrapTransport)
+		}
+		return c
+	}
+}
+
+// WithRateLimit returns a RESTClientGetter applying the options to the
+// clients of getter.
+func WithRateLimit(getter genericclioptions.RESTClientGetter, opts RateLimitOptions) genericclioptions.RESTClientGetter {
+	if opts.IsZero() {
+		return getter
+	}
+	return &wrapGetter{RESTClientGetter: getter, wrap: opts.ConfigWrapper()}
+}
+
+// adaptiveRateLimiter is a token bucket rate limiter whose rate decreases
+// multiplicatively when the API server throttles requests, and increases
+// additively as requests succeed.
+type adaptiveRateLimiter struct {
+	limiter *rate.Limiter
+	max     rate.Limit
+	now     func() time.Time
+
+	mu      sync.Mutex
+	updated time.Time
+}
+
+func newAdaptiveRateLimiter(qps float32, burst int) *adaptiveRateLimiter {
+	return &adaptiveRateLimiter{
+		limiter: rate.NewLimiter(rate.Limit(qps), burst),
+		max:     rate.Limit(qps),
+		now:     time.Now,
+	}
+}
+
+func (l *adaptiveRateLimiter) TryAccept() bool {
+	return l.limiter.AllowN(l.now(), 1)
+}
+
+func (l *adaptiveRateLimiter) Accept() {
+	_ = l.limiter.Wait(context.Background())
+}
+
+func (l *adaptiveRateLimiter) Wait(ctx context.Context) error {
+	return l.limiter.Wait(ctx)
+}
+
+func (l *adaptiveRateLimiter) Stop() {}
+
+func (l *adaptiveRateLimiter) QPS() float32 {
+	return float32(l.limiter.Limit())
+}
+
+// throttled halves the rate, down to adaptiveMinQPS.
+func (l *adaptiveRateLimiter) throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	limit := l.limiter.Limit() / 2
+	if min := rate.Limit(adaptiveMinQPS); limit < min {
+		limit = min
+		if l.max < min {
+			limit = l.max
+		}
+	}
+	l.limiter.SetLimitAt(now, limit)
+	l.updated = now
+}
+
+// succeeded raises the rate by a tenth of the maximum rate, at most once per
+// second.
+func (l *adaptiveRateLimiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	limit := l.limiter.Limit()
+	if limit >= l.max || now.Sub(l.updated) < time.Second {
+		return
+	}
+	limit += l.max / 10
+	if limit > l.max {
+		limit = l.max
+	}
+	l.limiter.SetLimitAt(now, limit)
+	l.updated = now
+}
+
+func (l *adaptiveRateLimiter) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &throttleObserver{rt: rt, limiter: l}
+}
+
+// throttleObserver reports the responses of the API server to an adaptive
+// rate limiter.
+type throttleObserver struct {
+	rt      http.RoundTripper
+	limiter *adaptiveRateLimiter
+}
+
+func (t *throttleObserver) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		t.limiter.throttled()
+	} else {
+		t.limiter.succeeded()
+	}
+	return resp, nil
+}
+
+func (t *throttleObserver) WrappedRoundTripper() http.RoundTripper {
+	return t.rt
+}
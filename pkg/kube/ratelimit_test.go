@@ -0,0 +1,111 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestRateLimitOptionsConfigWrapper(t *testing.T) {
+	base := &rest.Config{Host: "https://example.com", QPS: 5, Burst: 10}
+
+	if c := (RateLimitOptions{}).ConfigWrapper()(base); c != base {
+		t.Error("expected the configuration to be returned as is without options")
+	}
+
+	c := RateLimitOptions{QPS: 50, Burst: 100}.ConfigWrapper()(base)
+	if c.QPS != 50 || c.Burst != 100 {
+		t.Errorf("expected QPS 50 and burst 100, got %v and %d", c.QPS, c.Burst)
+	}
+	if c.RateLimiter != nil {
+		t.Error("expected no rate limiter")
+	}
+	if base.QPS != 5 {
+		t.Error("expected the base configuration to be unchanged")
+	}
+
+	wrap := RateLimitOptions{QPS: 20, Adaptive: true}.ConfigWrapper()
+	c1, c2 := wrap(base), wrap(base)
+	if c1.RateLimiter == nil || c1.RateLimiter != c2.RateLimiter {
+		t.Error("expected the configurations to share an adaptive rate limiter")
+	}
+	if c1.RateLimiter.QPS() != 20 {
+		t.Errorf("expected QPS 20, got %v", c1.RateLimiter.QPS())
+	}
+
+	if c := (RateLimitOptions{QPS: -1, Adaptive: true}).ConfigWrapper()(base); c.RateLimiter != nil {
+		t.Error("expected no rate limiter when rate limiting is disabled")
+	}
+}
+
+func TestAdaptiveRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newAdaptiveRateLimiter(20, 10)
+	l.now = func() time.Time { return now }
+
+	l.throttled()
+	if l.QPS() != 10 {
+		t.Errorf("expected QPS 10 after throttling, got %v", l.QPS())
+	}
+	for i := 0; i < 10; i++ {
+		l.throttled()
+	}
+	if l.QPS() != adaptiveMinQPS {
+		t.Errorf("expected QPS %d after throttling, got %v", adaptiveMinQPS, l.QPS())
+	}
+
+	l.succeeded()
+	if l.QPS() != adaptiveMinQPS {
+		t.Errorf("expected QPS to be raised at most once per second, got %v", l.QPS())
+	}
+	now = now.Add(time.Second)
+	l.succeeded()
+	if l.QPS() != 3 {
+		t.Errorf("expected QPS 3, got %v", l.QPS())
+	}
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		l.succeeded()
+	}
+	if l.QPS() != 20 {
+		t.Errorf("expected QPS to be raised back to 20, got %v", l.QPS())
+	}
+}
+
+func TestThrottleObserver(t *testing.T) {
+	status := http.StatusTooManyRequests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	l := newAdaptiveRateLimiter(20, 10)
+	client := &http.Client{Transport: l.wrapTransport(http.DefaultTransport)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if l.QPS() != 10 {
+		t.Errorf("expected QPS 10 after a throttled request, got %v", l.QPS())
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// ReadOnlyError is returned for the requests refused by the clients of
+// WithReadOnly because they could change the cluster.
+type ReadOnlyError struct {
+	Method string
+	URL    string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("read-only client refused to send %s %s", e.Method, e.URL)
+}
+
+// IsReadOnly reports whether err is, or wraps, a ReadOnlyError.
+func IsReadOnly(err error) bool {
+	var readOnly *ReadOnlyError
+	return errors.As(err, &readOnly)
+}
+
+// WithReadOnly returns a RESTClientGetter whose clients only send the
+// requests reading the cluster, the GET requests getting, listing and
+// watching resources, and HEAD requests. The other requests fail with a
+// ReadOnlyError without reaching the cluster, so that a client can be
+// guaranteed not to change it even with credentials allowing it.
+func WithReadOnly(getter genericclioptions.RESTClientGetter) genericclioptions.RESTClientGetter {
+	return &wrapGetter{RESTClientGetter: getter, wrap: readOnlyConfig}
+}
+
+// readOnlyConfig returns a copy of the configuration whose clients only send
+// the requests reading the cluster.
+func readOnlyConfig(c *rest.Config) *rest.Config {
+	c = rest.CopyConfig(c)
+	c.WrapTransport = transport.Wrappers(c.WrapTransport, func(rt http.RoundTripper) http.RoundTripper {
+		return &readOnlyRoundTripper{rt: rt}
+	})
+	return c
+}
+
+// readOnlyRoundTripper refuses the requests that could change the cluster.
+type readOnlyRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func (t *readOnlyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return t.rt.RoundTrip(req)
+	}
+	return nil, &ReadOnlyError{Method: req.Method, URL: req.URL.String()}
+}
+
+func (t *readOnlyRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return t.rt
+}
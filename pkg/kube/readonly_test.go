@@ -0,0 +1,61 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestReadOnlyConfig(t *testing.T) {
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"config","namespace":"default"}}`))
+	}))
+	defer srv.Close()
+
+	cs, err := kubernetes.NewForConfig(readOnlyConfig(&rest.Config{Host: srv.URL}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	configMaps := cs.CoreV1().ConfigMaps("default")
+
+	if _, err := configMaps.Get(ctx, "config", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected gets to be allowed, got %s", err)
+	}
+	_, err = configMaps.Create(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config"}}, metav1.CreateOptions{})
+	if !IsReadOnly(err) {
+		t.Errorf("expected a read-only error for creations, got %v", err)
+	}
+	err = configMaps.Delete(ctx, "config", metav1.DeleteOptions{})
+	if !IsReadOnly(err) {
+		t.Errorf("expected a read-only error for deletions, got %v", err)
+	}
+	if len(methods) != 1 || methods[0] != http.MethodGet {
+		t.Errorf("expected only the get to reach the server, got %v", methods)
+	}
+}
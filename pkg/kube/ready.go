@@ -19,6 +19,7 @@ package kube // import "github.com/open-hand/helm/pkg/kube"
 import (
 	"context"
 
+	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	appsv1beta1 "k8s.io/api/apps/v1beta1"
 	appsv1beta2 "k8s.io/api/apps/v1beta2"
@@ -28,6 +29,7 @@ import (
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
@@ -57,6 +59,21 @@ func CheckJobs(checkJobs bool) ReadyCheckerOption {
 	}
 }
 
+// IgnoreCustomResourceStatus returns a ReadyCheckerOption that configures a
+// ReadyChecker to consider the custom resources of the given kinds to be
+// ready regardless of their status, e.g. for CRDs whose conditions do not
+// follow the conventions. Kinds are given as "Kind" or "Kind.group".
+func IgnoreCustomResourceStatus(kinds ...string) ReadyCheckerOption {
+	return func(c *ReadyChecker) {
+		for _, kind := range kinds {
+			if c.ignoreKinds == nil {
+				c.ignoreKinds = make(map[string]bool)
+			}
+			c.ignoreKinds[kind] = true
+		}
+	}
+}
+
 // NewReadyChecker creates a new checker. Passed ReadyCheckerOptions can
 // be used to override defaults.
 func NewReadyChecker(cl kubernetes.Interface, log func(string, ...interface{}), opts ...ReadyCheckerOption) ReadyChecker {
@@ -79,12 +96,14 @@ type ReadyChecker struct {
 	log           func(string, ...interface{})
 	checkJobs     bool
 	pausedAsReady bool
+	ignoreKinds   map[string]bool
 }
 
 // IsReady checks if v is ready. It supports checking readiness for pods,
 // deployments, persistent volume claims, services, daemon sets, custom
 // resource definitions, stateful sets, replication controllers, and replica
-// sets. All other resource kinds are always considered ready.
+// sets. Custom resources are ready according to their status conditions, see
+// customResourceReady. All other resource kinds are always considered ready.
 //
 // IsReady will fetch the latest state of the object from the server prior to
 // performing readiness checks, and it will return any error encountered.
@@ -182,6 +201,8 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 		}
 	case *corev1.ReplicationController, *extensionsv1beta1.ReplicaSet, *appsv1beta2.ReplicaSet, *appsv1.ReplicaSet:
 		ok, err = c.podsReadyForObject(ctx, v.Namespace, value)
+	case *unstructured.Unstructured:
+		ok, err = c.customResourceReady(v)
 	}
 	if !ok || err != nil {
 		return false, err
@@ -189,6 +210,54 @@ func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, err
 	return true, nil
 }
 
+// customResourceReady interprets the status of a custom resource following
+// the conventions of kstatus: the resource is not ready while its status
+// does not reflect its latest generation, or while its Reconciling condition
+// is true or its Ready condition is false. A true Stalled condition is an
+// error. Resources without conditions are ready.
+func (c *ReadyChecker) customResourceReady(v *resource.Info) (bool, error) {
+	gvk := v.Object.GetObjectKind().GroupVersionKind()
+	if c.ignoreKinds[gvk.Kind] || c.ignoreKinds[gvk.GroupKind().String()] {
+		return true, nil
+	}
+	if err := v.Get(); err != nil {
+		return false, err
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(v.Object)
+	if err != nil {
+		return false, err
+	}
+
+	generation, _, _ := unstructured.NestedInt64(obj, "metadata", "generation")
+	observed, found, _ := unstructured.NestedInt64(obj, "status", "observedGeneration")
+	if found && observed < generation {
+		c.log("%s %s/%s status is not up to date: observed generation %d, generation %d", gvk.Kind, v.Namespace, v.Name, observed, generation)
+		return false, nil
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	for _, item := range conditions {
+		cond, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condType, _ := cond["type"].(string)
+		status, _ := cond["status"].(string)
+		message, _ := cond["message"].(string)
+		switch {
+		case condType == "Stalled" && status == string(corev1.ConditionTrue):
+			return false, errors.Errorf("%s %s/%s is stalled: %s", gvk.Kind, v.Namespace, v.Name, message)
+		case condType == "Reconciling" && status == string(corev1.ConditionTrue):
+			c.log("%s %s/%s is reconciling: %s", gvk.Kind, v.Namespace, v.Name, message)
+			return false, nil
+		case condType == "Ready" && status == string(corev1.ConditionFalse):
+			c.log("%s %s/%s is not ready: %s", gvk.Kind, v.Namespace, v.Name, message)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (c *ReadyChecker) podsReadyForObject(ctx context.Context, namespace string, obj runtime.Object) (bool, error) {
 	pods, err := c.podsforObject(ctx, namespace, obj)
 	if err != nil {
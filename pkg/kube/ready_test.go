@@ -17,16 +17,25 @@ limitations under the License.
 package kube // import "github.com/open-hand/helm/pkg/kube"
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
 	"testing"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/fake"
+	restfake "k8s.io/client-go/rest/fake"
 )
 
 const defaultNamespace = metav1.NamespaceDefault
@@ -352,6 +361,119 @@ func Test_ReadyChecker_volumeReady(t *testing.T) {
 	}
 }
 
+func Test_ReadyChecker_customResourceReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     map[string]interface{}
+		generation int64
+		ignore     []string
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name: "custom resource without status is ready",
+			want: true,
+		},
+		{
+			name:   "custom resource with true Ready condition is ready",
+			status: widgetStatus(1, "Ready", "True"),
+			want:   true,
+		},
+		{
+			name:   "custom resource with false Ready condition is not ready",
+			status: widgetStatus(1, "Ready", "False"),
+			want:   false,
+		},
+		{
+			name:   "custom resource with true Reconciling condition is not ready",
+			status: widgetStatus(1, "Reconciling", "True"),
+			want:   false,
+		},
+		{
+			name:    "custom resource with true Stalled condition fails",
+			status:  widgetStatus(1, "Stalled", "True"),
+			want:    false,
+			wantErr: true,
+		},
+		{
+			name:       "custom resource with outdated status is not ready",
+			status:     widgetStatus(1, "Ready", "True"),
+			generation: 2,
+			want:       false,
+		},
+		{
+			name:   "ignored kind is ready",
+			status: widgetStatus(1, "Ready", "False"),
+			ignore: []string{"Widget.example.com"},
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewReadyChecker(fake.NewSimpleClientset(), nil, IgnoreCustomResourceStatus(tt.ignore...))
+			generation := tt.generation
+			if generation == 0 {
+				generation = 1
+			}
+			got, err := c.IsReady(context.TODO(), newWidgetInfo(t, generation, tt.status))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("IsReady() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("IsReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func widgetStatus(observedGeneration int64, conditionType, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"observedGeneration": observedGeneration,
+		"conditions": []interface{}{
+			map[string]interface{}{"type": conditionType, "status": status, "message": "test"},
+		},
+	}
+}
+
+// newWidgetInfo returns a custom resource whose server state has the given
+// generation and status.
+func newWidgetInfo(t *testing.T, generation int64, status map[string]interface{}) *resource.Info {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace(defaultNamespace)
+	obj.SetName("foo")
+	obj.SetGeneration(generation)
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	body, err := json.Marshal(obj.Object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &restfake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+	return &resource.Info{
+		Client: client,
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+			GroupVersionKind: gvk,
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Namespace: defaultNamespace,
+		Name:      "foo",
+		Object:    obj.DeepCopy(),
+	}
+}
+
 func newDaemonSet(name string, maxUnavailable, numberReady, desiredNumberScheduled, updatedNumberScheduled int) *appsv1.DaemonSet {
 	return &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -23,6 +23,9 @@ type Result struct {
 	Created ResourceList
 	Updated ResourceList
 	Deleted ResourceList
+	// Unchanged are the existing resources left as is by an update, see
+	// ApplyOptions.Unchanged and ApplyOptions.OnConflict.
+	Unchanged ResourceList
 }
 
 // If needed, we can add methods to the Result type for things like diffing
@@ -32,33 +32,103 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 )
 
+// WaitStrategy is how waiting for resources to be ready checks them.
+type WaitStrategy string
+
+const (
+	// WaitStrategyWatch watches the resources and checks them again as soon
+	// as they change. It is the default.
+	WaitStrategyWatch WaitStrategy = "watch"
+	// WaitStrategyPoll checks the resources again every two seconds.
+	WaitStrategyPoll WaitStrategy = "poll"
+)
+
+// WaitStrategies are the supported wait strategies.
+var WaitStrategies = []WaitStrategy{WaitStrategyWatch, WaitStrategyPoll}
+
+// ParseWaitStrategy returns the wait strategy named s. The empty string is
+// the default strategy.
+func ParseWaitStrategy(s string) (WaitStrategy, error) {
+	switch WaitStrategy(s) {
+	case "", WaitStrategyWatch:
+		return WaitStrategyWatch, nil
+	case WaitStrategyPoll:
+		return WaitStrategyPoll, nil
+	}
+	return "", errors.Errorf("unknown wait strategy %q, expected %q or %q", s, WaitStrategyWatch, WaitStrategyPoll)
+}
+
+// WaitTiming is how long waiting for a resource to be ready took.
+type WaitTiming struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Duration is the time from the beginning of the wait until the
+	// resource was seen ready, or until the wait ended if it never was.
+	Duration time.Duration
+	// Ready tells whether the resource was seen ready.
+	Ready bool
+}
+
 type waiter struct {
 	c       ReadyChecker
 	timeout time.Duration
 	log     func(string, ...interface{})
+	// dynamic is the client watching the resources with WaitStrategyWatch.
+	dynamic dynamic.Interface
+}
+
+// newWaitTimings returns the timings of the resources before they are ready.
+func newWaitTimings(resources ResourceList) []WaitTiming {
+	timings := make([]WaitTiming, len(resources))
+	for i, info := range resources {
+		timings[i] = WaitTiming{Name: info.Name, Namespace: info.Namespace}
+		if info.Mapping != nil {
+			timings[i].Kind = info.Mapping.GroupVersionKind.Kind
+		} else if info.Object != nil {
+			timings[i].Kind = info.Object.GetObjectKind().GroupVersionKind().Kind
+		}
+	}
+	return timings
+}
+
+// finishWaitTimings sets the duration of the resources never seen ready.
+func finishWaitTimings(timings []WaitTiming, start time.Time) []WaitTiming {
+	for i := range timings {
+		if !timings[i].Ready {
+			timings[i].Duration = time.Since(start)
+		}
+	}
+	return timings
 }
 
 // waitForResources polls to get the current status of all pods, PVCs, Services and
 // Jobs(optional) until all are ready or a timeout is reached
-func (w *waiter) waitForResources(created ResourceList) error {
+func (w *waiter) waitForResources(created ResourceList) ([]WaitTiming, error) {
 	w.log("beginning wait for %d resources with timeout of %v", len(created), w.timeout)
 
 	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
 	defer cancel()
 
-	return wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
-		for _, v := range created {
+	start := time.Now()
+	timings := newWaitTimings(created)
+	err := wait.PollImmediateUntil(2*time.Second, func() (bool, error) {
+		for i, v := range created {
 			ready, err := w.c.IsReady(ctx, v)
 			if !ready || err != nil {
 				return false, err
 			}
+			if !timings[i].Ready {
+				timings[i].Ready, timings[i].Duration = true, time.Since(start)
+			}
 		}
 		return true, nil
 	}, ctx.Done())
+	return finishWaitTimings(timings, start), err
 }
 
 // waitForDeletedResources polls to check if all the resources are deleted or a timeout is reached
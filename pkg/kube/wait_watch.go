@@ -0,0 +1,171 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchResyncPeriod is how often the resources not ready are checked again
+// when waiting with WaitStrategyWatch even if their watches report nothing,
+// e.g. when their readiness depends on other resources.
+var watchResyncPeriod = 30 * time.Second
+
+// watchKey identifies the resources sharing a watch: the resources of a kind
+// in a namespace.
+type watchKey struct {
+	resource  schema.GroupVersionResource
+	namespace string
+}
+
+// watchForResources waits like waitForResources, but checks the resources
+// again as soon as a change to them is watched instead of polling them. The
+// resources of a kind in a namespace share a watch, restricted to the name of
+// the resource when there is only one, so that large releases do not load
+// the API server with requests.
+//
+// It falls back to polling when the resources cannot be watched.
+func (w *waiter) watchForResources(created ResourceList) ([]WaitTiming, error) {
+	groups := map[watchKey][]int{}
+	for i, info := range created {
+		if info.Mapping == nil {
+			w.log("unable to watch %s %q, falling back to polling", info.Object.GetObjectKind().GroupVersionKind().Kind, info.Name)
+			return w.waitForResources(created)
+		}
+		k := watchKey{resource: info.Mapping.Resource, namespace: info.Namespace}
+		groups[k] = append(groups[k], i)
+	}
+
+	w.log("beginning wait for %d resources with timeout of %v, watching %d kinds", len(created), w.timeout, len(groups))
+	var watchers sync.WaitGroup
+	defer watchers.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	// The watches start before the resources are first checked so that no
+	// change is missed in between.
+	var mu sync.Mutex
+	changed := map[watchKey]bool{}
+	notify := make(chan struct{}, 1)
+	for k, ids := range groups {
+		opts := metav1.ListOptions{}
+		if len(ids) == 1 {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", created[ids[0]].Name).String()
+		}
+		wi, err := w.watch(ctx, k, opts)
+		if err != nil {
+			cancel()
+			watchers.Wait()
+			w.log("unable to watch %s: %v, falling back to polling", k.resource.Resource, err)
+			return w.waitForResources(created)
+		}
+		watchers.Add(1)
+		go func(k watchKey, wi watch.Interface) {
+			defer watchers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					wi.Stop()
+					return
+				case _, ok := <-wi.ResultChan():
+					if !ok {
+						// The API server ends watches after a while: they
+						// are started again until the wait is over, the
+						// resources being checked again meanwhile.
+						var err error
+						if wi, err = w.watch(ctx, k, opts); err != nil {
+							if ctx.Err() == nil {
+								w.log("unable to watch %s again: %v", k.resource.Resource, err)
+							}
+							return
+						}
+					}
+					mu.Lock()
+					changed[k] = true
+					mu.Unlock()
+					select {
+					case notify <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}(k, wi)
+	}
+
+	start := time.Now()
+	timings := newWaitTimings(created)
+	pending := len(created)
+	check := func(ids []int) error {
+		for _, i := range ids {
+			if timings[i].Ready {
+				continue
+			}
+			ready, err := w.c.IsReady(ctx, created[i])
+			if err != nil {
+				return err
+			}
+			if ready {
+				timings[i].Ready, timings[i].Duration = true, time.Since(start)
+				pending--
+			}
+		}
+		return nil
+	}
+	all := make([]int, len(created))
+	for i := range all {
+		all[i] = i
+	}
+
+	resync := time.NewTicker(watchResyncPeriod)
+	defer resync.Stop()
+	err := check(all)
+	for err == nil && pending > 0 {
+		select {
+		case <-ctx.Done():
+			err = wait.ErrWaitTimeout
+		case <-notify:
+			mu.Lock()
+			keys := changed
+			changed = map[watchKey]bool{}
+			mu.Unlock()
+			for k := range keys {
+				if err = check(groups[k]); err != nil {
+					break
+				}
+			}
+		case <-resync.C:
+			err = check(all)
+		}
+	}
+	return finishWaitTimings(timings, start), err
+}
+
+// watch starts watching the resources of k.
+func (w *waiter) watch(ctx context.Context, k watchKey, opts metav1.ListOptions) (watch.Interface, error) {
+	if k.namespace == "" {
+		return w.dynamic.Resource(k.resource).Watch(ctx, opts)
+	}
+	return w.dynamic.Resource(k.resource).Namespace(k.namespace).Watch(ctx, opts)
+}
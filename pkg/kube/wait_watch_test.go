@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "github.com/open-hand/helm/pkg/kube"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func podInfo(pod *corev1.Pod) *resource.Info {
+	return &resource.Info{
+		Mapping: &meta.RESTMapping{
+			Resource:         podsResource,
+			GroupVersionKind: corev1.SchemeGroupVersion.WithKind("Pod"),
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Object:    pod.DeepCopy(),
+	}
+}
+
+func TestWatchForResources(t *testing.T) {
+	defer func(period time.Duration) { watchResyncPeriod = period }(watchResyncPeriod)
+	watchResyncPeriod = time.Hour
+
+	ready := newPodWithCondition("ready", corev1.ConditionTrue)
+	starting := newPodWithCondition("starting", corev1.ConditionFalse)
+	cs := fake.NewSimpleClientset(ready, starting)
+	dyn := dynamicfake.NewSimpleDynamicClient(scheme.Scheme, ready, starting)
+	w := waiter{
+		c:       NewReadyChecker(cs, t.Logf),
+		log:     t.Logf,
+		timeout: 10 * time.Second,
+		dynamic: dyn,
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		pod := newPodWithCondition("starting", corev1.ConditionTrue)
+		if _, err := cs.CoreV1().Pods(defaultNamespace).Update(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+			t.Error(err)
+			return
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		u.SetAPIVersion("v1")
+		u.SetKind("Pod")
+		if _, err := dyn.Resource(podsResource).Namespace(defaultNamespace).Update(context.Background(), u, metav1.UpdateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	start := time.Now()
+	timings, err := w.watchForResources(ResourceList{podInfo(ready), podInfo(starting)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to end as soon as the pod was ready, took %v", elapsed)
+	}
+	if len(timings) != 2 {
+		t.Fatalf("expected 2 timings, got %d", len(timings))
+	}
+	for _, timing := range timings {
+		if timing.Kind != "Pod" {
+			t.Errorf("expected the timing of a Pod, got %+v", timing)
+		}
+		if !timing.Ready {
+			t.Errorf("expected %s to be ready", timing.Name)
+		}
+	}
+	if timings[1].Name != "starting" || timings[1].Duration < timings[0].Duration {
+		t.Errorf("expected the starting pod to take longer to be ready, got %+v", timings)
+	}
+}
+
+func TestWatchForResourcesTimeout(t *testing.T) {
+	starting := newPodWithCondition("starting", corev1.ConditionFalse)
+	w := waiter{
+		c:       NewReadyChecker(fake.NewSimpleClientset(starting), t.Logf),
+		log:     t.Logf,
+		timeout: 200 * time.Millisecond,
+		dynamic: dynamicfake.NewSimpleDynamicClient(scheme.Scheme, starting),
+	}
+	timings, err := w.watchForResources(ResourceList{podInfo(starting)})
+	if err != wait.ErrWaitTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if len(timings) != 1 || timings[0].Ready || timings[0].Duration < w.timeout {
+		t.Errorf("expected the pod not to be ready for the whole wait, got %+v", timings)
+	}
+}
+
+func TestParseWaitStrategy(t *testing.T) {
+	for in, want := range map[string]WaitStrategy{"": WaitStrategyWatch, "watch": WaitStrategyWatch, "poll": WaitStrategyPoll} {
+		if got, err := ParseWaitStrategy(in); err != nil || got != want {
+			t.Errorf("ParseWaitStrategy(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseWaitStrategy("sleep"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
@@ -19,19 +19,35 @@ package lint // import "github.com/open-hand/helm/pkg/lint"
 import (
 	"path/filepath"
 
+	"github.com/open-hand/helm/pkg/chartutil"
+
 	"github.com/open-hand/helm/pkg/lint/rules"
 	"github.com/open-hand/helm/pkg/lint/support"
 )
 
 // All runs all of the available linters on the given base directory.
 func All(basedir string, values map[string]interface{}, namespace string, strict bool) support.Linter {
+	return AllWithAnalyzers(basedir, values, namespace, strict)
+}
+
+// AllWithAnalyzers runs all of the available linters on the given base
+// directory, then the analyzers on the objects rendered from the chart.
+func AllWithAnalyzers(basedir string, values map[string]interface{}, namespace string, strict bool, analyzers ...support.Analyzer) support.Linter {
+	return AllWithCapabilities(basedir, values, namespace, strict, nil, analyzers...)
+}
+
+// AllWithCapabilities runs all of the available linters on the given base
+// directory, rendering the chart with caps, such as the ones of a
+// capabilities file, instead of the default capabilities.
+func AllWithCapabilities(basedir string, values map[string]interface{}, namespace string, strict bool, caps *chartutil.Capabilities, analyzers ...support.Analyzer) support.Linter {
 	// Using abs path to get directory context
 	chartDir, _ := filepath.Abs(basedir)
 
 	linter := support.Linter{ChartDir: chartDir}
 	rules.Chartfile(&linter)
 	rules.ValuesWithOverrides(&linter, values)
-	rules.Templates(&linter, values, namespace, strict)
+	rules.TemplatesWithCapabilities(&linter, values, namespace, strict, caps)
 	rules.Dependencies(&linter)
+	rules.AnalyzeWithCapabilities(&linter, values, namespace, caps, analyzers...)
 	return linter
 }
@@ -0,0 +1,107 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package policy provides rule packs that the linter runs over the objects
+rendered from a chart, such as security best practices.
+*/
+package policy // import "github.com/open-hand/helm/pkg/lint/policy"
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+// packs are the rule packs by name.
+var packs = map[string]func() support.Analyzer{
+	"security": func() support.Analyzer { return Security{} },
+}
+
+// Names returns the names of the rule packs, sorted.
+func Names() []string {
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the rule pack with the given name.
+func Get(name string) (support.Analyzer, error) {
+	newPack, ok := packs[name]
+	if !ok {
+		return nil, errors.Errorf("unknown policy %q, must be one of: %s", name, strings.Join(Names(), ", "))
+	}
+	return newPack(), nil
+}
+
+// podSpec returns the pod spec of a workload, or nil if the object has none.
+func podSpec(m support.Manifest) map[string]interface{} {
+	var fields []string
+	switch m.Kind {
+	case "Pod":
+		fields = []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		fields = []string{"spec", "template", "spec"}
+	case "CronJob":
+		fields = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil
+	}
+	obj := m.Object
+	for _, f := range fields {
+		next, ok := obj[f].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		obj = next
+	}
+	return obj
+}
+
+// containers returns the containers and init containers of a pod spec.
+func containers(spec map[string]interface{}) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		list, _ := spec[field].([]interface{})
+		for _, c := range list {
+			if c, ok := c.(map[string]interface{}); ok {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+// lookup returns the value at the given path of fields in obj.
+func lookup(obj map[string]interface{}, fields ...string) (interface{}, bool) {
+	var v interface{} = obj
+	for _, f := range fields {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[f]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,116 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+// Identifiers of the rules of the security rule pack.
+const (
+	RunAsNonRootRule   = "security/run-as-non-root"
+	ResourceLimitsRule = "security/resource-limits"
+	ImageTagRule       = "security/image-tag"
+	NetworkPolicyRule  = "security/network-policy"
+)
+
+// Security is a rule pack enforcing security best practices on workloads:
+//
+//   - containers run as a non-root user
+//   - containers set CPU and memory limits
+//   - images are pinned to a tag other than "latest", or to a digest
+//   - charts deploying workloads include a NetworkPolicy
+type Security struct{}
+
+// Name returns the name of the rule pack.
+func (Security) Name() string {
+	return "security"
+}
+
+// Analyze checks the workloads in manifests.
+func (Security) Analyze(manifests []support.Manifest) []support.Message {
+	var messages []support.Message
+	report := func(severity int, m support.Manifest, rule, format string, args ...interface{}) {
+		subject := fmt.Sprintf("%s %q: ", m.Kind, m.Name)
+		messages = append(messages, support.NewMessage(severity, m.Path,
+			support.WithRuleID(rule, errors.New(subject+fmt.Sprintf(format, args...)))))
+	}
+
+	workloads := false
+	networkPolicy := false
+	for _, m := range manifests {
+		if m.Kind == "NetworkPolicy" {
+			networkPolicy = true
+		}
+		spec := podSpec(m)
+		if spec == nil {
+			continue
+		}
+		workloads = true
+
+		podNonRoot, _ := lookup(spec, "securityContext", "runAsNonRoot")
+		for _, c := range containers(spec) {
+			name, _ := c["name"].(string)
+
+			nonRoot, ok := lookup(c, "securityContext", "runAsNonRoot")
+			if !ok {
+				nonRoot = podNonRoot
+			}
+			if nonRoot != true {
+				report(support.WarningSev, m, RunAsNonRootRule, "container %q does not set securityContext.runAsNonRoot to true", name)
+			}
+
+			var missing []string
+			for _, resource := range []string{"cpu", "memory"} {
+				if _, ok := lookup(c, "resources", "limits", resource); !ok {
+					missing = append(missing, resource)
+				}
+			}
+			if len(missing) > 0 {
+				report(support.WarningSev, m, ResourceLimitsRule, "container %q does not set %s limits", name, strings.Join(missing, " and "))
+			}
+
+			image, _ := c["image"].(string)
+			if tag := imageTag(image); tag == "" || tag == "latest" {
+				report(support.WarningSev, m, ImageTagRule, "container %q uses image %q, which is not pinned to a tag other than latest or to a digest", name, image)
+			}
+		}
+	}
+
+	if workloads && !networkPolicy {
+		messages = append(messages, support.NewMessage(support.InfoSev, "templates/",
+			support.WithRuleID(NetworkPolicyRule, errors.New("the chart deploys workloads but no NetworkPolicy restricting their traffic"))))
+	}
+	return messages
+}
+
+// imageTag returns the tag of an image reference, or its digest if it has
+// one.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+func manifest(t *testing.T, path, content string) support.Manifest {
+	t.Helper()
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(content), &obj); err != nil {
+		t.Fatal(err)
+	}
+	m := support.Manifest{Path: path, Object: obj}
+	m.Kind, _ = obj["kind"].(string)
+	m.Name, _ = obj["metadata"].(map[string]interface{})["name"].(string)
+	return m
+}
+
+const secureDeployment = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      containers:
+      - name: web
+        image: nginx:1.21
+        resources:
+          limits:
+            cpu: 100m
+            memory: 128Mi
+`
+
+const insecureCronJob = `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          securityContext:
+            runAsNonRoot: true
+          initContainers:
+          - name: init
+            image: busybox@sha256:abcd
+            securityContext:
+              runAsNonRoot: false
+            resources:
+              limits:
+                cpu: 100m
+                memory: 128Mi
+          containers:
+          - name: backup
+            image: example.com:5000/backup
+            resources:
+              limits:
+                memory: 128Mi
+`
+
+const networkPolicy = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+`
+
+func rules(messages []support.Message) []string {
+	var ids []string
+	for _, m := range messages {
+		ids = append(ids, m.Path+" "+m.RuleID()+" "+support.SeverityString(m.Severity))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSecurity(t *testing.T) {
+	pack, err := Get("security")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := rules(pack.Analyze([]support.Manifest{
+		manifest(t, "templates/deployment.yaml", secureDeployment),
+		manifest(t, "templates/netpol.yaml", networkPolicy),
+	}))
+	if len(got) != 0 {
+		t.Errorf("expected no findings on a secure chart, got %v", got)
+	}
+
+	got = rules(pack.Analyze([]support.Manifest{
+		manifest(t, "templates/deployment.yaml", secureDeployment),
+		manifest(t, "templates/cronjob.yaml", insecureCronJob),
+	}))
+	want := []string{
+		"templates/ security/network-policy INFO",
+		"templates/cronjob.yaml security/image-tag WARNING",
+		"templates/cronjob.yaml security/resource-limits WARNING",
+		"templates/cronjob.yaml security/run-as-non-root WARNING",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected findings %v, got %v", want, got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	if _, err := Get("nope"); err == nil {
+		t.Error("expected an unknown policy to be rejected")
+	}
+	if !reflect.DeepEqual(Names(), []string{"security"}) {
+		t.Errorf("unexpected policies %v", Names())
+	}
+}
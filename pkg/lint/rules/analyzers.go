@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/engine"
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+// Analyze renders the chart in the Linter and runs the analyzers on the
+// rendered objects.
+//
+// Charts that fail to render are reported by Templates, so they are skipped
+// silently here.
+func Analyze(linter *support.Linter, values map[string]interface{}, namespace string, analyzers ...support.Analyzer) {
+	AnalyzeWithCapabilities(linter, values, namespace, nil, analyzers...)
+}
+
+// AnalyzeWithCapabilities is Analyze rendering the chart with caps, or the
+// default capabilities if caps is nil.
+func AnalyzeWithCapabilities(linter *support.Linter, values map[string]interface{}, namespace string, caps *chartutil.Capabilities, analyzers ...support.Analyzer) {
+	if len(analyzers) == 0 {
+		return
+	}
+	manifests, err := RenderManifestsWithCapabilities(linter.ChartDir, values, namespace, caps)
+	if err != nil {
+		return
+	}
+	for _, a := range analyzers {
+		for _, m := range a.Analyze(manifests) {
+			linter.AddMessage(m)
+		}
+	}
+}
+
+// RenderManifests renders the chart in chartDir the way the linter does, and
+// returns the rendered objects ordered by template.
+func RenderManifests(chartDir string, values map[string]interface{}, namespace string) ([]support.Manifest, error) {
+	return RenderManifestsWithCapabilities(chartDir, values, namespace, nil)
+}
+
+// RenderManifestsWithCapabilities is RenderManifests rendering the chart with
+// caps, or the default capabilities if caps is nil.
+func RenderManifestsWithCapabilities(chartDir string, values map[string]interface{}, namespace string, caps *chartutil.Capabilities) ([]support.Manifest, error) {
+	chart, err := loader.Load(chartDir)
+	if err != nil {
+		return nil, err
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      "test-release",
+		Namespace: namespace,
+	}
+	if err := chartutil.ProcessDependencies(chart, values); err != nil {
+		return nil, err
+	}
+	cvals, err := chartutil.CoalesceValues(chart, values)
+	if err != nil {
+		return nil, err
+	}
+	valuesToRender, err := chartutil.ToRenderValues(chart, cvals, options, caps)
+	if err != nil {
+		return nil, err
+	}
+	var e engine.Engine
+	e.LintMode = true
+	rendered, err := e.Render(chart, valuesToRender)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		ext := path.Ext(name)
+		if (ext == ".yaml" || ext == ".yml") && !strings.HasPrefix(path.Base(name), "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var manifests []support.Manifest
+	for _, name := range names {
+		// Report objects by the path of their template in the chart
+		tpath := strings.TrimPrefix(name, chart.Name()+"/")
+		decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(rendered[name]), 4096)
+		for {
+			var obj map[string]interface{}
+			if err := decoder.Decode(&obj); err == io.EOF {
+				break
+			} else if err != nil {
+				// Invalid YAML is reported by Templates
+				break
+			}
+			if obj == nil {
+				continue
+			}
+			m := support.Manifest{Path: tpath, Object: obj}
+			m.APIVersion, _ = obj["apiVersion"].(string)
+			m.Kind, _ = obj["kind"].(string)
+			if md, ok := obj["metadata"].(map[string]interface{}); ok {
+				m.Name, _ = md["name"].(string)
+				m.Namespace, _ = md["namespace"].(string)
+			}
+			manifests = append(manifests, m)
+		}
+	}
+	return manifests, nil
+}
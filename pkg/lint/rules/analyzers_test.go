@@ -0,0 +1,60 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+type nameAnalyzer struct{}
+
+func (nameAnalyzer) Name() string { return "name" }
+
+func (nameAnalyzer) Analyze(manifests []support.Manifest) []support.Message {
+	var messages []support.Message
+	for _, m := range manifests {
+		messages = append(messages, support.NewMessage(support.WarningSev, m.Path, support.WithRuleID("test/name", errors.New(m.Name))))
+	}
+	return messages
+}
+
+func TestAnalyze(t *testing.T) {
+	linter := support.Linter{ChartDir: "./testdata/goodone"}
+	Analyze(&linter, map[string]interface{}{"name": "Bar"}, namespace, nameAnalyzer{})
+
+	if len(linter.Messages) != 1 {
+		t.Fatalf("expected 1 message, got %v", linter.Messages)
+	}
+	m := linter.Messages[0]
+	if m.Path != "templates/goodone.yaml" || m.Err.Error() != "bar" || m.RuleID() != "test/name" {
+		t.Errorf("unexpected message %v (%s)", m, m.RuleID())
+	}
+	if linter.HighestSeverity != support.WarningSev {
+		t.Errorf("unexpected highest severity %d", linter.HighestSeverity)
+	}
+
+	// Charts that fail to render are reported by Templates
+	linter = support.Linter{ChartDir: templateTestBasedir}
+	Analyze(&linter, values, namespace, nameAnalyzer{})
+	if len(linter.Messages) != 0 {
+		t.Errorf("expected no message, got %v", linter.Messages)
+	}
+}
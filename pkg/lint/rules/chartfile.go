@@ -37,10 +37,10 @@ func Chartfile(linter *support.Linter) {
 	chartFileName := "Chart.yaml"
 	chartPath := filepath.Join(linter.ChartDir, chartFileName)
 
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartYamlNotDirectory(chartPath))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartYamlDirectoryRule, validateChartYamlNotDirectory(chartPath)))
 
 	chartFile, err := chartutil.LoadChartfile(chartPath)
-	validChartFile := linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartYamlFormat(err))
+	validChartFile := linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartYamlFormatRule, validateChartYamlFormat(err)))
 
 	// Guard clause. Following linter rules require a parsable ChartFile
 	if !validChartFile {
@@ -51,20 +51,20 @@ func Chartfile(linter *support.Linter) {
 	// errors would already be caught in the above load function
 	chartFileForTypeCheck, _ := loadChartFileForTypeCheck(chartPath)
 
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartName(chartFile))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartNameRule, validateChartName(chartFile)))
 
 	// Chart metadata
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartAPIVersion(chartFile))
-
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartVersionType(chartFileForTypeCheck))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartVersion(chartFile))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartAppVersionType(chartFileForTypeCheck))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartMaintainer(chartFile))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartSources(chartFile))
-	linter.RunLinterRule(support.InfoSev, chartFileName, validateChartIconPresence(chartFile))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartIconURL(chartFile))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartType(chartFile))
-	linter.RunLinterRule(support.ErrorSev, chartFileName, validateChartDependencies(chartFile))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartAPIVersionRule, validateChartAPIVersion(chartFile)))
+
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartVersionTypeRule, validateChartVersionType(chartFileForTypeCheck)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartVersionRule, validateChartVersion(chartFile)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartAppVersionTypeRule, validateChartAppVersionType(chartFileForTypeCheck)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartMaintainerRule, validateChartMaintainer(chartFile)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartSourcesRule, validateChartSources(chartFile)))
+	linter.RunLinterRule(support.InfoSev, chartFileName, support.WithRuleID(ChartIconRule, validateChartIconPresence(chartFile)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartIconURLRule, validateChartIconURL(chartFile)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartTypeRule, validateChartType(chartFile)))
+	linter.RunLinterRule(support.ErrorSev, chartFileName, support.WithRuleID(ChartDependenciesRule, validateChartDependencies(chartFile)))
 }
 
 func validateChartVersionType(data map[string]interface{}) error {
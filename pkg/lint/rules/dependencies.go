@@ -32,12 +32,12 @@ import (
 // See https://github.com/helm/helm/issues/7910
 func Dependencies(linter *support.Linter) {
 	c, err := loader.LoadDir(linter.ChartDir)
-	if !linter.RunLinterRule(support.ErrorSev, "", validateChartFormat(err)) {
+	if !linter.RunLinterRule(support.ErrorSev, "", support.WithRuleID(DependenciesFormatRule, validateChartFormat(err))) {
 		return
 	}
 
-	linter.RunLinterRule(support.ErrorSev, linter.ChartDir, validateDependencyInMetadata(c))
-	linter.RunLinterRule(support.WarningSev, linter.ChartDir, validateDependencyInChartsDir(c))
+	linter.RunLinterRule(support.ErrorSev, linter.ChartDir, support.WithRuleID(DependenciesMetadataRule, validateDependencyInMetadata(c)))
+	linter.RunLinterRule(support.WarningSev, linter.ChartDir, support.WithRuleID(DependenciesChartsRule, validateDependencyInChartsDir(c)))
 }
 
 func validateChartFormat(chartError error) error {
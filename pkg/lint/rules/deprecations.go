@@ -24,6 +24,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apiserver/pkg/endpoints/deprecation"
 	kscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/open-hand/helm/pkg/chartutil"
 )
 
 var (
@@ -46,6 +48,12 @@ func (e deprecatedAPIError) Error() string {
 }
 
 func validateNoDeprecations(resource *K8sYamlStruct) error {
+	return validateNoDeprecationsIn(resource, nil)
+}
+
+// validateNoDeprecationsIn checks for the APIs deprecated in the Kubernetes
+// version of caps, or of the client libraries if caps is nil.
+func validateNoDeprecationsIn(resource *K8sYamlStruct, caps *chartutil.Capabilities) error {
 	// if `resource` does not have an APIVersion or Kind, we cannot test it for deprecation
 	if resource.APIVersion == "" {
 		return nil
@@ -62,11 +70,15 @@ func validateNoDeprecations(resource *K8sYamlStruct) error {
 		}
 		return err
 	}
-	maj, err := strconv.Atoi(k8sVersionMajor)
+	major, minor := k8sVersionMajor, k8sVersionMinor
+	if caps != nil {
+		major, minor = caps.KubeVersion.Major, caps.KubeVersion.Minor
+	}
+	maj, err := strconv.Atoi(major)
 	if err != nil {
 		return err
 	}
-	min, err := strconv.Atoi(k8sVersionMinor)
+	min, err := strconv.Atoi(minor)
 	if err != nil {
 		return err
 	}
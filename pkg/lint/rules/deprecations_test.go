@@ -16,7 +16,11 @@ limitations under the License.
 
 package rules // import "github.com/open-hand/helm/pkg/lint/rules"
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/chartutil"
+)
 
 func TestValidateNoDeprecations(t *testing.T) {
 	deprecated := &K8sYamlStruct{
@@ -39,3 +43,23 @@ func TestValidateNoDeprecations(t *testing.T) {
 		t.Errorf("Expected a v1 Pod to not be deprecated")
 	}
 }
+
+func TestValidateNoDeprecationsIn(t *testing.T) {
+	psp := &K8sYamlStruct{
+		APIVersion: "policy/v1beta1",
+		Kind:       "PodSecurityPolicy",
+	}
+	caps := func(version string) *chartutil.Capabilities {
+		kv, err := chartutil.ParseKubeVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &chartutil.Capabilities{KubeVersion: *kv}
+	}
+	if err := validateNoDeprecationsIn(psp, caps("v1.20.0")); err != nil {
+		t.Errorf("Expected PodSecurityPolicy not to be deprecated in 1.20: %s", err)
+	}
+	if err := validateNoDeprecationsIn(psp, caps("v1.22.0")); err == nil {
+		t.Error("Expected PodSecurityPolicy to be deprecated in 1.22")
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+// Identifiers of the built-in rules. They are stable, so that findings can be
+// configured and suppressed by rule ID.
+const (
+	ChartYamlDirectoryRule  = "chart/yaml-not-directory"
+	ChartYamlFormatRule     = "chart/yaml-format"
+	ChartNameRule           = "chart/name"
+	ChartAPIVersionRule     = "chart/api-version"
+	ChartVersionTypeRule    = "chart/version-type"
+	ChartVersionRule        = "chart/version"
+	ChartAppVersionTypeRule = "chart/app-version-type"
+	ChartMaintainerRule     = "chart/maintainer"
+	ChartSourcesRule        = "chart/sources"
+	ChartIconRule           = "chart/icon"
+	ChartIconURLRule        = "chart/icon-url"
+	ChartTypeRule           = "chart/type"
+	ChartDependenciesRule   = "chart/dependencies"
+
+	ValuesFileRule          = "values/file"
+	ValuesSchemaRule        = "values/schema"
+	ValuesUnusedRule        = "values/unused"
+	ValuesUnusedDefaultRule = "values/unused-default"
+
+	TemplatesDirRule            = "templates/dir"
+	TemplatesLoadRule           = "templates/load"
+	TemplatesRenderRule         = "templates/render"
+	TemplatesExtensionRule      = "templates/extension"
+	TemplatesCRDHookRule        = "templates/crd-hook"
+	TemplatesReleaseTimeRule    = "templates/release-time"
+	TemplatesIndentRule         = "templates/indent"
+	TemplatesYamlRule           = "templates/yaml"
+	TemplatesMetadataNameRule   = "templates/metadata-name"
+	TemplatesDeprecatedAPIRule  = "templates/deprecated-api"
+	TemplatesSelectorRule       = "templates/match-selector"
+	TemplatesListAnnotationRule = "templates/list-annotations"
+
+	DependenciesFormatRule   = "dependencies/format"
+	DependenciesMetadataRule = "dependencies/metadata"
+	DependenciesChartsRule   = "dependencies/charts-dir"
+)
@@ -46,10 +46,18 @@ var (
 
 // Templates lints the templates in the Linter.
 func Templates(linter *support.Linter, values map[string]interface{}, namespace string, strict bool) {
+	TemplatesWithCapabilities(linter, values, namespace, strict, nil)
+}
+
+// TemplatesWithCapabilities lints the templates in the Linter, rendering them
+// with caps, such as the ones of a capabilities file, and checking for the
+// APIs deprecated in the Kubernetes version of caps. Nil caps are the
+// default capabilities.
+func TemplatesWithCapabilities(linter *support.Linter, values map[string]interface{}, namespace string, strict bool, caps *chartutil.Capabilities) {
 	fpath := "templates/"
 	templatesPath := filepath.Join(linter.ChartDir, fpath)
 
-	templatesDirExist := linter.RunLinterRule(support.WarningSev, fpath, validateTemplatesDir(templatesPath))
+	templatesDirExist := linter.RunLinterRule(support.WarningSev, fpath, support.WithRuleID(TemplatesDirRule, validateTemplatesDir(templatesPath)))
 
 	// Templates directory is optional for now
 	if !templatesDirExist {
@@ -59,7 +67,7 @@ func Templates(linter *support.Linter, values map[string]interface{}, namespace
 	// Load chart and parse templates
 	chart, err := loader.Load(linter.ChartDir)
 
-	chartLoaded := linter.RunLinterRule(support.ErrorSev, fpath, err)
+	chartLoaded := linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesLoadRule, err))
 
 	if !chartLoaded {
 		return
@@ -80,16 +88,16 @@ func Templates(linter *support.Linter, values map[string]interface{}, namespace
 	if err != nil {
 		return
 	}
-	valuesToRender, err := chartutil.ToRenderValues(chart, cvals, options, nil)
+	valuesToRender, err := chartutil.ToRenderValues(chart, cvals, options, caps)
 	if err != nil {
-		linter.RunLinterRule(support.ErrorSev, fpath, err)
+		linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesRenderRule, err))
 		return
 	}
 	var e engine.Engine
 	e.LintMode = true
 	renderedContentMap, err := e.Render(chart, valuesToRender)
 
-	renderOk := linter.RunLinterRule(support.ErrorSev, fpath, err)
+	renderOk := linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesRenderRule, err))
 
 	if !renderOk {
 		return
@@ -106,11 +114,11 @@ func Templates(linter *support.Linter, values map[string]interface{}, namespace
 		fileName, data := template.Name, template.Data
 		fpath = fileName
 
-		linter.RunLinterRule(support.ErrorSev, fpath, validateAllowedExtension(fileName))
+		linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesExtensionRule, validateAllowedExtension(fileName)))
 		// These are v3 specific checks to make sure and warn people if their
 		// chart is not compatible with v3
-		linter.RunLinterRule(support.WarningSev, fpath, validateNoCRDHooks(data))
-		linter.RunLinterRule(support.ErrorSev, fpath, validateNoReleaseTime(data))
+		linter.RunLinterRule(support.WarningSev, fpath, support.WithRuleID(TemplatesCRDHookRule, validateNoCRDHooks(data)))
+		linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesReleaseTimeRule, validateNoReleaseTime(data)))
 
 		// We only apply the following lint rules to yaml files
 		if filepath.Ext(fileName) != ".yaml" || filepath.Ext(fileName) == ".yml" {
@@ -126,7 +134,7 @@ func Templates(linter *support.Linter, values map[string]interface{}, namespace
 
 		renderedContent := renderedContentMap[path.Join(chart.Name(), fileName)]
 		if strings.TrimSpace(renderedContent) != "" {
-			linter.RunLinterRule(support.WarningSev, fpath, validateTopIndentLevel(renderedContent))
+			linter.RunLinterRule(support.WarningSev, fpath, support.WithRuleID(TemplatesIndentRule, validateTopIndentLevel(renderedContent)))
 
 			decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(renderedContent), 4096)
 
@@ -143,16 +151,16 @@ func Templates(linter *support.Linter, values map[string]interface{}, namespace
 
 				// If YAML linting fails, we sill progress. So we don't capture the returned state
 				// on this linter run.
-				linter.RunLinterRule(support.ErrorSev, fpath, validateYamlContent(err))
+				linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesYamlRule, validateYamlContent(err)))
 
 				if yamlStruct != nil {
 					// NOTE: set to warnings to allow users to support out-of-date kubernetes
 					// Refs https://github.com/helm/helm/issues/8596
-					linter.RunLinterRule(support.WarningSev, fpath, validateMetadataName(yamlStruct))
-					linter.RunLinterRule(support.WarningSev, fpath, validateNoDeprecations(yamlStruct))
+					linter.RunLinterRule(support.WarningSev, fpath, support.WithRuleID(TemplatesMetadataNameRule, validateMetadataName(yamlStruct)))
+					linter.RunLinterRule(support.WarningSev, fpath, support.WithRuleID(TemplatesDeprecatedAPIRule, validateNoDeprecationsIn(yamlStruct, caps)))
 
-					linter.RunLinterRule(support.ErrorSev, fpath, validateMatchSelector(yamlStruct, renderedContent))
-					linter.RunLinterRule(support.ErrorSev, fpath, validateListAnnotations(yamlStruct, renderedContent))
+					linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesSelectorRule, validateMatchSelector(yamlStruct, renderedContent)))
+					linter.RunLinterRule(support.ErrorSev, fpath, support.WithRuleID(TemplatesListAnnotationRule, validateListAnnotations(yamlStruct, renderedContent)))
 				}
 			}
 		}
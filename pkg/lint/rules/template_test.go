@@ -237,6 +237,9 @@ func TestDeprecatedAPIFails(t *testing.T) {
 		t.Fatalf("Expected 1 lint error, got %d", l)
 	}
 
+	if id := linter.Messages[0].RuleID(); id != TemplatesDeprecatedAPIRule {
+		t.Errorf("Expected rule %q, got %q", TemplatesDeprecatedAPIRule, id)
+	}
 	err := linter.Messages[0].Err.(deprecatedAPIError)
 	if err.Deprecated != "apps/v1beta1 Deployment" {
 		t.Errorf("Surprised to learn that %q is deprecated", err.Deprecated)
@@ -0,0 +1,90 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/engine"
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+// UnusedValues reports the values supplied by the user that no template of
+// the chart uses, such as misspelled keys, as warnings, and the default
+// values of the chart that no template uses as information.
+//
+// See chartutil.UnusedValues for how the values are found to be unused.
+func UnusedValues(linter *support.Linter, values map[string]interface{}, namespace string) {
+	chart, err := loader.Load(linter.ChartDir)
+	if err != nil {
+		// Reported by the templates rules.
+		return
+	}
+	defaults, err := chartutil.CoalesceValues(chart, map[string]interface{}{})
+	if err != nil {
+		return
+	}
+
+	options := chartutil.ReleaseOptions{
+		Name:      "test-release",
+		Namespace: namespace,
+	}
+	render := func(vals map[string]interface{}) (map[string]string, error) {
+		// The chart is loaded again since processing its dependencies
+		// removes the disabled ones.
+		chart, err := loader.Load(linter.ChartDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := chartutil.ProcessDependencies(chart, vals); err != nil {
+			return nil, err
+		}
+		cvals, err := chartutil.CoalesceValues(chart, vals)
+		if err != nil {
+			return nil, err
+		}
+		valuesToRender, err := chartutil.ToRenderValues(chart, cvals, options, nil)
+		if err != nil {
+			return nil, err
+		}
+		return engine.Render(chart, valuesToRender)
+	}
+
+	unused, err := chartutil.UnusedValues(values, values, render)
+	if err != nil {
+		// Reported by the templates rules.
+		return
+	}
+	reported := make(map[string]bool, len(unused))
+	for _, path := range unused {
+		reported[path] = true
+		linter.RunLinterRule(support.WarningSev, "values", support.WithRuleID(ValuesUnusedRule, errors.Errorf("value %q is not used by any template", path)))
+	}
+
+	unused, err = chartutil.UnusedValues(values, defaults, render)
+	if err != nil {
+		return
+	}
+	for _, path := range unused {
+		if reported[path] {
+			continue
+		}
+		linter.RunLinterRule(support.InfoSev, "values.yaml", support.WithRuleID(ValuesUnusedDefaultRule, errors.Errorf("default value %q is not used by any template", path)))
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/open-hand/helm/pkg/lint/support"
+)
+
+func TestUnusedValues(t *testing.T) {
+	linter := support.Linter{ChartDir: "./testdata/unusedvalues"}
+	values := map[string]interface{}{
+		"replicasCount": 3,
+		"port":          80,
+	}
+	UnusedValues(&linter, values, "default")
+
+	expect := []struct {
+		severity int
+		rule     string
+		text     string
+	}{
+		{support.WarningSev, ValuesUnusedRule, `value "replicasCount" is not used by any template`},
+		{support.InfoSev, ValuesUnusedDefaultRule, `default value "legacy" is not used by any template`},
+	}
+	if len(linter.Messages) != len(expect) {
+		t.Fatalf("expected %d messages, got %v", len(expect), linter.Messages)
+	}
+	for i, e := range expect {
+		msg := linter.Messages[i]
+		if msg.Severity != e.severity || msg.RuleID() != e.rule || msg.Err.Error() != e.text {
+			t.Errorf("expected message %d to be %v, got %v", i, e, msg)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package support
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Manifest is a Kubernetes object rendered from the templates of a chart.
+type Manifest struct {
+	// Path is the path of the template the object was rendered from.
+	Path       string
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	// Object is the content of the object.
+	Object map[string]interface{}
+}
+
+// Analyzer inspects the objects rendered from a chart, for instance to
+// enforce security best practices. Analyzers are run by the linter after the
+// built-in rules.
+type Analyzer interface {
+	// Name identifies the analyzer.
+	Name() string
+	// Analyze returns the findings of the analyzer on the given objects.
+	// Findings should report the rule that produced them with WithRuleID.
+	Analyze(manifests []Manifest) []Message
+}
+
+// RuleError is an error reported by a lint rule with a stable identifier.
+type RuleError struct {
+	RuleID string
+	Err    error
+}
+
+func (e *RuleError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error reported by the rule.
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the error reported by the rule, for errors.Cause.
+func (e *RuleError) Cause() error {
+	return e.Err
+}
+
+// WithRuleID annotates err with the identifier of the rule that reported it.
+// It returns nil if err is nil.
+func WithRuleID(id string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RuleError{RuleID: id, Err: err}
+}
+
+// RuleID returns the identifier of the rule that reported the message, or the
+// empty string if the rule has none.
+func (m Message) RuleID() string {
+	if m.ruleID != "" {
+		return m.ruleID
+	}
+	var err *RuleError
+	if errors.As(m.Err, &err) {
+		return err.RuleID
+	}
+	return ""
+}
+
+// AddMessage adds a message reported outside of RunLinterRule, such as by an
+// Analyzer, and returns true if it is valid.
+func (l *Linter) AddMessage(m Message) bool {
+	if m.ruleID != "" {
+		return l.RunLinterRule(m.Severity, m.Path, WithRuleID(m.ruleID, m.Err))
+	}
+	return l.RunLinterRule(m.Severity, m.Path, m.Err)
+}
+
+// SeverityString returns the name of a severity, such as "WARNING".
+func SeverityString(severity int) string {
+	if severity < 0 || severity >= len(sev) {
+		return sev[UnknownSev]
+	}
+	return sev[severity]
+}
+
+// ParseSeverity returns the severity with the given name, in any case.
+func ParseSeverity(name string) (int, error) {
+	for i, s := range sev {
+		if strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	return UnknownSev, errors.Errorf("invalid severity %q, must be one of: info, warning, error", name)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m Message) MarshalJSON() ([]byte, error) {
+	msg := ""
+	if m.Err != nil {
+		msg = m.Err.Error()
+	}
+	return json.Marshal(struct {
+		Severity string `json:"severity"`
+		Path     string `json:"path"`
+		RuleID   string `json:"ruleId,omitempty"`
+		Message  string `json:"message"`
+	}{SeverityString(m.Severity), m.Path, m.RuleID(), msg})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRuleID(t *testing.T) {
+	if WithRuleID("rule", nil) != nil {
+		t.Error("expected no error for a rule that passed")
+	}
+
+	m := NewMessage(WarningSev, "templates/pod.yaml", WithRuleID("security/image-tag", errors.New("Foo")))
+	if m.RuleID() != "security/image-tag" {
+		t.Errorf("unexpected rule ID %q", m.RuleID())
+	}
+	if m.Error() != "[WARNING] templates/pod.yaml: Foo" {
+		t.Errorf("Unexpected output: %s", m.Error())
+	}
+	// the error of the rule keeps its type
+	errFoo := &yamlError{"Foo"}
+	m = NewMessage(WarningSev, "templates/pod.yaml", WithRuleID("security/image-tag", errFoo))
+	if _, ok := m.Err.(*yamlError); !ok || m.RuleID() != "security/image-tag" {
+		t.Errorf("expected the error of the rule with its ID, got %T %q", m.Err, m.RuleID())
+	}
+	if errors.Cause(WithRuleID("security/image-tag", errFoo)) != errFoo {
+		t.Error("expected the cause of a rule error to be the error of the rule")
+	}
+	if id := NewMessage(ErrorSev, "Chart.yaml", errors.New("Bar")).RuleID(); id != "" {
+		t.Errorf("unexpected rule ID %q", id)
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"severity":"WARNING","path":"templates/pod.yaml","ruleId":"security/image-tag","message":"Foo"}` {
+		t.Errorf("Unexpected JSON: %s", b)
+	}
+}
+
+type yamlError struct{ msg string }
+
+func (e *yamlError) Error() string { return e.msg }
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("warning"); err != nil || s != WarningSev {
+		t.Errorf("unexpected severity %d: %v", s, err)
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("expected an unknown severity to be rejected")
+	}
+	if SeverityString(42) != "UNKNOWN" {
+		t.Errorf("unexpected name %q", SeverityString(42))
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSARIF(&buf, "v3", []ChartMessages{{
+		Chart: "mychart",
+		Messages: []Message{
+			NewMessage(ErrorSev, "templates/pod.yaml", WithRuleID("security/image-tag", errors.New("Foo"))),
+			NewMessage(InfoSev, "Chart.yaml", errors.New("Bar")),
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				Level     string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	run := log.Runs[0]
+	if log.Version != "2.1.0" || len(run.Tool.Driver.Rules) != 1 || len(run.Results) != 2 {
+		t.Fatalf("unexpected SARIF log: %s", buf.String())
+	}
+	r := run.Results[0]
+	if r.RuleID != "security/image-tag" || r.Level != "error" || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "mychart/templates/pod.yaml" {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if run.Results[1].Level != "note" {
+		t.Errorf("unexpected level %q", run.Results[1].Level)
+	}
+}
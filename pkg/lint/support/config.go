@@ -0,0 +1,209 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package support
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// ConfigFileName is the name of the lint configuration file of a chart.
+const ConfigFileName = ".helmlint.yaml"
+
+// Config configures the lint rules, for instance to standardize linting
+// across the charts of an organization. A chart can carry its own
+// configuration in a .helmlint.yaml file:
+//
+//	rules:
+//	  chart/icon:
+//	    disabled: true
+//	  security/*:
+//	    severity: error
+//	suppressions:
+//	  - rule: security/image-tag
+//	    path: templates/job.yaml
+//	    reason: the job always runs the image built with the chart
+type Config struct {
+	// Rules configure the rules by rule ID. Keys may be patterns, such as
+	// "security/*", in which case the first matching key in lexical order
+	// applies.
+	Rules map[string]RuleConfig `json:"rules,omitempty"`
+	// Suppressions are findings that are not reported.
+	Suppressions []Suppression `json:"suppressions,omitempty"`
+}
+
+// RuleConfig configures a lint rule.
+type RuleConfig struct {
+	// Disabled drops the findings of the rule.
+	Disabled bool `json:"disabled,omitempty"`
+	// Severity overrides the severity of the findings of the rule. It is one
+	// of "info", "warning" or "error".
+	Severity string `json:"severity,omitempty"`
+}
+
+// Suppression suppresses the findings of a rule on a path. Rule and Path
+// may be patterns, and match any rule or path when empty, but not both.
+type Suppression struct {
+	Rule string `json:"rule,omitempty"`
+	Path string `json:"path,omitempty"`
+	// Reason documents why the findings are suppressed.
+	Reason string `json:"reason,omitempty"`
+}
+
+// LoadConfig loads a lint configuration file.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(data)
+	return c, errors.Wrapf(err, "invalid lint configuration %s", filename)
+}
+
+// ParseConfig parses and validates a lint configuration.
+func ParseConfig(data []byte) (*Config, error) {
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	for id, rule := range c.Rules {
+		if _, err := path.Match(id, ""); err != nil {
+			return nil, errors.Wrapf(err, "rule %q", id)
+		}
+		if rule.Severity != "" {
+			if _, err := ParseSeverity(rule.Severity); err != nil {
+				return nil, errors.Wrapf(err, "rule %q", id)
+			}
+		}
+	}
+	for i, s := range c.Suppressions {
+		if s.Rule == "" && s.Path == "" {
+			return nil, errors.Errorf("suppression %d must set a rule or a path", i+1)
+		}
+		for _, pattern := range []string{s.Rule, s.Path} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.Wrapf(err, "suppression %d", i+1)
+			}
+		}
+	}
+	return c, nil
+}
+
+// Merge returns the configuration of c completed by the one of o, such as
+// the configuration of an organization completed by the one of a chart: the
+// rules of o only apply to the rule IDs that no rule of c matches, and the
+// suppressions of both apply. Either may be nil.
+func (c *Config) Merge(o *Config) *Config {
+	if c == nil {
+		return o
+	}
+	if o == nil {
+		return c
+	}
+	merged := &Config{Rules: make(map[string]RuleConfig, len(c.Rules)+len(o.Rules))}
+	for id, rule := range c.Rules {
+		merged.Rules[id] = rule
+	}
+	for id, rule := range o.Rules {
+		if _, ok := c.rule(id); !ok {
+			merged.Rules[id] = rule
+		}
+	}
+	merged.Suppressions = append(append(merged.Suppressions, c.Suppressions...), o.Suppressions...)
+	return merged
+}
+
+// Apply applies the configuration to the messages of the linter: the
+// findings of disabled rules and suppressed findings are dropped, and the
+// severities of the other findings are overridden. Messages of rules without
+// an ID can only be suppressed by path.
+func (c *Config) Apply(linter *Linter) {
+	if c == nil {
+		return
+	}
+	var messages []Message
+	highest := UnknownSev
+	for _, m := range linter.Messages {
+		id := m.RuleID()
+		if rule, ok := c.rule(id); ok {
+			if rule.Disabled {
+				continue
+			}
+			if rule.Severity != "" {
+				m.Severity, _ = ParseSeverity(rule.Severity)
+			}
+		}
+		if c.suppressed(id, m.Path) {
+			continue
+		}
+		messages = append(messages, m)
+		if m.Severity > highest {
+			highest = m.Severity
+		}
+	}
+	linter.Messages = messages
+	linter.HighestSeverity = highest
+}
+
+// rule returns the configuration of the rule with the given ID.
+func (c *Config) rule(id string) (RuleConfig, bool) {
+	if id == "" {
+		return RuleConfig{}, false
+	}
+	if rule, ok := c.Rules[id]; ok {
+		return rule, true
+	}
+	var match string
+	for pattern := range c.Rules {
+		if ok, _ := path.Match(pattern, id); ok && (match == "" || pattern < match) {
+			match = pattern
+		}
+	}
+	if match == "" {
+		return RuleConfig{}, false
+	}
+	return c.Rules[match], true
+}
+
+// suppressed returns true if a finding of the rule on the path is
+// suppressed.
+func (c *Config) suppressed(id, p string) bool {
+	for _, s := range c.Suppressions {
+		if s.Rule != "" && (id == "" || !match(s.Rule, id)) {
+			continue
+		}
+		if s.Path != "" && !match(s.Path, p) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// match returns true if name matches pattern, or is below the directory
+// pattern ends with.
+func match(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
@@ -0,0 +1,132 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package support
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", "rules:\n  chart/icon:\n    severity: error\nsuppressions:\n  - path: templates/\n", ""},
+		{"unknown field", "rule:\n  chart/icon: {}\n", "unknown field"},
+		{"invalid severity", "rules:\n  chart/icon:\n    severity: fatal\n", `invalid severity "fatal"`},
+		{"invalid pattern", "rules:\n  \"chart/[\":\n    disabled: true\n", "syntax error in pattern"},
+		{"empty suppression", "suppressions:\n  - reason: nothing\n", "suppression 1 must set a rule or a path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	c, err := ParseConfig([]byte(`
+rules:
+  chart/icon:
+    disabled: true
+  security/*:
+    severity: error
+  security/network-policy:
+    severity: info
+suppressions:
+  - rule: security/image-tag
+    path: templates/job.yaml
+  - path: templates/tests/
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linter := Linter{}
+	linter.RunLinterRule(InfoSev, "Chart.yaml", WithRuleID("chart/icon", errors.New("icon is recommended")))
+	linter.RunLinterRule(WarningSev, "templates/pod.yaml", WithRuleID("security/image-tag", errors.New("latest")))
+	linter.RunLinterRule(WarningSev, "templates/job.yaml", WithRuleID("security/image-tag", errors.New("latest")))
+	linter.RunLinterRule(InfoSev, "templates/", WithRuleID("security/network-policy", errors.New("no policy")))
+	linter.RunLinterRule(ErrorSev, "templates/tests/test.yaml", errors.New("invalid"))
+	linter.RunLinterRule(WarningSev, "values.yaml", errors.New("no rule"))
+
+	c.Apply(&linter)
+
+	want := []struct {
+		severity int
+		path     string
+	}{
+		{ErrorSev, "templates/pod.yaml"},
+		{InfoSev, "templates/"},
+		{WarningSev, "values.yaml"},
+	}
+	if len(linter.Messages) != len(want) {
+		t.Fatalf("expected %d messages, got %v", len(want), linter.Messages)
+	}
+	for i, w := range want {
+		if m := linter.Messages[i]; m.Severity != w.severity || m.Path != w.path {
+			t.Errorf("message %d: expected %s on %s, got %s", i, SeverityString(w.severity), w.path, m)
+		}
+	}
+	if linter.HighestSeverity != ErrorSev {
+		t.Errorf("expected the highest severity to be ERROR, got %s", SeverityString(linter.HighestSeverity))
+	}
+}
+
+func TestConfigMerge(t *testing.T) {
+	org := &Config{
+		Rules:        map[string]RuleConfig{"chart/icon": {Disabled: true}, "chart/name": {Severity: "warning"}, "security/*": {Severity: "error"}},
+		Suppressions: []Suppression{{Rule: "security/image-tag"}},
+	}
+	chart := &Config{
+		Rules:        map[string]RuleConfig{"chart/icon": {Severity: "error"}, "security/run-as-root": {Disabled: true}, "values/*": {Disabled: true}},
+		Suppressions: []Suppression{{Path: "templates/"}},
+	}
+
+	merged := org.Merge(chart)
+	if rule := merged.Rules["chart/icon"]; !rule.Disabled || rule.Severity != "" {
+		t.Errorf("expected the given rule to override the one of the chart, got %+v", rule)
+	}
+	if rule := merged.Rules["chart/name"]; rule.Severity != "warning" {
+		t.Errorf("expected the given rule to be kept, got %+v", rule)
+	}
+	if _, ok := merged.Rules["security/run-as-root"]; ok {
+		t.Error("expected the rule of the chart matched by a given pattern to be dropped")
+	}
+	if rule := merged.Rules["values/*"]; !rule.Disabled {
+		t.Errorf("expected the rule of the chart the given ones do not cover to be kept, got %+v", rule)
+	}
+	if len(merged.Suppressions) != 2 {
+		t.Errorf("expected 2 suppressions, got %d", len(merged.Suppressions))
+	}
+	if (*Config)(nil).Merge(chart) != chart || org.Merge(nil) != org {
+		t.Error("expected merging with nil to return the other configuration")
+	}
+}
@@ -47,14 +47,22 @@ type Message struct {
 	Severity int
 	Path     string
 	Err      error
+
+	// ruleID is the identifier of the rule that reported Err.
+	ruleID string
 }
 
 func (m Message) Error() string {
 	return fmt.Sprintf("[%s] %s: %s", sev[m.Severity], m.Path, m.Err.Error())
 }
 
-// NewMessage creates a new Message struct
+// NewMessage creates a new Message struct. The identifier of an error
+// annotated with WithRuleID is kept on the message and Err is the error of
+// the rule itself, so that it can still be asserted to its type.
 func NewMessage(severity int, path string, err error) Message {
+	if re, ok := err.(*RuleError); ok {
+		return Message{Severity: severity, Path: path, Err: re.Err, ruleID: re.RuleID}
+	}
 	return Message{Severity: severity, Path: path, Err: err}
 }
 
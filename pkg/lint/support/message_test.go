@@ -63,17 +63,17 @@ func TestRunLinterRule(t *testing.T) {
 }
 
 func TestMessage(t *testing.T) {
-	m := Message{ErrorSev, "Chart.yaml", errors.New("Foo")}
+	m := Message{Severity: ErrorSev, Path: "Chart.yaml", Err: errors.New("Foo")}
 	if m.Error() != "[ERROR] Chart.yaml: Foo" {
 		t.Errorf("Unexpected output: %s", m.Error())
 	}
 
-	m = Message{WarningSev, "templates/", errors.New("Bar")}
+	m = Message{Severity: WarningSev, Path: "templates/", Err: errors.New("Bar")}
 	if m.Error() != "[WARNING] templates/: Bar" {
 		t.Errorf("Unexpected output: %s", m.Error())
 	}
 
-	m = Message{InfoSev, "templates/rc.yaml", errors.New("FooBar")}
+	m = Message{Severity: InfoSev, Path: "templates/rc.yaml", Err: errors.New("FooBar")}
 	if m.Error() != "[INFO] templates/rc.yaml: FooBar" {
 		t.Errorf("Unexpected output: %s", m.Error())
 	}
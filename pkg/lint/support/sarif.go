@@ -0,0 +1,129 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package support
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+)
+
+// ChartMessages are the messages of the linting of a chart.
+type ChartMessages struct {
+	// Chart is the path of the chart that was linted.
+	Chart    string
+	Messages []Message
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+// sarifLevels maps severities to SARIF result levels.
+var sarifLevels = []string{"none", "note", "warning", "error"}
+
+// WriteSARIF writes the messages of the linting of charts in the Static
+// Analysis Results Interchange Format (SARIF) 2.1.0, which code scanning
+// services consume.
+func WriteSARIF(out io.Writer, version string, charts []ChartMessages) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "helm lint",
+			Version:        version,
+			InformationURI: "https://helm.sh/docs/helm/helm_lint/",
+		}},
+		Results: []sarifResult{},
+	}
+	rules := map[string]bool{}
+	for _, c := range charts {
+		for _, m := range c.Messages {
+			r := sarifResult{
+				RuleID: m.RuleID(),
+				Level:  sarifLevels[UnknownSev],
+			}
+			if m.Severity >= 0 && m.Severity < len(sarifLevels) {
+				r.Level = sarifLevels[m.Severity]
+			}
+			if m.Err != nil {
+				r.Message.Text = m.Err.Error()
+			}
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = path.Join(c.Chart, m.Path)
+			r.Locations = []sarifLocation{loc}
+			run.Results = append(run.Results, r)
+			if r.RuleID != "" {
+				rules[r.RuleID] = true
+			}
+		}
+	}
+	for id := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: id})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,311 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package logging provides the leveled logger of Helm.
+
+Services embedding Helm implement Logger to route the logs of the actions
+into their own logging pipeline, along with the fields identifying the
+release, its namespace and its chart.
+*/
+package logging // import "github.com/open-hand/helm/pkg/logging"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	// DebugLevel logs the details of the operations.
+	DebugLevel Level = iota
+	// InfoLevel logs the progress of the operations.
+	InfoLevel
+	// WarnLevel logs the unexpected situations an operation recovers from.
+	WarnLevel
+	// ErrorLevel logs the failures of the operations.
+	ErrorLevel
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < DebugLevel || l > ErrorLevel {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return WarnLevel, nil
+	}
+	return 0, errors.Errorf("invalid log level %q, must be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// Format is the format of the log entries written by a Logger created with
+// New.
+type Format string
+
+const (
+	// TextFormat writes the entries as human readable lines.
+	TextFormat Format = "text"
+	// JSONFormat writes the entries as JSON objects, one per line.
+	JSONFormat Format = "json"
+)
+
+// ParseFormat parses the name of a format.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case TextFormat:
+		return TextFormat, nil
+	case JSONFormat:
+		return JSONFormat, nil
+	}
+	return "", errors.Errorf("invalid log format %q, must be one of %s, %s", s, TextFormat, JSONFormat)
+}
+
+// Common field names.
+const (
+	ReleaseField   = "release"
+	NamespaceField = "namespace"
+	ChartField     = "chart"
+	RevisionField  = "revision"
+)
+
+// Logger is a leveled logger. The key-value pairs passed to its methods add
+// fields to the entry, e.g. logger.Info("release installed", "release", name).
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	// With returns a logger adding the fields to all entries.
+	With(keysAndValues ...interface{}) Logger
+}
+
+// New returns a logger writing the entries of the level and above to out in
+// the format.
+func New(out io.Writer, level Level, format Format) Logger {
+	return &logger{
+		w:     &writer{out: out, format: format, now: time.Now},
+		level: level,
+	}
+}
+
+// Nop returns a logger discarding all entries.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+// Printf returns a printf-style function logging at debug level, for the
+// packages taking such a function as their logger.
+func Printf(l Logger) func(format string, v ...interface{}) {
+	return func(format string, v ...interface{}) {
+		l.Debug(fmt.Sprintf(format, v...))
+	}
+}
+
+// FromPrintf returns a logger writing all entries with a printf-style
+// function, the fields appended to the message.
+func FromPrintf(printf func(format string, v ...interface{})) Logger {
+	if printf == nil {
+		return Nop()
+	}
+	return &printfLogger{printf: printf}
+}
+
+// writer writes the entries of the loggers derived from the same logger.
+type writer struct {
+	mu     sync.Mutex
+	out    io.Writer
+	format Format
+	now    func() time.Time
+}
+
+type logger struct {
+	w      *writer
+	level  Level
+	fields []interface{}
+}
+
+func (l *logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(DebugLevel, msg, keysAndValues)
+}
+
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(InfoLevel, msg, keysAndValues)
+}
+
+func (l *logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(WarnLevel, msg, keysAndValues)
+}
+
+func (l *logger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(ErrorLevel, msg, keysAndValues)
+}
+
+func (l *logger) With(keysAndValues ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return &logger{w: l.w, level: l.level, fields: fields}
+}
+
+func (l *logger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < l.level {
+		return
+	}
+	fields := append(append([]interface{}{}, l.fields...), keysAndValues...)
+
+	l.w.mu.Lock()
+	defer l.w.mu.Unlock()
+	var line []byte
+	if l.w.format == JSONFormat {
+		line = jsonEntry(l.w.now(), level, msg, fields)
+	} else {
+		line = textEntry(level, msg, fields)
+	}
+	l.w.out.Write(line)
+}
+
+// textEntry formats an entry the way the Helm CLI prints its messages, the
+// fields appended as key=value pairs.
+func textEntry(level Level, msg string, fields []interface{}) []byte {
+	var b strings.Builder
+	switch level {
+	case DebugLevel:
+		b.WriteString("[debug] ")
+	case WarnLevel:
+		b.WriteString("WARNING: ")
+	case ErrorLevel:
+		b.WriteString("Error: ")
+	}
+	b.WriteString(strings.TrimSuffix(msg, "\n"))
+	b.WriteString(formatFields(fields))
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+func jsonEntry(now time.Time, level Level, msg string, fields []interface{}) []byte {
+	entry := map[string]interface{}{}
+	for i := 0; i < len(fields); i += 2 {
+		key, value := fieldAt(fields, i)
+		entry[key] = jsonValue(value)
+	}
+	entry["time"] = now.UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = strings.TrimSuffix(msg, "\n")
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{
+			"time":  now.UTC().Format(time.RFC3339Nano),
+			"level": level.String(),
+			"msg":   msg,
+			"error": err.Error(),
+		})
+	}
+	return append(b, '\n')
+}
+
+// jsonValue returns the value to encode for a field, errors and other values
+// without a JSON representation being encoded as strings.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func formatFields(fields []interface{}) string {
+	var b strings.Builder
+	for i := 0; i < len(fields); i += 2 {
+		key, value := fieldAt(fields, i)
+		s := fmt.Sprint(value)
+		if strings.ContainsAny(s, " \t\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		fmt.Fprintf(&b, " %s=%s", key, s)
+	}
+	return b.String()
+}
+
+// fieldAt returns the field at index i of the key-value pairs. A missing
+// value is logged as such.
+func fieldAt(fields []interface{}, i int) (string, interface{}) {
+	key := fmt.Sprint(fields[i])
+	if i+1 >= len(fields) {
+		return key, "(MISSING)"
+	}
+	return key, fields[i+1]
+}
+
+type printfLogger struct {
+	printf func(format string, v ...interface{})
+	fields []interface{}
+}
+
+func (l *printfLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(msg, keysAndValues)
+}
+
+func (l *printfLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(msg, keysAndValues)
+}
+
+func (l *printfLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log("warning: "+msg, keysAndValues)
+}
+
+func (l *printfLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log("error: "+msg, keysAndValues)
+}
+
+func (l *printfLogger) With(keysAndValues ...interface{}) Logger {
+	fields := append(append([]interface{}{}, l.fields...), keysAndValues...)
+	return &printfLogger{printf: l.printf, fields: fields}
+}
+
+func (l *printfLogger) log(msg string, keysAndValues []interface{}) {
+	fields := append(append([]interface{}{}, l.fields...), keysAndValues...)
+	l.printf("%s%s", msg, formatFields(fields))
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+func (n nopLogger) With(...interface{}) Logger { return n }
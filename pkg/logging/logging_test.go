@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"debug": DebugLevel, "INFO": InfoLevel, "warn": WarnLevel, "warning": WarnLevel, "error": ErrorLevel} {
+		got, err := ParseLevel(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ParseLevel(%q) = %s, want %s", s, got, want)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("expected an error for an invalid level")
+	}
+}
+
+func TestTextLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, InfoLevel, TextFormat).With(ReleaseField, "foo")
+
+	l.Debug("hidden")
+	l.Info("release installed", NamespaceField, "default")
+	l.Warn("slow", "duration", "5 s")
+	l.Error("failed", "err", errors.New("boom"), "dangling")
+
+	expect := `release installed release=foo namespace=default
+WARNING: slow release=foo duration="5 s"
+Error: failed release=foo err=boom dangling=(MISSING)
+`
+	if out.String() != expect {
+		t.Errorf("expected\n%s\ngot\n%s", expect, out.String())
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, DebugLevel, JSONFormat)
+	l.(*logger).w.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	l.With(ReleaseField, "foo", RevisionField, 2).Debug("upgrading\n", "err", errors.New("boom"))
+
+	expect := `{"err":"boom","level":"debug","msg":"upgrading","release":"foo","revision":2,"time":"2020-01-02T03:04:05Z"}` + "\n"
+	if out.String() != expect {
+		t.Errorf("expected %s, got %s", expect, out.String())
+	}
+}
+
+func TestFromPrintf(t *testing.T) {
+	var out bytes.Buffer
+	l := FromPrintf(func(format string, v ...interface{}) {
+		fmt.Fprintf(&out, format+"\n", v...)
+	})
+	l.With(ReleaseField, "foo").Warn("slow")
+	Printf(l)("%d resources", 3)
+
+	expect := "warning: slow release=foo\n3 resources\n"
+	if out.String() != expect {
+		t.Errorf("expected %q, got %q", expect, out.String())
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/open-hand/helm/pkg/plugin"
+	"github.com/open-hand/helm/pkg/registry"
 )
 
 // ErrMissingMetadata indicates that plugin.yaml is missing.
@@ -65,6 +66,9 @@ func Update(i Installer) error {
 
 // NewForSource determines the correct Installer for the given source.
 func NewForSource(source, version string) (Installer, error) {
+	if strings.HasPrefix(source, registry.OCIScheme+"://") {
+		return NewOCIInstaller(source, version, nil)
+	}
 	// Check if source is a local directory
 	if isLocalReference(source) {
 		return NewLocalInstaller(source)
@@ -0,0 +1,119 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer // import "github.com/open-hand/helm/pkg/plugin/installer"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// LockFileName is the name of the lock file of the installed plugins, in
+// the plugins directory.
+const LockFileName = "plugins.lock"
+
+// Lock records the installed plugins, for the same versions to be
+// installed elsewhere with 'helm plugin install --from-lock'.
+type Lock struct {
+	Plugins []*LockedPlugin `json:"plugins"`
+}
+
+// LockedPlugin is an installed plugin.
+type LockedPlugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Source is the source the plugin was installed from.
+	Source string `json:"source"`
+	// Digest is the digest of the manifest of the plugins installed from
+	// OCI registries.
+	Digest string `json:"digest,omitempty"`
+}
+
+// LockPath returns the path of the lock file of the plugins directories,
+// in the first of them.
+func LockPath(pluginsDirectory string) string {
+	return filepath.Join(filepath.SplitList(pluginsDirectory)[0], LockFileName)
+}
+
+// LoadLock loads a lock file. A missing file is an empty lock.
+func LoadLock(path string) (*Lock, error) {
+	l := &Lock{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(data, l); err != nil {
+		return nil, errors.Wrapf(err, "invalid plugin lock file %s", path)
+	}
+	return l, nil
+}
+
+// Get returns the locked plugin of the given name, or nil.
+func (l *Lock) Get(name string) *LockedPlugin {
+	for _, p := range l.Plugins {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Set records a plugin, replacing the one of the same name.
+func (l *Lock) Set(p *LockedPlugin) {
+	l.Remove(p.Name)
+	l.Plugins = append(l.Plugins, p)
+	sort.Slice(l.Plugins, func(i, j int) bool { return l.Plugins[i].Name < l.Plugins[j].Name })
+}
+
+// Remove removes the plugin of the given name.
+func (l *Lock) Remove(name string) {
+	plugins := l.Plugins[:0]
+	for _, p := range l.Plugins {
+		if p.Name != name {
+			plugins = append(plugins, p)
+		}
+	}
+	l.Plugins = plugins
+}
+
+// Save writes the lock file.
+func (l *Lock) Save(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// PinnedSource returns the source installing the locked plugin, pinned to
+// its digest for the plugins installed from OCI registries.
+func (p *LockedPlugin) PinnedSource() string {
+	if p.Digest == "" {
+		return p.Source
+	}
+	return strings.SplitN(p.Source, "@", 2)[0] + "@" + p.Digest
+}
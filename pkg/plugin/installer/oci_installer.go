@@ -0,0 +1,149 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer // import "github.com/open-hand/helm/pkg/plugin/installer"
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/third_party/dep/fs"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/helmpath"
+	"github.com/open-hand/helm/pkg/plugin/cache"
+	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/registry"
+)
+
+// pluginPuller pulls plugins from registries.
+type pluginPuller interface {
+	PullPlugin(ref string) (*registry.PluginPullResult, error)
+}
+
+// OCIInstaller installs plugins published as OCI artifacts, e.g.
+// "oci://registry.example.com/plugins/diff:3.1.0".
+type OCIInstaller struct {
+	CacheDir   string
+	PluginName string
+	base
+	// Digest pins the digest of the manifest of the plugin. It is set from
+	// a reference ending in "@sha256:...", and to the digest of the plugin
+	// pulled once installed.
+	Digest string
+	// Verifier, when set, requires the plugin to have a cosign signature
+	// it verifies.
+	Verifier provenance.SignatureVerifier
+
+	ref    string
+	client pluginPuller
+}
+
+// NewOCIInstaller creates a new OCIInstaller pulling the plugin with client,
+// or with a registry client using the registry config file if nil. The
+// version is the tag of the plugin when source has neither a tag nor a
+// digest.
+func NewOCIInstaller(source, version string, client *registry.Client) (*OCIInstaller, error) {
+	ref := strings.TrimPrefix(source, registry.OCIScheme+"://")
+	var digest string
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		ref, digest = ref[:idx], ref[idx+1:]
+	}
+	repository := ref
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		repository = ref[:idx]
+	} else if version != "" && digest == "" {
+		ref += ":" + version
+	}
+	if digest != "" {
+		ref = repository + "@" + digest
+	}
+
+	key, err := cache.Key(source)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client, err = registry.NewClient(registry.ClientOptCredentialsFile(cli.New().RegistryConfig))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &OCIInstaller{
+		CacheDir:   helmpath.CachePath("plugins", key),
+		PluginName: path.Base(repository),
+		base:       newBase(source),
+		Digest:     digest,
+		ref:        ref,
+		client:     client,
+	}, nil
+}
+
+// Install pulls the plugin, checks its digest and signature, and installs
+// it into the plugin directory.
+//
+// Implements Installer.
+func (i *OCIInstaller) Install() error {
+	result, err := i.client.PullPlugin(i.ref)
+	if err != nil {
+		return err
+	}
+	if i.Digest != "" && result.Digest != i.Digest {
+		return errors.Errorf("plugin %s has digest %s, expected %s", i.ref, result.Digest, i.Digest)
+	}
+	if i.Verifier != nil {
+		if len(result.Signatures) == 0 {
+			return errors.Errorf("plugin %s is not signed", i.ref)
+		}
+		if _, err := i.Verifier.VerifyManifest(result.Digest, result.Signatures); err != nil {
+			return errors.Wrapf(err, "failed to verify the signature of plugin %s", i.ref)
+		}
+	}
+	i.Digest = result.Digest
+
+	if err := os.RemoveAll(i.CacheDir); err != nil {
+		return err
+	}
+	if err := (&TarGzExtractor{}).Extract(bytes.NewBuffer(result.Data), i.CacheDir); err != nil {
+		return errors.Wrap(err, "extracting files from archive")
+	}
+	if !isPlugin(i.CacheDir) {
+		return ErrMissingMetadata
+	}
+	src, err := filepath.Abs(i.CacheDir)
+	if err != nil {
+		return err
+	}
+	debug("copying %s to %s", src, i.Path())
+	return fs.CopyDir(src, i.Path())
+}
+
+// Update is not supported, since the plugins are published by version.
+func (i *OCIInstaller) Update() error {
+	return errors.Errorf("method Update() not implemented for OCIInstaller")
+}
+
+// Path is overridden to join on the plugin name, the last element of the
+// repository of the plugin.
+func (i OCIInstaller) Path() string {
+	if i.base.Source == "" {
+		return ""
+	}
+	return filepath.Join(i.PluginsDirectory, i.PluginName)
+}
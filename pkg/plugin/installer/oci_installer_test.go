@@ -0,0 +1,166 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer // import "github.com/open-hand/helm/pkg/plugin/installer"
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/test/ensure"
+	"github.com/open-hand/helm/pkg/provenance"
+	"github.com/open-hand/helm/pkg/registry"
+)
+
+var _ Installer = new(OCIInstaller)
+
+type fakePuller struct {
+	result *registry.PluginPullResult
+	ref    string
+}
+
+func (f *fakePuller) PullPlugin(ref string) (*registry.PluginPullResult, error) {
+	f.ref = ref
+	return f.result, nil
+}
+
+type rejectingVerifier struct{ provenance.SignatureVerifier }
+
+func (rejectingVerifier) VerifyManifest(digest string, sigs []*provenance.Signature) (*provenance.SignatureVerification, error) {
+	return nil, errors.New("no signature matches")
+}
+
+func pluginArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	data := []byte("name: diff\nversion: 3.1.0\n")
+	if err := tw.WriteHeader(&tar.Header{Name: "plugin.yaml", Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(data)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestNewOCIInstaller(t *testing.T) {
+	defer ensure.HelmHome(t)()
+
+	tests := []struct {
+		source  string
+		version string
+		ref     string
+		digest  string
+	}{
+		{"oci://example.com/plugins/diff", "3.1.0", "example.com/plugins/diff:3.1.0", ""},
+		{"oci://example.com/plugins/diff:3.0.0", "3.1.0", "example.com/plugins/diff:3.0.0", ""},
+		{"oci://localhost:5000/plugins/diff", "", "localhost:5000/plugins/diff", ""},
+		{"oci://example.com/plugins/diff:3.1.0@sha256:abc", "", "example.com/plugins/diff@sha256:abc", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		i, err := NewOCIInstaller(tt.source, tt.version, &registry.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i.ref != tt.ref || i.Digest != tt.digest || i.PluginName != "diff" {
+			t.Errorf("%s: unexpected ref %q, digest %q and name %q", tt.source, i.ref, i.Digest, i.PluginName)
+		}
+	}
+}
+
+func TestOCIInstaller(t *testing.T) {
+	defer ensure.HelmHome(t)()
+
+	pull := &registry.PluginPullResult{Digest: "sha256:1234", Data: pluginArchive(t)}
+	tests := []struct {
+		name     string
+		source   string
+		verifier provenance.SignatureVerifier
+		wantErr  string
+	}{
+		{name: "digest mismatch", source: "oci://example.com/plugins/diff@sha256:5678", wantErr: "expected sha256:5678"},
+		{name: "unsigned", source: "oci://example.com/plugins/diff:3.1.0", verifier: rejectingVerifier{}, wantErr: "is not signed"},
+		{name: "pinned", source: "oci://example.com/plugins/diff@sha256:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := NewOCIInstaller(tt.source, "", &registry.Client{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			i.PluginsDirectory = t.TempDir()
+			i.client = &fakePuller{result: pull}
+			i.Verifier = tt.verifier
+
+			err = Install(i)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i.Digest != "sha256:1234" {
+				t.Errorf("expected digest sha256:1234, got %q", i.Digest)
+			}
+			if _, err := os.Stat(filepath.Join(i.PluginsDirectory, "diff", "plugin.yaml")); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFileName)
+	lock, err := LoadLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Set(&LockedPlugin{Name: "diff", Version: "3.0.0", Source: "oci://example.com/plugins/diff:3.0.0", Digest: "sha256:1234"})
+	lock.Set(&LockedPlugin{Name: "secrets", Version: "1.0.0", Source: "https://github.com/example/helm-secrets"})
+	lock.Set(&LockedPlugin{Name: "diff", Version: "3.1.0", Source: "oci://example.com/plugins/diff:3.1.0", Digest: "sha256:5678"})
+	if err := lock.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = LoadLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Plugins) != 2 || lock.Plugins[0].Name != "diff" || lock.Plugins[0].Version != "3.1.0" {
+		t.Fatalf("unexpected plugins %v", lock.Plugins)
+	}
+	if got := lock.Get("diff").PinnedSource(); got != "oci://example.com/plugins/diff:3.1.0@sha256:5678" {
+		t.Errorf("unexpected pinned source %q", got)
+	}
+	if got := lock.Get("secrets").PinnedSource(); got != "https://github.com/example/helm-secrets" {
+		t.Errorf("unexpected pinned source %q", got)
+	}
+	lock.Remove("diff")
+	if lock.Get("diff") != nil || len(lock.Plugins) != 1 {
+		t.Errorf("expected diff to be removed, got %v", lock.Plugins)
+	}
+}
@@ -41,6 +41,14 @@ type Downloaders struct {
 	// Command is the executable path with which the plugin performs
 	// the actual download for the corresponding Protocols
 	Command string `json:"command"`
+	// MaxSize is the maximum size in bytes of the content the command may
+	// write. It can only lower the limit of Helm, used if zero.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// CredentialsFD opts in to receiving the username and password of the
+	// repository as a JSON object read from the file descriptor named by
+	// $HELM_PLUGIN_CREDENTIALS_FD, so that they never appear in the
+	// arguments or the environment of the command.
+	CredentialsFD bool `json:"credentialsFD,omitempty"`
 }
 
 // PlatformCommand represents a command for a particular operating system and architecture
@@ -98,6 +106,11 @@ type Metadata struct {
 	// for special protocols.
 	Downloaders []Downloaders `json:"downloaders"`
 
+	// Protocol is set for the plugins serving typed requests over the
+	// plugin protocol, e.g. getters, post-renderers, credential providers
+	// or storage drivers.
+	Protocol *Protocol `json:"protocol,omitempty"`
+
 	// UseTunnelDeprecated indicates that this command needs a tunnel.
 	// Setting this will cause a number of side effects, such as the
 	// automatic setting of HELM_HOST.
@@ -177,6 +190,11 @@ func validatePluginData(plug *Plugin, filepath string) error {
 		return fmt.Errorf("invalid plugin name at %q", filepath)
 	}
 	plug.Metadata.Usage = sanitizeString(plug.Metadata.Usage)
+	if plug.Metadata.Protocol != nil {
+		if err := validateProtocol(plug.Metadata.Protocol); err != nil {
+			return errors.Wrapf(err, "invalid plugin protocol at %q", filepath)
+		}
+	}
 
 	// We could also validate SemVer, executable, and other fields should we so choose.
 	return nil
@@ -0,0 +1,591 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+)
+
+// ProtocolVersion is the version of the plugin protocol spoken by Helm.
+const ProtocolVersion = 1
+
+// DefaultCallTimeout is the time a plugin has to answer a call, unless the
+// Timeout of its Client is set.
+const DefaultCallTimeout = 30 * time.Second
+
+// The capabilities a protocol plugin may provide.
+const (
+	// CapabilityGetter downloads charts for the URL schemes of the plugin.
+	CapabilityGetter = "getter"
+	// CapabilityPostRenderer post-renders the manifests of releases.
+	CapabilityPostRenderer = "postrenderer"
+	// CapabilityCredentials supplies the credentials of registries and
+	// chart repositories.
+	CapabilityCredentials = "credentials"
+	// CapabilityStorage stores releases, as the driver "plugin:NAME".
+	CapabilityStorage = "storage"
+)
+
+// Capabilities lists the capabilities supported by Helm.
+var Capabilities = []string{CapabilityGetter, CapabilityPostRenderer, CapabilityCredentials, CapabilityStorage}
+
+// Protocol describes a plugin serving typed requests over the plugin
+// protocol instead of being run as a command.
+//
+// The command of a protocol plugin is started with its stdin and stdout
+// connected to Helm, which sends JSON-RPC 2.0 requests, one per line, and
+// reads the responses, one per line. The first request is "initialize",
+// negotiating the protocol version and the capabilities used.
+type Protocol struct {
+	// Version is the version of the protocol spoken by the plugin.
+	Version int `json:"version"`
+	// Command is the command serving the requests. It is expanded with the
+	// environment and resolved relative to the plugin directory.
+	Command string `json:"command"`
+	// Capabilities are the capabilities the plugin provides.
+	Capabilities []string `json:"capabilities"`
+	// Schemes are the URL schemes of the charts downloaded by a plugin
+	// with the getter capability.
+	Schemes []string `json:"schemes,omitempty"`
+	// Env are the names of the environment variables passed to the plugin.
+	// Other than them, the plugin only gets PATH, HOME and the variables of
+	// 'helm env'.
+	Env []string `json:"env,omitempty"`
+}
+
+// Provides reports whether the plugin declares the protocol capability.
+func (p *Plugin) Provides(capability string) bool {
+	if p.Metadata.Protocol == nil {
+		return false
+	}
+	return containsString(p.Metadata.Protocol.Capabilities, capability)
+}
+
+// FindProviders returns the plugins that provide the protocol capability.
+func FindProviders(plugdirs, capability string) ([]*Plugin, error) {
+	plugins, err := FindPlugins(plugdirs)
+	if err != nil {
+		return nil, err
+	}
+	var found []*Plugin
+	for _, p := range plugins {
+		if p.Provides(capability) {
+			found = append(found, p)
+		}
+	}
+	return found, nil
+}
+
+// FindProvider returns the plugin of the given name providing the protocol
+// capability.
+func FindProvider(plugdirs, name, capability string) (*Plugin, error) {
+	plugins, err := FindProviders(plugdirs, capability)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if p.Metadata.Name == name {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("no plugin %q providing the %s capability", name, capability)
+}
+
+func validateProtocol(p *Protocol) error {
+	if p.Version < 1 || p.Version > ProtocolVersion {
+		return errors.Errorf("unsupported protocol version %d", p.Version)
+	}
+	if strings.TrimSpace(p.Command) == "" {
+		return errors.New("protocol command is empty")
+	}
+	for _, c := range p.Capabilities {
+		if !containsString(Capabilities, c) {
+			return errors.Errorf("unknown protocol capability %q", c)
+		}
+	}
+	if containsString(p.Capabilities, CapabilityGetter) && len(p.Schemes) == 0 {
+		return errors.New("a plugin with the getter capability must declare its schemes")
+	}
+	return nil
+}
+
+// Error codes of the protocol, besides the JSON-RPC ones.
+const (
+	// CodeNotFound reports that a release does not exist.
+	CodeNotFound = 1
+	// CodeExists reports that a release already exists.
+	CodeExists = 2
+
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternal       = -32603
+)
+
+// RPCError is an error returned by a plugin.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// InitializeRequest is the params of the "initialize" method.
+type InitializeRequest struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// InitializeResponse is the result of the "initialize" method.
+type InitializeResponse struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// GetRequest is the params of the "getter.get" method.
+type GetRequest struct {
+	URL                   string `json:"url"`
+	Username              string `json:"username,omitempty"`
+	Password              string `json:"password,omitempty"`
+	CertFile              string `json:"certFile,omitempty"`
+	KeyFile               string `json:"keyFile,omitempty"`
+	CAFile                string `json:"caFile,omitempty"`
+	InsecureSkipTLSverify bool   `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// GetResponse is the result of the "getter.get" method.
+type GetResponse struct {
+	Data []byte `json:"data"`
+}
+
+// PostRenderRequest is the params of the "postrenderer.run" method.
+type PostRenderRequest struct {
+	Manifests string   `json:"manifests"`
+	Args      []string `json:"args,omitempty"`
+}
+
+// PostRenderResponse is the result of the "postrenderer.run" method.
+type PostRenderResponse struct {
+	Manifests string `json:"manifests"`
+}
+
+// CredentialRequest is the params of the "credentials.get" method.
+type CredentialRequest struct {
+	Host string `json:"host"`
+}
+
+// CredentialResponse is the result of the "credentials.get" method.
+type CredentialResponse struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// StorageRequest is the params of the "storage.*" methods: "storage.get",
+// "storage.list", "storage.query", "storage.create", "storage.update" and
+// "storage.delete".
+type StorageRequest struct {
+	Namespace string            `json:"namespace"`
+	Key       string            `json:"key,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Release   *release.Release  `json:"release,omitempty"`
+}
+
+// StorageResponse is the result of the "storage.*" methods.
+type StorageResponse struct {
+	Release  *release.Release   `json:"release,omitempty"`
+	Releases []*release.Release `json:"releases,omitempty"`
+}
+
+// Client is a connection to a running protocol plugin.
+type Client struct {
+	// Timeout is the time the plugin has to answer a call,
+	// DefaultCallTimeout if 0. The plugin is stopped if it does not answer
+	// in time, and the later calls fail.
+	Timeout time.Duration
+
+	plugin       *Plugin
+	cmd          *exec.Cmd
+	stdin        io.WriteCloser
+	stdout       *bufio.Reader
+	capabilities []string
+
+	mu     sync.Mutex
+	nextID int64
+	failed error
+}
+
+// Start starts the protocol plugin and negotiates the protocol version and
+// the capabilities used with it.
+func Start(p *Plugin, settings *cli.EnvSettings) (*Client, error) {
+	if p.Metadata.Protocol == nil {
+		return nil, errors.Errorf("plugin %q does not speak the plugin protocol", p.Metadata.Name)
+	}
+	parts := strings.Fields(os.ExpandEnv(p.Metadata.Protocol.Command))
+	if len(parts) == 0 {
+		return nil, errors.Errorf("plugin %q has no protocol command", p.Metadata.Name)
+	}
+	main := parts[0]
+	if !filepath.IsAbs(main) {
+		main = filepath.Join(p.Dir, main)
+	}
+	cmd := exec.Command(main, parts[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = protocolEnv(p, settings)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "unable to start plugin %q", p.Metadata.Name)
+	}
+	c := &Client{plugin: p, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}
+
+	var resp InitializeResponse
+	if err := c.call("initialize", &InitializeRequest{ProtocolVersion: ProtocolVersion, Capabilities: Capabilities}, &resp); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if resp.ProtocolVersion != ProtocolVersion {
+		c.Close()
+		return nil, errors.Errorf("plugin %q speaks protocol version %d, Helm speaks version %d", p.Metadata.Name, resp.ProtocolVersion, ProtocolVersion)
+	}
+	for _, capability := range resp.Capabilities {
+		if p.Provides(capability) {
+			c.capabilities = append(c.capabilities, capability)
+		}
+	}
+	return c, nil
+}
+
+// protocolEnv returns the environment of a protocol plugin: PATH, HOME, the
+// variables of 'helm env' and the variables listed by the plugin.
+func protocolEnv(p *Plugin, settings *cli.EnvSettings) []string {
+	env := settings.EnvVars()
+	env["HELM_PLUGIN_NAME"] = p.Metadata.Name
+	env["HELM_PLUGIN_DIR"] = p.Dir
+	env["HELM_PLUGIN_PROTOCOL_VERSION"] = strconv.Itoa(ProtocolVersion)
+	for _, key := range append([]string{"PATH", "HOME"}, p.Metadata.Protocol.Env...) {
+		if val, ok := os.LookupEnv(key); ok {
+			env[key] = val
+		}
+	}
+	out := make([]string, 0, len(env))
+	for key, val := range env {
+		out = append(out, key+"="+val)
+	}
+	return out
+}
+
+// Capabilities returns the capabilities negotiated with the plugin.
+func (c *Client) Capabilities() []string {
+	return c.capabilities
+}
+
+// Call calls a method of a negotiated capability of the plugin, the
+// capability being the prefix of the method before the dot.
+func (c *Client) Call(method string, params, result interface{}) error {
+	capability := strings.SplitN(method, ".", 2)[0]
+	if !containsString(c.capabilities, capability) {
+		return errors.Errorf("plugin %q does not provide the %s capability", c.plugin.Metadata.Name, capability)
+	}
+	return c.call(method, params, result)
+}
+
+func (c *Client) call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed != nil {
+		return c.failed
+	}
+
+	c.nextID++
+	req := rpcRequest{JSONRPC: "2.0", ID: c.nextID, Method: method}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = b
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	line, err := c.exchange(method, append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return errors.Wrapf(err, "invalid response from plugin %q", c.plugin.Metadata.Name)
+	}
+	if resp.ID != req.ID {
+		return errors.Errorf("plugin %q answered request %d to request %d", c.plugin.Metadata.Name, resp.ID, req.ID)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return errors.Wrapf(json.Unmarshal(resp.Result, result), "invalid result of %s from plugin %q", method, c.plugin.Metadata.Name)
+}
+
+// exchange writes a request and reads the response line, stopping the
+// plugin if it does not answer within the timeout of the client, as its late
+// response would be read as the response of the next request.
+func (c *Client) exchange(method string, request []byte) ([]byte, error) {
+	type response struct {
+		line []byte
+		err  error
+	}
+	done := make(chan response, 1)
+	go func() {
+		if _, err := c.stdin.Write(request); err != nil {
+			done <- response{err: errors.Wrapf(err, "unable to call plugin %q", c.plugin.Metadata.Name)}
+			return
+		}
+		line, err := c.stdout.ReadBytes('\n')
+		if err != nil {
+			err = errors.Wrapf(err, "no response from plugin %q", c.plugin.Metadata.Name)
+		}
+		done <- response{line: line, err: err}
+	}()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCallTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-timer.C:
+		c.cmd.Process.Kill()
+		c.failed = errors.Errorf("plugin %q did not answer %s within %s", c.plugin.Metadata.Name, method, timeout)
+		return nil, c.failed
+	}
+}
+
+// Close stops the plugin, closing its stdin and waiting for it to exit.
+func (c *Client) Close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// Handler implements the capabilities of a protocol plugin written in Go,
+// served by Serve. The capabilities whose functions are nil are not provided.
+type Handler struct {
+	Get        func(req *GetRequest) ([]byte, error)
+	PostRender func(req *PostRenderRequest) (string, error)
+	Credential func(host string) (username, password string, err error)
+	// Storage returns the driver storing the releases of a namespace.
+	// It returns the errors of the driver package, e.g.
+	// driver.ErrReleaseNotFound, for them to be reported to Helm.
+	Storage func(namespace string) (StorageBackend, error)
+}
+
+// StorageBackend is the subset of driver.Driver served by a plugin.
+type StorageBackend interface {
+	Create(key string, rls *release.Release) error
+	Update(key string, rls *release.Release) error
+	Delete(key string) (*release.Release, error)
+	Get(key string) (*release.Release, error)
+	List(filter func(*release.Release) bool) ([]*release.Release, error)
+	Query(labels map[string]string) ([]*release.Release, error)
+}
+
+func (h *Handler) capabilities() []string {
+	var caps []string
+	if h.Get != nil {
+		caps = append(caps, CapabilityGetter)
+	}
+	if h.PostRender != nil {
+		caps = append(caps, CapabilityPostRenderer)
+	}
+	if h.Credential != nil {
+		caps = append(caps, CapabilityCredentials)
+	}
+	if h.Storage != nil {
+		caps = append(caps, CapabilityStorage)
+	}
+	return caps
+}
+
+// Serve serves the requests of Helm read from r, writing the responses to
+// w, until r is closed. Protocol plugins written in Go call it with
+// os.Stdin and os.Stdout.
+func Serve(r io.Reader, w io.Writer, h *Handler) error {
+	in := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := in.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			return errors.Wrap(err, "invalid request")
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+		result, err := h.handle(&req)
+		if err != nil {
+			rpcErr, ok := err.(*RPCError)
+			if !ok {
+				rpcErr = &RPCError{Code: storageErrorCode(err), Message: err.Error()}
+			}
+			resp.Error = rpcErr
+		} else if result != nil {
+			b, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			resp.Result = b
+		}
+		if err := enc.Encode(&resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *Handler) handle(req *rpcRequest) (interface{}, error) {
+	decode := func(v interface{}) error {
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			return &RPCError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+	notFound := &RPCError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+
+	switch {
+	case req.Method == "initialize":
+		return &InitializeResponse{ProtocolVersion: ProtocolVersion, Capabilities: h.capabilities()}, nil
+	case req.Method == "getter.get" && h.Get != nil:
+		var params GetRequest
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		data, err := h.Get(&params)
+		if err != nil {
+			return nil, err
+		}
+		return &GetResponse{Data: data}, nil
+	case req.Method == "postrenderer.run" && h.PostRender != nil:
+		var params PostRenderRequest
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		manifests, err := h.PostRender(&params)
+		if err != nil {
+			return nil, err
+		}
+		return &PostRenderResponse{Manifests: manifests}, nil
+	case req.Method == "credentials.get" && h.Credential != nil:
+		var params CredentialRequest
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		username, password, err := h.Credential(params.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &CredentialResponse{Username: username, Password: password}, nil
+	case strings.HasPrefix(req.Method, "storage.") && h.Storage != nil:
+		var params StorageRequest
+		if err := decode(&params); err != nil {
+			return nil, err
+		}
+		backend, err := h.Storage(params.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		return serveStorage(backend, req.Method, &params, notFound)
+	}
+	return nil, notFound
+}
+
+func serveStorage(b StorageBackend, method string, params *StorageRequest, notFound error) (*StorageResponse, error) {
+	var resp StorageResponse
+	var err error
+	switch method {
+	case "storage.get":
+		resp.Release, err = b.Get(params.Key)
+	case "storage.list":
+		resp.Releases, err = b.List(func(*release.Release) bool { return true })
+	case "storage.query":
+		resp.Releases, err = b.Query(params.Labels)
+	case "storage.create":
+		err = b.Create(params.Key, params.Release)
+	case "storage.update":
+		err = b.Update(params.Key, params.Release)
+	case "storage.delete":
+		resp.Release, err = b.Delete(params.Key)
+	default:
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
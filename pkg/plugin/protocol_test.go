@@ -0,0 +1,247 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// TestMain serves the plugin protocol when the test binary is started as a
+// protocol plugin.
+func TestMain(m *testing.M) {
+	if os.Getenv("HELM_PLUGIN_TEST_SERVE") == "1" {
+		stores := map[string]*driver.Memory{}
+		err := Serve(os.Stdin, os.Stdout, &Handler{
+			Get: func(req *GetRequest) ([]byte, error) {
+				return []byte("chart of " + req.URL), nil
+			},
+			PostRender: func(req *PostRenderRequest) (string, error) {
+				if len(req.Args) > 0 && req.Args[0] == "--hang" {
+					time.Sleep(time.Minute)
+				}
+				return req.Manifests + strings.Join(req.Args, " "), nil
+			},
+			Credential: func(host string) (string, string, error) {
+				return "user@" + host, os.Getenv("HELM_PLUGIN_TEST_SECRET"), nil
+			},
+			Storage: func(namespace string) (StorageBackend, error) {
+				if stores[namespace] == nil {
+					stores[namespace] = driver.NewMemory()
+					stores[namespace].SetNamespace(namespace)
+				}
+				return stores[namespace], nil
+			},
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// protocolPlugin writes a protocol plugin running the test binary.
+func protocolPlugin(t *testing.T, capabilities ...string) *Plugin {
+	t.Helper()
+	exe, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "proto")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf(`name: proto
+version: 0.1.0
+protocol:
+  version: 1
+  command: %q
+  capabilities: [%s]
+  schemes: [proto]
+  env: [HELM_PLUGIN_TEST_SERVE]
+`, exe, strings.Join(capabilities, ", "))
+	if err := ioutil.WriteFile(filepath.Join(dir, PluginFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestProtocolCapabilities(t *testing.T) {
+	os.Setenv("HELM_PLUGIN_TEST_SERVE", "1")
+	defer os.Unsetenv("HELM_PLUGIN_TEST_SERVE")
+	settings := cli.New()
+
+	p := protocolPlugin(t, CapabilityGetter, CapabilityPostRenderer)
+	c, err := Start(p, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if caps := c.Capabilities(); len(caps) != 2 || caps[0] != CapabilityGetter || caps[1] != CapabilityPostRenderer {
+		t.Errorf("unexpected negotiated capabilities %v", caps)
+	}
+
+	var resp GetResponse
+	if err := c.Call("getter.get", &GetRequest{URL: "proto://charts/hello"}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data) != "chart of proto://charts/hello" {
+		t.Errorf("unexpected chart %q", resp.Data)
+	}
+
+	if err := c.Call("credentials.get", &CredentialRequest{Host: "example.com"}, &CredentialResponse{}); err == nil {
+		t.Error("expected an error calling a capability not declared by the plugin")
+	}
+
+	out, err := NewPostRenderer(p, settings, "--label", "x").Run(bytes.NewBufferString("kind: Pod\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "kind: Pod\n--label x" {
+		t.Errorf("unexpected post-rendered manifests %q", out)
+	}
+}
+
+func TestProtocolEnv(t *testing.T) {
+	os.Setenv("HELM_PLUGIN_TEST_SERVE", "1")
+	os.Setenv("HELM_PLUGIN_TEST_SECRET", "secret")
+	defer os.Unsetenv("HELM_PLUGIN_TEST_SERVE")
+	defer os.Unsetenv("HELM_PLUGIN_TEST_SECRET")
+
+	p := protocolPlugin(t, CapabilityCredentials)
+	username, password, err := NewCredentialProvider(p, cli.New()).Credential("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "user@example.com" {
+		t.Errorf("unexpected username %q", username)
+	}
+	if password != "" {
+		t.Error("expected the environment variables not listed by the plugin to be hidden from it")
+	}
+}
+
+func TestStorageDriver(t *testing.T) {
+	os.Setenv("HELM_PLUGIN_TEST_SERVE", "1")
+	defer os.Unsetenv("HELM_PLUGIN_TEST_SERVE")
+
+	d := NewStorageDriver(protocolPlugin(t, CapabilityStorage), cli.New(), "apps")
+	defer d.Close()
+	rls := &release.Release{Name: "web", Namespace: "apps", Version: 1, Info: &release.Info{Status: release.StatusDeployed}}
+	if err := d.Create("sh.helm.release.v1.web.v1", rls); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Create("sh.helm.release.v1.web.v1", rls); err != driver.ErrReleaseExists {
+		t.Errorf("expected %v, got %v", driver.ErrReleaseExists, err)
+	}
+	got, err := d.Get("sh.helm.release.v1.web.v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "web" || got.Version != 1 {
+		t.Errorf("unexpected release %+v", got)
+	}
+	list, err := d.Query(map[string]string{"name": "web", "owner": "helm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Errorf("expected 1 release, got %d", len(list))
+	}
+	if _, err := d.Delete("sh.helm.release.v1.web.v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get("sh.helm.release.v1.web.v1"); err != driver.ErrReleaseNotFound {
+		t.Errorf("expected %v, got %v", driver.ErrReleaseNotFound, err)
+	}
+
+	// The drivers of other namespaces call the same process.
+	staging := d.ForNamespace("staging")
+	if err := staging.Create("sh.helm.release.v1.web.v1", rls); err != nil {
+		t.Fatal(err)
+	}
+	if staging.process.c != d.process.c {
+		t.Error("expected the drivers of both namespaces to share the plugin process")
+	}
+	if _, err := d.Get("sh.helm.release.v1.web.v1"); err != driver.ErrReleaseNotFound {
+		t.Errorf("expected the release of staging not to be found in apps, got %v", err)
+	}
+
+	// The plugin is started again after Close.
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := staging.Get("sh.helm.release.v1.web.v1"); err != driver.ErrReleaseNotFound {
+		t.Errorf("expected %v from a new plugin process, got %v", driver.ErrReleaseNotFound, err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	os.Setenv("HELM_PLUGIN_TEST_SERVE", "1")
+	defer os.Unsetenv("HELM_PLUGIN_TEST_SERVE")
+
+	c, err := Start(protocolPlugin(t, CapabilityPostRenderer), cli.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Timeout = 100 * time.Millisecond
+
+	err = c.Call("postrenderer.run", &PostRenderRequest{Args: []string{"--hang"}}, &PostRenderResponse{})
+	if err == nil || !strings.Contains(err.Error(), `plugin "proto" did not answer postrenderer.run within 100ms`) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if err := c.Call("postrenderer.run", &PostRenderRequest{}, &PostRenderResponse{}); err == nil {
+		t.Error("expected the calls to fail after a timeout")
+	}
+}
+
+func TestValidateProtocol(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol Protocol
+		wantErr  bool
+	}{
+		{name: "valid", protocol: Protocol{Version: 1, Command: "serve", Capabilities: []string{CapabilityStorage}}},
+		{name: "future version", protocol: Protocol{Version: 2, Command: "serve"}, wantErr: true},
+		{name: "no command", protocol: Protocol{Version: 1}, wantErr: true},
+		{name: "unknown capability", protocol: Protocol{Version: 1, Command: "serve", Capabilities: []string{"scheduler"}}, wantErr: true},
+		{name: "getter without schemes", protocol: Protocol{Version: 1, Command: "serve", Capabilities: []string{CapabilityGetter}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateProtocol(&tt.protocol); (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// callOnce starts the plugin, calls one method and stops the plugin.
+func callOnce(p *Plugin, settings *cli.EnvSettings, method string, params, result interface{}) error {
+	c, err := Start(p, settings)
+	if err != nil {
+		return err
+	}
+	err = c.Call(method, params, result)
+	if cerr := c.Close(); err == nil && cerr != nil {
+		err = errors.Wrapf(cerr, "plugin %q", p.Metadata.Name)
+	}
+	return err
+}
+
+// PostRenderer is a post-renderer provided by a protocol plugin.
+type PostRenderer struct {
+	plugin   *Plugin
+	settings *cli.EnvSettings
+	args     []string
+}
+
+// NewPostRenderer returns the post-renderer of the plugin, passed args.
+func NewPostRenderer(p *Plugin, settings *cli.EnvSettings, args ...string) *PostRenderer {
+	return &PostRenderer{plugin: p, settings: settings, args: args}
+}
+
+// Run post-renders the manifests with the plugin.
+func (r *PostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	var resp PostRenderResponse
+	req := &PostRenderRequest{Manifests: renderedManifests.String(), Args: r.args}
+	if err := callOnce(r.plugin, r.settings, "postrenderer.run", req, &resp); err != nil {
+		return nil, errors.Wrap(err, "error while running post render on files")
+	}
+	return bytes.NewBufferString(resp.Manifests), nil
+}
+
+// CredentialProvider supplies the credentials of hosts with a protocol
+// plugin. It implements registry.CredentialProvider.
+type CredentialProvider struct {
+	plugin   *Plugin
+	settings *cli.EnvSettings
+}
+
+// NewCredentialProvider returns the credential provider of the plugin.
+func NewCredentialProvider(p *Plugin, settings *cli.EnvSettings) *CredentialProvider {
+	return &CredentialProvider{plugin: p, settings: settings}
+}
+
+// Credential returns the credentials of the host.
+func (c *CredentialProvider) Credential(host string) (string, string, error) {
+	var resp CredentialResponse
+	if err := callOnce(c.plugin, c.settings, "credentials.get", &CredentialRequest{Host: host}, &resp); err != nil {
+		return "", "", err
+	}
+	return resp.Username, resp.Password, nil
+}
+
+// StorageDriver is a release storage driver provided by a protocol plugin,
+// named "plugin:NAME". The plugin is started on the first operation and
+// kept running until Close, shared by the drivers of other namespaces
+// returned by ForNamespace.
+type StorageDriver struct {
+	process   *pluginProcess
+	namespace string
+}
+
+var _ driver.Driver = (*StorageDriver)(nil)
+
+// NewStorageDriver returns the storage driver of the plugin for the
+// releases of namespace.
+func NewStorageDriver(p *Plugin, settings *cli.EnvSettings, namespace string) *StorageDriver {
+	return &StorageDriver{process: &pluginProcess{plugin: p, settings: settings}, namespace: namespace}
+}
+
+// ForNamespace returns the driver of the releases of another namespace,
+// calling the same plugin process.
+func (d *StorageDriver) ForNamespace(namespace string) *StorageDriver {
+	return &StorageDriver{process: d.process, namespace: namespace}
+}
+
+// Name returns the name of the driver.
+func (d *StorageDriver) Name() string {
+	return "plugin:" + d.process.plugin.Metadata.Name
+}
+
+func (d *StorageDriver) call(method string, req *StorageRequest) (*StorageResponse, error) {
+	c, err := d.process.client()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Namespace = d.namespace
+	resp := &StorageResponse{}
+	if err := c.Call(method, req, resp); err != nil {
+		if rpcErr, ok := err.(*RPCError); ok {
+			switch rpcErr.Code {
+			case CodeNotFound:
+				return nil, driver.ErrReleaseNotFound
+			case CodeExists:
+				return nil, driver.ErrReleaseExists
+			}
+			return nil, err
+		}
+		// The plugin failed or did not answer in time, the next operation
+		// starts it again.
+		d.process.reset(c)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Get returns the release named by key.
+func (d *StorageDriver) Get(key string) (*release.Release, error) {
+	resp, err := d.call("storage.get", &StorageRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Release == nil {
+		return nil, driver.ErrReleaseNotFound
+	}
+	return resp.Release, nil
+}
+
+// List returns the releases of the namespace matching filter.
+func (d *StorageDriver) List(filter func(*release.Release) bool) ([]*release.Release, error) {
+	resp, err := d.call("storage.list", &StorageRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var list []*release.Release
+	for _, rls := range resp.Releases {
+		if filter(rls) {
+			list = append(list, rls)
+		}
+	}
+	return list, nil
+}
+
+// Query returns the releases of the namespace matching labels.
+func (d *StorageDriver) Query(labels map[string]string) ([]*release.Release, error) {
+	resp, err := d.call("storage.query", &StorageRequest{Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Releases) == 0 {
+		return nil, driver.ErrReleaseNotFound
+	}
+	return resp.Releases, nil
+}
+
+// Create stores a new release.
+func (d *StorageDriver) Create(key string, rls *release.Release) error {
+	_, err := d.call("storage.create", &StorageRequest{Key: key, Release: rls})
+	return err
+}
+
+// Update updates a stored release.
+func (d *StorageDriver) Update(key string, rls *release.Release) error {
+	_, err := d.call("storage.update", &StorageRequest{Key: key, Release: rls})
+	return err
+}
+
+// Delete deletes a stored release and returns it.
+func (d *StorageDriver) Delete(key string) (*release.Release, error) {
+	resp, err := d.call("storage.delete", &StorageRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Release, nil
+}
+
+// Close stops the plugin, for the drivers of all namespaces.
+func (d *StorageDriver) Close() error {
+	return d.process.Close()
+}
+
+// pluginProcess is a plugin started on the first call.
+type pluginProcess struct {
+	plugin   *Plugin
+	settings *cli.EnvSettings
+
+	mu sync.Mutex
+	c  *Client
+}
+
+// client returns the client of the running plugin, starting it if needed.
+func (p *pluginProcess) client() (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.c == nil {
+		c, err := Start(p.plugin, p.settings)
+		if err != nil {
+			return nil, err
+		}
+		p.c = c
+	}
+	return p.c, nil
+}
+
+// reset stops the plugin of the client if it is still the running one.
+func (p *pluginProcess) reset(c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.c == c {
+		c.Close()
+		p.c = nil
+	}
+}
+
+// Close stops the plugin if it is running.
+func (p *pluginProcess) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.c == nil {
+		return nil
+	}
+	err := p.c.Close()
+	p.c = nil
+	return err
+}
+
+// storageErrorCode returns the protocol error code of the error of a
+// storage backend.
+func storageErrorCode(err error) int {
+	switch {
+	case errors.Is(err, driver.ErrReleaseNotFound):
+		return CodeNotFound
+	case errors.Is(err, driver.ErrReleaseExists):
+		return CodeExists
+	}
+	return codeInternal
+}
@@ -0,0 +1,154 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// CELSpec is a policy written as a CEL expression, which is evaluated on
+// each resource with the object bound to the "object" variable, and must
+// return true for the resources complying with the policy, e.g.:
+//
+//	name: no-latest-images
+//	enforcement: deny
+//	kinds: [Deployment, StatefulSet, DaemonSet]
+//	expression: >-
+//	  object.spec.template.spec.containers.all(c, !c.image.endsWith(':latest'))
+//	message: images must be pinned to a tag other than latest
+type CELSpec struct {
+	Name string `json:"name"`
+	// Enforcement is "deny", the default, or "warn".
+	Enforcement string `json:"enforcement,omitempty"`
+	// Kinds restricts the policy to resources of these kinds. The policy
+	// applies to all resources if it is empty.
+	Kinds      []string `json:"kinds,omitempty"`
+	Expression string   `json:"expression"`
+	// Message describes the violations of the policy.
+	Message string `json:"message,omitempty"`
+}
+
+// CELFile is a file of CEL policies.
+type CELFile struct {
+	Policies []CELSpec `json:"policies"`
+}
+
+type celPolicy struct {
+	spec        CELSpec
+	enforcement Enforcement
+	kinds       map[string]bool
+	program     cel.Program
+}
+
+// NewCELPolicy compiles a CEL policy.
+func NewCELPolicy(spec CELSpec) (Policy, error) {
+	if spec.Name == "" {
+		return nil, errors.New("policy name is required")
+	}
+	enforcement, err := ParseEnforcement(spec.Enforcement)
+	if err != nil {
+		return nil, errors.Wrapf(err, "policy %s", spec.Name)
+	}
+	env, err := cel.NewEnv(cel.Declarations(decls.NewVar("object", decls.Dyn)))
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(spec.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrapf(issues.Err(), "policy %s: invalid expression", spec.Name)
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, errors.Wrapf(err, "policy %s: invalid expression", spec.Name)
+	}
+
+	p := &celPolicy{spec: spec, enforcement: enforcement, program: program}
+	if len(spec.Kinds) > 0 {
+		p.kinds = make(map[string]bool, len(spec.Kinds))
+		for _, kind := range spec.Kinds {
+			p.kinds[kind] = true
+		}
+	}
+	return p, nil
+}
+
+// LoadCELFile loads the CEL policies of a file.
+func LoadCELFile(filename string) ([]Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f CELFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, errors.Wrapf(err, "invalid policy file %s", filename)
+	}
+	policies := make([]Policy, 0, len(f.Policies))
+	for _, spec := range f.Policies {
+		p, err := NewCELPolicy(spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid policy file %s", filename)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (p *celPolicy) Name() string {
+	return p.spec.Name
+}
+
+func (p *celPolicy) Enforcement() Enforcement {
+	return p.enforcement
+}
+
+func (p *celPolicy) Evaluate(resources []Resource) ([]Violation, error) {
+	var violations []Violation
+	for _, r := range resources {
+		if p.kinds != nil && !p.kinds[r.Kind()] {
+			continue
+		}
+		out, _, err := p.program.Eval(map[string]interface{}{"object": r.Object})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate %s", r)
+		}
+		ok, isBool := out.Value().(bool)
+		if !isBool {
+			return nil, errors.Errorf("expression returned %v for %s, expected a boolean", out.Value(), r)
+		}
+		if ok {
+			continue
+		}
+		message := p.spec.Message
+		if message == "" {
+			message = fmt.Sprintf("expression %q is false", p.spec.Expression)
+		}
+		violations = append(violations, Violation{
+			Policy:      p.spec.Name,
+			Enforcement: p.enforcement,
+			Resource:    r.String(),
+			Source:      r.Source,
+			Message:     message,
+		})
+	}
+	return violations, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package policy evaluates policies on the resources rendered from a chart
+before they are applied to the cluster, so that platform guardrails are
+enforced in the deploy path rather than only at admission.
+
+Policies implement the Policy interface. Embedders can supply their own, such
+as Rego policies evaluated with Open Policy Agent, and the package provides
+policies written as CEL expressions.
+*/
+package policy // import "github.com/open-hand/helm/pkg/policy"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// Enforcement is how the violations of a policy are handled.
+type Enforcement string
+
+const (
+	// Deny blocks the operation on violations.
+	Deny Enforcement = "deny"
+	// Warn reports violations without blocking the operation.
+	Warn Enforcement = "warn"
+)
+
+// ParseEnforcement returns the enforcement with the given name. The empty
+// string is Deny.
+func ParseEnforcement(s string) (Enforcement, error) {
+	switch Enforcement(strings.ToLower(s)) {
+	case "", Deny:
+		return Deny, nil
+	case Warn:
+		return Warn, nil
+	}
+	return "", errors.Errorf("invalid enforcement %q, must be one of: deny, warn", s)
+}
+
+// Resource is a Kubernetes object rendered from a chart.
+type Resource struct {
+	// Source is the path of the template the object was rendered from.
+	Source string
+	// Object is the content of the object.
+	Object map[string]interface{}
+}
+
+// Kind returns the kind of the object.
+func (r Resource) Kind() string {
+	kind, _ := r.Object["kind"].(string)
+	return kind
+}
+
+// Name returns the name of the object.
+func (r Resource) Name() string {
+	md, _ := r.Object["metadata"].(map[string]interface{})
+	name, _ := md["name"].(string)
+	return name
+}
+
+// String returns the kind and name of the object, e.g. "Deployment/web".
+func (r Resource) String() string {
+	return r.Kind() + "/" + r.Name()
+}
+
+// Violation is a resource that does not comply with a policy.
+type Violation struct {
+	Policy      string
+	Enforcement Enforcement
+	// Resource identifies the object, e.g. "Deployment/web". It is empty for
+	// violations of the whole set of resources.
+	Resource string
+	// Source is the path of the template the object was rendered from.
+	Source  string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Resource == "" {
+		return fmt.Sprintf("policy %s: %s", v.Policy, v.Message)
+	}
+	return fmt.Sprintf("policy %s: %s (%s): %s", v.Policy, v.Resource, v.Source, v.Message)
+}
+
+// Policy checks the resources rendered from a chart.
+type Policy interface {
+	// Name identifies the policy.
+	Name() string
+	// Enforcement is how the violations of the policy are handled.
+	Enforcement() Enforcement
+	// Evaluate returns the violations of the policy by the resources.
+	Evaluate(resources []Resource) ([]Violation, error)
+}
+
+// DeniedError is returned when resources violate policies enforced with
+// Deny.
+type DeniedError struct {
+	Violations []Violation
+}
+
+func (e *DeniedError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.String())
+	}
+	return fmt.Sprintf("rendered manifests violate %d policy rule(s):\n%s", len(e.Violations), strings.Join(msgs, "\n"))
+}
+
+// Checker evaluates policies on rendered manifests.
+type Checker struct {
+	Policies []Policy
+	// Warn is called with the violations of policies enforced with Warn. They
+	// are dropped if it is nil.
+	Warn func(Violation)
+}
+
+// Check evaluates the policies on the resources in the manifests, which are
+// YAML documents such as the manifest of a release and its hooks. It returns
+// a *DeniedError if a policy enforced with Deny is violated.
+func (c *Checker) Check(manifests ...string) error {
+	if c == nil || len(c.Policies) == 0 {
+		return nil
+	}
+	resources, err := Resources(manifests...)
+	if err != nil {
+		return err
+	}
+	var denied []Violation
+	for _, p := range c.Policies {
+		violations, err := p.Evaluate(resources)
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate policy %s", p.Name())
+		}
+		for _, v := range violations {
+			if v.Policy == "" {
+				v.Policy = p.Name()
+			}
+			if v.Enforcement == "" {
+				v.Enforcement = p.Enforcement()
+			}
+			if v.Enforcement == Warn {
+				if c.Warn != nil {
+					c.Warn(v)
+				}
+				continue
+			}
+			denied = append(denied, v)
+		}
+	}
+	if len(denied) > 0 {
+		return &DeniedError{Violations: denied}
+	}
+	return nil
+}
+
+// Resources returns the objects in the manifests, in order.
+func Resources(manifests ...string) ([]Resource, error) {
+	var resources []Resource
+	for _, manifest := range manifests {
+		parsed, err := releaseutil.ParseManifest(manifest)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse rendered manifest")
+		}
+		for _, res := range parsed {
+			resources = append(resources, Resource{Source: res.Source, Object: res.Object})
+		}
+	}
+	return resources, nil
+}
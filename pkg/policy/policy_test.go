@@ -0,0 +1,168 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const manifest = `---
+# Source: web/templates/pod.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  labels:
+    team: frontend
+spec:
+  containers:
+  - name: web
+    image: nginx:1.21
+---
+# Source: web/templates/worker.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: worker
+spec:
+  containers:
+  - name: worker
+    image: busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000
+---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+func TestResources(t *testing.T) {
+	resources, err := Resources(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ resource, source string }{
+		{"Pod/web", "web/templates/pod.yaml"},
+		{"Pod/worker", "web/templates/worker.yaml"},
+		{"ConfigMap/config", "web/templates/configmap.yaml"},
+	}
+	if len(resources) != len(want) {
+		t.Fatalf("expected %d resources, got %d", len(want), len(resources))
+	}
+	for i, w := range want {
+		if resources[i].String() != w.resource || resources[i].Source != w.source {
+			t.Errorf("resource %d: expected %s from %s, got %s from %s", i, w.resource, w.source, resources[i], resources[i].Source)
+		}
+	}
+}
+
+func TestCheckerCEL(t *testing.T) {
+	policies, err := LoadCELFile("testdata/policies.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []Violation
+	checker := &Checker{Policies: policies, Warn: func(v Violation) { warnings = append(warnings, v) }}
+	err = checker.Check(manifest)
+
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		t.Fatalf("expected a denied error, got %v", err)
+	}
+	if len(denied.Violations) != 1 || denied.Violations[0].Resource != "Pod/worker" || denied.Violations[0].Policy != "require-team-label" {
+		t.Errorf("unexpected violations: %v", denied.Violations)
+	}
+	if !strings.Contains(err.Error(), "policy require-team-label: Pod/worker (web/templates/worker.yaml): pods must carry a team label") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(warnings) != 1 || warnings[0].Resource != "Pod/web" || warnings[0].Enforcement != Warn {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestCheckerEmbedded(t *testing.T) {
+	// Policies supplied by embedders, e.g. evaluating Rego, see all the
+	// resources at once.
+	var seen int
+	checker := &Checker{Policies: []Policy{testPolicy(func(resources []Resource) ([]Violation, error) {
+		seen = len(resources)
+		return []Violation{{Message: "too many resources"}}, nil
+	})}}
+	err := checker.Check(manifest)
+	if seen != 3 {
+		t.Errorf("expected the policy to see 3 resources, got %d", seen)
+	}
+	if err == nil || !strings.Contains(err.Error(), "policy embedded: too many resources") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	checker.Policies = []Policy{testPolicy(func([]Resource) ([]Violation, error) {
+		return nil, errors.New("unavailable")
+	})}
+	if err := checker.Check(manifest); err == nil || err.Error() != "failed to evaluate policy embedded: unavailable" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := (*Checker)(nil).Check(manifest); err != nil {
+		t.Errorf("expected a nil checker to pass, got %v", err)
+	}
+}
+
+func TestNewCELPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    CELSpec
+		wantErr string
+	}{
+		{"valid", CELSpec{Name: "ok", Expression: "true"}, ""},
+		{"no name", CELSpec{Expression: "true"}, "policy name is required"},
+		{"invalid enforcement", CELSpec{Name: "p", Enforcement: "block", Expression: "true"}, `invalid enforcement "block"`},
+		{"invalid expression", CELSpec{Name: "p", Expression: "object.metadata.name =="}, "policy p: invalid expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCELPolicy(tt.spec)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	p, err := NewCELPolicy(CELSpec{Name: "p", Expression: "object.metadata.name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Evaluate([]Resource{{Object: map[string]interface{}{"metadata": map[string]interface{}{"name": "x"}}}}); err == nil {
+		t.Error("expected an error for an expression that does not return a boolean")
+	}
+}
+
+type testPolicy func([]Resource) ([]Violation, error)
+
+func (testPolicy) Name() string                                         { return "embedded" }
+func (testPolicy) Enforcement() Enforcement                             { return Deny }
+func (p testPolicy) Evaluate(resources []Resource) ([]Violation, error) { return p(resources) }
@@ -0,0 +1,462 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Signature is a cosign signature, as attached to a chart stored in an OCI
+// registry or published next to a chart archive.
+type Signature struct {
+	// Payload is the signed content: a simple signing payload for signatures
+	// of OCI charts, a DSSE envelope for attestations, or nil for detached
+	// signatures of chart archives.
+	Payload []byte
+	// Signature is the raw signature. It is unused for DSSE envelopes, which
+	// carry their own.
+	Signature []byte
+	// Certificate is the PEM encoded signing certificate of keyless
+	// signatures.
+	Certificate []byte
+	// Chain is the PEM encoded chain of intermediate certificates of
+	// Certificate.
+	Chain []byte
+	// Bundle is the entry of the signature in the transparency log, as
+	// recorded by cosign.
+	Bundle []byte
+}
+
+// SignatureVerification contains information about a verified signature.
+type SignatureVerification struct {
+	// Signer identifies the signer: the identity in the certificate for
+	// keyless signatures, or the fingerprint of the public key.
+	Signer string
+	// Issuer is the OIDC issuer that authenticated the signer of keyless
+	// signatures.
+	Issuer string
+	// Digest is the digest the signature was verified for, prepended with
+	// the scheme: the manifest digest of an OCI chart, or the digest of a
+	// chart archive.
+	Digest string
+}
+
+// Statement is an in-toto statement about a chart, as held by an
+// attestation.
+type Statement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []Subject       `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Subject is an artifact an in-toto statement is about.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// SignatureVerifier verifies chart signatures made with tools other than
+// GPG, whose provenance files are verified by Signatory.
+type SignatureVerifier interface {
+	// VerifyBlob verifies a detached signature over a chart archive.
+	VerifyBlob(archive []byte, sig *Signature) (*SignatureVerification, error)
+	// VerifyManifest verifies that one of sigs signs the OCI manifest with
+	// the given digest.
+	VerifyManifest(digest string, sigs []*Signature) (*SignatureVerification, error)
+	// VerifyAttestations verifies the attestations held by the DSSE
+	// envelopes of atts for the OCI manifest with the given digest, and
+	// returns the statements of those that are valid.
+	VerifyAttestations(digest string, atts []*Signature) ([]*Statement, error)
+}
+
+// CosignOptions configures a CosignVerifier. Either KeyFile, for signatures
+// made with a key pair, or RootsFile and RekorKeyFile, for keyless
+// signatures, must be set.
+type CosignOptions struct {
+	// KeyFile is the PEM encoded public key signatures are verified with.
+	KeyFile string
+	// RootsFile holds the PEM encoded root certificates of the authority
+	// issuing keyless signing certificates, e.g. Fulcio.
+	RootsFile string
+	// RekorKeyFile is the PEM encoded public key of the transparency log
+	// keyless signatures are recorded in. The time a signature was recorded
+	// at is used to check the validity of the short-lived signing
+	// certificate.
+	RekorKeyFile string
+	// Identity is a regular expression the identity in keyless signing
+	// certificates, an email address or URI, must match.
+	Identity string
+	// Issuer is the OIDC issuer that must have authenticated the signer of
+	// keyless signatures.
+	Issuer string
+}
+
+// CosignVerifier verifies signatures made with cosign.
+type CosignVerifier struct {
+	key      crypto.PublicKey
+	roots    *x509.CertPool
+	rekorKey crypto.PublicKey
+	identity *regexp.Regexp
+	issuer   string
+}
+
+// Object identifiers of the OIDC issuer in Fulcio certificates.
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// NewCosignVerifier creates a CosignVerifier from the given options.
+func NewCosignVerifier(opts CosignOptions) (*CosignVerifier, error) {
+	v := &CosignVerifier{issuer: opts.Issuer}
+	if opts.KeyFile != "" {
+		key, err := loadPublicKey(opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.key = key
+		return v, nil
+	}
+
+	if opts.RootsFile == "" || opts.RekorKeyFile == "" {
+		return nil, errors.New("a public key, or certificate roots and a transparency log key for keyless signatures, are required to verify cosign signatures")
+	}
+	if opts.Identity == "" || opts.Issuer == "" {
+		return nil, errors.New("an identity and an issuer are required to verify keyless cosign signatures")
+	}
+	b, err := ioutil.ReadFile(opts.RootsFile)
+	if err != nil {
+		return nil, err
+	}
+	v.roots = x509.NewCertPool()
+	if !v.roots.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("no certificates found in %s", opts.RootsFile)
+	}
+	if v.rekorKey, err = loadPublicKey(opts.RekorKeyFile); err != nil {
+		return nil, err
+	}
+	if v.identity, err = regexp.Compile("^(?:" + opts.Identity + ")$"); err != nil {
+		return nil, errors.Wrap(err, "invalid identity")
+	}
+	return v, nil
+}
+
+// VerifyBlob verifies a detached signature over a chart archive, as made by
+// 'cosign sign-blob'.
+func (v *CosignVerifier) VerifyBlob(archive []byte, sig *Signature) (*SignatureVerification, error) {
+	ver, err := v.verify(archive, sig)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(archive)
+	ver.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	return ver, nil
+}
+
+// VerifyManifest verifies that one of sigs, as made by 'cosign sign', signs
+// the OCI manifest with the given digest.
+func (v *CosignVerifier) VerifyManifest(digest string, sigs []*Signature) (*SignatureVerification, error) {
+	if len(sigs) == 0 {
+		return nil, errors.Errorf("no signatures found for %s", digest)
+	}
+	var errs []error
+	for _, sig := range sigs {
+		var payload struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal(sig.Payload, &payload); err != nil {
+			errs = append(errs, errors.Wrap(err, "invalid signature payload"))
+			continue
+		}
+		if payload.Critical.Image.DockerManifestDigest != digest {
+			errs = append(errs, errors.Errorf("signature is for %s", payload.Critical.Image.DockerManifestDigest))
+			continue
+		}
+		ver, err := v.verify(sig.Payload, sig)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ver.Digest = digest
+		return ver, nil
+	}
+	return nil, errors.Errorf("none of the %d signatures of %s could be verified. First error is: %s", len(sigs), digest, errs[0])
+}
+
+// VerifyAttestations verifies attestations, as made by 'cosign attest', for
+// the OCI manifest with the given digest. Attestations that cannot be
+// verified or are about other artifacts are left out.
+func (v *CosignVerifier) VerifyAttestations(digest string, atts []*Signature) ([]*Statement, error) {
+	var statements []*Statement
+	for _, att := range atts {
+		var env struct {
+			PayloadType string `json:"payloadType"`
+			Payload     string `json:"payload"`
+			Signatures  []struct {
+				Sig string `json:"sig"`
+			} `json:"signatures"`
+		}
+		if err := json.Unmarshal(att.Payload, &env); err != nil {
+			continue
+		}
+		payload, err := base64.StdEncoding.DecodeString(env.Payload)
+		if err != nil {
+			continue
+		}
+		pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(env.PayloadType), env.PayloadType, len(payload), payload)
+		verified := false
+		for _, s := range env.Signatures {
+			raw, err := base64.StdEncoding.DecodeString(s.Sig)
+			if err != nil {
+				continue
+			}
+			sig := *att
+			sig.Signature = raw
+			if _, err := v.verify([]byte(pae), &sig); err == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			continue
+		}
+		var st Statement
+		if err := json.Unmarshal(payload, &st); err != nil {
+			continue
+		}
+		for _, s := range st.Subject {
+			if "sha256:"+s.Digest["sha256"] == digest {
+				statements = append(statements, &st)
+				break
+			}
+		}
+	}
+	return statements, nil
+}
+
+// verify checks sig.Signature over signed, with the key of the verifier or
+// the signing certificate of keyless signatures.
+func (v *CosignVerifier) verify(signed []byte, sig *Signature) (*SignatureVerification, error) {
+	if v.key != nil {
+		if err := verifySignature(v.key, signed, sig.Signature); err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKIXPublicKey(v.key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		return &SignatureVerification{Signer: hex.EncodeToString(sum[:])}, nil
+	}
+
+	if len(sig.Certificate) == 0 {
+		return nil, errors.New("keyless signature has no certificate")
+	}
+	cert, err := parseCertificate(sig.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	signedAt, err := v.verifyBundle(sig, cert)
+	if err != nil {
+		return nil, err
+	}
+	intermediates := x509.NewCertPool()
+	intermediates.AppendCertsFromPEM(sig.Chain)
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   signedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, errors.Wrap(err, "invalid signing certificate")
+	}
+
+	var identity string
+	for _, id := range append(cert.EmailAddresses, uriStrings(cert)...) {
+		if v.identity.MatchString(id) {
+			identity = id
+			break
+		}
+	}
+	if identity == "" {
+		return nil, errors.Errorf("signing certificate identity does not match %s", v.identity)
+	}
+	if issuer := certificateIssuer(cert); issuer != v.issuer {
+		return nil, errors.Errorf("signing certificate was issued for %q, not %q", issuer, v.issuer)
+	}
+	if err := verifySignature(cert.PublicKey, signed, sig.Signature); err != nil {
+		return nil, err
+	}
+	return &SignatureVerification{Signer: identity, Issuer: v.issuer}, nil
+}
+
+// verifyBundle checks that the signature was recorded in the transparency
+// log while its certificate was valid, and returns the time it was recorded
+// at.
+func (v *CosignVerifier) verifyBundle(sig *Signature, cert *x509.Certificate) (time.Time, error) {
+	if len(sig.Bundle) == 0 {
+		return time.Time{}, errors.New("keyless signature has no transparency log entry")
+	}
+	var bundle struct {
+		SignedEntryTimestamp []byte `json:"SignedEntryTimestamp"`
+		Payload              struct {
+			Body           string `json:"body"`
+			IntegratedTime int64  `json:"integratedTime"`
+			LogID          string `json:"logID"`
+			LogIndex       int64  `json:"logIndex"`
+		} `json:"Payload"`
+	}
+	if err := json.Unmarshal(sig.Bundle, &bundle); err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid transparency log entry")
+	}
+	// The timestamp signs the canonical JSON encoding of the payload, whose
+	// keys are sorted; the struct fields above are declared in that order.
+	canonical, err := json.Marshal(bundle.Payload)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := verifySignature(v.rekorKey, canonical, bundle.SignedEntryTimestamp); err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid transparency log entry")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(bundle.Payload.Body)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid transparency log entry")
+	}
+	var entry struct {
+		Spec struct {
+			Signature struct {
+				Content   string `json:"content"`
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &entry); err != nil {
+		return time.Time{}, errors.Wrap(err, "invalid transparency log entry")
+	}
+	if entry.Spec.Signature.Content != base64.StdEncoding.EncodeToString(sig.Signature) {
+		return time.Time{}, errors.New("transparency log entry is for another signature")
+	}
+	if logged, err := base64.StdEncoding.DecodeString(entry.Spec.Signature.PublicKey.Content); err == nil {
+		if c, err := parseCertificate(logged); err == nil && !c.Equal(cert) {
+			return time.Time{}, errors.New("transparency log entry is for another certificate")
+		}
+	}
+	return time.Unix(bundle.Payload.IntegratedTime, 0), nil
+}
+
+func verifySignature(key crypto.PublicKey, signed, sig []byte) error {
+	sum := sha256.Sum256(signed)
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, sum[:], sig) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig); err != nil {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, signed, sig) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.Errorf("unsupported public key type %T", key)
+	}
+	return nil
+}
+
+func loadPublicKey(filename string) (crypto.PublicKey, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.Errorf("no PEM encoded public key found in %s", filename)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse public key in %s", filename)
+	}
+	return key, nil
+}
+
+// parseCertificate parses a PEM encoded certificate. Cosign also writes
+// certificates as base64 encoded PEM, which is accepted as well.
+func parseCertificate(b []byte) (*x509.Certificate, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("-----BEGIN")) {
+		if decoded, err := base64.StdEncoding.DecodeString(string(b)); err == nil {
+			b = decoded
+		}
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func uriStrings(cert *x509.Certificate) []string {
+	uris := make([]string, 0, len(cert.URIs))
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+	return uris
+}
+
+// certificateIssuer returns the OIDC issuer recorded in a Fulcio
+// certificate.
+func certificateIssuer(cert *x509.Certificate) string {
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				return issuer
+			}
+		case ext.Id.Equal(oidIssuer):
+			return string(ext.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,275 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provenance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePublicKey(t *testing.T, dir, name string, key *ecdsa.PrivateKey) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	t.Helper()
+	sum := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func simpleSigningPayload(digest string) []byte {
+	return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"example.com/charts/hello"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"},"optional":null}`, digest))
+}
+
+func TestCosignVerifierKey(t *testing.T) {
+	dir := t.TempDir()
+	key := newKey(t)
+	v, err := NewCosignVerifier(CosignOptions{KeyFile: writePublicKey(t, dir, "cosign.pub", key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := []byte("chart archive")
+	ver, err := v.VerifyBlob(archive, &Signature{Signature: sign(t, key, archive)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(archive)
+	if ver.Digest != fmt.Sprintf("sha256:%x", sum) {
+		t.Errorf("unexpected digest %s", ver.Digest)
+	}
+	if _, err := v.VerifyBlob([]byte("tampered"), &Signature{Signature: sign(t, key, archive)}); err == nil {
+		t.Error("expected a signature over other content to fail")
+	}
+	if _, err := v.VerifyBlob(archive, &Signature{Signature: sign(t, newKey(t), archive)}); err == nil {
+		t.Error("expected a signature made with another key to fail")
+	}
+
+	digest := "sha256:" + fmt.Sprintf("%x", sha256.Sum256([]byte("manifest")))
+	payload := simpleSigningPayload(digest)
+	other := simpleSigningPayload("sha256:0000")
+	sigs := []*Signature{
+		{Payload: other, Signature: sign(t, key, other)},
+		{Payload: payload, Signature: sign(t, key, payload)},
+	}
+	if ver, err = v.VerifyManifest(digest, sigs); err != nil {
+		t.Fatal(err)
+	}
+	if ver.Digest != digest {
+		t.Errorf("expected digest %s, got %s", digest, ver.Digest)
+	}
+	if _, err := v.VerifyManifest(digest, sigs[:1]); err == nil {
+		t.Error("expected a signature of another manifest to fail")
+	}
+	if _, err := v.VerifyManifest(digest, nil); err == nil {
+		t.Error("expected a manifest without signatures to fail")
+	}
+}
+
+func TestCosignVerifierAttestations(t *testing.T) {
+	dir := t.TempDir()
+	key := newKey(t)
+	v, err := NewCosignVerifier(CosignOptions{KeyFile: writePublicKey(t, dir, "cosign.pub", key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hex := fmt.Sprintf("%x", sha256.Sum256([]byte("manifest")))
+	envelope := func(signer *ecdsa.PrivateKey, subject string) *Signature {
+		statement := []byte(fmt.Sprintf(`{"_type":"https://in-toto.io/Statement/v0.1","predicateType":"https://slsa.dev/provenance/v0.2","subject":[{"name":"example.com/charts/hello","digest":{"sha256":%q}}],"predicate":{}}`, subject))
+		payloadType := "application/vnd.in-toto+json"
+		pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(statement), statement)
+		b, _ := json.Marshal(map[string]interface{}{
+			"payloadType": payloadType,
+			"payload":     base64.StdEncoding.EncodeToString(statement),
+			"signatures":  []map[string]string{{"sig": base64.StdEncoding.EncodeToString(sign(t, signer, []byte(pae)))}},
+		})
+		return &Signature{Payload: b}
+	}
+
+	statements, err := v.VerifyAttestations("sha256:"+hex, []*Signature{
+		envelope(key, hex),
+		envelope(newKey(t), hex),
+		envelope(key, "0000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("expected 1 verified statement, got %d", len(statements))
+	}
+	if statements[0].PredicateType != "https://slsa.dev/provenance/v0.2" {
+		t.Errorf("unexpected predicate type %s", statements[0].PredicateType)
+	}
+}
+
+func TestCosignVerifierKeyless(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	rootKey := newKey(t)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := x509.ParseCertificate(rootDER)
+	rootsFile := filepath.Join(dir, "roots.pem")
+	if err := ioutil.WriteFile(rootsFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rekorKey := newKey(t)
+
+	signedAt := now.Add(-30 * time.Minute)
+	issuer, _ := asn1.Marshal("https://token.actions.githubusercontent.com")
+	signingKey := newKey(t)
+	certDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       signedAt.Add(-time.Minute),
+		NotAfter:        signedAt.Add(10 * time.Minute),
+		EmailAddresses:  []string{"release@example.com"},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: issuer}},
+	}, root, &signingKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	archive := []byte("chart archive")
+	raw := sign(t, signingKey, archive)
+	bundle := func(integratedTime time.Time, signer *ecdsa.PrivateKey) []byte {
+		body, _ := json.Marshal(map[string]interface{}{
+			"apiVersion": "0.0.1",
+			"kind":       "hashedrekord",
+			"spec": map[string]interface{}{
+				"signature": map[string]interface{}{
+					"content":   base64.StdEncoding.EncodeToString(raw),
+					"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString(certPEM)},
+				},
+			},
+		})
+		payload := map[string]interface{}{
+			"body":           base64.StdEncoding.EncodeToString(body),
+			"integratedTime": integratedTime.Unix(),
+			"logID":          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+			"logIndex":       42,
+		}
+		canonical, _ := json.Marshal(payload)
+		b, _ := json.Marshal(map[string]interface{}{
+			"SignedEntryTimestamp": sign(t, signer, canonical),
+			"Payload":              payload,
+		})
+		return b
+	}
+
+	opts := CosignOptions{
+		RootsFile:    rootsFile,
+		RekorKeyFile: writePublicKey(t, dir, "rekor.pub", rekorKey),
+		Identity:     `.*@example\.com`,
+		Issuer:       "https://token.actions.githubusercontent.com",
+	}
+	v, err := NewCosignVerifier(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &Signature{Signature: raw, Certificate: certPEM, Bundle: bundle(signedAt, rekorKey)}
+	ver, err := v.VerifyBlob(archive, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ver.Signer != "release@example.com" || ver.Issuer != opts.Issuer {
+		t.Errorf("unexpected signer %q from %q", ver.Signer, ver.Issuer)
+	}
+
+	// The certificate is only valid around the time the signature was
+	// recorded at.
+	if _, err := v.VerifyBlob(archive, &Signature{Signature: raw, Certificate: certPEM, Bundle: bundle(now, rekorKey)}); err == nil {
+		t.Error("expected a signature recorded after the certificate expired to fail")
+	}
+	if _, err := v.VerifyBlob(archive, &Signature{Signature: raw, Certificate: certPEM, Bundle: bundle(signedAt, newKey(t))}); err == nil {
+		t.Error("expected a log entry not signed by the transparency log to fail")
+	}
+	if _, err := v.VerifyBlob(archive, &Signature{Signature: raw, Certificate: certPEM}); err == nil {
+		t.Error("expected a signature without log entry to fail")
+	}
+
+	for name, o := range map[string]CosignOptions{
+		"identity": {RootsFile: opts.RootsFile, RekorKeyFile: opts.RekorKeyFile, Identity: `.*@example\.org`, Issuer: opts.Issuer},
+		"issuer":   {RootsFile: opts.RootsFile, RekorKeyFile: opts.RekorKeyFile, Identity: opts.Identity, Issuer: "https://accounts.google.com"},
+	} {
+		v, err := NewCosignVerifier(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.VerifyBlob(archive, sig); err == nil {
+			t.Errorf("expected a mismatched %s to fail", name)
+		}
+	}
+
+	if _, err := NewCosignVerifier(CosignOptions{RootsFile: opts.RootsFile, RekorKeyFile: opts.RekorKeyFile}); err == nil {
+		t.Error("expected keyless verification without identity to be rejected")
+	}
+	if _, err := NewCosignVerifier(CosignOptions{}); err == nil {
+		t.Error("expected verification without key or roots to be rejected")
+	}
+}
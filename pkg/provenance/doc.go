@@ -33,5 +33,16 @@ and using `gpg --verify`, `keybase pgp verify`, or similar:
 	$  gpg --verify some.sig
 	gpg: Signature made Mon Jul 25 17:23:44 2016 MDT using RSA key ID 1FC18762
 	gpg: Good signature from "Helm Testing (This key should only be used for testing. DO NOT TRUST.) <helm-testing@helm.sh>" [ultimate]
+
+Signatures can be verified without a keyring on the filesystem by creating a
+Signatory from armored public keys with NewFromArmoredKeys, and by verifying
+charts and provenance files held in memory with VerifyData. Charts can be
+signed without the private key ever entering the process by setting an
+ExternalSigner, such as GPGAgentSigner, on the Signatory.
+
+Charts may also be signed with cosign, either with a key pair or keylessly with
+a short-lived certificate recorded in a transparency log. CosignVerifier
+verifies the signatures and attestations cosign attaches to charts stored in
+OCI registries, and detached signatures of chart archives.
 */
 package provenance // import "github.com/open-hand/helm/pkg/provenance"
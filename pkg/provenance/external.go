@@ -0,0 +1,155 @@
+/*
+Copyright The Helm Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp/armor"     //nolint
+	"golang.org/x/crypto/openpgp/clearsign" //nolint
+	"golang.org/x/crypto/openpgp/packet"    //nolint
+)
+
+// ExternalSigner signs messages with a private key that Helm has no access
+// to, such as one held by gpg-agent, a smartcard or a signing service.
+type ExternalSigner interface {
+	// DetachSign returns an ASCII armored OpenPGP detached signature of
+	// message.
+	DetachSign(message []byte) ([]byte, error)
+}
+
+// ExternalSignerFunc is a function that implements ExternalSigner.
+type ExternalSignerFunc func(message []byte) ([]byte, error)
+
+// DetachSign calls f(message).
+func (f ExternalSignerFunc) DetachSign(message []byte) ([]byte, error) {
+	return f(message)
+}
+
+// GPGAgentSigner signs messages with the gpg command, which leaves the
+// private key to gpg-agent. The agent takes care of asking for passphrases
+// and of smartcards.
+type GPGAgentSigner struct {
+	// Path is the path to the gpg command. It defaults to "gpg".
+	Path string
+	// KeyID identifies the key to sign with, as accepted by gpg --local-user.
+	// The default key of gpg is used when it is empty.
+	KeyID string
+	// Homedir is the GnuPG home directory. It defaults to the one of gpg.
+	Homedir string
+}
+
+// DetachSign signs message with gpg.
+func (g *GPGAgentSigner) DetachSign(message []byte) ([]byte, error) {
+	path := g.Path
+	if path == "" {
+		path = "gpg"
+	}
+	args := []string{"--batch", "--yes", "--armor", "--detach-sign", "--digest-algo", "SHA512"}
+	if g.Homedir != "" {
+		args = append(args, "--homedir", g.Homedir)
+	}
+	if g.KeyID != "" {
+		args = append(args, "--local-user", g.KeyID)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(message)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "gpg failed to sign: %s", strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// hashNames are the names of hashes in the headers of clear signed messages.
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA1:   "SHA1",
+	crypto.SHA224: "SHA224",
+	crypto.SHA256: "SHA256",
+	crypto.SHA384: "SHA384",
+	crypto.SHA512: "SHA512",
+}
+
+// clearSignExternal clear signs message with signer. The signer signs the
+// message in its canonical form, with lines ending in CRLF and without
+// trailing whitespace, which is how it is checked after decoding.
+func clearSignExternal(message []byte, signer ExternalSigner) (string, error) {
+	text := strings.TrimSuffix(string(message), "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	sig, err := signer.DetachSign([]byte(strings.Join(lines, "\r\n")))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign message block")
+	}
+	hash, err := signatureHash(sig)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "-----BEGIN PGP SIGNED MESSAGE-----\nHash: %s\n\n", hash)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "-") {
+			out.WriteString("- ")
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	out.Write(bytes.TrimSpace(sig))
+	out.WriteString("\n")
+
+	if block, _ := clearsign.Decode([]byte(out.String())); block == nil {
+		return "", errors.New("external signer returned an invalid signature")
+	}
+	return out.String(), nil
+}
+
+// signatureHash returns the name of the hash of an armored signature.
+func signatureHash(sig []byte) (string, error) {
+	block, err := armor.Decode(bytes.NewReader(sig))
+	if err != nil {
+		return "", errors.Wrap(err, "external signer returned an invalid signature")
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "external signer returned an invalid signature")
+	}
+	var hash crypto.Hash
+	switch sig := p.(type) {
+	case *packet.Signature:
+		hash = sig.Hash
+	case *packet.SignatureV3:
+		hash = sig.Hash
+	default:
+		return "", errors.Errorf("external signer returned a %T instead of a signature", p)
+	}
+	name, ok := hashNames[hash]
+	if !ok {
+		return "", errors.Errorf("unsupported signature hash %s", hash)
+	}
+	return name, nil
+}
@@ -74,6 +74,9 @@ type Signatory struct {
 	Entity *openpgp.Entity
 	// The keyring for this instance of Helm. This is used for verification.
 	KeyRing openpgp.EntityList
+	// Signer signs on behalf of this instance of Helm when the private key is
+	// held outside of it, such as by gpg-agent. It takes precedence over Entity.
+	Signer ExternalSigner
 }
 
 // NewFromFiles constructs a new Signatory from the PGP key in the given filename.
@@ -102,6 +105,24 @@ func NewFromFiles(keyfile, keyringfile string) (*Signatory, error) {
 	}, nil
 }
 
+// NewFromArmoredKeys creates a Signatory that verifies signatures with the
+// given ASCII armored public keys, without reading a keyring from the
+// filesystem. Each key may hold several entities.
+func NewFromArmoredKeys(keys ...[]byte) (*Signatory, error) {
+	s := &Signatory{}
+	for i, key := range keys {
+		ring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read armored key %d", i)
+		}
+		s.KeyRing = append(s.KeyRing, ring...)
+	}
+	if len(s.KeyRing) == 0 {
+		return nil, errors.New("no keys to verify signatures with")
+	}
+	return s, nil
+}
+
 // NewFromKeyring reads a keyring file and creates a Signatory.
 //
 // If id is not the empty string, this will also try to find an Entity in the
@@ -197,13 +218,15 @@ func (s *Signatory) DecryptKey(fn PassphraseFetcher) error {
 //
 // This takes the path to a chart archive file and a key, and it returns a clear signature.
 //
-// The Signatory must have a valid Entity.PrivateKey for this to work. If it does
-// not, an error will be returned.
+// The Signatory must have a valid Entity.PrivateKey or a Signer for this to
+// work. If it does not, an error will be returned.
 func (s *Signatory) ClearSign(chartpath string) (string, error) {
-	if s.Entity == nil {
-		return "", errors.New("private key not found")
-	} else if s.Entity.PrivateKey == nil {
-		return "", errors.New("provided key is not a private key. Try providing a keyring with secret keys")
+	if s.Signer == nil {
+		if s.Entity == nil {
+			return "", errors.New("private key not found")
+		} else if s.Entity.PrivateKey == nil {
+			return "", errors.New("provided key is not a private key. Try providing a keyring with secret keys")
+		}
 	}
 
 	if fi, err := os.Stat(chartpath); err != nil {
@@ -219,6 +242,10 @@ func (s *Signatory) ClearSign(chartpath string) (string, error) {
 		return "", err
 	}
 
+	if s.Signer != nil {
+		return clearSignExternal(b.Bytes(), s.Signer)
+	}
+
 	// Sign the buffer
 	w, err := clearsign.Encode(out, s.Entity.PrivateKey, &defaultPGPConfig)
 	if err != nil {
@@ -261,24 +288,48 @@ func (s *Signatory) Verify(chartpath, sigpath string) (*Verification, error) {
 		return ver, errors.Wrap(err, "failed to decode signature")
 	}
 
-	by, err := s.verifySignature(sig)
+	sum, err := DigestFile(chartpath)
 	if err != nil {
 		return ver, err
 	}
-	ver.SignedBy = by
+	return s.verify(sig, sum, filepath.Base(chartpath))
+}
 
-	// Second, verify the hash of the tarball.
-	sum, err := DigestFile(chartpath)
+// VerifyData checks the signature of a provenance file held in memory and
+// verifies that it is legit for the chart archive with the given file name.
+//
+// Along with NewFromArmoredKeys, it allows verifying charts without any
+// keyring, chart or provenance file on the filesystem.
+func (s *Signatory) VerifyData(archive []byte, filename string, provData []byte) (*Verification, error) {
+	block, _ := clearsign.Decode(provData)
+	if block == nil {
+		return &Verification{}, errors.Wrap(errors.New("signature block not found"), "failed to decode signature")
+	}
+
+	sum, err := Digest(bytes.NewReader(archive))
+	if err != nil {
+		return &Verification{}, err
+	}
+	return s.verify(block, sum, filepath.Base(filename))
+}
+
+// verify checks the signature block, and that it holds the given sum for the
+// chart archive named basename.
+func (s *Signatory) verify(sig *clearsign.Block, sum, basename string) (*Verification, error) {
+	ver := &Verification{}
+	by, err := s.verifySignature(sig)
 	if err != nil {
 		return ver, err
 	}
+	ver.SignedBy = by
+
+	// Second, verify the hash of the tarball.
 	_, sums, err := parseMessageBlock(sig.Plaintext)
 	if err != nil {
 		return ver, err
 	}
 
 	sum = "sha256:" + sum
-	basename := filepath.Base(chartpath)
 	if sha, ok := sums.Files[basename]; !ok {
 		return ver, errors.Errorf("provenance does not contain a SHA for a file named %q", basename)
 	} else if sha != sum {
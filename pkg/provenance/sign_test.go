@@ -21,10 +21,13 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"golang.org/x/crypto/openpgp"                  //nolint
+	"golang.org/x/crypto/openpgp/armor"            //nolint
 	pgperrors "golang.org/x/crypto/openpgp/errors" //nolint
 )
 
@@ -343,3 +346,137 @@ func readSumFile(sumfile string) (string, error) {
 	parts := strings.SplitN(sig, " ", 2)
 	return parts[0], nil
 }
+
+func TestNewFromArmoredKeys(t *testing.T) {
+	if _, err := NewFromArmoredKeys(); err == nil {
+		t.Error("expected a signatory without keys to be rejected")
+	}
+	if _, err := NewFromArmoredKeys([]byte("not a key")); err == nil {
+		t.Error("expected an invalid key to be rejected")
+	}
+
+	signer, err := NewFromArmoredKeys(armoredKey(t, testPubfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := ioutil.ReadFile(testChartfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ioutil.ReadFile(testSigBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ver, err := signer.VerifyData(archive, testChartfile, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ver.SignedBy.Identities[testKeyName]; !ok {
+		t.Errorf("Expected identity %q", testKeyName)
+	}
+	if ver.FileName != filepath.Base(testChartfile) {
+		t.Errorf("FileName is unexpectedly %q", ver.FileName)
+	}
+
+	if _, err := signer.VerifyData([]byte("tampered"), testChartfile, sig); err == nil {
+		t.Error("expected the verification of another archive to fail")
+	}
+	tampered, err := ioutil.ReadFile(testTamperedSigBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.VerifyData(archive, testChartfile, tampered); err == nil {
+		t.Errorf("Expected %s to fail.", testTamperedSigBlock)
+	}
+}
+
+func TestClearSignExternal(t *testing.T) {
+	key, err := NewFromFiles(testKeyfile, testPubfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &Signatory{Signer: ExternalSignerFunc(func(message []byte) ([]byte, error) {
+		var out strings.Builder
+		err := openpgp.ArmoredDetachSign(&out, key.Entity, strings.NewReader(string(message)), &defaultPGPConfig)
+		return []byte(out.String()), err
+	})}
+
+	sig, err := signer.ClearSign(testChartfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sig, testMessageBlock) {
+		t.Errorf("expected message block to be in sig: %s", sig)
+	}
+	if !strings.Contains(sig, "Hash: SHA512") {
+		t.Errorf("expected the hash of the signature in sig: %s", sig)
+	}
+
+	archive, err := ioutil.ReadFile(testChartfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.VerifyData(archive, testChartfile, []byte(sig)); err != nil {
+		t.Errorf("Failed to verify the external signature: %s", err)
+	}
+
+	signer.Signer = ExternalSignerFunc(func([]byte) ([]byte, error) {
+		return []byte("not a signature"), nil
+	})
+	if _, err := signer.ClearSign(testChartfile); err == nil {
+		t.Error("expected an invalid external signature to be rejected")
+	}
+}
+
+func TestGPGAgentSigner(t *testing.T) {
+	gpg, err := exec.LookPath("gpg")
+	if err != nil {
+		t.Skip("gpg is not installed")
+	}
+	homedir, err := ioutil.TempDir("", "gpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		exec.Command("gpgconf", "--homedir", homedir, "--kill", "gpg-agent").Run()
+		os.RemoveAll(homedir)
+	}()
+	if out, err := exec.Command(gpg, "--batch", "--homedir", homedir, "--import", testKeyfile).CombinedOutput(); err != nil {
+		t.Skipf("unable to import the test key: %s", out)
+	}
+
+	signer := &Signatory{Signer: &GPGAgentSigner{Path: gpg, KeyID: "helm-testing@helm.sh", Homedir: homedir}}
+	sig, err := signer.ClearSign(testChartfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := NewFromFiles(testKeyfile, testPubfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := ioutil.ReadFile(testChartfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.VerifyData(archive, testChartfile, []byte(sig)); err != nil {
+		t.Errorf("Failed to verify the signature made by gpg: %s", err)
+	}
+}
+
+// armoredKey returns the ASCII armored form of the key in a binary key file.
+func armoredKey(t *testing.T, keyfile string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(keyfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	w, err := armor.Encode(&out, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	return []byte(out.String())
+}
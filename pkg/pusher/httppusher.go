@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pusher
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/tlsutil"
+	"github.com/open-hand/helm/internal/version"
+)
+
+// HTTPPusher uploads charts to chart repositories served over HTTP that
+// implement the upload API of ChartMuseum, such as ChartMuseum itself and
+// Harbor.
+//
+// The chart is posted, with its provenance file if it has one, as a
+// multipart form to the "api" endpoint of the repository: a chart pushed to
+// https://charts.example.com/org/repo is posted to
+// https://charts.example.com/api/org/repo/charts, and a chart pushed to the
+// Harbor repository https://harbor.example.com/chartrepo/project to
+// https://harbor.example.com/api/chartrepo/project/charts.
+type HTTPPusher struct {
+	opts options
+}
+
+// Push performs a Push from repo.Pusher.
+func (pusher *HTTPPusher) Push(chartRef, href string, options ...Option) error {
+	for _, opt := range options {
+		opt(&pusher.opts)
+	}
+	return pusher.push(chartRef, href)
+}
+
+func (pusher *HTTPPusher) push(chartRef, href string) error {
+	stat, err := os.Stat(chartRef)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.Errorf("%s: no such file", chartRef)
+		}
+		return err
+	}
+	if stat.IsDir() {
+		return errors.New("cannot push directory, must provide chart archive (.tgz)")
+	}
+
+	u, err := UploadURL(href)
+	if err != nil {
+		return err
+	}
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	if err := addFormFile(w, "chart", chartRef); err != nil {
+		return err
+	}
+	if _, err := os.Stat(chartRef + ".prov"); err == nil {
+		if err := addFormFile(w, "prov", chartRef+".prov"); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("User-Agent", version.GetUserAgent())
+	if pusher.opts.username != "" || pusher.opts.password != "" {
+		req.SetBasicAuth(pusher.opts.username, pusher.opts.password)
+	}
+
+	client, err := pusher.httpClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("failed to push %s to %s : %s", filepath.Base(chartRef), href, responseError(resp))
+	}
+	return nil
+}
+
+// UploadURL returns the URL of the upload API of the chart repository at
+// repoURL.
+func UploadURL(repoURL string) (string, error) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid repository URL %s", repoURL)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.Errorf("invalid repository URL %s", repoURL)
+	}
+	p := strings.Trim(u.Path, "/")
+	if p != "" {
+		p += "/"
+	}
+	u.Path = "/api/" + p + "charts"
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// addFormFile adds the content of the file at path to the form w as field.
+func addFormFile(w *multipart.Writer, field, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	part, err := w.CreateFormFile(field, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, f)
+	return err
+}
+
+// responseError returns the status of the failed response, with the error
+// message returned by ChartMuseum if any.
+func responseError(resp *http.Response) string {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		return resp.Status + ": " + body.Error
+	}
+	return resp.Status
+}
+
+func (pusher *HTTPPusher) httpClient() (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if (pusher.opts.certFile != "" && pusher.opts.keyFile != "") || pusher.opts.caFile != "" {
+		tlsConf, err := tlsutil.NewClientTLS(pusher.opts.certFile, pusher.opts.keyFile, pusher.opts.caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't create TLS config for client")
+		}
+		transport.TLSClientConfig = tlsConf
+	}
+	if pusher.opts.insecureSkipVerifyTLS {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// NewHTTPPusher constructs a valid ChartMuseum API client as a Pusher
+func NewHTTPPusher(ops ...Option) (Pusher, error) {
+	var client HTTPPusher
+
+	for _, opt := range ops {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pusher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadURL(t *testing.T) {
+	tests := map[string]string{
+		"https://charts.example.com":                 "https://charts.example.com/api/charts",
+		"https://charts.example.com/":                "https://charts.example.com/api/charts",
+		"https://charts.example.com/org/repo":        "https://charts.example.com/api/org/repo/charts",
+		"https://harbor.example.com/chartrepo/proj/": "https://harbor.example.com/api/chartrepo/proj/charts",
+	}
+	for repoURL, want := range tests {
+		got, err := UploadURL(repoURL)
+		if err != nil {
+			t.Errorf("%s: %s", repoURL, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", repoURL, want, got)
+		}
+	}
+	if _, err := UploadURL("charts.example.com/org"); err == nil {
+		t.Error("expected an error for a URL without a scheme")
+	}
+}
+
+func TestHTTPPusher(t *testing.T) {
+	var files map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/org/charts" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		files = map[string]string{}
+		for field, headers := range r.MultipartForm.File {
+			files[field] = headers[0].Filename
+		}
+		if _, ok := files["chart"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"no chart"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"saved":true}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewHTTPPusher(WithBasicAuth("user", "pass"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Push("../../cmd/helm/testdata/testcharts/signtest-0.1.0.tgz", srv.URL+"/org"); err != nil {
+		t.Fatal(err)
+	}
+	if files["chart"] != "signtest-0.1.0.tgz" || files["prov"] != "signtest-0.1.0.tgz.prov" {
+		t.Errorf("expected the chart and its provenance to be uploaded, got %v", files)
+	}
+
+	files = nil
+	if err := p.Push("../../cmd/helm/testdata/testcharts/compressedchart-0.1.0.tgz", srv.URL+"/org"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["prov"]; ok || files["chart"] != "compressedchart-0.1.0.tgz" {
+		t.Errorf("expected only the chart to be uploaded, got %v", files)
+	}
+
+	p, err = NewHTTPPusher(WithBasicAuth("user", "wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Push("../../cmd/helm/testdata/testcharts/compressedchart-0.1.0.tgz", srv.URL+"/org")
+	if err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("expected an authorization error, got %v", err)
+	}
+
+	if err := p.Push("../../cmd/helm/testdata/testcharts", srv.URL+"/org"); err == nil {
+		t.Error("expected an error pushing a directory")
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.WriteHeader(http.StatusConflict)
+	rec.Body.WriteString(`{"error":"signtest-0.1.0.tgz already exists"}`)
+	resp := rec.Result()
+	if got, want := responseError(resp), "409 Conflict: signtest-0.1.0.tgz already exists"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	rec = httptest.NewRecorder()
+	rec.WriteHeader(http.StatusInternalServerError)
+	rec.Body.WriteString("oops")
+	resp = rec.Result()
+	if got, want := responseError(resp), "500 Internal Server Error"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
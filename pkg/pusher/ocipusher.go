@@ -26,6 +26,7 @@ import (
 
 	"github.com/open-hand/helm/pkg/chart/loader"
 	"github.com/open-hand/helm/pkg/registry"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 // OCIPusher is the default OCI backend handler
@@ -75,6 +76,25 @@ func (pusher *OCIPusher) push(chartRef, href string) error {
 		pushOpts = append(pushOpts, registry.PushOptProvData(provBytes))
 	}
 
+	sbomRef := chartRef + sbom.FileSuffix
+	if _, err := os.Stat(sbomRef); err == nil {
+		sbomBytes, err := ioutil.ReadFile(sbomRef)
+		if err != nil {
+			return err
+		}
+		format, err := sbom.Detect(sbomBytes)
+		if err != nil {
+			return errors.Wrapf(err, "invalid SBOM %s", sbomRef)
+		}
+		pushOpts = append(pushOpts, registry.PushOptSBOM(sbomBytes, format.MediaType()))
+	} else if pusher.opts.sbomFormat != "" {
+		sbomBytes, err := sbom.Generate(meta, sbom.Options{Format: pusher.opts.sbomFormat})
+		if err != nil {
+			return errors.Wrap(err, "failed to generate SBOM")
+		}
+		pushOpts = append(pushOpts, registry.PushOptSBOM(sbomBytes, pusher.opts.sbomFormat.MediaType()))
+	}
+
 	ref := fmt.Sprintf("%s:%s",
 		path.Join(strings.TrimPrefix(href, fmt.Sprintf("%s://", registry.OCIScheme)), meta.Metadata.Name),
 		meta.Metadata.Version)
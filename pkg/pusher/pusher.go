@@ -21,13 +21,21 @@ import (
 
 	"github.com/open-hand/helm/pkg/cli"
 	"github.com/open-hand/helm/pkg/registry"
+	"github.com/open-hand/helm/pkg/sbom"
 )
 
 // options are generic parameters to be provided to the pusher during instantiation.
 //
 // Pushers may or may not ignore these parameters as they are passed in.
 type options struct {
-	registryClient *registry.Client
+	registryClient        *registry.Client
+	sbomFormat            sbom.Format
+	username              string
+	password              string
+	certFile              string
+	keyFile               string
+	caFile                string
+	insecureSkipVerifyTLS bool
 }
 
 // Option allows specifying various settings configurable by the user for overriding the defaults
@@ -41,6 +49,38 @@ func WithRegistryClient(client *registry.Client) Option {
 	}
 }
 
+// WithSBOMFormat sets the format of the software bill of materials generated
+// and attached to a chart that has none stored next to it.
+func WithSBOMFormat(format sbom.Format) Option {
+	return func(opts *options) {
+		opts.sbomFormat = format
+	}
+}
+
+// WithBasicAuth sets the request's Authorization header to use the provided credentials
+func WithBasicAuth(username, password string) Option {
+	return func(opts *options) {
+		opts.username = username
+		opts.password = password
+	}
+}
+
+// WithTLSClientConfig sets the client auth with the provided credentials.
+func WithTLSClientConfig(certFile, keyFile, caFile string) Option {
+	return func(opts *options) {
+		opts.certFile = certFile
+		opts.keyFile = keyFile
+		opts.caFile = caFile
+	}
+}
+
+// WithInsecureSkipVerifyTLS determines if a TLS Certificate will be checked
+func WithInsecureSkipVerifyTLS(insecureSkipVerifyTLS bool) Option {
+	return func(opts *options) {
+		opts.insecureSkipVerifyTLS = insecureSkipVerifyTLS
+	}
+}
+
 // Pusher is an interface to support upload to the specified URL.
 type Pusher interface {
 	// Push file content by url string
@@ -87,9 +127,14 @@ var ociProvider = Provider{
 	New:     NewOCIPusher,
 }
 
+var httpProvider = Provider{
+	Schemes: []string{"http", "https"},
+	New:     NewHTTPPusher,
+}
+
 // All finds all of the registered pushers as a list of Provider instances.
 // Currently, just the built-in pushers are collected.
 func All(settings *cli.EnvSettings) Providers {
-	result := Providers{ociProvider}
+	result := Providers{ociProvider, httpProvider}
 	return result
 }
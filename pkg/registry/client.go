@@ -25,9 +25,11 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"oras.land/oras-go/pkg/auth"
@@ -61,6 +63,8 @@ type (
 		authorizer         auth.Client
 		registryAuthorizer *registryauth.Client
 		resolver           remotes.Resolver
+		credentialProvider CredentialProvider
+		mirrors            *RegistriesConfig
 	}
 
 	// ClientOption allows specifying various settings configurable by the user for overriding the defaults
@@ -86,11 +90,11 @@ func NewClient(options ...ClientOption) (*Client, error) {
 		}
 		client.authorizer = authClient
 	}
+	if dockerClient, ok := client.authorizer.(*dockerauth.Client); ok {
+		client.credentialProvider = ChainCredentialProviders(client.credentialProvider, dockerClient)
+	}
 	if client.resolver == nil {
-		headers := http.Header{}
-		headers.Set("User-Agent", version.GetUserAgent())
-		opts := []auth.ResolverOption{auth.WithResolverHeaders(headers)}
-		resolver, err := client.authorizer.ResolverWithOpts(opts...)
+		resolver, err := client.newResolver()
 		if err != nil {
 			return nil, err
 		}
@@ -107,37 +111,53 @@ func NewClient(options ...ClientOption) (*Client, error) {
 			Header: http.Header{
 				"User-Agent": {version.GetUserAgent()},
 			},
-			Cache: cache,
-			Credential: func(ctx context.Context, reg string) (registryauth.Credential, error) {
-				dockerClient, ok := client.authorizer.(*dockerauth.Client)
-				if !ok {
-					return registryauth.EmptyCredential, errors.New("unable to obtain docker client")
-				}
-
-				username, password, err := dockerClient.Credential(reg)
-				if err != nil {
-					return registryauth.EmptyCredential, errors.New("unable to retrieve credentials")
-				}
-
-				// A blank returned username and password value is a bearer token
-				if username == "" && password != "" {
-					return registryauth.Credential{
-						RefreshToken: password,
-					}, nil
-				}
-
-				return registryauth.Credential{
-					Username: username,
-					Password: password,
-				}, nil
-
-			},
+			Cache:      cache,
+			Credential: registryCredential(client.credentialProvider),
 		}
 
 	}
 	return client, nil
 }
 
+// registryCredential adapts provider to the credential function of the
+// registry clients.
+func registryCredential(provider CredentialProvider) func(context.Context, string) (registryauth.Credential, error) {
+	return func(ctx context.Context, reg string) (registryauth.Credential, error) {
+		if provider == nil {
+			return registryauth.EmptyCredential, nil
+		}
+		username, password, err := provider.Credential(reg)
+		if err != nil {
+			return registryauth.EmptyCredential, errors.New("unable to retrieve credentials")
+		}
+
+		// A blank returned username and password value is a bearer token
+		if username == "" && password != "" {
+			return registryauth.Credential{
+				RefreshToken: password,
+			}, nil
+		}
+
+		return registryauth.Credential{
+			Username: username,
+			Password: password,
+		}, nil
+	}
+}
+
+// newResolver returns a resolver authorized with the credentials of the client.
+func (c *Client) newResolver() (remotes.Resolver, error) {
+	headers := http.Header{}
+	headers.Set("User-Agent", version.GetUserAgent())
+	if c.credentialProvider == nil {
+		return c.authorizer.ResolverWithOpts(auth.WithResolverHeaders(headers))
+	}
+	return docker.NewResolver(docker.ResolverOptions{
+		Credentials: c.credentialProvider.Credential,
+		Headers:     headers,
+	}), nil
+}
+
 // ClientOptDebug returns a function that sets the debug setting on client options set
 func ClientOptDebug(debug bool) ClientOption {
 	return func(client *Client) {
@@ -159,6 +179,15 @@ func ClientOptWriter(out io.Writer) ClientOption {
 	}
 }
 
+// ClientOptCredentialProvider returns a function that sets a provider of
+// credentials on a client options set. It is consulted before the
+// credentials file and the Docker config.
+func ClientOptCredentialProvider(provider CredentialProvider) ClientOption {
+	return func(client *Client) {
+		client.credentialProvider = provider
+	}
+}
+
 // ClientOptCredentialsFile returns a function that sets the credentialsFile setting on a client options set
 func ClientOptCredentialsFile(credentialsFile string) ClientOption {
 	return func(client *Client) {
@@ -246,6 +275,11 @@ type (
 		Chart    *descriptorPullSummaryWithMeta `json:"chart"`
 		Prov     *descriptorPullSummary         `json:"prov"`
 		Ref      string                         `json:"ref"`
+		// Mirror is the reference the chart was pulled from when it was
+		// pulled from a mirror of its registry.
+		Mirror string `json:"mirror,omitempty"`
+		// Annotations are the annotations of the manifest.
+		Annotations map[string]string `json:"annotations,omitempty"`
 	}
 
 	descriptorPullSummary struct {
@@ -299,18 +333,41 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 		allowedMediaTypes = append(allowedMediaTypes, ProvLayerMediaType)
 	}
 
-	var descriptors, layers []ocispec.Descriptor
-	registryStore := content.Registry{Resolver: c.resolver}
-
-	manifest, err := oras.Copy(ctx(c.out, c.debug), registryStore, parsedRef.String(), memoryStore, "",
-		oras.WithPullEmptyNameAllowed(),
-		oras.WithAllowedMediaTypes(allowedMediaTypes),
-		oras.WithLayerDescriptors(func(l []ocispec.Descriptor) {
-			layers = l
-		}))
+	// The chart is pulled from the mirrors of its registry, in order, then
+	// from the registry itself.
+	refs, err := c.mirrors.MirrorRefs(parsedRef)
 	if err != nil {
 		return nil, err
 	}
+	refs = append(refs, parsedRef)
+
+	var descriptors, layers []ocispec.Descriptor
+	var manifest ocispec.Descriptor
+	var pulledRef registry.Reference
+	for _, r := range refs {
+		resolver, err := c.resolverFor(r.Registry)
+		if err != nil {
+			return nil, err
+		}
+		registryStore := content.Registry{Resolver: resolver}
+		memoryStore = content.NewMemory()
+		manifest, err = oras.Copy(ctx(c.out, c.debug), registryStore, r.String(), memoryStore, "",
+			oras.WithPullEmptyNameAllowed(),
+			oras.WithAllowedMediaTypes(allowedMediaTypes),
+			oras.WithLayerDescriptors(func(l []ocispec.Descriptor) {
+				layers = l
+			}))
+		if err == nil {
+			pulledRef = r
+			break
+		}
+		if r == parsedRef {
+			return nil, err
+		}
+		if c.debug {
+			fmt.Fprintf(c.out, "Failed to pull %s from the mirror %s: %s\n", parsedRef.String(), r.String(), err)
+		}
+	}
 
 	descriptors = append(descriptors, manifest)
 	descriptors = append(descriptors, layers...)
@@ -366,11 +423,19 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 		Prov:  &descriptorPullSummary{},
 		Ref:   parsedRef.String(),
 	}
+	if pulledRef != parsedRef {
+		result.Mirror = pulledRef.String()
+	}
 	var getManifestErr error
 	if _, manifestData, ok := memoryStore.Get(manifest); !ok {
 		getManifestErr = errors.Errorf("Unable to retrieve blob with digest %s", manifest.Digest)
 	} else {
 		result.Manifest.Data = manifestData
+		var m ocispec.Manifest
+		if err := json.Unmarshal(manifestData, &m); err != nil {
+			return nil, errors.Wrap(err, "unable to parse manifest")
+		}
+		result.Annotations = m.Annotations
 	}
 	if getManifestErr != nil {
 		return nil, getManifestErr
@@ -417,6 +482,9 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	}
 
 	fmt.Fprintf(c.out, "Pulled: %s\n", result.Ref)
+	if result.Mirror != "" {
+		fmt.Fprintf(c.out, "Mirror: %s\n", result.Mirror)
+	}
 	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
 
 	if strings.Contains(result.Ref, "_") {
@@ -458,7 +526,10 @@ type (
 		Config   *descriptorPushSummary         `json:"config"`
 		Chart    *descriptorPushSummaryWithMeta `json:"chart"`
 		Prov     *descriptorPushSummary         `json:"prov"`
+		SBOM     *descriptorPushSummary         `json:"sbom,omitempty"`
 		Ref      string                         `json:"ref"`
+		// Annotations are the annotations set on the manifest.
+		Annotations map[string]string `json:"annotations,omitempty"`
 	}
 
 	descriptorPushSummary struct {
@@ -472,8 +543,12 @@ type (
 	}
 
 	pushOperation struct {
-		provData   []byte
-		strictMode bool
+		provData     []byte
+		strictMode   bool
+		annotations  map[string]string
+		creationTime time.Time
+		sbomData     []byte
+		sbomType     string
 	}
 )
 
@@ -527,7 +602,11 @@ func (c *Client) Push(data []byte, ref string, options ...PushOption) (*PushResu
 		descriptors = append(descriptors, provDescriptor)
 	}
 
-	manifestData, manifest, err := content.GenerateManifest(&configDescriptor, nil, descriptors...)
+	annotations, err := generateOCIAnnotations(meta, operation.annotations, operation.creationTime)
+	if err != nil {
+		return nil, err
+	}
+	manifestData, manifest, err := content.GenerateManifest(&configDescriptor, annotations, descriptors...)
 	if err != nil {
 		return nil, err
 	}
@@ -536,7 +615,14 @@ func (c *Client) Push(data []byte, ref string, options ...PushOption) (*PushResu
 		return nil, err
 	}
 
-	registryStore := content.Registry{Resolver: c.resolver}
+	// The resolver tracks the blobs it has pushed by digest alone, and would
+	// skip uploading a layer already pushed to another repository, leaving
+	// the manifest with unknown blobs. Use a fresh one for every push.
+	resolver, err := c.newResolver()
+	if err != nil {
+		return nil, err
+	}
+	registryStore := content.Registry{Resolver: resolver}
 	_, err = oras.Copy(ctx(c.out, c.debug), memoryStore, parsedRef.String(), registryStore, "",
 		oras.WithNameValidation(nil))
 	if err != nil {
@@ -556,9 +642,10 @@ func (c *Client) Push(data []byte, ref string, options ...PushOption) (*PushResu
 			Digest: configDescriptor.Digest.String(),
 			Size:   configDescriptor.Size,
 		},
-		Chart: chartSummary,
-		Prov:  &descriptorPushSummary{}, // prevent nil references
-		Ref:   parsedRef.String(),
+		Chart:       chartSummary,
+		Prov:        &descriptorPushSummary{}, // prevent nil references
+		Ref:         parsedRef.String(),
+		Annotations: annotations,
 	}
 	if operation.provData != nil {
 		result.Prov = &descriptorPushSummary{
@@ -568,6 +655,17 @@ func (c *Client) Push(data []byte, ref string, options ...PushOption) (*PushResu
 	}
 	fmt.Fprintf(c.out, "Pushed: %s\n", result.Ref)
 	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
+	if operation.sbomData != nil {
+		sbomRef, sbomManifest, err := c.pushSBOM(parsedRef, manifest.Digest.String(), operation.sbomData, operation.sbomType)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to attach SBOM")
+		}
+		result.SBOM = &descriptorPushSummary{
+			Digest: sbomManifest.Digest.String(),
+			Size:   sbomManifest.Size,
+		}
+		fmt.Fprintf(c.out, "SBOM: %s\n", sbomRef)
+	}
 	if strings.Contains(parsedRef.Reference, "_") {
 		fmt.Fprintf(c.out, "%s contains an underscore.\n", result.Ref)
 		fmt.Fprint(c.out, registryUnderscoreMessage+"\n")
@@ -583,6 +681,15 @@ func PushOptProvData(provData []byte) PushOption {
 	}
 }
 
+// PushOptSBOM returns a function that sets a software bill of materials of
+// the given media type to attach to the chart on push
+func PushOptSBOM(data []byte, mediaType string) PushOption {
+	return func(operation *pushOperation) {
+		operation.sbomData = data
+		operation.sbomType = mediaType
+	}
+}
+
 // PushOptStrictMode returns a function that sets the strictMode setting on push
 func PushOptStrictMode(strictMode bool) PushOption {
 	return func(operation *pushOperation) {
@@ -590,6 +697,24 @@ func PushOptStrictMode(strictMode bool) PushOption {
 	}
 }
 
+// PushOptAnnotations returns a function that sets additional annotations on
+// the manifest on push. They take precedence over the annotations derived from
+// the chart metadata, except for the title and version, which must match the
+// chart.
+func PushOptAnnotations(annotations map[string]string) PushOption {
+	return func(operation *pushOperation) {
+		operation.annotations = annotations
+	}
+}
+
+// PushOptCreationTime returns a function that sets the time recorded in the
+// created annotation on push. It defaults to the current time.
+func PushOptCreationTime(creationTime time.Time) PushOption {
+	return func(operation *pushOperation) {
+		operation.creationTime = creationTime
+	}
+}
+
 // Tags provides a sorted list all semver compliant tags for a given repository
 func (c *Client) Tags(ref string) ([]string, error) {
 	parsedReference, err := registry.ParseReference(ref)
@@ -597,26 +722,26 @@ func (c *Client) Tags(ref string) ([]string, error) {
 		return nil, err
 	}
 
-	repository := registryremote.Repository{
-		Reference: parsedReference,
-		Client:    c.registryAuthorizer,
+	// The tags are listed in the mirrors of the registry, in order, then in
+	// the registry itself.
+	refs, err := c.mirrors.MirrorRefs(parsedReference)
+	if err != nil {
+		return nil, err
 	}
+	refs = append(refs, parsedReference)
 
 	var registryTags []string
-
-	for {
-		registryTags, err = registry.Tags(ctx(c.out, c.debug), &repository)
-		if err != nil {
-			// Fallback to http based request
-			if !repository.PlainHTTP && strings.Contains(err.Error(), "server gave HTTP response") {
-				repository.PlainHTTP = true
-				continue
-			}
+	for _, r := range refs {
+		registryTags, err = c.tags(r)
+		if err == nil {
+			break
+		}
+		if r == parsedReference {
 			return nil, err
 		}
-
-		break
-
+		if c.debug {
+			fmt.Fprintf(c.out, "Failed to list the tags of %s in the mirror %s: %s\n", ref, r.String(), err)
+		}
 	}
 
 	var tagVersions []*semver.Version
@@ -641,3 +766,29 @@ func (c *Client) Tags(ref string) ([]string, error) {
 	return tags, nil
 
 }
+
+// tags lists the tags of the repository of ref.
+func (c *Client) tags(ref registry.Reference) ([]string, error) {
+	client, err := c.repositoryClientFor(ref.Registry)
+	if err != nil {
+		return nil, err
+	}
+	repository := registryremote.Repository{
+		Reference: ref,
+		Client:    client,
+		PlainHTTP: c.mirrors.plainHTTP(ref.Registry),
+	}
+
+	for {
+		registryTags, err := registry.Tags(ctx(c.out, c.debug), &repository)
+		if err != nil {
+			// Fallback to http based request
+			if !repository.PlainHTTP && strings.Contains(err.Error(), "server gave HTTP response") {
+				repository.PlainHTTP = true
+				continue
+			}
+			return nil, err
+		}
+		return registryTags, nil
+	}
+}
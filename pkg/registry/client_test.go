@@ -19,6 +19,13 @@ package registry
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -36,9 +43,15 @@ import (
 	"github.com/distribution/distribution/v3/registry"
 	_ "github.com/distribution/distribution/v3/registry/auth/htpasswd"
 	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/suite"
 	"golang.org/x/crypto/bcrypt"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/oras"
+
+	"github.com/open-hand/helm/pkg/provenance"
 )
 
 var (
@@ -46,6 +59,7 @@ var (
 	testHtpasswdFileBasename = "authtest.htpasswd"
 	testUsername             = "myuser"
 	testPassword             = "mypass"
+	testCreationTime         = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
 )
 
 type RegistryClientTestSuite struct {
@@ -186,9 +200,17 @@ func (suite *RegistryClientTestSuite) Test_1_Push() {
 
 	// push with prov
 	ref = fmt.Sprintf("%s/testrepo/%s:%s", suite.DockerRegistryHost, meta.Name, meta.Version)
-	result, err := suite.RegistryClient.Push(chartData, ref, PushOptProvData(provData))
+	result, err := suite.RegistryClient.Push(chartData, ref,
+		PushOptProvData(provData),
+		PushOptCreationTime(testCreationTime),
+		PushOptAnnotations(map[string]string{"io.example.team": "platform"}))
 	suite.Nil(err, "no error pushing good ref with prov")
 
+	// title and version must match the chart
+	_, err = suite.RegistryClient.Push(chartData, ref,
+		PushOptAnnotations(map[string]string{"org.opencontainers.image.version": "9.9.9"}))
+	suite.NotNil(err, "error overriding the version annotation")
+
 	_, err = suite.RegistryClient.Pull(ref)
 	suite.Nil(err, "no error pulling a simple chart")
 
@@ -198,12 +220,12 @@ func (suite *RegistryClientTestSuite) Test_1_Push() {
 	suite.Equal(ref, result.Ref)
 	suite.Equal(meta.Name, result.Chart.Meta.Name)
 	suite.Equal(meta.Version, result.Chart.Meta.Version)
-	suite.Equal(int64(512), result.Manifest.Size)
+	suite.Equal(int64(771), result.Manifest.Size)
 	suite.Equal(int64(99), result.Config.Size)
 	suite.Equal(int64(973), result.Chart.Size)
 	suite.Equal(int64(695), result.Prov.Size)
 	suite.Equal(
-		"sha256:af4c20a1df1431495e673c14ecfa3a2ba24839a7784349d6787cd67957392e83",
+		"sha256:d15f07301f1717776a1966827e2cc2cd9527fe83ef0d38220d0fd0e6fcddeafb",
 		result.Manifest.Digest)
 	suite.Equal(
 		"sha256:8d17cb6bf6ccd8c29aace9a658495cbd5e2e87fc267876e86117c7db681c9580",
@@ -271,12 +293,12 @@ func (suite *RegistryClientTestSuite) Test_2_Pull() {
 	suite.Equal(ref, result.Ref)
 	suite.Equal(meta.Name, result.Chart.Meta.Name)
 	suite.Equal(meta.Version, result.Chart.Meta.Version)
-	suite.Equal(int64(512), result.Manifest.Size)
+	suite.Equal(int64(771), result.Manifest.Size)
 	suite.Equal(int64(99), result.Config.Size)
 	suite.Equal(int64(973), result.Chart.Size)
 	suite.Equal(int64(695), result.Prov.Size)
 	suite.Equal(
-		"sha256:af4c20a1df1431495e673c14ecfa3a2ba24839a7784349d6787cd67957392e83",
+		"sha256:d15f07301f1717776a1966827e2cc2cd9527fe83ef0d38220d0fd0e6fcddeafb",
 		result.Manifest.Digest)
 	suite.Equal(
 		"sha256:8d17cb6bf6ccd8c29aace9a658495cbd5e2e87fc267876e86117c7db681c9580",
@@ -287,14 +309,110 @@ func (suite *RegistryClientTestSuite) Test_2_Pull() {
 	suite.Equal(
 		"sha256:b0a02b7412f78ae93324d48df8fcc316d8482e5ad7827b5b238657a29a22f256",
 		result.Prov.Digest)
-	suite.Equal("{\"schemaVersion\":2,\"config\":{\"mediaType\":\"application/vnd.cncf.helm.config.v1+json\",\"digest\":\"sha256:8d17cb6bf6ccd8c29aace9a658495cbd5e2e87fc267876e86117c7db681c9580\",\"size\":99},\"layers\":[{\"mediaType\":\"application/vnd.cncf.helm.chart.provenance.v1.prov\",\"digest\":\"sha256:b0a02b7412f78ae93324d48df8fcc316d8482e5ad7827b5b238657a29a22f256\",\"size\":695},{\"mediaType\":\"application/vnd.cncf.helm.chart.content.v1.tar+gzip\",\"digest\":\"sha256:e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55\",\"size\":973}]}",
+	suite.Equal("{\"schemaVersion\":2,\"config\":{\"mediaType\":\"application/vnd.cncf.helm.config.v1+json\",\"digest\":\"sha256:8d17cb6bf6ccd8c29aace9a658495cbd5e2e87fc267876e86117c7db681c9580\",\"size\":99},\"layers\":[{\"mediaType\":\"application/vnd.cncf.helm.chart.provenance.v1.prov\",\"digest\":\"sha256:b0a02b7412f78ae93324d48df8fcc316d8482e5ad7827b5b238657a29a22f256\",\"size\":695},{\"mediaType\":\"application/vnd.cncf.helm.chart.content.v1.tar+gzip\",\"digest\":\"sha256:e5ef611620fb97704d8751c16bab17fedb68883bfb0edc76f78a70e9173f9b55\",\"size\":973}],\"annotations\":{\"io.example.team\":\"platform\",\"org.opencontainers.image.created\":\"2022-01-01T00:00:00Z\",\"org.opencontainers.image.description\":\"A Helm chart for Kubernetes\",\"org.opencontainers.image.title\":\"signtest\",\"org.opencontainers.image.version\":\"0.1.0\"}}",
 		string(result.Manifest.Data))
+	suite.Equal("platform", result.Annotations["io.example.team"])
+	suite.Equal("0.1.0", result.Annotations["org.opencontainers.image.version"])
 	suite.Equal("{\"name\":\"signtest\",\"version\":\"0.1.0\",\"description\":\"A Helm chart for Kubernetes\",\"apiVersion\":\"v1\"}",
 		string(result.Config.Data))
 	suite.Equal(chartData, result.Chart.Data)
 	suite.Equal(provData, result.Prov.Data)
 }
 
+func (suite *RegistryClientTestSuite) Test_3_Signatures() {
+	chartData, err := ioutil.ReadFile("../downloader/testdata/signtest-0.1.0.tgz")
+	suite.Nil(err, "no error loading test chart")
+	ref := fmt.Sprintf("%s/testrepo/signtest:0.1.0", suite.DockerRegistryHost)
+
+	// no signatures
+	result, err := suite.RegistryClient.Signatures(ref)
+	suite.Nil(err, "no error fetching the signatures of an unsigned chart")
+	suite.Equal("sha256:d15f07301f1717776a1966827e2cc2cd9527fe83ef0d38220d0fd0e6fcddeafb", result.Digest)
+	suite.Equal(digest.FromBytes(chartData).String(), result.ChartDigest)
+	suite.Empty(result.Signatures)
+	suite.Empty(result.Attestations)
+
+	// sign the manifest the way cosign does
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Nil(err)
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s/testrepo/signtest"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`,
+		suite.DockerRegistryHost, result.Digest))
+	sum := sha256.Sum256(payload)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	suite.Nil(err)
+
+	memoryStore := content.NewMemory()
+	layer, err := memoryStore.Add("", CosignSignatureMediaType, payload)
+	suite.Nil(err)
+	layer.Annotations = map[string]string{CosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig)}
+	config, err := memoryStore.Add("", ocispec.MediaTypeImageConfig, []byte("{}"))
+	suite.Nil(err)
+	manifestData, manifest, err := content.GenerateManifest(&config, nil, layer)
+	suite.Nil(err)
+	sigRef := fmt.Sprintf("%s/testrepo/signtest:%s.sig", suite.DockerRegistryHost, strings.Replace(result.Digest, ":", "-", 1))
+	suite.Nil(memoryStore.StoreManifest(sigRef, manifest, manifestData))
+	_, err = oras.Copy(context.Background(), memoryStore, sigRef, content.Registry{Resolver: suite.RegistryClient.resolver}, "")
+	suite.Nil(err, "no error pushing signature")
+
+	result, err = suite.RegistryClient.Signatures(ref)
+	suite.Nil(err, "no error fetching signatures")
+	suite.Len(result.Signatures, 1)
+	suite.Equal(payload, result.Signatures[0].Payload)
+	suite.Equal(sig, result.Signatures[0].Signature)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	suite.Nil(err)
+	keyFile := filepath.Join(suite.WorkspaceDir, "cosign.pub")
+	suite.Nil(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	verifier, err := provenance.NewCosignVerifier(provenance.CosignOptions{KeyFile: keyFile})
+	suite.Nil(err)
+	_, err = verifier.VerifyManifest(result.Digest, result.Signatures)
+	suite.Nil(err, "no error verifying the signature")
+}
+
+func (suite *RegistryClientTestSuite) Test_3_SBOM() {
+	chartData, err := ioutil.ReadFile("../downloader/testdata/signtest-0.1.0.tgz")
+	suite.Nil(err, "no error loading test chart")
+	ref := fmt.Sprintf("%s/testrepo/sbom/signtest:0.1.0", suite.DockerRegistryHost)
+
+	sbom := []byte(`{"spdxVersion":"SPDX-2.3"}`)
+	result, err := suite.RegistryClient.Push(chartData, ref, PushOptSBOM(sbom, "application/spdx+json"))
+	suite.Nil(err, "no error pushing chart with SBOM")
+	suite.NotEmpty(result.SBOM.Digest)
+
+	sbomResult, err := suite.RegistryClient.SBOM(ref)
+	suite.Nil(err, "no error fetching SBOM")
+	suite.Equal(result.Manifest.Digest, sbomResult.Digest)
+	suite.Equal(fmt.Sprintf("%s/testrepo/sbom/signtest:%s.sbom", suite.DockerRegistryHost, strings.Replace(result.Manifest.Digest, ":", "-", 1)), sbomResult.Ref)
+	suite.Equal("application/spdx+json", sbomResult.MediaType)
+	suite.Equal(sbom, sbomResult.Data)
+
+	// the chart pushed without SBOM
+	_, err = suite.RegistryClient.SBOM(fmt.Sprintf("%s/testrepo/signtest:0.1.0", suite.DockerRegistryHost))
+	suite.NotNil(err, "error fetching the SBOM of a chart without one")
+}
+
+func (suite *RegistryClientTestSuite) Test_3_Plugins() {
+	data := []byte("plugin archive")
+	ref := fmt.Sprintf("%s/testrepo/plugins/diff:3.1.0", suite.DockerRegistryHost)
+
+	digest, err := suite.RegistryClient.PushPlugin(data, ref)
+	suite.Nil(err, "no error pushing plugin")
+
+	result, err := suite.RegistryClient.PullPlugin(ref)
+	suite.Nil(err, "no error pulling plugin")
+	suite.Equal(digest, result.Digest)
+	suite.Equal(data, result.Data)
+	suite.Empty(result.Signatures)
+
+	result, err = suite.RegistryClient.PullPlugin(fmt.Sprintf("%s/testrepo/plugins/diff@%s", suite.DockerRegistryHost, digest))
+	suite.Nil(err, "no error pulling plugin by digest")
+	suite.Equal(data, result.Data)
+
+	_, err = suite.RegistryClient.PullPlugin(fmt.Sprintf("%s/testrepo/signtest:0.1.0", suite.DockerRegistryHost))
+	suite.NotNil(err, "error pulling a chart as a plugin")
+}
+
 func (suite *RegistryClientTestSuite) Test_3_Tags() {
 
 	// Load test chart (to build ref pushed in previous test)
@@ -311,6 +429,43 @@ func (suite *RegistryClientTestSuite) Test_3_Tags() {
 
 }
 
+func (suite *RegistryClientTestSuite) Test_3_Mirrors() {
+	chartData, err := ioutil.ReadFile("../downloader/testdata/local-subchart-0.1.0.tgz")
+	suite.Nil(err, "no error loading test chart")
+	meta, err := extractChartMeta(chartData)
+	suite.Nil(err, "no error extracting chart meta")
+
+	// The client has no credentials of its own, the mirror is authenticated
+	// with the ones of the mirrors configuration.
+	client, err := NewClient(
+		ClientOptWriter(suite.Out),
+		ClientOptCredentialsFile(filepath.Join(suite.WorkspaceDir, "mirrors-config.json")),
+		ClientOptMirrors(&RegistriesConfig{
+			Mirrors: map[string]MirrorConfig{
+				"charts.example.com": {Endpoints: []string{
+					"http://127.0.0.1:1",
+					fmt.Sprintf("http://%s/testrepo", suite.DockerRegistryHost),
+				}},
+			},
+			Configs: map[string]RegistryConfig{
+				suite.DockerRegistryHost: {Auth: &RegistryAuthConfig{Username: testUsername, Password: testPassword}},
+			},
+		}),
+	)
+	suite.Nil(err, "no error creating registry client with mirrors")
+
+	ref := fmt.Sprintf("charts.example.com/%s:%s", meta.Name, meta.Version)
+	result, err := client.Pull(ref)
+	suite.Nil(err, "no error pulling a chart from a mirror")
+	suite.Equal(ref, result.Ref)
+	suite.Equal(fmt.Sprintf("%s/testrepo/%s:%s", suite.DockerRegistryHost, meta.Name, meta.Version), result.Mirror)
+	suite.Equal(chartData, result.Chart.Data)
+
+	tags, err := client.Tags(fmt.Sprintf("charts.example.com/%s", meta.Name))
+	suite.Nil(err, "no error retrieving tags from a mirror")
+	suite.Equal([]string{meta.Version}, tags)
+}
+
 func (suite *RegistryClientTestSuite) Test_4_Logout() {
 	err := suite.RegistryClient.Logout("this-host-aint-real:5000")
 	suite.NotNil(err, "error logging out of registry that has no entry")
@@ -34,4 +34,32 @@ const (
 
 	// LegacyChartLayerMediaType is the legacy reserved media type for Helm chart package content.
 	LegacyChartLayerMediaType = "application/tar+gzip"
+
+	// CosignSignatureMediaType is the media type of the layers of cosign signatures
+	CosignSignatureMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// CosignAttestationMediaType is the media type of the layers of cosign attestations
+	CosignAttestationMediaType = "application/vnd.dsse.envelope.v1+json"
+
+	// CosignSignatureAnnotation is the layer annotation holding a base64 encoded cosign signature
+	CosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// CosignCertificateAnnotation is the layer annotation holding the signing certificate of a keyless cosign signature
+	CosignCertificateAnnotation = "dev.sigstore.cosign/certificate"
+
+	// CosignChainAnnotation is the layer annotation holding the certificate chain of a keyless cosign signature
+	CosignChainAnnotation = "dev.sigstore.cosign/chain"
+
+	// CosignBundleAnnotation is the layer annotation holding the transparency log entry of a cosign signature
+	CosignBundleAnnotation = "dev.sigstore.cosign/bundle"
+
+	// PluginConfigMediaType is the reserved media type for the config of Helm plugin manifests
+	PluginConfigMediaType = "application/vnd.cncf.helm.plugin.config.v1+json"
+
+	// PluginLayerMediaType is the reserved media type for Helm plugin archives
+	PluginLayerMediaType = "application/vnd.cncf.helm.plugin.content.v1.tar+gzip"
+
+	// SBOMConfigMediaType is the media type of the config of the manifest a
+	// software bill of materials is attached to a chart with
+	SBOMConfigMediaType = "application/vnd.cncf.helm.sbom.config.v1+json"
 )
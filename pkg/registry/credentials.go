@@ -0,0 +1,80 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry // import "github.com/open-hand/helm/pkg/registry"
+
+import (
+	dockerauth "oras.land/oras-go/pkg/auth/docker"
+)
+
+// CredentialProvider supplies the credentials used to access a registry or
+// chart repository host, e.g. "registry.example.com:5000".
+//
+// An empty username and password means the host is accessed anonymously. An
+// empty username with a password means the password is an identity token, to
+// be presented as a bearer token.
+type CredentialProvider interface {
+	Credential(host string) (username, password string, err error)
+}
+
+// CredentialProviderFunc adapts a function to a CredentialProvider.
+type CredentialProviderFunc func(host string) (username, password string, err error)
+
+// Credential calls f(host).
+func (f CredentialProviderFunc) Credential(host string) (string, string, error) {
+	return f(host)
+}
+
+// NewDockerCredentialProvider returns a CredentialProvider that looks up
+// credentials in the given config files, then in the Docker config file,
+// ~/.docker/config.json by default. Credentials stored through credential
+// helpers, configured with credsStore or credHelpers (e.g.
+// docker-credential-ecr-login, docker-credential-gcloud or
+// docker-credential-acr-env), are retrieved by running the helper.
+//
+// Config files that do not exist are ignored.
+func NewDockerCredentialProvider(configPaths ...string) (CredentialProvider, error) {
+	client, err := dockerauth.NewClientWithDockerFallback(configPaths...)
+	if err != nil {
+		return nil, err
+	}
+	return client.(*dockerauth.Client), nil
+}
+
+// ChainCredentialProviders returns a CredentialProvider that returns the
+// first credentials found by providers, in order. Providers that fail are
+// skipped; the first error is only returned if no credentials are found.
+func ChainCredentialProviders(providers ...CredentialProvider) CredentialProvider {
+	return CredentialProviderFunc(func(host string) (string, string, error) {
+		var firstErr error
+		for _, p := range providers {
+			if p == nil {
+				continue
+			}
+			username, password, err := p.Credential(host)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			if username != "" || password != "" {
+				return username, password, nil
+			}
+		}
+		return "", "", firstErr
+	})
+}
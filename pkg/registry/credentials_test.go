@@ -0,0 +1,55 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChainCredentialProviders(t *testing.T) {
+	anonymous := CredentialProviderFunc(func(string) (string, string, error) {
+		return "", "", nil
+	})
+	failing := CredentialProviderFunc(func(string) (string, string, error) {
+		return "", "", errors.New("helper not found")
+	})
+	static := CredentialProviderFunc(func(host string) (string, string, error) {
+		if host == "registry.example.com" {
+			return "user", "pass", nil
+		}
+		return "", "", nil
+	})
+
+	chain := ChainCredentialProviders(nil, anonymous, failing, static)
+	username, password, err := chain.Credential("registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "user" || password != "pass" {
+		t.Errorf("expected credentials of the last provider, got %q, %q", username, password)
+	}
+
+	if _, _, err := chain.Credential("other.example.com"); err == nil {
+		t.Error("expected the error of the failing provider when no credentials are found")
+	}
+
+	username, password, err = ChainCredentialProviders(anonymous).Credential("other.example.com")
+	if err != nil || username != "" || password != "" {
+		t.Errorf("expected anonymous access, got %q, %q, %v", username, password, err)
+	}
+}
@@ -0,0 +1,321 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry // import "github.com/open-hand/helm/pkg/registry"
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/pkg/registry"
+	registryauth "oras.land/oras-go/pkg/registry/remote/auth"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/internal/tlsutil"
+	"github.com/open-hand/helm/internal/version"
+)
+
+// MirrorsFileBasename is the filename of the registry mirrors configuration,
+// in the same directory as the credentials file.
+const MirrorsFileBasename = "registry/registries.yaml"
+
+// mirrorWildcard is the host of the mirrors used for all the registries
+// without mirrors of their own.
+const mirrorWildcard = "*"
+
+// RegistriesConfig maps registry hosts to mirrors, and configures the
+// authentication and TLS of the registries, in the format of the
+// registries.yaml file of containerd based distributions such as k3s:
+//
+//	mirrors:
+//	  ghcr.io:
+//	    endpoint:
+//	      - https://mirror.internal:5000/ghcr
+//	configs:
+//	  mirror.internal:5000:
+//	    auth:
+//	      username: helm
+//	      password: secret
+//	    tls:
+//	      ca_file: /etc/ssl/mirror-ca.pem
+type RegistriesConfig struct {
+	// Mirrors are the mirrors of the registries by host, "*" being used for
+	// all the registries without mirrors of their own.
+	Mirrors map[string]MirrorConfig `json:"mirrors,omitempty"`
+	// Configs are the authentication and TLS settings by registry or mirror
+	// host.
+	Configs map[string]RegistryConfig `json:"configs,omitempty"`
+}
+
+// MirrorConfig lists the mirrors of a registry.
+type MirrorConfig struct {
+	// Endpoints are the URLs of the mirrors, tried in order before the
+	// registry itself. The path of a URL, if any, prefixes the repositories,
+	// and http URLs are accessed in plain HTTP.
+	Endpoints []string `json:"endpoint,omitempty"`
+	// Rewrite maps regular expressions matching the repositories to their
+	// replacements in the mirrors, e.g. "^library/(.*)": "mirrored/$1".
+	Rewrite map[string]string `json:"rewrite,omitempty"`
+}
+
+// RegistryConfig holds the authentication and TLS settings of a registry
+// or mirror.
+type RegistryConfig struct {
+	Auth *RegistryAuthConfig `json:"auth,omitempty"`
+	TLS  *RegistryTLSConfig  `json:"tls,omitempty"`
+}
+
+// RegistryAuthConfig holds the credentials of a registry. An identity token
+// is presented as a bearer token.
+type RegistryAuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// RegistryTLSConfig holds the TLS settings of a registry.
+type RegistryTLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// LoadRegistriesConfig loads the registry mirrors configuration in path. A
+// missing file is an empty configuration.
+func LoadRegistriesConfig(path string) (*RegistriesConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &RegistriesConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := &RegistriesConfig{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, errors.Wrapf(err, "cannot load registry mirrors configuration %s", path)
+	}
+	for host, m := range cfg.Mirrors {
+		for _, endpoint := range m.Endpoints {
+			if _, _, _, err := parseMirrorEndpoint(endpoint); err != nil {
+				return nil, errors.Wrapf(err, "mirrors of %s", host)
+			}
+		}
+		for expr := range m.Rewrite {
+			if _, err := regexp.Compile(expr); err != nil {
+				return nil, errors.Wrapf(err, "invalid rewrite of the mirrors of %s", host)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// ClientOptMirrors returns a function that sets the registry mirrors
+// configuration on a client options set. Charts are pulled from the mirrors
+// of their registry, in order, then from the registry itself if all the
+// mirrors fail.
+func ClientOptMirrors(config *RegistriesConfig) ClientOption {
+	return func(client *Client) {
+		client.mirrors = config
+	}
+}
+
+// MirrorRefs returns the references of ref in the mirrors of its registry,
+// in the order they are tried. ref itself is not included.
+func (cfg *RegistriesConfig) MirrorRefs(ref registry.Reference) ([]registry.Reference, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	m, ok := cfg.Mirrors[ref.Registry]
+	if !ok {
+		m, ok = cfg.Mirrors[mirrorWildcard]
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	repository := ref.Repository
+	for expr, replacement := range m.Rewrite {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rewrite of the mirrors of %s", ref.Registry)
+		}
+		if re.MatchString(repository) {
+			repository = re.ReplaceAllString(repository, replacement)
+			break
+		}
+	}
+
+	var refs []registry.Reference
+	for _, endpoint := range m.Endpoints {
+		host, prefix, _, err := parseMirrorEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		mirrored := registry.Reference{
+			Registry:   host,
+			Repository: strings.TrimPrefix(prefix+"/"+repository, "/"),
+			Reference:  ref.Reference,
+		}
+		if host == ref.Registry && mirrored.Repository == ref.Repository {
+			continue
+		}
+		refs = append(refs, mirrored)
+	}
+	return refs, nil
+}
+
+// plainHTTP reports whether host is a mirror reached in plain HTTP.
+func (cfg *RegistriesConfig) plainHTTP(host string) bool {
+	if cfg == nil {
+		return false
+	}
+	for _, m := range cfg.Mirrors {
+		for _, endpoint := range m.Endpoints {
+			if h, _, plain, err := parseMirrorEndpoint(endpoint); err == nil && h == host && plain {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseMirrorEndpoint returns the host and path of the mirror endpoint, and
+// whether it is reached in plain HTTP. Endpoints without a scheme are
+// reached in HTTPS.
+func parseMirrorEndpoint(endpoint string) (host, prefix string, plainHTTP bool, err error) {
+	e := endpoint
+	switch {
+	case strings.HasPrefix(e, "http://"):
+		e, plainHTTP = strings.TrimPrefix(e, "http://"), true
+	case strings.HasPrefix(e, "https://"):
+		e = strings.TrimPrefix(e, "https://")
+	case strings.Contains(e, "://"):
+		return "", "", false, errors.Errorf("invalid mirror endpoint %q: the scheme must be http or https", endpoint)
+	}
+	e = strings.TrimSuffix(e, "/")
+	host, prefix = e, ""
+	if i := strings.Index(e, "/"); i >= 0 {
+		host, prefix = e[:i], strings.TrimSuffix(e[i+1:], "/")
+	}
+	if host == "" {
+		return "", "", false, errors.Errorf("invalid mirror endpoint %q: missing host", endpoint)
+	}
+	// Registries serve their API under /v2, which is not part of the
+	// repositories.
+	prefix = strings.TrimPrefix(strings.TrimPrefix(prefix, "v2"), "/")
+	return host, prefix, plainHTTP, nil
+}
+
+// hostConfig returns the settings of host, nil if it has none.
+func (cfg *RegistriesConfig) hostConfig(host string) *RegistryConfig {
+	if cfg == nil {
+		return nil
+	}
+	if c, ok := cfg.Configs[host]; ok {
+		return &c
+	}
+	return nil
+}
+
+// credential returns the credentials of host in the configuration, as
+// returned by a CredentialProvider.
+func (c *RegistryConfig) credential(string) (string, string, error) {
+	if c == nil || c.Auth == nil {
+		return "", "", nil
+	}
+	if c.Auth.IdentityToken != "" {
+		return "", c.Auth.IdentityToken, nil
+	}
+	return c.Auth.Username, c.Auth.Password, nil
+}
+
+// httpClient returns an HTTP client with the TLS settings of the registry,
+// nil if it has none.
+func (c *RegistryConfig) httpClient() (*http.Client, error) {
+	if c == nil || c.TLS == nil {
+		return nil, nil
+	}
+	tlsConf, err := tlsutil.NewClientTLS(c.TLS.CertFile, c.TLS.KeyFile, c.TLS.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the TLS configuration of the registry")
+	}
+	tlsConf.InsecureSkipVerify = c.TLS.InsecureSkipVerify
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	return &http.Client{Transport: transport}, nil
+}
+
+// credentialProviderFor returns the provider of the credentials of host:
+// the ones of the mirrors configuration first, then the ones of the client.
+func (c *Client) credentialProviderFor(host string) CredentialProvider {
+	hc := c.mirrors.hostConfig(host)
+	if hc == nil || hc.Auth == nil {
+		return c.credentialProvider
+	}
+	return ChainCredentialProviders(CredentialProviderFunc(hc.credential), c.credentialProvider)
+}
+
+// resolverFor returns the resolver used to pull from host, which is the one
+// of the client unless the mirrors configuration has settings for host.
+func (c *Client) resolverFor(host string) (remotes.Resolver, error) {
+	hc := c.mirrors.hostConfig(host)
+	plainHTTP := c.mirrors.plainHTTP(host)
+	if hc == nil && !plainHTTP {
+		return c.resolver, nil
+	}
+	httpClient, err := hc.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	headers := http.Header{}
+	headers.Set("User-Agent", version.GetUserAgent())
+	opts := docker.ResolverOptions{
+		Headers:   headers,
+		PlainHTTP: plainHTTP,
+		Client:    httpClient,
+	}
+	if provider := c.credentialProviderFor(host); provider != nil {
+		opts.Credentials = provider.Credential
+	}
+	return docker.NewResolver(opts), nil
+}
+
+// repositoryClientFor returns the client used to list the tags of the
+// repositories of host, which is the one of the client unless the mirrors
+// configuration has settings for host.
+func (c *Client) repositoryClientFor(host string) (*registryauth.Client, error) {
+	hc := c.mirrors.hostConfig(host)
+	if hc == nil {
+		return c.registryAuthorizer, nil
+	}
+	httpClient, err := hc.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	client := *c.registryAuthorizer
+	client.Client = httpClient
+	if hc.Auth != nil {
+		client.Credential = registryCredential(c.credentialProviderFor(host))
+	}
+	return &client, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"oras.land/oras-go/pkg/registry"
+)
+
+func TestLoadRegistriesConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := LoadRegistriesConfig(filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Mirrors) != 0 || len(cfg.Configs) != 0 {
+		t.Errorf("expected an empty configuration, got %+v", cfg)
+	}
+
+	path := filepath.Join(dir, "registries.yaml")
+	data := `mirrors:
+  ghcr.io:
+    endpoint:
+      - https://mirror.internal:5000/ghcr
+configs:
+  mirror.internal:5000:
+    auth:
+      username: helm
+      password: secret
+    tls:
+      insecure_skip_verify: true
+`
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadRegistriesConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Mirrors["ghcr.io"].Endpoints; !reflect.DeepEqual(got, []string{"https://mirror.internal:5000/ghcr"}) {
+		t.Errorf("unexpected endpoints %v", got)
+	}
+	c := cfg.Configs["mirror.internal:5000"]
+	if c.Auth == nil || c.Auth.Username != "helm" || c.Auth.Password != "secret" || c.TLS == nil || !c.TLS.InsecureSkipVerify {
+		t.Errorf("unexpected config %+v", c)
+	}
+
+	for name, data := range map[string]string{
+		"unknown field": "mirror:\n  ghcr.io: {}\n",
+		"bad scheme":    "mirrors:\n  ghcr.io:\n    endpoint: [\"ftp://mirror.internal\"]\n",
+		"bad rewrite":   "mirrors:\n  ghcr.io:\n    endpoint: [\"mirror.internal\"]\n    rewrite:\n      \"(\": x\n",
+		"missing host":  "mirrors:\n  ghcr.io:\n    endpoint: [\"https:///ghcr\"]\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRegistriesConfig(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMirrorRefs(t *testing.T) {
+	cfg := &RegistriesConfig{
+		Mirrors: map[string]MirrorConfig{
+			"ghcr.io": {
+				Endpoints: []string{"https://mirror.internal:5000/ghcr/", "http://cache.internal/v2", "ghcr.io"},
+				Rewrite:   map[string]string{"^org/(.*)$": "mirrored/$1"},
+			},
+			"*": {Endpoints: []string{"mirror.internal:5000/all"}},
+		},
+	}
+
+	tests := []struct {
+		ref  string
+		want []string
+	}{
+		{
+			ref: "ghcr.io/org/chart:1.0.0",
+			want: []string{
+				"mirror.internal:5000/ghcr/mirrored/chart:1.0.0",
+				"cache.internal/mirrored/chart:1.0.0",
+				"ghcr.io/mirrored/chart:1.0.0",
+			},
+		},
+		{
+			ref:  "ghcr.io/other/chart:1.0.0",
+			want: []string{"mirror.internal:5000/ghcr/other/chart:1.0.0", "cache.internal/other/chart:1.0.0"},
+		},
+		{
+			ref:  "registry.example.com/chart:2.0.0",
+			want: []string{"mirror.internal:5000/all/chart:2.0.0"},
+		},
+	}
+	for _, tt := range tests {
+		ref, err := registry.ParseReference(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs, err := cfg.MirrorRefs(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range refs {
+			got = append(got, r.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.ref, tt.want, got)
+		}
+	}
+
+	if !cfg.plainHTTP("cache.internal") || cfg.plainHTTP("mirror.internal:5000") {
+		t.Error("expected only the http endpoints to be reached in plain HTTP")
+	}
+
+	var none *RegistriesConfig
+	if refs, err := none.MirrorRefs(registry.Reference{Registry: "ghcr.io", Repository: "chart"}); err != nil || refs != nil {
+		t.Errorf("expected no mirrors, got %v, %v", refs, err)
+	}
+}
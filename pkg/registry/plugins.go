@@ -0,0 +1,128 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry // import "github.com/open-hand/helm/pkg/registry"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/oras"
+
+	"github.com/open-hand/helm/pkg/provenance"
+)
+
+// maxPluginSize bounds the size of the plugin archives pulled.
+const maxPluginSize = 256 << 20
+
+// PluginPullResult is the result returned by PullPlugin.
+type PluginPullResult struct {
+	Ref string `json:"ref"`
+	// Digest is the digest of the manifest of the plugin, which signatures
+	// refer to.
+	Digest string `json:"digest"`
+	// Data is the gzipped tar archive of the plugin.
+	Data []byte `json:"-"`
+	// Signatures are the cosign signatures attached to the plugin.
+	Signatures []*provenance.Signature `json:"-"`
+}
+
+// PushPlugin pushes the gzipped tar archive of a plugin, returning the
+// digest of its manifest.
+func (c *Client) PushPlugin(data []byte, ref string) (string, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	memoryStore := content.NewMemory()
+	layer, err := memoryStore.Add("", PluginLayerMediaType, data)
+	if err != nil {
+		return "", err
+	}
+	config, err := memoryStore.Add("", PluginConfigMediaType, []byte("{}"))
+	if err != nil {
+		return "", err
+	}
+	manifestData, manifest, err := content.GenerateManifest(&config, nil, layer)
+	if err != nil {
+		return "", err
+	}
+	if err := memoryStore.StoreManifest(parsedRef.String(), manifest, manifestData); err != nil {
+		return "", err
+	}
+	resolver, err := c.newResolver()
+	if err != nil {
+		return "", err
+	}
+	_, err = oras.Copy(ctx(c.out, c.debug), memoryStore, parsedRef.String(), content.Registry{Resolver: resolver}, "",
+		oras.WithNameValidation(nil))
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(c.out, "Pushed: %s\n", parsedRef.String())
+	fmt.Fprintf(c.out, "Digest: %s\n", manifest.Digest)
+	return manifest.Digest.String(), nil
+}
+
+// PullPlugin pulls the archive of a plugin and the cosign signatures
+// attached to it. The reference may pin the digest of the manifest, as in
+// "registry.example.com/plugins/diff@sha256:...".
+func (c *Client) PullPlugin(ref string) (*PluginPullResult, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx := ctx(c.out, c.debug)
+
+	name, desc, err := c.resolver.Resolve(ctx, parsedRef.String())
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := c.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	result := &PluginPullResult{
+		Ref:    parsedRef.String(),
+		Digest: desc.Digest.String(),
+	}
+	for _, l := range manifest.Layers {
+		if l.MediaType != PluginLayerMediaType {
+			continue
+		}
+		if result.Data, err = fetchBlobLimit(ctx, fetcher, l, maxPluginSize); err != nil {
+			return nil, err
+		}
+	}
+	if result.Data == nil {
+		return nil, errors.Errorf("manifest does not contain a layer with mediatype %s", PluginLayerMediaType)
+	}
+
+	tag := strings.Replace(result.Digest, ":", "-", 1)
+	repository := fmt.Sprintf("%s/%s", parsedRef.Registry, parsedRef.Repository)
+	if result.Signatures, err = c.fetchCosignLayers(ctx, repository+":"+tag+".sig", CosignSignatureMediaType); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch signatures")
+	}
+	fmt.Fprintf(c.out, "Pulled: %s\n", result.Ref)
+	fmt.Fprintf(c.out, "Digest: %s\n", result.Digest)
+	return result, nil
+}
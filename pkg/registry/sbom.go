@@ -0,0 +1,124 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry // import "github.com/open-hand/helm/pkg/registry"
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/oras"
+	"oras.land/oras-go/pkg/registry"
+)
+
+// SBOMResult is the result returned by SBOM.
+type SBOMResult struct {
+	// Ref is the reference the software bill of materials is stored at.
+	Ref string `json:"ref"`
+	// Digest is the digest of the manifest of the chart it describes.
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Data      []byte `json:"-"`
+}
+
+// sbomTag returns the reference a software bill of materials of the chart
+// manifest with the given digest is stored at. Like cosign, it is the tag
+// "sha256-<digest>.sbom" of the repository of the chart.
+func sbomTag(ref registry.Reference, digest string) string {
+	return fmt.Sprintf("%s/%s:%s.sbom", ref.Registry, ref.Repository, strings.Replace(digest, ":", "-", 1))
+}
+
+// pushSBOM attaches a software bill of materials to the chart manifest with
+// the given digest, and returns the reference and the descriptor of the
+// manifest it was pushed with.
+func (c *Client) pushSBOM(ref registry.Reference, digest string, data []byte, mediaType string) (string, ocispec.Descriptor, error) {
+	if mediaType == "" {
+		return "", ocispec.Descriptor{}, errors.New("the media type of the SBOM is required")
+	}
+	memoryStore := content.NewMemory()
+	layer, err := memoryStore.Add("", mediaType, data)
+	if err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+	config, err := memoryStore.Add("", SBOMConfigMediaType, []byte("{}"))
+	if err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+	manifestData, manifest, err := content.GenerateManifest(&config, nil, layer)
+	if err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+	tag := sbomTag(ref, digest)
+	if err := memoryStore.StoreManifest(tag, manifest, manifestData); err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+
+	resolver, err := c.newResolver()
+	if err != nil {
+		return "", ocispec.Descriptor{}, err
+	}
+	_, err = oras.Copy(ctx(c.out, c.debug), memoryStore, tag, content.Registry{Resolver: resolver}, "",
+		oras.WithNameValidation(nil))
+	return tag, manifest, err
+}
+
+// SBOM fetches the software bill of materials attached to a chart when it
+// was pushed. A chart without one is an error.
+func (c *Client) SBOM(ref string) (*SBOMResult, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx := ctx(c.out, c.debug)
+
+	_, desc, err := c.resolver.Resolve(ctx, parsedRef.String())
+	if err != nil {
+		return nil, err
+	}
+	tag := sbomTag(parsedRef, desc.Digest.String())
+	name, sbomDesc, err := c.resolver.Resolve(ctx, tag)
+	if errdefs.IsNotFound(err) {
+		return nil, errors.Errorf("no SBOM is attached to %s", parsedRef.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := c.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := fetchManifest(ctx, fetcher, sbomDesc)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.Layers) != 1 {
+		return nil, errors.Errorf("manifest %s has %d layers, expected an SBOM", tag, len(manifest.Layers))
+	}
+	data, err := fetchBlob(ctx, fetcher, manifest.Layers[0])
+	if err != nil {
+		return nil, err
+	}
+	return &SBOMResult{
+		Ref:       tag,
+		Digest:    desc.Digest.String(),
+		MediaType: manifest.Layers[0].MediaType,
+		Data:      data,
+	}, nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry // import "github.com/open-hand/helm/pkg/registry"
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/pkg/provenance"
+)
+
+// maxSignatureBlobSize bounds the size of the manifests and layers read
+// while fetching signatures.
+const maxSignatureBlobSize = 4 << 20
+
+// SignaturesResult is the result returned by Signatures.
+type SignaturesResult struct {
+	Ref string `json:"ref"`
+	// Digest is the digest of the manifest of the chart, which signatures
+	// and attestations refer to.
+	Digest string `json:"digest"`
+	// ChartDigest is the digest of the chart layer of the manifest.
+	ChartDigest string `json:"chartDigest"`
+	// Signatures are the cosign signatures attached to the chart.
+	Signatures []*provenance.Signature `json:"-"`
+	// Attestations are the cosign attestations attached to the chart.
+	Attestations []*provenance.Signature `json:"-"`
+}
+
+// Signatures fetches the cosign signatures and attestations attached to a
+// chart. They are stored by cosign under the tags "sha256-<digest>.sig" and
+// "sha256-<digest>.att" of the repository of the chart, where <digest> is
+// the digest of the chart manifest. A chart without signatures or
+// attestations is not an error.
+func (c *Client) Signatures(ref string) (*SignaturesResult, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	ctx := ctx(c.out, c.debug)
+
+	name, desc, err := c.resolver.Resolve(ctx, parsedRef.String())
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := c.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	result := &SignaturesResult{
+		Ref:    parsedRef.String(),
+		Digest: desc.Digest.String(),
+	}
+	for _, l := range manifest.Layers {
+		if l.MediaType == ChartLayerMediaType || l.MediaType == LegacyChartLayerMediaType {
+			result.ChartDigest = l.Digest.String()
+		}
+	}
+
+	tag := strings.Replace(result.Digest, ":", "-", 1)
+	repository := fmt.Sprintf("%s/%s", parsedRef.Registry, parsedRef.Repository)
+	if result.Signatures, err = c.fetchCosignLayers(ctx, repository+":"+tag+".sig", CosignSignatureMediaType); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch signatures")
+	}
+	if result.Attestations, err = c.fetchCosignLayers(ctx, repository+":"+tag+".att", CosignAttestationMediaType); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch attestations")
+	}
+	return result, nil
+}
+
+// fetchCosignLayers returns the layers of the given media type of a cosign
+// signature or attestation manifest, or nothing if it does not exist.
+func (c *Client) fetchCosignLayers(ctx context.Context, ref, mediaType string) ([]*provenance.Signature, error) {
+	name, desc, err := c.resolver.Resolve(ctx, ref)
+	if errdefs.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := c.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := fetchManifest(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	var sigs []*provenance.Signature
+	for _, l := range manifest.Layers {
+		if l.MediaType != mediaType {
+			continue
+		}
+		payload, err := fetchBlob(ctx, fetcher, l)
+		if err != nil {
+			return nil, err
+		}
+		sig := &provenance.Signature{
+			Payload:     payload,
+			Certificate: []byte(l.Annotations[CosignCertificateAnnotation]),
+			Chain:       []byte(l.Annotations[CosignChainAnnotation]),
+			Bundle:      []byte(l.Annotations[CosignBundleAnnotation]),
+		}
+		if s := l.Annotations[CosignSignatureAnnotation]; s != "" {
+			if sig.Signature, err = base64.StdEncoding.DecodeString(s); err != nil {
+				return nil, errors.Wrapf(err, "invalid signature in layer %s", l.Digest)
+			}
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs, nil
+}
+
+func fetchManifest(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) (*ocispec.Manifest, error) {
+	b, err := fetchBlob(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse manifest %s", desc.Digest)
+	}
+	return &manifest, nil
+}
+
+// fetchBlob reads the content of desc and checks it against its digest.
+func fetchBlob(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) ([]byte, error) {
+	return fetchBlobLimit(ctx, fetcher, desc, maxSignatureBlobSize)
+}
+
+// fetchBlobLimit reads the content of desc, up to limit bytes, and checks it
+// against its digest.
+func fetchBlobLimit(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor, limit int64) ([]byte, error) {
+	if err := desc.Digest.Validate(); err != nil {
+		return nil, err
+	}
+	if desc.Size > limit {
+		return nil, errors.Errorf("blob %s is too large: %d bytes", desc.Digest, desc.Size)
+	}
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if desc.Digest.Algorithm().FromBytes(b) != desc.Digest {
+		return nil, errors.Errorf("content of blob %s does not match its digest", desc.Digest)
+	}
+	return b, nil
+}
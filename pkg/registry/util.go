@@ -22,8 +22,10 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	orascontext "oras.land/oras-go/pkg/context"
@@ -94,6 +96,56 @@ func extractChartMeta(chartData []byte) (*chart.Metadata, error) {
 	return ch.Metadata, nil
 }
 
+// generateOCIAnnotations returns the manifest annotations for a chart. The
+// standard org.opencontainers.image annotations are derived from the chart
+// metadata, then custom annotations are applied on top. The title and version
+// annotations cannot be overridden, since clients rely on them to identify
+// the chart.
+func generateOCIAnnotations(meta *chart.Metadata, custom map[string]string, created time.Time) (map[string]string, error) {
+	if created.IsZero() {
+		created = time.Now()
+	}
+	annotations := map[string]string{
+		ocispec.AnnotationTitle:   meta.Name,
+		ocispec.AnnotationVersion: meta.Version,
+		ocispec.AnnotationCreated: created.UTC().Format(time.RFC3339),
+	}
+	if meta.Description != "" {
+		annotations[ocispec.AnnotationDescription] = meta.Description
+	}
+	if meta.Home != "" {
+		annotations[ocispec.AnnotationURL] = meta.Home
+	}
+	if len(meta.Sources) > 0 {
+		annotations[ocispec.AnnotationSource] = meta.Sources[0]
+	}
+	var authors []string
+	for _, m := range meta.Maintainers {
+		if m == nil || m.Name == "" {
+			continue
+		}
+		if m.Email != "" {
+			authors = append(authors, fmt.Sprintf("%s (%s)", m.Name, m.Email))
+		} else {
+			authors = append(authors, m.Name)
+		}
+	}
+	if len(authors) > 0 {
+		annotations[ocispec.AnnotationAuthors] = strings.Join(authors, ", ")
+	}
+
+	for k, v := range custom {
+		if k == ocispec.AnnotationTitle || k == ocispec.AnnotationVersion {
+			if v != annotations[k] {
+				return nil, errors.Errorf("annotation %s must match the chart, got %q instead of %q", k, v, annotations[k])
+			}
+			continue
+		}
+		annotations[k] = v
+	}
+	return annotations, nil
+}
+
 // ctx retrieves a fresh context.
 // disable verbose logging coming from ORAS (unless debug is enabled)
 func ctx(out io.Writer, debug bool) context.Context {
@@ -25,6 +25,11 @@ type Info struct {
 	FirstDeployed time.Time `json:"first_deployed,omitempty"`
 	// LastDeployed is when the release was last deployed.
 	LastDeployed time.Time `json:"last_deployed,omitempty"`
+	// Completed is when the operation creating this revision completed,
+	// successfully or not.
+	Completed *time.Time `json:"completed,omitempty"`
+	// DeployedBy is the user who created this revision.
+	DeployedBy string `json:"deployed_by,omitempty"`
 	// Deleted tracks when this object was deleted.
 	Deleted time.Time `json:"deleted"`
 	// Description is human-friendly "log entry" about this release.
@@ -33,4 +38,15 @@ type Info struct {
 	Status Status `json:"status,omitempty"`
 	// Contains the rendered templates/NOTES.txt if available
 	Notes string `json:"notes,omitempty"`
+	// Waves records the progress of a release applied in waves.
+	Waves *WaveProgress `json:"waves,omitempty"`
+	// Resources are the resources applied by the release and its hooks.
+	Resources []ResourceIdentity `json:"resources,omitempty"`
+	// WaitTimings record how long the resources of the release took to be
+	// ready when the operation waited for them.
+	WaitTimings []WaitTiming `json:"wait_timings,omitempty"`
+	// ResourceDigests are the SHA-256 digests of the resources of the release
+	// as applied, by the key of their identity. They are recorded by the
+	// upgrades skipping the unchanged resources.
+	ResourceDigests map[string]string `json:"resource_digests,omitempty"`
 }
@@ -0,0 +1,33 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import "github.com/open-hand/helm/pkg/time"
+
+// PinnedOverride is a value pinned on a release. Pinned overrides are stored
+// apart from the values of the release and applied over them on every
+// upgrade until they are removed, whether the values are reused or reset.
+type PinnedOverride struct {
+	// Key is the path of the value, as given to --set, e.g. "image.tag".
+	Key string `json:"key"`
+	// Value is the value, parsed as by --set.
+	Value string `json:"value"`
+	// SetAt is the time the override was pinned.
+	SetAt time.Time `json:"set_at"`
+	// Revision is the revision of the release the override was pinned on.
+	Revision int `json:"revision"`
+}
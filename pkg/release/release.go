@@ -31,6 +31,9 @@ type Release struct {
 	Config map[string]interface{} `json:"config,omitempty"`
 	// ConfigRaw是config的string形式
 	ConfigRaw string `json:"configRaw,omitempty"`
+	// Overrides are the values pinned on the release, applied over Config
+	// on every upgrade until they are removed.
+	Overrides []*PinnedOverride `json:"overrides,omitempty"`
 	// Manifest is the string representation of the rendered template.
 	Manifest string `json:"manifest,omitempty"`
 	// Hooks are all of the hooks declared for this release.
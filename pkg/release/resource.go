@@ -0,0 +1,43 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import "strings"
+
+// ResourceIdentity identifies a Kubernetes resource applied by a release.
+type ResourceIdentity struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// Key identifies the resource regardless of the version of its API.
+func (r ResourceIdentity) Key() string {
+	group := ""
+	if i := strings.LastIndex(r.APIVersion, "/"); i >= 0 {
+		group = r.APIVersion[:i]
+	}
+	return strings.Join([]string{group, r.Kind, r.Namespace, r.Name}, "/")
+}
+
+func (r ResourceIdentity) String() string {
+	if r.Namespace == "" {
+		return r.Kind + " " + r.Name
+	}
+	return r.Kind + " " + r.Namespace + "/" + r.Name
+}
@@ -15,10 +15,46 @@ limitations under the License.
 
 package release
 
+import "time"
+
 // UninstallReleaseResponse represents a successful response to an uninstall request.
 type UninstallReleaseResponse struct {
 	// Release is the release that was marked deleted.
 	Release *Release `json:"release,omitempty"`
 	// Info is an uninstall message
 	Info string `json:"info,omitempty"`
+	// Deleted are the resources deleted by the uninstall, or that would be
+	// deleted by a dry run.
+	Deleted []ResourceIdentity `json:"deleted,omitempty"`
+	// Kept are the resources kept due to their resource policy.
+	Kept []ResourceIdentity `json:"kept,omitempty"`
+	// Hooks are the names of the delete hooks run by the uninstall, or that
+	// would be run by a dry run.
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+// TestReleaseResponse is the result of running the tests of a release.
+type TestReleaseResponse struct {
+	// Release is the release with the last execution of its test hooks.
+	Release *Release `json:"release,omitempty"`
+	// Tests are the results of the tests run, in execution order.
+	Tests []TestResult `json:"tests"`
+}
+
+// TestResult is the result of a test of a release.
+type TestResult struct {
+	Name        string        `json:"name"`
+	Kind        string        `json:"kind"`
+	Phase       HookPhase     `json:"phase"`
+	StartedAt   time.Time     `json:"started_at"`
+	CompletedAt time.Time     `json:"completed_at"`
+	Duration    time.Duration `json:"duration"`
+	// ExitCode is the exit code of the test container, for the tests run
+	// by pods and jobs whose pod could be found.
+	ExitCode *int32 `json:"exit_code,omitempty"`
+	// Logs are the last lines of the logs of the test pod.
+	Logs string `json:"logs,omitempty"`
+	// RetainedUntil is when the resources of a failed test kept for
+	// inspection are deleted.
+	RetainedUntil *time.Time `json:"retained_until,omitempty"`
 }
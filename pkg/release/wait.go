@@ -0,0 +1,32 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import "time"
+
+// WaitTiming records how long waiting for a resource of a release to be
+// ready took.
+type WaitTiming struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Duration is the time from the beginning of the wait until the
+	// resource was ready, or until the wait ended if it never was.
+	Duration time.Duration `json:"duration"`
+	// Ready tells whether the resource was ready.
+	Ready bool `json:"ready"`
+}
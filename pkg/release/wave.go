@@ -0,0 +1,31 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+// WaveProgress records the waves of a release applied so far, so that a
+// failed release can be resumed from the wave that failed.
+type WaveProgress struct {
+	// Waves are the numbers of the waves of the release, in apply order.
+	Waves []int `json:"waves"`
+	// Completed is the number of waves applied and ready.
+	Completed int `json:"completed"`
+}
+
+// Done returns true if all the waves are completed.
+func (w *WaveProgress) Done() bool {
+	return w.Completed >= len(w.Waves)
+}
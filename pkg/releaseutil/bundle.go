@@ -0,0 +1,188 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil // import "github.com/open-hand/helm/pkg/releaseutil"
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+)
+
+// BundleAPIVersion is the version of the release bundle format written by
+// WriteBundle.
+const BundleAPIVersion = "v1"
+
+// bundleIndexFile is the name of the file describing the contents of a
+// release bundle.
+const bundleIndexFile = "bundle.json"
+
+// maxBundleFileSize bounds the size of a single file read from a bundle.
+const maxBundleFileSize = 64 * 1024 * 1024
+
+type bundleIndex struct {
+	APIVersion string           `json:"apiVersion"`
+	Name       string           `json:"name"`
+	Namespace  string           `json:"namespace"`
+	Revisions  []bundleRevision `json:"revisions"`
+}
+
+type bundleRevision struct {
+	Version int    `json:"version"`
+	Path    string `json:"path"`
+	// Labels are kept here because they are not part of the release's
+	// JSON encoding.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// WriteBundle writes the history of a single release, including its chart,
+// values and hooks, to out as a gzipped tar archive. The archive can be read
+// back with ReadBundle, e.g. to restore the release in another cluster.
+func WriteBundle(out io.Writer, history []*rspb.Release) error {
+	if len(history) == 0 {
+		return errors.New("no revisions to write")
+	}
+	h := make([]*rspb.Release, len(history))
+	copy(h, history)
+	SortByRevision(h)
+
+	index := bundleIndex{
+		APIVersion: BundleAPIVersion,
+		Name:       h[0].Name,
+		Namespace:  h[0].Namespace,
+	}
+	files := make([][]byte, 0, len(h))
+	for _, rls := range h {
+		if rls.Name != index.Name {
+			return errors.Errorf("revision %d belongs to release %q, not %q", rls.Version, rls.Name, index.Name)
+		}
+		b, err := json.Marshal(rls)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode revision %d", rls.Version)
+		}
+		index.Revisions = append(index.Revisions, bundleRevision{
+			Version: rls.Version,
+			Path:    fmt.Sprintf("releases/%s.v%d.json", rls.Name, rls.Version),
+			Labels:  rls.Labels,
+		})
+		files = append(files, b)
+	}
+	ib, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	tw := tar.NewWriter(zw)
+	if err := writeBundleFile(tw, bundleIndexFile, ib); err != nil {
+		return err
+	}
+	for i, rev := range index.Revisions {
+		if err := writeBundleFile(tw, rev.Path, files[i]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeBundleFile(tw *tar.Writer, name string, body []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(body)),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(body)
+	return err
+}
+
+// ReadBundle reads a release bundle written by WriteBundle and returns the
+// revisions it holds, oldest first.
+func ReadBundle(in io.Reader) ([]*rspb.Release, error) {
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "release bundle is not a gzipped archive")
+	}
+	defer zr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(zr)
+	for {
+		hd, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read release bundle")
+		}
+		if hd.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hd.Size > maxBundleFileSize {
+			return nil, errors.Errorf("file %q in release bundle is too large", hd.Name)
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(tr, maxBundleFileSize))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %q from release bundle", hd.Name)
+		}
+		files[hd.Name] = b
+	}
+
+	ib, ok := files[bundleIndexFile]
+	if !ok {
+		return nil, errors.Errorf("release bundle has no %s", bundleIndexFile)
+	}
+	var index bundleIndex
+	if err := json.Unmarshal(ib, &index); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", bundleIndexFile)
+	}
+	if index.APIVersion != BundleAPIVersion {
+		return nil, errors.Errorf("unsupported release bundle version %q", index.APIVersion)
+	}
+	if len(index.Revisions) == 0 {
+		return nil, errors.New("release bundle has no revisions")
+	}
+
+	history := make([]*rspb.Release, 0, len(index.Revisions))
+	for _, rev := range index.Revisions {
+		b, ok := files[rev.Path]
+		if !ok {
+			return nil, errors.Errorf("release bundle is missing %q", rev.Path)
+		}
+		rls := &rspb.Release{}
+		if err := json.Unmarshal(b, rls); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %q", rev.Path)
+		}
+		if rls.Name != index.Name || rls.Version != rev.Version {
+			return nil, errors.Errorf("%q does not hold revision %d of release %q", rev.Path, rev.Version, index.Name)
+		}
+		rls.Labels = rev.Labels
+		history = append(history, rls)
+	}
+	SortByRevision(history)
+	return history, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil // import "github.com/open-hand/helm/pkg/releaseutil"
+
+import (
+	"bytes"
+	"testing"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	v1 := tsRelease("angry-bird", 1, 1000, rspb.StatusSuperseded)
+	v2 := tsRelease("angry-bird", 2, 2000, rspb.StatusDeployed)
+	v2.Namespace = "default"
+	v2.Config = map[string]interface{}{"replicas": float64(3)}
+	v2.Manifest = "kind: ConfigMap\n"
+	v2.Labels = map[string]string{"team": "birds"}
+
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, []*rspb.Release{v2, v1}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Version != 1 || history[1].Version != 2 {
+		t.Fatalf("Expected revisions 1 and 2, got %v", history)
+	}
+	got := history[1]
+	if got.Namespace != "default" || got.Manifest != v2.Manifest || got.Config["replicas"] != float64(3) {
+		t.Errorf("Expected revision 2 to be restored, got %+v", got)
+	}
+	if got.Labels["team"] != "birds" {
+		t.Errorf("Expected labels to be restored, got %v", got.Labels)
+	}
+	if !got.Info.LastDeployed.Equal(v2.Info.LastDeployed) {
+		t.Errorf("Expected last deployed %s, got %s", v2.Info.LastDeployed, got.Info.LastDeployed)
+	}
+}
+
+func TestWriteBundleMixedReleases(t *testing.T) {
+	rels := []*rspb.Release{
+		tsRelease("angry-bird", 1, 1000, rspb.StatusDeployed),
+		tsRelease("quiet-bear", 1, 1000, rspb.StatusDeployed),
+	}
+	if err := WriteBundle(&bytes.Buffer{}, rels); err == nil {
+		t.Error("Expected an error writing revisions of different releases")
+	}
+}
+
+func TestReadBundleInvalid(t *testing.T) {
+	if _, err := ReadBundle(bytes.NewBufferString("not a bundle")); err == nil {
+		t.Error("Expected an error reading an invalid bundle")
+	}
+}
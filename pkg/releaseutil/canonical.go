@@ -0,0 +1,107 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// sourcePrefix starts the comment naming the template a rendered document
+// comes from.
+const sourcePrefix = "# Source: "
+
+// canonicalDoc is a document of a manifest being canonicalized.
+type canonicalDoc struct {
+	source    string
+	kind      string
+	namespace string
+	name      string
+	content   string
+}
+
+// CanonicalizeManifest returns the canonical form of a rendered manifest, so
+// that two renders can be compared, or diffed, showing only the changes of
+// their resources.
+//
+// Every document is re-serialized with sorted keys and normalized
+// formatting, keeping the "# Source:" comment naming its template but
+// dropping the other comments. Empty documents are dropped, and the
+// documents are ordered by kind as in InstallOrder, then by namespace, name
+// and template. Every document starts with a "---" separator.
+func CanonicalizeManifest(manifest string) (string, error) {
+	var docs []canonicalDoc
+	for _, d := range SplitManifestDocuments(manifest) {
+		doc := canonicalDoc{}
+		source, content := splitSource(d)
+		doc.source = source
+
+		j, err := yaml.YAMLToJSON([]byte(content))
+		if err != nil {
+			return "", errors.Wrapf(err, "YAML parse error on %s", doc.source)
+		}
+		if string(j) == "null" {
+			continue
+		}
+		var head struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+		}
+		// Documents that are not objects are kept, with an empty head.
+		_ = yaml.Unmarshal(j, &head)
+		y, err := yaml.JSONToYAML(j)
+		if err != nil {
+			return "", err
+		}
+		doc.kind, doc.namespace, doc.name = head.Kind, head.Metadata.Namespace, head.Metadata.Name
+		doc.content = string(y)
+		docs = append(docs, doc)
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		a, b := docs[i], docs[j]
+		if a.kind != b.kind {
+			return lessByKind(a, b, a.kind, b.kind, InstallOrder)
+		}
+		if a.namespace != b.namespace {
+			return a.namespace < b.namespace
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		return a.content < b.content
+	})
+
+	var out strings.Builder
+	for _, doc := range docs {
+		out.WriteString("---\n")
+		if doc.source != "" {
+			out.WriteString(sourcePrefix + doc.source + "\n")
+		}
+		out.WriteString(doc.content)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,137 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"testing"
+)
+
+func TestCanonicalizeManifest(t *testing.T) {
+	a := `---
+# Source: chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels: {app: web, tier: front}
+spec:
+  ports:
+  - port: 80
+---
+# Source: chart/templates/empty.yaml
+# nothing rendered
+---
+# Source: chart/templates/deployment.yaml
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: web
+spec:
+  replicas: 3
+---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+data:
+  big: 12345678901234567890
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`
+	// the same resources in another order and formatting
+	b := `# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: b}
+data: {big: 12345678901234567890}
+
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: web   # the web server
+spec:
+    replicas: 3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: a}
+---
+# Source: chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    tier: front
+    app: web
+  name: web
+spec:
+  ports: [{port: 80}]
+`
+	want := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+data:
+  big: 12345678901234567890
+kind: ConfigMap
+metadata:
+  name: b
+---
+# Source: chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app: web
+    tier: front
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+`
+	for name, manifest := range map[string]string{"a": a, "b": b} {
+		got, err := CanonicalizeManifest(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected the canonical form of %s to be\n%s\ngot\n%s", name, want, got)
+		}
+	}
+
+	if _, err := CanonicalizeManifest("kind: [unterminated"); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
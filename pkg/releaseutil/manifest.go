@@ -18,7 +18,6 @@ package releaseutil
 
 import (
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 )
@@ -33,30 +32,63 @@ type SimpleHead struct {
 	} `json:"metadata,omitempty"`
 }
 
-var sep = regexp.MustCompile("(?:^|\\s*\n)---\\s*")
-
 // SplitManifests takes a string of manifest and returns a map contains individual manifests
 func SplitManifests(bigFile string) map[string]string {
-	// Basically, we're quickly splitting a stream of YAML documents into an
-	// array of YAML docs. The file name is just a place holder, but should be
-	// integer-sortable so that manifests get output in the same order as the
-	// input (see `BySplitManifestsOrder`).
+	// The file name is just a place holder, but should be integer-sortable so
+	// that manifests get output in the same order as the input (see
+	// `BySplitManifestsOrder`).
 	tpl := "manifest-%d"
 	res := map[string]string{}
-	// Making sure that any extra whitespace in YAML stream doesn't interfere in splitting documents correctly.
-	bigFileTmp := strings.TrimSpace(bigFile)
-	docs := sep.Split(bigFileTmp, -1)
-	var count int
-	for _, d := range docs {
-		if d == "" {
+	for i, d := range SplitManifestDocuments(bigFile) {
+		res[fmt.Sprintf(tpl, i)] = d
+	}
+	return res
+}
+
+// SplitManifestDocuments splits a stream of YAML documents into its
+// documents, in order, with their surrounding whitespace trimmed. Documents
+// are separated by the lines starting with a "---" marker, which may be
+// followed by the beginning of the next document on the same line, as in
+// "--- # Source: chart/templates/service.yaml". Empty documents are dropped.
+func SplitManifestDocuments(bigFile string) []string {
+	var docs []string
+	var doc []string
+	flush := func() {
+		if d := strings.TrimSpace(strings.Join(doc, "\n")); d != "" {
+			docs = append(docs, d)
+		}
+		doc = doc[:0]
+	}
+	for _, line := range strings.Split(bigFile, "\n") {
+		rest, ok := documentMarker(line)
+		if !ok {
+			doc = append(doc, line)
 			continue
 		}
+		flush()
+		if rest != "" {
+			doc = append(doc, rest)
+		}
+	}
+	flush()
+	return docs
+}
 
-		d = strings.TrimSpace(d)
-		res[fmt.Sprintf(tpl, count)] = d
-		count = count + 1
+// documentMarker reports whether line starts with a "---" document marker,
+// and returns what follows it.
+func documentMarker(line string) (string, bool) {
+	if !strings.HasPrefix(line, "---") {
+		return "", false
 	}
-	return res
+	rest := strings.TrimRight(line[3:], " \t\r")
+	if rest == "" {
+		return "", true
+	}
+	if rest[0] != ' ' && rest[0] != '\t' {
+		// e.g. "----" or "---foo", which are not markers
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
 }
 
 // BySplitManifestsOrder sorts by in-file manifest order, as provided in function `SplitManifests`
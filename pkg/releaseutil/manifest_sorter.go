@@ -66,6 +66,13 @@ var events = map[string]release.HookEvent{
 	"test-success": release.HookTest,
 }
 
+// ParseHookEvent returns the hook event named s, as in the helm.sh/hook
+// annotation. It returns false if there is no such event.
+func ParseHookEvent(s string) (release.HookEvent, bool) {
+	e, ok := events[s]
+	return e, ok
+}
+
 // SortManifests takes a map of filename/YAML contents, splits the file
 // by manifest entries, and sorts the entries into hook types.
 //
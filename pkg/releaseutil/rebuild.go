@@ -0,0 +1,153 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil // import "github.com/open-hand/helm/pkg/releaseutil"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/pkg/chart"
+)
+
+// serverSetFields are fields that the API server or controllers set on live
+// objects. They are removed by ManifestFromObjects, since a manifest that
+// contained them could not be applied again.
+var serverSetFields = [][]string{
+	{"status"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "selfLink"},
+	{"metadata", "managedFields"},
+	{"metadata", "ownerReferences"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+}
+
+// serverSetKindFields are fields allocated by the API server for objects of
+// a given kind. They are immutable, so a manifest recording them would make
+// subsequent upgrades that do not set them fail.
+var serverSetKindFields = map[string][][]string{
+	"Service": {
+		{"spec", "clusterIP"},
+		{"spec", "clusterIPs"},
+	},
+	"PersistentVolumeClaim": {
+		{"spec", "volumeName"},
+	},
+}
+
+// ManifestFromObjects renders live objects as a release manifest, so that a
+// release record can be rebuilt from the state of a cluster. Objects are
+// ordered by InstallOrder and name, and fields set by the API server are
+// removed. The objects are not modified.
+func ManifestFromObjects(objs []*unstructured.Unstructured) (string, error) {
+	sorted := make([]*unstructured.Unstructured, len(objs))
+	copy(sorted, objs)
+	ordering := make(map[string]int, len(InstallOrder))
+	for i, k := range InstallOrder {
+		ordering[k] = i
+	}
+	rank := func(kind string) int {
+		if i, ok := ordering[kind]; ok {
+			return i
+		}
+		return len(InstallOrder)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ra, rb := rank(a.GetKind()), rank(b.GetKind()); ra != rb {
+			return ra < rb
+		}
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		return a.GetName() < b.GetName()
+	})
+
+	var sb strings.Builder
+	for _, obj := range sorted {
+		obj = obj.DeepCopy()
+		for _, f := range append(serverSetFields, serverSetKindFields[obj.GetKind()]...) {
+			unstructured.RemoveNestedField(obj.Object, f...)
+		}
+		b, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "---\n# Source: %s/%s.yaml\n%s", strings.ToLower(obj.GetKind()), obj.GetName(), b)
+	}
+	return sb.String(), nil
+}
+
+// ChartMetadataFromObjects guesses the metadata of the chart that created
+// objects from the helm.sh/chart and app.kubernetes.io/version labels that
+// charts conventionally set. The most common values are used. It returns nil
+// if no object carries a chart label.
+func ChartMetadataFromObjects(objs []*unstructured.Unstructured) *chart.Metadata {
+	chartLabel := mostCommonLabel(objs, "helm.sh/chart")
+	if chartLabel == "" {
+		return nil
+	}
+	md := &chart.Metadata{
+		APIVersion: chart.APIVersionV2,
+		Name:       chartLabel,
+		AppVersion: mostCommonLabel(objs, "app.kubernetes.io/version"),
+	}
+	// The label is "<name>-<version>", with '+' replaced by '_' since it
+	// is not allowed in label values. The name may itself contain dashes.
+	for i := strings.Index(chartLabel, "-"); i >= 0; {
+		version := strings.ReplaceAll(chartLabel[i+1:], "_", "+")
+		if _, err := semver.StrictNewVersion(version); err == nil {
+			md.Name, md.Version = chartLabel[:i], version
+			break
+		}
+		next := strings.Index(chartLabel[i+1:], "-")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return md
+}
+
+func mostCommonLabel(objs []*unstructured.Unstructured, key string) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, obj := range objs {
+		v, ok := obj.GetLabels()[key]
+		if !ok || v == "" {
+			continue
+		}
+		counts[v]++
+		if c := counts[v]; c > counts[best] || (c == counts[best] && v < best) {
+			best = v
+		}
+	}
+	return best
+}
@@ -0,0 +1,97 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil // import "github.com/open-hand/helm/pkg/releaseutil"
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func liveObject(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       "default",
+			"uid":             "0000",
+			"resourceVersion": "42",
+			"labels": map[string]interface{}{
+				"helm.sh/chart":             "my-web-app-1.2.0-rc.1_build.5",
+				"app.kubernetes.io/version": "2.0",
+			},
+		},
+		"spec":   spec,
+		"status": map[string]interface{}{"phase": "Active"},
+	}}
+}
+
+func TestManifestFromObjects(t *testing.T) {
+	objs := []*unstructured.Unstructured{
+		liveObject("Service", "web", map[string]interface{}{"clusterIP": "10.0.0.1", "type": "ClusterIP"}),
+		liveObject("ConfigMap", "web", nil),
+	}
+	manifest, err := ManifestFromObjects(objs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `---
+# Source: configmap/web.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app.kubernetes.io/version: "2.0"
+    helm.sh/chart: my-web-app-1.2.0-rc.1_build.5
+  name: web
+  namespace: default
+spec: null
+---
+# Source: service/web.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app.kubernetes.io/version: "2.0"
+    helm.sh/chart: my-web-app-1.2.0-rc.1_build.5
+  name: web
+  namespace: default
+spec:
+  type: ClusterIP
+`
+	if manifest != expect {
+		t.Errorf("Expected manifest:\n%s\ngot:\n%s", expect, manifest)
+	}
+	if _, ok := objs[0].Object["status"]; !ok {
+		t.Error("Expected the objects not to be modified")
+	}
+}
+
+func TestChartMetadataFromObjects(t *testing.T) {
+	md := ChartMetadataFromObjects([]*unstructured.Unstructured{liveObject("ConfigMap", "web", nil)})
+	if md == nil {
+		t.Fatal("Expected chart metadata")
+	}
+	if md.Name != "my-web-app" || md.Version != "1.2.0-rc.1+build.5" || md.AppVersion != "2.0" {
+		t.Errorf("Unexpected chart metadata %+v", md)
+	}
+
+	if md := ChartMetadataFromObjects(nil); md != nil {
+		t.Errorf("Expected no chart metadata, got %+v", md)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Resource is a resource of a rendered manifest, such as the manifest of a
+// release.
+type Resource struct {
+	schema.GroupVersionKind
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Source is the template the resource was rendered from, given by the
+	// "# Source:" comment of its document, if any.
+	Source string
+	// Content is the YAML document of the resource, without its "# Source:"
+	// comment.
+	Content string
+	// Object is the parsed document.
+	Object map[string]interface{}
+}
+
+// ParseManifest parses the documents of a rendered manifest into resources,
+// in the order of the manifest. Empty documents, and the ones holding only
+// comments, are skipped.
+func ParseManifest(manifest string) ([]Resource, error) {
+	var resources []Resource
+	for _, doc := range SplitManifestDocuments(manifest) {
+		source, content := splitSource(doc)
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(content), &obj); err != nil {
+			if source != "" {
+				return nil, errors.Wrapf(err, "YAML parse error on %s", source)
+			}
+			return nil, errors.Wrap(err, "YAML parse error")
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		res := Resource{Source: source, Content: content, Object: obj}
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		res.GroupVersionKind = schema.FromAPIVersionAndKind(apiVersion, kind)
+		if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+			res.Name, _ = metadata["name"].(string)
+			res.Namespace, _ = metadata["namespace"].(string)
+			res.Labels = stringMap(metadata["labels"])
+			res.Annotations = stringMap(metadata["annotations"])
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// APIVersion returns the API version of the resource, e.g. "apps/v1".
+func (r *Resource) APIVersion() string {
+	v, _ := r.GroupVersionKind.ToAPIVersionAndKind()
+	return v
+}
+
+// splitSource returns the template in the "# Source:" comment of a
+// document, if any, and the document without the comment.
+func splitSource(doc string) (string, string) {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, sourcePrefix) {
+			source := strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix))
+			return source, strings.Join(append(lines[:i:i], lines[i+1:]...), "\n")
+		}
+	}
+	return "", doc
+}
+
+// stringMap returns the string values of a map of a parsed document, such
+// as its labels.
+func stringMap(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			res[k] = s
+		}
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package releaseutil
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseManifest(t *testing.T) {
+	manifest := `---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    note: |
+      ---
+      not a separator
+spec:
+  replicas: 1
+--- # Source: chart/templates/empty.yaml
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  banner: "----"
+`
+	resources, err := ParseManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resources))
+	}
+
+	web := resources[0]
+	if want := (schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}); web.GroupVersionKind != want {
+		t.Errorf("expected %v, got %v", want, web.GroupVersionKind)
+	}
+	if web.APIVersion() != "apps/v1" || web.Name != "web" || web.Namespace != "prod" || web.Source != "chart/templates/deployment.yaml" {
+		t.Errorf("unexpected resource %+v", web)
+	}
+	if !reflect.DeepEqual(web.Labels, map[string]string{"app": "web"}) {
+		t.Errorf("unexpected labels %v", web.Labels)
+	}
+	if web.Annotations["note"] != "---\nnot a separator\n" {
+		t.Errorf("expected the annotation to keep its indented marker, got %q", web.Annotations["note"])
+	}
+
+	config := resources[1]
+	if config.APIVersion() != "v1" || config.Kind != "ConfigMap" || config.Source != "" || config.Labels != nil {
+		t.Errorf("unexpected resource %+v", config)
+	}
+
+	if _, err := ParseManifest("# Source: chart/templates/bad.yaml\nkind: [unterminated"); err == nil {
+		t.Error("expected an error for invalid YAML")
+	}
+}
+
+func TestSplitManifestDocuments(t *testing.T) {
+	docs := SplitManifestDocuments("a: 1\n---\n\n--- b: 2\n----\n---foo\n---\t\nc: 3\n")
+	want := []string{"a: 1", "b: 2\n----\n---foo", "c: 3"}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("expected %q, got %q", want, docs)
+	}
+}
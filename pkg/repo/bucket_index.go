@@ -0,0 +1,255 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/open-hand/helm/internal/urlutil"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/getter"
+	"github.com/open-hand/helm/pkg/provenance"
+)
+
+// metadataReadSize is the size of the first read of the chart archives
+// indexed by IndexBucket, doubled until Chart.yaml is found.
+const metadataReadSize = 64 * 1024
+
+// IndexBucketOptions are the options of IndexBucket.
+type IndexBucketOptions struct {
+	// Getters provide the getter of the scheme of the bucket URL, which must
+	// be a getter.BucketLister. The chart archives are read partially if it
+	// is a getter.RangeGetter, and downloaded otherwise.
+	Getters getter.Providers
+	// Options are passed to the getter.
+	Options []getter.Option
+	// BaseURL is the URL of the chart repository served from the bucket. The
+	// charts are indexed by their path relative to the bucket URL if it is
+	// empty, to serve the index from the bucket URL.
+	BaseURL string
+	// Previous is a previous index of the bucket. The charts it has at the
+	// same URL are copied from it instead of being read again, with their
+	// digest.
+	Previous *IndexFile
+	// Digests downloads the whole chart archives to compute their digests,
+	// which are left empty otherwise since they cannot be computed from
+	// partial reads.
+	Digests bool
+	// Concurrency is the number of archives read at once, 8 by default.
+	Concurrency int
+}
+
+// IndexBucket generates the index of the charts in an object storage
+// bucket, e.g. s3://bucket/charts or gs://bucket/charts, from a listing of
+// the bucket without downloading the whole archives: only their beginning,
+// up to their Chart.yaml, is read.
+//
+// The index returned is sorted.
+func IndexBucket(ctx context.Context, bucketURL string, opts IndexBucketOptions) (*IndexFile, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid bucket URL %s", bucketURL)
+	}
+	newGetter := func() (getter.BucketLister, error) {
+		g, err := opts.Getters.ByScheme(u.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		lister, ok := g.(getter.BucketLister)
+		if !ok {
+			return nil, errors.Errorf("the getter of the scheme %q cannot list buckets", u.Scheme)
+		}
+		return lister, nil
+	}
+	lister, err := newGetter()
+	if err != nil {
+		return nil, err
+	}
+	getterOpts := append([]getter.Option{getter.WithContext(ctx)}, opts.Options...)
+
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	listURL := *u
+	listURL.Path = "/" + prefix
+	objects, err := lister.List(listURL.String(), getterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := map[string]*ChartVersion{}
+	if opts.Previous != nil {
+		for _, versions := range opts.Previous.Entries {
+			for _, cv := range versions {
+				if len(cv.URLs) > 0 {
+					previous[cv.URLs[0]] = cv
+				}
+			}
+		}
+	}
+
+	type job struct {
+		object getter.BucketObject
+		url    string
+	}
+	var jobs []job
+	index := NewIndexFile()
+	for _, o := range objects {
+		if !strings.HasSuffix(o.Key, ".tgz") || !strings.HasPrefix(o.Key, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(o.Key, prefix)
+		chartURL := rel
+		if opts.BaseURL != "" {
+			if chartURL, err = urlutil.URLJoin(opts.BaseURL, rel); err != nil {
+				chartURL = path.Join(opts.BaseURL, rel)
+			}
+		}
+		if cv, ok := previous[chartURL]; ok {
+			index.Entries[cv.Name] = append(index.Entries[cv.Name], cv)
+			continue
+		}
+		jobs = append(jobs, job{object: o, url: chartURL})
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		queue    = make(chan job)
+	)
+	for w := 0; w < concurrency && w < len(jobs); w++ {
+		g, err := newGetter()
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				objectURL := *u
+				objectURL.Path = "/" + j.object.Key
+				cv, err := indexBucketObject(g, objectURL.String(), j.object, opts.Digests, getterOpts)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = errors.Wrapf(err, "failed to index %s", objectURL.String())
+				}
+				if err == nil {
+					cv.URLs = []string{j.url}
+					index.Entries[cv.Name] = append(index.Entries[cv.Name], cv)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, j := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	index.SortEntries()
+	return index, nil
+}
+
+// indexBucketObject returns the index entry of the chart archive at href,
+// without its URLs.
+func indexBucketObject(g getter.Getter, href string, o getter.BucketObject, digest bool, opts []getter.Option) (*ChartVersion, error) {
+	var (
+		md  *chart.Metadata
+		sum string
+		err error
+	)
+	rg, partial := g.(getter.RangeGetter)
+	if digest || !partial {
+		data, err := g.Get(href, opts...)
+		if err != nil {
+			return nil, err
+		}
+		archive := data.Bytes()
+		if sum, err = provenance.Digest(bytes.NewReader(archive)); err != nil {
+			return nil, err
+		}
+		md, err = readArchiveMetadata(archive, true)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for length := int64(metadataReadSize); ; length *= 2 {
+			complete := o.Size > 0 && length >= o.Size
+			data, err := rg.GetRange(href, 0, length, opts...)
+			if err != nil {
+				return nil, err
+			}
+			md, err = readArchiveMetadata(data.Bytes(), complete || int64(data.Len()) < length)
+			if err == nil {
+				break
+			}
+			if err != loader.ErrIncompleteArchive {
+				return nil, err
+			}
+		}
+	}
+
+	if md.APIVersion == "" {
+		md.APIVersion = chart.APIVersionV1
+	}
+	if err = md.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validate failed")
+	}
+	return &ChartVersion{Metadata: md, Digest: sum, Created: o.LastModified}, nil
+}
+
+// readArchiveMetadata reads the metadata of the chart from the beginning of
+// its archive, which is complete if the whole archive is given.
+func readArchiveMetadata(archive []byte, complete bool) (*chart.Metadata, error) {
+	files, err := loader.LoadPartialArchiveFiles(archive, complete, chartutil.ChartfileName)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("Chart.yaml file is missing")
+	}
+	md := &chart.Metadata{}
+	if err := yaml.Unmarshal(files[0].Data, md); err != nil {
+		return nil, errors.Wrap(err, "cannot load Chart.yaml")
+	}
+	return md, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+// ChannelAnnotation is the chart annotation declaring the release channel of
+// a chart version, such as "beta".
+const ChannelAnnotation = "helm.sh/channel"
+
+// The release channels, from the most to the least conservative. The
+// consumers of a channel also get the versions of the more conservative ones:
+// beta consumers get stable and beta versions, edge consumers get them all.
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+	ChannelEdge   = "edge"
+)
+
+// Channels are the release channels, from the most to the least conservative.
+var Channels = []string{ChannelStable, ChannelBeta, ChannelEdge}
+
+// ValidateChannel returns an error if channel is not a release channel.
+func ValidateChannel(channel string) error {
+	if channelRank(channel) < 0 {
+		return errors.Errorf("unknown channel %q, expected one of %s", channel, strings.Join(Channels, ", "))
+	}
+	return nil
+}
+
+func channelRank(channel string) int {
+	for i, c := range Channels {
+		if c == channel {
+			return i
+		}
+	}
+	return -1
+}
+
+// Channel returns the release channel of the chart version: the one declared
+// by its ChannelAnnotation, otherwise stable for releases and edge for
+// pre-releases.
+func (cv *ChartVersion) Channel() string {
+	if cv.Metadata != nil {
+		if c := strings.TrimSpace(cv.Annotations[ChannelAnnotation]); channelRank(c) >= 0 {
+			return c
+		}
+	}
+	if v, err := semver.NewVersion(cv.Version); err == nil && v.Prerelease() != "" {
+		return ChannelEdge
+	}
+	return ChannelStable
+}
+
+// InChannel tells whether the chart version is available to the consumers of
+// channel.
+func (cv *ChartVersion) InChannel(channel string) bool {
+	return channelRank(cv.Channel()) <= channelRank(channel)
+}
+
+// GetInChannel returns the newest version of the chart matching the version
+// constraint that is available in the release channel, see Get.
+//
+// Unlike with Get, pre-release versions match an empty version as long as
+// they are available in the channel. An empty channel behaves like Get, and
+// so does a digest, which pins a version whatever its channel.
+func (i IndexFile) GetInChannel(name, version, channel string) (*ChartVersion, error) {
+	if channel == "" || IsDigest(version) {
+		return i.Get(name, version)
+	}
+	if err := ValidateChannel(channel); err != nil {
+		return nil, err
+	}
+	vs, ok := i.Entries[name]
+	if !ok {
+		return nil, ErrNoChartName
+	}
+
+	// An exact version is returned whatever its channel.
+	for _, ver := range vs {
+		if version != "" && version == ver.Version {
+			return ver, nil
+		}
+	}
+
+	if version == "" {
+		version = ">0.0.0-0"
+	}
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, err
+	}
+	for _, ver := range vs {
+		test, err := semver.NewVersion(ver.Version)
+		if err != nil {
+			continue
+		}
+		if constraint.Check(test) && ver.InChannel(channel) {
+			return ver, nil
+		}
+	}
+	return nil, errors.Errorf("no chart version found for %s-%s in the %s channel", name, version, channel)
+}
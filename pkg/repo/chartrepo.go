@@ -57,6 +57,27 @@ type Entry struct {
 	CAFile                string `json:"caFile"`
 	InsecureSkipTLSverify bool   `json:"insecure_skip_tls_verify"`
 	PassCredentialsAll    bool   `json:"pass_credentials_all"`
+	// CredentialStore is the name of the credential store holding the
+	// username and password of the repository, which are then left out of
+	// the repositories file, see NewCredentialStore.
+	CredentialStore string `json:"credentialStore,omitempty"`
+	// Aliases are other names the repository can be referred to by, such as
+	// its former names, in chart references like "alias/chart" or
+	// "@alias/chart" and in the repository of dependencies.
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// HasName tells whether the repository is named name or has it as an alias.
+func (e *Entry) HasName(name string) bool {
+	if e.Name == name {
+		return true
+	}
+	for _, alias := range e.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
 }
 
 // ChartRepository represents a chart repository
@@ -132,12 +153,16 @@ func (r *ChartRepository) DownloadIndexFile() (*IndexFile, string, error) {
 	parsedURL.Path = path.Join(parsedURL.Path, "index.yaml")
 
 	indexURL := parsedURL.String()
+	username, password, err := r.Config.Credentials()
+	if err != nil {
+		return nil, "", err
+	}
 	// TODO add user-agent
 	resp, err := r.Client.Get(indexURL,
 		getter.WithURL(r.Config.URL),
 		getter.WithInsecureSkipVerifyTLS(r.Config.InsecureSkipTLSverify),
 		getter.WithTLSClientConfig(r.Config.CertFile, r.Config.KeyFile, r.Config.CAFile),
-		getter.WithBasicAuth(r.Config.Username, r.Config.Password),
+		getter.WithBasicAuth(username, password),
 		getter.WithPassCredentialsAll(r.Config.PassCredentialsAll),
 	)
 	if err != nil {
@@ -219,6 +244,13 @@ func FindChartInRepoURL(repoURL, chartName, chartVersion, certFile, keyFile, caF
 // without adding repo to repositories, like FindChartInRepoURL,
 // but it also receives credentials for the chart repository.
 func FindChartInAuthRepoURL(repoURL, username, password, chartName, chartVersion, certFile, keyFile, caFile string, getters getter.Providers) (string, error) {
+	return FindChartInChannelRepoURL(repoURL, username, password, chartName, chartVersion, "", certFile, keyFile, caFile, getters)
+}
+
+// FindChartInChannelRepoURL finds chart in chart repository pointed by repoURL
+// like FindChartInAuthRepoURL, but only among the versions available in the
+// release channel, see IndexFile.GetInChannel.
+func FindChartInChannelRepoURL(repoURL, username, password, chartName, chartVersion, channel, certFile, keyFile, caFile string, getters getter.Providers) (string, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	var repoIndex *IndexFile
@@ -240,7 +272,10 @@ func FindChartInAuthRepoURL(repoURL, username, password, chartName, chartVersion
 	if chartVersion != "" {
 		errMsg = fmt.Sprintf("%s version %q", errMsg, chartVersion)
 	}
-	cv, err := repoIndex.Get(chartName, chartVersion)
+	if channel != "" {
+		errMsg = fmt.Sprintf("%s in the %s channel", errMsg, channel)
+	}
+	cv, err := repoIndex.GetInChannel(chartName, chartVersion, channel)
 	// err不为nil，可能是repoIndex数据过旧造成，尝试更新repoIndex后再获取ChartVersion,如果err仍不为nil，返回错误
 	if err != nil {
 		// 删除旧缓存
@@ -249,7 +284,7 @@ func FindChartInAuthRepoURL(repoURL, username, password, chartName, chartVersion
 		if err != nil {
 			return "", err
 		}
-		cv, err = repoIndex.Get(chartName, chartVersion)
+		cv, err = repoIndex.GetInChannel(chartName, chartVersion, channel)
 		if err != nil {
 			return "", errors.Errorf("%s not found in %s repository", errMsg, repoURL)
 		}
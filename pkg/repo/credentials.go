@@ -0,0 +1,164 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo // import "github.com/open-hand/helm/pkg/repo"
+
+import (
+	"runtime"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/pkg/errors"
+)
+
+// KeychainCredentialStore is the name of the credential store of the
+// keychain of the operating system: the macOS Keychain, the Windows
+// Credential Manager, or the Secret Service on other systems.
+const KeychainCredentialStore = "keychain"
+
+// CredentialStore stores the credentials of chart repositories, by their
+// URL, outside of the repositories file.
+type CredentialStore interface {
+	// Get returns the username and password stored for the repository URL,
+	// or an error satisfying IsCredentialsNotFound if there are none.
+	Get(url string) (username, password string, err error)
+	// Store stores the username and password of the repository URL.
+	Store(url, username, password string) error
+	// Erase removes the credentials of the repository URL.
+	Erase(url string) error
+}
+
+// IsCredentialsNotFound reports whether err is returned by a CredentialStore
+// that has no credentials for a repository.
+func IsCredentialsNotFound(err error) bool {
+	return credentials.IsErrCredentialsNotFound(errors.Cause(err))
+}
+
+// NewCredentialStore returns the credential store name, implemented by the
+// credential helper program docker-credential-<name> found in the PATH,
+// following the protocol of the Docker credential helpers, e.g.
+// docker-credential-pass. KeychainCredentialStore is implemented by the
+// helper of the keychain of the operating system: osxkeychain, wincred, or
+// secretservice.
+func NewCredentialStore(name string) CredentialStore {
+	if name == KeychainCredentialStore {
+		switch runtime.GOOS {
+		case "darwin":
+			name = "osxkeychain"
+		case "windows":
+			name = "wincred"
+		default:
+			name = "secretservice"
+		}
+	}
+	return helperCredentialStore{program: client.NewShellProgramFunc("docker-credential-" + name)}
+}
+
+// helperCredentialStore is a CredentialStore running a credential helper.
+type helperCredentialStore struct {
+	program client.ProgramFunc
+}
+
+func (s helperCredentialStore) Get(url string) (string, string, error) {
+	creds, err := client.Get(s.program, url)
+	if err != nil {
+		return "", "", err
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+func (s helperCredentialStore) Store(url, username, password string) error {
+	return client.Store(s.program, &credentials.Credentials{ServerURL: url, Username: username, Secret: password})
+}
+
+func (s helperCredentialStore) Erase(url string) error {
+	return client.Erase(s.program, url)
+}
+
+// Credentials returns the username and password of the repository, looked
+// up in its credential store if they are not in the repositories file.
+func (e *Entry) Credentials() (username, password string, err error) {
+	if e.CredentialStore == "" || e.Username != "" || e.Password != "" {
+		return e.Username, e.Password, nil
+	}
+	username, password, err = NewCredentialStore(e.CredentialStore).Get(e.URL)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to get the credentials of the repository %q from the credential store %q", e.Name, e.CredentialStore)
+	}
+	return username, password, nil
+}
+
+// StoreCredentials moves the username and password of the repository from
+// the repositories file to the credential store name.
+func (e *Entry) StoreCredentials(name string) error {
+	if e.Username == "" && e.Password == "" {
+		return nil
+	}
+	if err := NewCredentialStore(name).Store(e.URL, e.Username, e.Password); err != nil {
+		return errors.Wrapf(err, "failed to store the credentials of the repository %q in the credential store %q", e.Name, name)
+	}
+	e.CredentialStore = name
+	e.Username, e.Password = "", ""
+	return nil
+}
+
+// EraseCredentials removes the credentials of the repository from its
+// credential store, if it has one.
+func (e *Entry) EraseCredentials() error {
+	if e.CredentialStore == "" {
+		return nil
+	}
+	err := NewCredentialStore(e.CredentialStore).Erase(e.URL)
+	if err != nil && !IsCredentialsNotFound(err) {
+		return errors.Wrapf(err, "failed to erase the credentials of the repository %q from the credential store %q", e.Name, e.CredentialStore)
+	}
+	return nil
+}
+
+// MigrateCredentials moves the usernames and passwords of the repositories
+// with the given names, or of all the repositories if no names are given,
+// from the repositories file to the credential store name. It returns the
+// names of the repositories whose credentials were moved.
+func (r *File) MigrateCredentials(name string, names ...string) ([]string, error) {
+	for _, n := range names {
+		if !r.Has(n) {
+			return nil, errors.Errorf("no repo named %q found", n)
+		}
+	}
+	var migrated []string
+	for _, e := range r.Repositories {
+		if len(names) > 0 && !containsName(names, e.Name) {
+			continue
+		}
+		if e.Username == "" && e.Password == "" {
+			continue
+		}
+		if err := e.StoreCredentials(name); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, e.Name)
+	}
+	return migrated, nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -23,6 +23,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -40,6 +41,9 @@ import (
 
 var indexPath = "index.yaml"
 
+// digestRegexp matches the digests pinning chart archives.
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
 // APIVersionV1 is the v1 API version for index and repository files.
 const APIVersionV1 = "v1"
 
@@ -172,10 +176,28 @@ func (i IndexFile) SortEntries() {
 	}
 }
 
+// IsDigest tells whether a chart version is the SHA-256 digest of a chart
+// archive, as in "sha256:" followed by the hex encoded digest, rather than a
+// version or a version constraint.
+func IsDigest(version string) bool {
+	return digestRegexp.MatchString(version)
+}
+
+// getByDigest returns the version of the chart whose archive has the digest.
+func (i IndexFile) getByDigest(name, digest string) (*ChartVersion, error) {
+	for _, ver := range i.Entries[name] {
+		if strings.TrimPrefix(ver.Digest, "sha256:") == strings.TrimPrefix(digest, "sha256:") {
+			return ver, nil
+		}
+	}
+	return nil, errors.Errorf("no chart version found for %s with digest %s", name, digest)
+}
+
 // Get returns the ChartVersion for the given name.
 //
 // If version is empty, this will return the chart with the latest stable version,
-// prerelease versions will be skipped.
+// prerelease versions will be skipped. If version is a digest, see IsDigest,
+// this will return the chart version whose archive has this digest.
 func (i IndexFile) Get(name, version string) (*ChartVersion, error) {
 	vs, ok := i.Entries[name]
 	if !ok {
@@ -184,6 +206,9 @@ func (i IndexFile) Get(name, version string) (*ChartVersion, error) {
 	if len(vs) == 0 {
 		return nil, ErrNoChartVersion
 	}
+	if IsDigest(version) {
+		return i.getByDigest(name, version)
+	}
 
 	var constraint *semver.Constraints
 	if version == "" {
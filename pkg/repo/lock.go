@@ -0,0 +1,169 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo // import "github.com/open-hand/helm/pkg/repo"
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultLockTimeout is how long the mutations of a repositories file
+	// wait for the lock held by another process by default.
+	DefaultLockTimeout = 30 * time.Second
+	// DefaultLockRetryInterval is the delay between two attempts to acquire
+	// the lock of a repositories file by default.
+	DefaultLockRetryInterval = time.Second
+)
+
+var (
+	// ErrFileLocked is returned when the lock of a repositories file held by
+	// another process could not be acquired in time.
+	ErrFileLocked = errors.New("the repositories file is locked by another process")
+	// ErrSkipWrite is returned by the update function of UpdateFile to leave
+	// the repositories file as it is. UpdateFile then returns nil.
+	ErrSkipWrite = errors.New("the repositories file is unchanged")
+)
+
+// LockOptions configure how the mutations of a repositories file wait for
+// its lock when another process holds it.
+type LockOptions struct {
+	// Timeout is how long to retry acquiring the lock, DefaultLockTimeout if
+	// zero. A negative timeout tries once, failing right away if the file is
+	// locked.
+	Timeout time.Duration
+	// RetryInterval is the delay between two attempts,
+	// DefaultLockRetryInterval if zero.
+	RetryInterval time.Duration
+}
+
+// LockPath returns the path of the lock file of the repositories file path:
+// path with its extension replaced by .lock, or with .lock appended if it has
+// none. The file is shared with the versions of helm locking the file in
+// repo add only, so its name must not change.
+func LockPath(path string) string {
+	ext := filepath.Ext(path)
+	if len(ext) > 0 && len(ext) < len(path) {
+		return strings.TrimSuffix(path, ext) + ".lock"
+	}
+	return path + ".lock"
+}
+
+// LockFile acquires the advisory lock of the repositories file path, which
+// the helm processes mutating the file hold, creating the directory of the
+// file if needed. It returns the function releasing the lock.
+func LockFile(path string, opts LockOptions) (unlock func() error, err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	lock := flock.New(LockPath(path))
+
+	timeout, interval := opts.Timeout, opts.RetryInterval
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
+	}
+	if interval <= 0 {
+		interval = DefaultLockRetryInterval
+	}
+	var locked bool
+	if timeout < 0 {
+		locked, err = lock.TryLock()
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		locked, err = lock.TryLockContext(ctx, interval)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to lock the repositories file %s", path)
+	}
+	if !locked {
+		return nil, errors.Wrapf(ErrFileLocked, "%s", path)
+	}
+	return lock.Unlock, nil
+}
+
+// UpdateFile applies update to the repositories file path while holding its
+// lock, so that the concurrent mutations of the file by other processes are
+// not lost. The file is loaded once the lock is acquired, or is a new file
+// if it does not exist, and it is written if update succeeds, unless it
+// returns ErrSkipWrite.
+func UpdateFile(path string, opts LockOptions, update func(*File) error) error {
+	unlock, err := LockFile(path, opts)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := LoadFile(path)
+	if os.IsNotExist(errors.Cause(err)) {
+		f, err = NewFile(), nil
+	}
+	if err != nil {
+		return err
+	}
+	switch err := update(f); {
+	case errors.Is(err, ErrSkipWrite):
+		return nil
+	case err != nil:
+		return err
+	}
+	return f.WriteFile(path, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it to path, so that readers never see a partially written file. An
+// existing file keeps its permissions, and a symbolic link its target.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,198 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestLockPath(t *testing.T) {
+	for path, expect := range map[string]string{
+		"/home/repositories.yaml": "/home/repositories.lock",
+		"/home/repositories":      "/home/repositories.lock",
+	} {
+		if got := LockPath(path); got != expect {
+			t.Errorf("LockPath(%q): expected %q, got %q", path, expect, got)
+		}
+	}
+}
+
+func TestLockFileContention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "repositories.yaml")
+	unlock, err := LockFile(path, LockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LockFile(path, LockOptions{Timeout: -1}); !errors.Is(err, ErrFileLocked) {
+		t.Fatalf("expected the file to be locked, got %v", err)
+	}
+
+	// A waiting mutation acquires the lock once it is released.
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(released)
+		unlock()
+	}()
+	unlock2, err := LockFile(path, LockOptions{Timeout: 5 * time.Second, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-released:
+	default:
+		t.Error("expected the lock to be acquired after it was released")
+	}
+	if err := unlock2(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockFileTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	unlock, err := LockFile(path, LockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	start := time.Now()
+	_, err = LockFile(path, LockOptions{Timeout: 100 * time.Millisecond, RetryInterval: 10 * time.Millisecond})
+	if !errors.Is(err, ErrFileLocked) {
+		t.Fatalf("expected the file to be locked, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected to wait for the timeout, gave up after %s", elapsed)
+	}
+}
+
+func TestUpdateFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	opts := LockOptions{Timeout: 10 * time.Second, RetryInterval: 5 * time.Millisecond}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateFile(path, opts, func(f *File) error {
+				f.Update(&Entry{Name: fmt.Sprintf("repo-%d", i), URL: fmt.Sprintf("https://example.com/%d", i)})
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Repositories) != n {
+		t.Fatalf("expected the %d repositories to be kept, got %d", n, len(f.Repositories))
+	}
+	for i := 0; i < n; i++ {
+		if !f.Has(fmt.Sprintf("repo-%d", i)) {
+			t.Errorf("expected repo-%d to be kept", i)
+		}
+	}
+}
+
+func TestUpdateFileUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	data := []byte("apiVersion: v1\nrepositories: []\n# kept\n")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, updateErr := range []error{ErrSkipWrite, errors.New("failed")} {
+		err := UpdateFile(path, LockOptions{}, func(f *File) error {
+			f.Add(&Entry{Name: "ignored"})
+			return updateErr
+		})
+		if updateErr == ErrSkipWrite && err != nil {
+			t.Errorf("expected no error skipping the write, got %v", err)
+		}
+		if updateErr != ErrSkipWrite && err != updateErr {
+			t.Errorf("expected the error of the update, got %v", err)
+		}
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(data) {
+			t.Errorf("expected the file to be unchanged, got %q", got)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.yaml")
+	if err := ioutil.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "repositories.yaml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip(err)
+	}
+
+	if err := writeFileAtomic(link, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the symbolic link to be kept, got %v, %v", fi, err)
+	}
+	data, err := ioutil.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Errorf("expected the target to be replaced, got %q", data)
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the target to keep its permissions 0600, got %o", fi.Mode().Perm())
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected no temporary file to be left, got %d files", len(entries))
+	}
+}
@@ -86,12 +86,19 @@ func (r *File) Has(name string) bool {
 }
 
 // Get returns an entry with the given name if it exists, otherwise returns nil
+//
+// The names of the repositories take precedence over their aliases.
 func (r *File) Get(name string) *Entry {
 	for _, entry := range r.Repositories {
 		if entry.Name == name {
 			return entry
 		}
 	}
+	for _, entry := range r.Repositories {
+		if entry.HasName(name) {
+			return entry
+		}
+	}
 	return nil
 }
 
@@ -111,6 +118,10 @@ func (r *File) Remove(name string) bool {
 }
 
 // WriteFile writes a repositories file to the given path.
+//
+// The file is replaced atomically, keeping its permissions if it exists. It
+// does not lock the file: see UpdateFile and LockFile to mutate a file other
+// processes may mutate concurrently.
 func (r *File) WriteFile(path string, perm os.FileMode) error {
 	data, err := yaml.Marshal(r)
 	if err != nil {
@@ -119,5 +130,5 @@ func (r *File) WriteFile(path string, perm os.FileMode) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path, data, perm)
+	return writeFileAtomic(path, data, perm)
 }
@@ -0,0 +1,409 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package sbom generates software bills of materials for charts.
+
+A bill of materials lists the templates of a chart, its dependencies, and the
+container images referenced by its default values, in the SPDX or CycloneDX
+JSON format.
+*/
+package sbom // import "github.com/open-hand/helm/pkg/sbom"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/open-hand/helm/internal/version"
+	"github.com/open-hand/helm/pkg/chart"
+)
+
+// Format is the format of a bill of materials.
+type Format string
+
+const (
+	// SPDX is the SPDX 2.3 JSON format.
+	SPDX Format = "spdx"
+	// CycloneDX is the CycloneDX 1.4 JSON format.
+	CycloneDX Format = "cyclonedx"
+)
+
+// Media types of bills of materials, as stored in OCI registries.
+const (
+	SPDXMediaType      = "application/spdx+json"
+	CycloneDXMediaType = "application/vnd.cyclonedx+json"
+)
+
+// FileSuffix is appended to the name of a chart archive to name the bill of
+// materials stored next to it.
+const FileSuffix = ".sbom.json"
+
+// Formats returns the names of the supported formats.
+func Formats() []string {
+	return []string{string(SPDX), string(CycloneDX)}
+}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case SPDX, CycloneDX:
+		return Format(s), nil
+	}
+	return "", errors.Errorf("invalid SBOM format %q, must be one of: %s", s, strings.Join(Formats(), ", "))
+}
+
+// Detect returns the format of a bill of materials.
+func Detect(data []byte) (Format, error) {
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", errors.Wrap(err, "unable to parse SBOM")
+	}
+	switch {
+	case doc.SPDXVersion != "":
+		return SPDX, nil
+	case doc.BOMFormat == "CycloneDX":
+		return CycloneDX, nil
+	}
+	return "", errors.New("unable to detect the format of the SBOM")
+}
+
+// MediaType returns the media type of bills of materials in format f.
+func (f Format) MediaType() string {
+	if f == CycloneDX {
+		return CycloneDXMediaType
+	}
+	return SPDXMediaType
+}
+
+// Options configures the generation of a bill of materials.
+type Options struct {
+	Format Format
+	// Created is the time the bill of materials is recorded as created at.
+	// It defaults to the current time.
+	Created time.Time
+}
+
+// component is an item of a bill of materials.
+type component struct {
+	kind    string // "chart", "image" or "file"
+	name    string
+	version string
+	sha256  string
+	parent  *component
+}
+
+func (c *component) ref() string {
+	switch c.kind {
+	case "file":
+		return "file:" + c.name
+	case "image":
+		return "image:" + c.name + ":" + c.version
+	}
+	return fmt.Sprintf("chart:%s@%s", c.name, c.version)
+}
+
+// Generate returns the bill of materials of ch.
+func Generate(ch *chart.Chart, opts Options) ([]byte, error) {
+	if ch == nil || ch.Metadata == nil {
+		return nil, errors.New("chart metadata is required to generate an SBOM")
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+	root, components := collect(ch)
+	switch opts.Format {
+	case SPDX, "":
+		return generateSPDX(root, components, opts.Created)
+	case CycloneDX:
+		return generateCycloneDX(root, components, opts.Created)
+	}
+	return nil, errors.Errorf("invalid SBOM format %q", opts.Format)
+}
+
+// collect returns the component of the chart and those it contains or
+// depends on, ordered by kind and name.
+func collect(ch *chart.Chart) (*component, []*component) {
+	root := &component{kind: "chart", name: ch.Name(), version: ch.Metadata.Version}
+	var components []*component
+	images := make(map[string]bool)
+
+	var walk func(ch *chart.Chart, c *component, dir string)
+	walk = func(ch *chart.Chart, c *component, dir string) {
+		for _, t := range ch.Templates {
+			sum := sha256.Sum256(t.Data)
+			components = append(components, &component{
+				kind:   "file",
+				name:   path.Join(dir, t.Name),
+				sha256: hex.EncodeToString(sum[:]),
+				parent: c,
+			})
+		}
+		for _, image := range Images(ch.Values) {
+			if images[image] {
+				continue
+			}
+			images[image] = true
+			name, tag := splitImage(image)
+			components = append(components, &component{kind: "image", name: name, version: tag, parent: root})
+		}
+		for _, dep := range ch.Dependencies() {
+			d := &component{kind: "chart", name: dep.Name(), version: dep.Metadata.Version, parent: c}
+			components = append(components, d)
+			walk(dep, d, path.Join(dir, "charts", dep.Name()))
+		}
+	}
+	walk(ch, root, "")
+
+	rank := map[string]int{"chart": 0, "image": 1, "file": 2}
+	sort.SliceStable(components, func(i, j int) bool {
+		a, b := components[i], components[j]
+		if a.kind != b.kind {
+			return rank[a.kind] < rank[b.kind]
+		}
+		return a.ref() < b.ref()
+	})
+	return root, components
+}
+
+// Images returns the container images referenced by values, sorted. Images
+// are recognized as maps with a "repository" key, optionally accompanied by
+// "registry", "tag" and "digest" keys, and as strings under an "image" key.
+func Images(values map[string]interface{}) []string {
+	found := make(map[string]bool)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if image := imageFromMap(v); image != "" {
+				found[image] = true
+			}
+			for k, e := range v {
+				if s, ok := e.(string); ok && k == "image" && s != "" {
+					found[s] = true
+				}
+				walk(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(values)
+
+	images := make([]string, 0, len(found))
+	for image := range found {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images
+}
+
+func imageFromMap(m map[string]interface{}) string {
+	repository, ok := m["repository"].(string)
+	if !ok || repository == "" {
+		return ""
+	}
+	image := repository
+	if registry, ok := m["registry"].(string); ok && registry != "" {
+		image = registry + "/" + repository
+	}
+	if tag := fmt.Sprint(m["tag"]); m["tag"] != nil && tag != "" {
+		image += ":" + tag
+	}
+	if digest, ok := m["digest"].(string); ok && digest != "" {
+		image += "@" + digest
+	}
+	return image
+}
+
+// splitImage splits an image reference into its name and its tag or digest.
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
+
+var invalidSPDXID = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func spdxID(c *component) string {
+	kind := strings.ToUpper(c.kind[:1]) + c.kind[1:]
+	return "SPDXRef-" + invalidSPDXID.ReplaceAllString(kind+"-"+strings.TrimPrefix(c.ref(), c.kind+":"), "-")
+}
+
+func generateSPDX(root *component, components []*component, created time.Time) ([]byte, error) {
+	type checksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type pkg struct {
+		Name                  string `json:"name"`
+		SPDXID                string `json:"SPDXID"`
+		VersionInfo           string `json:"versionInfo,omitempty"`
+		DownloadLocation      string `json:"downloadLocation"`
+		FilesAnalyzed         bool   `json:"filesAnalyzed"`
+		PrimaryPackagePurpose string `json:"primaryPackagePurpose"`
+	}
+	type file struct {
+		FileName  string     `json:"fileName"`
+		SPDXID    string     `json:"SPDXID"`
+		Checksums []checksum `json:"checksums"`
+	}
+	type relationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+
+	packages := []pkg{{
+		Name:                  root.name,
+		SPDXID:                spdxID(root),
+		VersionInfo:           root.version,
+		DownloadLocation:      "NOASSERTION",
+		PrimaryPackagePurpose: "APPLICATION",
+	}}
+	files := []file{}
+	relationships := []relationship{{"SPDXRef-DOCUMENT", "DESCRIBES", spdxID(root)}}
+	digest := sha256.New()
+	for _, c := range components {
+		fmt.Fprintln(digest, c.ref(), c.sha256)
+		switch c.kind {
+		case "file":
+			files = append(files, file{
+				FileName:  "./" + c.name,
+				SPDXID:    spdxID(c),
+				Checksums: []checksum{{"SHA256", c.sha256}},
+			})
+			relationships = append(relationships, relationship{spdxID(c.parent), "CONTAINS", spdxID(c)})
+		default:
+			purpose := "APPLICATION"
+			if c.kind == "image" {
+				purpose = "CONTAINER"
+			}
+			packages = append(packages, pkg{
+				Name:                  c.name,
+				SPDXID:                spdxID(c),
+				VersionInfo:           c.version,
+				DownloadLocation:      "NOASSERTION",
+				PrimaryPackagePurpose: purpose,
+			})
+			relationships = append(relationships, relationship{spdxID(c.parent), "DEPENDS_ON", spdxID(c)})
+		}
+	}
+
+	name := root.name + "-" + root.version
+	doc := map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": fmt.Sprintf("https://helm.sh/spdxdocs/%s-%x", name, digest.Sum(nil)),
+		"creationInfo": map[string]interface{}{
+			"created":  created.UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: helm-" + version.GetVersion()},
+		},
+		"packages":      packages,
+		"files":         files,
+		"relationships": relationships,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func generateCycloneDX(root *component, components []*component, created time.Time) ([]byte, error) {
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type cdxComponent struct {
+		Type    string `json:"type"`
+		BOMRef  string `json:"bom-ref"`
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+		Hashes  []hash `json:"hashes,omitempty"`
+	}
+	type dependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	}
+
+	toCDX := func(c *component) cdxComponent {
+		cc := cdxComponent{BOMRef: c.ref(), Name: c.name, Version: c.version}
+		switch c.kind {
+		case "file":
+			cc.Type = "file"
+			cc.Hashes = []hash{{"SHA-256", c.sha256}}
+		case "image":
+			cc.Type = "container"
+		default:
+			cc.Type = "application"
+		}
+		return cc
+	}
+
+	cdxComponents := []cdxComponent{}
+	dependsOn := map[string][]string{root.ref(): {}}
+	order := []string{root.ref()}
+	for _, c := range components {
+		cdxComponents = append(cdxComponents, toCDX(c))
+		if c.kind == "file" {
+			continue
+		}
+		parent := c.parent.ref()
+		dependsOn[parent] = append(dependsOn[parent], c.ref())
+		if c.kind == "chart" {
+			dependsOn[c.ref()] = []string{}
+			order = append(order, c.ref())
+		}
+	}
+	dependencies := make([]dependency, 0, len(order))
+	for _, ref := range order {
+		dependencies = append(dependencies, dependency{Ref: ref, DependsOn: dependsOn[ref]})
+	}
+
+	doc := map[string]interface{}{
+		"bomFormat":   "CycloneDX",
+		"specVersion": "1.4",
+		"version":     1,
+		"metadata": map[string]interface{}{
+			"timestamp": created.UTC().Format(time.RFC3339),
+			"tools": []map[string]string{{
+				"vendor":  "Helm",
+				"name":    "helm",
+				"version": version.GetVersion(),
+			}},
+			"component": toCDX(root),
+		},
+		"components":   cdxComponents,
+		"dependencies": dependencies,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
@@ -0,0 +1,187 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/open-hand/helm/pkg/chart"
+)
+
+func testChart() *chart.Chart {
+	sub := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "redis", Version: "1.2.3"},
+		Templates: []*chart.File{{Name: "templates/statefulset.yaml", Data: []byte("kind: StatefulSet")}},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{"registry": "docker.io", "repository": "bitnami/redis", "tag": "6.2"},
+		},
+	}
+	ch := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "hello", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment")}},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{"repository": "nginx", "tag": 1.21},
+			"sidecars": []interface{}{
+				map[string]interface{}{"name": "proxy", "image": "envoyproxy/envoy@sha256:abcd"},
+			},
+		},
+	}
+	ch.AddDependency(sub)
+	return ch
+}
+
+func TestImages(t *testing.T) {
+	got := Images(testChart().Values)
+	want := []string{"envoyproxy/envoy@sha256:abcd", "nginx:1.21"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected images %v, got %v", want, got)
+	}
+}
+
+func TestGenerateSPDX(t *testing.T) {
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := Generate(testChart(), Options{Format: SPDX, Created: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Generate(testChart(), Options{Format: SPDX, Created: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Error("expected the SBOM of the same chart to be identical")
+	}
+
+	var doc struct {
+		SPDXVersion  string `json:"spdxVersion"`
+		CreationInfo struct {
+			Created string `json:"created"`
+		} `json:"creationInfo"`
+		Packages []struct {
+			Name        string `json:"name"`
+			SPDXID      string `json:"SPDXID"`
+			VersionInfo string `json:"versionInfo"`
+		} `json:"packages"`
+		Files []struct {
+			FileName string `json:"fileName"`
+		} `json:"files"`
+		Relationships []struct {
+			SPDXElementID      string `json:"spdxElementId"`
+			RelationshipType   string `json:"relationshipType"`
+			RelatedSPDXElement string `json:"relatedSpdxElement"`
+		} `json:"relationships"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.CreationInfo.Created != "2022-01-01T00:00:00Z" {
+		t.Errorf("unexpected document header %s created %s", doc.SPDXVersion, doc.CreationInfo.Created)
+	}
+
+	var packages []string
+	for _, p := range doc.Packages {
+		packages = append(packages, p.Name+"@"+p.VersionInfo)
+	}
+	want := []string{"hello@0.1.0", "redis@1.2.3", "docker.io/bitnami/redis@6.2", "envoyproxy/envoy@sha256:abcd", "nginx@1.21"}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("expected packages %v, got %v", want, packages)
+	}
+
+	var files []string
+	for _, f := range doc.Files {
+		files = append(files, f.FileName)
+	}
+	wantFiles := []string{"./charts/redis/templates/statefulset.yaml", "./templates/deployment.yaml"}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("expected files %v, got %v", wantFiles, files)
+	}
+
+	found := false
+	for _, r := range doc.Relationships {
+		if r.SPDXElementID == "SPDXRef-Chart-redis-1.2.3" && r.RelationshipType == "CONTAINS" && r.RelatedSPDXElement == "SPDXRef-File-charts-redis-templates-statefulset.yaml" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the subchart to contain its templates, got %v", doc.Relationships)
+	}
+}
+
+func TestGenerateCycloneDX(t *testing.T) {
+	data, err := Generate(testChart(), Options{Format: CycloneDX})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		BOMFormat string `json:"bomFormat"`
+		Metadata  struct {
+			Component struct {
+				Name string `json:"name"`
+			} `json:"component"`
+		} `json:"metadata"`
+		Components []struct {
+			Type string `json:"type"`
+			Name string `json:"name"`
+		} `json:"components"`
+		Dependencies []struct {
+			Ref       string   `json:"ref"`
+			DependsOn []string `json:"dependsOn"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.Metadata.Component.Name != "hello" {
+		t.Errorf("unexpected document %s of %s", doc.BOMFormat, doc.Metadata.Component.Name)
+	}
+	types := map[string]int{}
+	for _, c := range doc.Components {
+		types[c.Type]++
+	}
+	if !reflect.DeepEqual(types, map[string]int{"application": 1, "container": 3, "file": 2}) {
+		t.Errorf("unexpected component types %v", types)
+	}
+	if len(doc.Dependencies) != 2 || doc.Dependencies[0].Ref != "chart:hello@0.1.0" || len(doc.Dependencies[0].DependsOn) != 4 {
+		t.Errorf("unexpected dependencies %+v", doc.Dependencies)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("cyclonedx"); err != nil || f.MediaType() != CycloneDXMediaType {
+		t.Errorf("unexpected format %q: %v", f, err)
+	}
+	if _, err := ParseFormat("swid"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+
+	for _, f := range []Format{SPDX, CycloneDX} {
+		data, err := Generate(testChart(), Options{Format: f})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Detect(data); err != nil || got != f {
+			t.Errorf("expected to detect %q, got %q: %v", f, got, err)
+		}
+	}
+	if _, err := Detect([]byte(`{}`)); err == nil {
+		t.Error("expected a document of unknown format to be rejected")
+	}
+}
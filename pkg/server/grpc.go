@@ -0,0 +1,162 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+const (
+	// ServiceName is the name of the gRPC service. Its methods are Install,
+	// Upgrade, Uninstall, Rollback, Status and List, which take the request
+	// types of the package.
+	ServiceName = "helm.v1.Releases"
+	// CodecName is the name of the codec of the messages of the gRPC
+	// service, which are encoded as JSON. Clients select it with
+	// grpc.CallContentSubtype(CodecName).
+	CodecName = "json"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes the gRPC messages as JSON, the same payloads as the REST
+// API.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string { return CodecName }
+
+// RegisterGRPC registers the gRPC service of the server. The calls are
+// authenticated by the bearer token of their authorization metadata.
+func (s *Server) RegisterGRPC(g *grpc.Server) {
+	g.RegisterService(&serviceDesc, s)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Install", func() interface{} { return &InstallRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Install(ctx, req.(*InstallRequest))
+			}),
+		unaryMethod("Upgrade", func() interface{} { return &UpgradeRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Upgrade(ctx, req.(*UpgradeRequest))
+			}),
+		unaryMethod("Uninstall", func() interface{} { return &UninstallRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Uninstall(ctx, req.(*UninstallRequest))
+			}),
+		unaryMethod("Rollback", func() interface{} { return &RollbackRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Rollback(ctx, req.(*RollbackRequest))
+			}),
+		unaryMethod("Status", func() interface{} { return &StatusRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.Status(ctx, req.(*StatusRequest))
+			}),
+		unaryMethod("List", func() interface{} { return &ListRequest{} },
+			func(s *Server, ctx context.Context, req interface{}) (interface{}, error) {
+				return s.List(ctx, req.(*ListRequest))
+			}),
+	},
+}
+
+// unaryMethod returns the description of a method decoding its request with
+// newRequest and calling call once authenticated.
+func unaryMethod(name string, newRequest func() interface{}, call func(s *Server, ctx context.Context, req interface{}) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			s := srv.(*Server)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if err := s.authenticate(ctx, grpcToken(ctx)); err != nil {
+					return nil, grpcError(err)
+				}
+				resp, err := call(s, ctx, req)
+				if err != nil {
+					return nil, grpcError(err)
+				}
+				return resp, nil
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// grpcToken returns the bearer token of the authorization metadata of a
+// call.
+func grpcToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, v := range md.Get("authorization") {
+		if token := bearerToken(v); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// grpcError returns the status of a failed call.
+func grpcError(err error) error {
+	var invalid *invalidError
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		code = codes.Unauthenticated
+	case errors.Is(err, driver.ErrReleaseNotFound):
+		code = codes.NotFound
+	case errors.Is(err, driver.ErrReleaseExists):
+		code = codes.AlreadyExists
+	case action.IsReadOnly(err), errors.Is(err, ErrChartSourceNotAllowed):
+		code = codes.PermissionDenied
+	case errors.As(err, &invalid):
+		code = codes.InvalidArgument
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
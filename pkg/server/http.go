@@ -0,0 +1,256 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/cli/output"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// maxRequestBody is the maximum size of the body of a REST request.
+const maxRequestBody = 1 << 20
+
+// Handler returns the handler of the REST API:
+//
+//	GET    /healthz                                              (not authenticated)
+//	GET    /v1/releases                                          list the releases of all namespaces
+//	GET    /v1/namespaces/{namespace}/releases                   list
+//	POST   /v1/namespaces/{namespace}/releases                   install an InstallRequest
+//	GET    /v1/namespaces/{namespace}/releases/{name}            status
+//	PUT    /v1/namespaces/{namespace}/releases/{name}            upgrade with an UpgradeRequest
+//	DELETE /v1/namespaces/{namespace}/releases/{name}            uninstall
+//	POST   /v1/namespaces/{namespace}/releases/{name}/rollback   roll back with a RollbackRequest
+//
+// The namespace and name of the path take precedence over the ones of the
+// body. The list accepts the all, filter, selector, limit and offset query
+// parameters, the status the revision one, and the uninstall the keepHistory,
+// dryRun, wait, timeout and description ones. The requests are authenticated
+// by the bearer token of their Authorization header. Errors are returned as
+// {"error": "message"}.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serveHTTP)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok\n")
+		return
+	}
+	if err := s.authenticate(r.Context(), bearerToken(r.Header.Get("Authorization"))); err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeHTTPError(w, err)
+		return
+	}
+
+	var namespace, name, sub string
+	all := false
+	switch parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/"); {
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "releases":
+		all = true
+	case len(parts) >= 4 && len(parts) <= 6 && parts[0] == "v1" && parts[1] == "namespaces" && parts[3] == "releases":
+		namespace = parts[2]
+		if len(parts) > 4 {
+			name = parts[4]
+		}
+		if len(parts) > 5 {
+			sub = parts[5]
+		}
+	default:
+		writeHTTPError(w, errNotFound)
+		return
+	}
+
+	ctx := r.Context()
+	var resp interface{}
+	var err error
+	status := http.StatusOK
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		req := &ListRequest{Namespace: namespace, AllNamespaces: all}
+		if err = listQuery(r, req); err == nil {
+			resp, err = s.List(ctx, req)
+		}
+	case name == "" && r.Method == http.MethodPost && !all:
+		req := &InstallRequest{}
+		if err = decodeBody(r, req); err == nil {
+			req.Namespace = namespace
+			resp, err = s.Install(ctx, req)
+			status = http.StatusCreated
+		}
+	case name != "" && sub == "" && r.Method == http.MethodGet:
+		req := &StatusRequest{Namespace: namespace, Name: name}
+		if req.Revision, err = intQuery(r, "revision"); err == nil {
+			resp, err = s.Status(ctx, req)
+		}
+	case name != "" && sub == "" && r.Method == http.MethodPut:
+		req := &UpgradeRequest{}
+		if err = decodeBody(r, req); err == nil {
+			req.Namespace, req.Name = namespace, name
+			resp, err = s.Upgrade(ctx, req)
+		}
+	case name != "" && sub == "" && r.Method == http.MethodDelete:
+		req := &UninstallRequest{Namespace: namespace, Name: name}
+		if err = uninstallQuery(r, req); err == nil {
+			resp, err = s.Uninstall(ctx, req)
+		}
+	case name != "" && sub == "rollback" && r.Method == http.MethodPost:
+		req := &RollbackRequest{}
+		if err = decodeBody(r, req); err == nil {
+			req.Namespace, req.Name = namespace, name
+			resp, err = s.Rollback(ctx, req)
+		}
+	case sub != "" && sub != "rollback":
+		err = errNotFound
+	default:
+		err = errMethodNotAllowed
+	}
+	if err != nil {
+		writeHTTPError(w, err)
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, status, resp)
+}
+
+var (
+	errNotFound         = errors.New("not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+)
+
+// bearerToken returns the token of a bearer Authorization header.
+func bearerToken(header string) string {
+	const prefix = "bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(io.LimitReader(r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && err != io.EOF {
+		return invalidf("invalid request body: %s", err)
+	}
+	return nil
+}
+
+func listQuery(r *http.Request, req *ListRequest) error {
+	q := r.URL.Query()
+	req.Filter = q.Get("filter")
+	req.Selector = q.Get("selector")
+	var err error
+	if req.All, err = boolQuery(r, "all"); err != nil {
+		return err
+	}
+	if req.Limit, err = intQuery(r, "limit"); err != nil {
+		return err
+	}
+	req.Offset, err = intQuery(r, "offset")
+	return err
+}
+
+func uninstallQuery(r *http.Request, req *UninstallRequest) error {
+	var err error
+	if req.KeepHistory, err = boolQuery(r, "keepHistory"); err != nil {
+		return err
+	}
+	if req.DryRun, err = boolQuery(r, "dryRun"); err != nil {
+		return err
+	}
+	if req.Wait, err = boolQuery(r, "wait"); err != nil {
+		return err
+	}
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return invalidf("invalid timeout %q", v)
+		}
+		req.Timeout = metav1.Duration{Duration: d}
+	}
+	req.Description = r.URL.Query().Get("description")
+	return nil
+}
+
+func boolQuery(r *http.Request, key string) (bool, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, invalidf("invalid %s %q", key, v)
+	}
+	return b, nil
+}
+
+func intQuery(r *http.Request, key string) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, invalidf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
+
+// httpStatus returns the status of the response to a failed request.
+func httpStatus(err error) int {
+	var invalid *invalidError
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errNotFound), errors.Is(err, driver.ErrReleaseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errMethodNotAllowed):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, driver.ErrReleaseExists):
+		return http.StatusConflict
+	case action.IsReadOnly(err), errors.Is(err, ErrChartSourceNotAllowed):
+		return http.StatusForbidden
+	case errors.As(err, &invalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func writeHTTPError(w http.ResponseWriter, err error) {
+	writeJSON(w, httpStatus(err), output.Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,503 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package server exposes the release actions over an authenticated API.
+
+The same operations are served over REST, by Handler, and over gRPC, by
+RegisterGRPC. Both take the request types of this package and return the
+releases of package release, encoded as JSON. It is what 'helm serve' runs,
+and it can be embedded in services deploying releases on behalf of their
+users.
+*/
+package server // import "github.com/open-hand/helm/pkg/server"
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart"
+	"github.com/open-hand/helm/pkg/cli"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// defaultTimeout is the timeout of the operations of the requests that do
+// not set one, the default of the CLI.
+const defaultTimeout = 300 * time.Second
+
+// ConfigFunc returns the action configuration of the operations on the
+// releases of a namespace, closed once they are done.
+type ConfigFunc func(namespace string) (*action.Configuration, error)
+
+// Authenticator authenticates the bearer token of a request.
+type Authenticator interface {
+	// Authenticate returns an error if the token is not allowed to call
+	// the server.
+	Authenticate(ctx context.Context, token string) error
+}
+
+// ErrUnauthenticated is returned for the requests without a valid token.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Tokens returns an Authenticator allowing the requests with one of the
+// tokens.
+func Tokens(tokens ...string) Authenticator {
+	return staticTokens(tokens)
+}
+
+type staticTokens []string
+
+func (t staticTokens) Authenticate(_ context.Context, token string) error {
+	if token == "" {
+		return ErrUnauthenticated
+	}
+	for _, allowed := range t {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(allowed)) == 1 {
+			return nil
+		}
+	}
+	return ErrUnauthenticated
+}
+
+// ErrChartSourceNotAllowed is returned for the requests installing a chart
+// from a path of the server or from a repository it does not allow.
+var ErrChartSourceNotAllowed = errors.New("chart source not allowed")
+
+// Server runs the release actions of the requests.
+type Server struct {
+	settings     *cli.EnvSettings
+	config       ConfigFunc
+	auth         Authenticator
+	allowLocal   bool
+	repositories []*url.URL
+}
+
+// Option configures a Server.
+type Option func(*Server) error
+
+// AllowLocalCharts lets the requests install the charts at paths of the
+// filesystem of the server, which are refused by default.
+func AllowLocalCharts() Option {
+	return func(s *Server) error {
+		s.allowLocal = true
+		return nil
+	}
+}
+
+// AllowRepositories lets the requests download the charts from the
+// repositories and registries under the URLs, such as
+// "https://charts.example.com" or "oci://registry.example.com/team". Without
+// them, the requests can only name the charts of the repositories configured
+// on the server, as "repo/chart".
+func AllowRepositories(urls ...string) Option {
+	return func(s *Server) error {
+		for _, raw := range urls {
+			u, err := url.Parse(raw)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return errors.Errorf("invalid repository URL %q", raw)
+			}
+			s.repositories = append(s.repositories, u)
+		}
+		return nil
+	}
+}
+
+// New returns a server running the actions with the configurations of
+// config and locating the charts with settings. The requests are
+// authenticated by auth, and all rejected if it is nil.
+func New(settings *cli.EnvSettings, config ConfigFunc, auth Authenticator, opts ...Option) (*Server, error) {
+	s := &Server{settings: settings, config: config, auth: auth}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// authenticate authenticates the bearer token of a request.
+func (s *Server) authenticate(ctx context.Context, token string) error {
+	if s.auth == nil {
+		return ErrUnauthenticated
+	}
+	if err := s.auth.Authenticate(ctx, token); err != nil {
+		return errors.Wrap(ErrUnauthenticated, err.Error())
+	}
+	return nil
+}
+
+// invalidError is an error in the parameters of a request.
+type invalidError struct {
+	msg string
+}
+
+func (e *invalidError) Error() string { return e.msg }
+
+func invalidf(format string, args ...interface{}) error {
+	return &invalidError{msg: errors.Errorf(format, args...).Error()}
+}
+
+// InstallRequest installs a chart.
+type InstallRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Chart is the name of the chart, downloaded with its Version from the
+	// repository at RepoURL, as by 'helm install --repo'.
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	RepoURL string `json:"repoURL,omitempty"`
+	// Values are the values overriding the ones of the chart.
+	Values          map[string]interface{} `json:"values,omitempty"`
+	CreateNamespace bool                   `json:"createNamespace,omitempty"`
+	DryRun          bool                   `json:"dryRun,omitempty"`
+	Wait            bool                   `json:"wait,omitempty"`
+	Atomic          bool                   `json:"atomic,omitempty"`
+	// Timeout defaults to 5 minutes.
+	Timeout     metav1.Duration `json:"timeout,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// UpgradeRequest upgrades a release to a chart.
+type UpgradeRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Chart is the name of the chart, downloaded with its Version from the
+	// repository at RepoURL, as by 'helm upgrade --repo'.
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	RepoURL string `json:"repoURL,omitempty"`
+	// Values are the values overriding the ones of the chart.
+	Values      map[string]interface{} `json:"values,omitempty"`
+	ReuseValues bool                   `json:"reuseValues,omitempty"`
+	ResetValues bool                   `json:"resetValues,omitempty"`
+	// Install installs the release if it does not exist.
+	Install    bool `json:"install,omitempty"`
+	DryRun     bool `json:"dryRun,omitempty"`
+	Wait       bool `json:"wait,omitempty"`
+	Atomic     bool `json:"atomic,omitempty"`
+	Force      bool `json:"force,omitempty"`
+	MaxHistory int  `json:"maxHistory,omitempty"`
+	// Timeout defaults to 5 minutes.
+	Timeout     metav1.Duration `json:"timeout,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// UninstallRequest uninstalls a release.
+type UninstallRequest struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	KeepHistory bool   `json:"keepHistory,omitempty"`
+	DryRun      bool   `json:"dryRun,omitempty"`
+	Wait        bool   `json:"wait,omitempty"`
+	// Timeout defaults to 5 minutes.
+	Timeout     metav1.Duration `json:"timeout,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// RollbackRequest rolls a release back to a revision.
+type RollbackRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Revision is the revision to roll back to, the previous one if 0.
+	Revision      int  `json:"revision,omitempty"`
+	DryRun        bool `json:"dryRun,omitempty"`
+	Wait          bool `json:"wait,omitempty"`
+	Force         bool `json:"force,omitempty"`
+	Recreate      bool `json:"recreate,omitempty"`
+	CleanupOnFail bool `json:"cleanupOnFail,omitempty"`
+	// Timeout defaults to 5 minutes.
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+}
+
+// StatusRequest gets a release.
+type StatusRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Revision is the revision to get, the last one if 0.
+	Revision int `json:"revision,omitempty"`
+}
+
+// ListRequest lists releases.
+type ListRequest struct {
+	// Namespace is the namespace of the releases, ignored if AllNamespaces
+	// is set.
+	Namespace     string `json:"namespace"`
+	AllNamespaces bool   `json:"allNamespaces,omitempty"`
+	// All lists the releases in all states instead of the deployed and
+	// failed ones.
+	All bool `json:"all,omitempty"`
+	// Filter is a regular expression the names of the releases must match.
+	Filter string `json:"filter,omitempty"`
+	// Selector is a label selector the releases must match.
+	Selector string `json:"selector,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	Offset   int    `json:"offset,omitempty"`
+}
+
+// ListResponse is the result of a ListRequest.
+type ListResponse struct {
+	Releases []*release.Release `json:"releases"`
+}
+
+// Install installs the chart of the request.
+func (s *Server) Install(ctx context.Context, req *InstallRequest) (*release.Release, error) {
+	if req.Name == "" || req.Chart == "" {
+		return nil, invalidf("name and chart are required")
+	}
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	client := action.NewInstall(cfg, action.ChartPathOptions{Version: req.Version, RepoURL: req.RepoURL},
+		"", 0, "", nil, req.Namespace, req.Name, "", "", 0, "", "", "", false)
+	client.CreateNamespace = req.CreateNamespace
+	client.DryRun = req.DryRun
+	client.Wait = req.Wait
+	client.Atomic = req.Atomic
+	client.Timeout = timeout(req.Timeout)
+	client.Description = req.Description
+
+	chrt, err := s.loadChart(&client.ChartPathOptions, req.Chart)
+	if err != nil {
+		return nil, err
+	}
+	return client.RunWithContext(ctx, chrt, values(req.Values), "")
+}
+
+// Upgrade upgrades the release of the request, or installs it if it does not
+// exist and the request allows it.
+func (s *Server) Upgrade(ctx context.Context, req *UpgradeRequest) (*release.Release, error) {
+	if req.Name == "" || req.Chart == "" {
+		return nil, invalidf("name and chart are required")
+	}
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	if req.Install {
+		history := action.NewHistory(cfg)
+		history.Max = 1
+		if _, err := history.Run(req.Name); errors.Is(err, driver.ErrReleaseNotFound) {
+			return s.Install(ctx, &InstallRequest{
+				Namespace:   req.Namespace,
+				Name:        req.Name,
+				Chart:       req.Chart,
+				Version:     req.Version,
+				RepoURL:     req.RepoURL,
+				Values:      req.Values,
+				DryRun:      req.DryRun,
+				Wait:        req.Wait,
+				Atomic:      req.Atomic,
+				Timeout:     req.Timeout,
+				Description: req.Description,
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	client := action.NewUpgrade(cfg, action.ChartPathOptions{Version: req.Version, RepoURL: req.RepoURL},
+		"", 0, "", nil, req.Name, "", "", 0, "", "", req.ReuseValues, "")
+	client.Namespace = req.Namespace
+	client.ResetValues = req.ResetValues
+	client.DryRun = req.DryRun
+	client.Wait = req.Wait
+	client.Atomic = req.Atomic
+	client.Force = req.Force
+	client.MaxHistory = req.MaxHistory
+	client.Timeout = timeout(req.Timeout)
+	client.Description = req.Description
+
+	chrt, err := s.loadChart(&client.ChartPathOptions, req.Chart)
+	if err != nil {
+		return nil, err
+	}
+	return client.RunWithContext(ctx, req.Name, chrt, values(req.Values), "")
+}
+
+// Uninstall uninstalls the release of the request.
+func (s *Server) Uninstall(_ context.Context, req *UninstallRequest) (*release.UninstallReleaseResponse, error) {
+	if req.Name == "" {
+		return nil, invalidf("name is required")
+	}
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	client := action.NewUninstall(cfg)
+	client.KeepHistory = req.KeepHistory
+	client.DryRun = req.DryRun
+	client.Wait = req.Wait
+	client.Timeout = timeout(req.Timeout)
+	client.Description = req.Description
+	return client.Run(req.Name)
+}
+
+// Rollback rolls the release of the request back and returns the release
+// of the rollback.
+func (s *Server) Rollback(_ context.Context, req *RollbackRequest) (*release.Release, error) {
+	if req.Name == "" {
+		return nil, invalidf("name is required")
+	}
+	if req.Revision < 0 {
+		return nil, invalidf("invalid revision %d", req.Revision)
+	}
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	client := action.NewRollback(cfg)
+	client.Version = req.Revision
+	client.DryRun = req.DryRun
+	client.Wait = req.Wait
+	client.Force = req.Force
+	client.Recreate = req.Recreate
+	client.CleanupOnFail = req.CleanupOnFail
+	client.Timeout = timeout(req.Timeout)
+	if err := client.Run(req.Name); err != nil {
+		return nil, err
+	}
+	if req.DryRun {
+		return nil, nil
+	}
+	return cfg.Releases.Last(req.Name)
+}
+
+// Status returns the release of the request.
+func (s *Server) Status(_ context.Context, req *StatusRequest) (*release.Release, error) {
+	if req.Name == "" {
+		return nil, invalidf("name is required")
+	}
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	client := action.NewStatus(cfg)
+	client.Version = req.Revision
+	return client.Run(req.Name)
+}
+
+// List returns the releases matching the request.
+func (s *Server) List(_ context.Context, req *ListRequest) (*ListResponse, error) {
+	cfg, err := s.config(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer cfg.Close()
+	client := action.NewList(cfg)
+	client.AllNamespaces = req.AllNamespaces
+	client.Filter = req.Filter
+	client.Selector = req.Selector
+	client.Limit = req.Limit
+	client.Offset = req.Offset
+	if req.All {
+		client.StateMask = action.ListAll
+	}
+	rels, err := client.Run()
+	if err != nil {
+		return nil, err
+	}
+	if rels == nil {
+		rels = []*release.Release{}
+	}
+	return &ListResponse{Releases: rels}, nil
+}
+
+// checkChartSource checks that the chart is not a path of the server, unless
+// local charts are allowed, and that it is downloaded from an allowed
+// repository if it is not one of the configured repositories.
+func (s *Server) checkChartSource(opts *action.ChartPathOptions, name string) error {
+	source := opts.RepoURL
+	if source == "" && strings.Contains(name, "://") {
+		source = name
+	}
+	if source != "" {
+		u, err := url.Parse(source)
+		if err != nil {
+			return invalidf("invalid chart source %q", source)
+		}
+		for _, allowed := range s.repositories {
+			if underURL(u, allowed) {
+				return nil
+			}
+		}
+		return errors.Wrapf(ErrChartSourceNotAllowed, "%s is not an allowed repository", u.Redacted())
+	}
+	if s.allowLocal {
+		return nil
+	}
+	if _, err := os.Stat(name); err == nil || strings.HasPrefix(name, "/") || strings.HasPrefix(name, ".") {
+		return errors.Wrapf(ErrChartSourceNotAllowed, "%s is a local chart", name)
+	}
+	return nil
+}
+
+// underURL reports whether u is allowed or under its path.
+func underURL(u, allowed *url.URL) bool {
+	if !strings.EqualFold(u.Scheme, allowed.Scheme) || !strings.EqualFold(u.Host, allowed.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(allowed.Path, "/")
+	p := path.Clean("/" + u.Path)
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// loadChart locates and loads the chart, which must be installable and have
+// its dependencies.
+func (s *Server) loadChart(opts *action.ChartPathOptions, name string) (*chart.Chart, error) {
+	if err := s.checkChartSource(opts, name); err != nil {
+		return nil, err
+	}
+	chrt, err := opts.LoadChart(name, s.settings)
+	if err != nil {
+		return nil, err
+	}
+	switch chrt.Metadata.Type {
+	case "", "application":
+	default:
+		return nil, invalidf("%s charts are not installable", chrt.Metadata.Type)
+	}
+	return chrt, nil
+}
+
+func timeout(d metav1.Duration) time.Duration {
+	if d.Duration <= 0 {
+		return defaultTimeout
+	}
+	return d.Duration
+}
+
+func values(vals map[string]interface{}) map[string]interface{} {
+	if vals == nil {
+		return map[string]interface{}{}
+	}
+	return vals
+}
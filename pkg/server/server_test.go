@@ -0,0 +1,368 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/open-hand/helm/pkg/action"
+	"github.com/open-hand/helm/pkg/chart/loader"
+	"github.com/open-hand/helm/pkg/chartutil"
+	"github.com/open-hand/helm/pkg/cli"
+	kubefake "github.com/open-hand/helm/pkg/kube/fake"
+	"github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+const testToken = "secret-token"
+
+// newTestServer returns a server and the URL of a repository serving the
+// charts of testdata.
+func newTestServer(t *testing.T, readOnly bool) (*Server, string) {
+	t.Helper()
+	charts := filepath.Join(t.TempDir(), "charts")
+	if err := os.Mkdir(charts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chrt, err := loader.Load("testdata/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chartutil.Save(chrt, charts); err != nil {
+		t.Fatal(err)
+	}
+	repo := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(charts))))
+	t.Cleanup(repo.Close)
+
+	settings := cli.New()
+	settings.RepositoryCache = t.TempDir()
+	settings.RepositoryConfig = filepath.Join(t.TempDir(), "repositories.yaml")
+
+	store := storage.Init(driver.NewMemory())
+	config := func(namespace string) (*action.Configuration, error) {
+		store.Driver.(*driver.Memory).SetNamespace(namespace)
+		return &action.Configuration{
+			Releases:     store,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...interface{}) {},
+			ReadOnly:     readOnly,
+		}, nil
+	}
+	s, err := New(settings, config, Tokens(testToken), AllowRepositories(repo.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, repo.URL + "/"
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, token, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	s, repoURL := newTestServer(t, false)
+	h := s.Handler()
+	chart := `"chart": "hello", "version": "0.1.0", "repoURL": "` + repoURL + `"`
+	releases := "/v1/namespaces/apps/releases"
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		check  func(t *testing.T, body string)
+	}{{
+		name:   "health check without token",
+		method: http.MethodGet,
+		path:   "/healthz",
+		status: http.StatusOK,
+	}, {
+		name:   "missing token",
+		method: http.MethodGet,
+		path:   releases,
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "invalid token",
+		method: http.MethodGet,
+		path:   releases,
+		token:  "wrong",
+		status: http.StatusUnauthorized,
+	}, {
+		name:   "unknown release",
+		method: http.MethodGet,
+		path:   releases + "/hello",
+		token:  testToken,
+		status: http.StatusNotFound,
+	}, {
+		name:   "install",
+		method: http.MethodPost,
+		path:   releases,
+		token:  testToken,
+		body:   `{"name": "hello", ` + chart + `, "values": {"greeting": "hi"}}`,
+		status: http.StatusCreated,
+		check: func(t *testing.T, body string) {
+			rel := decodeRelease(t, body)
+			if rel.Namespace != "apps" || rel.Version != 1 || rel.Info.Status != release.StatusDeployed {
+				t.Errorf("unexpected release %s/%s revision %d %s", rel.Namespace, rel.Name, rel.Version, rel.Info.Status)
+			}
+			if !strings.Contains(rel.Manifest, `greeting: "hi"`) {
+				t.Errorf("values not rendered in manifest:\n%s", rel.Manifest)
+			}
+		},
+	}, {
+		name:   "install of an existing release",
+		method: http.MethodPost,
+		path:   releases,
+		token:  testToken,
+		body:   `{"name": "hello", ` + chart + `}`,
+		status: http.StatusInternalServerError,
+	}, {
+		name:   "invalid body",
+		method: http.MethodPost,
+		path:   releases,
+		token:  testToken,
+		body:   `{"name": "hello", "charts": "hello"}`,
+		status: http.StatusBadRequest,
+	}, {
+		name:   "upgrade",
+		method: http.MethodPut,
+		path:   releases + "/hello",
+		token:  testToken,
+		body:   `{` + chart + `}`,
+		status: http.StatusOK,
+		check: func(t *testing.T, body string) {
+			if rel := decodeRelease(t, body); rel.Version != 2 {
+				t.Errorf("expected revision 2, got %d", rel.Version)
+			}
+		},
+	}, {
+		name:   "rollback",
+		method: http.MethodPost,
+		path:   releases + "/hello/rollback",
+		token:  testToken,
+		body:   `{"revision": 1}`,
+		status: http.StatusOK,
+		check: func(t *testing.T, body string) {
+			if rel := decodeRelease(t, body); rel.Version != 3 {
+				t.Errorf("expected revision 3, got %d", rel.Version)
+			}
+		},
+	}, {
+		name:   "status of a revision",
+		method: http.MethodGet,
+		path:   releases + "/hello?revision=2",
+		token:  testToken,
+		status: http.StatusOK,
+		check: func(t *testing.T, body string) {
+			if rel := decodeRelease(t, body); rel.Version != 2 || rel.Info.Status != release.StatusSuperseded {
+				t.Errorf("unexpected revision %d %s", rel.Version, rel.Info.Status)
+			}
+		},
+	}, {
+		name:   "list",
+		method: http.MethodGet,
+		path:   releases,
+		token:  testToken,
+		status: http.StatusOK,
+		check: func(t *testing.T, body string) {
+			var resp ListResponse
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Releases) != 1 || resp.Releases[0].Version != 3 {
+				t.Errorf("unexpected releases %s", body)
+			}
+		},
+	}, {
+		name:   "unsupported method",
+		method: http.MethodPatch,
+		path:   releases + "/hello",
+		token:  testToken,
+		status: http.StatusMethodNotAllowed,
+	}, {
+		name:   "uninstall",
+		method: http.MethodDelete,
+		path:   releases + "/hello",
+		token:  testToken,
+		status: http.StatusOK,
+		check: func(t *testing.T, body string) {
+			if !strings.Contains(body, `"status":"uninstalled"`) {
+				t.Errorf("unexpected response %s", body)
+			}
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := doRequest(t, h, tt.method, tt.path, tt.token, tt.body)
+			if code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, code, body)
+			}
+			if tt.check != nil {
+				tt.check(t, body)
+			}
+		})
+	}
+}
+
+func TestHandlerReadOnly(t *testing.T) {
+	s, repoURL := newTestServer(t, true)
+	h := s.Handler()
+	releases := "/v1/namespaces/apps/releases"
+
+	body := `{"name": "hello", "chart": "hello", "version": "0.1.0", "repoURL": "` + repoURL + `"}`
+	if code, resp := doRequest(t, h, http.MethodPost, releases, testToken, body); code != http.StatusForbidden {
+		t.Errorf("expected status %d for an install, got %d: %s", http.StatusForbidden, code, resp)
+	}
+	if code, resp := doRequest(t, h, http.MethodGet, releases, testToken, ""); code != http.StatusOK {
+		t.Errorf("expected status %d for a list, got %d: %s", http.StatusOK, code, resp)
+	}
+}
+
+func TestHandlerChartSources(t *testing.T) {
+	s, _ := newTestServer(t, false)
+	h := s.Handler()
+	releases := "/v1/namespaces/apps/releases"
+
+	for _, body := range []string{
+		`{"name": "hello", "chart": "testdata/hello"}`,
+		`{"name": "hello", "chart": "/etc/hello"}`,
+		`{"name": "hello", "chart": "hello", "repoURL": "http://169.254.169.254/"}`,
+		`{"name": "hello", "chart": "oci://registry.example.com/hello"}`,
+	} {
+		if code, resp := doRequest(t, h, http.MethodPost, releases, testToken, body); code != http.StatusForbidden {
+			t.Errorf("%s: expected status %d, got %d: %s", body, http.StatusForbidden, code, resp)
+		}
+	}
+
+	s, err := New(s.settings, s.config, s.auth, AllowLocalCharts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"name": "hello", "chart": "testdata/hello"}`
+	if code, resp := doRequest(t, s.Handler(), http.MethodPost, releases, testToken, body); code == http.StatusForbidden {
+		t.Errorf("expected a local chart to be allowed, got %d: %s", code, resp)
+	}
+}
+
+func TestUnderURL(t *testing.T) {
+	for _, tt := range []struct {
+		url, allowed string
+		expect       bool
+	}{
+		{"https://charts.example.com/", "https://charts.example.com", true},
+		{"https://charts.example.com/stable/", "https://charts.example.com/stable", true},
+		{"https://charts.example.com/stable/../incubator", "https://charts.example.com/stable", false},
+		{"https://charts.example.com/stable-old", "https://charts.example.com/stable", false},
+		{"https://charts.example.com.evil.com/", "https://charts.example.com", false},
+		{"http://charts.example.com/", "https://charts.example.com", false},
+		{"oci://REGISTRY.example.com/team/hello", "oci://registry.example.com/team", true},
+	} {
+		u, _ := url.Parse(tt.url)
+		allowed, _ := url.Parse(tt.allowed)
+		if got := underURL(u, allowed); got != tt.expect {
+			t.Errorf("underURL(%q, %q) = %t, expected %t", tt.url, tt.allowed, got, tt.expect)
+		}
+	}
+
+	if _, err := New(nil, nil, nil, AllowRepositories("charts.example.com")); err == nil {
+		t.Error("expected an error for a repository URL without a scheme")
+	}
+}
+
+func decodeRelease(t *testing.T, body string) *release.Release {
+	t.Helper()
+	var rel release.Release
+	if err := json.Unmarshal([]byte(body), &rel); err != nil {
+		t.Fatalf("invalid release %s: %s", body, err)
+	}
+	return &rel
+}
+
+func TestGRPC(t *testing.T) {
+	s, repoURL := newTestServer(t, false)
+	l := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	s.RegisterGRPC(g)
+	go g.Serve(l)
+	defer g.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(CodecName)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	req := &InstallRequest{Namespace: "apps", Name: "hello", Chart: "hello", Version: "0.1.0", RepoURL: repoURL}
+	var rel release.Release
+	err = conn.Invoke(ctx, "/"+ServiceName+"/Install", req, &rel)
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("expected %s without token, got %s", codes.Unauthenticated, code)
+	}
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+testToken)
+	if err := conn.Invoke(ctx, "/"+ServiceName+"/Install", req, &rel); err != nil {
+		t.Fatal(err)
+	}
+	if rel.Name != "hello" || rel.Version != 1 {
+		t.Errorf("unexpected release %s revision %d", rel.Name, rel.Version)
+	}
+
+	err = conn.Invoke(ctx, "/"+ServiceName+"/Status", &StatusRequest{Namespace: "apps", Name: "missing"}, &rel)
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("expected %s for a missing release, got %s: %v", codes.NotFound, code, err)
+	}
+
+	var list ListResponse
+	if err := conn.Invoke(ctx, "/"+ServiceName+"/List", &ListRequest{Namespace: "apps"}, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Releases) != 1 {
+		t.Errorf("expected 1 release, got %d", len(list.Releases))
+	}
+}
@@ -43,14 +43,29 @@ const ConfigMapsDriverName = "ConfigMap"
 type ConfigMaps struct {
 	impl corev1.ConfigMapInterface
 	Log  func(string, ...interface{})
+
+	// Compression is the algorithm used to compress new release records.
+	// Records are always read back with whichever algorithm wrote them.
+	Compression Compression
+	// ChunkSize is the largest encoded release record stored in a single
+	// ConfigMap. Larger records are split across several ConfigMaps. Zero
+	// disables chunking.
+	ChunkSize int
+	// Keys, if set, encrypts new release records and decrypts encrypted
+	// ones. Unencrypted records remain readable.
+	Keys KeyProvider
+	// Metrics, if set, receives the size of every release record written.
+	Metrics Metrics
 }
 
 // NewConfigMaps initializes a new ConfigMaps wrapping an implementation of
 // the kubernetes ConfigMapsInterface.
 func NewConfigMaps(impl corev1.ConfigMapInterface) *ConfigMaps {
 	return &ConfigMaps{
-		impl: impl,
-		Log:  func(_ string, _ ...interface{}) {},
+		impl:        impl,
+		Log:         func(_ string, _ ...interface{}) {},
+		Compression: CompressionGzip,
+		ChunkSize:   DefaultChunkSize,
 	}
 }
 
@@ -73,7 +88,7 @@ func (cfgmaps *ConfigMaps) Get(key string) (*rspb.Release, error) {
 		return nil, err
 	}
 	// found the configmap, decode the base64 data string
-	r, err := decodeRelease(obj.Data["release"])
+	r, err := cfgmaps.decode(obj)
 	if err != nil {
 		cfgmaps.Log("get: failed to decode data %q: %s", key, err)
 		return nil, err
@@ -99,8 +114,9 @@ func (cfgmaps *ConfigMaps) List(filter func(*rspb.Release) bool) ([]*rspb.Releas
 
 	// iterate over the configmaps object list
 	// and decode each release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := cfgmaps.decode(item)
 		if err != nil {
 			cfgmaps.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -139,8 +155,8 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]*rspb.Release, err
 	}
 
 	var results []*rspb.Release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+	for i := range list.Items {
+		rls, err := cfgmaps.decode(&list.Items[i])
 		if err != nil {
 			cfgmaps.Log("query: failed to decode release: %s", err)
 			continue
@@ -159,14 +175,14 @@ func (cfgmaps *ConfigMaps) Create(key string, rls *rspb.Release) error {
 	lbs.init()
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
-	// create a new configmap to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	// create the configmaps to hold the release
+	objs, err := cfgmaps.newConfigMapsObjects(key, rls, lbs)
 	if err != nil {
 		cfgmaps.Log("create: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
 	// push the configmap object out into the kubiverse
-	if _, err := cfgmaps.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+	if _, err := cfgmaps.impl.Create(context.Background(), objs[0], metav1.CreateOptions{}); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -174,6 +190,14 @@ func (cfgmaps *ConfigMaps) Create(key string, rls *rspb.Release) error {
 		cfgmaps.Log("create: failed to create: %s", err)
 		return err
 	}
+	// the primary configmap now reserves the key, so store the remaining chunks
+	if err := cfgmaps.applyChunks(objs[1:]); err != nil {
+		cfgmaps.Log("create: failed to create chunk: %s", err)
+		if derr := cfgmaps.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); derr != nil {
+			cfgmaps.Log("create: failed to clean up %q: %s", key, derr)
+		}
+		return err
+	}
 	return nil
 }
 
@@ -186,34 +210,131 @@ func (cfgmaps *ConfigMaps) Update(key string, rls *rspb.Release) error {
 	lbs.init()
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
-	// create a new configmap object to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	// create new configmap objects to hold the release
+	objs, err := cfgmaps.newConfigMapsObjects(key, rls, lbs)
 	if err != nil {
 		cfgmaps.Log("update: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	// remember how many chunks the previous record used
+	stale := 1
+	if old, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+		if stale, err = chunkCount(old.Annotations); err != nil {
+			stale = 1
+		}
+	}
+	// write the chunks before the primary configmap that references them,
+	// and remove the ones the previous record did not use if the update fails
+	if err := cfgmaps.applyChunks(objs[1:]); err != nil {
+		cfgmaps.Log("update: failed to update chunk: %s", err)
+		cfgmaps.deleteChunks(key, stale, len(objs))
+		return err
+	}
 	// push the configmap object out into the kubiverse
-	_, err = cfgmaps.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
+	_, err = cfgmaps.impl.Update(context.Background(), objs[0], metav1.UpdateOptions{})
 	if err != nil {
 		cfgmaps.Log("update: failed to update: %s", err)
+		cfgmaps.deleteChunks(key, stale, len(objs))
 		return err
 	}
+	cfgmaps.deleteChunks(key, len(objs), stale)
 	return nil
 }
 
 // Delete deletes the ConfigMap holding the release named by key.
 func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 	// fetch the release to check existence
-	if rls, err = cfgmaps.Get(key); err != nil {
+	obj, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, err
+	}
+	if rls, err = cfgmaps.decode(obj); err != nil {
 		return nil, err
 	}
 	// delete the release
 	if err = cfgmaps.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
 		return rls, err
 	}
+	n, _ := chunkCount(obj.Annotations)
+	cfgmaps.deleteChunks(key, 1, n)
 	return rls, nil
 }
 
+// decode decodes the release stored in obj, fetching the remaining
+// chunks if the record was split across several ConfigMaps.
+func (cfgmaps *ConfigMaps) decode(obj *v1.ConfigMap) (*rspb.Release, error) {
+	data, err := joinChunks(obj.Name, obj.Annotations, obj.Data["release"], func(key string) (string, error) {
+		chunk, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return chunk.Data["release"], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeReleaseWith(data, cfgmaps.Keys)
+}
+
+// applyChunks creates or updates the ConfigMaps holding chunks of a release.
+func (cfgmaps *ConfigMaps) applyChunks(objs []*v1.ConfigMap) error {
+	for _, obj := range objs {
+		_, err := cfgmaps.impl.Create(context.Background(), obj, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = cfgmaps.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChunks deletes the chunk ConfigMaps from..to-1 of the release stored
+// under key. Failures are logged since the chunks are no longer referenced.
+func (cfgmaps *ConfigMaps) deleteChunks(key string, from, to int) {
+	for i := from; i < to; i++ {
+		err := cfgmaps.impl.Delete(context.Background(), chunkKey(key, i), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			cfgmaps.Log("failed to delete chunk %q: %s", chunkKey(key, i), err)
+		}
+	}
+}
+
+// newConfigMapsObjects constructs the ConfigMaps needed to store a release:
+// the primary ConfigMap named by key and, if the encoded release is larger
+// than the configured chunk size, one ConfigMap per additional chunk.
+func (cfgmaps *ConfigMaps) newConfigMapsObjects(key string, rls *rspb.Release, lbs labels) ([]*v1.ConfigMap, error) {
+	s, err := encodeReleaseWith(rls, cfgmaps.Compression, cfgmaps.Keys)
+	if err != nil {
+		return nil, err
+	}
+	chunks := splitChunks(s, cfgmaps.ChunkSize)
+	observeRecord(cfgmaps.Metrics, cfgmaps.Log, ConfigMapsDriverName, key, len(s), len(chunks))
+
+	obj := newConfigMapsObjectData(key, rls, lbs, chunks[0])
+	obj.Annotations = chunkAnnotations(s, chunks)
+	objs := []*v1.ConfigMap{obj}
+	for i := 1; i < len(chunks); i++ {
+		var clbs labels
+		clbs.init()
+		clbs.set("name", rls.Name)
+		clbs.set("owner", chunkOwner)
+		clbs.set("version", strconv.Itoa(rls.Version))
+		objs = append(objs, &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkKey(key, i),
+				Labels: clbs.toMap(),
+			},
+			Data: map[string]string{"release": chunks[i]},
+		})
+	}
+	return objs, nil
+}
+
 // newConfigMapsObject constructs a kubernetes ConfigMap object
 // to store a release. Each configmap data entry is the base64
 // encoded gzipped string of a release.
@@ -228,13 +349,18 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 //    "name"           - name of the release.
 //
 func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigMap, error) {
-	const owner = "helm"
-
 	// encode the release
 	s, err := encodeRelease(rls)
 	if err != nil {
 		return nil, err
 	}
+	return newConfigMapsObjectData(key, rls, lbs, s), nil
+}
+
+// newConfigMapsObjectData constructs the ConfigMap storing the already
+// encoded release data s.
+func newConfigMapsObjectData(key string, rls *rspb.Release, lbs labels, s string) *v1.ConfigMap {
+	const owner = "helm"
 
 	if lbs == nil {
 		lbs.init()
@@ -253,5 +379,5 @@ func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigM
 			Labels: lbs.toMap(),
 		},
 		Data: map[string]string{"release": s},
-	}, nil
+	}
 }
@@ -239,3 +239,38 @@ func TestConfigMapDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestConfigMapChunked(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+
+	var mock MockConfigMapsInterface
+	mock.Init(t)
+	cfgmaps := NewConfigMaps(&mock)
+	cfgmaps.ChunkSize = 32
+
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	if len(mock.objects) < 2 {
+		t.Fatalf("Expected release to be split into chunks, got %d objects", len(mock.objects))
+	}
+
+	got, err := cfgmaps.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	if _, err := cfgmaps.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected all chunks to be deleted, got %d objects", len(mock.objects))
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultChunkSize is the largest encoded release payload the Secret and
+// ConfigMap drivers store in a single object. Kubernetes rejects objects
+// larger than 1MiB, so some headroom is left for the object metadata.
+const DefaultChunkSize = 1024*1024 - 64*1024
+
+const (
+	// chunksAnnotation records the number of objects a release record was
+	// split across. It is only set on the primary object of a chunked record.
+	chunksAnnotation = "helm.sh/release-chunks"
+	// checksumAnnotation records the checksum of the complete encoded
+	// release record of a chunked record.
+	checksumAnnotation = "helm.sh/release-checksum"
+	// chunkOwner is the owner label of the objects holding the second and
+	// subsequent chunks, so that they are never listed as releases.
+	chunkOwner = "helm-chunk"
+)
+
+// ErrChecksumMismatch indicates that the chunks of a release record were
+// reassembled but did not match the checksum recorded when it was written.
+var ErrChecksumMismatch = errors.New("release: checksum mismatch")
+
+// chunkKey returns the name of the object holding chunk i of the release
+// record stored under key. Chunk 0 is stored under key itself.
+func chunkKey(key string, i int) string {
+	return fmt.Sprintf("%s.c%d", key, i)
+}
+
+// splitChunks splits data into pieces of at most size bytes. A size of zero
+// or less disables chunking.
+func splitChunks(data string, size int) []string {
+	if size <= 0 || len(data) <= size {
+		return []string{data}
+	}
+	var chunks []string
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return append(chunks, data)
+}
+
+// chunkAnnotations returns the annotations to set on the primary object of
+// a record split into the given chunks, or nil if it was not split.
+func chunkAnnotations(data string, chunks []string) map[string]string {
+	if len(chunks) < 2 {
+		return nil
+	}
+	return map[string]string{
+		chunksAnnotation:   strconv.Itoa(len(chunks)),
+		checksumAnnotation: checksum(data),
+	}
+}
+
+// chunkCount returns the number of objects the record whose primary object
+// carries annotations is stored in.
+func chunkCount(annotations map[string]string) (int, error) {
+	v, ok := annotations[chunksAnnotation]
+	if !ok {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("invalid %s annotation %q", chunksAnnotation, v)
+	}
+	return n, nil
+}
+
+// joinChunks reassembles the encoded release record whose primary object is
+// named key, carries annotations and holds first. The remaining chunks are
+// fetched with get and the result is verified against the recorded checksum.
+func joinChunks(key string, annotations map[string]string, first string, get func(key string) (string, error)) (string, error) {
+	n, err := chunkCount(annotations)
+	if err != nil || n == 1 {
+		return first, err
+	}
+	var sb strings.Builder
+	sb.WriteString(first)
+	for i := 1; i < n; i++ {
+		chunk, err := get(chunkKey(key, i))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get chunk %d of %d", i+1, n)
+		}
+		sb.WriteString(chunk)
+	}
+	data := sb.String()
+	if checksum(data) != annotations[checksumAnnotation] {
+		return "", ErrChecksumMismatch
+	}
+	return data, nil
+}
+
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,186 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// magicEnvelope prefixes release records that have been encrypted. It can
+// never be confused with a gzip or zstd header or with plain JSON.
+var magicEnvelope = []byte("\x00helm-enc.v1\x00")
+
+// ErrNoKeyProvider indicates that a release record is encrypted but the
+// driver reading it has no KeyProvider configured.
+var ErrNoKeyProvider = errors.New("release: record is encrypted but no key provider is configured")
+
+// KeyProvider supplies the key-encryption keys used for envelope encryption
+// of release records. Every record is encrypted with its own random data key,
+// which is in turn wrapped by the KeyProvider and stored alongside the record.
+//
+// Implementations may hold keys in memory, as LocalKeyProvider does, or
+// delegate wrapping to an external key management service.
+type KeyProvider interface {
+	// KeyID identifies the key used to wrap new data keys.
+	KeyID() string
+	// WrapKey encrypts a data key with the key identified by KeyID.
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key that was wrapped by the key identified
+	// by keyID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// envelope is the stored form of an encrypted release record.
+type envelope struct {
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"key"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// seal encrypts plaintext with a fresh data key wrapped by kp.
+func seal(kp KeyProvider, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	wrapped, err := kp.WrapKey(dataKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to wrap data key")
+	}
+	b, err := json.Marshal(envelope{
+		KeyID:      kp.KeyID(),
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Data:       aead.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, magicEnvelope...), b...), nil
+}
+
+// open reverses seal. Records that are not encrypted are returned as is.
+func open(kp KeyProvider, b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, magicEnvelope) {
+		return b, nil
+	}
+	if kp == nil {
+		return nil, ErrNoKeyProvider
+	}
+	var env envelope
+	if err := json.Unmarshal(b[len(magicEnvelope):], &env); err != nil {
+		return nil, errors.Wrap(err, "failed to parse encrypted release")
+	}
+	dataKey, err := kp.UnwrapKey(env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap data key with key %q", env.KeyID)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, nil)
+	return plaintext, errors.Wrap(err, "failed to decrypt release")
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKeyProvider is a KeyProvider that wraps data keys with AES-GCM using
+// key-encryption keys held in memory.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKeyProvider returns a LocalKeyProvider wrapping new data keys with
+// key. Each key must be 16, 24 or 32 bytes long. Previous keys are only used
+// to read records written before a key rotation.
+func NewLocalKeyProvider(key []byte, previous ...[]byte) (*LocalKeyProvider, error) {
+	kp := &LocalKeyProvider{keys: make(map[string]cipher.AEAD)}
+	for i, k := range append([][]byte{key}, previous...) {
+		aead, err := newGCM(k)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid encryption key")
+		}
+		id := LocalKeyID(k)
+		if i == 0 {
+			kp.current = id
+		}
+		kp.keys[id] = aead
+	}
+	return kp, nil
+}
+
+// LocalKeyID returns the identifier a LocalKeyProvider records for key. It is
+// derived from the key so that records can be matched to the key that wrote
+// them without revealing it.
+func LocalKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local:" + hex.EncodeToString(sum[:8])
+}
+
+// KeyID implements KeyProvider.
+func (kp *LocalKeyProvider) KeyID() string {
+	return kp.current
+}
+
+// WrapKey implements KeyProvider.
+func (kp *LocalKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	aead := kp.keys[kp.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// UnwrapKey implements KeyProvider.
+func (kp *LocalKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := kp.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("unknown key %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, wrapped[:n], wrapped[n:], nil)
+}
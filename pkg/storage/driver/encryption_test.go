@@ -0,0 +1,100 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+)
+
+func TestEncryptedSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	kp, err := NewLocalKeyProvider(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	rel.Manifest = "kind: Secret\ndata:\n  password: hunter2\n"
+	plain := releaseStub("plain-pigeon", 1, "default", rspb.StatusDeployed)
+
+	secrets := newTestFixtureSecrets(t, plain)
+	secrets.Keys = kp
+
+	if err := secrets.Create(testKey(rel.Name, rel.Version), rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	mock := secrets.impl.(*MockSecretsInterface)
+	stored := mock.objects[testKey(rel.Name, rel.Version)].Data["release"]
+	b, err := b64.DecodeString(string(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, magicEnvelope) {
+		t.Errorf("Expected stored release to be encrypted")
+	}
+
+	got, err := secrets.Get(testKey(rel.Name, rel.Version))
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	// releases written before encryption was enabled remain readable
+	if _, err := secrets.Get(testKey(plain.Name, plain.Version)); err != nil {
+		t.Errorf("Failed to get unencrypted release: %s", err)
+	}
+
+	// a driver without keys cannot read the encrypted release
+	secrets.Keys = nil
+	if _, err := secrets.Get(testKey(rel.Name, rel.Version)); !errors.Is(err, ErrNoKeyProvider) {
+		t.Errorf("Expected %v, got %v", ErrNoKeyProvider, err)
+	}
+
+	// after a key rotation the previous key still reads old records
+	rotated, err := NewLocalKeyProvider(bytes.Repeat([]byte{2}, 32), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets.Keys = rotated
+	if _, err := secrets.Get(testKey(rel.Name, rel.Version)); err != nil {
+		t.Errorf("Failed to get release after key rotation: %s", err)
+	}
+
+	// but a different key does not
+	other, err := NewLocalKeyProvider(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets.Keys = other
+	if _, err := secrets.Get(testKey(rel.Name, rel.Version)); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("Expected unknown key error, got %v", err)
+	}
+}
+
+func TestNewLocalKeyProviderInvalidKey(t *testing.T) {
+	if _, err := NewLocalKeyProvider([]byte("short")); err == nil {
+		t.Error("Expected an error for an invalid key length")
+	}
+}
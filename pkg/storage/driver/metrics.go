@@ -0,0 +1,52 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import "time"
+
+// MaxObjectSize is the largest object the Kubernetes API server accepts.
+// A Secret or ConfigMap holding a release record cannot exceed it.
+const MaxObjectSize = 1024 * 1024
+
+// recordSizeWarning is the fraction of MaxObjectSize above which a release
+// record stored in a single object is logged as a warning.
+const recordSizeWarning = 0.9
+
+// Metrics receives measurements of release storage. It is meant to be
+// implemented by embedders that export them, e.g. as Prometheus counters
+// and histograms. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveOperation records a storage operation: one of "get", "list",
+	// "query", "create", "update" or "delete" performed by the named driver,
+	// how long it took and the error it returned, if any.
+	ObserveOperation(driver, op string, d time.Duration, err error)
+	// ObserveRecordSize records the encoded size in bytes of a release record
+	// written by the named driver.
+	ObserveRecordSize(driver string, size int)
+}
+
+// observeRecord reports the encoded size of the release record stored under
+// key and warns through log if it is stored in a single object that is close
+// to MaxObjectSize.
+func observeRecord(m Metrics, log func(string, ...interface{}), driver, key string, size, chunks int) {
+	if m != nil {
+		m.ObserveRecordSize(driver, size)
+	}
+	if chunks == 1 && float64(size) >= recordSizeWarning*MaxObjectSize {
+		log("WARNING: release record %q is %d bytes, close to the %d byte object size limit", key, size, MaxObjectSize)
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import rspb "github.com/open-hand/helm/pkg/release"
+
+// ListOptions narrows a listing performed by a Paginator.
+type ListOptions struct {
+	// Statuses restricts the listing to releases whose latest revision has
+	// one of these statuses. An empty list matches every status.
+	Statuses []string
+	// AfterName and AfterNamespace, if AfterName is set, restrict the
+	// listing to releases ordered after the release they identify.
+	AfterName      string
+	AfterNamespace string
+	// Descending orders releases by descending rather than ascending name.
+	Descending bool
+	// Limit is the maximum number of releases returned. Zero means no limit.
+	Limit int
+}
+
+// Paginator is implemented by drivers that can select the latest revision
+// of every release and page through them in the backend, rather than
+// loading every revision into memory.
+//
+// ListLatest returns the latest revision of every release matching opts,
+// ordered by name and then namespace.
+type Paginator interface {
+	ListLatest(opts ListOptions) ([]*rspb.Release, error)
+}
@@ -0,0 +1,94 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import rspb "github.com/open-hand/helm/pkg/release"
+
+// Scoped keeps the release records of a namespace in a driver shared with
+// other namespaces, such as the Secrets of a namespace dedicated to release
+// history. The keys of the records are prefixed with the namespace, so that
+// releases of the same name in different namespaces do not collide, and
+// only the releases of the namespace are listed and queried.
+type Scoped struct {
+	Driver
+	// Namespace is the namespace of the releases. Every release is listed
+	// if it is empty.
+	Namespace string
+}
+
+// NewScoped returns a driver keeping the records of the releases of
+// namespace in d.
+func NewScoped(d Driver, namespace string) *Scoped {
+	return &Scoped{Driver: d, Namespace: namespace}
+}
+
+func (s *Scoped) key(key string) string {
+	if s.Namespace == "" {
+		return key
+	}
+	return s.Namespace + "." + key
+}
+
+func (s *Scoped) owns(rls *rspb.Release) bool {
+	return s.Namespace == "" || rls.Namespace == s.Namespace
+}
+
+// Get returns the release named by key.
+func (s *Scoped) Get(key string) (*rspb.Release, error) {
+	return s.Driver.Get(s.key(key))
+}
+
+// List returns the releases of the namespace that satisfy filter.
+func (s *Scoped) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	return s.Driver.List(func(rls *rspb.Release) bool {
+		return s.owns(rls) && filter(rls)
+	})
+}
+
+// Query returns the releases of the namespace that match labels, or
+// ErrReleaseNotFound if there are none.
+func (s *Scoped) Query(labels map[string]string) ([]*rspb.Release, error) {
+	all, err := s.Driver.Query(labels)
+	if err != nil {
+		return nil, err
+	}
+	var results []*rspb.Release
+	for _, rls := range all {
+		if s.owns(rls) {
+			results = append(results, rls)
+		}
+	}
+	if len(results) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return results, nil
+}
+
+// Create stores the release under the key of the namespace.
+func (s *Scoped) Create(key string, rls *rspb.Release) error {
+	return s.Driver.Create(s.key(key), rls)
+}
+
+// Update updates the release stored under the key of the namespace.
+func (s *Scoped) Update(key string, rls *rspb.Release) error {
+	return s.Driver.Update(s.key(key), rls)
+}
+
+// Delete deletes the release stored under the key of the namespace.
+func (s *Scoped) Delete(key string) (*rspb.Release, error) {
+	return s.Driver.Delete(s.key(key))
+}
@@ -0,0 +1,88 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "github.com/open-hand/helm/pkg/storage/driver"
+
+import (
+	"testing"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+)
+
+func TestScoped(t *testing.T) {
+	shared := newTestFixtureSecrets(t)
+	apps := NewScoped(shared, "apps")
+	web := NewScoped(shared, "web")
+
+	// releases of the same name in different namespaces do not collide
+	for _, d := range []*Scoped{apps, web} {
+		for v := 1; v <= 2; v++ {
+			rls := releaseStub("frontend", v, d.Namespace, rspb.StatusSuperseded)
+			if err := d.Create(testKey(rls.Name, rls.Version), rls); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := shared.Get("apps." + testKey("frontend", 1)); err != nil {
+		t.Errorf("expected the record to be stored under the key of its namespace: %s", err)
+	}
+
+	rls, err := web.Get(testKey("frontend", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rls.Namespace != "web" {
+		t.Errorf("expected the release of namespace web, got %q", rls.Namespace)
+	}
+
+	listed, err := apps.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	queried, err := apps.Query(map[string]string{"name": "frontend", "owner": "helm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, found := range [][]*rspb.Release{listed, queried} {
+		if len(found) != 2 {
+			t.Errorf("expected the 2 revisions of namespace apps, got %d", len(found))
+		}
+		for _, rls := range found {
+			if rls.Namespace != "apps" {
+				t.Errorf("expected only releases of namespace apps, got %q", rls.Namespace)
+			}
+		}
+	}
+
+	all, err := NewScoped(shared, "").List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 {
+		t.Errorf("expected the 4 records of all the namespaces, got %d", len(all))
+	}
+
+	if _, err := NewScoped(shared, "other").Query(map[string]string{"name": "frontend", "owner": "helm"}); err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+
+	if _, err := web.Delete(testKey("frontend", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apps.Get(testKey("frontend", 1)); err != nil {
+		t.Errorf("expected the release of namespace apps to be kept: %s", err)
+	}
+}
@@ -43,14 +43,29 @@ const SecretsDriverName = "Secret"
 type Secrets struct {
 	impl corev1.SecretInterface
 	Log  func(string, ...interface{})
+
+	// Compression is the algorithm used to compress new release records.
+	// Records are always read back with whichever algorithm wrote them.
+	Compression Compression
+	// ChunkSize is the largest encoded release record stored in a single
+	// Secret. Larger records are split across several Secrets. Zero
+	// disables chunking.
+	ChunkSize int
+	// Keys, if set, encrypts new release records and decrypts encrypted
+	// ones. Unencrypted records remain readable.
+	Keys KeyProvider
+	// Metrics, if set, receives the size of every release record written.
+	Metrics Metrics
 }
 
 // NewSecrets initializes a new Secrets wrapping an implementation of
 // the kubernetes SecretsInterface.
 func NewSecrets(impl corev1.SecretInterface) *Secrets {
 	return &Secrets{
-		impl: impl,
-		Log:  func(_ string, _ ...interface{}) {},
+		impl:        impl,
+		Log:         func(_ string, _ ...interface{}) {},
+		Compression: CompressionGzip,
+		ChunkSize:   DefaultChunkSize,
 	}
 }
 
@@ -71,7 +86,7 @@ func (secrets *Secrets) Get(key string) (*rspb.Release, error) {
 		return nil, errors.Wrapf(err, "get: failed to get %q", key)
 	}
 	// found the secret, decode the base64 data string
-	r, err := decodeRelease(string(obj.Data["release"]))
+	r, err := secrets.decode(obj)
 	return r, errors.Wrapf(err, "get: failed to decode data %q", key)
 }
 
@@ -91,8 +106,9 @@ func (secrets *Secrets) List(filter func(*rspb.Release) bool) ([]*rspb.Release,
 
 	// iterate over the secrets object list
 	// and decode each release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := secrets.decode(item)
 		if err != nil {
 			secrets.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -130,8 +146,8 @@ func (secrets *Secrets) Query(labels map[string]string) ([]*rspb.Release, error)
 	}
 
 	var results []*rspb.Release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+	for i := range list.Items {
+		rls, err := secrets.decode(&list.Items[i])
 		if err != nil {
 			secrets.Log("query: failed to decode release: %s", err)
 			continue
@@ -150,19 +166,26 @@ func (secrets *Secrets) Create(key string, rls *rspb.Release) error {
 	lbs.init()
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
-	// create a new secret to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	// create the secrets to hold the release
+	objs, err := secrets.newSecretsObjects(key, rls, lbs)
 	if err != nil {
 		return errors.Wrapf(err, "create: failed to encode release %q", rls.Name)
 	}
 	// push the secret object out into the kubiverse
-	if _, err := secrets.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+	if _, err := secrets.impl.Create(context.Background(), objs[0], metav1.CreateOptions{}); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
 
 		return errors.Wrap(err, "create: failed to create")
 	}
+	// the primary secret now reserves the key, so store the remaining chunks
+	if err := secrets.applyChunks(objs[1:]); err != nil {
+		if derr := secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); derr != nil {
+			secrets.Log("create: failed to clean up %q: %s", key, derr)
+		}
+		return errors.Wrap(err, "create: failed to create chunk")
+	}
 	return nil
 }
 
@@ -175,25 +198,126 @@ func (secrets *Secrets) Update(key string, rls *rspb.Release) error {
 	lbs.init()
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
-	// create a new secret object to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	// create new secret objects to hold the release
+	objs, err := secrets.newSecretsObjects(key, rls, lbs)
 	if err != nil {
 		return errors.Wrapf(err, "update: failed to encode release %q", rls.Name)
 	}
+	// remember how many chunks the previous record used
+	stale := 1
+	if old, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+		if stale, err = chunkCount(old.Annotations); err != nil {
+			stale = 1
+		}
+	}
+	// write the chunks before the primary secret that references them, and
+	// remove the ones the previous record did not use if the update fails
+	if err := secrets.applyChunks(objs[1:]); err != nil {
+		secrets.deleteChunks(key, stale, len(objs))
+		return errors.Wrap(err, "update: failed to update chunk")
+	}
 	// push the secret object out into the kubiverse
-	_, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
-	return errors.Wrap(err, "update: failed to update")
+	if _, err = secrets.impl.Update(context.Background(), objs[0], metav1.UpdateOptions{}); err != nil {
+		secrets.deleteChunks(key, stale, len(objs))
+		return errors.Wrap(err, "update: failed to update")
+	}
+	secrets.deleteChunks(key, len(objs), stale)
+	return nil
 }
 
 // Delete deletes the Secret holding the release named by key.
 func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 	// fetch the release to check existence
-	if rls, err = secrets.Get(key); err != nil {
-		return nil, err
+	obj, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrReleaseNotFound
+		}
+		return nil, errors.Wrapf(err, "delete: failed to get %q", key)
+	}
+	if rls, err = secrets.decode(obj); err != nil {
+		return nil, errors.Wrapf(err, "delete: failed to decode data %q", key)
 	}
 	// delete the release
-	err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{})
-	return rls, err
+	if err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
+		return rls, err
+	}
+	n, _ := chunkCount(obj.Annotations)
+	secrets.deleteChunks(key, 1, n)
+	return rls, nil
+}
+
+// decode decodes the release stored in obj, fetching the remaining
+// chunks if the record was split across several Secrets.
+func (secrets *Secrets) decode(obj *v1.Secret) (*rspb.Release, error) {
+	data, err := joinChunks(obj.Name, obj.Annotations, string(obj.Data["release"]), func(key string) (string, error) {
+		chunk, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		return string(chunk.Data["release"]), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeReleaseWith(data, secrets.Keys)
+}
+
+// applyChunks creates or updates the Secrets holding chunks of a release.
+func (secrets *Secrets) applyChunks(objs []*v1.Secret) error {
+	for _, obj := range objs {
+		_, err := secrets.impl.Create(context.Background(), obj, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteChunks deletes the chunk Secrets from..to-1 of the release stored
+// under key. Failures are logged since the chunks are no longer referenced.
+func (secrets *Secrets) deleteChunks(key string, from, to int) {
+	for i := from; i < to; i++ {
+		err := secrets.impl.Delete(context.Background(), chunkKey(key, i), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			secrets.Log("failed to delete chunk %q: %s", chunkKey(key, i), err)
+		}
+	}
+}
+
+// newSecretsObjects constructs the Secrets needed to store a release:
+// the primary Secret named by key and, if the encoded release is larger
+// than the configured chunk size, one Secret per additional chunk.
+func (secrets *Secrets) newSecretsObjects(key string, rls *rspb.Release, lbs labels) ([]*v1.Secret, error) {
+	s, err := encodeReleaseWith(rls, secrets.Compression, secrets.Keys)
+	if err != nil {
+		return nil, err
+	}
+	chunks := splitChunks(s, secrets.ChunkSize)
+	observeRecord(secrets.Metrics, secrets.Log, SecretsDriverName, key, len(s), len(chunks))
+
+	obj := newSecretsObjectData(key, rls, lbs, chunks[0])
+	obj.Annotations = chunkAnnotations(s, chunks)
+	objs := []*v1.Secret{obj}
+	for i := 1; i < len(chunks); i++ {
+		var clbs labels
+		clbs.init()
+		clbs.set("name", rls.Name)
+		clbs.set("owner", chunkOwner)
+		clbs.set("version", strconv.Itoa(rls.Version))
+		objs = append(objs, &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkKey(key, i),
+				Labels: clbs.toMap(),
+			},
+			Type: "helm.sh/release.v1",
+			Data: map[string][]byte{"release": []byte(chunks[i])},
+		})
+	}
+	return objs, nil
 }
 
 // newSecretsObject constructs a kubernetes Secret object
@@ -210,13 +334,18 @@ func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 //    "name"           - name of the release.
 //
 func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, error) {
-	const owner = "helm"
-
 	// encode the release
 	s, err := encodeRelease(rls)
 	if err != nil {
 		return nil, err
 	}
+	return newSecretsObjectData(key, rls, lbs, s), nil
+}
+
+// newSecretsObjectData constructs the Secret storing the already encoded
+// release data s.
+func newSecretsObjectData(key string, rls *rspb.Release, lbs labels, s string) *v1.Secret {
+	const owner = "helm"
 
 	if lbs == nil {
 		lbs.init()
@@ -246,5 +375,5 @@ func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, er
 		},
 		Type: "helm.sh/release.v1",
 		Data: map[string][]byte{"release": []byte(s)},
-	}, nil
+	}
 }
@@ -14,12 +14,19 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	rspb "github.com/open-hand/helm/pkg/release"
 )
@@ -239,3 +246,141 @@ func TestSecretDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestSecretChunked(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+
+	var mock MockSecretsInterface
+	mock.Init(t)
+	secrets := NewSecrets(&mock)
+	secrets.Compression = CompressionZstd
+	secrets.ChunkSize = 32
+
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	chunks := len(mock.objects)
+	if chunks < 2 {
+		t.Fatalf("Expected release to be split into chunks, got %d objects", chunks)
+	}
+
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	// chunks must not be listed as releases
+	ls, err := secrets.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatalf("Failed to list releases: %s", err)
+	}
+	if len(ls) != 1 {
+		t.Errorf("Expected 1 release, got %d", len(ls))
+	}
+
+	// shrinking the record removes the stale chunks
+	secrets.ChunkSize = 0
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != 1 {
+		t.Errorf("Expected stale chunks to be deleted, got %d objects", len(mock.objects))
+	}
+
+	// a corrupted chunk is detected
+	secrets.ChunkSize = 32
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	mock.objects[chunkKey(key, 1)].Data["release"] = []byte("corrupted")
+	if _, err := secrets.Get(key); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected %v, got %v", ErrChecksumMismatch, err)
+	}
+}
+
+// failingUpdateSecrets fails the updates of the Secret named name.
+type failingUpdateSecrets struct {
+	*MockSecretsInterface
+	name string
+}
+
+func (f failingUpdateSecrets) Update(ctx context.Context, secret *v1.Secret, opts metav1.UpdateOptions) (*v1.Secret, error) {
+	if secret.Name == f.name {
+		return nil, errors.New("update failed")
+	}
+	return f.MockSecretsInterface.Update(ctx, secret, opts)
+}
+
+func TestSecretChunkedUpdateFailure(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+
+	var mock MockSecretsInterface
+	mock.Init(t, rel)
+	secrets := NewSecrets(failingUpdateSecrets{&mock, key})
+	secrets.ChunkSize = 32
+
+	if err := secrets.Update(key, rel); err == nil {
+		t.Fatal("Expected the update to fail")
+	}
+	// the chunks of the failed update must not be left behind
+	if len(mock.objects) != 1 {
+		t.Errorf("Expected the orphaned chunks to be deleted, got %d objects", len(mock.objects))
+	}
+	if _, err := secrets.Get(key); err != nil {
+		t.Errorf("Expected the previous record to be intact, got %v", err)
+	}
+}
+
+type sizeMetrics struct{ sizes []int }
+
+func (m *sizeMetrics) ObserveOperation(string, string, time.Duration, error) {}
+
+func (m *sizeMetrics) ObserveRecordSize(_ string, size int) { m.sizes = append(m.sizes, size) }
+
+func TestSecretRecordSize(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	// random data does not compress, so the record exceeds the warning size
+	manifest := make([]byte, MaxObjectSize)
+	rand.New(rand.NewSource(1)).Read(manifest)
+	rel.Manifest = string(manifest)
+
+	var logged []string
+	metrics := &sizeMetrics{}
+	secrets := newTestFixtureSecrets(t)
+	secrets.Metrics = metrics
+	secrets.ChunkSize = 0
+	secrets.Log = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	if err := secrets.Create(testKey(rel.Name, rel.Version), rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	if len(metrics.sizes) != 1 || metrics.sizes[0] < MaxObjectSize {
+		t.Errorf("Expected the record size to be observed, got %v", metrics.sizes)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "object size limit") {
+		t.Errorf("Expected a size warning, got %v", logged)
+	}
+
+	// chunked records are not close to the limit
+	logged = nil
+	secrets.ChunkSize = DefaultChunkSize
+	if err := secrets.Update(testKey(rel.Name, rel.Version), rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("Expected no size warning, got %v", logged)
+	}
+}
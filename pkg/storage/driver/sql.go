@@ -75,6 +75,12 @@ type SQL struct {
 	statementBuilder sq.StatementBuilderType
 
 	Log func(string, ...interface{})
+
+	// Keys, if set, encrypts new release records and decrypts encrypted
+	// ones. Unencrypted records remain readable.
+	Keys KeyProvider
+	// Metrics, if set, receives the size of every release record written.
+	Metrics Metrics
 }
 
 // Name returns the name of the driver.
@@ -224,7 +230,7 @@ func (s *SQL) Get(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := decodeReleaseWith(record.Body, s.Keys)
 	if err != nil {
 		s.Log("get: failed to decode data %q: %v", key, err)
 		return nil, err
@@ -259,7 +265,7 @@ func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := decodeReleaseWith(record.Body, s.Keys)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
@@ -272,6 +278,76 @@ func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 	return releases, nil
 }
 
+// ListLatest implements Paginator. The latest revision of every release is
+// selected, filtered and ordered by the database.
+func (s *SQL) ListLatest(opts ListOptions) ([]*rspb.Release, error) {
+	col := func(c string) string { return "r." + c }
+	sb := s.statementBuilder.
+		Select(col(sqlReleaseTableBodyColumn)).
+		From(sqlReleaseTableName + " AS r").
+		Where(sq.Eq{col(sqlReleaseTableOwnerColumn): sqlReleaseDefaultOwner})
+
+	if s.namespace != "" {
+		sb = sb.Where(sq.Eq{col(sqlReleaseTableNamespaceColumn): s.namespace})
+	}
+
+	sb = sb.Where(fmt.Sprintf(
+		"%s = (SELECT MAX(%s) FROM %s WHERE %s = %s AND %s = %s AND %s = %s)",
+		col(sqlReleaseTableVersionColumn),
+		sqlReleaseTableVersionColumn,
+		sqlReleaseTableName,
+		sqlReleaseTableNameColumn, col(sqlReleaseTableNameColumn),
+		sqlReleaseTableNamespaceColumn, col(sqlReleaseTableNamespaceColumn),
+		sqlReleaseTableOwnerColumn, col(sqlReleaseTableOwnerColumn),
+	))
+
+	if len(opts.Statuses) > 0 {
+		sb = sb.Where(sq.Eq{col(sqlReleaseTableStatusColumn): opts.Statuses})
+	}
+
+	cmp, order := ">", "ASC"
+	if opts.Descending {
+		cmp, order = "<", "DESC"
+	}
+	if opts.AfterName != "" {
+		sb = sb.Where(sq.Expr(
+			fmt.Sprintf("(%s, %s) %s (?, ?)", col(sqlReleaseTableNameColumn), col(sqlReleaseTableNamespaceColumn), cmp),
+			opts.AfterName, opts.AfterNamespace,
+		))
+	}
+	sb = sb.OrderBy(
+		col(sqlReleaseTableNameColumn)+" "+order,
+		col(sqlReleaseTableNamespaceColumn)+" "+order,
+	)
+	if opts.Limit > 0 {
+		sb = sb.Limit(uint64(opts.Limit))
+	}
+
+	query, args, err := sb.ToSql()
+	if err != nil {
+		s.Log("failed to build query: %v", err)
+		return nil, err
+	}
+
+	var records = []SQLReleaseWrapper{}
+	if err := s.db.Select(&records, query, args...); err != nil {
+		s.Log("list: failed to list: %v", err)
+		return nil, err
+	}
+
+	releases := make([]*rspb.Release, 0, len(records))
+	for _, record := range records {
+		release, err := decodeReleaseWith(record.Body, s.Keys)
+		if err != nil {
+			s.Log("list: failed to decode release: %v: %v", record, err)
+			continue
+		}
+		releases = append(releases, release)
+	}
+
+	return releases, nil
+}
+
 // Query returns the set of releases that match the provided set of labels.
 func (s *SQL) Query(labels map[string]string) ([]*rspb.Release, error) {
 	sb := s.statementBuilder.
@@ -316,7 +392,7 @@ func (s *SQL) Query(labels map[string]string) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := decodeReleaseWith(record.Body, s.Keys)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
@@ -339,11 +415,14 @@ func (s *SQL) Create(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := encodeReleaseWith(rls, CompressionGzip, s.Keys)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
 	}
+	if s.Metrics != nil {
+		s.Metrics.ObserveRecordSize(SQLDriverName, len(body))
+	}
 
 	transaction, err := s.db.Beginx()
 	if err != nil {
@@ -416,11 +495,14 @@ func (s *SQL) Update(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := encodeReleaseWith(rls, CompressionGzip, s.Keys)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
 	}
+	if s.Metrics != nil {
+		s.Metrics.ObserveRecordSize(SQLDriverName, len(body))
+	}
 
 	query, args, err := s.statementBuilder.
 		Update(sqlReleaseTableName).
@@ -473,7 +555,7 @@ func (s *SQL) Delete(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := decodeReleaseWith(record.Body, s.Keys)
 	if err != nil {
 		s.Log("failed to decode release %s: %v", key, err)
 		transaction.Rollback()
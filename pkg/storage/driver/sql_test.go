@@ -152,6 +152,60 @@ func TestSQLList(t *testing.T) {
 	}
 }
 
+func TestSQLListLatest(t *testing.T) {
+	body1, _ := encodeRelease(releaseStub("key-1", 2, "default", rspb.StatusDeployed))
+	body2, _ := encodeRelease(releaseStub("key-2", 1, "default", rspb.StatusFailed))
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	query := fmt.Sprintf(
+		"SELECT r.%s FROM %s AS r WHERE r.%s = $1 AND r.%s = $2 AND "+
+			"r.%s = (SELECT MAX(%s) FROM %s WHERE %s = r.%s AND %s = r.%s AND %s = r.%s) AND "+
+			"r.%s IN ($3,$4) AND (r.%s, r.%s) > ($5, $6) ORDER BY r.%s ASC, r.%s ASC LIMIT 3",
+		sqlReleaseTableBodyColumn,
+		sqlReleaseTableName,
+		sqlReleaseTableOwnerColumn,
+		sqlReleaseTableNamespaceColumn,
+		sqlReleaseTableVersionColumn,
+		sqlReleaseTableVersionColumn,
+		sqlReleaseTableName,
+		sqlReleaseTableNameColumn, sqlReleaseTableNameColumn,
+		sqlReleaseTableNamespaceColumn, sqlReleaseTableNamespaceColumn,
+		sqlReleaseTableOwnerColumn, sqlReleaseTableOwnerColumn,
+		sqlReleaseTableStatusColumn,
+		sqlReleaseTableNameColumn, sqlReleaseTableNamespaceColumn,
+		sqlReleaseTableNameColumn, sqlReleaseTableNamespaceColumn,
+	)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(sqlReleaseDefaultOwner, sqlDriver.namespace, "deployed", "failed", "key-0", "default").
+		WillReturnRows(
+			mock.NewRows([]string{
+				sqlReleaseTableBodyColumn,
+			}).
+				AddRow(body1).
+				AddRow(body2),
+		).RowsWillBeClosed()
+
+	rels, err := sqlDriver.ListLatest(ListOptions{
+		Statuses:       []string{"deployed", "failed"},
+		AfterName:      "key-0",
+		AfterNamespace: "default",
+		Limit:          3,
+	})
+	if err != nil {
+		t.Fatalf("Failed to list latest releases: %v", err)
+	}
+	if len(rels) != 2 || rels[0].Name != "key-1" || rels[1].Name != "key-2" {
+		t.Errorf("Expected releases key-1 and key-2, got %v", rels)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
 func TestSqlCreate(t *testing.T) {
 	vers := 1
 	name := "smug-pigeon"
@@ -21,48 +21,117 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
+	"io"
 	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
 	rspb "github.com/open-hand/helm/pkg/release"
 )
 
 var b64 = base64.StdEncoding
 
-var magicGzip = []byte{0x1f, 0x8b, 0x08}
+var (
+	magicGzip = []byte{0x1f, 0x8b, 0x08}
+	magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Compression is the algorithm used to compress a release record before it
+// is base64 encoded and stored.
+type Compression string
+
+const (
+	// CompressionGzip compresses release records with gzip. It is the default
+	// and can be read by every version of Helm 3.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses release records with zstd. It is faster and
+	// usually smaller than gzip, but records written with it cannot be read
+	// by versions of Helm that predate its introduction.
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression returns the Compression named by s. An empty string
+// selects the default, CompressionGzip.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case "":
+		return CompressionGzip, nil
+	case CompressionGzip, CompressionZstd:
+		return c, nil
+	default:
+		return "", errors.Errorf("unknown release compression %q", s)
+	}
+}
 
 // encodeRelease encodes a release returning a base64 encoded
 // gzipped string representation, or error.
 func encodeRelease(rls *rspb.Release) (string, error) {
+	return encodeReleaseWith(rls, CompressionGzip, nil)
+}
+
+// encodeReleaseWith encodes a release returning a base64 encoded string
+// representation compressed with c, or error. If kp is not nil the
+// compressed release is encrypted with a data key wrapped by kp.
+func encodeReleaseWith(rls *rspb.Release, c Compression, kp KeyProvider) (string, error) {
 	b, err := json.Marshal(rls)
 	if err != nil {
 		return "", err
 	}
 	var buf bytes.Buffer
-	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	var w io.WriteCloser
+	switch c {
+	case CompressionGzip, "":
+		w, err = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	case CompressionZstd:
+		w, err = zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	default:
+		err = errors.Errorf("unknown release compression %q", c)
+	}
 	if err != nil {
 		return "", err
 	}
 	if _, err = w.Write(b); err != nil {
 		return "", err
 	}
-	w.Close()
+	if err := w.Close(); err != nil {
+		return "", err
+	}
 
-	return b64.EncodeToString(buf.Bytes()), nil
+	out := buf.Bytes()
+	if kp != nil {
+		if out, err = seal(kp, out); err != nil {
+			return "", err
+		}
+	}
+	return b64.EncodeToString(out), nil
 }
 
 // decodeRelease decodes the bytes of data into a release
 // type. Data must contain a base64 encoded gzipped string of a
 // valid release, otherwise an error is returned.
 func decodeRelease(data string) (*rspb.Release, error) {
+	return decodeReleaseWith(data, nil)
+}
+
+// decodeReleaseWith decodes the bytes of data into a release type,
+// decrypting it with a data key unwrapped by kp if it was encrypted.
+func decodeReleaseWith(data string, kp KeyProvider) (*rspb.Release, error) {
 	// base64 decode string
 	b, err := b64.DecodeString(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if b, err = open(kp, b); err != nil {
+		return nil, err
+	}
+
 	// For backwards compatibility with releases that were stored before
-	// compression was introduced we skip decompression if the
-	// gzip magic header is not found
-	if bytes.Equal(b[0:3], magicGzip) {
+	// compression was introduced we skip decompression if neither
+	// the gzip nor the zstd magic header is found
+	switch {
+	case bytes.HasPrefix(b, magicGzip):
 		r, err := gzip.NewReader(bytes.NewReader(b))
 		if err != nil {
 			return nil, err
@@ -73,6 +142,17 @@ func decodeRelease(data string) (*rspb.Release, error) {
 			return nil, err
 		}
 		b = b2
+	case bytes.HasPrefix(b, magicZstd):
+		r, err := zstd.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		b2, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		b = b2
 	}
 
 	var rls rspb.Release
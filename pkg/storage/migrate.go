@@ -0,0 +1,54 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// MoveReleases moves the records of the releases matching filter from one
+// storage to another, such as from the namespaces of the releases to a
+// namespace dedicated to release history. Each record is created in to
+// before being deleted from from. A record already in to is only deleted
+// from from, so that an interrupted move can be resumed. It returns the
+// moved records, ordered by name and revision.
+func MoveReleases(from, to *Storage, filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	releases, err := from.list(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the releases to move")
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Name != releases[j].Name {
+			return releases[i].Name < releases[j].Name
+		}
+		return releases[i].Version < releases[j].Version
+	})
+	for _, rls := range releases {
+		if err := to.Create(rls); err != nil && !errors.Is(err, driver.ErrReleaseExists) {
+			return nil, errors.Wrapf(err, "unable to store revision %d of release %q", rls.Version, rls.Name)
+		}
+		if _, err := from.Delete(rls.Name, rls.Version); err != nil {
+			return nil, errors.Wrapf(err, "unable to delete revision %d of release %q", rls.Version, rls.Name)
+		}
+	}
+	return releases, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"fmt"
+	"testing"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+func TestMoveReleases(t *testing.T) {
+	from := Init(driver.NewMemory())
+	to := Init(driver.NewMemory())
+	for _, rls := range []ReleaseTestData{
+		{Name: "frontend", Version: 2, Namespace: "apps", Status: rspb.StatusDeployed},
+		{Name: "frontend", Version: 1, Namespace: "apps", Status: rspb.StatusSuperseded},
+		{Name: "backend", Version: 1, Namespace: "apps", Status: rspb.StatusDeployed},
+	} {
+		assertErrNil(t.Fatal, from.Create(rls.ToRelease()), "Storing release")
+	}
+	// a revision left in to by an interrupted move
+	assertErrNil(t.Fatal, to.Create(ReleaseTestData{Name: "frontend", Version: 1, Namespace: "apps", Status: rspb.StatusSuperseded}.ToRelease()), "Storing release")
+
+	moved, err := MoveReleases(from, to, func(rls *rspb.Release) bool { return rls.Namespace == "apps" })
+	assertErrNil(t.Fatal, err, "Moving releases")
+
+	var got []string
+	for _, rls := range moved {
+		got = append(got, fmt.Sprintf("%s.%d", rls.Name, rls.Version))
+	}
+	if len(got) != 3 || got[0] != "backend.1" || got[1] != "frontend.1" || got[2] != "frontend.2" {
+		t.Errorf("expected the moved revisions ordered by name and revision, got %v", got)
+	}
+
+	left, err := from.ListReleases()
+	assertErrNil(t.Fatal, err, "Listing releases")
+	if len(left) != 0 {
+		t.Errorf("expected no release left in the source, got %d", len(left))
+	}
+	stored, err := to.ListReleases()
+	assertErrNil(t.Fatal, err, "Listing releases")
+	if len(stored) != 3 {
+		t.Errorf("expected the 3 revisions in the destination, got %d", len(stored))
+	}
+}
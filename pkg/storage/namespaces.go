@@ -0,0 +1,90 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+)
+
+// DefaultListParallelism is the number of namespaces ListNamespaces queries
+// at a time when no parallelism is given.
+const DefaultListParallelism = 8
+
+// NamespaceError records why the releases of a namespace could not be listed.
+type NamespaceError struct {
+	Namespace string
+	Err       error
+}
+
+func (e *NamespaceError) Error() string {
+	return fmt.Sprintf("namespace %q: %s", e.Namespace, e.Err)
+}
+
+func (e *NamespaceError) Unwrap() error { return e.Err }
+
+// ListNamespaces lists the releases matching filter in each of namespaces,
+// using the storage returned by forNamespace and querying up to parallelism
+// namespaces at a time.
+//
+// Namespaces in which the caller is not allowed to list releases are skipped
+// and returned, in the order given, alongside the releases of the others. Any
+// other error fails the listing.
+func ListNamespaces(namespaces []string, forNamespace func(namespace string) *Storage, parallelism int, filter func(*rspb.Release) bool) ([]*rspb.Release, []*NamespaceError, error) {
+	if parallelism <= 0 {
+		parallelism = DefaultListParallelism
+	}
+
+	results := make([][]*rspb.Release, len(namespaces))
+	errs := make([]error, len(namespaces))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, ns := range namespaces {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ns string) {
+			defer func() { <-sem; wg.Done() }()
+			results[i], errs[i] = forNamespace(ns).list(filter)
+		}(i, ns)
+	}
+	wg.Wait()
+
+	var (
+		releases []*rspb.Release
+		skipped  []*NamespaceError
+		failed   []*NamespaceError
+	)
+	for i, ns := range namespaces {
+		switch err := errs[i]; {
+		case err == nil:
+			releases = append(releases, results[i]...)
+		case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+			skipped = append(skipped, &NamespaceError{Namespace: ns, Err: err})
+		default:
+			failed = append(failed, &NamespaceError{Namespace: ns, Err: err})
+		}
+	}
+	if len(failed) > 0 {
+		return nil, skipped, errors.Errorf("encountered %d errors listing releases. First is: %s", len(failed), failed[0])
+	}
+	return releases, skipped, nil
+}
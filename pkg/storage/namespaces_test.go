@@ -0,0 +1,78 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+	"github.com/open-hand/helm/pkg/storage/driver"
+)
+
+// failingListDriver is a driver whose List always fails with err.
+type failingListDriver struct {
+	*driver.Memory
+	err error
+}
+
+func (d *failingListDriver) List(_ func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	return nil, d.err
+}
+
+func TestListNamespaces(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", errors.New("denied"))
+	stores := map[string]*Storage{
+		"a": Init(driver.NewMemory()),
+		"b": Init(&failingListDriver{Memory: driver.NewMemory(), err: errors.Wrap(forbidden, "list: failed to list")}),
+		"c": Init(driver.NewMemory()),
+	}
+	for _, ns := range []string{"a", "c"} {
+		rls := ReleaseTestData{Name: "rel-" + ns, Version: 1, Namespace: ns, Status: rspb.StatusDeployed}.ToRelease()
+		if err := stores[ns].Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	forNamespace := func(ns string) *Storage { return stores[ns] }
+	all := func(*rspb.Release) bool { return true }
+
+	rels, skipped, err := ListNamespaces([]string{"a", "b", "c"}, forNamespace, 2, all)
+	if err != nil {
+		t.Fatalf("Failed to list namespaces: %s", err)
+	}
+	var names []string
+	for _, rls := range rels {
+		names = append(names, rls.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "rel-a" || names[1] != "rel-c" {
+		t.Errorf("Expected releases rel-a and rel-c, got %v", names)
+	}
+	if len(skipped) != 1 || skipped[0].Namespace != "b" || !apierrors.IsForbidden(skipped[0]) {
+		t.Errorf("Expected namespace b to be skipped as forbidden, got %v", skipped)
+	}
+
+	// errors other than access denials fail the listing
+	stores["c"] = Init(&failingListDriver{Memory: driver.NewMemory(), err: errors.New("connection refused")})
+	if _, _, err := ListNamespaces([]string{"a", "b", "c"}, forNamespace, 0, all); err == nil {
+		t.Error("Expected an error listing namespace c")
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"time"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+	relutil "github.com/open-hand/helm/pkg/releaseutil"
+)
+
+// RetentionPolicy describes which revisions of a release are kept when its
+// history is pruned.
+//
+// The most recent revision and any revision that is deployed or has an
+// operation in progress are always kept. Of the remaining revisions, those
+// selected by any of the rules below are kept and all others are pruned.
+type RetentionPolicy struct {
+	// KeepSuperseded is the number of most recent superseded revisions to
+	// keep. A negative value keeps all superseded revisions.
+	KeepSuperseded int
+	// KeepYoungerThan keeps every revision last deployed within this
+	// duration. Zero disables the rule.
+	KeepYoungerThan time.Duration
+	// KeepLastSuccessful keeps the most recent revision that was deployed
+	// successfully, even if it has since been superseded.
+	KeepLastSuccessful bool
+	// KeepLastFailed keeps the most recent failed revision.
+	KeepLastFailed bool
+}
+
+// DefaultRetentionPolicy is the policy used when none is configured.
+var DefaultRetentionPolicy = RetentionPolicy{
+	KeepSuperseded:     10,
+	KeepLastSuccessful: true,
+	KeepLastFailed:     true,
+}
+
+// Select returns the revisions of a single release's history that the
+// policy does not keep, oldest first. now is the time against which
+// KeepYoungerThan is evaluated.
+func (p RetentionPolicy) Select(history []*rspb.Release, now time.Time) []*rspb.Release {
+	if len(history) == 0 {
+		return nil
+	}
+	h := make([]*rspb.Release, len(history))
+	copy(h, history)
+	// newest first
+	relutil.Reverse(h, relutil.SortByRevision)
+
+	keep := make(map[int]bool)
+	keep[h[0].Version] = true
+
+	superseded := 0
+	lastSuccessful, lastFailed := false, false
+	for _, rel := range h {
+		status := rel.Info.Status
+		if status == rspb.StatusDeployed || status.IsPending() || status == rspb.StatusUninstalling {
+			keep[rel.Version] = true
+		}
+		if p.KeepYoungerThan > 0 && now.Sub(rel.Info.LastDeployed.Time) < p.KeepYoungerThan {
+			keep[rel.Version] = true
+		}
+		if p.KeepLastSuccessful && !lastSuccessful && (status == rspb.StatusDeployed || status == rspb.StatusSuperseded) {
+			keep[rel.Version] = true
+			lastSuccessful = true
+		}
+		if p.KeepLastFailed && !lastFailed && status == rspb.StatusFailed {
+			keep[rel.Version] = true
+			lastFailed = true
+		}
+		if status == rspb.StatusSuperseded && !keep[rel.Version] {
+			if p.KeepSuperseded < 0 || superseded < p.KeepSuperseded {
+				keep[rel.Version] = true
+			}
+			superseded++
+		}
+	}
+
+	var prune []*rspb.Release
+	for i := len(h) - 1; i >= 0; i-- {
+		if !keep[h[i].Version] {
+			prune = append(prune, h[i])
+		}
+	}
+	return prune
+}
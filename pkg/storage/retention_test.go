@@ -0,0 +1,106 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage // import "github.com/open-hand/helm/pkg/storage"
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	rspb "github.com/open-hand/helm/pkg/release"
+	helmtime "github.com/open-hand/helm/pkg/time"
+)
+
+func TestRetentionPolicySelect(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	rel := func(version int, status rspb.Status, age time.Duration) *rspb.Release {
+		return &rspb.Release{
+			Name:    "angry-bird",
+			Version: version,
+			Info: &rspb.Info{
+				Status:       status,
+				LastDeployed: helmtime.Time{Time: now.Add(-age)},
+			},
+		}
+	}
+	day := 24 * time.Hour
+	history := []*rspb.Release{
+		rel(1, rspb.StatusSuperseded, 9*day),
+		rel(2, rspb.StatusFailed, 8*day),
+		rel(3, rspb.StatusSuperseded, 7*day),
+		rel(4, rspb.StatusFailed, 6*day),
+		rel(5, rspb.StatusSuperseded, 5*day),
+		rel(6, rspb.StatusSuperseded, 4*day),
+		rel(7, rspb.StatusFailed, 3*day),
+		rel(8, rspb.StatusDeployed, 2*day),
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		pruned []int
+	}{{
+		name:   "keep nothing optional",
+		policy: RetentionPolicy{},
+		pruned: []int{1, 2, 3, 4, 5, 6, 7},
+	}, {
+		name:   "keep superseded",
+		policy: RetentionPolicy{KeepSuperseded: 2},
+		pruned: []int{1, 2, 3, 4, 7},
+	}, {
+		name:   "keep all superseded",
+		policy: RetentionPolicy{KeepSuperseded: -1},
+		pruned: []int{2, 4, 7},
+	}, {
+		name:   "keep last failed",
+		policy: RetentionPolicy{KeepLastFailed: true},
+		pruned: []int{1, 2, 3, 4, 5, 6},
+	}, {
+		name:   "keep young revisions",
+		policy: RetentionPolicy{KeepYoungerThan: 5*day + time.Hour},
+		pruned: []int{1, 2, 3, 4},
+	}, {
+		name:   "default policy",
+		policy: DefaultRetentionPolicy,
+		pruned: []int{2, 4},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, r := range tt.policy.Select(history, now) {
+				got = append(got, r.Version)
+			}
+			if !reflect.DeepEqual(got, tt.pruned) {
+				t.Errorf("expected %v to be pruned, got %v", tt.pruned, got)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyKeepsLastSuccessful(t *testing.T) {
+	history := []*rspb.Release{
+		{Name: "angry-bird", Version: 1, Info: &rspb.Info{Status: rspb.StatusSuperseded}},
+		{Name: "angry-bird", Version: 2, Info: &rspb.Info{Status: rspb.StatusSuperseded}},
+		{Name: "angry-bird", Version: 3, Info: &rspb.Info{Status: rspb.StatusFailed}},
+	}
+	policy := RetentionPolicy{KeepLastSuccessful: true}
+	pruned := policy.Select(history, time.Now())
+	if len(pruned) != 1 || pruned[0].Version != 1 {
+		t.Errorf("expected only revision 1 to be pruned, got %v", pruned)
+	}
+}
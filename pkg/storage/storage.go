@@ -19,6 +19,7 @@ package storage // import "github.com/open-hand/helm/pkg/storage"
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -43,6 +44,15 @@ type Storage struct {
 	// ignored (meaning no limits are imposed).
 	MaxHistory int
 
+	// Metrics, if set, receives the outcome and duration of every driver
+	// operation. Drivers that encode release records additionally report
+	// record sizes through their own Metrics field.
+	Metrics driver.Metrics
+
+	// SlowThreshold is the duration above which a driver operation is
+	// logged as slow. Values of 0 or less disable the warning.
+	SlowThreshold time.Duration
+
 	Log func(string, ...interface{})
 }
 
@@ -51,7 +61,10 @@ type Storage struct {
 // release identified by the key, version pair does not exist.
 func (s *Storage) Get(name string, version int) (*rspb.Release, error) {
 	s.Log("getting release %q", makeKey(name, version))
-	return s.Driver.Get(makeKey(name, version))
+	start := time.Now()
+	rls, err := s.Driver.Get(makeKey(name, version))
+	s.observe("get", start, err)
+	return rls, err
 }
 
 // Create creates a new storage entry holding the release. An
@@ -66,7 +79,10 @@ func (s *Storage) Create(rls *rspb.Release) error {
 			return err
 		}
 	}
-	return s.Driver.Create(makeKey(rls.Name, rls.Version), rls)
+	start := time.Now()
+	err := s.Driver.Create(makeKey(rls.Name, rls.Version), rls)
+	s.observe("create", start, err)
+	return err
 }
 
 // Update updates the release in storage. An error is returned if the
@@ -74,7 +90,10 @@ func (s *Storage) Create(rls *rspb.Release) error {
 // does not exist.
 func (s *Storage) Update(rls *rspb.Release) error {
 	s.Log("updating release %q", makeKey(rls.Name, rls.Version))
-	return s.Driver.Update(makeKey(rls.Name, rls.Version), rls)
+	start := time.Now()
+	err := s.Driver.Update(makeKey(rls.Name, rls.Version), rls)
+	s.observe("update", start, err)
+	return err
 }
 
 // Delete deletes the release from storage. An error is returned if
@@ -82,21 +101,24 @@ func (s *Storage) Update(rls *rspb.Release) error {
 // does not exist.
 func (s *Storage) Delete(name string, version int) (*rspb.Release, error) {
 	s.Log("deleting release %q", makeKey(name, version))
-	return s.Driver.Delete(makeKey(name, version))
+	start := time.Now()
+	rls, err := s.Driver.Delete(makeKey(name, version))
+	s.observe("delete", start, err)
+	return rls, err
 }
 
 // ListReleases returns all releases from storage. An error is returned if the
 // storage backend fails to retrieve the releases.
 func (s *Storage) ListReleases() ([]*rspb.Release, error) {
 	s.Log("listing all releases in storage")
-	return s.Driver.List(func(_ *rspb.Release) bool { return true })
+	return s.list(func(_ *rspb.Release) bool { return true })
 }
 
 // ListUninstalled returns all releases with Status == UNINSTALLED. An error is returned
 // if the storage backend fails to retrieve the releases.
 func (s *Storage) ListUninstalled() ([]*rspb.Release, error) {
 	s.Log("listing uninstalled releases in storage")
-	return s.Driver.List(func(rls *rspb.Release) bool {
+	return s.list(func(rls *rspb.Release) bool {
 		return relutil.StatusFilter(rspb.StatusUninstalled).Check(rls)
 	})
 }
@@ -105,11 +127,26 @@ func (s *Storage) ListUninstalled() ([]*rspb.Release, error) {
 // if the storage backend fails to retrieve the releases.
 func (s *Storage) ListDeployed() ([]*rspb.Release, error) {
 	s.Log("listing all deployed releases in storage")
-	return s.Driver.List(func(rls *rspb.Release) bool {
+	return s.list(func(rls *rspb.Release) bool {
 		return relutil.StatusFilter(rspb.StatusDeployed).Check(rls)
 	})
 }
 
+// ListLatest returns the latest revision of every release matching opts,
+// ordered by name, if the driver implements driver.Paginator. Otherwise ok is
+// false and the caller is expected to fall back to ListReleases.
+func (s *Storage) ListLatest(opts driver.ListOptions) (rls []*rspb.Release, ok bool, err error) {
+	p, ok := s.Driver.(driver.Paginator)
+	if !ok {
+		return nil, false, nil
+	}
+	s.Log("listing latest releases in storage")
+	start := time.Now()
+	rls, err = p.ListLatest(opts)
+	s.observe("list", start, err)
+	return rls, true, err
+}
+
 // Deployed returns the last deployed release with the provided release name, or
 // returns ErrReleaseNotFound if not found.
 func (s *Storage) Deployed(name string) (*rspb.Release, error) {
@@ -153,7 +190,7 @@ func (s *Storage) Installed(name string) (*rspb.Release, error) {
 func (s *Storage) InstalledAll(name string) ([]*rspb.Release, error) {
 	s.Log("getting deployed releases from %q history", name)
 
-	ls, err := s.Driver.Query(map[string]string{
+	ls, err := s.query(map[string]string{
 		"name":  name,
 		"owner": "helm",
 	})
@@ -171,7 +208,7 @@ func (s *Storage) InstalledAll(name string) ([]*rspb.Release, error) {
 func (s *Storage) DeployedAll(name string) ([]*rspb.Release, error) {
 	s.Log("getting deployed releases from %q history", name)
 
-	ls, err := s.Driver.Query(map[string]string{
+	ls, err := s.query(map[string]string{
 		"name":   name,
 		"owner":  "helm",
 		"status": "deployed",
@@ -190,7 +227,33 @@ func (s *Storage) DeployedAll(name string) ([]*rspb.Release, error) {
 func (s *Storage) History(name string) ([]*rspb.Release, error) {
 	s.Log("getting release history for %q", name)
 
-	return s.Driver.Query(map[string]string{"name": name, "owner": "helm"})
+	return s.query(map[string]string{"name": name, "owner": "helm"})
+}
+
+func (s *Storage) list(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	start := time.Now()
+	ls, err := s.Driver.List(filter)
+	s.observe("list", start, err)
+	return ls, err
+}
+
+func (s *Storage) query(labels map[string]string) ([]*rspb.Release, error) {
+	start := time.Now()
+	ls, err := s.Driver.Query(labels)
+	s.observe("query", start, err)
+	return ls, err
+}
+
+// observe reports a driver operation that began at start to s.Metrics and
+// logs a warning if it took longer than s.SlowThreshold.
+func (s *Storage) observe(op string, start time.Time, err error) {
+	d := time.Since(start)
+	if s.Metrics != nil {
+		s.Metrics.ObserveOperation(s.Driver.Name(), op, d, err)
+	}
+	if s.SlowThreshold > 0 && d >= s.SlowThreshold {
+		s.Log("WARNING: storage %s with the %s driver took %s", op, s.Driver.Name(), d)
+	}
 }
 
 // removeLeastRecent removes items from history until the length number of releases
@@ -19,7 +19,9 @@ package storage // import "github.com/open-hand/helm/pkg/storage"
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -558,3 +560,59 @@ func assertErrNil(eh func(args ...interface{}), err error, message string) {
 		eh(fmt.Sprintf("%s: %q", message, err))
 	}
 }
+
+type recordingMetrics struct {
+	ops   []string
+	sizes []int
+}
+
+func (m *recordingMetrics) ObserveOperation(driver, op string, _ time.Duration, err error) {
+	m.ops = append(m.ops, fmt.Sprintf("%s/%s/%t", driver, op, err == nil))
+}
+
+func (m *recordingMetrics) ObserveRecordSize(_ string, size int) {
+	m.sizes = append(m.sizes, size)
+}
+
+func TestStorageMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	var logged []string
+	storage := Init(driver.NewMemory())
+	storage.Metrics = metrics
+	storage.SlowThreshold = time.Nanosecond
+	storage.Log = func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}
+
+	rls := ReleaseTestData{Name: "angry-beaver", Version: 1, Status: rspb.StatusDeployed}.ToRelease()
+	assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+	assertErrNil(t.Fatal, storage.Update(rls), "UpdateRelease")
+	if _, err := storage.Get(rls.Name, 2); err == nil {
+		t.Fatal("expected get of a missing revision to fail")
+	}
+	_, err := storage.History(rls.Name)
+	assertErrNil(t.Fatal, err, "History")
+	_, err = storage.ListReleases()
+	assertErrNil(t.Fatal, err, "ListReleases")
+
+	expected := []string{
+		"Memory/create/true",
+		"Memory/update/true",
+		"Memory/get/false",
+		"Memory/query/true",
+		"Memory/list/true",
+	}
+	if !reflect.DeepEqual(metrics.ops, expected) {
+		t.Errorf("expected operations %v, got %v", expected, metrics.ops)
+	}
+
+	slow := 0
+	for _, l := range logged {
+		if strings.HasPrefix(l, "WARNING: storage ") {
+			slow++
+		}
+	}
+	if slow != len(expected) {
+		t.Errorf("expected %d slow operation warnings, got %d: %v", len(expected), slow, logged)
+	}
+}